@@ -10,4 +10,8 @@ type Blobstore interface {
 	Validate() (err error)
 
 	Delete(blobId string) (err error)
+
+	// Copy stores the contents of one blob under another blob ID, using
+	// the provider's copy operation when it has one
+	Copy(srcBlobID, dstBlobID string) (err error)
 }
@@ -0,0 +1,373 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// PrefetchingBlobstore keeps downloaded blobs in a local cache
+// that can be warmed in the background
+type PrefetchingBlobstore interface {
+	DigestBlobstore
+
+	// Prefetch downloads blobs that are not cached yet with at most
+	// concurrency downloads at a time. Canceling ctx stops starting
+	// downloads, downloads in progress are finished.
+	Prefetch(ctx context.Context, blobs []PrefetchBlob, concurrency int) PrefetchJob
+}
+
+type PrefetchBlob struct {
+	BlobID string
+	Digest boshcrypto.Digest
+}
+
+type PrefetchProgress struct {
+	Total  int
+	Done   int
+	Failed int
+}
+
+type PrefetchJob interface {
+	Progress() PrefetchProgress
+
+	// Done is closed once every blob was cached, failed or skipped
+	Done() <-chan struct{}
+
+	// Wait returns the errors of failed downloads, or ctx.Err()
+	// when blobs were skipped because of cancellation
+	Wait() error
+}
+
+type cachingBlobstore struct {
+	blobstore DigestBlobstore
+	fs        boshsys.FileSystem
+	cacheDir  string
+
+	// inFlight has a channel per blob that is being downloaded
+	// which is closed when the download finished
+	inFlight     map[string]chan struct{}
+	inFlightLock sync.Mutex
+
+	// copies are files handed out from the cache to be cleaned up here
+	copies     map[string]bool
+	copiesLock sync.Mutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewCachingBlobstore keeps a copy of every blob it gets in cacheDir and
+// serves it from there as long as it matches the requested digest.
+// Getting a blob that is being prefetched waits for that download.
+func NewCachingBlobstore(blobstore DigestBlobstore, fs boshsys.FileSystem, cacheDir string, logger boshlog.Logger) PrefetchingBlobstore {
+	return &cachingBlobstore{
+		blobstore: blobstore,
+		fs:        fs,
+		cacheDir:  cacheDir,
+		inFlight:  map[string]chan struct{}{},
+		copies:    map[string]bool{},
+		logTag:    "cachingBlobstore",
+		logger:    logger,
+	}
+}
+
+func (b *cachingBlobstore) Get(blobID string, digest boshcrypto.Digest) (string, error) {
+	release := b.claim(blobID)
+	defer release()
+
+	fileName, found, err := b.getCached(blobID, digest)
+	if err != nil || found {
+		return fileName, err
+	}
+
+	fileName, err = b.blobstore.Get(blobID, digest)
+	if err != nil {
+		return "", err
+	}
+
+	err = b.store(blobID, fileName)
+	if err != nil {
+		b.logger.Warn(b.logTag, "Caching blob '%s': %s", blobID, err)
+	}
+
+	return fileName, nil
+}
+
+// GetWithOpts serves blobs from the cache only when they are not decompressed
+func (b *cachingBlobstore) GetWithOpts(blobID string, digest boshcrypto.Digest, opts GetOpts) (string, error) {
+	if !opts.Decompress {
+		return b.Get(blobID, digest)
+	}
+
+	return b.blobstore.GetWithOpts(blobID, digest, opts)
+}
+
+func (b *cachingBlobstore) CleanUp(fileName string) error {
+	b.copiesLock.Lock()
+	isCopy := b.copies[fileName]
+	delete(b.copies, fileName)
+	b.copiesLock.Unlock()
+
+	if isCopy {
+		return b.fs.RemoveAll(fileName)
+	}
+
+	return b.blobstore.CleanUp(fileName)
+}
+
+func (b *cachingBlobstore) Create(fileName string) (string, boshcrypto.MultipleDigest, error) {
+	return b.blobstore.Create(fileName)
+}
+
+func (b *cachingBlobstore) Validate() error {
+	return b.blobstore.Validate()
+}
+
+func (b *cachingBlobstore) Delete(blobID string) error {
+	err := b.fs.RemoveAll(b.cachePath(blobID))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing cached blob '%s'", blobID)
+	}
+
+	return b.blobstore.Delete(blobID)
+}
+
+// Copy drops a cached blob with the destination ID since it is replaced
+func (b *cachingBlobstore) Copy(srcBlobID, dstBlobID string) error {
+	err := b.fs.RemoveAll(b.cachePath(dstBlobID))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing cached blob '%s'", dstBlobID)
+	}
+
+	return b.blobstore.Copy(srcBlobID, dstBlobID)
+}
+
+func (b *cachingBlobstore) Prefetch(ctx context.Context, blobs []PrefetchBlob, concurrency int) PrefetchJob {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	job := &prefetchJob{
+		progress: PrefetchProgress{Total: len(blobs)},
+		doneCh:   make(chan struct{}),
+	}
+
+	queue := make(chan PrefetchBlob)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for blob := range queue {
+				job.finish(blob.BlobID, b.prefetch(blob))
+			}
+		}()
+	}
+
+	go func() {
+		defer close(job.doneCh)
+
+	feed:
+		for _, blob := range blobs {
+			if ctx.Err() != nil {
+				job.cancel(ctx.Err())
+				break
+			}
+
+			select {
+			case queue <- blob:
+			case <-ctx.Done():
+				job.cancel(ctx.Err())
+				break feed
+			}
+		}
+
+		close(queue)
+		wg.Wait()
+	}()
+
+	return job
+}
+
+func (b *cachingBlobstore) prefetch(blob PrefetchBlob) error {
+	release := b.claim(blob.BlobID)
+	defer release()
+
+	if b.isCached(blob.BlobID, blob.Digest) {
+		return nil
+	}
+
+	b.logger.Debug(b.logTag, "Prefetching blob '%s'", blob.BlobID)
+
+	fileName, err := b.blobstore.Get(blob.BlobID, blob.Digest)
+	if err != nil {
+		return err
+	}
+
+	defer b.blobstore.CleanUp(fileName)
+
+	return b.store(blob.BlobID, fileName)
+}
+
+// claim waits for other downloads of blobID and returns
+// a function to let the next one start
+func (b *cachingBlobstore) claim(blobID string) (release func()) {
+	for {
+		b.inFlightLock.Lock()
+		ch, found := b.inFlight[blobID]
+		if !found {
+			ch = make(chan struct{})
+			b.inFlight[blobID] = ch
+			b.inFlightLock.Unlock()
+			break
+		}
+		b.inFlightLock.Unlock()
+
+		<-ch
+	}
+
+	return func() {
+		b.inFlightLock.Lock()
+		close(b.inFlight[blobID])
+		delete(b.inFlight, blobID)
+		b.inFlightLock.Unlock()
+	}
+}
+
+func (b *cachingBlobstore) isCached(blobID string, digest boshcrypto.Digest) bool {
+	cachePath := b.cachePath(blobID)
+
+	if !b.fs.FileExists(cachePath) {
+		return false
+	}
+
+	err := digest.VerifyFilePath(cachePath, b.fs)
+	if err != nil {
+		b.logger.Warn(b.logTag, "Discarding cached blob '%s': %s", blobID, err)
+		b.fs.RemoveAll(cachePath)
+		return false
+	}
+
+	return true
+}
+
+func (b *cachingBlobstore) getCached(blobID string, digest boshcrypto.Digest) (string, bool, error) {
+	if !b.isCached(blobID, digest) {
+		return "", false, nil
+	}
+
+	file, err := b.fs.TempFile("bosh-blobstore-cachingBlobstore-Get")
+	if err != nil {
+		return "", false, bosherr.WrapError(err, "Creating temporary file")
+	}
+
+	fileName := file.Name()
+	file.Close()
+
+	err = b.fs.CopyFile(b.cachePath(blobID), fileName)
+	if err != nil {
+		b.fs.RemoveAll(fileName)
+		return "", false, bosherr.WrapErrorf(err, "Copying cached blob '%s'", blobID)
+	}
+
+	b.copiesLock.Lock()
+	b.copies[fileName] = true
+	b.copiesLock.Unlock()
+
+	return fileName, true, nil
+}
+
+func (b *cachingBlobstore) store(blobID, fileName string) error {
+	err := b.fs.MkdirAll(b.cacheDir, os.FileMode(0700))
+	if err != nil {
+		return bosherr.WrapError(err, "Creating cache directory")
+	}
+
+	cachePath := b.cachePath(blobID)
+	tmpPath := cachePath + ".tmp"
+
+	err = b.fs.CopyFile(fileName, tmpPath)
+	if err == nil {
+		err = b.fs.Rename(tmpPath, cachePath)
+	}
+	if err != nil {
+		b.fs.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Caching blob '%s'", blobID)
+	}
+
+	return nil
+}
+
+// cachePath does not use blob IDs as file names since some
+// blobstores use IDs that contain path separators
+func (b *cachingBlobstore) cachePath(blobID string) string {
+	sum := sha256.Sum256([]byte(blobID))
+	return filepath.Join(b.cacheDir, hex.EncodeToString(sum[:]))
+}
+
+type prefetchJob struct {
+	progress PrefetchProgress
+	errs     []error
+	doneCh   chan struct{}
+	lock     sync.Mutex
+}
+
+func (j *prefetchJob) Progress() PrefetchProgress {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.progress
+}
+
+func (j *prefetchJob) Done() <-chan struct{} {
+	return j.doneCh
+}
+
+func (j *prefetchJob) Wait() error {
+	<-j.doneCh
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if len(j.errs) == 0 {
+		return nil
+	}
+
+	err := j.errs[0]
+	for _, nextErr := range j.errs[1:] {
+		err = bosherr.WrapComplexError(err, nextErr)
+	}
+
+	return bosherr.WrapError(err, "Prefetching blobs")
+}
+
+func (j *prefetchJob) finish(blobID string, err error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if err != nil {
+		j.progress.Failed++
+		j.errs = append(j.errs, bosherr.WrapErrorf(err, "Prefetching blob '%s'", blobID))
+		return
+	}
+
+	j.progress.Done++
+}
+
+func (j *prefetchJob) cancel(err error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.errs = append(j.errs, err)
+}
@@ -0,0 +1,210 @@
+package blobstore_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("cachingBlobstore", func() {
+	var (
+		inner     *fakeblob.FakeDigestBlobstore
+		fs        boshsys.FileSystem
+		cacheDir  string
+		blobstore boshblob.PrefetchingBlobstore
+
+		contents     map[string]string
+		getsLock     sync.Mutex
+		gets         map[string]int
+		blockGets    chan struct{}
+		blockedGetCh chan string
+	)
+
+	digestOf := func(s string) boshcrypto.Digest {
+		sum := sha1.Sum([]byte(s))
+		return boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, hex.EncodeToString(sum[:]))
+	}
+
+	readFile := func(path string) string {
+		bytes, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		return string(bytes)
+	}
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		cacheDir = filepath.Join(GinkgoT().TempDir(), "cache")
+		downloadDir := GinkgoT().TempDir()
+
+		contents = map[string]string{"blob-1": "one", "blob-2": "two", "blob-3": "three"}
+		gets = map[string]int{}
+		blockGets = nil
+		blockedGetCh = make(chan string, 10)
+
+		inner = &fakeblob.FakeDigestBlobstore{}
+		inner.GetStub = func(blobID string, _ boshcrypto.Digest) (string, error) {
+			getsLock.Lock()
+			gets[blobID]++
+			n := gets[blobID]
+			block := blockGets
+			getsLock.Unlock()
+
+			if block != nil {
+				blockedGetCh <- blobID
+				<-block
+			}
+
+			content, found := contents[blobID]
+			if !found {
+				return "", errors.New("fake-get-error")
+			}
+
+			path := filepath.Join(downloadDir, blobID+"-"+string(rune('0'+n)))
+			return path, os.WriteFile(path, []byte(content), 0644)
+		}
+		inner.CleanUpStub = func(fileName string) error {
+			return os.Remove(fileName)
+		}
+
+		blobstore = boshblob.NewCachingBlobstore(inner, fs, cacheDir, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	getCount := func(blobID string) int {
+		getsLock.Lock()
+		defer getsLock.Unlock()
+		return gets[blobID]
+	}
+
+	Describe("Get", func() {
+		It("serves blobs from the cache after the first download", func() {
+			first, err := blobstore.Get("blob-1", digestOf("one"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readFile(first)).To(Equal("one"))
+			Expect(blobstore.CleanUp(first)).To(Succeed())
+
+			second, err := blobstore.Get("blob-1", digestOf("one"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readFile(second)).To(Equal("one"))
+			Expect(getCount("blob-1")).To(Equal(1))
+
+			Expect(blobstore.CleanUp(second)).To(Succeed())
+			Expect(second).ToNot(BeAnExistingFile())
+			Expect(inner.CleanUpCallCount()).To(Equal(1))
+		})
+
+		It("downloads again when the cached blob does not match the digest", func() {
+			fileName, err := blobstore.Get("blob-1", digestOf("one"))
+			Expect(err).ToNot(HaveOccurred())
+			blobstore.CleanUp(fileName)
+
+			contents["blob-1"] = "changed"
+
+			fileName, err = blobstore.Get("blob-1", digestOf("changed"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readFile(fileName)).To(Equal("changed"))
+			Expect(getCount("blob-1")).To(Equal(2))
+		})
+
+		It("removes cached blobs on delete", func() {
+			fileName, err := blobstore.Get("blob-1", digestOf("one"))
+			Expect(err).ToNot(HaveOccurred())
+			blobstore.CleanUp(fileName)
+
+			Expect(blobstore.Delete("blob-1")).To(Succeed())
+			Expect(inner.DeleteCallCount()).To(Equal(1))
+
+			entries, err := os.ReadDir(cacheDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Describe("Prefetch", func() {
+		blobs := func(ids ...string) []boshblob.PrefetchBlob {
+			var result []boshblob.PrefetchBlob
+			for _, id := range ids {
+				result = append(result, boshblob.PrefetchBlob{BlobID: id, Digest: digestOf(contents[id])})
+			}
+			return result
+		}
+
+		It("warms the cache in the background and reports progress", func() {
+			job := blobstore.Prefetch(context.Background(), blobs("blob-1", "blob-2", "blob-3"), 2)
+			Expect(job.Wait()).To(Succeed())
+			Expect(job.Done()).To(BeClosed())
+			Expect(job.Progress()).To(Equal(boshblob.PrefetchProgress{Total: 3, Done: 3}))
+
+			for id, content := range contents {
+				fileName, err := blobstore.Get(id, digestOf(content))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(readFile(fileName)).To(Equal(content))
+				Expect(getCount(id)).To(Equal(1))
+			}
+
+			Expect(inner.CleanUpCallCount()).To(Equal(3))
+		})
+
+		It("reports failed downloads", func() {
+			job := blobstore.Prefetch(context.Background(), []boshblob.PrefetchBlob{
+				{BlobID: "blob-1", Digest: digestOf("one")},
+				{BlobID: "missing", Digest: digestOf("")},
+			}, 1)
+
+			err := job.Wait()
+			Expect(err).To(MatchError(ContainSubstring("Prefetching blob 'missing': fake-get-error")))
+			Expect(job.Progress()).To(Equal(boshblob.PrefetchProgress{Total: 2, Done: 1, Failed: 1}))
+		})
+
+		It("lets Get wait for a blob that is being prefetched", func() {
+			blockGets = make(chan struct{})
+
+			job := blobstore.Prefetch(context.Background(), blobs("blob-1"), 1)
+			Eventually(blockedGetCh).Should(Receive(Equal("blob-1")))
+
+			got := make(chan string)
+			go func() {
+				defer GinkgoRecover()
+				fileName, err := blobstore.Get("blob-1", digestOf("one"))
+				Expect(err).ToNot(HaveOccurred())
+				got <- fileName
+			}()
+
+			Consistently(got).ShouldNot(Receive())
+			close(blockGets)
+
+			var fileName string
+			Eventually(got).Should(Receive(&fileName))
+			Expect(readFile(fileName)).To(Equal("one"))
+			Expect(getCount("blob-1")).To(Equal(1))
+			Expect(job.Wait()).To(Succeed())
+		})
+
+		It("stops starting downloads when canceled", func() {
+			blockGets = make(chan struct{})
+			ctx, cancel := context.WithCancel(context.Background())
+
+			job := blobstore.Prefetch(ctx, blobs("blob-1", "blob-2", "blob-3"), 1)
+			Eventually(blockedGetCh).Should(Receive(Equal("blob-1")))
+
+			cancel()
+			close(blockGets)
+
+			Expect(job.Wait()).To(MatchError(ContainSubstring("context canceled")))
+			Expect(getCount("blob-3")).To(Equal(0))
+			Expect(job.Progress().Done).To(BeNumerically("<", 3))
+		})
+	})
+})
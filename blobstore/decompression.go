@@ -0,0 +1,140 @@
+package blobstore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	CompressionAuto = ""
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+type GetOpts struct {
+	// Decompress replaces gzip and zstd compressed blobs with their contents
+	// after the digest of the stored blob was verified
+	Decompress bool
+
+	// Compression is detected from the first bytes of the blob unless it is
+	// known from blob metadata. zstd blobs need the zstd CLI on the PATH.
+	Compression string
+}
+
+// blobDecompressor writes decompressed blobs to new temp files
+type blobDecompressor struct {
+	fs     boshsys.FileSystem
+	runner boshsys.CmdRunner
+}
+
+// decompress returns fileName itself when the blob is not compressed
+func (d blobDecompressor) decompress(fileName, compression string) (string, error) {
+	if compression == CompressionAuto {
+		var err error
+
+		compression, err = d.detect(fileName)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch compression {
+	case CompressionNone:
+		return fileName, nil
+	case CompressionGzip:
+		return d.gunzip(fileName)
+	case CompressionZstd:
+		return d.unzstd(fileName)
+	default:
+		return "", bosherr.Errorf("Unsupported blob compression '%s'", compression)
+	}
+}
+
+func (d blobDecompressor) detect(fileName string) (string, error) {
+	file, err := d.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Opening blob '%s'", fileName)
+	}
+
+	defer file.Close()
+
+	magic := make([]byte, len(zstdMagic))
+
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", bosherr.WrapErrorf(err, "Reading blob '%s'", fileName)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic[:n], gzipMagic):
+		return CompressionGzip, nil
+	case bytes.HasPrefix(magic[:n], zstdMagic):
+		return CompressionZstd, nil
+	default:
+		return CompressionNone, nil
+	}
+}
+
+func (d blobDecompressor) gunzip(fileName string) (string, error) {
+	src, err := d.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Opening blob '%s'", fileName)
+	}
+
+	defer src.Close()
+
+	reader, err := gzip.NewReader(bufio.NewReader(src))
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Reading gzip header of blob '%s'", fileName)
+	}
+
+	dst, err := d.fs.TempFile("bosh-blobstore-decompressed")
+	if err != nil {
+		return "", bosherr.WrapError(err, "Creating temporary file")
+	}
+
+	_, err = boshsys.CopyStream(dst, reader, boshsys.CopyOpts{})
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		d.fs.RemoveAll(dst.Name())
+		return "", bosherr.WrapErrorf(err, "Decompressing blob '%s'", fileName)
+	}
+
+	return dst.Name(), nil
+}
+
+func (d blobDecompressor) unzstd(fileName string) (string, error) {
+	if d.runner == nil || !d.runner.CommandExists("zstd") {
+		return "", bosherr.Errorf("Decompressing zstd blob '%s': zstd is not available", fileName)
+	}
+
+	dst, err := d.fs.TempFile("bosh-blobstore-decompressed")
+	if err != nil {
+		return "", bosherr.WrapError(err, "Creating temporary file")
+	}
+
+	dst.Close()
+
+	_, _, _, err = d.runner.RunCommand("zstd", "-d", "-q", "-f", "-o", dst.Name(), fileName)
+	if err != nil {
+		d.fs.RemoveAll(dst.Name())
+		return "", bosherr.WrapErrorf(err, "Decompressing blob '%s'", fileName)
+	}
+
+	return dst.Name(), nil
+}
@@ -0,0 +1,153 @@
+package blobstore_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("GetWithOpts decompression", func() {
+	var (
+		fs      boshsys.FileSystem
+		dir     string
+		inner   *fakeblob.FakeBlobstore
+		store   DigestBlobstore
+		blobs   map[string]string
+		cleaned []string
+	)
+
+	sha1Digest := func(contents []byte) boshcrypto.Digest {
+		sum := sha1.Sum(contents)
+		return boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, hex.EncodeToString(sum[:]))
+	}
+
+	gzipped := func(contents string) []byte {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		writer.Write([]byte(contents))
+		writer.Close()
+		return buf.Bytes()
+	}
+
+	addBlob := func(blobID string, contents []byte) boshcrypto.Digest {
+		path := filepath.Join(dir, blobID)
+		Expect(os.WriteFile(path, contents, 0644)).To(Succeed())
+		blobs[blobID] = path
+		return sha1Digest(contents)
+	}
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		dir = GinkgoT().TempDir()
+		blobs = map[string]string{}
+		cleaned = nil
+
+		inner = &fakeblob.FakeBlobstore{}
+		inner.GetStub = func(blobID string) (string, error) { return blobs[blobID], nil }
+		inner.CleanUpStub = func(fileName string) error {
+			cleaned = append(cleaned, fileName)
+			return nil
+		}
+
+		store = NewDigestVerifiableBlobstore(inner, fs, []boshcrypto.Algorithm{boshcrypto.DigestAlgorithmSHA1})
+	})
+
+	It("decompresses gzip blobs after verifying the digest of the stored blob", func() {
+		digest := addBlob("fake-blob-id", gzipped("fake-contents"))
+
+		fileName, err := store.GetWithOpts("fake-blob-id", digest, GetOpts{Decompress: true})
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(fileName)
+
+		contents, err := os.ReadFile(fileName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("fake-contents"))
+
+		Expect(cleaned).To(Equal([]string{blobs["fake-blob-id"]}))
+	})
+
+	It("does not verify the digest against the decompressed contents", func() {
+		addBlob("fake-blob-id", gzipped("fake-contents"))
+
+		_, err := store.GetWithOpts("fake-blob-id", sha1Digest([]byte("fake-contents")), GetOpts{Decompress: true})
+		Expect(err).To(MatchError(ContainSubstring("Checking downloaded blob 'fake-blob-id'")))
+	})
+
+	It("returns uncompressed blobs as they are", func() {
+		digest := addBlob("fake-blob-id", []byte("fake-contents"))
+
+		fileName, err := store.GetWithOpts("fake-blob-id", digest, GetOpts{Decompress: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileName).To(Equal(blobs["fake-blob-id"]))
+		Expect(cleaned).To(BeEmpty())
+	})
+
+	It("keeps compressed blobs unless asked to decompress", func() {
+		digest := addBlob("fake-blob-id", gzipped("fake-contents"))
+
+		fileName, err := store.Get("fake-blob-id", digest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileName).To(Equal(blobs["fake-blob-id"]))
+	})
+
+	It("uses the compression from blob metadata instead of detecting it", func() {
+		digest := addBlob("fake-blob-id", gzipped("fake-contents"))
+
+		fileName, err := store.GetWithOpts("fake-blob-id", digest, GetOpts{Decompress: true, Compression: CompressionNone})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileName).To(Equal(blobs["fake-blob-id"]))
+
+		_, err = store.GetWithOpts("fake-blob-id", digest, GetOpts{Decompress: true, Compression: "lz4"})
+		Expect(err).To(MatchError(ContainSubstring("Unsupported blob compression 'lz4'")))
+		Expect(cleaned).To(Equal([]string{blobs["fake-blob-id"]}))
+	})
+
+	Describe("zstd", func() {
+		var zstdBlob []byte
+
+		BeforeEach(func() {
+			zstdBlob = []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}
+		})
+
+		It("fails when the zstd CLI is not available", func() {
+			digest := addBlob("fake-blob-id", zstdBlob)
+
+			_, err := store.GetWithOpts("fake-blob-id", digest, GetOpts{Decompress: true})
+			Expect(err).To(MatchError(ContainSubstring("zstd is not available")))
+		})
+
+		It("decompresses with the zstd CLI of blobstores from the provider", func() {
+			runner := fakesys.NewFakeCmdRunner()
+			runner.AvailableCommands["zstd"] = true
+
+			provider := NewProvider(fs, runner, dir, boshlog.NewLogger(boshlog.LevelNone))
+
+			localDir := filepath.Join(dir, "local")
+			Expect(os.MkdirAll(localDir, 0750)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(localDir, "fake-blob-id"), zstdBlob, 0644)).To(Succeed())
+
+			store, err := provider.Get(BlobstoreTypeLocal, map[string]interface{}{"blobstore_path": localDir})
+			Expect(err).ToNot(HaveOccurred())
+
+			fileName, err := store.GetWithOpts("fake-blob-id", sha1Digest(zstdBlob), GetOpts{Decompress: true})
+			Expect(err).ToNot(HaveOccurred())
+			defer os.Remove(fileName)
+
+			Expect(runner.RunCommands).To(HaveLen(1))
+			Expect(runner.RunCommands[0][:6]).To(Equal([]string{"zstd", "-d", "-q", "-f", "-o", fileName}))
+		})
+	})
+})
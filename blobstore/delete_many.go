@@ -0,0 +1,130 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BlobDeleter is implemented by Blobstore and DigestBlobstore
+type BlobDeleter interface {
+	Delete(blobID string) error
+}
+
+// DeleteManyError reports the blobs DeleteMany could not delete.
+// Skipped blobs were not attempted because ctx was canceled.
+type DeleteManyError struct {
+	Total   int
+	Failed  map[string]error
+	Skipped []string
+	Err     error
+}
+
+func (e DeleteManyError) Error() string {
+	msg := fmt.Sprintf("Deleting %d of %d blobs failed", len(e.Failed), e.Total)
+
+	if len(e.Skipped) > 0 {
+		msg += fmt.Sprintf(", %d were skipped: %s", len(e.Skipped), e.Err)
+	}
+
+	for _, blobID := range e.FailedBlobIDs() {
+		msg += fmt.Sprintf("\n  '%s': %s", blobID, e.Failed[blobID])
+	}
+
+	return msg
+}
+
+func (e DeleteManyError) Unwrap() error {
+	return e.Err
+}
+
+// FailedBlobIDs returns the IDs of the blobs that failed in sorted order
+func (e DeleteManyError) FailedBlobIDs() []string {
+	blobIDs := make([]string, 0, len(e.Failed))
+	for blobID := range e.Failed {
+		blobIDs = append(blobIDs, blobID)
+	}
+	sort.Strings(blobIDs)
+	return blobIDs
+}
+
+// DeleteMany deletes blobs with at most concurrency deletes at a time,
+// starting no more than rateLimit deletes per second when rateLimit is
+// greater than 0. Blobs that are already gone count as deleted. Failed
+// deletes do not stop the others; they are reported in a DeleteManyError
+// together with the blobs skipped once ctx was canceled.
+func DeleteMany(ctx context.Context, deleter BlobDeleter, blobIDs []string, concurrency int, rateLimit float64) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var interval time.Duration
+	if rateLimit > 0 {
+		interval = time.Duration(float64(time.Second) / rateLimit)
+	}
+
+	result := DeleteManyError{Total: len(blobIDs), Failed: map[string]error{}}
+	var resultLock sync.Mutex
+
+	queue := make(chan string)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for blobID := range queue {
+				err := deleter.Delete(blobID)
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					resultLock.Lock()
+					result.Failed[blobID] = err
+					resultLock.Unlock()
+				}
+			}
+		}()
+	}
+
+	var nextStart time.Time
+
+feed:
+	for i, blobID := range blobIDs {
+		if interval > 0 {
+			if wait := time.Until(nextStart); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+				}
+			}
+		}
+
+		if ctx.Err() != nil {
+			result.Skipped = blobIDs[i:]
+			break
+		}
+
+		select {
+		case queue <- blobID:
+			nextStart = time.Now().Add(interval)
+		case <-ctx.Done():
+			result.Skipped = blobIDs[i:]
+			break feed
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	if len(result.Skipped) > 0 {
+		result.Err = ctx.Err()
+	}
+
+	if len(result.Failed) == 0 && len(result.Skipped) == 0 {
+		return nil
+	}
+
+	return result
+}
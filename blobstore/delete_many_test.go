@@ -0,0 +1,131 @@
+package blobstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+)
+
+var _ = Describe("DeleteMany", func() {
+	var (
+		blobstore *fakeblob.FakeBlobstore
+		blobIDs   []string
+	)
+
+	BeforeEach(func() {
+		blobstore = &fakeblob.FakeBlobstore{}
+
+		blobIDs = nil
+		for i := 0; i < 20; i++ {
+			blobIDs = append(blobIDs, fmt.Sprintf("blob-%02d", i))
+		}
+	})
+
+	deletedBlobIDs := func() []string {
+		var deleted []string
+		for i := 0; i < blobstore.DeleteCallCount(); i++ {
+			deleted = append(deleted, blobstore.DeleteArgsForCall(i))
+		}
+		return deleted
+	}
+
+	It("deletes every blob", func() {
+		err := boshblob.DeleteMany(context.Background(), blobstore, blobIDs, 4, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(deletedBlobIDs()).To(ConsistOf(blobIDs))
+	})
+
+	It("deletes at most concurrency blobs at a time", func() {
+		var running, maxRunning int32
+
+		blobstore.DeleteStub = func(string) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}
+
+		err := boshblob.DeleteMany(context.Background(), blobstore, blobIDs, 3, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(atomic.LoadInt32(&maxRunning)).To(BeNumerically("<=", 3))
+		Expect(atomic.LoadInt32(&maxRunning)).To(BeNumerically(">", 1))
+	})
+
+	It("starts no more deletes per second than the rate limit", func() {
+		started := time.Now()
+
+		err := boshblob.DeleteMany(context.Background(), blobstore, blobIDs[:6], 6, 100)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(time.Since(started)).To(BeNumerically(">=", 50*time.Millisecond))
+		Expect(blobstore.DeleteCallCount()).To(Equal(6))
+	})
+
+	It("reports failed blobs and keeps deleting the others", func() {
+		blobstore.DeleteStub = func(blobID string) error {
+			switch blobID {
+			case "blob-03", "blob-07":
+				return errors.New("fake-delete-err")
+			case "blob-05":
+				return boshblob.NotFoundError{BlobID: blobID, Err: errors.New("fake-not-found")}
+			}
+			return nil
+		}
+
+		err := boshblob.DeleteMany(context.Background(), blobstore, blobIDs, 2, 0)
+		Expect(err).To(HaveOccurred())
+		Expect(blobstore.DeleteCallCount()).To(Equal(20))
+
+		var deleteErr boshblob.DeleteManyError
+		Expect(errors.As(err, &deleteErr)).To(BeTrue())
+		Expect(deleteErr.Total).To(Equal(20))
+		Expect(deleteErr.FailedBlobIDs()).To(Equal([]string{"blob-03", "blob-07"}))
+		Expect(deleteErr.Skipped).To(BeEmpty())
+		Expect(err.Error()).To(Equal("Deleting 2 of 20 blobs failed\n  'blob-03': fake-delete-err\n  'blob-07': fake-delete-err"))
+	})
+
+	It("skips the remaining blobs once the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var lock sync.Mutex
+		blobstore.DeleteStub = func(blobID string) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if blobID == "blob-04" {
+				cancel()
+			}
+			return nil
+		}
+
+		err := boshblob.DeleteMany(ctx, blobstore, blobIDs, 1, 0)
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+
+		var deleteErr boshblob.DeleteManyError
+		Expect(errors.As(err, &deleteErr)).To(BeTrue())
+		Expect(deleteErr.Failed).To(BeEmpty())
+		Expect(deleteErr.Skipped).ToNot(BeEmpty())
+		Expect(len(deleteErr.Skipped) + blobstore.DeleteCallCount()).To(Equal(20))
+	})
+})
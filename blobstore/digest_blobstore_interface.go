@@ -11,6 +11,9 @@ type DigestBlobstore interface {
 	// Cleanup call is needed to properly cleanup downloaded blob.
 	Get(blobID string, digest boshcrypto.Digest) (fileName string, err error)
 
+	// GetWithOpts is like Get, the digest is always checked against the blob as stored
+	GetWithOpts(blobID string, digest boshcrypto.Digest, opts GetOpts) (fileName string, err error)
+
 	CleanUp(fileName string) (err error)
 
 	Create(fileName string) (blobID string, digest boshcrypto.MultipleDigest, err error)
@@ -18,4 +21,8 @@ type DigestBlobstore interface {
 	Validate() (err error)
 
 	Delete(blobId string) (err error)
+
+	// Copy stores the contents of one blob under another blob ID, the
+	// digest of the copy is the same as the digest of the source blob
+	Copy(srcBlobID, dstBlobID string) (err error)
 }
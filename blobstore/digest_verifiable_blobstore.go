@@ -1,6 +1,7 @@
 package blobstore
 
 import (
+	"io"
 	"os"
 
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
@@ -11,6 +12,7 @@ import (
 type digestVerifiableBlobstore struct {
 	blobstore        Blobstore
 	fs               boshsys.FileSystem
+	runner           boshsys.CmdRunner
 	createAlgorithms []boshcrypto.Algorithm
 }
 
@@ -22,7 +24,41 @@ func NewDigestVerifiableBlobstore(blobstore Blobstore, fs boshsys.FileSystem, cr
 	}
 }
 
+// NewDigestVerifiableBlobstoreWithCmdRunner returns a blobstore that can also
+// decompress zstd blobs on GetWithOpts by running the zstd CLI
+func NewDigestVerifiableBlobstoreWithCmdRunner(blobstore Blobstore, fs boshsys.FileSystem, runner boshsys.CmdRunner, createAlgorithms []boshcrypto.Algorithm) DigestBlobstore {
+	return digestVerifiableBlobstore{
+		blobstore:        blobstore,
+		fs:               fs,
+		runner:           runner,
+		createAlgorithms: createAlgorithms,
+	}
+}
+
 func (b digestVerifiableBlobstore) Get(blobID string, digest boshcrypto.Digest) (string, error) {
+	return b.GetWithOpts(blobID, digest, GetOpts{})
+}
+
+func (b digestVerifiableBlobstore) GetWithOpts(blobID string, digest boshcrypto.Digest, opts GetOpts) (string, error) {
+	fileName, err := b.get(blobID, digest)
+	if err != nil || !opts.Decompress {
+		return fileName, err
+	}
+
+	decompressedFileName, err := blobDecompressor{fs: b.fs, runner: b.runner}.decompress(fileName, opts.Compression)
+	if err != nil {
+		b.blobstore.CleanUp(fileName)
+		return "", bosherr.WrapErrorf(err, "Decompressing blob '%s'", blobID)
+	}
+
+	if decompressedFileName != fileName {
+		b.blobstore.CleanUp(fileName)
+	}
+
+	return decompressedFileName, nil
+}
+
+func (b digestVerifiableBlobstore) get(blobID string, digest boshcrypto.Digest) (string, error) {
 	fileName, err := b.blobstore.Get(blobID)
 	if err != nil {
 		return "", bosherr.WrapError(err, "Getting blob from inner blobstore")
@@ -35,8 +71,13 @@ func (b digestVerifiableBlobstore) Get(blobID string, digest boshcrypto.Digest)
 
 	defer file.Close()
 
-	err = digest.Verify(file)
+	reader := &verifiedReader{reader: file}
+
+	err = digest.Verify(reader)
 	if err != nil {
+		if reader.complete {
+			err = ChecksumMismatchError{BlobID: blobID, Err: err}
+		}
 		return "", bosherr.WrapErrorf(err, "Checking downloaded blob '%s'", blobID)
 	}
 
@@ -47,6 +88,10 @@ func (b digestVerifiableBlobstore) Delete(blobId string) error {
 	return b.blobstore.Delete(blobId)
 }
 
+func (b digestVerifiableBlobstore) Copy(srcBlobID, dstBlobID string) error {
+	return b.blobstore.Copy(srcBlobID, dstBlobID)
+}
+
 func (b digestVerifiableBlobstore) CleanUp(fileName string) error {
 	return b.blobstore.CleanUp(fileName)
 }
@@ -87,3 +132,17 @@ func (b digestVerifiableBlobstore) computeDigest(algo boshcrypto.Algorithm, file
 
 	return algo.CreateDigest(file)
 }
+
+// verifiedReader tells digest mismatches apart from read errors
+type verifiedReader struct {
+	reader   io.Reader
+	complete bool
+}
+
+func (r *verifiedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.EOF {
+		r.complete = true
+	}
+	return n, err
+}
@@ -57,6 +57,7 @@ var _ = Describe("checksumVerifiableBlobstore", func() {
 			_, err := checksumVerifiableBlobstore.Get("fake-blob-id", incorrectDigest)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Checking downloaded blob 'fake-blob-id'"))
+			Expect(errors.Is(err, boshblob.ErrChecksumMismatch)).To(BeTrue())
 		})
 
 		It("returns error if inner blobstore getting fails", func() {
@@ -25,3 +25,7 @@ func (b dummyBlobstore) Validate() error {
 func (b dummyBlobstore) Delete(blobID string) error {
 	return nil
 }
+
+func (b dummyBlobstore) Copy(srcBlobID, dstBlobID string) error {
+	return nil
+}
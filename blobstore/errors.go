@@ -0,0 +1,72 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Blobstore errors can be told apart with errors.Is, e.g.
+// errors.Is(err, ErrNotFound), no matter how often they were wrapped
+var (
+	ErrNotFound         = errors.New("blob not found")
+	ErrUnauthorized     = errors.New("blobstore access denied")
+	ErrChecksumMismatch = errors.New("blob checksum mismatch")
+	ErrThrottled        = errors.New("blobstore request throttled")
+)
+
+type NotFoundError struct {
+	BlobID string
+	Err    error
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("Blob '%s' not found: %s", e.BlobID, e.Err)
+}
+
+func (e NotFoundError) Is(target error) bool { return target == ErrNotFound }
+func (e NotFoundError) Unwrap() error        { return e.Err }
+
+type UnauthorizedError struct {
+	BlobID string
+	Err    error
+}
+
+func (e UnauthorizedError) Error() string {
+	return fmt.Sprintf("Access to blob '%s' denied: %s", e.BlobID, e.Err)
+}
+
+func (e UnauthorizedError) Is(target error) bool { return target == ErrUnauthorized }
+func (e UnauthorizedError) Unwrap() error        { return e.Err }
+
+// ChecksumMismatchError is returned when a downloaded blob does not match
+// its expected digest, i.e. it is corrupted or was replaced
+type ChecksumMismatchError struct {
+	BlobID string
+	Err    error
+}
+
+func (e ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("Blob '%s' does not match its digest: %s", e.BlobID, e.Err)
+}
+
+func (e ChecksumMismatchError) Is(target error) bool { return target == ErrChecksumMismatch }
+func (e ChecksumMismatchError) Unwrap() error        { return e.Err }
+
+// ThrottledError asks to retry after RetryAfter, which is zero
+// when the blobstore did not say how long to wait
+type ThrottledError struct {
+	BlobID     string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e ThrottledError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("Request for blob '%s' throttled, retry after %s: %s", e.BlobID, e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("Request for blob '%s' throttled: %s", e.BlobID, e.Err)
+}
+
+func (e ThrottledError) Is(target error) bool { return target == ErrThrottled }
+func (e ThrottledError) Unwrap() error        { return e.Err }
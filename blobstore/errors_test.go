@@ -0,0 +1,42 @@
+package blobstore_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/blobstore"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var _ = Describe("blobstore errors", func() {
+	cause := errors.New("fake-cause")
+
+	It("can be told apart with errors.Is when wrapped", func() {
+		typed := map[error]error{
+			NotFoundError{BlobID: "fake-id", Err: cause}:         ErrNotFound,
+			UnauthorizedError{BlobID: "fake-id", Err: cause}:     ErrUnauthorized,
+			ChecksumMismatchError{BlobID: "fake-id", Err: cause}: ErrChecksumMismatch,
+			ThrottledError{BlobID: "fake-id", Err: cause}:        ErrThrottled,
+		}
+
+		for err, sentinel := range typed {
+			wrapped := bosherr.WrapError(bosherr.WrapError(err, "fake-outer"), "fake-outermost")
+
+			for _, other := range []error{ErrNotFound, ErrUnauthorized, ErrChecksumMismatch, ErrThrottled} {
+				Expect(errors.Is(wrapped, other)).To(Equal(other == sentinel), "%s is %s", err, other)
+			}
+			Expect(errors.Is(wrapped, cause)).To(BeTrue())
+		}
+	})
+
+	It("describes the blob", func() {
+		Expect(NotFoundError{BlobID: "fake-id", Err: cause}.Error()).To(Equal("Blob 'fake-id' not found: fake-cause"))
+		Expect(UnauthorizedError{BlobID: "fake-id", Err: cause}.Error()).To(Equal("Access to blob 'fake-id' denied: fake-cause"))
+		Expect(ChecksumMismatchError{BlobID: "fake-id", Err: cause}.Error()).To(Equal("Blob 'fake-id' does not match its digest: fake-cause"))
+		Expect(ThrottledError{BlobID: "fake-id", Err: cause}.Error()).To(Equal("Request for blob 'fake-id' throttled: fake-cause"))
+		Expect(ThrottledError{BlobID: "fake-id", RetryAfter: 2 * time.Second, Err: cause}.Error()).To(Equal("Request for blob 'fake-id' throttled, retry after 2s: fake-cause"))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"errors"
 
@@ -84,6 +85,59 @@ func (b externalBlobstore) Create(fileName string) (string, error) {
 	return blobID, nil
 }
 
+// Copy uses the copy command of providers that have one and otherwise
+// downloads the blob and uploads it again under the destination ID
+// Copy falls back to get and put only when the provider cli does not know
+// the copy command, other errors of copy are returned as is
+func (b externalBlobstore) Copy(srcBlobID, dstBlobID string) error {
+	stdout, stderr, _, err := b.runner.RunCommand(b.executable(), "-c", b.configFilePath, "copy", srcBlobID, dstBlobID)
+	if err == nil {
+		return nil
+	}
+
+	copyErr := bosherr.WrapErrorf(err, "Shelling out to %s cli", b.executable())
+
+	if !isUnsupportedCommand(stdout, stderr, err) {
+		return copyErr
+	}
+
+	err = b.copyWithGetAndPut(srcBlobID, dstBlobID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Falling back to get and put after copy failed: %s", copyErr)
+	}
+
+	return nil
+}
+
+func (b externalBlobstore) copyWithGetAndPut(srcBlobID, dstBlobID string) error {
+	fileName, err := b.Get(srcBlobID)
+	if err != nil {
+		return bosherr.WrapError(err, "Making get command")
+	}
+	defer b.CleanUp(fileName)
+
+	err = b.run("put", fileName, dstBlobID)
+	if err != nil {
+		return bosherr.WrapError(err, "Making put command")
+	}
+
+	return nil
+}
+
+// isUnsupportedCommand recognizes the errors provider clis
+// print for commands they do not implement
+func isUnsupportedCommand(stdout, stderr string, err error) bool {
+	output := strings.ToLower(stdout + "\n" + stderr + "\n" + err.Error())
+
+	for _, message := range []string{"unknown command", "unsupported command", "not supported", "not implemented"} {
+		if strings.Contains(output, message) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (b externalBlobstore) Validate() error {
 	if !b.runner.CommandExists(b.executable()) {
 		return bosherr.Errorf("executable %s not found in PATH", b.executable())
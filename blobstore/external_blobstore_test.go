@@ -10,6 +10,7 @@ import (
 
 	boshassert "github.com/cloudfoundry/bosh-utils/assert"
 	. "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
 )
@@ -148,4 +149,75 @@ var _ = Describe("externalBlobstore", func() {
 			}))
 		})
 	})
+
+	Describe("Copy", func() {
+		It("uses the copy command of the provider", func() {
+			err := blobstore.Copy("fake-src-id", "fake-dst-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(runner.RunCommands).To(Equal([][]string{{
+				"bosh-blobstore-fake-provider", "-c", configPath, "copy",
+				"fake-src-id", "fake-dst-id",
+			}}))
+		})
+
+		Context("when the provider does not support copy", func() {
+			var tempFile boshsys.File
+
+			BeforeEach(func() {
+				var err error
+				tempFile, err = fs.TempFile("bosh-blobstore-external-TestCopy")
+				Expect(err).ToNot(HaveOccurred())
+				fs.ReturnTempFile = tempFile
+
+				copyCmd := []string{"bosh-blobstore-fake-provider", "-c", configPath, "copy", "fake-src-id", "fake-dst-id"}
+				runner.AddCmdResult(strings.Join(copyCmd, " "), fakesys.FakeCmdResult{
+					Stderr:     "unknown command: 'copy'",
+					ExitStatus: 1,
+					Error:      errors.New("fake-copy-error"),
+				})
+			})
+
+			It("downloads the blob and uploads it under the destination id", func() {
+				err := blobstore.Copy("fake-src-id", "fake-dst-id")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(runner.RunCommands).To(Equal([][]string{
+					{"bosh-blobstore-fake-provider", "-c", configPath, "copy", "fake-src-id", "fake-dst-id"},
+					{"bosh-blobstore-fake-provider", "-c", configPath, "get", "fake-src-id", tempFile.Name()},
+					{"bosh-blobstore-fake-provider", "-c", configPath, "put", tempFile.Name(), "fake-dst-id"},
+				}))
+				Expect(fs.FileExists(tempFile.Name())).To(BeFalse())
+			})
+
+			It("errs when uploading the blob errs", func() {
+				putCmd := []string{"bosh-blobstore-fake-provider", "-c", configPath, "put", tempFile.Name(), "fake-dst-id"}
+				runner.AddCmdResult(strings.Join(putCmd, " "), fakesys.FakeCmdResult{Error: errors.New("fake-put-error")})
+
+				err := blobstore.Copy("fake-src-id", "fake-dst-id")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-put-error"))
+				Expect(err.Error()).To(ContainSubstring("fake-copy-error"))
+				Expect(fs.FileExists(tempFile.Name())).To(BeFalse())
+			})
+		})
+
+		Context("when copying fails for other reasons", func() {
+			BeforeEach(func() {
+				copyCmd := []string{"bosh-blobstore-fake-provider", "-c", configPath, "copy", "fake-src-id", "fake-dst-id"}
+				runner.AddCmdResult(strings.Join(copyCmd, " "), fakesys.FakeCmdResult{
+					Stderr:     "access denied",
+					ExitStatus: 1,
+					Error:      errors.New("fake-copy-error"),
+				})
+			})
+
+			It("returns the error without falling back to get and put", func() {
+				err := blobstore.Copy("fake-src-id", "fake-dst-id")
+				Expect(err).To(MatchError(ContainSubstring("fake-copy-error")))
+
+				Expect(runner.RunCommands).To(HaveLen(1))
+			})
+		})
+	})
 })
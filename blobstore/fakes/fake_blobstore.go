@@ -48,6 +48,15 @@ type FakeBlobstore struct {
 	deleteReturns struct {
 		result1 error
 	}
+	CopyStub        func(srcBlobID, dstBlobID string) (err error)
+	copyMutex       sync.RWMutex
+	copyArgsForCall []struct {
+		srcBlobID string
+		dstBlobID string
+	}
+	copyReturns struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -206,6 +215,39 @@ func (fake *FakeBlobstore) DeleteReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeBlobstore) Copy(srcBlobID, dstBlobID string) (err error) {
+	fake.copyMutex.Lock()
+	fake.copyArgsForCall = append(fake.copyArgsForCall, struct {
+		srcBlobID string
+		dstBlobID string
+	}{srcBlobID, dstBlobID})
+	fake.recordInvocation("Copy", []interface{}{srcBlobID, dstBlobID})
+	fake.copyMutex.Unlock()
+	if fake.CopyStub != nil {
+		return fake.CopyStub(srcBlobID, dstBlobID)
+	}
+	return fake.copyReturns.result1
+}
+
+func (fake *FakeBlobstore) CopyCallCount() int {
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
+	return len(fake.copyArgsForCall)
+}
+
+func (fake *FakeBlobstore) CopyArgsForCall(i int) (string, string) {
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
+	return fake.copyArgsForCall[i].srcBlobID, fake.copyArgsForCall[i].dstBlobID
+}
+
+func (fake *FakeBlobstore) CopyReturns(result1 error) {
+	fake.CopyStub = nil
+	fake.copyReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeBlobstore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -219,6 +261,8 @@ func (fake *FakeBlobstore) Invocations() map[string][][]interface{} {
 	defer fake.validateMutex.RUnlock()
 	fake.deleteMutex.RLock()
 	defer fake.deleteMutex.RUnlock()
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
 	return fake.invocations
 }
 
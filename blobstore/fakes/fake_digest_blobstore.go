@@ -19,6 +19,17 @@ type FakeDigestBlobstore struct {
 		result1 string
 		result2 error
 	}
+	GetWithOptsStub        func(blobID string, digest boshcrypto.Digest, opts blobstore.GetOpts) (fileName string, err error)
+	getWithOptsMutex       sync.RWMutex
+	getWithOptsArgsForCall []struct {
+		blobID string
+		digest boshcrypto.Digest
+		opts   blobstore.GetOpts
+	}
+	getWithOptsReturns struct {
+		result1 string
+		result2 error
+	}
 	CleanUpStub        func(fileName string) (err error)
 	cleanUpMutex       sync.RWMutex
 	cleanUpArgsForCall []struct {
@@ -51,6 +62,15 @@ type FakeDigestBlobstore struct {
 	deleteReturns struct {
 		result1 error
 	}
+	CopyStub        func(srcBlobID, dstBlobID string) (err error)
+	copyMutex       sync.RWMutex
+	copyArgsForCall []struct {
+		srcBlobID string
+		dstBlobID string
+	}
+	copyReturns struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -89,6 +109,41 @@ func (fake *FakeDigestBlobstore) GetReturns(result1 string, result2 error) {
 	}{result1, result2}
 }
 
+func (fake *FakeDigestBlobstore) GetWithOpts(blobID string, digest boshcrypto.Digest, opts blobstore.GetOpts) (fileName string, err error) {
+	fake.getWithOptsMutex.Lock()
+	fake.getWithOptsArgsForCall = append(fake.getWithOptsArgsForCall, struct {
+		blobID string
+		digest boshcrypto.Digest
+		opts   blobstore.GetOpts
+	}{blobID, digest, opts})
+	fake.recordInvocation("GetWithOpts", []interface{}{blobID, digest, opts})
+	fake.getWithOptsMutex.Unlock()
+	if fake.GetWithOptsStub != nil {
+		return fake.GetWithOptsStub(blobID, digest, opts)
+	}
+	return fake.getWithOptsReturns.result1, fake.getWithOptsReturns.result2
+}
+
+func (fake *FakeDigestBlobstore) GetWithOptsCallCount() int {
+	fake.getWithOptsMutex.RLock()
+	defer fake.getWithOptsMutex.RUnlock()
+	return len(fake.getWithOptsArgsForCall)
+}
+
+func (fake *FakeDigestBlobstore) GetWithOptsArgsForCall(i int) (string, boshcrypto.Digest, blobstore.GetOpts) {
+	fake.getWithOptsMutex.RLock()
+	defer fake.getWithOptsMutex.RUnlock()
+	return fake.getWithOptsArgsForCall[i].blobID, fake.getWithOptsArgsForCall[i].digest, fake.getWithOptsArgsForCall[i].opts
+}
+
+func (fake *FakeDigestBlobstore) GetWithOptsReturns(result1 string, result2 error) {
+	fake.GetWithOptsStub = nil
+	fake.getWithOptsReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeDigestBlobstore) CleanUp(fileName string) (err error) {
 	fake.cleanUpMutex.Lock()
 	fake.cleanUpArgsForCall = append(fake.cleanUpArgsForCall, struct {
@@ -211,11 +266,46 @@ func (fake *FakeDigestBlobstore) DeleteReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeDigestBlobstore) Copy(srcBlobID, dstBlobID string) (err error) {
+	fake.copyMutex.Lock()
+	fake.copyArgsForCall = append(fake.copyArgsForCall, struct {
+		srcBlobID string
+		dstBlobID string
+	}{srcBlobID, dstBlobID})
+	fake.recordInvocation("Copy", []interface{}{srcBlobID, dstBlobID})
+	fake.copyMutex.Unlock()
+	if fake.CopyStub != nil {
+		return fake.CopyStub(srcBlobID, dstBlobID)
+	}
+	return fake.copyReturns.result1
+}
+
+func (fake *FakeDigestBlobstore) CopyCallCount() int {
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
+	return len(fake.copyArgsForCall)
+}
+
+func (fake *FakeDigestBlobstore) CopyArgsForCall(i int) (string, string) {
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
+	return fake.copyArgsForCall[i].srcBlobID, fake.copyArgsForCall[i].dstBlobID
+}
+
+func (fake *FakeDigestBlobstore) CopyReturns(result1 error) {
+	fake.CopyStub = nil
+	fake.copyReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeDigestBlobstore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.getMutex.RLock()
 	defer fake.getMutex.RUnlock()
+	fake.getWithOptsMutex.RLock()
+	defer fake.getWithOptsMutex.RUnlock()
 	fake.cleanUpMutex.RLock()
 	defer fake.cleanUpMutex.RUnlock()
 	fake.createMutex.RLock()
@@ -224,6 +314,8 @@ func (fake *FakeDigestBlobstore) Invocations() map[string][][]interface{} {
 	defer fake.validateMutex.RUnlock()
 	fake.deleteMutex.RLock()
 	defer fake.deleteMutex.RUnlock()
+	fake.copyMutex.RLock()
+	defer fake.copyMutex.RUnlock()
 	return fake.invocations
 }
 
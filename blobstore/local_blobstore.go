@@ -1,8 +1,10 @@
 package blobstore
 
 import (
+	"errors"
 	"os"
 	"path"
+	"runtime"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -11,6 +13,9 @@ import (
 
 const (
 	blobstorePathPermissions = os.FileMode(0770)
+
+	shardWidth    = 2
+	maxShardDepth = 4
 )
 
 type localBlobstore struct {
@@ -40,9 +45,14 @@ func (b localBlobstore) Get(blobID string) (fileName string, err error) {
 
 	fileName = file.Name()
 
-	err = b.fs.CopyFile(path.Join(b.path(), blobID), fileName)
+	blobPath := b.existingBlobPath(blobID)
+
+	err = b.fs.CopyFile(blobPath, fileName)
 	if err != nil {
 		b.fs.RemoveAll(fileName)
+		if !b.fs.FileExists(blobPath) {
+			err = NotFoundError{BlobID: blobID, Err: err}
+		}
 		return "", bosherr.WrapError(err, "Copying file")
 	}
 
@@ -55,8 +65,7 @@ func (b localBlobstore) CleanUp(fileName string) error {
 }
 
 func (b localBlobstore) Delete(blobID string) error {
-	blobPath := path.Join(b.path(), blobID)
-	return b.fs.RemoveAll(blobPath)
+	return b.fs.RemoveAll(b.existingBlobPath(blobID))
 }
 
 func (b localBlobstore) Create(fileName string) (blobID string, err error) {
@@ -66,20 +75,59 @@ func (b localBlobstore) Create(fileName string) (blobID string, err error) {
 		return
 	}
 
-	err = b.fs.MkdirAll(b.path(), blobstorePathPermissions)
+	err = b.write(fileName, b.blobPath(blobID))
 	if err != nil {
-		err = bosherr.WrapError(err, "Making blobstore path")
 		blobID = ""
 		return
 	}
 
-	err = b.fs.CopyFile(fileName, path.Join(b.path(), blobID))
+	return
+}
+
+// Copy copies the blob file without reading it into a temp file first
+func (b localBlobstore) Copy(srcBlobID, dstBlobID string) error {
+	srcPath := b.existingBlobPath(srcBlobID)
+	if !b.fs.FileExists(srcPath) {
+		return bosherr.WrapError(NotFoundError{BlobID: srcBlobID, Err: errors.New("no such blob file")}, "Copying blob")
+	}
+
+	return b.write(srcPath, b.blobPath(dstBlobID))
+}
+
+func (b localBlobstore) write(fileName, blobPath string) error {
+	var createdDirs []string
+	if b.fsync() {
+		createdDirs = b.missingDirs(path.Dir(blobPath))
+	}
+
+	err := b.fs.MkdirAll(path.Dir(blobPath), blobstorePathPermissions)
 	if err != nil {
-		err = bosherr.WrapError(err, "Copying file to blobstore path")
-		blobID = ""
-		return
+		return bosherr.WrapError(err, "Making blobstore path")
 	}
-	return
+
+	err = b.fs.CopyFile(fileName, blobPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Copying file to blobstore path")
+	}
+
+	if b.fsync() {
+		err = b.syncBlob(blobPath, createdDirs)
+		if err != nil {
+			b.fs.RemoveAll(blobPath)
+			return bosherr.WrapError(err, "Syncing blob to disk")
+		}
+	}
+
+	return nil
+}
+
+func (b localBlobstore) Stat(blobID string) (BlobInfo, error) {
+	info, err := b.fs.Stat(b.existingBlobPath(blobID))
+	if err != nil {
+		return BlobInfo{}, bosherr.WrapError(err, "Getting blob file info")
+	}
+
+	return BlobInfo{Size: info.Size()}, nil
 }
 
 func (b localBlobstore) Validate() error {
@@ -93,6 +141,19 @@ func (b localBlobstore) Validate() error {
 		return bosherr.Error("blobstore_path must be a string")
 	}
 
+	if shardDepth, found := b.options["shard_depth"]; found {
+		depth, ok := intOption(shardDepth)
+		if !ok || depth < 0 || depth > maxShardDepth {
+			return bosherr.Errorf("shard_depth must be an integer between 0 and %d", maxShardDepth)
+		}
+	}
+
+	if fsync, found := b.options["fsync"]; found {
+		if _, ok := fsync.(bool); !ok {
+			return bosherr.Error("fsync must be a boolean")
+		}
+	}
+
 	return nil
 }
 
@@ -100,3 +161,111 @@ func (b localBlobstore) path() string {
 	// Validate() makes sure that it's a string
 	return b.options["blobstore_path"].(string)
 }
+
+func (b localBlobstore) shardDepth() int {
+	depth, _ := intOption(b.options["shard_depth"])
+	return depth
+}
+
+func (b localBlobstore) fsync() bool {
+	fsync, _ := b.options["fsync"].(bool)
+	return fsync
+}
+
+// blobPath fans blobs out into nested directories named after two character
+// prefixes of the blob ID (e.g. ab/cd/abcdef... for shard_depth 2)
+func (b localBlobstore) blobPath(blobID string) string {
+	parts := []string{b.path()}
+
+	for i := 0; i < b.shardDepth() && len(blobID) >= (i+1)*shardWidth; i++ {
+		parts = append(parts, blobID[i*shardWidth:(i+1)*shardWidth])
+	}
+
+	return path.Join(append(parts, blobID)...)
+}
+
+// existingBlobPath falls back to the flat layout so that blobs created
+// before sharding was enabled can still be read and deleted
+func (b localBlobstore) existingBlobPath(blobID string) string {
+	blobPath := b.blobPath(blobID)
+	flatPath := path.Join(b.path(), blobID)
+
+	if blobPath != flatPath && !b.fs.FileExists(blobPath) && b.fs.FileExists(flatPath) {
+		return flatPath
+	}
+
+	return blobPath
+}
+
+// missingDirs returns dir and its parents that do not exist yet, starting
+// with dir
+func (b localBlobstore) missingDirs(dir string) []string {
+	var missing []string
+
+	for !b.fs.FileExists(dir) {
+		missing = append(missing, dir)
+
+		parent := path.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return missing
+}
+
+// syncBlob flushes the blob and the directory entries pointing to it and
+// to the shard directories in createdDirs so the blob survives a power
+// loss right after Create returns
+func (b localBlobstore) syncBlob(blobPath string, createdDirs []string) error {
+	err := b.syncPath(blobPath)
+	if err != nil {
+		return err
+	}
+
+	// directories cannot be flushed on Windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	err = b.syncPath(path.Dir(blobPath))
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range createdDirs {
+		err = b.syncPath(path.Dir(dir))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b localBlobstore) syncPath(syncPath string) error {
+	file, err := b.fs.OpenFile(syncPath, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if syncer, ok := file.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+
+	return nil
+}
+
+// intOption accepts ints as well as float64s produced by JSON decoding
+func intOption(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), v == float64(int(v))
+	default:
+		return 0, false
+	}
+}
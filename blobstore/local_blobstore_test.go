@@ -50,6 +50,147 @@ var _ = Describe("localBlobstore", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("blobstore_path must be a string"))
 		})
+
+		It("accepts shard_depth decoded from JSON", func() {
+			options := map[string]interface{}{"blobstore_path": fakeBlobstorePath, "shard_depth": float64(2)}
+			blobstore = NewLocalBlobstore(fs, uuidGen, options)
+
+			err := blobstore.Validate()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error when shard_depth is out of range", func() {
+			for _, depth := range []interface{}{-1, 5, 1.5, "2"} {
+				options := map[string]interface{}{"blobstore_path": fakeBlobstorePath, "shard_depth": depth}
+				blobstore = NewLocalBlobstore(fs, uuidGen, options)
+
+				err := blobstore.Validate()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("shard_depth must be an integer between 0 and 4"))
+			}
+		})
+
+		It("returns error when fsync is not a boolean", func() {
+			options := map[string]interface{}{"blobstore_path": fakeBlobstorePath, "fsync": "true"}
+			blobstore = NewLocalBlobstore(fs, uuidGen, options)
+
+			err := blobstore.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fsync must be a boolean"))
+		})
+	})
+
+	Context("with shard_depth", func() {
+		BeforeEach(func() {
+			options := map[string]interface{}{"blobstore_path": fakeBlobstorePath, "shard_depth": 2}
+			blobstore = NewLocalBlobstore(fs, uuidGen, options)
+		})
+
+		It("creates blobs in directories named after prefixes of the blob id", func() {
+			fs.WriteFileString("/fake-file.txt", "fake-file-contents")
+			uuidGen.GeneratedUUID = "abcdef-uuid"
+
+			blobID, err := blobstore.Create("/fake-file.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("abcdef-uuid"))
+
+			Expect(fs.ReadFileString(fakeBlobstorePath + "/ab/cd/abcdef-uuid")).To(Equal("fake-file-contents"))
+			Expect(fs.FileExists(fakeBlobstorePath + "/abcdef-uuid")).To(BeFalse())
+
+			fileName, err := blobstore.Get(blobID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.ReadFileString(fileName)).To(Equal("fake-file-contents"))
+
+			err = blobstore.Delete(blobID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists(fakeBlobstorePath + "/ab/cd/abcdef-uuid")).To(BeFalse())
+		})
+
+		It("gets and deletes blobs created before sharding was enabled", func() {
+			fs.WriteFileString(fakeBlobstorePath+"/abcdef-uuid", "flat contents")
+
+			fileName, err := blobstore.Get("abcdef-uuid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.ReadFileString(fileName)).To(Equal("flat contents"))
+
+			err = blobstore.Delete("abcdef-uuid")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists(fakeBlobstorePath + "/abcdef-uuid")).To(BeFalse())
+		})
+
+		It("copies blobs into the directory of the destination blob id", func() {
+			fs.WriteFileString(fakeBlobstorePath+"/abcdef-uuid", "flat contents")
+
+			err := blobstore.Copy("abcdef-uuid", "123456-uuid")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.ReadFileString(fakeBlobstorePath + "/12/34/123456-uuid")).To(Equal("flat contents"))
+		})
+	})
+
+	Context("with fsync", func() {
+		BeforeEach(func() {
+			options := map[string]interface{}{"blobstore_path": fakeBlobstorePath, "fsync": true}
+			blobstore = NewLocalBlobstore(fs, uuidGen, options)
+
+			fs.WriteFileString("/fake-file.txt", "fake-file-contents")
+			uuidGen.GeneratedUUID = "some-uuid"
+		})
+
+		It("syncs the created blob", func() {
+			blobFile := fakesys.NewFakeFile(fakeBlobstorePath+"/some-uuid", fs)
+			fs.RegisterOpenFile(fakeBlobstorePath+"/some-uuid", blobFile)
+
+			_, err := blobstore.Create("/fake-file.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobFile.SyncCallCount).To(Equal(1))
+		})
+
+		It("syncs the shard directories it created", func() {
+			options := map[string]interface{}{"blobstore_path": fakeBlobstorePath, "shard_depth": 2, "fsync": true}
+			blobstore = NewLocalBlobstore(fs, uuidGen, options)
+			fs.MkdirAll(fakeBlobstorePath, os.ModePerm)
+
+			// closing a fake file unregisters it
+			registerDirs := func() map[string]*fakesys.FakeFile {
+				dirs := map[string]*fakesys.FakeFile{}
+				for _, dir := range []string{"", "/so", "/so/me"} {
+					dirs[dir] = fakesys.NewFakeFile(fakeBlobstorePath+dir, fs)
+					fs.RegisterOpenFile(fakeBlobstorePath+dir, dirs[dir])
+				}
+				return dirs
+			}
+
+			dirs := registerDirs()
+			_, err := blobstore.Create("/fake-file.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists(fakeBlobstorePath + "/so/me/some-uuid")).To(BeTrue())
+
+			Expect(dirs["/so/me"].SyncCallCount).To(Equal(1))
+			Expect(dirs["/so"].SyncCallCount).To(Equal(1))
+			Expect(dirs[""].SyncCallCount).To(Equal(1))
+
+			uuidGen.GeneratedUUID = "some-other-uuid"
+			dirs = registerDirs()
+			_, err = blobstore.Create("/fake-file.txt")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dirs["/so/me"].SyncCallCount).To(Equal(1))
+			Expect(dirs["/so"].SyncCallCount).To(Equal(0))
+			Expect(dirs[""].SyncCallCount).To(Equal(0))
+		})
+
+		It("removes the blob and errs when syncing fails", func() {
+			blobFile := fakesys.NewFakeFile(fakeBlobstorePath+"/some-uuid", fs)
+			blobFile.SyncErr = errors.New("fake-sync-err")
+			fs.RegisterOpenFile(fakeBlobstorePath+"/some-uuid", blobFile)
+
+			blobID, err := blobstore.Create("/fake-file.txt")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Syncing blob to disk: fake-sync-err"))
+			Expect(blobID).To(BeEmpty())
+			Expect(fs.FileExists(fakeBlobstorePath + "/some-uuid")).To(BeFalse())
+		})
 	})
 
 	Describe("Get", func() {
@@ -96,6 +237,22 @@ var _ = Describe("localBlobstore", func() {
 			Expect(fileName).To(BeEmpty())
 			Expect(fs.FileExists(tempFile.Name())).To(BeFalse())
 		})
+
+		It("returns a NotFoundError when the blob does not exist", func() {
+			fs.CopyFileError = errors.New("fake-copy-file-error")
+
+			_, err := blobstore.Get("fake-missing-blob-id")
+			Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+		})
+
+		It("does not return a NotFoundError when copying an existing blob fails", func() {
+			fs.WriteFileString(fakeBlobstorePath+"/fake-blob-id", "fake contents")
+			fs.CopyFileError = errors.New("fake-copy-file-error")
+
+			_, err := blobstore.Get("fake-blob-id")
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, ErrNotFound)).To(BeFalse())
+		})
 	})
 
 	Describe("CleanUp", func() {
@@ -159,6 +316,50 @@ var _ = Describe("localBlobstore", func() {
 		})
 	})
 
+	Describe("Copy", func() {
+		It("stores the contents of the source blob under the destination blob id", func() {
+			fs.WriteFileString("/fake-file.txt", "fake-file-contents")
+			uuidGen.GeneratedUUID = "fake-src-uuid"
+			srcBlobID, err := blobstore.Create("/fake-file.txt")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = blobstore.Copy(srcBlobID, "fake-dst-uuid")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.ReadFileString(fakeBlobstorePath + "/fake-dst-uuid")).To(Equal("fake-file-contents"))
+			Expect(fs.ReadFileString(fakeBlobstorePath + "/fake-src-uuid")).To(Equal("fake-file-contents"))
+		})
+
+		It("returns a NotFoundError when the source blob does not exist", func() {
+			err := blobstore.Copy("missing-uuid", "fake-dst-uuid")
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+			Expect(fs.FileExists(fakeBlobstorePath + "/fake-dst-uuid")).To(BeFalse())
+		})
+
+		It("errs when copy file errs", func() {
+			fs.WriteFileString(fakeBlobstorePath+"/fake-src-uuid", "fake-file-contents")
+			fs.CopyFileError = errors.New("fake-copy-file-error")
+
+			err := blobstore.Copy("fake-src-uuid", "fake-dst-uuid")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-copy-file-error"))
+		})
+	})
+
+	Describe("Stat", func() {
+		It("returns the size of the stored blob", func() {
+			fs.WriteFileString("/fake-file.txt", "fake-file-contents")
+			blobID, err := blobstore.Create("/fake-file.txt")
+			Expect(err).ToNot(HaveOccurred())
+
+			info, err := blobstore.(BlobStater).Stat(blobID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Size).To(Equal(int64(len("fake-file-contents"))))
+			Expect(info.Digest).To(BeNil())
+		})
+	})
+
 	Describe("Delete", func() {
 		It("removes the blob from the blobstore", func() {
 			fs.WriteFileString("/fake-file.txt", "fake-file-contents")
@@ -14,6 +14,9 @@ import (
 const (
 	BlobstoreTypeDummy = "dummy"
 	BlobstoreTypeLocal = "local"
+
+	// verifyUploadsOption is handled by the provider and not passed to the blobstore
+	verifyUploadsOption = "verify_uploads"
 )
 
 type Provider struct {
@@ -42,6 +45,11 @@ func NewProvider(
 func (p Provider) Get(storeType string, options map[string]interface{}) (DigestBlobstore, error) {
 	var blobstore Blobstore
 
+	verifyUploads, options, err := p.extractVerifyUploads(options)
+	if err != nil {
+		return nil, err
+	}
+
 	switch storeType {
 	case BlobstoreTypeDummy:
 		blobstore = newDummyBlobstore()
@@ -64,14 +72,39 @@ func (p Provider) Get(storeType string, options map[string]interface{}) (DigestB
 		)
 	}
 
+	if verifyUploads {
+		blobstore = NewUploadVerifyingBlobstore(blobstore, p.fs, p.logger)
+	}
+
 	createAlgos := []boshcrypto.Algorithm{boshcrypto.DigestAlgorithmSHA1}
-	verifiableBlobstore := NewDigestVerifiableBlobstore(blobstore, p.fs, createAlgos)
+	verifiableBlobstore := NewDigestVerifiableBlobstoreWithCmdRunner(blobstore, p.fs, p.runner, createAlgos)
 	digestBlobstore := NewRetryableBlobstore(verifiableBlobstore, 3, p.logger)
 
-	err := blobstore.Validate()
+	err = blobstore.Validate()
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Validating blobstore")
 	}
 
 	return digestBlobstore, nil
 }
+
+func (p Provider) extractVerifyUploads(options map[string]interface{}) (bool, map[string]interface{}, error) {
+	value, found := options[verifyUploadsOption]
+	if !found {
+		return false, options, nil
+	}
+
+	verifyUploads, ok := value.(bool)
+	if !ok {
+		return false, nil, bosherr.Errorf("%s must be a boolean", verifyUploadsOption)
+	}
+
+	blobstoreOptions := make(map[string]interface{}, len(options)-1)
+	for key, value := range options {
+		if key != verifyUploadsOption {
+			blobstoreOptions[key] = value
+		}
+	}
+
+	return verifyUploads, blobstoreOptions, nil
+}
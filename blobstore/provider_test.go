@@ -50,7 +50,7 @@ var _ = Describe("Provider", func() {
 				boshcrypto.DigestAlgorithmSHA1,
 			}
 
-			expectedBlobstore := NewDigestVerifiableBlobstore(externalBlobstore, fs, expectedAlgos)
+			expectedBlobstore := NewDigestVerifiableBlobstoreWithCmdRunner(externalBlobstore, fs, runner, expectedAlgos)
 			expectedBlobstore = NewRetryableBlobstore(expectedBlobstore, 3, logger)
 
 			blobstore, err := provider.Get("fake-external-type", options)
@@ -61,6 +61,29 @@ var _ = Describe("Provider", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("wraps the blobstore to verify uploads when verify_uploads is set", func() {
+			options := map[string]interface{}{"blobstore_path": "/fake-path", "verify_uploads": true}
+
+			localBlobstore := NewLocalBlobstore(fs, boshuuid.NewGenerator(), map[string]interface{}{"blobstore_path": "/fake-path"})
+			expectedBlobstore := NewDigestVerifiableBlobstoreWithCmdRunner(
+				NewUploadVerifyingBlobstore(localBlobstore, fs, logger),
+				fs,
+				runner,
+				[]boshcrypto.Algorithm{boshcrypto.DigestAlgorithmSHA1},
+			)
+			expectedBlobstore = NewRetryableBlobstore(expectedBlobstore, 3, logger)
+
+			blobstore, err := provider.Get(BlobstoreTypeLocal, options)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobstore).To(Equal(expectedBlobstore))
+			Expect(options).To(HaveKey("verify_uploads"))
+		})
+
+		It("errs when verify_uploads is not a boolean", func() {
+			_, err := provider.Get(BlobstoreTypeDummy, map[string]interface{}{"verify_uploads": "yes"})
+			Expect(err).To(MatchError("verify_uploads must be a boolean"))
+		})
+
 		It("get external errs when external command not in path", func() {
 			options := map[string]interface{}{"key": "value"}
 			runner.CommandExistsValue = false
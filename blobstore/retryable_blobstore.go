@@ -1,6 +1,9 @@
 package blobstore
 
 import (
+	"errors"
+	"time"
+
 	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -24,17 +27,33 @@ func NewRetryableBlobstore(blobstore DigestBlobstore, maxTries int, logger boshl
 }
 
 func (b retryableBlobstore) Get(blobID string, fingerprint boshcrypto.Digest) (string, error) {
+	return b.retryGet(func() (string, error) {
+		return b.blobstore.Get(blobID, fingerprint)
+	})
+}
+
+func (b retryableBlobstore) GetWithOpts(blobID string, fingerprint boshcrypto.Digest, opts GetOpts) (string, error) {
+	return b.retryGet(func() (string, error) {
+		return b.blobstore.GetWithOpts(blobID, fingerprint, opts)
+	})
+}
+
+func (b retryableBlobstore) retryGet(get func() (string, error)) (string, error) {
 	var fileName string
 	var lastErr error
 
 	for i := 1; i <= b.maxTries; i++ {
-		fileName, lastErr = b.blobstore.Get(blobID, fingerprint)
+		fileName, lastErr = get()
 		if lastErr == nil {
 			return fileName, nil
 		}
 
 		b.logger.Info(b.logTag,
 			"Failed to get blob with error '%s', attempt %d out of %d", lastErr.Error(), i, b.maxTries)
+
+		if !b.retryAfter(lastErr, i) {
+			break
+		}
 	}
 
 	return "", bosherr.WrapError(lastErr, "Getting blob from inner blobstore")
@@ -60,11 +79,35 @@ func (b retryableBlobstore) Create(fileName string) (string, boshcrypto.Multiple
 		lastErr = thisErr
 		b.logger.Info(b.logTag,
 			"Failed to create blob with error %s, attempt %d out of %d", lastErr.Error(), i, b.maxTries)
+
+		if !b.retryAfter(lastErr, i) {
+			break
+		}
 	}
 
 	return "", boshcrypto.MultipleDigest{}, bosherr.WrapError(lastErr, "Creating blob in inner blobstore")
 }
 
+func (b retryableBlobstore) Copy(srcBlobID, dstBlobID string) error {
+	var lastErr error
+
+	for i := 1; i <= b.maxTries; i++ {
+		lastErr = b.blobstore.Copy(srcBlobID, dstBlobID)
+		if lastErr == nil {
+			return nil
+		}
+
+		b.logger.Info(b.logTag,
+			"Failed to copy blob with error %s, attempt %d out of %d", lastErr.Error(), i, b.maxTries)
+
+		if !b.retryAfter(lastErr, i) {
+			break
+		}
+	}
+
+	return bosherr.WrapError(lastErr, "Copying blob in inner blobstore")
+}
+
 func (b retryableBlobstore) Validate() error {
 	if b.maxTries < 1 {
 		return bosherr.Error("Max tries must be > 0")
@@ -72,3 +115,23 @@ func (b retryableBlobstore) Validate() error {
 
 	return b.blobstore.Validate()
 }
+
+// retryAfter reports whether err is worth another attempt and waits
+// as long as a throttling blobstore asked for
+func (b retryableBlobstore) retryAfter(err error, attempt int) bool {
+	if errors.Is(err, ErrNotFound) {
+		return false
+	}
+
+	if attempt == b.maxTries {
+		return true
+	}
+
+	var throttledErr ThrottledError
+	if errors.As(err, &throttledErr) && throttledErr.RetryAfter > 0 {
+		b.logger.Info(b.logTag, "Retrying after %s as requested by the blobstore", throttledErr.RetryAfter)
+		time.Sleep(throttledErr.RetryAfter)
+	}
+
+	return true
+}
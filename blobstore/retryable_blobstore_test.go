@@ -2,6 +2,7 @@ package blobstore_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -130,6 +131,84 @@ var _ = Describe("retryableBlobstore", func() {
 		})
 	})
 
+	Describe("Copy", func() {
+		It("delegates to inner blobstore", func() {
+			err := retryableBlobstore.Copy("fake-src-id", "fake-dst-id")
+			Expect(err).ToNot(HaveOccurred())
+
+			srcBlobID, dstBlobID := innerBlobstore.CopyArgsForCall(0)
+			Expect(srcBlobID).To(Equal("fake-src-id"))
+			Expect(dstBlobID).To(Equal("fake-dst-id"))
+		})
+
+		It("retries until the inner blobstore succeeds", func() {
+			calls := 0
+			innerBlobstore.CopyStub = func(_, _ string) error {
+				calls++
+				if calls < 3 {
+					return errors.New("fake-copy-error")
+				}
+				return nil
+			}
+
+			err := retryableBlobstore.Copy("fake-src-id", "fake-dst-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(innerBlobstore.CopyCallCount()).To(Equal(3))
+		})
+
+		It("returns last try error from inner blobstore", func() {
+			innerBlobstore.CopyReturns(errors.New("fake-copy-error"))
+
+			err := retryableBlobstore.Copy("fake-src-id", "fake-dst-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-copy-error"))
+			Expect(innerBlobstore.CopyCallCount()).To(Equal(3))
+		})
+	})
+
+	Describe("Get with typed errors", func() {
+		var digest boshcrypto.Digest
+
+		BeforeEach(func() {
+			digest = boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fingerprint")
+		})
+
+		It("does not retry blobs that are not found", func() {
+			innerBlobstore.GetReturns("", bosherr.WrapError(boshblob.NotFoundError{BlobID: "fake-blob-id", Err: errors.New("fake-404")}, "fake-wrap"))
+
+			_, err := retryableBlobstore.Get("fake-blob-id", digest)
+			Expect(errors.Is(err, boshblob.ErrNotFound)).To(BeTrue())
+			Expect(innerBlobstore.GetCallCount()).To(Equal(1))
+		})
+
+		It("retries throttled requests after the requested delay", func() {
+			throttledErr := boshblob.ThrottledError{BlobID: "fake-blob-id", RetryAfter: 50 * time.Millisecond, Err: errors.New("fake-503")}
+
+			var calls []time.Time
+			innerBlobstore.GetStub = func(string, boshcrypto.Digest) (string, error) {
+				calls = append(calls, time.Now())
+				if len(calls) < 2 {
+					return "", throttledErr
+				}
+				return "fake-path", nil
+			}
+
+			path, err := retryableBlobstore.Get("fake-blob-id", digest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal("fake-path"))
+			Expect(calls).To(HaveLen(2))
+			Expect(calls[1].Sub(calls[0])).To(BeNumerically(">=", 50*time.Millisecond))
+		})
+
+		It("does not wait after the last attempt", func() {
+			innerBlobstore.GetReturns("", boshblob.ThrottledError{BlobID: "fake-blob-id", RetryAfter: time.Hour, Err: errors.New("fake-503")})
+			retryableBlobstore = boshblob.NewRetryableBlobstore(innerBlobstore, 1, logger)
+
+			_, err := retryableBlobstore.Get("fake-blob-id", digest)
+			Expect(errors.Is(err, boshblob.ErrThrottled)).To(BeTrue())
+		})
+	})
+
 	Describe("Create", func() {
 		Context("when inner blobstore succeeds before maximum number of create tries (first time)", func() {
 			It("returns blobID and fingerprint without an error", func() {
@@ -0,0 +1,210 @@
+package blobstore
+
+import (
+	"fmt"
+	"os"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// BlobInfo describes a stored blob as reported by the blobstore
+type BlobInfo struct {
+	Size int64
+
+	// Digest is nil unless the provider reports one (e.g. an ETag/MD5)
+	Digest boshcrypto.Digest
+}
+
+// BlobStater is implemented by blobstores that can describe a stored
+// blob without downloading it, like a HEAD request
+type BlobStater interface {
+	Stat(blobID string) (BlobInfo, error)
+}
+
+// UploadVerificationError is returned by Create when the stored blob
+// does not match the uploaded file
+type UploadVerificationError struct {
+	BlobID   string
+	FileName string
+	Reason   string
+
+	ExpectedSize int64
+	ActualSize   int64
+
+	Remediation string
+	Err         error
+}
+
+func (e UploadVerificationError) Error() string {
+	msg := fmt.Sprintf("Verifying upload of '%s' as blob '%s': %s", e.FileName, e.BlobID, e.Reason)
+	if e.Remediation != "" {
+		msg += ". " + e.Remediation
+	}
+	return msg
+}
+
+func (e UploadVerificationError) Unwrap() error { return e.Err }
+
+type uploadVerifyingBlobstore struct {
+	blobstore Blobstore
+	fs        boshsys.FileSystem
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewUploadVerifyingBlobstore checks every created blob against the uploaded
+// file. Blobstores implementing BlobStater are asked for size and digest,
+// all others are verified by downloading the blob again.
+func NewUploadVerifyingBlobstore(blobstore Blobstore, fs boshsys.FileSystem, logger boshlog.Logger) Blobstore {
+	return uploadVerifyingBlobstore{
+		blobstore: blobstore,
+		fs:        fs,
+		logTag:    "uploadVerifyingBlobstore",
+		logger:    logger,
+	}
+}
+
+func (b uploadVerifyingBlobstore) Get(blobID string) (string, error) {
+	return b.blobstore.Get(blobID)
+}
+
+func (b uploadVerifyingBlobstore) CleanUp(fileName string) error {
+	return b.blobstore.CleanUp(fileName)
+}
+
+func (b uploadVerifyingBlobstore) Delete(blobID string) error {
+	return b.blobstore.Delete(blobID)
+}
+
+func (b uploadVerifyingBlobstore) Copy(srcBlobID, dstBlobID string) error {
+	return b.blobstore.Copy(srcBlobID, dstBlobID)
+}
+
+func (b uploadVerifyingBlobstore) Validate() error {
+	return b.blobstore.Validate()
+}
+
+func (b uploadVerifyingBlobstore) Create(fileName string) (string, error) {
+	blobID, err := b.blobstore.Create(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	verificationErr, err := b.verify(blobID, fileName)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Verifying upload of blob '%s'", blobID)
+	}
+
+	if verificationErr == nil {
+		return blobID, nil
+	}
+
+	// a retry creates a new blob, so the broken one is not needed anymore
+	err = b.blobstore.Delete(blobID)
+	if err != nil {
+		b.logger.Error(b.logTag, "Failed to delete blob '%s' after failed upload verification: %s", blobID, err)
+		verificationErr.Remediation = fmt.Sprintf("Deleting the broken blob failed (%s), remove it manually and retry the upload", err)
+	} else {
+		verificationErr.Remediation = "The broken blob was deleted; retry the upload and check for proxies, network interruptions or storage quotas truncating uploads"
+	}
+
+	return "", *verificationErr
+}
+
+func (b uploadVerifyingBlobstore) verify(blobID, fileName string) (*UploadVerificationError, error) {
+	fileInfo, err := b.fs.Stat(fileName)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Getting size of '%s'", fileName)
+	}
+
+	stater, ok := b.blobstore.(BlobStater)
+	if !ok {
+		return b.verifyByDownload(blobID, fileName, fileInfo.Size())
+	}
+
+	info, err := stater.Stat(blobID)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Getting blob info")
+	}
+
+	if info.Size != fileInfo.Size() {
+		return sizeMismatch(blobID, fileName, fileInfo.Size(), info.Size), nil
+	}
+
+	if info.Digest == nil {
+		return nil, nil
+	}
+
+	return b.verifyDigest(blobID, fileName, info.Digest)
+}
+
+func (b uploadVerifyingBlobstore) verifyByDownload(blobID, fileName string, expectedSize int64) (*UploadVerificationError, error) {
+	downloadedFileName, err := b.blobstore.Get(blobID)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Downloading blob")
+	}
+
+	defer b.blobstore.CleanUp(downloadedFileName)
+
+	downloadedInfo, err := b.fs.Stat(downloadedFileName)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Getting size of '%s'", downloadedFileName)
+	}
+
+	if downloadedInfo.Size() != expectedSize {
+		return sizeMismatch(blobID, fileName, expectedSize, downloadedInfo.Size()), nil
+	}
+
+	digest, err := b.fileDigest(downloadedFileName, boshcrypto.DigestAlgorithmSHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.verifyDigest(blobID, fileName, digest)
+}
+
+func (b uploadVerifyingBlobstore) verifyDigest(blobID, fileName string, digest boshcrypto.Digest) (*UploadVerificationError, error) {
+	file, err := b.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening '%s'", fileName)
+	}
+
+	defer file.Close()
+
+	err = digest.Verify(file)
+	if err != nil {
+		return &UploadVerificationError{
+			BlobID:   blobID,
+			FileName: fileName,
+			Reason:   "stored content does not match",
+			Err:      err,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func (b uploadVerifyingBlobstore) fileDigest(fileName string, algo boshcrypto.Algorithm) (boshcrypto.Digest, error) {
+	file, err := b.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening '%s'", fileName)
+	}
+
+	defer file.Close()
+
+	return algo.CreateDigest(file)
+}
+
+func sizeMismatch(blobID, fileName string, expectedSize, actualSize int64) *UploadVerificationError {
+	return &UploadVerificationError{
+		BlobID:       blobID,
+		FileName:     fileName,
+		Reason:       fmt.Sprintf("expected %d bytes but blobstore has %d bytes", expectedSize, actualSize),
+		ExpectedSize: expectedSize,
+		ActualSize:   actualSize,
+	}
+}
@@ -0,0 +1,123 @@
+package blobstore_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/blobstore"
+	fakeblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+type statingBlobstore struct {
+	*fakeblob.FakeBlobstore
+
+	info    BlobInfo
+	statErr error
+}
+
+func (b statingBlobstore) Stat(blobID string) (BlobInfo, error) {
+	return b.info, b.statErr
+}
+
+var _ = Describe("uploadVerifyingBlobstore", func() {
+	var (
+		fs             *fakesys.FakeFileSystem
+		innerBlobstore *fakeblob.FakeBlobstore
+		logger         boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		innerBlobstore = &fakeblob.FakeBlobstore{}
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+
+		fs.WriteFileString("/fake-file", "fake-contents")
+		innerBlobstore.CreateReturns("fake-blob-id", nil)
+	})
+
+	Context("when the blobstore reports blob info", func() {
+		create := func(info BlobInfo) (string, error) {
+			stating := statingBlobstore{FakeBlobstore: innerBlobstore, info: info}
+			return NewUploadVerifyingBlobstore(stating, fs, logger).Create("/fake-file")
+		}
+
+		It("returns the blob ID when size matches", func() {
+			blobID, err := create(BlobInfo{Size: int64(len("fake-contents"))})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(innerBlobstore.GetCallCount()).To(Equal(0))
+		})
+
+		It("returns an UploadVerificationError and deletes the blob when the size differs", func() {
+			_, err := create(BlobInfo{Size: 4})
+			Expect(err).To(HaveOccurred())
+
+			var verificationErr UploadVerificationError
+			Expect(errors.As(err, &verificationErr)).To(BeTrue())
+			Expect(verificationErr.BlobID).To(Equal("fake-blob-id"))
+			Expect(verificationErr.ExpectedSize).To(Equal(int64(13)))
+			Expect(verificationErr.ActualSize).To(Equal(int64(4)))
+			Expect(verificationErr.Remediation).To(ContainSubstring("The broken blob was deleted"))
+			Expect(err.Error()).To(ContainSubstring("expected 13 bytes but blobstore has 4 bytes"))
+
+			Expect(innerBlobstore.DeleteCallCount()).To(Equal(1))
+			Expect(innerBlobstore.DeleteArgsForCall(0)).To(Equal("fake-blob-id"))
+		})
+
+		It("compares reported digests", func() {
+			digest := boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA1, "fake-sha1")
+
+			_, err := create(BlobInfo{Size: int64(len("fake-contents")), Digest: digest})
+			Expect(err).To(BeAssignableToTypeOf(UploadVerificationError{}))
+			Expect(err.Error()).To(ContainSubstring("stored content does not match"))
+		})
+
+		It("includes manual remediation when deleting the blob fails", func() {
+			innerBlobstore.DeleteReturns(errors.New("fake-delete-err"))
+
+			_, err := create(BlobInfo{Size: 4})
+			Expect(err.(UploadVerificationError).Remediation).To(ContainSubstring("fake-delete-err"))
+		})
+
+		It("returns other errors when stating fails", func() {
+			stating := statingBlobstore{FakeBlobstore: innerBlobstore, statErr: errors.New("fake-stat-err")}
+
+			_, err := NewUploadVerifyingBlobstore(stating, fs, logger).Create("/fake-file")
+			Expect(err).To(MatchError(ContainSubstring("fake-stat-err")))
+			Expect(err).ToNot(BeAssignableToTypeOf(UploadVerificationError{}))
+		})
+	})
+
+	Context("when the blobstore cannot report blob info", func() {
+		It("verifies the blob by downloading it", func() {
+			fs.WriteFileString("/fake-downloaded", "fake-contents")
+			innerBlobstore.GetReturns("/fake-downloaded", nil)
+
+			blobID, err := NewUploadVerifyingBlobstore(innerBlobstore, fs, logger).Create("/fake-file")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blobID).To(Equal("fake-blob-id"))
+			Expect(innerBlobstore.GetArgsForCall(0)).To(Equal("fake-blob-id"))
+			Expect(innerBlobstore.CleanUpArgsForCall(0)).To(Equal("/fake-downloaded"))
+		})
+
+		It("detects modified content of the same size", func() {
+			fs.WriteFileString("/fake-downloaded", "fake-contentz")
+			innerBlobstore.GetReturns("/fake-downloaded", nil)
+
+			_, err := NewUploadVerifyingBlobstore(innerBlobstore, fs, logger).Create("/fake-file")
+			Expect(err).To(BeAssignableToTypeOf(UploadVerificationError{}))
+		})
+	})
+
+	It("returns create errors of the inner blobstore", func() {
+		innerBlobstore.CreateReturns("", errors.New("fake-create-err"))
+
+		_, err := NewUploadVerifyingBlobstore(innerBlobstore, fs, logger).Create("/fake-file")
+		Expect(err).To(MatchError("fake-create-err"))
+	})
+})
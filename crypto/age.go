@@ -0,0 +1,488 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// Files produced by AgeEncrypt follow the age v1 format
+// (https://age-encryption.org/v1) and can be decrypted with age and rage.
+// Only X25519 recipients are supported.
+
+const (
+	ageIntro          = "age-encryption.org/v1"
+	ageStanzaPrefix   = "->"
+	ageMACPrefix      = "---"
+	ageX25519Type     = "X25519"
+	ageX25519Label    = "age-encryption.org/v1/X25519"
+	ageRecipientHRP   = "age"
+	ageIdentityHRP    = "AGE-SECRET-KEY-"
+	ageFileKeySize    = 16
+	ageColumnsPerLine = 64
+)
+
+var ageBase64 = base64.RawStdEncoding.Strict()
+
+type X25519Recipient struct {
+	publicKey []byte
+}
+
+type X25519Identity struct {
+	secretKey []byte
+	publicKey []byte
+}
+
+func ParseX25519Recipient(recipient string) (*X25519Recipient, error) {
+	hrp, publicKey, err := bech32Decode(recipient)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing age recipient")
+	}
+
+	if hrp != ageRecipientHRP || len(publicKey) != curve25519.PointSize {
+		return nil, bosherr.Error("Parsing age recipient: not an X25519 recipient")
+	}
+
+	return &X25519Recipient{publicKey: publicKey}, nil
+}
+
+func (r *X25519Recipient) String() string {
+	encoded, _ := bech32Encode(ageRecipientHRP, r.publicKey)
+	return encoded
+}
+
+func GenerateX25519Identity() (*X25519Identity, error) {
+	secretKey, err := RandomBytes(curve25519.ScalarSize)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating age identity")
+	}
+
+	return newX25519Identity(secretKey)
+}
+
+func ParseX25519Identity(identity string) (*X25519Identity, error) {
+	hrp, secretKey, err := bech32Decode(identity)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing age identity")
+	}
+
+	if hrp != strings.ToLower(ageIdentityHRP) || len(secretKey) != curve25519.ScalarSize {
+		return nil, bosherr.Error("Parsing age identity: not an X25519 identity")
+	}
+
+	return newX25519Identity(secretKey)
+}
+
+func newX25519Identity(secretKey []byte) (*X25519Identity, error) {
+	publicKey, err := curve25519.X25519(secretKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Deriving age recipient")
+	}
+
+	return &X25519Identity{secretKey: secretKey, publicKey: publicKey}, nil
+}
+
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{publicKey: i.publicKey}
+}
+
+func (i *X25519Identity) String() string {
+	encoded, _ := bech32Encode(ageIdentityHRP, i.secretKey)
+	return encoded
+}
+
+type ageStanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+func (r *X25519Recipient) wrap(fileKey []byte) (ageStanza, error) {
+	ephemeralSecret, err := RandomBytes(curve25519.ScalarSize)
+	if err != nil {
+		return ageStanza{}, err
+	}
+
+	ephemeralShare, err := curve25519.X25519(ephemeralSecret, curve25519.Basepoint)
+	if err != nil {
+		return ageStanza{}, err
+	}
+
+	sharedSecret, err := curve25519.X25519(ephemeralSecret, r.publicKey)
+	if err != nil {
+		return ageStanza{}, err
+	}
+
+	salt := append(append([]byte{}, ephemeralShare...), r.publicKey...)
+
+	wrapKey, err := ageHKDF(sharedSecret, salt, ageX25519Label, chacha20poly1305.KeySize)
+	if err != nil {
+		return ageStanza{}, err
+	}
+
+	body, err := ageAEADSeal(wrapKey, fileKey)
+	if err != nil {
+		return ageStanza{}, err
+	}
+
+	return ageStanza{Type: ageX25519Type, Args: []string{ageBase64.EncodeToString(ephemeralShare)}, Body: body}, nil
+}
+
+// unwrap returns nil without error when the stanza is not meant for i
+func (i *X25519Identity) unwrap(stanza ageStanza) ([]byte, error) {
+	if stanza.Type != ageX25519Type {
+		return nil, nil
+	}
+
+	if len(stanza.Args) != 1 || len(stanza.Body) != ageFileKeySize+chacha20poly1305.Overhead {
+		return nil, bosherr.Error("Invalid X25519 recipient stanza")
+	}
+
+	ephemeralShare, err := ageBase64.DecodeString(stanza.Args[0])
+	if err != nil || len(ephemeralShare) != curve25519.PointSize {
+		return nil, bosherr.Error("Invalid X25519 recipient stanza")
+	}
+
+	sharedSecret, err := curve25519.X25519(i.secretKey, ephemeralShare)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Invalid X25519 recipient stanza")
+	}
+
+	salt := append(append([]byte{}, ephemeralShare...), i.publicKey...)
+
+	wrapKey, err := ageHKDF(sharedSecret, salt, ageX25519Label, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), stanza.Body, nil)
+	if err != nil {
+		return nil, nil
+	}
+
+	return fileKey, nil
+}
+
+// AgeEncrypt returns a writer encrypting everything written to it to dst.
+// Close must be called to write the final chunk; it does not close dst.
+func AgeEncrypt(dst io.Writer, recipients ...*X25519Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, bosherr.Error("Encrypting requires at least one recipient")
+	}
+
+	fileKey, err := RandomBytes(ageFileKeySize)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating file key")
+	}
+
+	var stanzas []ageStanza
+
+	for _, recipient := range recipients {
+		stanza, err := recipient.wrap(fileKey)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Wrapping file key for '%s'", recipient)
+		}
+		stanzas = append(stanzas, stanza)
+	}
+
+	header := marshalAgeHeaderWithoutMAC(stanzas)
+
+	mac, err := ageHeaderMAC(fileKey, header)
+	if err != nil {
+		return nil, err
+	}
+
+	header = append(header, ' ')
+	header = append(header, ageBase64.EncodeToString(mac)...)
+	header = append(header, '\n')
+
+	_, err = dst.Write(header)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Writing age header")
+	}
+
+	nonce, err := RandomBytes(ageStreamNonceSize)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating payload nonce")
+	}
+
+	_, err = dst.Write(nonce)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Writing payload nonce")
+	}
+
+	aead, err := newAgeStreamAEAD(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAgeStreamWriter(aead, dst), nil
+}
+
+// AgeDecrypt returns a reader of the plaintext of src. Every chunk is
+// authenticated before it is returned, so callers must discard everything
+// read so far when Read returns an error.
+func AgeDecrypt(src io.Reader, identities ...*X25519Identity) (io.Reader, error) {
+	if len(identities) == 0 {
+		return nil, bosherr.Error("Decrypting requires at least one identity")
+	}
+
+	reader := bufio.NewReader(src)
+
+	stanzas, header, mac, err := parseAgeHeader(reader)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing age header")
+	}
+
+	var fileKey []byte
+
+	for _, identity := range identities {
+		for _, stanza := range stanzas {
+			fileKey, err = identity.unwrap(stanza)
+			if err != nil {
+				return nil, err
+			}
+			if fileKey != nil {
+				break
+			}
+		}
+		if fileKey != nil {
+			break
+		}
+	}
+
+	if fileKey == nil {
+		return nil, bosherr.Error("No identity matched any of the recipients")
+	}
+
+	expectedMAC, err := ageHeaderMAC(fileKey, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(mac, expectedMAC) {
+		return nil, bosherr.Error("Header MAC verification failed")
+	}
+
+	nonce := make([]byte, ageStreamNonceSize)
+
+	_, err = io.ReadFull(reader, nonce)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading payload nonce")
+	}
+
+	aead, err := newAgeStreamAEAD(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAgeStreamReader(aead, reader), nil
+}
+
+// EncryptFile encrypts srcPath to dstPath with the age format
+func EncryptFile(fs boshsys.FileSystem, srcPath, dstPath string, recipients ...*X25519Recipient) error {
+	return transformFile(fs, srcPath, dstPath, func(src io.Reader, dst io.Writer) error {
+		writer, err := AgeEncrypt(dst, recipients...)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(writer, src)
+		if err != nil {
+			return bosherr.WrapError(err, "Encrypting")
+		}
+
+		return writer.Close()
+	})
+}
+
+// DecryptFile decrypts an age encrypted srcPath to dstPath. dstPath is
+// removed if decryption fails part way through.
+func DecryptFile(fs boshsys.FileSystem, srcPath, dstPath string, identities ...*X25519Identity) error {
+	return transformFile(fs, srcPath, dstPath, func(src io.Reader, dst io.Writer) error {
+		reader, err := AgeDecrypt(src, identities...)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(dst, reader)
+		if err != nil {
+			return bosherr.WrapError(err, "Decrypting")
+		}
+
+		return nil
+	})
+}
+
+func transformFile(fs boshsys.FileSystem, srcPath, dstPath string, transform func(io.Reader, io.Writer) error) error {
+	src, err := fs.OpenFile(srcPath, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening '%s'", srcPath)
+	}
+	defer src.Close()
+
+	dst, err := fs.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0600))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating '%s'", dstPath)
+	}
+
+	err = transform(src, dst)
+	if err == nil {
+		err = dst.Close()
+	} else {
+		dst.Close()
+	}
+
+	if err != nil {
+		fs.RemoveAll(dstPath)
+		return bosherr.WrapErrorf(err, "Writing '%s'", dstPath)
+	}
+
+	return nil
+}
+
+func ageAEADSeal(key, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), plaintext, nil), nil
+}
+
+// ageHKDF derives a key with HKDF-SHA-256 as used throughout the age format
+func ageHKDF(secret, salt []byte, label string, length int) ([]byte, error) {
+	key := make([]byte, length)
+
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(label)), key)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Deriving '%s' key", label)
+	}
+
+	return key, nil
+}
+
+func ageHeaderMAC(fileKey, header []byte) ([]byte, error) {
+	macKey, err := ageHKDF(fileKey, nil, "header", sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(header)
+
+	return mac.Sum(nil), nil
+}
+
+// marshalAgeHeaderWithoutMAC returns the header up to and including the
+// "---" MAC prefix which is the input of the header MAC
+func marshalAgeHeaderWithoutMAC(stanzas []ageStanza) []byte {
+	var header bytes.Buffer
+
+	header.WriteString(ageIntro + "\n")
+
+	for _, stanza := range stanzas {
+		header.WriteString(strings.Join(append([]string{ageStanzaPrefix, stanza.Type}, stanza.Args...), " ") + "\n")
+
+		body := ageBase64.EncodeToString(stanza.Body)
+		for len(body) >= ageColumnsPerLine {
+			header.WriteString(body[:ageColumnsPerLine] + "\n")
+			body = body[ageColumnsPerLine:]
+		}
+		header.WriteString(body + "\n")
+	}
+
+	header.WriteString(ageMACPrefix)
+
+	return header.Bytes()
+}
+
+func parseAgeHeader(reader *bufio.Reader) ([]ageStanza, []byte, []byte, error) {
+	var header bytes.Buffer
+
+	readLine := func() (string, error) {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", bosherr.WrapError(err, "Reading header line")
+		}
+		header.WriteString(line)
+		return strings.TrimSuffix(line, "\n"), nil
+	}
+
+	intro, err := readLine()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if intro != ageIntro {
+		return nil, nil, nil, bosherr.Errorf("Unsupported version line '%s'", intro)
+	}
+
+	var stanzas []ageStanza
+
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if strings.HasPrefix(line, ageMACPrefix+" ") {
+			mac, err := ageBase64.DecodeString(strings.TrimPrefix(line, ageMACPrefix+" "))
+			if err != nil || len(mac) != sha256.Size {
+				return nil, nil, nil, bosherr.Error("Invalid header MAC")
+			}
+
+			// the MAC covers the header up to and including "---"
+			withoutMAC := header.Bytes()[:header.Len()-len(line)-1+len(ageMACPrefix)]
+
+			return stanzas, withoutMAC, mac, nil
+		}
+
+		args := strings.Split(line, " ")
+		if len(args) < 2 || args[0] != ageStanzaPrefix {
+			return nil, nil, nil, bosherr.Errorf("Invalid stanza line '%s'", line)
+		}
+
+		stanza := ageStanza{Type: args[1], Args: args[2:]}
+
+		for {
+			bodyLine, err := readLine()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			if len(bodyLine) > ageColumnsPerLine {
+				return nil, nil, nil, bosherr.Error("Invalid stanza body line length")
+			}
+
+			decoded, err := ageBase64.DecodeString(bodyLine)
+			if err != nil {
+				return nil, nil, nil, bosherr.WrapError(err, "Decoding stanza body")
+			}
+			stanza.Body = append(stanza.Body, decoded...)
+
+			if len(bodyLine) < ageColumnsPerLine {
+				break
+			}
+		}
+
+		stanzas = append(stanzas, stanza)
+	}
+}
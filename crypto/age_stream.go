@@ -0,0 +1,208 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// age payloads use the STREAM construction: 64 KiB chunks sealed with
+// ChaCha20-Poly1305 using a big endian chunk counter as nonce whose last
+// byte marks the final chunk. EncryptStream uses it as well.
+
+const (
+	ageStreamNonceSize    = 16
+	ageStreamChunkSize    = 64 * 1024
+	ageStreamEncChunkSize = ageStreamChunkSize + chacha20poly1305.Overhead
+	ageStreamLastChunk    = 0x01
+)
+
+func newAgeStreamAEAD(fileKey, nonce []byte) (cipher.AEAD, error) {
+	payloadKey, err := ageHKDF(fileKey, nonce, "payload", chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return chacha20poly1305.New(payloadKey)
+}
+
+type ageStreamNonce [chacha20poly1305.NonceSize]byte
+
+func (n *ageStreamNonce) increment() {
+	for i := len(n) - 2; i >= 0; i-- {
+		n[i]++
+		if n[i] != 0 {
+			return
+		}
+	}
+	panic("age stream chunk counter overflowed")
+}
+
+type ageStreamWriter struct {
+	aead  cipher.AEAD
+	dst   io.Writer
+	nonce ageStreamNonce
+
+	plaintext  []byte
+	ciphertext []byte
+	err        error
+}
+
+func newAgeStreamWriter(aead cipher.AEAD, dst io.Writer) *ageStreamWriter {
+	return &ageStreamWriter{
+		aead:       aead,
+		dst:        dst,
+		plaintext:  make([]byte, 0, ageStreamChunkSize),
+		ciphertext: make([]byte, 0, ageStreamEncChunkSize),
+	}
+}
+
+func (w *ageStreamWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+
+	for len(p) > 0 {
+		// a full chunk is only flushed once more data follows
+		// since the last chunk has to be marked as such
+		if len(w.plaintext) == ageStreamChunkSize {
+			w.err = w.flush(false)
+			if w.err != nil {
+				return written, w.err
+			}
+		}
+
+		n := copy(w.plaintext[len(w.plaintext):ageStreamChunkSize], p)
+		w.plaintext = w.plaintext[:len(w.plaintext)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+func (w *ageStreamWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.err = w.flush(true)
+	if w.err != nil {
+		return w.err
+	}
+
+	w.err = bosherr.Error("Writing to closed encrypted stream")
+
+	return nil
+}
+
+func (w *ageStreamWriter) flush(last bool) error {
+	if last {
+		w.nonce[len(w.nonce)-1] = ageStreamLastChunk
+	}
+
+	w.ciphertext = w.aead.Seal(w.ciphertext[:0], w.nonce[:], w.plaintext, nil)
+
+	_, err := w.dst.Write(w.ciphertext)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing encrypted chunk")
+	}
+
+	w.nonce.increment()
+	w.plaintext = w.plaintext[:0]
+
+	return nil
+}
+
+type ageStreamReader struct {
+	aead  cipher.AEAD
+	src   io.Reader
+	nonce ageStreamNonce
+
+	// buf holds one encrypted chunk plus one byte to detect the last chunk
+	buf      []byte
+	buffered int
+
+	plainBuf  []byte
+	plaintext []byte
+	first     bool
+	err       error
+}
+
+func newAgeStreamReader(aead cipher.AEAD, src io.Reader) *ageStreamReader {
+	return &ageStreamReader{
+		aead:     aead,
+		src:      src,
+		buf:      make([]byte, ageStreamEncChunkSize+1),
+		plainBuf: make([]byte, 0, ageStreamChunkSize),
+		first:    true,
+	}
+}
+
+func (r *ageStreamReader) Read(p []byte) (int, error) {
+	for len(r.plaintext) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		r.err = r.readChunk()
+	}
+
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+
+	return n, nil
+}
+
+// readChunk sets io.EOF as error once the last chunk was decrypted
+func (r *ageStreamReader) readChunk() error {
+	n, err := io.ReadFull(r.src, r.buf[r.buffered:])
+	n += r.buffered
+
+	last := false
+
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return bosherr.WrapError(err, "Reading encrypted chunk")
+	}
+
+	chunkSize := ageStreamEncChunkSize
+	if last {
+		chunkSize = n
+		r.nonce[len(r.nonce)-1] = ageStreamLastChunk
+	}
+
+	if chunkSize < chacha20poly1305.Overhead {
+		return bosherr.Error("Truncated encrypted payload")
+	}
+
+	plaintext, err := r.aead.Open(r.plainBuf[:0], r.nonce[:], r.buf[:chunkSize], nil)
+	if err != nil {
+		return bosherr.WrapError(err, "Decrypting chunk")
+	}
+
+	if last && len(plaintext) == 0 && !r.first {
+		return bosherr.Error("Unexpected empty last chunk")
+	}
+
+	r.first = false
+	r.plaintext = plaintext
+
+	if last {
+		return io.EOF
+	}
+
+	// keep the extra byte read past the chunk for the next one
+	r.buf[0] = r.buf[ageStreamEncChunkSize]
+	r.buffered = 1
+	r.nonce.increment()
+
+	return nil
+}
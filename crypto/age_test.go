@@ -0,0 +1,249 @@
+package crypto_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	. "github.com/cloudfoundry/bosh-utils/crypto"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("age encryption", func() {
+	const (
+		// from the age test vectors
+		testIdentity  = "AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX"
+		testRecipient = "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"
+	)
+
+	var identity *X25519Identity
+
+	BeforeEach(func() {
+		var err error
+		identity, err = GenerateX25519Identity()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	encrypt := func(plaintext []byte, recipients ...*X25519Recipient) []byte {
+		var encrypted bytes.Buffer
+
+		writer, err := AgeEncrypt(&encrypted, recipients...)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = writer.Write(plaintext)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		return encrypted.Bytes()
+	}
+
+	decrypt := func(encrypted []byte, identities ...*X25519Identity) ([]byte, error) {
+		reader, err := AgeDecrypt(bytes.NewReader(encrypted), identities...)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	}
+
+	Describe("keys", func() {
+		It("parses and formats identities and recipients", func() {
+			parsed, err := ParseX25519Identity(testIdentity)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed.String()).To(Equal(testIdentity))
+			Expect(parsed.Recipient().String()).To(Equal(testRecipient))
+
+			recipient, err := ParseX25519Recipient(testRecipient)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recipient.String()).To(Equal(testRecipient))
+		})
+
+		It("generates identities", func() {
+			Expect(identity.String()).To(HavePrefix("AGE-SECRET-KEY-1"))
+			Expect(identity.Recipient().String()).To(HavePrefix("age1"))
+
+			parsed, err := ParseX25519Identity(identity.String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed.Recipient().String()).To(Equal(identity.Recipient().String()))
+		})
+
+		It("rejects invalid keys", func() {
+			_, err := ParseX25519Recipient(strings.Replace(testRecipient, "zvk", "zvq", 1))
+			Expect(err).To(MatchError(ContainSubstring("Invalid bech32 checksum")))
+
+			_, err = ParseX25519Recipient(testIdentity)
+			Expect(err).To(MatchError(ContainSubstring("not an X25519 recipient")))
+
+			_, err = ParseX25519Identity(testRecipient)
+			Expect(err).To(MatchError(ContainSubstring("not an X25519 identity")))
+		})
+	})
+
+	Describe("AgeEncrypt and AgeDecrypt", func() {
+		It("round trips payloads of various sizes", func() {
+			for _, size := range []int{0, 1, 64 * 1024, 64*1024 + 1, 3*64*1024 - 7} {
+				plaintext := bytes.Repeat([]byte("x"), size)
+
+				encrypted := encrypt(plaintext, identity.Recipient())
+				Expect(string(encrypted)).To(HavePrefix("age-encryption.org/v1\n-> X25519 "))
+
+				decrypted, err := decrypt(encrypted, identity)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(decrypted).To(Equal(plaintext))
+			}
+		})
+
+		It("encrypts to multiple recipients", func() {
+			other, err := GenerateX25519Identity()
+			Expect(err).ToNot(HaveOccurred())
+
+			encrypted := encrypt([]byte("fake-secret"), identity.Recipient(), other.Recipient())
+
+			decrypted, err := decrypt(encrypted, other)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(decrypted)).To(Equal("fake-secret"))
+		})
+
+		It("fails with identities that are not recipients", func() {
+			other, err := GenerateX25519Identity()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = decrypt(encrypt([]byte("fake-secret"), identity.Recipient()), other)
+			Expect(err).To(MatchError("No identity matched any of the recipients"))
+		})
+
+		It("detects modified headers", func() {
+			encrypted := encrypt([]byte("fake-secret"), identity.Recipient())
+			modified := bytes.Replace(encrypted, []byte("age-encryption.org/v1\n"), []byte("age-encryption.org/v1\n-> fake-type\n\n"), 1)
+
+			_, err := decrypt(modified, identity)
+			Expect(err).To(MatchError("Header MAC verification failed"))
+		})
+
+		It("detects modified payloads", func() {
+			encrypted := encrypt([]byte("fake-secret"), identity.Recipient())
+			encrypted[len(encrypted)-20] ^= 1
+
+			_, err := decrypt(encrypted, identity)
+			Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+		})
+
+		It("detects payloads truncated at a chunk boundary", func() {
+			encrypted := encrypt(bytes.Repeat([]byte("x"), 64*1024+1), identity.Recipient())
+
+			_, err := decrypt(encrypted[:len(encrypted)-17], identity)
+			Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+		})
+
+		It("requires recipients and identities", func() {
+			_, err := AgeEncrypt(&bytes.Buffer{})
+			Expect(err).To(HaveOccurred())
+
+			_, err = AgeDecrypt(&bytes.Buffer{})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("does not allow writes after Close", func() {
+			writer, err := AgeEncrypt(&bytes.Buffer{}, identity.Recipient())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+
+			_, err = writer.Write([]byte("late"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("compatibility", func() {
+		It("decrypts files encrypted by age", func() {
+			// produced by age v1.0.0 for testRecipient
+			vectors := map[string]string{
+				"age interop test vector\n": "YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBmMzYrb2pNNTgzU1VwUkpzbmFySTJTSFNrTDNqbnROTTI3ZUVXbWtSbWljCnFFWDgvcThOTTVnU3kvME5FUmhrbUZ5V0R6MzFCSHVrb3N5QlU1UGoraTQKLS0tIEUxazFhZU1xSXhJNG40SFd0b2t6VnBWSWF0ckhjdDl0WFRUTXBtMzdpbkkKn9znSqCNBMuE9sd9mAtFJqxQ2eJD9Ksfh2TwnEq+w74WVq2n8eflpOPDwiT1UyptK91L+78e2S4=",
+				"":                          "YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBGUjhGYjI4SGdjU2dsUkgzclhiK1ZGMnlTdzl5emtneUQ0Mm54Mk5VR2hNCnVoVFFuNWpuZUtBZWcxblNNSnpIUEIzeWdNamY2RGFEOG1aOWRyeXdrem8KLS0tIHlYSjI3bFRYNG9vMmVmVVAwTVlrNW81NkFNbEVmRkNVdW81RU8xT2t2UlkKV7Y23tCvnfTlkKme61kP6xefdaYPBRZHK2MqGSIN+2I=",
+			}
+
+			testID, err := ParseX25519Identity(testIdentity)
+			Expect(err).ToNot(HaveOccurred())
+
+			for plaintext, vector := range vectors {
+				encrypted, err := base64.StdEncoding.DecodeString(vector)
+				Expect(err).ToNot(HaveOccurred())
+
+				decrypted, err := decrypt(encrypted, testID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(decrypted)).To(Equal(plaintext))
+
+				encrypted[len(encrypted)-1] ^= 1
+				_, err = decrypt(encrypted, testID)
+				Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+			}
+		})
+
+		It("uses ChaCha20-Poly1305 as specified by RFC 8439", func() {
+			// RFC 8439, section 2.8.2
+			key, _ := hex.DecodeString("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+			nonce, _ := hex.DecodeString("070000004041424344454647")
+			aad, _ := hex.DecodeString("50515253c0c1c2c3c4c5c6c7")
+			plaintext := "Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it."
+			expected := "d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d6" +
+				"3dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b36" +
+				"92ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc" +
+				"3ff4def08e4b7a9de576d26586cec64b6116" +
+				"1ae10b594f09e26a7e902ecbd0600691"
+
+			aead, err := chacha20poly1305.New(key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hex.EncodeToString(aead.Seal(nil, nonce, []byte(plaintext), aad))).To(Equal(expected))
+		})
+	})
+
+	Describe("EncryptFile and DecryptFile", func() {
+		var (
+			fs  boshsys.FileSystem
+			dir string
+		)
+
+		BeforeEach(func() {
+			fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+			dir = GinkgoT().TempDir()
+		})
+
+		It("encrypts and decrypts files", func() {
+			plaintext := bytes.Repeat([]byte("fake-backup"), 20000)
+			Expect(os.WriteFile(filepath.Join(dir, "backup"), plaintext, 0600)).To(Succeed())
+
+			err := EncryptFile(fs, filepath.Join(dir, "backup"), filepath.Join(dir, "backup.age"), identity.Recipient())
+			Expect(err).ToNot(HaveOccurred())
+
+			err = DecryptFile(fs, filepath.Join(dir, "backup.age"), filepath.Join(dir, "restored"), identity)
+			Expect(err).ToNot(HaveOccurred())
+
+			restored, err := os.ReadFile(filepath.Join(dir, "restored"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(restored).To(Equal(plaintext))
+		})
+
+		It("removes the destination when decryption fails", func() {
+			encrypted := encrypt(bytes.Repeat([]byte("x"), 3*64*1024), identity.Recipient())
+			encrypted[len(encrypted)-1] ^= 1
+			Expect(os.WriteFile(filepath.Join(dir, "backup.age"), encrypted, 0600)).To(Succeed())
+
+			err := DecryptFile(fs, filepath.Join(dir, "backup.age"), filepath.Join(dir, "restored"), identity)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Writing '"))
+			Expect(filepath.Join(dir, "restored")).ToNot(BeAnExistingFile())
+		})
+
+		It("returns an error when the source cannot be opened", func() {
+			err := EncryptFile(fs, filepath.Join(dir, "missing"), filepath.Join(dir, "out"), identity.Recipient())
+			Expect(err).To(MatchError(ContainSubstring("Opening '")))
+		})
+	})
+})
@@ -9,6 +9,7 @@ import (
 	"io"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
 var (
@@ -26,7 +27,7 @@ func (a algorithmSHAImpl) Name() string { return a.name }
 func (a algorithmSHAImpl) CreateDigest(reader io.Reader) (Digest, error) {
 	hash := a.hashFunc()
 
-	_, err := io.Copy(hash, reader)
+	_, err := boshsys.CopyStream(hash, reader, boshsys.CopyOpts{})
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Copying file for digest calculation")
 	}
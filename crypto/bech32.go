@@ -0,0 +1,154 @@
+package crypto
+
+import (
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// bech32 encoding (BIP 173) without the 90 character limit, as used by age
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+
+	expanded = append(expanded, 0)
+
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+
+	return expanded
+}
+
+// bech32Encode returns an upper case string when hrp is upper case
+func bech32Encode(hrp string, data []byte) (string, error) {
+	lowerHRP := strings.ToLower(hrp)
+	if hrp != lowerHRP && hrp != strings.ToUpper(hrp) {
+		return "", bosherr.Errorf("Mixed case bech32 prefix '%s'", hrp)
+	}
+
+	values, err := bech32ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	checksumInput := append(bech32HRPExpand(lowerHRP), values...)
+	checksumInput = append(checksumInput, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(checksumInput) ^ 1
+
+	var encoded strings.Builder
+	encoded.WriteString(lowerHRP)
+	encoded.WriteByte('1')
+
+	for _, v := range values {
+		encoded.WriteByte(bech32Charset[v])
+	}
+
+	for i := 0; i < 6; i++ {
+		encoded.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+
+	if hrp != lowerHRP {
+		return strings.ToUpper(encoded.String()), nil
+	}
+
+	return encoded.String(), nil
+}
+
+func bech32Decode(encoded string) (string, []byte, error) {
+	lowerEncoded := strings.ToLower(encoded)
+	if encoded != lowerEncoded && encoded != strings.ToUpper(encoded) {
+		return "", nil, bosherr.Error("Mixed case bech32 string")
+	}
+
+	separator := strings.LastIndexByte(lowerEncoded, '1')
+	if separator < 1 || separator+7 > len(lowerEncoded) {
+		return "", nil, bosherr.Error("Invalid bech32 separator position")
+	}
+
+	hrp := lowerEncoded[:separator]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, bosherr.Error("Invalid bech32 prefix character")
+		}
+	}
+
+	var values []byte
+	for i := separator + 1; i < len(lowerEncoded); i++ {
+		v := strings.IndexByte(bech32Charset, lowerEncoded[i])
+		if v < 0 {
+			return "", nil, bosherr.Errorf("Invalid bech32 character '%c'", lowerEncoded[i])
+		}
+		values = append(values, byte(v))
+	}
+
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, bosherr.Error("Invalid bech32 checksum")
+	}
+
+	data, err := bech32ConvertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return hrp, data, nil
+}
+
+func bech32ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var (
+		acc    uint32
+		bits   uint
+		result []byte
+	)
+
+	maxValue := uint32(1)<<toBits - 1
+
+	for _, b := range data {
+		if uint32(b)>>fromBits != 0 {
+			return nil, bosherr.Errorf("Invalid bech32 data byte %d", b)
+		}
+
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, bosherr.Error("Invalid bech32 padding")
+	}
+
+	return result, nil
+}
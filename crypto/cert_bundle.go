@@ -0,0 +1,172 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"path/filepath"
+	"sort"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// CertBundle is a deduplicated set of certificates that is written
+// as PEM with leaf certificates first, then intermediates, then roots
+type CertBundle struct {
+	certs []*x509.Certificate
+}
+
+// CertBundleDiff lists certificates only found in one of two bundles
+type CertBundleDiff struct {
+	Added   []*x509.Certificate
+	Removed []*x509.Certificate
+}
+
+func (d CertBundleDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+func NewCertBundle(certs ...*x509.Certificate) *CertBundle {
+	bundle := &CertBundle{}
+	bundle.Add(certs...)
+	return bundle
+}
+
+// ParseCertBundle parses all certificates of a PEM file
+func ParseCertBundle(pemCerts []byte) (*CertBundle, error) {
+	certs, err := parseCertificatesPEM(pemCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCertBundle(certs...), nil
+}
+
+// ReadCertBundle returns an empty bundle when path does not exist
+func ReadCertBundle(fs boshsys.FileSystem, path string) (*CertBundle, error) {
+	if !fs.FileExists(path) {
+		return NewCertBundle(), nil
+	}
+
+	contents, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading cert bundle '%s'", path)
+	}
+
+	bundle, err := ParseCertBundle(contents)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing cert bundle '%s'", path)
+	}
+
+	return bundle, nil
+}
+
+// Add ignores certificates that are already part of the bundle
+func (b *CertBundle) Add(certs ...*x509.Certificate) {
+	for _, cert := range certs {
+		if !b.Contains(cert) {
+			b.certs = append(b.certs, cert)
+		}
+	}
+
+	// stable so that certificates of the same kind keep the order they were added in
+	sort.SliceStable(b.certs, func(i, j int) bool {
+		return certBundleRank(b.certs[i]) < certBundleRank(b.certs[j])
+	})
+}
+
+// Remove returns whether cert was part of the bundle
+func (b *CertBundle) Remove(cert *x509.Certificate) bool {
+	for i, existing := range b.certs {
+		if existing.Equal(cert) {
+			b.certs = append(b.certs[:i], b.certs[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *CertBundle) Contains(cert *x509.Certificate) bool {
+	for _, existing := range b.certs {
+		if existing.Equal(cert) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *CertBundle) Certificates() []*x509.Certificate {
+	return append([]*x509.Certificate(nil), b.certs...)
+}
+
+func (b *CertBundle) CertPool() *x509.CertPool {
+	certPool := x509.NewCertPool()
+	for _, cert := range b.certs {
+		certPool.AddCert(cert)
+	}
+
+	return certPool
+}
+
+func (b *CertBundle) PEM() []byte {
+	var buf bytes.Buffer
+	for _, cert := range b.certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+
+	return buf.Bytes()
+}
+
+// Diff returns the certificates that other adds to and removes from b
+func (b *CertBundle) Diff(other *CertBundle) CertBundleDiff {
+	var diff CertBundleDiff
+
+	for _, cert := range other.certs {
+		if !b.Contains(cert) {
+			diff.Added = append(diff.Added, cert)
+		}
+	}
+
+	for _, cert := range b.certs {
+		if !other.Contains(cert) {
+			diff.Removed = append(diff.Removed, cert)
+		}
+	}
+
+	return diff
+}
+
+// Write replaces path through a rename so that readers never see a
+// partially written bundle
+func (b *CertBundle) Write(fs boshsys.FileSystem, path string) error {
+	tmpPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+
+	err := fs.WriteFile(tmpPath, b.PEM())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing cert bundle '%s'", path)
+	}
+
+	err = fs.Rename(tmpPath, path)
+	if err != nil {
+		fs.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Writing cert bundle '%s'", path)
+	}
+
+	return nil
+}
+
+// certBundleRank orders leaf certificates before intermediates before roots
+func certBundleRank(cert *x509.Certificate) int {
+	if !cert.IsCA {
+		return 0
+	}
+
+	if bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil {
+		return 2
+	}
+
+	return 1
+}
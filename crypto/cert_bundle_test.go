@@ -0,0 +1,146 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/crypto"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CertBundle", func() {
+	var (
+		root, intermediate, leaf, otherRoot *x509.Certificate
+	)
+
+	newCert := func(name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  isCA,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		}
+
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		Expect(err).ToNot(HaveOccurred())
+
+		cert, err := x509.ParseCertificate(der)
+		Expect(err).ToNot(HaveOccurred())
+
+		return cert, key
+	}
+
+	BeforeEach(func() {
+		var rootKey, intermediateKey *ecdsa.PrivateKey
+
+		root, rootKey = newCert("root", true, nil, nil)
+		intermediate, intermediateKey = newCert("intermediate", true, root, rootKey)
+		leaf, _ = newCert("leaf", false, intermediate, intermediateKey)
+		otherRoot, _ = newCert("other-root", true, nil, nil)
+	})
+
+	It("sorts leaf certificates before intermediates before roots and drops duplicates", func() {
+		bundle := NewCertBundle(root, intermediate, root, leaf, otherRoot, leaf)
+
+		Expect(bundle.Certificates()).To(Equal([]*x509.Certificate{leaf, intermediate, root, otherRoot}))
+	})
+
+	It("round trips through PEM", func() {
+		bundle := NewCertBundle(root, intermediate, leaf)
+
+		parsed, err := ParseCertBundle(append(bundle.PEM(), bundle.PEM()...))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed.Certificates()).To(HaveLen(3))
+		Expect(parsed.PEM()).To(Equal(bundle.PEM()))
+	})
+
+	It("returns errors for invalid PEM", func() {
+		_, err := ParseCertBundle([]byte("not-pem"))
+		Expect(err).To(MatchError("Parsing certificate 1: Missing PEM block"))
+	})
+
+	It("diffs bundles", func() {
+		oldBundle := NewCertBundle(root, intermediate)
+		newBundle := NewCertBundle(root, otherRoot)
+
+		diff := oldBundle.Diff(newBundle)
+		Expect(diff.Added).To(Equal([]*x509.Certificate{otherRoot}))
+		Expect(diff.Removed).To(Equal([]*x509.Certificate{intermediate}))
+		Expect(diff.Empty()).To(BeFalse())
+
+		Expect(newBundle.Diff(NewCertBundle(otherRoot, root)).Empty()).To(BeTrue())
+	})
+
+	It("removes certificates", func() {
+		bundle := NewCertBundle(root, otherRoot)
+
+		Expect(bundle.Remove(root)).To(BeTrue())
+		Expect(bundle.Remove(root)).To(BeFalse())
+		Expect(bundle.Certificates()).To(Equal([]*x509.Certificate{otherRoot}))
+	})
+
+	It("builds a cert pool that verifies the chain", func() {
+		bundle := NewCertBundle(root, intermediate)
+
+		_, err := leaf.Verify(x509.VerifyOptions{Roots: NewCertBundle(root).CertPool(), Intermediates: bundle.CertPool()})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Describe("Write and ReadCertBundle", func() {
+		var fs *fakesys.FakeFileSystem
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+		})
+
+		It("writes bundles through a rename", func() {
+			bundle := NewCertBundle(root, otherRoot)
+			Expect(bundle.Write(fs, "/etc/ssl/bundle.pem")).To(Succeed())
+
+			Expect(fs.RenameOldPaths).To(Equal([]string{"/etc/ssl/.bundle.pem.tmp"}))
+			Expect(fs.FileExists("/etc/ssl/.bundle.pem.tmp")).To(BeFalse())
+
+			read, err := ReadCertBundle(fs, "/etc/ssl/bundle.pem")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(read.Diff(bundle).Empty()).To(BeTrue())
+		})
+
+		It("keeps the old bundle when renaming fails", func() {
+			Expect(NewCertBundle(root).Write(fs, "/etc/ssl/bundle.pem")).To(Succeed())
+			fs.RenameError = errors.New("fake-rename-err")
+
+			err := NewCertBundle(otherRoot).Write(fs, "/etc/ssl/bundle.pem")
+			Expect(err).To(MatchError(ContainSubstring("fake-rename-err")))
+			Expect(fs.FileExists("/etc/ssl/.bundle.pem.tmp")).To(BeFalse())
+
+			read, err := ReadCertBundle(fs, "/etc/ssl/bundle.pem")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(read.Certificates()).To(Equal([]*x509.Certificate{root}))
+		})
+
+		It("reads missing bundles as empty", func() {
+			bundle, err := ReadCertBundle(fs, "/etc/ssl/missing.pem")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bundle.Certificates()).To(BeEmpty())
+		})
+	})
+})
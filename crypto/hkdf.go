@@ -0,0 +1,39 @@
+package crypto
+
+import (
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// HKDFExtract implements the extract step of RFC 5869.
+// A nil salt is treated as a string of hash length zeros.
+func HKDFExtract(hashFunc func() hash.Hash, secret, salt []byte) []byte {
+	return hkdf.Extract(hashFunc, secret, salt)
+}
+
+// HKDFExpand implements the expand step of RFC 5869.
+func HKDFExpand(hashFunc func() hash.Hash, pseudoRandomKey, info []byte, length int) ([]byte, error) {
+	maxLength := 255 * hashFunc().Size()
+	if length < 0 || length > maxLength {
+		return nil, bosherr.Errorf("HKDF cannot derive %d bytes; maximum is %d", length, maxLength)
+	}
+
+	key := make([]byte, length)
+
+	_, err := io.ReadFull(hkdf.Expand(hashFunc, pseudoRandomKey, info), key)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Expanding HKDF key")
+	}
+
+	return key, nil
+}
+
+// HKDF derives a key of the given length from secret
+// using both the extract and expand steps of RFC 5869.
+func HKDF(hashFunc func() hash.Hash, secret, salt, info []byte, length int) ([]byte, error) {
+	return HKDFExpand(hashFunc, HKDFExtract(hashFunc, secret, salt), info, length)
+}
@@ -0,0 +1,45 @@
+package crypto_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+var _ = Describe("HKDF", func() {
+	mustDecode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		Expect(err).ToNot(HaveOccurred())
+		return b
+	}
+
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+
+	It("matches RFC 5869 test case 1", func() {
+		salt := mustDecode("000102030405060708090a0b0c")
+		info := mustDecode("f0f1f2f3f4f5f6f7f8f9")
+
+		prk := HKDFExtract(sha256.New, secret, salt)
+		Expect(hex.EncodeToString(prk)).To(Equal("077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5"))
+
+		key, err := HKDF(sha256.New, secret, salt, info, 42)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hex.EncodeToString(key)).To(Equal("3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"))
+	})
+
+	It("matches RFC 5869 test case 3 (no salt and info)", func() {
+		key, err := HKDF(sha256.New, secret, nil, nil, 42)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hex.EncodeToString(key)).To(Equal("8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8"))
+	})
+
+	It("errors when asked for more than 255 blocks", func() {
+		_, err := HKDFExpand(sha256.New, make([]byte, 32), nil, 255*32+1)
+		Expect(err).To(HaveOccurred())
+	})
+})
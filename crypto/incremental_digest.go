@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"hash"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const incrementalDigestStateMagic = "bosh-digest-state-v1\x00"
+
+// IncrementalDigest computes a digest of data written to it over time.
+// Its state can be saved with MarshalBinary and restored after a restart
+// with RestoreIncrementalDigest, so that only new data has to be hashed.
+type IncrementalDigest struct {
+	algorithm Algorithm
+	hash      hash.Hash
+	written   int64
+}
+
+func NewIncrementalDigest(algorithm Algorithm) (*IncrementalDigest, error) {
+	shaAlgorithm, ok := algorithm.(algorithmSHAImpl)
+	if !ok {
+		return nil, bosherr.Errorf("Unable to create incremental digest of unknown algorithm '%s'", algorithm.Name())
+	}
+
+	return &IncrementalDigest{algorithm: shaAlgorithm, hash: shaAlgorithm.hashFunc()}, nil
+}
+
+// RestoreIncrementalDigest continues a digest saved with MarshalBinary
+func RestoreIncrementalDigest(state []byte) (*IncrementalDigest, error) {
+	if !bytes.HasPrefix(state, []byte(incrementalDigestStateMagic)) {
+		return nil, bosherr.Error("Restoring incremental digest: unknown state format")
+	}
+
+	state = state[len(incrementalDigestStateMagic):]
+
+	name, rest, found := bytes.Cut(state, []byte{0})
+	if !found || len(rest) < 8 {
+		return nil, bosherr.Error("Restoring incremental digest: truncated state")
+	}
+
+	var algorithm Algorithm
+	switch string(name) {
+	case DigestAlgorithmSHA1.Name():
+		algorithm = DigestAlgorithmSHA1
+	case DigestAlgorithmSHA256.Name():
+		algorithm = DigestAlgorithmSHA256
+	case DigestAlgorithmSHA512.Name():
+		algorithm = DigestAlgorithmSHA512
+	default:
+		return nil, bosherr.Errorf("Restoring incremental digest: unknown algorithm '%s'", name)
+	}
+
+	digest, err := NewIncrementalDigest(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	digest.written = int64(binary.BigEndian.Uint64(rest[:8]))
+
+	err = digest.hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(rest[8:])
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Restoring incremental %s digest", name)
+	}
+
+	return digest, nil
+}
+
+func (d *IncrementalDigest) Write(p []byte) (int, error) {
+	n, err := d.hash.Write(p)
+	d.written += int64(n)
+	return n, err
+}
+
+func (d *IncrementalDigest) Algorithm() Algorithm { return d.algorithm }
+
+// Written returns the number of bytes hashed so far, which is the offset
+// to continue reading from after a restore
+func (d *IncrementalDigest) Written() int64 { return d.written }
+
+// Digest returns the digest of all data written so far, more data can be
+// written afterwards
+func (d *IncrementalDigest) Digest() Digest {
+	return NewDigest(d.algorithm, fmt.Sprintf("%x", d.hash.Sum(nil)))
+}
+
+func (d *IncrementalDigest) MarshalBinary() ([]byte, error) {
+	hashState, err := d.hash.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Saving incremental %s digest", d.algorithm.Name())
+	}
+
+	var state bytes.Buffer
+
+	state.WriteString(incrementalDigestStateMagic)
+	state.WriteString(d.algorithm.Name())
+	state.WriteByte(0)
+	binary.Write(&state, binary.BigEndian, uint64(d.written))
+	state.Write(hashState)
+
+	return state.Bytes(), nil
+}
@@ -0,0 +1,74 @@
+package crypto_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+var _ = Describe("IncrementalDigest", func() {
+	for _, algorithm := range []Algorithm{DigestAlgorithmSHA1, DigestAlgorithmSHA256, DigestAlgorithmSHA512} {
+		algorithm := algorithm
+
+		It("continues a saved "+algorithm.Name()+" digest", func() {
+			expected, err := algorithm.CreateDigest(strings.NewReader("something different"))
+			Expect(err).ToNot(HaveOccurred())
+
+			digest, err := NewIncrementalDigest(algorithm)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = digest.Write([]byte("something "))
+			Expect(err).ToNot(HaveOccurred())
+
+			state, err := digest.MarshalBinary()
+			Expect(err).ToNot(HaveOccurred())
+
+			restored, err := RestoreIncrementalDigest(state)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(restored.Algorithm()).To(Equal(algorithm))
+			Expect(restored.Written()).To(Equal(int64(10)))
+
+			_, err = restored.Write([]byte("different"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(restored.Written()).To(Equal(int64(19)))
+			Expect(restored.Digest().String()).To(Equal(expected.String()))
+		})
+	}
+
+	It("returns intermediate digests without finishing the computation", func() {
+		digest, err := NewIncrementalDigest(DigestAlgorithmSHA1)
+		Expect(err).ToNot(HaveOccurred())
+
+		digest.Write([]byte("something "))
+		Expect(digest.Digest().String()).ToNot(Equal("da7102c07515effc353226eac2be923c916c5c94"))
+
+		digest.Write([]byte("different"))
+		Expect(digest.Digest().String()).To(Equal("da7102c07515effc353226eac2be923c916c5c94"))
+	})
+
+	It("returns an error for unknown algorithms", func() {
+		_, err := NewIncrementalDigest(NewUnknownAlgorithm("md5"))
+		Expect(err).To(MatchError("Unable to create incremental digest of unknown algorithm 'md5'"))
+	})
+
+	It("returns an error for invalid states", func() {
+		_, err := RestoreIncrementalDigest([]byte("garbage"))
+		Expect(err).To(MatchError(ContainSubstring("unknown state format")))
+
+		digest, err := NewIncrementalDigest(DigestAlgorithmSHA256)
+		Expect(err).ToNot(HaveOccurred())
+
+		state, err := digest.MarshalBinary()
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = RestoreIncrementalDigest(state[:len(state)-4])
+		Expect(err).To(MatchError(ContainSubstring("Restoring incremental sha256 digest")))
+
+		_, err = RestoreIncrementalDigest([]byte(strings.Replace(string(state), "sha256", "sha999", 1)))
+		Expect(err).To(MatchError(ContainSubstring("unknown algorithm 'sha999'")))
+	})
+})
@@ -0,0 +1,247 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// DigestEncoding is the multibase prefix of a multihash digest string
+type DigestEncoding byte
+
+const (
+	DigestEncodingBase16    DigestEncoding = 'f'
+	DigestEncodingBase32    DigestEncoding = 'b'
+	DigestEncodingBase58    DigestEncoding = 'z'
+	DigestEncodingBase64    DigestEncoding = 'm'
+	DigestEncodingBase64URL DigestEncoding = 'u'
+)
+
+// multihash function codes, see https://github.com/multiformats/multicodec
+var multihashCodes = map[string]uint64{
+	"sha1":   0x11,
+	"sha256": 0x12,
+	"sha512": 0x13,
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// multihashDigest is a digest whose string encodes the algorithm, the
+// digest length and the encoding of the digest: a multibase prefix
+// followed by the encoded multihash <code varint><length varint><digest>.
+// The digest may be truncated to length bytes.
+type multihashDigest struct {
+	algorithm algorithmSHAImpl
+	sum       []byte
+	encoding  DigestEncoding
+}
+
+// NewMultihashDigest converts a digest of a known algorithm,
+// in either string format, to a multihash digest
+func NewMultihashDigest(digest Digest, encoding DigestEncoding) (Digest, error) {
+	if mh, ok := digest.(multihashDigest); ok {
+		mh.encoding = encoding
+		return mh, mh.validate()
+	}
+
+	algorithm, ok := digest.Algorithm().(algorithmSHAImpl)
+	if !ok {
+		return nil, bosherr.Errorf("Unable to create multihash digest of unknown algorithm '%s'", digest.Algorithm().Name())
+	}
+
+	sum, err := hex.DecodeString(strings.TrimPrefix(digest.String(), algorithm.Name()+":"))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Decoding digest '%s'", digest.String())
+	}
+
+	mh := multihashDigest{algorithm: algorithm, sum: sum, encoding: encoding}
+
+	return mh, mh.validate()
+}
+
+// ParseDigest parses a single digest either in the legacy 'algorithm:hex'
+// format, as bare sha1 hex, or as a multihash digest string
+func ParseDigest(digest string) (Digest, error) {
+	return MultipleDigest{}.parseDigestString(digest)
+}
+
+func (d multihashDigest) Algorithm() Algorithm { return d.algorithm }
+
+func (d multihashDigest) String() string {
+	var mh []byte
+	mh = binary.AppendUvarint(mh, multihashCodes[d.algorithm.Name()])
+	mh = binary.AppendUvarint(mh, uint64(len(d.sum)))
+	mh = append(mh, d.sum...)
+
+	return string(d.encoding) + encodeMultibase(d.encoding, mh)
+}
+
+func (d multihashDigest) Verify(reader io.Reader) error {
+	hash := d.algorithm.hashFunc()
+
+	_, err := io.Copy(hash, reader)
+	if err != nil {
+		return bosherr.WrapError(err, "Computing digest from stream")
+	}
+
+	computed := multihashDigest{
+		algorithm: d.algorithm,
+		sum:       hash.Sum(nil)[:len(d.sum)],
+		encoding:  d.encoding,
+	}
+
+	if !bytes.Equal(d.sum, computed.sum) {
+		return bosherr.Errorf("Expected stream to have digest '%s' but was '%s'", d.String(), computed.String())
+	}
+
+	return nil
+}
+
+func (d multihashDigest) VerifyFilePath(filePath string, fs boshsys.FileSystem) error {
+	file, err := fs.OpenFile(filePath, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Calculating digest of '%s'", filePath)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return d.Verify(file)
+}
+
+func (d multihashDigest) validate() error {
+	if _, found := multibaseEncodings[d.encoding]; !found {
+		return bosherr.Errorf("Unknown digest encoding '%c'", d.encoding)
+	}
+
+	size := d.algorithm.hashFunc().Size()
+	if len(d.sum) == 0 || len(d.sum) > size {
+		return bosherr.Errorf("Expected %s digest to have 1 to %d bytes but has %d", d.algorithm.Name(), size, len(d.sum))
+	}
+
+	return nil
+}
+
+// parseMultihashDigest returns false when digest is not a multihash
+// digest of a known algorithm
+func parseMultihashDigest(digest string) (multihashDigest, bool) {
+	if len(digest) < 2 {
+		return multihashDigest{}, false
+	}
+
+	encoding := DigestEncoding(digest[0])
+
+	mh, err := decodeMultibase(encoding, digest[1:])
+	if err != nil {
+		return multihashDigest{}, false
+	}
+
+	code, n := binary.Uvarint(mh)
+	if n <= 0 {
+		return multihashDigest{}, false
+	}
+	mh = mh[n:]
+
+	length, n := binary.Uvarint(mh)
+	if n <= 0 || uint64(len(mh)-n) != length {
+		return multihashDigest{}, false
+	}
+
+	for name, knownCode := range multihashCodes {
+		if code == knownCode {
+			algorithm := algorithmSHAImpl{name}
+			parsed := multihashDigest{algorithm: algorithm, sum: mh[n:], encoding: encoding}
+			return parsed, parsed.validate() == nil
+		}
+	}
+
+	return multihashDigest{}, false
+}
+
+var multibaseEncodings = map[DigestEncoding]struct {
+	encode func([]byte) string
+	decode func(string) ([]byte, error)
+}{
+	DigestEncodingBase16:    {hex.EncodeToString, decodeBase16Lower},
+	DigestEncodingBase32:    {base32Lower.EncodeToString, base32Lower.DecodeString},
+	DigestEncodingBase58:    {encodeBase58, decodeBase58},
+	DigestEncodingBase64:    {base64.RawStdEncoding.EncodeToString, base64.RawStdEncoding.DecodeString},
+	DigestEncodingBase64URL: {base64.RawURLEncoding.EncodeToString, base64.RawURLEncoding.DecodeString},
+}
+
+func encodeMultibase(encoding DigestEncoding, data []byte) string {
+	return multibaseEncodings[encoding].encode(data)
+}
+
+func decodeMultibase(encoding DigestEncoding, data string) ([]byte, error) {
+	codec, found := multibaseEncodings[encoding]
+	if !found {
+		return nil, bosherr.Errorf("Unknown digest encoding '%c'", encoding)
+	}
+	return codec.decode(data)
+}
+
+// decodeBase16Lower rejects upper case which multibase encodes as 'F'
+func decodeBase16Lower(data string) ([]byte, error) {
+	if strings.ToLower(data) != data {
+		return nil, bosherr.Error("Expected lower case base16")
+	}
+	return hex.DecodeString(data)
+}
+
+func encodeBase58(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+
+	// every leading zero byte is encoded as a leading '1'
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+func decodeBase58(data string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+
+	for _, r := range data {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, bosherr.Errorf("Invalid base58 character '%c'", r)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	var zeros int
+	for zeros < len(data) && data[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
@@ -0,0 +1,119 @@
+package crypto_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+var _ = Describe("multihashDigest", func() {
+	const (
+		sha256Hex       = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+		sha256Base16    = "f1220" + sha256Hex
+		sha256Base58    = "zQmatYkNGZnELf8cAGdyJpUca2PyY4szai3RHyyWofNY1pY"
+		sha256Base32    = "bciqlu6awx6hqdt7kifaubxs5vyrchmadmgrzmf32ts2bb73b6iablli"
+		sha256Base64    = "mEiC6eBa/jwHP6kFBQN5driIjsANho5YXepy0EP9h8gAVrQ"
+		sha1Base64URL   = "uERSpmT42RwaBaro-JXF4UMJsnNDYnQ"
+		sha1Hex         = "a9993e364706816aba3e25717850c26c9cd0d89d"
+		sha512Truncated = "f1308ddaf35a193617aba"
+	)
+
+	Describe("ParseDigest", func() {
+		DescribeTable("parses multihash digests in every encoding",
+			func(digestString string, algorithm Algorithm) {
+				digest, err := ParseDigest(digestString)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(digest.Algorithm()).To(Equal(algorithm))
+				Expect(digest.String()).To(Equal(digestString))
+				Expect(digest.Verify(strings.NewReader("abc"))).To(Succeed())
+			},
+			Entry("base16", sha256Base16, DigestAlgorithmSHA256),
+			Entry("base32", sha256Base32, DigestAlgorithmSHA256),
+			Entry("base58", sha256Base58, DigestAlgorithmSHA256),
+			Entry("base64", sha256Base64, DigestAlgorithmSHA256),
+			Entry("base64url", sha1Base64URL, DigestAlgorithmSHA1),
+			Entry("truncated", sha512Truncated, DigestAlgorithmSHA512),
+		)
+
+		It("still parses legacy digests", func() {
+			digest, err := ParseDigest("sha256:" + sha256Hex)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.String()).To(Equal("sha256:" + sha256Hex))
+
+			digest, err = ParseDigest(sha1Hex)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.Algorithm()).To(Equal(DigestAlgorithmSHA1))
+			Expect(digest.String()).To(Equal(sha1Hex))
+		})
+
+		It("does not mistake legacy sha1 digests starting with a multibase prefix for multihashes", func() {
+			for _, prefix := range []string{"f", "b"} {
+				legacy := prefix + sha1Hex[1:]
+
+				digest, err := ParseDigest(legacy)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(digest.Algorithm()).To(Equal(DigestAlgorithmSHA1))
+				Expect(digest.String()).To(Equal(legacy))
+			}
+		})
+
+		It("returns an error for unparseable digests", func() {
+			_, err := ParseDigest("sha256:!")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewMultihashDigest", func() {
+		It("converts legacy digests", func() {
+			digest, err := NewMultihashDigest(NewDigest(DigestAlgorithmSHA256, sha256Hex), DigestEncodingBase58)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.String()).To(Equal(sha256Base58))
+
+			digest, err = NewMultihashDigest(digest, DigestEncodingBase64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.String()).To(Equal(sha256Base64))
+		})
+
+		It("returns an error for unknown algorithms and encodings", func() {
+			_, err := NewMultihashDigest(NewDigest(NewUnknownAlgorithm("md5"), "abcd"), DigestEncodingBase16)
+			Expect(err).To(MatchError("Unable to create multihash digest of unknown algorithm 'md5'"))
+
+			_, err = NewMultihashDigest(NewDigest(DigestAlgorithmSHA256, sha256Hex), DigestEncoding('x'))
+			Expect(err).To(MatchError("Unknown digest encoding 'x'"))
+		})
+
+		It("returns an error for digests longer than the algorithm produces", func() {
+			_, err := NewMultihashDigest(NewDigest(DigestAlgorithmSHA1, sha256Hex), DigestEncodingBase16)
+			Expect(err).To(MatchError("Expected sha1 digest to have 1 to 20 bytes but has 32"))
+		})
+	})
+
+	Describe("Verify", func() {
+		It("returns an error when the digest does not match", func() {
+			digest, err := ParseDigest(sha256Base16)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = digest.Verify(strings.NewReader("abd"))
+			Expect(err).To(MatchError("Expected stream to have digest '" + sha256Base16 + "' but was 'f1220a52d159f262b2c6ddb724a61840befc36eb30c88877a4030b65cbe86298449c9'"))
+		})
+	})
+
+	Describe("in MultipleDigest", func() {
+		It("parses multihash and legacy digests side by side", func() {
+			digest, err := ParseMultipleDigest(sha1Hex + ";" + sha256Base64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.Algorithm()).To(Equal(DigestAlgorithmSHA256))
+			Expect(digest.String()).To(Equal(sha1Hex + ";" + sha256Base64))
+			Expect(digest.Verify(strings.NewReader("abc"))).To(Succeed())
+		})
+
+		It("rejects a legacy and a multihash digest of the same algorithm", func() {
+			_, err := ParseMultipleDigest("sha256:" + sha256Hex + ";" + sha256Base16)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Multiple digests of the same algorithm 'sha256'"))
+		})
+	})
+})
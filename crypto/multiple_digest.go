@@ -209,6 +209,12 @@ func (MultipleDigest) parseDigestString(digest string) (Digest, error) {
 		return nil, emptyDigestError{}
 	}
 
+	if !strings.Contains(digest, ":") {
+		if mh, ok := parseMultihashDigest(digest); ok {
+			return mh, nil
+		}
+	}
+
 	pieces := strings.SplitN(digest, ":", 2)
 
 	for _, piece := range pieces {
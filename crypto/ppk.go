@@ -0,0 +1,370 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	ppkHeaderPrefix = "PuTTY-User-Key-File-"
+	ppkMACKeyPrefix = "putty-private-key-file-mac-key"
+
+	// ppkMaxArgon2Memory in KiB keeps malicious files from exhausting
+	// memory, puttygen uses 8 MiB by default
+	ppkMaxArgon2Memory = 1024 * 1024
+)
+
+type ppkFile struct {
+	version    int
+	algorithm  string
+	encryption string
+	comment    string
+	public     []byte
+	private    []byte
+	mac        []byte
+
+	// only set for encrypted version 3 files
+	kdf               string
+	argon2Memory      uint32
+	argon2Passes      uint32
+	argon2Parallelism uint8
+	argon2Salt        []byte
+}
+
+// parsePPK supports unencrypted PPK files and encrypted files of
+// version 2 and 3 of the format. Version 3 keys derived with Argon2d are
+// not supported since golang.org/x/crypto/argon2 only has Argon2i and
+// Argon2id.
+func parsePPK(keyBytes, passphrase []byte) (crypto.Signer, error) {
+	file, err := readPPK(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var macKey []byte
+
+	switch {
+	case file.encryption == "none":
+		if file.version == 2 {
+			macKey = ppkV2MACKey(nil)
+		}
+
+	case file.encryption == "aes256-cbc" && file.version == 2:
+		if len(passphrase) == 0 {
+			return nil, PassphraseMissingError{}
+		}
+
+		if len(file.private)%aes.BlockSize != 0 {
+			return nil, bosherr.Error("Encrypted private key is not a multiple of the block size")
+		}
+
+		block, err := aes.NewCipher(ppkV2CipherKey(passphrase))
+		if err != nil {
+			return nil, err
+		}
+
+		cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(file.private, file.private)
+		macKey = ppkV2MACKey(passphrase)
+
+	case file.encryption == "aes256-cbc" && file.version == 3:
+		if len(passphrase) == 0 {
+			return nil, PassphraseMissingError{}
+		}
+
+		if len(file.private)%aes.BlockSize != 0 {
+			return nil, bosherr.Error("Encrypted private key is not a multiple of the block size")
+		}
+
+		keys, err := ppkV3Keys(file, passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := aes.NewCipher(keys[:32])
+		if err != nil {
+			return nil, err
+		}
+
+		cipher.NewCBCDecrypter(block, keys[32:48]).CryptBlocks(file.private, file.private)
+		macKey = keys[48:]
+
+	default:
+		return nil, bosherr.Errorf("Unsupported encryption '%s'", file.encryption)
+	}
+
+	if !hmac.Equal(file.mac, ppkMAC(file, macKey)) {
+		if file.encryption != "none" {
+			return nil, bosherr.Error("Incorrect passphrase")
+		}
+		return nil, bosherr.Error("MAC does not match the key")
+	}
+
+	return ppkSigner(file.algorithm, file.public, file.private)
+}
+
+func readPPK(keyBytes []byte) (ppkFile, error) {
+	var file ppkFile
+
+	scanner := bufio.NewScanner(bytes.NewReader(keyBytes))
+
+	readBlob := func(value string) ([]byte, error) {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing line count '%s'", value)
+		}
+
+		var encoded strings.Builder
+		for i := 0; i < count; i++ {
+			if !scanner.Scan() {
+				return nil, bosherr.Error("Unexpected end of file")
+			}
+			encoded.WriteString(strings.TrimSpace(scanner.Text()))
+		}
+
+		return base64.StdEncoding.DecodeString(encoded.String())
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ": ")
+		if !found {
+			return file, bosherr.Errorf("Unexpected line '%s'", line)
+		}
+
+		var err error
+
+		switch {
+		case strings.HasPrefix(name, ppkHeaderPrefix):
+			file.version, err = strconv.Atoi(strings.TrimPrefix(name, ppkHeaderPrefix))
+			if err == nil && file.version != 2 && file.version != 3 {
+				err = bosherr.Errorf("Unsupported version %d", file.version)
+			}
+			file.algorithm = value
+		case name == "Encryption":
+			file.encryption = value
+		case name == "Comment":
+			file.comment = value
+		case name == "Public-Lines":
+			file.public, err = readBlob(value)
+		case name == "Private-Lines":
+			file.private, err = readBlob(value)
+		case name == "Private-MAC":
+			file.mac, err = hex.DecodeString(value)
+		case name == "Key-Derivation":
+			file.kdf = value
+		case name == "Argon2-Memory":
+			file.argon2Memory, err = parsePPKUint(value, ppkMaxArgon2Memory)
+		case name == "Argon2-Passes":
+			file.argon2Passes, err = parsePPKUint(value, math.MaxUint32)
+		case name == "Argon2-Parallelism":
+			var parallelism uint32
+			parallelism, err = parsePPKUint(value, math.MaxUint8)
+			file.argon2Parallelism = uint8(parallelism)
+		case name == "Argon2-Salt":
+			file.argon2Salt, err = hex.DecodeString(value)
+		}
+
+		if err != nil {
+			return file, bosherr.WrapErrorf(err, "Reading '%s'", name)
+		}
+	}
+
+	if file.version == 0 || file.public == nil || file.private == nil || file.mac == nil {
+		return file, bosherr.Error("Missing header, public key, private key or MAC")
+	}
+
+	return file, nil
+}
+
+func parsePPKUint(value string, max uint64) (uint32, error) {
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	if n == 0 || n > max {
+		return 0, bosherr.Errorf("Expected a value between 1 and %d", max)
+	}
+
+	return uint32(n), nil
+}
+
+// ppkV3Keys derives the cipher key, IV and MAC key of version 3 files
+func ppkV3Keys(file ppkFile, passphrase []byte) ([]byte, error) {
+	if file.argon2Memory == 0 || file.argon2Passes == 0 || file.argon2Parallelism == 0 || file.argon2Salt == nil {
+		return nil, bosherr.Error("Missing Argon2 parameters")
+	}
+
+	const keysLen = 32 + aes.BlockSize + 32
+
+	switch file.kdf {
+	case "Argon2id":
+		return argon2.IDKey(passphrase, file.argon2Salt, file.argon2Passes, file.argon2Memory, file.argon2Parallelism, keysLen), nil
+	case "Argon2i":
+		return argon2.Key(passphrase, file.argon2Salt, file.argon2Passes, file.argon2Memory, file.argon2Parallelism, keysLen), nil
+	default:
+		return nil, bosherr.Errorf("Unsupported key derivation '%s', convert the key with 'puttygen --ppk-param kdf=argon2id'", file.kdf)
+	}
+}
+
+func ppkV2CipherKey(passphrase []byte) []byte {
+	var key []byte
+	for i := uint32(0); i < 2; i++ {
+		h := sha1.New()
+		h.Write(binary.BigEndian.AppendUint32(nil, i))
+		h.Write(passphrase)
+		key = h.Sum(key)
+	}
+	return key[:32]
+}
+
+func ppkV2MACKey(passphrase []byte) []byte {
+	h := sha1.New()
+	h.Write([]byte(ppkMACKeyPrefix))
+	h.Write(passphrase)
+	return h.Sum(nil)
+}
+
+func ppkMAC(file ppkFile, key []byte) []byte {
+	newHash := sha256.New
+	if file.version == 2 {
+		newHash = func() hash.Hash { return sha1.New() }
+	}
+
+	mac := hmac.New(newHash, key)
+	for _, field := range [][]byte{
+		[]byte(file.algorithm),
+		[]byte(file.encryption),
+		[]byte(file.comment),
+		file.public,
+		file.private,
+	} {
+		mac.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		mac.Write(field)
+	}
+
+	return mac.Sum(nil)
+}
+
+func ppkSigner(algorithm string, public, private []byte) (crypto.Signer, error) {
+	pub := &sshWireReader{buf: public}
+	priv := &sshWireReader{buf: private}
+
+	if keyType := string(pub.readString()); keyType != algorithm {
+		return nil, bosherr.Errorf("Public key type '%s' does not match '%s'", keyType, algorithm)
+	}
+
+	switch algorithm {
+	case "ssh-rsa":
+		e, n := pub.readMPInt(), pub.readMPInt()
+		d, p, q := priv.readMPInt(), priv.readMPInt(), priv.readMPInt()
+		if pub.err != nil || priv.err != nil || !e.IsInt64() {
+			return nil, bosherr.Error("Malformed RSA key")
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err := key.Validate(); err != nil {
+			return nil, bosherr.WrapError(err, "Validating RSA key")
+		}
+		key.Precompute()
+
+		return key, nil
+
+	case "ssh-ed25519":
+		pub.readString()
+
+		// PuTTY writes the seed as a little-endian number without trailing zeros
+		seed := priv.readString()
+		if priv.err != nil || len(seed) > ed25519.SeedSize {
+			return nil, bosherr.Error("Malformed Ed25519 key")
+		}
+		seed = append(append([]byte(nil), seed...), make([]byte, ed25519.SeedSize-len(seed))...)
+
+		return ed25519.NewKeyFromSeed(seed), nil
+
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		curve := map[string]elliptic.Curve{
+			"nistp256": elliptic.P256(),
+			"nistp384": elliptic.P384(),
+			"nistp521": elliptic.P521(),
+		}[string(pub.readString())]
+
+		point := pub.readString()
+		d := priv.readMPInt()
+		if pub.err != nil || priv.err != nil || curve == nil {
+			return nil, bosherr.Error("Malformed ECDSA key")
+		}
+
+		x, y := elliptic.Unmarshal(curve, point)
+		if x == nil {
+			return nil, bosherr.Error("Malformed ECDSA public key")
+		}
+
+		return &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: d}, nil
+
+	default:
+		return nil, bosherr.Errorf("Unsupported key type '%s'", algorithm)
+	}
+}
+
+// sshWireReader reads strings and mpints as encoded in RFC 4251
+type sshWireReader struct {
+	buf []byte
+	err error
+}
+
+func (r *sshWireReader) readString() []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if len(r.buf) < 4 {
+		r.err = bosherr.Error("Unexpected end of key data")
+		return nil
+	}
+
+	length := binary.BigEndian.Uint32(r.buf)
+	if uint64(len(r.buf)-4) < uint64(length) {
+		r.err = bosherr.Error("Unexpected end of key data")
+		return nil
+	}
+
+	s := r.buf[4 : 4+length]
+	r.buf = r.buf[4+length:]
+
+	return s
+}
+
+func (r *sshWireReader) readMPInt() *big.Int {
+	return new(big.Int).SetBytes(r.readString())
+}
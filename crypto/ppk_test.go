@@ -0,0 +1,229 @@
+package crypto_test
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ssh"
+
+	. "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+// encodePPK writes keys like puttygen does, version 3 keys are encrypted
+// with Argon2id
+func encodePPK(version int, key crypto.Signer, passphrase string) string {
+	return encodePPKWithKDF(version, key, passphrase, "Argon2id")
+}
+
+func encodePPKWithKDF(version int, key crypto.Signer, passphrase, kdf string) string {
+	publicKey, err := ssh.NewPublicKey(key.Public())
+	Expect(err).ToNot(HaveOccurred())
+
+	public := publicKey.Marshal()
+	algorithm := publicKey.Type()
+
+	var private []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		private = ssh.Marshal(struct{ D, P, Q, Iqmp *big.Int }{k.D, k.Primes[0], k.Primes[1], k.Precomputed.Qinv})
+	case *ecdsa.PrivateKey:
+		private = ssh.Marshal(struct{ D *big.Int }{k.D})
+	case ed25519.PrivateKey:
+		little := new(big.Int).SetBytes(reverse(k.Seed())).Bytes()
+		private = ssh.Marshal(struct{ Seed []byte }{reverse(little)})
+	}
+
+	encryption := "none"
+	macKey := []byte{}
+	newHash := sha256.New
+	if version == 2 {
+		newHash = func() hash.Hash { return sha1.New() }
+		h := sha1.New()
+		h.Write([]byte("putty-private-key-file-mac-key" + passphrase))
+		macKey = h.Sum(nil)
+	}
+
+	var encrypted []byte
+	var kdfLines string
+	if passphrase != "" {
+		encryption = "aes256-cbc"
+		private = append(private, make([]byte, aes.BlockSize-len(private)%aes.BlockSize)...)
+
+		var cipherKey []byte
+		iv := make([]byte, aes.BlockSize)
+
+		if version == 2 {
+			for _, counter := range [][]byte{{0, 0, 0, 0}, {0, 0, 0, 1}} {
+				h := sha1.New()
+				h.Write(counter)
+				h.Write([]byte(passphrase))
+				cipherKey = h.Sum(cipherKey)
+			}
+		} else {
+			salt := make([]byte, 16)
+			_, err := rand.Read(salt)
+			Expect(err).ToNot(HaveOccurred())
+
+			var keys []byte
+			switch kdf {
+			case "Argon2id":
+				keys = argon2.IDKey([]byte(passphrase), salt, 2, 8192, 1, 80)
+			case "Argon2i":
+				keys = argon2.Key([]byte(passphrase), salt, 2, 8192, 1, 80)
+			default:
+				keys = make([]byte, 80)
+			}
+			cipherKey, iv, macKey = keys[:32], keys[32:48], keys[48:]
+
+			kdfLines = fmt.Sprintf("Key-Derivation: %s\nArgon2-Memory: 8192\nArgon2-Passes: 2\nArgon2-Parallelism: 1\nArgon2-Salt: %s\n", kdf, hex.EncodeToString(salt))
+		}
+
+		block, err := aes.NewCipher(cipherKey[:32])
+		Expect(err).ToNot(HaveOccurred())
+
+		encrypted = make([]byte, len(private))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, private)
+	}
+
+	mac := hmac.New(newHash, macKey)
+	mac.Write(ssh.Marshal(struct {
+		Algorithm, Encryption, Comment string
+		Public, Private                []byte
+	}{algorithm, encryption, "fake-comment", public, private}))
+
+	if encrypted != nil {
+		private = encrypted
+	}
+
+	lines := func(data []byte) string {
+		encoded := base64.StdEncoding.EncodeToString(data)
+		var result []string
+		for len(encoded) > 64 {
+			result = append(result, encoded[:64])
+			encoded = encoded[64:]
+		}
+		result = append(result, encoded)
+		return fmt.Sprintf("%d\n%s", len(result), strings.Join(result, "\n"))
+	}
+
+	return fmt.Sprintf("PuTTY-User-Key-File-%d: %s\nEncryption: %s\nComment: fake-comment\nPublic-Lines: %s\n%sPrivate-Lines: %s\nPrivate-MAC: %s\n",
+		version, algorithm, encryption, lines(public), kdfLines, lines(private), hex.EncodeToString(mac.Sum(nil)))
+}
+
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
+
+var _ = Describe("ParsePrivateKey with PuTTY keys", func() {
+	var keys []crypto.Signer
+
+	BeforeEach(func() {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+
+		ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+
+		keys = []crypto.Signer{rsaKey, ecdsaKey, ed25519Key}
+	})
+
+	It("parses unencrypted version 2 and 3 keys", func() {
+		for _, version := range []int{2, 3} {
+			for _, key := range keys {
+				signer, err := ParsePrivateKey([]byte(encodePPK(version, key, "")))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(signer.Public()).To(Equal(key.Public()))
+			}
+		}
+	})
+
+	It("parses Ed25519 seeds that end with zeros", func() {
+		seed := make([]byte, ed25519.SeedSize)
+		_, err := rand.Read(seed[:30])
+		Expect(err).ToNot(HaveOccurred())
+		key := ed25519.NewKeyFromSeed(seed)
+
+		signer, err := ParsePrivateKey([]byte(encodePPK(3, key, "")))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(signer).To(Equal(key))
+	})
+
+	It("decrypts encrypted version 2 and 3 keys", func() {
+		for _, version := range []int{2, 3} {
+			for _, key := range keys {
+				ppk := []byte(encodePPK(version, key, "fake-passphrase"))
+
+				_, err := ParsePrivateKey(ppk)
+				Expect(IsPassphraseMissingError(err)).To(BeTrue())
+
+				_, err = ParsePrivateKeyWithPassphrase(ppk, []byte("wrong-passphrase"))
+				Expect(err).To(MatchError(ContainSubstring("Incorrect passphrase")))
+
+				signer, err := ParsePrivateKeyWithPassphrase(ppk, []byte("fake-passphrase"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(signer.Public()).To(Equal(key.Public()))
+			}
+		}
+	})
+
+	It("decrypts version 3 keys derived with Argon2i", func() {
+		ppk := encodePPKWithKDF(3, keys[2], "fake-passphrase", "Argon2i")
+
+		signer, err := ParsePrivateKeyWithPassphrase([]byte(ppk), []byte("fake-passphrase"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(signer.Public()).To(Equal(keys[2].Public()))
+	})
+
+	It("rejects version 3 keys derived with Argon2d", func() {
+		ppk := encodePPKWithKDF(3, keys[2], "fake-passphrase", "Argon2d")
+
+		_, err := ParsePrivateKeyWithPassphrase([]byte(ppk), []byte("fake-passphrase"))
+		Expect(err).To(MatchError(ContainSubstring("Unsupported key derivation 'Argon2d'")))
+	})
+
+	It("rejects version 3 keys asking for too much memory", func() {
+		ppk := strings.Replace(encodePPK(3, keys[2], "fake-passphrase"), "Argon2-Memory: 8192", "Argon2-Memory: 4294967295", 1)
+
+		_, err := ParsePrivateKeyWithPassphrase([]byte(ppk), []byte("fake-passphrase"))
+		Expect(err).To(MatchError(ContainSubstring("Reading 'Argon2-Memory'")))
+	})
+
+	It("rejects keys that do not match their MAC", func() {
+		ppk := strings.Replace(encodePPK(2, keys[2], ""), "Comment: fake-comment", "Comment: other-comment", 1)
+
+		_, err := ParsePrivateKey([]byte(ppk))
+		Expect(err).To(MatchError(ContainSubstring("MAC does not match the key")))
+	})
+
+	It("rejects truncated files", func() {
+		ppk := encodePPK(2, keys[2], "")
+		ppk = ppk[:strings.Index(ppk, "Private-Lines")]
+
+		_, err := ParsePrivateKey([]byte(ppk))
+		Expect(err).To(MatchError(ContainSubstring("Parsing PuTTY private key")))
+	})
+})
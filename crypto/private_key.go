@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/ssh"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// PassphraseMissingError is returned when parsing an encrypted private key
+// without a passphrase
+type PassphraseMissingError struct{}
+
+func (e PassphraseMissingError) Error() string {
+	return "Private key is encrypted but no passphrase was given"
+}
+
+func IsPassphraseMissingError(err error) bool {
+	var passphraseErr PassphraseMissingError
+	return errors.As(err, &passphraseErr)
+}
+
+// ParsePrivateKey parses RSA, ECDSA and Ed25519 private keys encoded as
+// PKCS#1, PKCS#8, SEC 1 or OpenSSH PEM blocks, or as PuTTY PPK files
+func ParsePrivateKey(keyBytes []byte) (crypto.Signer, error) {
+	return ParsePrivateKeyWithPassphrase(keyBytes, nil)
+}
+
+// ParsePrivateKeyWithPassphrase is like ParsePrivateKey and decrypts
+// encrypted keys with passphrase. Unencrypted keys ignore the passphrase.
+func ParsePrivateKeyWithPassphrase(keyBytes, passphrase []byte) (crypto.Signer, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(keyBytes), []byte(ppkHeaderPrefix)) {
+		key, err := parsePPK(keyBytes, passphrase)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Parsing PuTTY private key")
+		}
+		return key, nil
+	}
+
+	key, err := ssh.ParseRawPrivateKey(keyBytes)
+
+	var missingErr *ssh.PassphraseMissingError
+	if errors.As(err, &missingErr) {
+		if len(passphrase) == 0 {
+			return nil, PassphraseMissingError{}
+		}
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(keyBytes, passphrase)
+	}
+
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing private key")
+	}
+
+	return privateKeySigner(key)
+}
+
+// MarshalPrivateKeyPEM encodes key as an unencrypted PKCS#8 PEM block,
+// which is understood by ssh.ParsePrivateKey and openssl alike
+func MarshalPrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling private key")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func privateKeySigner(key interface{}) (crypto.Signer, error) {
+	switch k := key.(type) {
+	case *ed25519.PrivateKey:
+		return *k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	case crypto.Signer:
+		return k, nil
+	default:
+		return nil, bosherr.Errorf("Unsupported private key type %T", key)
+	}
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	AlphabetLowercase    = "abcdefghijklmnopqrstuvwxyz"
+	AlphabetUppercase    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	AlphabetDigits       = "0123456789"
+	AlphabetHex          = "0123456789abcdef"
+	AlphabetAlphanumeric = AlphabetLowercase + AlphabetUppercase + AlphabetDigits
+	AlphabetURLSafe      = AlphabetAlphanumeric + "-_"
+	AlphabetPassword     = AlphabetAlphanumeric + "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
+
+// RandomReader is the source of randomness for all helpers in this file
+var RandomReader io.Reader = rand.Reader
+
+func RandomBytes(length int) ([]byte, error) {
+	bytes := make([]byte, length)
+
+	_, err := io.ReadFull(RandomReader, bytes)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading random bytes")
+	}
+
+	return bytes, nil
+}
+
+// RandomToken returns a URL safe base64 encoded token with numBytes of entropy
+func RandomToken(numBytes int) (string, error) {
+	bytes, err := RandomBytes(numBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// RandomString returns a string of the given length where each character
+// is picked uniformly from alphabet. Rejection sampling is used so that
+// alphabets whose size is not a power of two are not biased.
+func RandomString(length int, alphabet string) (string, error) {
+	err := validateAlphabet(alphabet)
+	if err != nil {
+		return "", err
+	}
+
+	// Largest multiple of the alphabet size that fits into a byte
+	limit := 256 - (256 % len(alphabet))
+
+	result := make([]byte, 0, length)
+	buf := make([]byte, length+length/4+1)
+
+	for len(result) < length {
+		_, err := io.ReadFull(RandomReader, buf)
+		if err != nil {
+			return "", bosherr.WrapError(err, "Reading random bytes")
+		}
+
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+
+			result = append(result, alphabet[int(b)%len(alphabet)])
+			if len(result) == length {
+				break
+			}
+		}
+	}
+
+	return string(result), nil
+}
+
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return bosherr.Errorf("Alphabet must contain between 2 and 256 characters, got %d", len(alphabet))
+	}
+
+	seen := map[byte]bool{}
+	for i := 0; i < len(alphabet); i++ {
+		if alphabet[i] > 127 {
+			return bosherr.Error("Alphabet must only contain ASCII characters")
+		}
+		if seen[alphabet[i]] {
+			return bosherr.Errorf("Alphabet contains duplicate character '%c'", alphabet[i])
+		}
+		seen[alphabet[i]] = true
+	}
+
+	return nil
+}
+
+// ConstantTimeEqual compares two strings without leaking
+// the position of the first difference through timing.
+func ConstantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
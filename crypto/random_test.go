@@ -0,0 +1,91 @@
+package crypto_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+type erroringReader struct{}
+
+func (erroringReader) Read([]byte) (int, error) { return 0, errors.New("fake-read-err") }
+
+var _ = Describe("Random helpers", func() {
+	Describe("RandomToken", func() {
+		It("encodes the requested number of random bytes", func() {
+			token, err := RandomToken(32)
+			Expect(err).ToNot(HaveOccurred())
+
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(HaveLen(32))
+		})
+	})
+
+	Describe("RandomString", func() {
+		It("only uses characters from the alphabet", func() {
+			str, err := RandomString(200, AlphabetHex)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str).To(HaveLen(200))
+
+			for _, c := range str {
+				Expect(AlphabetHex).To(ContainSubstring(string(c)))
+			}
+		})
+
+		It("rejects bytes that would bias the distribution", func() {
+			// 256 % 3 == 1 so 0xff must be rejected for a 3 letter alphabet
+			origReader := RandomReader
+			defer func() { RandomReader = origReader }()
+
+			RandomReader = bytes.NewReader(append(bytes.Repeat([]byte{0xff}, 3), 0, 1, 2, 3, 4, 5, 6, 7))
+
+			str, err := RandomString(2, "abc")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str).To(Equal("ab"))
+		})
+
+		It("returns an error for invalid alphabets", func() {
+			_, err := RandomString(10, "a")
+			Expect(err).To(HaveOccurred())
+
+			_, err = RandomString(10, "abca")
+			Expect(err).To(MatchError(ContainSubstring("duplicate character 'a'")))
+
+			_, err = RandomString(10, "abé")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when randomness cannot be read", func() {
+			origReader := RandomReader
+			defer func() { RandomReader = origReader }()
+			RandomReader = erroringReader{}
+
+			_, err := RandomString(10, AlphabetPassword)
+			Expect(err).To(MatchError(ContainSubstring("fake-read-err")))
+		})
+
+		It("produces different values", func() {
+			a, err := RandomString(32, AlphabetAlphanumeric)
+			Expect(err).ToNot(HaveOccurred())
+			b, err := RandomString(32, AlphabetAlphanumeric)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(a).ToNot(Equal(b))
+			Expect(strings.Trim(a, AlphabetAlphanumeric)).To(BeEmpty())
+		})
+	})
+
+	Describe("ConstantTimeEqual", func() {
+		It("compares strings", func() {
+			Expect(ConstantTimeEqual("secret", "secret")).To(BeTrue())
+			Expect(ConstantTimeEqual("secret", "secreT")).To(BeFalse())
+			Expect(ConstantTimeEqual("secret", "secrets")).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// StreamKeySize is the size of keys used by EncryptStream and DecryptStream
+const StreamKeySize = 32
+
+// Encrypted streams start with a magic line and a random salt, followed
+// by the chunks of the age payload format sealed with a key derived
+// from the stream key and the salt. The final chunk flag is part of the
+// nonce, so truncated or reordered chunks fail to decrypt.
+const streamSaltSize = 16
+
+var streamMagic = []byte("bosh-stream/v1\n")
+
+// EncryptStream returns a writer encrypting everything written to it to dst
+// in 64 KiB chunks with a key of StreamKeySize bytes.
+// Close must be called to write the final chunk; it does not close dst.
+func EncryptStream(dst io.Writer, key []byte) (io.WriteCloser, error) {
+	salt, err := RandomBytes(streamSaltSize)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating stream salt")
+	}
+
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dst.Write(append(append([]byte{}, streamMagic...), salt...))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Writing stream header")
+	}
+
+	return newAgeStreamWriter(aead, dst), nil
+}
+
+// DecryptStream returns a reader of the plaintext of src. Every chunk is
+// authenticated before it is returned, so callers must discard everything
+// read so far when Read returns an error.
+func DecryptStream(src io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, len(streamMagic)+streamSaltSize)
+
+	_, err := io.ReadFull(src, header)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading stream header")
+	}
+
+	if !bytes.Equal(header[:len(streamMagic)], streamMagic) {
+		return nil, bosherr.Error("Unknown encrypted stream format")
+	}
+
+	aead, err := newStreamAEAD(key, header[len(streamMagic):])
+	if err != nil {
+		return nil, err
+	}
+
+	return newAgeStreamReader(aead, src), nil
+}
+
+func newStreamAEAD(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != StreamKeySize {
+		return nil, bosherr.Errorf("Expected stream key of %d bytes but got %d", StreamKeySize, len(key))
+	}
+
+	payloadKey, err := ageHKDF(key, salt, "bosh-stream payload", chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Deriving payload key")
+	}
+
+	return chacha20poly1305.New(payloadKey)
+}
@@ -0,0 +1,280 @@
+package crypto_test
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	. "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
+var _ = Describe("EncryptStream and DecryptStream", func() {
+	const (
+		headerSize    = len("bosh-stream/v1\n") + 16
+		encChunkSize  = 64*1024 + 16
+		plainChunkLen = 64 * 1024
+	)
+
+	var key []byte
+
+	BeforeEach(func() {
+		var err error
+		key, err = RandomBytes(StreamKeySize)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	encrypt := func(plaintext []byte) []byte {
+		var encrypted bytes.Buffer
+
+		writer, err := EncryptStream(&encrypted, key)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = writer.Write(plaintext)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		return encrypted.Bytes()
+	}
+
+	decrypt := func(encrypted []byte, key []byte) ([]byte, error) {
+		reader, err := DecryptStream(bytes.NewReader(encrypted), key)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	}
+
+	It("round trips payloads of various sizes", func() {
+		for _, size := range []int{0, 1, plainChunkLen, plainChunkLen + 1, 3*plainChunkLen - 7} {
+			plaintext := bytes.Repeat([]byte("x"), size)
+
+			encrypted := encrypt(plaintext)
+			Expect(string(encrypted)).To(HavePrefix("bosh-stream/v1\n"))
+
+			decrypted, err := decrypt(encrypted, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal(plaintext))
+		}
+	})
+
+	It("streams large payloads chunk by chunk", func() {
+		reader, writer := io.Pipe()
+
+		go func() {
+			encWriter, err := EncryptStream(writer, key)
+			if err == nil {
+				chunk := bytes.Repeat([]byte("y"), 10000)
+				for i := 0; i < 1000 && err == nil; i++ {
+					_, err = encWriter.Write(chunk)
+				}
+				if err == nil {
+					err = encWriter.Close()
+				}
+			}
+			writer.CloseWithError(err)
+		}()
+
+		decReader, err := DecryptStream(reader, key)
+		Expect(err).ToNot(HaveOccurred())
+
+		hash := sha256.New()
+		n, err := io.Copy(hash, decReader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(int64(10000 * 1000)))
+
+		expected := sha256.New()
+		for i := 0; i < 1000; i++ {
+			expected.Write(bytes.Repeat([]byte("y"), 10000))
+		}
+		Expect(hash.Sum(nil)).To(Equal(expected.Sum(nil)))
+	})
+
+	It("uses a new salt for every stream", func() {
+		Expect(encrypt([]byte("fake-secret"))).ToNot(Equal(encrypt([]byte("fake-secret"))))
+	})
+
+	It("fails with another key", func() {
+		otherKey, err := RandomBytes(StreamKeySize)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = decrypt(encrypt([]byte("fake-secret")), otherKey)
+		Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+	})
+
+	It("requires keys of StreamKeySize bytes", func() {
+		_, err := EncryptStream(&bytes.Buffer{}, key[:16])
+		Expect(err).To(MatchError("Expected stream key of 32 bytes but got 16"))
+
+		_, err = DecryptStream(bytes.NewReader(encrypt(nil)), key[:16])
+		Expect(err).To(MatchError("Expected stream key of 32 bytes but got 16"))
+	})
+
+	It("rejects other formats", func() {
+		_, err := decrypt(bytes.Repeat([]byte("z"), 100), key)
+		Expect(err).To(MatchError("Unknown encrypted stream format"))
+
+		_, err = decrypt([]byte("bosh"), key)
+		Expect(err).To(MatchError(ContainSubstring("Reading stream header")))
+	})
+
+	It("detects modified chunks", func() {
+		encrypted := encrypt([]byte("fake-secret"))
+		encrypted[len(encrypted)-20] ^= 1
+
+		_, err := decrypt(encrypted, key)
+		Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+	})
+
+	It("detects streams truncated at a chunk boundary", func() {
+		encrypted := encrypt(bytes.Repeat([]byte("x"), 2*plainChunkLen+1))
+
+		_, err := decrypt(encrypted[:headerSize+2*encChunkSize], key)
+		Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+
+		_, err = decrypt(encrypted[:headerSize], key)
+		Expect(err).To(MatchError("Truncated encrypted payload"))
+	})
+
+	It("detects reordered chunks", func() {
+		encrypted := encrypt(bytes.Repeat([]byte("x"), 2*plainChunkLen+1))
+
+		first := encrypted[headerSize : headerSize+encChunkSize]
+		second := encrypted[headerSize+encChunkSize : headerSize+2*encChunkSize]
+
+		var reordered []byte
+		reordered = append(reordered, encrypted[:headerSize]...)
+		reordered = append(reordered, second...)
+		reordered = append(reordered, first...)
+		reordered = append(reordered, encrypted[headerSize+2*encChunkSize:]...)
+
+		_, err := decrypt(reordered, key)
+		Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+	})
+
+	It("does not allow writes after Close", func() {
+		writer, err := EncryptStream(&bytes.Buffer{}, key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		_, err = writer.Write([]byte("late"))
+		Expect(err).To(MatchError("Writing to closed encrypted stream"))
+	})
+
+	Describe("STREAM construction", func() {
+		var salt []byte
+
+		BeforeEach(func() {
+			salt = bytes.Repeat([]byte{0x5a}, 16)
+		})
+
+		payloadAEAD := func(salt []byte) cipher.AEAD {
+			payloadKey := make([]byte, chacha20poly1305.KeySize)
+			_, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte("bosh-stream payload")), payloadKey)
+			Expect(err).ToNot(HaveOccurred())
+
+			aead, err := chacha20poly1305.New(payloadKey)
+			Expect(err).ToNot(HaveOccurred())
+			return aead
+		}
+
+		nonce := func(counter int, final bool) []byte {
+			n := make([]byte, chacha20poly1305.NonceSize)
+			n[len(n)-2] = byte(counter)
+			if final {
+				n[len(n)-1] = 1
+			}
+			return n
+		}
+
+		// seal builds a stream chunk by chunk independently of EncryptStream
+		seal := func(chunks []string, finalFlags []bool) []byte {
+			aead := payloadAEAD(salt)
+
+			stream := append([]byte("bosh-stream/v1\n"), salt...)
+			for i, chunk := range chunks {
+				stream = aead.Seal(stream, nonce(i, finalFlags[i]), []byte(chunk), nil)
+			}
+			return stream
+		}
+
+		fullChunk := string(bytes.Repeat([]byte("x"), plainChunkLen))
+
+		It("decrypts known answers", func() {
+			key = make([]byte, StreamKeySize)
+			for i := range key {
+				key[i] = byte(i)
+			}
+
+			// magic line, salt of 0x5a bytes and "fake-secret" as final chunk
+			encrypted, err := hex.DecodeString("626f73682d73747265616d2f76310a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a" +
+				"5ada71b21896ed94e5e33ebf5f88e592d09ad3bb4dfecac69cfeaea8")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(seal([]string{"fake-secret"}, []bool{true})).To(Equal(encrypted))
+
+			decrypted, err := decrypt(encrypted, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(decrypted)).To(Equal("fake-secret"))
+		})
+
+		It("marks only the last chunk as final", func() {
+			encrypted := encrypt([]byte(fullChunk + "tail"))
+			aead := payloadAEAD(encrypted[headerSize-16 : headerSize])
+
+			_, err := aead.Open(nil, nonce(0, false), encrypted[headerSize:headerSize+encChunkSize], nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			last, err := aead.Open(nil, nonce(1, true), encrypted[headerSize+encChunkSize:], nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(last)).To(Equal("tail"))
+		})
+
+		It("writes a full last chunk instead of an empty one", func() {
+			encrypted := encrypt([]byte(fullChunk))
+			Expect(encrypted).To(HaveLen(headerSize + encChunkSize))
+
+			aead := payloadAEAD(encrypted[headerSize-16 : headerSize])
+			_, err := aead.Open(nil, nonce(0, true), encrypted[headerSize:], nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("decrypts streams sealed chunk by chunk", func() {
+			decrypted, err := decrypt(seal([]string{fullChunk, "tail"}, []bool{false, true}), key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(decrypted)).To(Equal(fullChunk + "tail"))
+
+			decrypted, err = decrypt(seal([]string{""}, []bool{true}), key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(BeEmpty())
+		})
+
+		It("requires the final chunk flag on the last chunk only", func() {
+			_, err := decrypt(seal([]string{fullChunk, "tail"}, []bool{false, false}), key)
+			Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+
+			_, err = decrypt(seal([]string{fullChunk, "tail"}, []bool{true, true}), key)
+			Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+		})
+
+		It("rejects an empty last chunk after other chunks", func() {
+			_, err := decrypt(seal([]string{fullChunk, ""}, []bool{false, true}), key)
+			Expect(err).To(MatchError("Unexpected empty last chunk"))
+		})
+
+		It("rejects streams truncated after a chunk", func() {
+			stream := seal([]string{fullChunk, fullChunk, "tail"}, []bool{false, false, true})
+
+			_, err := decrypt(stream[:headerSize+2*encChunkSize], key)
+			Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+
+			_, err = decrypt(stream[:headerSize+encChunkSize+20], key)
+			Expect(err).To(MatchError(ContainSubstring("Decrypting chunk")))
+		})
+	})
+})
@@ -9,7 +9,21 @@ import (
 )
 
 func CertPoolFromPEM(pemCerts []byte) (*x509.CertPool, error) {
+	certs, err := parseCertificatesPEM(pemCerts)
+	if err != nil {
+		return nil, err
+	}
+
 	certPool := x509.NewCertPool()
+	for _, cert := range certs {
+		certPool.AddCert(cert)
+	}
+
+	return certPool, nil
+}
+
+func parseCertificatesPEM(pemCerts []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
 
 	for pemCertsIdx := 1; len(pemCerts) > 0; pemCertsIdx++ {
 		var block *pem.Block
@@ -32,8 +46,8 @@ func CertPoolFromPEM(pemCerts []byte) (*x509.CertPool, error) {
 			return nil, bosherr.WrapErrorf(err, "Parsing certificate %d", pemCertsIdx)
 		}
 
-		certPool.AddCert(cert)
+		certs = append(certs, cert)
 	}
 
-	return certPool, nil
+	return certs, nil
 }
@@ -45,6 +45,10 @@ func (e ComplexError) ShortError() string {
 	return fmt.Sprintf("%s: %s", errorMessage, causeMessage)
 }
 
+func (e ComplexError) Unwrap() []error {
+	return []error{e.Err, e.Cause}
+}
+
 func Error(msg string) error {
 	return errors.New(msg)
 }
@@ -0,0 +1,129 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	ExitCodeOK          = 0
+	ExitCodeFailure     = 1
+	ExitCodeUserError   = 2
+	ExitCodeUnavailable = 69
+	ExitCodeInternal    = 70
+	ExitCodeTempFailure = 75
+)
+
+// ExitCoder is implemented by errors that know which process exit code
+// they should result in when they terminate a CLI.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+type ExitError struct {
+	Err  error
+	Code int
+}
+
+func NewExitError(err error, code int) ExitError {
+	return ExitError{Err: err, Code: code}
+}
+
+func (e ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e ExitError) ExitCode() int { return e.Code }
+
+func (e ExitError) Unwrap() error { return e.Err }
+
+func (e UserError) Error() string {
+	if e.Err == nil {
+		return "<nil user error>"
+	}
+	return e.Err.Error()
+}
+
+func (e UserError) ExitCode() int { return ExitCodeUserError }
+
+func (e UserError) Unwrap() error { return e.Err }
+
+// ExitCodeFor returns the exit code for the first ExitCoder found in the
+// error chain, ExitCodeOK for a nil error and ExitCodeFailure otherwise.
+func ExitCodeFor(err error) int {
+	if err == nil {
+		return ExitCodeOK
+	}
+
+	var exitCoder ExitCoder
+	if errors.As(err, &exitCoder) {
+		return exitCoder.ExitCode()
+	}
+
+	return ExitCodeFailure
+}
+
+type MainOpts struct {
+	// JSON prints errors as a single JSON object instead of human readable text
+	JSON bool
+
+	// Stderr defaults to os.Stderr
+	Stderr io.Writer
+}
+
+type jsonError struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// PrintError writes err to w either as human readable text
+// (shortened when possible) or as a JSON object.
+func PrintError(w io.Writer, err error, asJSON bool) {
+	if err == nil {
+		return
+	}
+
+	code := ExitCodeFor(err)
+
+	if asJSON {
+		bytes, marshalErr := json.Marshal(jsonError{Error: err.Error(), ExitCode: code})
+		if marshalErr == nil {
+			fmt.Fprintf(w, "%s\n", bytes)
+			return
+		}
+	}
+
+	msg := err.Error()
+	if shortenableErr, ok := err.(ShortenableError); ok {
+		msg = shortenableErr.ShortError()
+	}
+
+	fmt.Fprintf(w, "Error: %s\n", msg)
+}
+
+// RunMain runs fn, prints any returned error and returns the exit code
+// the process should terminate with.
+func RunMain(fn func() error, opts MainOpts) int {
+	stderr := opts.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	err := fn()
+	PrintError(stderr, err, opts.JSON)
+
+	return ExitCodeFor(err)
+}
+
+// Main is meant to be the only call in a CLI's main function.
+// It exits the process with the exit code mapped from fn's error.
+func Main(fn func() error, opts MainOpts) {
+	os.Exit(RunMain(fn, opts))
+}
@@ -0,0 +1,84 @@
+package errors_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var _ = Describe("ExitCodeFor", func() {
+	It("returns 0 for nil errors", func() {
+		Expect(ExitCodeFor(nil)).To(Equal(ExitCodeOK))
+	})
+
+	It("returns generic failure for untyped errors", func() {
+		Expect(ExitCodeFor(Error("fake-error"))).To(Equal(ExitCodeFailure))
+	})
+
+	It("returns the exit code of an ExitCoder", func() {
+		err := NewExitError(Error("fake-error"), ExitCodeTempFailure)
+		Expect(ExitCodeFor(err)).To(Equal(ExitCodeTempFailure))
+	})
+
+	It("maps user errors", func() {
+		Expect(ExitCodeFor(NewUserError("fake-error"))).To(Equal(ExitCodeUserError))
+	})
+
+	It("finds exit coders wrapped as causes", func() {
+		err := WrapError(NewExitError(Error("fake-cause"), 42), "fake-message")
+		Expect(ExitCodeFor(err)).To(Equal(42))
+	})
+
+	It("finds exit coders inside multi errors", func() {
+		err := NewMultiError(Error("fake-error"), NewUserError("fake-user-error"))
+		Expect(ExitCodeFor(err)).To(Equal(ExitCodeUserError))
+	})
+})
+
+var _ = Describe("PrintError", func() {
+	It("prints short human readable errors", func() {
+		buf := &bytes.Buffer{}
+		cause := &testShortError{fullMsg: "cause-full", shortMsg: "cause-short"}
+
+		PrintError(buf, WrapError(cause, "fake-message"), false)
+		Expect(buf.String()).To(Equal("Error: fake-message: cause-short\n"))
+	})
+
+	It("prints JSON errors including the exit code", func() {
+		buf := &bytes.Buffer{}
+
+		PrintError(buf, NewExitError(Error("fake-error"), 3), true)
+
+		var out map[string]interface{}
+		Expect(json.Unmarshal(buf.Bytes(), &out)).To(Succeed())
+		Expect(out).To(Equal(map[string]interface{}{"error": "fake-error", "exit_code": float64(3)}))
+	})
+
+	It("prints nothing for nil errors", func() {
+		buf := &bytes.Buffer{}
+		PrintError(buf, nil, false)
+		Expect(buf.String()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("RunMain", func() {
+	It("returns the mapped exit code and prints the error", func() {
+		buf := &bytes.Buffer{}
+
+		code := RunMain(func() error { return NewUserError("bad flag") }, MainOpts{Stderr: buf})
+		Expect(code).To(Equal(ExitCodeUserError))
+		Expect(buf.String()).To(Equal("Error: bad flag\n"))
+	})
+
+	It("returns 0 when fn succeeds", func() {
+		buf := &bytes.Buffer{}
+
+		code := RunMain(func() error { return nil }, MainOpts{Stderr: buf})
+		Expect(code).To(Equal(ExitCodeOK))
+		Expect(buf.String()).To(BeEmpty())
+	})
+})
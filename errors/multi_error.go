@@ -19,3 +19,7 @@ func (e MultiError) Error() string {
 	}
 	return strings.Join(errors, "\n")
 }
+
+func (e MultiError) Unwrap() []error {
+	return e.Errors
+}
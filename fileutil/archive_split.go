@@ -0,0 +1,190 @@
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// SplitManifestSuffix is appended to the archive path to name the manifest
+// written by SplitArchive
+const SplitManifestSuffix = ".parts.json"
+
+// SplitManifest describes an archive split into parts. Part paths are
+// relative to the manifest so that the parts can be moved together.
+type SplitManifest struct {
+	Name   string                    `json:"name"`
+	Size   int64                     `json:"size"`
+	Digest boshcrypto.MultipleDigest `json:"digest"`
+	Parts  []SplitPart               `json:"parts"`
+}
+
+type SplitPart struct {
+	Path   string                    `json:"path"`
+	Size   int64                     `json:"size"`
+	Digest boshcrypto.MultipleDigest `json:"digest"`
+}
+
+// SplitArchive writes archivePath as parts of at most maxPartBytes next to
+// it, named <archive>.part0000 and so on, and returns the path of the manifest
+// that JoinArchive needs to put them back together
+func SplitArchive(fs boshsys.FileSystem, archivePath string, maxPartBytes int64) (string, error) {
+	if maxPartBytes <= 0 {
+		return "", bosherr.Errorf("Splitting archive '%s': max part size must be positive", archivePath)
+	}
+
+	src, err := fs.OpenFile(archivePath, os.O_RDONLY, 0)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Opening archive '%s'", archivePath)
+	}
+
+	defer src.Close()
+
+	manifest := SplitManifest{Name: filepath.Base(archivePath)}
+	archiveHash := sha256.New()
+	reader := io.TeeReader(src, archiveHash)
+
+	for i := 0; ; i++ {
+		partPath := fmt.Sprintf("%s.part%04d", archivePath, i)
+
+		part, err := writeSplitPart(fs, partPath, io.LimitReader(reader, maxPartBytes))
+		if err != nil {
+			return "", err
+		}
+
+		// an empty archive still gets a single empty part
+		if part.Size == 0 && i > 0 {
+			err = fs.RemoveAll(partPath)
+			if err != nil {
+				return "", bosherr.WrapErrorf(err, "Removing empty part '%s'", partPath)
+			}
+			break
+		}
+
+		manifest.Size += part.Size
+		manifest.Parts = append(manifest.Parts, part)
+
+		if part.Size < maxPartBytes {
+			break
+		}
+	}
+
+	manifest.Digest = sha256MultipleDigest(archiveHash)
+
+	contents, err := json.Marshal(manifest)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Marshalling split manifest")
+	}
+
+	manifestPath := archivePath + SplitManifestSuffix
+
+	err = fs.WriteFile(manifestPath, contents)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Writing split manifest '%s'", manifestPath)
+	}
+
+	return manifestPath, nil
+}
+
+// JoinArchive concatenates the parts listed in the manifest into dstPath
+// and verifies the digests of every part and of the whole archive
+func JoinArchive(fs boshsys.FileSystem, manifestPath, dstPath string) error {
+	contents, err := fs.ReadFile(manifestPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading split manifest '%s'", manifestPath)
+	}
+
+	var manifest SplitManifest
+
+	err = json.Unmarshal(contents, &manifest)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Unmarshalling split manifest '%s'", manifestPath)
+	}
+
+	dst, err := fs.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating archive '%s'", dstPath)
+	}
+
+	err = joinArchiveParts(fs, manifest, filepath.Dir(manifestPath), dst)
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = bosherr.WrapErrorf(closeErr, "Closing archive '%s'", dstPath)
+	}
+
+	if err == nil {
+		err = manifest.Digest.VerifyFilePath(dstPath, fs)
+	}
+
+	if err != nil {
+		fs.RemoveAll(dstPath)
+		return bosherr.WrapErrorf(err, "Joining archive '%s'", manifest.Name)
+	}
+
+	return nil
+}
+
+func writeSplitPart(fs boshsys.FileSystem, partPath string, reader io.Reader) (SplitPart, error) {
+	dst, err := fs.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return SplitPart{}, bosherr.WrapErrorf(err, "Creating part '%s'", partPath)
+	}
+
+	size, digest, err := CopyWithDigest(dst, reader, boshcrypto.DigestAlgorithmSHA256)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return SplitPart{}, bosherr.WrapErrorf(err, "Writing part '%s'", partPath)
+	}
+
+	return SplitPart{
+		Path:   filepath.Base(partPath),
+		Size:   size,
+		Digest: digest,
+	}, nil
+}
+
+func joinArchiveParts(fs boshsys.FileSystem, manifest SplitManifest, dir string, dst io.Writer) error {
+	var size int64
+
+	for _, part := range manifest.Parts {
+		partPath := filepath.Join(dir, part.Path)
+
+		err := part.Digest.VerifyFilePath(partPath, fs)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Verifying part '%s'", partPath)
+		}
+
+		src, err := fs.OpenFile(partPath, os.O_RDONLY, 0)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Opening part '%s'", partPath)
+		}
+
+		n, err := boshsys.CopyStream(dst, src, boshsys.CopyOpts{})
+		src.Close()
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Copying part '%s'", partPath)
+		}
+
+		size += n
+	}
+
+	if size != manifest.Size {
+		return bosherr.Errorf("Expected %d bytes but parts contain %d bytes", manifest.Size, size)
+	}
+
+	return nil
+}
+
+func sha256MultipleDigest(h hash.Hash) boshcrypto.MultipleDigest {
+	return boshcrypto.MustNewMultipleDigest(boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA256, hex.EncodeToString(h.Sum(nil))))
+}
@@ -0,0 +1,103 @@
+package fileutil_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("SplitArchive and JoinArchive", func() {
+	var (
+		fs          boshsys.FileSystem
+		dir         string
+		archivePath string
+	)
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		dir = GinkgoT().TempDir()
+		archivePath = filepath.Join(dir, "release.tgz")
+	})
+
+	readManifest := func(manifestPath string) SplitManifest {
+		contents, err := os.ReadFile(manifestPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		var manifest SplitManifest
+		Expect(json.Unmarshal(contents, &manifest)).To(Succeed())
+		return manifest
+	}
+
+	It("splits archives into parts of at most maxPartBytes and joins them again", func() {
+		contents := strings.Repeat("0123456789", 25)
+		Expect(os.WriteFile(archivePath, []byte(contents), 0644)).To(Succeed())
+
+		manifestPath, err := SplitArchive(fs, archivePath, 100)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifestPath).To(Equal(archivePath + ".parts.json"))
+
+		manifest := readManifest(manifestPath)
+		Expect(manifest.Name).To(Equal("release.tgz"))
+		Expect(manifest.Size).To(Equal(int64(250)))
+		Expect(manifest.Parts).To(HaveLen(3))
+		Expect(manifest.Parts[0].Path).To(Equal("release.tgz.part0000"))
+		Expect(manifest.Parts[0].Size).To(Equal(int64(100)))
+		Expect(manifest.Parts[2].Size).To(Equal(int64(50)))
+
+		dstPath := filepath.Join(dir, "joined.tgz")
+		Expect(JoinArchive(fs, manifestPath, dstPath)).To(Succeed())
+
+		joined, err := os.ReadFile(dstPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(joined)).To(Equal(contents))
+	})
+
+	It("does not write an empty trailing part when the size is a multiple of maxPartBytes", func() {
+		Expect(os.WriteFile(archivePath, []byte(strings.Repeat("a", 200)), 0644)).To(Succeed())
+
+		manifestPath, err := SplitArchive(fs, archivePath, 100)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readManifest(manifestPath).Parts).To(HaveLen(2))
+		Expect(filepath.Join(dir, "release.tgz.part0002")).ToNot(BeAnExistingFile())
+	})
+
+	It("splits empty archives into a single empty part", func() {
+		Expect(os.WriteFile(archivePath, nil, 0644)).To(Succeed())
+
+		manifestPath, err := SplitArchive(fs, archivePath, 100)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readManifest(manifestPath).Parts).To(HaveLen(1))
+
+		dstPath := filepath.Join(dir, "joined.tgz")
+		Expect(JoinArchive(fs, manifestPath, dstPath)).To(Succeed())
+		Expect(dstPath).To(BeAnExistingFile())
+	})
+
+	It("fails to join parts that were modified and removes the partial archive", func() {
+		Expect(os.WriteFile(archivePath, []byte(strings.Repeat("a", 150)), 0644)).To(Succeed())
+
+		manifestPath, err := SplitArchive(fs, archivePath, 100)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(dir, "release.tgz.part0001"), []byte(strings.Repeat("b", 50)), 0644)).To(Succeed())
+
+		dstPath := filepath.Join(dir, "joined.tgz")
+		err = JoinArchive(fs, manifestPath, dstPath)
+		Expect(err).To(MatchError(ContainSubstring("Verifying part")))
+		Expect(dstPath).ToNot(BeAnExistingFile())
+	})
+
+	It("rejects non-positive part sizes", func() {
+		_, err := SplitArchive(fs, archivePath, 0)
+		Expect(err).To(MatchError(ContainSubstring("max part size must be positive")))
+	})
+})
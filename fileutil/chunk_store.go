@@ -0,0 +1,325 @@
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/fileutil/pathutil"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// ChunkManifest lists the chunks of every file stored by ChunkStore.Store.
+// Keeping it is enough to restore the directory and to deduplicate the
+// next backup against this one.
+type ChunkManifest struct {
+	Files []ChunkedFile `json:"files"`
+}
+
+type ChunkedFile struct {
+	// Path is relative to the stored directory and slash separated
+	Path string      `json:"path"`
+	Mode os.FileMode `json:"mode"`
+
+	// Link is the target of symlinks
+	Link string `json:"link,omitempty"`
+
+	Chunks []ChunkRef `json:"chunks,omitempty"`
+}
+
+type ChunkRef struct {
+	// Digest is the hex encoded SHA-256 of the chunk
+	Digest string `json:"digest"`
+	BlobID string `json:"blob_id"`
+	Size   int    `json:"size"`
+}
+
+type ChunkStoreResult struct {
+	Chunks        int
+	Uploaded      int
+	BytesUploaded int64
+}
+
+// ChunkStore backs up directories as content defined chunks in a blobstore
+// and only uploads chunks that are not referenced by a previous manifest yet
+type ChunkStore struct {
+	fs        boshsys.FileSystem
+	blobstore boshblob.DigestBlobstore
+	opts      ChunkerOpts
+}
+
+func NewChunkStore(fs boshsys.FileSystem, blobstore boshblob.DigestBlobstore, opts ChunkerOpts) ChunkStore {
+	return ChunkStore{fs: fs, blobstore: blobstore, opts: opts}
+}
+
+// Store chunks directories, regular files and symlinks below dir.
+// Chunks of previous are reused instead of being uploaded again,
+// which requires their blobs to still exist.
+func (s ChunkStore) Store(dir string, previous ChunkManifest) (ChunkManifest, ChunkStoreResult, error) {
+	var manifest ChunkManifest
+	var result ChunkStoreResult
+
+	known := map[string]ChunkRef{}
+	for _, file := range previous.Files {
+		for _, chunk := range file.Chunks {
+			known[chunk.Digest] = chunk
+		}
+	}
+
+	err := s.fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == dir {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		file := ChunkedFile{Path: filepath.ToSlash(relPath), Mode: info.Mode()}
+
+		switch {
+		case info.IsDir():
+		case info.Mode()&os.ModeSymlink != 0:
+			file.Link, err = s.fs.Readlink(path)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Reading link '%s'", path)
+			}
+		case info.Mode().IsRegular():
+			file.Chunks, err = s.storeFile(path, known, &result)
+			if err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+
+		manifest.Files = append(manifest.Files, file)
+
+		return nil
+	})
+	if err != nil {
+		return ChunkManifest{}, result, bosherr.WrapErrorf(err, "Storing chunks of '%s'", dir)
+	}
+
+	return manifest, result, nil
+}
+
+func (s ChunkStore) storeFile(path string, known map[string]ChunkRef, result *ChunkStoreResult) ([]ChunkRef, error) {
+	file, err := s.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening '%s'", path)
+	}
+
+	defer file.Close()
+
+	chunker, err := NewChunker(file, s.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []ChunkRef
+
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Chunking '%s'", path)
+		}
+
+		sum := sha256.Sum256(chunk)
+		digest := hex.EncodeToString(sum[:])
+
+		result.Chunks++
+
+		ref, found := known[digest]
+		if !found {
+			ref, err = s.upload(chunk, digest)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Uploading chunk of '%s'", path)
+			}
+
+			known[digest] = ref
+			result.Uploaded++
+			result.BytesUploaded += int64(len(chunk))
+		}
+
+		refs = append(refs, ref)
+	}
+}
+
+func (s ChunkStore) upload(chunk []byte, digest string) (ChunkRef, error) {
+	tempFile, err := s.fs.TempFile("bosh-utils-chunk")
+	if err != nil {
+		return ChunkRef{}, bosherr.WrapError(err, "Creating temporary file")
+	}
+
+	defer s.fs.RemoveAll(tempFile.Name())
+
+	_, err = tempFile.Write(chunk)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ChunkRef{}, bosherr.WrapError(err, "Writing temporary file")
+	}
+
+	blobID, _, err := s.blobstore.Create(tempFile.Name())
+	if err != nil {
+		return ChunkRef{}, err
+	}
+
+	return ChunkRef{Digest: digest, BlobID: blobID, Size: len(chunk)}, nil
+}
+
+// Restore recreates the files of manifest below dir. Symlinks have to
+// point inside of dir and are created after the files, so that no file is
+// restored through a symlink of the manifest. Directories get their modes
+// last so that read-only ones can still be filled.
+func (s ChunkStore) Restore(manifest ChunkManifest, dir string) error {
+	paths := make([]string, len(manifest.Files))
+	targets := make([]string, len(manifest.Files))
+
+	// the whole manifest is checked so that nothing is restored for invalid ones
+	for i, file := range manifest.Files {
+		path, err := pathutil.SafeJoin(dir, filepath.FromSlash(file.Path))
+		if err == nil && path == filepath.Clean(dir) {
+			err = bosherr.Errorf("Path '%s' is the restore directory", file.Path)
+		}
+		if err == nil && file.Mode&os.ModeSymlink != 0 {
+			targets[i], err = safeLinkTarget(dir, path, file.Link)
+		}
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Restoring '%s'", file.Path)
+		}
+
+		paths[i] = path
+	}
+
+	var dirs []int
+
+	for i, file := range manifest.Files {
+		if file.Mode&os.ModeSymlink != 0 {
+			continue
+		}
+
+		err := s.prepareRestore(dir, paths[i])
+		if err == nil {
+			if file.Mode.IsDir() {
+				err = s.fs.MkdirAll(paths[i], os.FileMode(0700))
+				dirs = append(dirs, i)
+			} else {
+				err = s.restoreFile(file, paths[i])
+			}
+		}
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Restoring '%s'", file.Path)
+		}
+	}
+
+	for i, file := range manifest.Files {
+		if file.Mode&os.ModeSymlink == 0 {
+			continue
+		}
+
+		err := s.prepareRestore(dir, paths[i])
+		if err == nil {
+			err = s.fs.Symlink(targets[i], paths[i])
+		}
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Restoring '%s'", file.Path)
+		}
+	}
+
+	// deepest first so that no parent is read-only yet
+	sort.SliceStable(dirs, func(a, b int) bool {
+		return len(paths[dirs[a]]) > len(paths[dirs[b]])
+	})
+
+	for _, i := range dirs {
+		err := s.fs.Chmod(paths[i], manifest.Files[i].Mode.Perm())
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Restoring '%s'", manifest.Files[i].Path)
+		}
+	}
+
+	return nil
+}
+
+// prepareRestore creates the parents of path without going through
+// symlinks and removes a symlink at path so that it is replaced
+func (s ChunkStore) prepareRestore(dir, path string) error {
+	err := checkNoSymlinkParents(s.fs, dir, path)
+	if err != nil {
+		return err
+	}
+
+	err = s.fs.MkdirAll(filepath.Dir(path), os.FileMode(0755))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating parent directory of '%s'", path)
+	}
+
+	return removeSymlink(s.fs, path)
+}
+
+func (s ChunkStore) restoreFile(file ChunkedFile, path string) error {
+	dst, err := s.fs.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, file.Mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range file.Chunks {
+		err = s.restoreChunk(chunk, dst)
+		if err != nil {
+			break
+		}
+	}
+
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.fs.Chmod(path, file.Mode.Perm())
+}
+
+func (s ChunkStore) restoreChunk(chunk ChunkRef, dst io.Writer) error {
+	fileName, err := s.blobstore.Get(chunk.BlobID, boshcrypto.NewDigest(boshcrypto.DigestAlgorithmSHA256, chunk.Digest))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting chunk '%s'", chunk.BlobID)
+	}
+
+	defer s.blobstore.CleanUp(fileName)
+
+	src, err := s.fs.OpenFile(fileName, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening chunk '%s'", chunk.BlobID)
+	}
+
+	defer src.Close()
+
+	n, err := boshsys.CopyStream(dst, src, boshsys.CopyOpts{})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying chunk '%s'", chunk.BlobID)
+	}
+
+	if n != int64(chunk.Size) {
+		return bosherr.Errorf("Expected chunk '%s' to have %d bytes but got %d", chunk.BlobID, chunk.Size, n)
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package fileutil_test
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshblob "github.com/cloudfoundry/bosh-utils/blobstore"
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	. "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+var _ = Describe("ChunkStore", func() {
+	var (
+		fs         boshsys.FileSystem
+		dir        string
+		chunkStore ChunkStore
+		data       []byte
+	)
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		dir = GinkgoT().TempDir()
+
+		blobstore := boshblob.NewDigestVerifiableBlobstore(
+			boshblob.NewLocalBlobstore(fs, boshuuid.NewGenerator(), map[string]interface{}{"blobstore_path": GinkgoT().TempDir()}),
+			fs,
+			[]boshcrypto.Algorithm{boshcrypto.DigestAlgorithmSHA256},
+		)
+		chunkStore = NewChunkStore(fs, blobstore, ChunkerOpts{MinSize: 1024, AvgSize: 4096, MaxSize: 16384})
+
+		data = make([]byte, 256*1024)
+		rand.New(rand.NewSource(3)).Read(data)
+
+		Expect(os.MkdirAll(filepath.Join(dir, "data", "empty"), 0750)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "data", "disk.img"), data, 0640)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "data", "copy.img"), data, 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "empty-file"), nil, 0644)).To(Succeed())
+	})
+
+	It("restores stored directories", func() {
+		if runtime.GOOS != "windows" {
+			Expect(os.Symlink("data/disk.img", filepath.Join(dir, "link"))).To(Succeed())
+		}
+
+		manifest, result, err := chunkStore.Store(dir, ChunkManifest{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Uploaded).To(Equal(result.Chunks / 2))
+		Expect(result.BytesUploaded).To(Equal(int64(len(data))))
+
+		restoreDir := filepath.Join(GinkgoT().TempDir(), "restored")
+		Expect(chunkStore.Restore(manifest, restoreDir)).To(Succeed())
+
+		restored, err := os.ReadFile(filepath.Join(restoreDir, "data", "disk.img"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored).To(Equal(data))
+
+		restored, err = os.ReadFile(filepath.Join(restoreDir, "data", "copy.img"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored).To(Equal(data))
+
+		Expect(filepath.Join(restoreDir, "data", "empty")).To(BeADirectory())
+		Expect(filepath.Join(restoreDir, "empty-file")).To(BeARegularFile())
+
+		if runtime.GOOS != "windows" {
+			info, err := os.Stat(filepath.Join(restoreDir, "data", "copy.img"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			target, err := os.Readlink(filepath.Join(restoreDir, "link"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(target).To(Equal("data/disk.img"))
+		}
+	})
+
+	It("restores read-only directories after their contents", func() {
+		if runtime.GOOS == "windows" {
+			Skip("Directory modes are not restored on Windows")
+		}
+
+		manifest := ChunkManifest{Files: []ChunkedFile{
+			{Path: "ro", Mode: os.ModeDir | 0555},
+			{Path: "ro/sub", Mode: os.ModeDir | 0500},
+			{Path: "ro/sub/file", Mode: 0444},
+			{Path: "ro/link", Mode: os.ModeSymlink | 0777, Link: "sub/file"},
+		}}
+
+		restoreDir := GinkgoT().TempDir()
+		DeferCleanup(func() {
+			os.Chmod(filepath.Join(restoreDir, "ro", "sub"), 0755)
+			os.Chmod(filepath.Join(restoreDir, "ro"), 0755)
+		})
+
+		Expect(chunkStore.Restore(manifest, restoreDir)).To(Succeed())
+
+		info, err := os.Stat(filepath.Join(restoreDir, "ro"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0555)))
+
+		info, err = os.Stat(filepath.Join(restoreDir, "ro", "sub"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0500)))
+
+		Expect(filepath.Join(restoreDir, "ro", "sub", "file")).To(BeARegularFile())
+		Expect(os.Readlink(filepath.Join(restoreDir, "ro", "link"))).To(Equal("sub/file"))
+	})
+
+	It("only uploads changed chunks of later backups", func() {
+		first, _, err := chunkStore.Store(dir, ChunkManifest{})
+		Expect(err).ToNot(HaveOccurred())
+
+		data[100*1024] ^= 0xff
+		Expect(os.WriteFile(filepath.Join(dir, "data", "disk.img"), data, 0640)).To(Succeed())
+
+		second, result, err := chunkStore.Store(dir, first)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Uploaded).To(BeNumerically(">=", 1))
+		Expect(result.BytesUploaded).To(BeNumerically("<", 3*16384))
+
+		restoreDir := GinkgoT().TempDir()
+		Expect(chunkStore.Restore(second, restoreDir)).To(Succeed())
+
+		restored, err := os.ReadFile(filepath.Join(restoreDir, "data", "disk.img"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored).To(Equal(data))
+	})
+
+	It("refuses to restore paths outside of the directory", func() {
+		manifest := ChunkManifest{Files: []ChunkedFile{{Path: "../escape", Mode: 0644}}}
+
+		err := chunkStore.Restore(manifest, GinkgoT().TempDir())
+		Expect(err).To(MatchError(ContainSubstring("escapes root")))
+	})
+
+	Context("with symlinks", func() {
+		var restoreDir string
+
+		BeforeEach(func() {
+			if runtime.GOOS == "windows" {
+				Skip("Creating symlinks requires privileges on Windows")
+			}
+
+			restoreDir = GinkgoT().TempDir()
+		})
+
+		It("refuses link targets outside of the directory before restoring anything", func() {
+			for _, target := range []string{"/etc/passwd", "../../outside", "../data/../../outside"} {
+				manifest := ChunkManifest{Files: []ChunkedFile{
+					{Path: "data", Mode: os.ModeDir | 0755},
+					{Path: "data/link", Mode: os.ModeSymlink | 0777, Link: target},
+				}}
+
+				err := chunkStore.Restore(manifest, restoreDir)
+				Expect(err).To(HaveOccurred())
+				Expect(filepath.Join(restoreDir, "data")).ToNot(BeADirectory())
+			}
+		})
+
+		It("creates symlinks after the files they point to", func() {
+			manifest := ChunkManifest{Files: []ChunkedFile{
+				{Path: "link", Mode: os.ModeSymlink | 0777, Link: "data"},
+				{Path: "data", Mode: os.ModeDir | 0750},
+				{Path: "data/empty", Mode: 0640},
+			}}
+
+			Expect(chunkStore.Restore(manifest, restoreDir)).To(Succeed())
+
+			target, err := os.Readlink(filepath.Join(restoreDir, "link"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(target).To(Equal("data"))
+			Expect(filepath.Join(restoreDir, "link", "empty")).To(BeARegularFile())
+		})
+
+		It("does not restore files through symlinks", func() {
+			outside := GinkgoT().TempDir()
+			Expect(os.Symlink(outside, filepath.Join(restoreDir, "data"))).To(Succeed())
+
+			manifest := ChunkManifest{Files: []ChunkedFile{{Path: "data/file", Mode: 0644}}}
+
+			err := chunkStore.Restore(manifest, restoreDir)
+			Expect(err).To(MatchError(ContainSubstring("through symlink")))
+			Expect(filepath.Join(outside, "file")).ToNot(BeAnExistingFile())
+		})
+
+		It("replaces symlinks instead of writing to their targets", func() {
+			outside := filepath.Join(GinkgoT().TempDir(), "outside")
+			Expect(os.WriteFile(outside, []byte("fake-outside"), 0644)).To(Succeed())
+			Expect(os.Symlink(outside, filepath.Join(restoreDir, "file"))).To(Succeed())
+
+			manifest := ChunkManifest{Files: []ChunkedFile{{Path: "file", Mode: 0644}}}
+			Expect(chunkStore.Restore(manifest, restoreDir)).To(Succeed())
+
+			content, err := os.ReadFile(outside)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal("fake-outside"))
+			Expect(filepath.Join(restoreDir, "file")).To(BeARegularFile())
+		})
+	})
+})
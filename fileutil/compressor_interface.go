@@ -1,9 +1,34 @@
 package fileutil
 
+import (
+	"os"
+	"time"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+)
+
 type CompressorOptions struct {
 	SameOwner       bool
 	PathInArchive   string
 	StripComponents int
+
+	// Dedup replaces identical extracted files with hardlinks when set
+	Dedup *DedupOptions
+}
+
+// ArchiveEntry describes a tarball member as recorded in its header
+type ArchiveEntry struct {
+	Path     string
+	Size     int64
+	Mode     os.FileMode
+	UID      int
+	GID      int
+	Linkname string
+	ModTime  time.Time
+
+	// Digest is set for job and package tarballs listed in a release.MF
+	// at the root of the archive
+	Digest boshcrypto.Digest
 }
 
 type Compressor interface {
@@ -14,6 +39,9 @@ type Compressor interface {
 
 	DecompressFileToDir(path string, dir string, options CompressorOptions) (err error)
 
+	// List reads the entries of a tarball without extracting it
+	List(path string) (entries []ArchiveEntry, err error)
+
 	// CleanUp cleans up compressed file after it was used
 	CleanUp(path string) error
 }
@@ -0,0 +1,89 @@
+package fileutil
+
+import (
+	"io"
+	"os"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// CopyWithDigest copies src to dst and computes the digests of the copied
+// data with every algorithm in the same pass
+func CopyWithDigest(dst io.Writer, src io.Reader, algos ...boshcrypto.Algorithm) (int64, boshcrypto.MultipleDigest, error) {
+	return CopyWithDigestOpts(dst, src, boshsys.CopyOpts{}, algos...)
+}
+
+// CopyWithDigestOpts is CopyWithDigest with progress and cancellation
+func CopyWithDigestOpts(dst io.Writer, src io.Reader, opts boshsys.CopyOpts, algos ...boshcrypto.Algorithm) (int64, boshcrypto.MultipleDigest, error) {
+	if len(algos) == 0 {
+		return 0, boshcrypto.MultipleDigest{}, bosherr.Error("Must provide at least one algorithm")
+	}
+
+	hashes := make([]*boshcrypto.IncrementalDigest, len(algos))
+	writers := []io.Writer{dst}
+
+	for i, algo := range algos {
+		hash, err := boshcrypto.NewIncrementalDigest(algo)
+		if err != nil {
+			return 0, boshcrypto.MultipleDigest{}, err
+		}
+
+		hashes[i] = hash
+		writers = append(writers, hash)
+	}
+
+	written, err := boshsys.CopyStream(io.MultiWriter(writers...), src, opts)
+	if err != nil {
+		return written, boshcrypto.MultipleDigest{}, err
+	}
+
+	digests := make([]boshcrypto.Digest, len(hashes))
+	for i, hash := range hashes {
+		digests[i] = hash.Digest()
+	}
+
+	return written, boshcrypto.MustNewMultipleDigest(digests...), nil
+}
+
+// FileTee writes everything read through it to a file
+type FileTee struct {
+	src  io.Reader
+	file boshsys.File
+	err  error
+}
+
+// TeeToFile creates or truncates path and returns a reader that copies
+// everything read from src into it. Close has to be called to close the
+// file and reports errors writing it.
+func TeeToFile(fs boshsys.FileSystem, path string, src io.Reader) (*FileTee, error) {
+	file, err := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Creating '%s'", path)
+	}
+
+	return &FileTee{src: src, file: file}, nil
+}
+
+func (t *FileTee) Read(p []byte) (int, error) {
+	n, err := t.src.Read(p)
+	if n > 0 && t.err == nil {
+		_, t.err = t.file.Write(p[:n])
+		if t.err != nil {
+			return n, bosherr.WrapErrorf(t.err, "Writing '%s'", t.file.Name())
+		}
+	}
+	return n, err
+}
+
+func (t *FileTee) Close() error {
+	err := t.file.Close()
+	if t.err != nil {
+		return bosherr.WrapErrorf(t.err, "Writing '%s'", t.file.Name())
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Closing '%s'", t.file.Name())
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package fileutil_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing/iotest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	. "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("CopyWithDigest", func() {
+	content := strings.Repeat("fake-content", 10000)
+
+	It("copies the data and computes digests of it", func() {
+		var dst bytes.Buffer
+
+		written, digest, err := CopyWithDigest(&dst, strings.NewReader(content), boshcrypto.DigestAlgorithmSHA1, boshcrypto.DigestAlgorithmSHA256)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(written).To(Equal(int64(len(content))))
+		Expect(dst.String()).To(Equal(content))
+
+		sha1, err := boshcrypto.DigestAlgorithmSHA1.CreateDigest(strings.NewReader(content))
+		Expect(err).ToNot(HaveOccurred())
+		sha256, err := boshcrypto.DigestAlgorithmSHA256.CreateDigest(strings.NewReader(content))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(digest).To(Equal(boshcrypto.MustNewMultipleDigest(sha1, sha256)))
+	})
+
+	It("requires an algorithm", func() {
+		_, _, err := CopyWithDigest(&bytes.Buffer{}, strings.NewReader(content))
+		Expect(err).To(MatchError(ContainSubstring("at least one algorithm")))
+	})
+
+	It("rejects unknown algorithms", func() {
+		_, _, err := CopyWithDigest(&bytes.Buffer{}, strings.NewReader(content), boshcrypto.NewUnknownAlgorithm("md5"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("passes progress and cancellation to the copy", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, _, err := CopyWithDigestOpts(&bytes.Buffer{}, strings.NewReader(content), boshsys.CopyOpts{Context: ctx}, boshcrypto.DigestAlgorithmSHA1)
+		Expect(err).To(MatchError(context.Canceled))
+	})
+})
+
+var _ = Describe("TeeToFile", func() {
+	var (
+		fs  boshsys.FileSystem
+		dir string
+	)
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+
+		var err error
+		dir, err = os.MkdirTemp("", "tee-to-file")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+	})
+
+	It("writes everything read to the file", func() {
+		content := strings.Repeat("fake-content", 10000)
+		path := filepath.Join(dir, "tee")
+
+		tee, err := TeeToFile(fs, path, iotest.HalfReader(strings.NewReader(content)))
+		Expect(err).ToNot(HaveOccurred())
+
+		var read bytes.Buffer
+		_, err = read.ReadFrom(tee)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tee.Close()).To(Succeed())
+
+		Expect(read.String()).To(Equal(content))
+		Expect(fs.ReadFileString(path)).To(Equal(content))
+	})
+
+	It("returns an error when the file can not be created", func() {
+		_, err := TeeToFile(fs, filepath.Join(dir, "missing", "tee"), strings.NewReader("data"))
+		Expect(err).To(HaveOccurred())
+	})
+})
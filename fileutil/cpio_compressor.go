@@ -0,0 +1,604 @@
+package fileutil
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/fileutil/pathutil"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type CpioOptions struct {
+	// Gzip compresses written archives, as expected for most initramfs images
+	Gzip bool
+
+	// ModTime is recorded for every added member so that archives only
+	// depend on file contents; defaults to the epoch
+	ModTime time.Time
+}
+
+type cpioCompressor struct {
+	fs   boshsys.FileSystem
+	opts CpioOptions
+}
+
+// NewCpioCompressor reads and writes newc cpio archives, optionally gzipped,
+// without shelling out. Members are written sorted by path and owned by root,
+// hardlinks are stored as separate files. Extraction ignores
+// CompressorOptions.SameOwner and does not create device nodes. It only
+// creates symlinks pointing within the destination, after all other
+// members, and never writes through symlinks.
+func NewCpioCompressor(fs boshsys.FileSystem, opts CpioOptions) Compressor {
+	return cpioCompressor{fs: fs, opts: opts}
+}
+
+// cpioMember has either data or the path of a file to read it from
+type cpioMember struct {
+	header     cpioHeader
+	data       []byte
+	sourcePath string
+}
+
+type cpioLinkKey struct {
+	devMajor, devMinor, ino uint32
+}
+
+// cpioExtraction tracks members that can only be created once the files
+// they refer to were extracted
+type cpioExtraction struct {
+	dir          string
+	pendingLinks map[cpioLinkKey][]string
+	symlinks     []cpioSymlink
+}
+
+type cpioSymlink struct {
+	path, target string
+}
+
+func (c cpioCompressor) CompressFilesInDir(dir string) (string, error) {
+	return c.CompressSpecificFilesInDir(dir, []string{"."})
+}
+
+func (c cpioCompressor) CompressSpecificFilesInDir(dir string, files []string) (string, error) {
+	archive, err := c.fs.TempFile("bosh-platform-disk-CpioCompressor-CompressSpecificFilesInDir")
+	if err != nil {
+		return "", bosherr.WrapError(err, "Creating temporary file for cpio archive")
+	}
+
+	archivePath := archive.Name()
+	archive.Close()
+
+	members, err := collectCpioMembers(c.fs, dir, files, c.opts)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeCpioArchive(c.fs, archivePath, members, c.opts.Gzip)
+	if err != nil {
+		return "", err
+	}
+
+	return archivePath, nil
+}
+
+func (c cpioCompressor) DecompressFileToDir(archivePath string, dir string, options CompressorOptions) error {
+	var pathInArchive string
+
+	if options.PathInArchive != "" {
+		if _, err := pathutil.SafeJoin(dir, options.PathInArchive); err != nil {
+			return bosherr.WrapError(err, "Validating path in archive")
+		}
+		pathInArchive = path.Clean(filepath.ToSlash(options.PathInArchive))
+	}
+
+	extraction := &cpioExtraction{dir: dir, pendingLinks: map[cpioLinkKey][]string{}}
+
+	err := c.readArchive(archivePath, func(header cpioHeader, data io.Reader) error {
+		name := cpioMemberName(header.Name)
+		if name == "." {
+			return nil
+		}
+
+		if pathInArchive != "" && pathInArchive != "." && name != pathInArchive && !strings.HasPrefix(name, pathInArchive+"/") {
+			return nil
+		}
+
+		if options.StripComponents > 0 {
+			components := strings.Split(name, "/")
+			if len(components) <= options.StripComponents {
+				return nil
+			}
+			name = strings.Join(components[options.StripComponents:], "/")
+		}
+
+		target, err := pathutil.SafeJoin(dir, name)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Validating member '%s'", header.Name)
+		}
+
+		return c.extractMember(header, data, target, extraction)
+	})
+	if err == nil {
+		err = c.extractSymlinks(extraction)
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Extracting cpio archive '%s'", archivePath)
+	}
+
+	if options.Dedup != nil {
+		_, err = NewDeduplicator(c.fs).Dedup(dir, *options.Dedup)
+		if err != nil {
+			return bosherr.WrapError(err, "Deduplicating extracted files")
+		}
+	}
+
+	return nil
+}
+
+func (c cpioCompressor) List(archivePath string) ([]ArchiveEntry, error) {
+	var entries []ArchiveEntry
+
+	err := c.readArchive(archivePath, func(header cpioHeader, data io.Reader) error {
+		entry := ArchiveEntry{
+			Path:    header.Name,
+			Size:    header.Size,
+			Mode:    header.fileMode(),
+			UID:     int(header.UID),
+			GID:     int(header.GID),
+			ModTime: time.Unix(int64(header.ModTime), 0).UTC(),
+		}
+
+		if header.fileType() == cpioTypeSymlink {
+			linkname, err := io.ReadAll(data)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Reading link of '%s'", header.Name)
+			}
+			entry.Linkname = string(linkname)
+		}
+
+		entries = append(entries, entry)
+
+		return nil
+	})
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing cpio archive '%s'", archivePath)
+	}
+
+	return entries, nil
+}
+
+func (c cpioCompressor) CleanUp(archivePath string) error {
+	return c.fs.RemoveAll(archivePath)
+}
+
+func (c cpioCompressor) readArchive(archivePath string, memberFunc func(cpioHeader, io.Reader) error) error {
+	file, err := c.fs.OpenFile(archivePath, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapError(err, "Opening cpio archive")
+	}
+
+	defer file.Close()
+
+	reader, err := decompressingReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+
+	cpio := newCpioReader(reader)
+
+	for {
+		header, err := cpio.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = memberFunc(header, cpio)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c cpioCompressor) extractMember(header cpioHeader, data io.Reader, target string, extraction *cpioExtraction) error {
+	mode := header.fileMode()
+
+	if header.fileType() == cpioTypeSymlink {
+		linkname, err := io.ReadAll(data)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading link of '%s'", header.Name)
+		}
+
+		// symlinks are created last so that no member is written through them
+		extraction.symlinks = append(extraction.symlinks, cpioSymlink{path: target, target: string(linkname)})
+		return nil
+	}
+
+	err := checkNoSymlinkParents(c.fs, extraction.dir, target)
+	if err == nil {
+		err = removeSymlink(c.fs, target)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch header.fileType() {
+	case cpioTypeDir:
+		err := c.fs.MkdirAll(target, mode.Perm())
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating directory '%s'", target)
+		}
+		return c.fs.Chmod(target, mode.Perm())
+
+	case cpioTypeRegular:
+		err := c.writeMemberFile(target, data, mode.Perm())
+		if err != nil {
+			return err
+		}
+
+		if header.Nlink < 2 {
+			return nil
+		}
+
+		// newc stores the data of hardlinked files with the last link only
+		key := cpioLinkKey{header.DevMajor, header.DevMinor, header.Ino}
+		if header.Size == 0 {
+			extraction.pendingLinks[key] = append(extraction.pendingLinks[key], target)
+			return nil
+		}
+
+		for _, linkPath := range extraction.pendingLinks[key] {
+			err = c.fs.RemoveAll(linkPath)
+			if err == nil {
+				err = c.fs.Hardlink(target, linkPath)
+			}
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Linking '%s' to '%s'", linkPath, target)
+			}
+		}
+		delete(extraction.pendingLinks, key)
+
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// extractSymlinks only creates symlinks pointing within the destination.
+// Links below other links of the archive are refused.
+func (c cpioCompressor) extractSymlinks(extraction *cpioExtraction) error {
+	for _, symlink := range extraction.symlinks {
+		target, err := safeLinkTarget(extraction.dir, symlink.path, symlink.target)
+		if err != nil {
+			return err
+		}
+
+		err = checkNoSymlinkParents(c.fs, extraction.dir, symlink.path)
+		if err != nil {
+			return err
+		}
+
+		err = c.fs.MkdirAll(filepath.Dir(symlink.path), os.FileMode(0755))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating parent of '%s'", symlink.path)
+		}
+
+		err = c.fs.Symlink(target, symlink.path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating symlink '%s'", symlink.path)
+		}
+	}
+
+	return nil
+}
+
+func (c cpioCompressor) writeMemberFile(target string, data io.Reader, perm os.FileMode) error {
+	err := c.fs.MkdirAll(filepath.Dir(target), os.FileMode(0755))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating parent of '%s'", target)
+	}
+
+	file, err := c.fs.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating '%s'", target)
+	}
+
+	_, err = boshsys.CopyStream(file, data, boshsys.CopyOpts{})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing '%s'", target)
+	}
+
+	return c.fs.Chmod(target, perm)
+}
+
+// AppendFilesToCpio adds files below dir to the archive at archivePath,
+// replacing members with the same path, and rewrites it in place. Existing
+// members keep their order and new members follow sorted by path, so
+// appending the same files always produces the same archive. The archive
+// stays gzipped if it was.
+func AppendFilesToCpio(fs boshsys.FileSystem, archivePath, dir string, files []string, opts CpioOptions) error {
+	members, gzipped, err := readCpioMembers(fs, archivePath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading cpio archive '%s'", archivePath)
+	}
+
+	added, err := collectCpioMembers(fs, dir, files, opts)
+	if err != nil {
+		return err
+	}
+
+	index := map[string]int{}
+	for i, member := range members {
+		index[cpioMemberName(member.header.Name)] = i
+	}
+
+	for _, member := range added {
+		if i, found := index[member.header.Name]; found {
+			members[i] = member
+			continue
+		}
+		members = append(members, member)
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(archivePath), "."+filepath.Base(archivePath)+".tmp")
+
+	err = writeCpioArchive(fs, tmpPath, members, gzipped || opts.Gzip)
+	if err != nil {
+		fs.RemoveAll(tmpPath)
+		return err
+	}
+
+	err = fs.Rename(tmpPath, archivePath)
+	if err != nil {
+		fs.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Replacing cpio archive '%s'", archivePath)
+	}
+
+	return nil
+}
+
+// readCpioMembers loads all members into memory and gives every
+// hardlinked file its own copy of the data
+func readCpioMembers(fs boshsys.FileSystem, archivePath string) ([]cpioMember, bool, error) {
+	file, err := fs.OpenFile(archivePath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer file.Close()
+
+	buffered := bufio.NewReader(file)
+
+	magic, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	gzipped := len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+
+	reader, err := decompressingReader(buffered)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var members []cpioMember
+	linkData := map[cpioLinkKey][]byte{}
+
+	cpio := newCpioReader(reader)
+
+	for {
+		header, err := cpio.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		data, err := io.ReadAll(cpio)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if header.fileType() == cpioTypeRegular && header.Nlink > 1 && header.Size > 0 {
+			linkData[cpioLinkKey{header.DevMajor, header.DevMinor, header.Ino}] = data
+		}
+
+		members = append(members, cpioMember{header: header, data: data})
+	}
+
+	for i, member := range members {
+		header := member.header
+		if header.fileType() != cpioTypeRegular || header.Nlink < 2 {
+			continue
+		}
+
+		data := linkData[cpioLinkKey{header.DevMajor, header.DevMinor, header.Ino}]
+		members[i].data = data
+		members[i].header.Size = int64(len(data))
+		members[i].header.Nlink = 1
+	}
+
+	return members, gzipped, nil
+}
+
+func collectCpioMembers(fs boshsys.FileSystem, dir string, files []string, opts CpioOptions) ([]cpioMember, error) {
+	var modTime uint32
+	if !opts.ModTime.IsZero() && opts.ModTime.Unix() > 0 {
+		modTime = uint32(opts.ModTime.Unix())
+	}
+
+	membersByName := map[string]cpioMember{}
+
+	for _, file := range files {
+		root, err := pathutil.SafeJoin(dir, file)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Validating file '%s' to archive", file)
+		}
+
+		err = fs.Walk(root, func(walkPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(dir, walkPath)
+			if err != nil {
+				return err
+			}
+
+			name := filepath.ToSlash(rel)
+			if name == "." {
+				return nil
+			}
+
+			member, err := newCpioMember(fs, walkPath, name, info, modTime)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Adding '%s' to cpio archive", walkPath)
+			}
+
+			membersByName[name] = member
+
+			return nil
+		})
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Collecting files in '%s'", root)
+		}
+	}
+
+	members := make([]cpioMember, 0, len(membersByName))
+	for _, member := range membersByName {
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].header.Name < members[j].header.Name })
+
+	return members, nil
+}
+
+func newCpioMember(fs boshsys.FileSystem, filePath, name string, info os.FileInfo, modTime uint32) (cpioMember, error) {
+	member := cpioMember{
+		header: cpioHeader{
+			Name:    name,
+			Mode:    cpioModeOf(info.Mode()),
+			Nlink:   1,
+			ModTime: modTime,
+		},
+	}
+
+	switch {
+	case info.IsDir():
+		member.header.Nlink = 2
+
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := fs.Readlink(filePath)
+		if err != nil {
+			return cpioMember{}, err
+		}
+		member.data = []byte(target)
+		member.header.Size = int64(len(member.data))
+
+	case info.Mode().IsRegular():
+		member.sourcePath = filePath
+		member.header.Size = info.Size()
+
+	default:
+		return cpioMember{}, bosherr.Errorf("Unsupported file type '%s'", info.Mode().Type())
+	}
+
+	return member, nil
+}
+
+func writeCpioArchive(fs boshsys.FileSystem, archivePath string, members []cpioMember, gzipped bool) error {
+	file, err := fs.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating cpio archive '%s'", archivePath)
+	}
+
+	err = writeCpioMembers(fs, file, members, gzipped)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing cpio archive '%s'", archivePath)
+	}
+
+	return nil
+}
+
+func writeCpioMembers(fs boshsys.FileSystem, writer io.Writer, members []cpioMember, gzipped bool) error {
+	var gzipWriter *gzip.Writer
+	if gzipped {
+		gzipWriter = gzip.NewWriter(writer)
+		writer = gzipWriter
+	}
+
+	buffered := bufio.NewWriter(writer)
+	cpio := newCpioWriter(buffered)
+
+	for _, member := range members {
+		err := cpio.WriteHeader(member.header)
+		if err != nil {
+			return err
+		}
+
+		if member.sourcePath == "" {
+			_, err = cpio.Write(member.data)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = copyCpioSource(fs, cpio, member)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := cpio.Close()
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err == nil && gzipWriter != nil {
+		err = gzipWriter.Close()
+	}
+
+	return err
+}
+
+func copyCpioSource(fs boshsys.FileSystem, cpio *cpioWriter, member cpioMember) error {
+	src, err := fs.OpenFile(member.sourcePath, os.O_RDONLY, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening '%s'", member.sourcePath)
+	}
+
+	defer src.Close()
+
+	n, err := boshsys.CopyStream(cpio, io.LimitReader(src, member.header.Size), boshsys.CopyOpts{})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying '%s'", member.sourcePath)
+	}
+
+	if n != member.header.Size {
+		return bosherr.Errorf("File '%s' changed while archiving", member.sourcePath)
+	}
+
+	return nil
+}
+
+func cpioMemberName(name string) string {
+	return path.Clean(strings.TrimPrefix(name, "./"))
+}
@@ -0,0 +1,337 @@
+package fileutil_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// newcMember builds a raw newc member the way other cpio implementations write them
+func newcMember(ino, mode, nlink int, name, data string) string {
+	pad := func(n int) string { return string(make([]byte, (4-n%4)%4)) }
+
+	header := fmt.Sprintf("070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+		ino, mode, 0, 0, nlink, 0, len(data), 0, 0, 0, 0, len(name)+1, 0)
+
+	return header + name + "\x00" + pad(len(header)+len(name)+1) + data + pad(len(data))
+}
+
+var _ = Describe("cpioCompressor", func() {
+	var (
+		fs         boshsys.FileSystem
+		compressor Compressor
+		srcDir     string
+		dstDir     string
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip("initramfs images are only built on unix")
+		}
+
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		compressor = NewCpioCompressor(fs, CpioOptions{})
+
+		srcDir = GinkgoT().TempDir()
+		dstDir = GinkgoT().TempDir()
+
+		Expect(os.MkdirAll(filepath.Join(srcDir, "bin"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(srcDir, "init"), []byte("#!/bin/sh\nexec /bin/busybox sh\n"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(srcDir, "bin", "busybox"), []byte("fake-busybox"), 0700)).To(Succeed())
+		Expect(os.Symlink("busybox", filepath.Join(srcDir, "bin", "sh"))).To(Succeed())
+	})
+
+	It("round trips files, directories and symlinks", func() {
+		archivePath, err := compressor.CompressFilesInDir(srcDir)
+		Expect(err).ToNot(HaveOccurred())
+		defer compressor.CleanUp(archivePath)
+
+		Expect(compressor.DecompressFileToDir(archivePath, dstDir, CompressorOptions{})).To(Succeed())
+
+		contents, err := os.ReadFile(filepath.Join(dstDir, "init"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("#!/bin/sh\nexec /bin/busybox sh\n"))
+
+		info, err := os.Stat(filepath.Join(dstDir, "bin", "busybox"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+
+		target, err := os.Readlink(filepath.Join(dstDir, "bin", "sh"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(target).To(Equal("busybox"))
+	})
+
+	It("lists members sorted by path and owned by root", func() {
+		archivePath, err := compressor.CompressSpecificFilesInDir(srcDir, []string{"bin", "init"})
+		Expect(err).ToNot(HaveOccurred())
+		defer compressor.CleanUp(archivePath)
+
+		entries, err := compressor.List(archivePath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(Equal([]ArchiveEntry{
+			{Path: "bin", Mode: os.ModeDir | 0755, ModTime: time.Unix(0, 0).UTC()},
+			{Path: "bin/busybox", Size: 12, Mode: 0700, ModTime: time.Unix(0, 0).UTC()},
+			{Path: "bin/sh", Size: 7, Mode: os.ModeSymlink | 0777, Linkname: "busybox", ModTime: time.Unix(0, 0).UTC()},
+			{Path: "init", Size: 31, Mode: 0755, ModTime: time.Unix(0, 0).UTC()},
+		}))
+	})
+
+	It("writes the same archive for the same files regardless of their timestamps", func() {
+		firstPath, err := compressor.CompressFilesInDir(srcDir)
+		Expect(err).ToNot(HaveOccurred())
+		defer compressor.CleanUp(firstPath)
+
+		later := time.Now().Add(time.Hour)
+		Expect(os.Chtimes(filepath.Join(srcDir, "init"), later, later)).To(Succeed())
+
+		secondPath, err := compressor.CompressFilesInDir(srcDir)
+		Expect(err).ToNot(HaveOccurred())
+		defer compressor.CleanUp(secondPath)
+
+		first, err := os.ReadFile(firstPath)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := os.ReadFile(secondPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first).To(Equal(second))
+		Expect(string(first[:6])).To(Equal("070701"))
+	})
+
+	It("gzips archives when asked to", func() {
+		compressor = NewCpioCompressor(fs, CpioOptions{Gzip: true})
+
+		archivePath, err := compressor.CompressFilesInDir(srcDir)
+		Expect(err).ToNot(HaveOccurred())
+		defer compressor.CleanUp(archivePath)
+
+		contents, err := os.ReadFile(archivePath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contents[:2]).To(Equal([]byte{0x1f, 0x8b}))
+
+		Expect(compressor.DecompressFileToDir(archivePath, dstDir, CompressorOptions{})).To(Succeed())
+		Expect(filepath.Join(dstDir, "bin", "busybox")).To(BeAnExistingFile())
+	})
+
+	It("extracts only the path in archive and strips components", func() {
+		archivePath, err := compressor.CompressFilesInDir(srcDir)
+		Expect(err).ToNot(HaveOccurred())
+		defer compressor.CleanUp(archivePath)
+
+		err = compressor.DecompressFileToDir(archivePath, dstDir, CompressorOptions{PathInArchive: "bin", StripComponents: 1})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(filepath.Join(dstDir, "busybox")).To(BeAnExistingFile())
+		Expect(filepath.Join(dstDir, "init")).ToNot(BeAnExistingFile())
+	})
+
+	It("extracts archives written by other tools, including hardlinks", func() {
+		archive := newcMember(1, 040755, 2, ".", "") +
+			newcMember(2, 040755, 2, "sbin", "") +
+			newcMember(3, 0100755, 2, "sbin/modprobe", "") +
+			newcMember(3, 0100755, 2, "sbin/kmod", "fake-kmod") +
+			newcMember(0, 0, 1, "TRAILER!!!", "")
+
+		archivePath := filepath.Join(srcDir, "initrd.img")
+		Expect(os.WriteFile(archivePath, []byte(archive), 0644)).To(Succeed())
+
+		Expect(compressor.DecompressFileToDir(archivePath, dstDir, CompressorOptions{})).To(Succeed())
+
+		contents, err := os.ReadFile(filepath.Join(dstDir, "sbin", "modprobe"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("fake-kmod"))
+
+		kmod, err := os.Stat(filepath.Join(dstDir, "sbin", "kmod"))
+		Expect(err).ToNot(HaveOccurred())
+		modprobe, err := os.Stat(filepath.Join(dstDir, "sbin", "modprobe"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.SameFile(kmod, modprobe)).To(BeTrue())
+	})
+
+	It("rejects members escaping the destination", func() {
+		archive := newcMember(1, 0100644, 1, "../escaped", "x") + newcMember(0, 0, 1, "TRAILER!!!", "")
+
+		archivePath := filepath.Join(srcDir, "evil.cpio")
+		Expect(os.WriteFile(archivePath, []byte(archive), 0644)).To(Succeed())
+
+		err := compressor.DecompressFileToDir(archivePath, dstDir, CompressorOptions{})
+		Expect(err).To(HaveOccurred())
+		Expect(filepath.Join(filepath.Dir(dstDir), "escaped")).ToNot(BeAnExistingFile())
+	})
+
+	Describe("crafted archives", func() {
+		var (
+			outsideDir string
+			extract    func(members ...string) error
+		)
+
+		BeforeEach(func() {
+			outsideDir = GinkgoT().TempDir()
+
+			extract = func(members ...string) error {
+				archive := ""
+				for _, member := range members {
+					archive += member
+				}
+				archive += newcMember(0, 0, 1, "TRAILER!!!", "")
+
+				archivePath := filepath.Join(srcDir, "crafted.cpio")
+				Expect(os.WriteFile(archivePath, []byte(archive), 0644)).To(Succeed())
+
+				return compressor.DecompressFileToDir(archivePath, dstDir, CompressorOptions{})
+			}
+		})
+
+		It("rejects symlinks pointing outside of the destination", func() {
+			for _, target := range []string{filepath.Join(outsideDir, "file"), "../../outside", "../sub/../../outside", "a/../../../outside"} {
+				err := extract(newcMember(1, 0120777, 1, "sub/link", target))
+				Expect(err).To(MatchError(ContainSubstring("Link target")), target)
+
+				_, err = os.Lstat(filepath.Join(dstDir, "sub", "link"))
+				Expect(os.IsNotExist(err)).To(BeTrue(), target)
+			}
+		})
+
+		It("creates symlinks within the destination with cleaned targets", func() {
+			err := extract(
+				newcMember(1, 0120777, 1, "bin/sh", "../bin/./busybox"),
+				newcMember(2, 0100755, 1, "bin/busybox", "fake-busybox"),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			target, err := os.Readlink(filepath.Join(dstDir, "bin", "sh"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(target).To(Equal("../bin/busybox"))
+		})
+
+		It("does not write files through symlinks of the archive", func() {
+			err := extract(
+				newcMember(1, 0120777, 1, "link", outsideDir),
+				newcMember(2, 0100644, 1, "link/file", "escaped"),
+			)
+			Expect(err).To(HaveOccurred())
+
+			Expect(filepath.Join(outsideDir, "file")).ToNot(BeAnExistingFile())
+		})
+
+		It("does not write files through symlinks already in the destination", func() {
+			Expect(os.Symlink(outsideDir, filepath.Join(dstDir, "link"))).To(Succeed())
+
+			err := extract(newcMember(1, 0100644, 1, "link/file", "escaped"))
+			Expect(err).To(MatchError(ContainSubstring("through symlink")))
+
+			Expect(filepath.Join(outsideDir, "file")).ToNot(BeAnExistingFile())
+		})
+
+		It("replaces symlinks in the destination instead of writing to their target", func() {
+			victim := filepath.Join(outsideDir, "victim")
+			Expect(os.WriteFile(victim, []byte("original"), 0644)).To(Succeed())
+			Expect(os.Symlink(victim, filepath.Join(dstDir, "file"))).To(Succeed())
+
+			Expect(extract(newcMember(1, 0100644, 1, "file", "replaced"))).To(Succeed())
+
+			contents, err := os.ReadFile(victim)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("original"))
+
+			info, err := os.Lstat(filepath.Join(dstDir, "file"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().IsRegular()).To(BeTrue())
+		})
+	})
+
+	Describe("AppendFilesToCpio", func() {
+		var (
+			archivePath string
+			extraDir    string
+		)
+
+		BeforeEach(func() {
+			archive := newcMember(1, 0100755, 1, "init", "old-init") +
+				newcMember(2, 040755, 2, "etc", "") +
+				newcMember(3, 0100755, 2, "kmod", "") +
+				newcMember(3, 0100755, 2, "modprobe", "fake-kmod") +
+				newcMember(0, 0, 1, "TRAILER!!!", "")
+
+			archivePath = filepath.Join(srcDir, "initrd.img")
+			Expect(os.WriteFile(archivePath, []byte(archive), 0644)).To(Succeed())
+
+			extraDir = GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(extraDir, "etc"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(extraDir, "init"), []byte("new-init"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(extraDir, "etc", "fstab"), []byte("fake-fstab"), 0644)).To(Succeed())
+		})
+
+		It("replaces existing members in place and appends new ones", func() {
+			Expect(AppendFilesToCpio(fs, archivePath, extraDir, []string{"init", "etc/fstab"}, CpioOptions{})).To(Succeed())
+
+			entries, err := compressor.List(archivePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			var paths []string
+			for _, entry := range entries {
+				paths = append(paths, entry.Path)
+			}
+			Expect(paths).To(Equal([]string{"init", "etc", "kmod", "modprobe", "etc/fstab"}))
+
+			Expect(compressor.DecompressFileToDir(archivePath, dstDir, CompressorOptions{})).To(Succeed())
+
+			contents, err := os.ReadFile(filepath.Join(dstDir, "init"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("new-init"))
+
+			contents, err = os.ReadFile(filepath.Join(dstDir, "kmod"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("fake-kmod"))
+
+			contents, err = os.ReadFile(filepath.Join(dstDir, "etc", "fstab"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("fake-fstab"))
+		})
+
+		It("produces the same archive when appending the same files", func() {
+			copyPath := filepath.Join(srcDir, "initrd-copy.img")
+			original, err := os.ReadFile(archivePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.WriteFile(copyPath, original, 0644)).To(Succeed())
+
+			Expect(AppendFilesToCpio(fs, archivePath, extraDir, []string{"."}, CpioOptions{})).To(Succeed())
+
+			later := time.Now().Add(time.Hour)
+			Expect(os.Chtimes(filepath.Join(extraDir, "init"), later, later)).To(Succeed())
+			Expect(AppendFilesToCpio(fs, copyPath, extraDir, []string{"."}, CpioOptions{})).To(Succeed())
+
+			first, err := os.ReadFile(archivePath)
+			Expect(err).ToNot(HaveOccurred())
+			second, err := os.ReadFile(copyPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bytes.Equal(first, second)).To(BeTrue())
+		})
+
+		It("keeps gzipped archives gzipped", func() {
+			gzipped := NewCpioCompressor(fs, CpioOptions{Gzip: true})
+
+			gzippedPath, err := gzipped.CompressFilesInDir(srcDir)
+			Expect(err).ToNot(HaveOccurred())
+			defer gzipped.CleanUp(gzippedPath)
+
+			Expect(AppendFilesToCpio(fs, gzippedPath, extraDir, []string{"etc"}, CpioOptions{})).To(Succeed())
+
+			contents, err := os.ReadFile(gzippedPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents[:2]).To(Equal([]byte{0x1f, 0x8b}))
+
+			entries, err := compressor.List(gzippedPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries[len(entries)-1].Path).To(Equal("etc/fstab"))
+		})
+	})
+})
@@ -0,0 +1,278 @@
+package fileutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	cpioNewcMagic    = "070701"
+	cpioNewcCRCMagic = "070702"
+	cpioHeaderSize   = 110
+	cpioTrailerName  = "TRAILER!!!"
+
+	cpioTypeMask    = 0170000
+	cpioTypeFifo    = 0010000
+	cpioTypeChar    = 0020000
+	cpioTypeDir     = 0040000
+	cpioTypeBlock   = 0060000
+	cpioTypeRegular = 0100000
+	cpioTypeSymlink = 0120000
+	cpioTypeSocket  = 0140000
+)
+
+// cpioHeader is a member of a newc ("070701") archive as used for initramfs images
+type cpioHeader struct {
+	Name      string
+	Ino       uint32
+	Mode      uint32
+	UID       uint32
+	GID       uint32
+	Nlink     uint32
+	ModTime   uint32
+	Size      int64
+	DevMajor  uint32
+	DevMinor  uint32
+	RdevMajor uint32
+	RdevMinor uint32
+}
+
+func (h cpioHeader) fileType() uint32 {
+	return h.Mode & cpioTypeMask
+}
+
+func (h cpioHeader) fileMode() os.FileMode {
+	mode := os.FileMode(h.Mode & 0777)
+	if h.Mode&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if h.Mode&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if h.Mode&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+
+	switch h.fileType() {
+	case cpioTypeDir:
+		mode |= os.ModeDir
+	case cpioTypeSymlink:
+		mode |= os.ModeSymlink
+	case cpioTypeChar:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case cpioTypeBlock:
+		mode |= os.ModeDevice
+	case cpioTypeFifo:
+		mode |= os.ModeNamedPipe
+	case cpioTypeSocket:
+		mode |= os.ModeSocket
+	}
+
+	return mode
+}
+
+func cpioModeOf(mode os.FileMode) uint32 {
+	cpioMode := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		cpioMode |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		cpioMode |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		cpioMode |= 01000
+	}
+
+	switch {
+	case mode.IsDir():
+		cpioMode |= cpioTypeDir
+	case mode&os.ModeSymlink != 0:
+		cpioMode |= cpioTypeSymlink
+	case mode&os.ModeCharDevice != 0:
+		cpioMode |= cpioTypeChar
+	case mode&os.ModeDevice != 0:
+		cpioMode |= cpioTypeBlock
+	case mode&os.ModeNamedPipe != 0:
+		cpioMode |= cpioTypeFifo
+	case mode&os.ModeSocket != 0:
+		cpioMode |= cpioTypeSocket
+	default:
+		cpioMode |= cpioTypeRegular
+	}
+
+	return cpioMode
+}
+
+func cpioPadding(n int64) int64 {
+	return (4 - n%4) % 4
+}
+
+// cpioReader reads newc archives; Read returns the data of the current member
+type cpioReader struct {
+	reader    io.Reader
+	remaining int64
+	padding   int64
+}
+
+func newCpioReader(reader io.Reader) *cpioReader {
+	return &cpioReader{reader: reader}
+}
+
+// Next skips the rest of the current member and returns io.EOF at the trailer
+func (r *cpioReader) Next() (cpioHeader, error) {
+	_, err := io.CopyN(io.Discard, r.reader, r.remaining+r.padding)
+	if err != nil {
+		return cpioHeader{}, bosherr.WrapError(err, "Skipping cpio member data")
+	}
+
+	raw := make([]byte, cpioHeaderSize)
+
+	_, err = io.ReadFull(r.reader, raw)
+	if err != nil {
+		return cpioHeader{}, bosherr.WrapError(err, "Reading cpio header")
+	}
+
+	magic := string(raw[:6])
+	if magic != cpioNewcMagic && magic != cpioNewcCRCMagic {
+		return cpioHeader{}, bosherr.Errorf("Unsupported cpio format '%s', only newc archives are supported", magic)
+	}
+
+	var fields [13]uint32
+
+	for i := range fields {
+		value, err := strconv.ParseUint(string(raw[6+i*8:14+i*8]), 16, 32)
+		if err != nil {
+			return cpioHeader{}, bosherr.WrapError(err, "Parsing cpio header")
+		}
+		fields[i] = uint32(value)
+	}
+
+	nameSize := int64(fields[11])
+	if nameSize == 0 {
+		return cpioHeader{}, bosherr.Error("Parsing cpio header: empty name")
+	}
+
+	name := make([]byte, nameSize+cpioPadding(cpioHeaderSize+nameSize))
+
+	_, err = io.ReadFull(r.reader, name)
+	if err != nil {
+		return cpioHeader{}, bosherr.WrapError(err, "Reading cpio member name")
+	}
+
+	header := cpioHeader{
+		Name:      string(bytes.TrimRight(name[:nameSize], "\x00")),
+		Ino:       fields[0],
+		Mode:      fields[1],
+		UID:       fields[2],
+		GID:       fields[3],
+		Nlink:     fields[4],
+		ModTime:   fields[5],
+		Size:      int64(fields[6]),
+		DevMajor:  fields[7],
+		DevMinor:  fields[8],
+		RdevMajor: fields[9],
+		RdevMinor: fields[10],
+	}
+
+	if header.Name == cpioTrailerName {
+		r.remaining, r.padding = 0, 0
+		return cpioHeader{}, io.EOF
+	}
+
+	r.remaining = header.Size
+	r.padding = cpioPadding(header.Size)
+
+	return header, nil
+}
+
+func (r *cpioReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+
+	if err == io.EOF && r.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// cpioWriter numbers inodes in the order members are written so that
+// the same members always produce the same archive
+type cpioWriter struct {
+	writer    io.Writer
+	ino       uint32
+	remaining int64
+	padding   int64
+}
+
+func newCpioWriter(writer io.Writer) *cpioWriter {
+	return &cpioWriter{writer: writer}
+}
+
+func (w *cpioWriter) WriteHeader(header cpioHeader) error {
+	if w.remaining > 0 {
+		return bosherr.Errorf("Missing %d bytes of cpio member data", w.remaining)
+	}
+
+	if w.padding > 0 {
+		_, err := w.writer.Write(make([]byte, w.padding))
+		if err != nil {
+			return err
+		}
+	}
+
+	if header.Name != cpioTrailerName {
+		w.ino++
+		header.Ino = w.ino
+	}
+
+	nameSize := int64(len(header.Name) + 1)
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%s%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+		cpioNewcMagic, header.Ino, header.Mode, header.UID, header.GID, header.Nlink, header.ModTime,
+		header.Size, header.DevMajor, header.DevMinor, header.RdevMajor, header.RdevMinor, nameSize, 0)
+
+	buf.WriteString(header.Name)
+	buf.Write(make([]byte, 1+cpioPadding(cpioHeaderSize+nameSize)))
+
+	_, err := w.writer.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	w.remaining = header.Size
+	w.padding = cpioPadding(header.Size)
+
+	return nil
+}
+
+func (w *cpioWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.remaining {
+		return 0, bosherr.Errorf("Writing %d bytes exceeds cpio member size", len(p))
+	}
+
+	n, err := w.writer.Write(p)
+	w.remaining -= int64(n)
+
+	return n, err
+}
+
+// Close writes the trailer but does not close the underlying writer
+func (w *cpioWriter) Close() error {
+	return w.WriteHeader(cpioHeader{Name: cpioTrailerName, Nlink: 1})
+}
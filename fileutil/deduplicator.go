@@ -0,0 +1,244 @@
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// DedupOptions configures replacing identical files with hardlinks.
+// Duplicates recorded as link entries in a tarball are already
+// extracted as hardlinks by tar; dedup additionally links files
+// whose contents, mode and ownership match.
+type DedupOptions struct {
+	// CacheDir is a content addressed store shared across extractions.
+	// Files are linked to matching cache entries and missing entries are added.
+	// It implies ReadOnly since writing to a linked file would change the
+	// cache and every extraction linked to it.
+	CacheDir string
+
+	// ReadOnly removes write permissions from deduplicated files so that
+	// writers have to call BreakHardlink before modifying them
+	ReadOnly bool
+}
+
+type DedupResult struct {
+	Files      int
+	Linked     int
+	BytesSaved int64
+}
+
+type Deduplicator struct {
+	fs boshsys.FileSystem
+}
+
+func NewDeduplicator(fs boshsys.FileSystem) Deduplicator {
+	return Deduplicator{fs: fs}
+}
+
+type dedupCandidate struct {
+	path string
+	stat boshsys.FileStat
+}
+
+func (d Deduplicator) Dedup(dir string, options DedupOptions) (DedupResult, error) {
+	var result DedupResult
+
+	if options.CacheDir != "" {
+		options.ReadOnly = true
+
+		err := d.fs.MkdirAll(options.CacheDir, os.FileMode(0700))
+		if err != nil {
+			return result, bosherr.WrapErrorf(err, "Creating dedup cache dir '%s'", options.CacheDir)
+		}
+	}
+
+	var candidates []dedupCandidate
+
+	err := d.fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || info.Mode()&os.ModeType != 0 || info.Size() == 0 {
+			return nil
+		}
+
+		stat, err := d.fs.Lstatx(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Getting stats of '%s'", path)
+		}
+
+		candidates = append(candidates, dedupCandidate{path: path, stat: stat})
+
+		return nil
+	})
+	if err != nil {
+		return result, bosherr.WrapErrorf(err, "Walking '%s'", dir)
+	}
+
+	canonicals := map[string]dedupCandidate{}
+
+	for _, candidate := range candidates {
+		result.Files++
+
+		key, err := d.dedupKey(candidate)
+		if err != nil {
+			return result, err
+		}
+
+		canonical, found := canonicals[key]
+		if !found && options.CacheDir != "" {
+			canonical, found, err = d.cacheEntry(filepath.Join(options.CacheDir, key), candidate)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		if !found {
+			if options.ReadOnly {
+				err = d.removeWritePermissions(candidate)
+				if err != nil {
+					return result, err
+				}
+			}
+
+			canonicals[key] = candidate
+			continue
+		}
+
+		canonicals[key] = canonical
+
+		if sameFile(canonical.stat, candidate.stat) {
+			continue
+		}
+
+		err = d.replaceWithHardlink(canonical.path, candidate.path)
+		if err != nil {
+			return result, err
+		}
+
+		result.Linked++
+		result.BytesSaved += candidate.stat.Size()
+	}
+
+	return result, nil
+}
+
+// BreakHardlink gives path its own copy of the content so that
+// it can be modified without affecting other links to the same file
+func (d Deduplicator) BreakHardlink(path string) error {
+	stat, err := d.fs.Lstatx(path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting stats of '%s'", path)
+	}
+
+	if stat.Nlink == 1 {
+		return d.fs.Chmod(path, stat.Mode().Perm()|0200)
+	}
+
+	tmpPath := dedupTempPath(path, "copy")
+
+	err = d.fs.CopyFile(path, tmpPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying '%s'", path)
+	}
+
+	err = d.fs.Chmod(tmpPath, stat.Mode().Perm()|0200)
+	if err != nil {
+		_ = d.fs.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Making '%s' writable", tmpPath)
+	}
+
+	err = d.fs.Rename(tmpPath, path)
+	if err != nil {
+		_ = d.fs.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Replacing '%s' with its copy", path)
+	}
+
+	return nil
+}
+
+func (d Deduplicator) dedupKey(candidate dedupCandidate) (string, error) {
+	file, err := d.fs.OpenFile(candidate.path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Opening '%s'", candidate.path)
+	}
+
+	defer file.Close()
+
+	digest := sha256.New()
+
+	_, err = io.Copy(digest, file)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Hashing '%s'", candidate.path)
+	}
+
+	return fmt.Sprintf("%s-%o-%d-%d",
+		hex.EncodeToString(digest.Sum(nil)), candidate.stat.Mode().Perm(), candidate.stat.UID, candidate.stat.GID), nil
+}
+
+func (d Deduplicator) cacheEntry(cachePath string, candidate dedupCandidate) (dedupCandidate, bool, error) {
+	if d.fs.FileExists(cachePath) {
+		stat, err := d.fs.Lstatx(cachePath)
+		if err != nil {
+			return dedupCandidate{}, false, bosherr.WrapErrorf(err, "Getting stats of '%s'", cachePath)
+		}
+
+		return dedupCandidate{path: cachePath, stat: stat}, true, nil
+	}
+
+	// populating the cache links the cache entry to the candidate
+	// which then stays the canonical copy for this extraction
+	err := d.fs.Hardlink(candidate.path, cachePath)
+	if err != nil {
+		return dedupCandidate{}, false, bosherr.WrapErrorf(err, "Adding '%s' to dedup cache", candidate.path)
+	}
+
+	return dedupCandidate{}, false, nil
+}
+
+func (d Deduplicator) removeWritePermissions(candidate dedupCandidate) error {
+	err := d.fs.Chmod(candidate.path, candidate.stat.Mode().Perm()&^0222)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing write permissions from '%s'", candidate.path)
+	}
+
+	return nil
+}
+
+// replaceWithHardlink links to a temp path first and renames it over path
+// so that path always exists, either with its old content or as the link
+func (d Deduplicator) replaceWithHardlink(canonicalPath, path string) error {
+	tmpPath := dedupTempPath(path, "link")
+
+	err := d.fs.Hardlink(canonicalPath, tmpPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Linking '%s' to '%s'", path, canonicalPath)
+	}
+
+	err = d.fs.Rename(tmpPath, path)
+	if err != nil {
+		_ = d.fs.RemoveAll(tmpPath)
+		return bosherr.WrapErrorf(err, "Replacing '%s' with link to '%s'", path, canonicalPath)
+	}
+
+	return nil
+}
+
+// dedupTempPath is next to path so that renaming it over path is atomic
+// and random so that it does not clash with files of the extraction
+func dedupTempPath(path, purpose string) string {
+	return fmt.Sprintf("%s.dedup-%s-%d", path, purpose, rand.Int63())
+}
+
+func sameFile(a, b boshsys.FileStat) bool {
+	return a.Inode != 0 && a.Inode == b.Inode && a.Device == b.Device
+}
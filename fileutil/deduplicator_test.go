@@ -0,0 +1,175 @@
+package fileutil_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("Deduplicator", func() {
+	var (
+		fs           boshsys.FileSystem
+		dir          string
+		deduplicator Deduplicator
+	)
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		dir = GinkgoT().TempDir()
+		deduplicator = NewDeduplicator(fs)
+
+		Expect(os.MkdirAll(filepath.Join(dir, "a"), 0750)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(dir, "b"), 0750)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "a", "binary"), []byte("fake-binary"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "b", "binary"), []byte("fake-binary"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "b", "other"), []byte("fake-other"), 0755)).To(Succeed())
+	})
+
+	sameFile := func(path1, path2 string) bool {
+		stat1, err := os.Stat(path1)
+		Expect(err).ToNot(HaveOccurred())
+		stat2, err := os.Stat(path2)
+		Expect(err).ToNot(HaveOccurred())
+		return os.SameFile(stat1, stat2)
+	}
+
+	Describe("Dedup", func() {
+		It("replaces identical files with hardlinks", func() {
+			result, err := deduplicator.Dedup(dir, DedupOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(DedupResult{Files: 3, Linked: 1, BytesSaved: int64(len("fake-binary"))}))
+
+			Expect(sameFile(filepath.Join(dir, "a", "binary"), filepath.Join(dir, "b", "binary"))).To(BeTrue())
+			Expect(sameFile(filepath.Join(dir, "a", "binary"), filepath.Join(dir, "b", "other"))).To(BeFalse())
+
+			content, err := os.ReadFile(filepath.Join(dir, "b", "binary"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal("fake-binary"))
+		})
+
+		It("does not link files with different modes", func() {
+			if runtime.GOOS == "windows" {
+				Skip("Windows does not support unix file modes")
+			}
+
+			Expect(os.Chmod(filepath.Join(dir, "b", "binary"), 0644)).To(Succeed())
+
+			result, err := deduplicator.Dedup(dir, DedupOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Linked).To(Equal(0))
+		})
+
+		It("links files to entries of the cache dir across extractions", func() {
+			cacheDir := filepath.Join(GinkgoT().TempDir(), "cache")
+
+			result, err := deduplicator.Dedup(filepath.Join(dir, "a"), DedupOptions{CacheDir: cacheDir})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Linked).To(Equal(0))
+
+			result, err = deduplicator.Dedup(filepath.Join(dir, "b"), DedupOptions{CacheDir: cacheDir})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Linked).To(Equal(1))
+
+			Expect(sameFile(filepath.Join(dir, "a", "binary"), filepath.Join(dir, "b", "binary"))).To(BeTrue())
+
+			entries, err := os.ReadDir(cacheDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+		})
+
+		It("removes write permissions from files linked to the cache dir", func() {
+			if runtime.GOOS == "windows" {
+				Skip("Windows does not support unix file modes")
+			}
+
+			cacheDir := filepath.Join(GinkgoT().TempDir(), "cache")
+
+			_, err := deduplicator.Dedup(filepath.Join(dir, "a"), DedupOptions{CacheDir: cacheDir})
+			Expect(err).ToNot(HaveOccurred())
+
+			stat, err := os.Stat(filepath.Join(dir, "a", "binary"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0555)))
+		})
+
+		It("keeps files named like its temp files", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "b", "binary.dedup-link"), []byte("fake-unrelated"), 0755)).To(Succeed())
+
+			result, err := deduplicator.Dedup(dir, DedupOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Linked).To(Equal(1))
+
+			content, err := os.ReadFile(filepath.Join(dir, "b", "binary.dedup-link"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal("fake-unrelated"))
+
+			entries, err := os.ReadDir(filepath.Join(dir, "b"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(3))
+		})
+
+		It("removes write permissions when ReadOnly is set", func() {
+			if runtime.GOOS == "windows" {
+				Skip("Windows does not support unix file modes")
+			}
+
+			_, err := deduplicator.Dedup(dir, DedupOptions{ReadOnly: true})
+			Expect(err).ToNot(HaveOccurred())
+
+			stat, err := os.Stat(filepath.Join(dir, "b", "binary"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0555)))
+		})
+	})
+
+	Describe("BreakHardlink", func() {
+		It("gives the path its own writable copy", func() {
+			_, err := deduplicator.Dedup(dir, DedupOptions{ReadOnly: true})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = deduplicator.BreakHardlink(filepath.Join(dir, "b", "binary"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(sameFile(filepath.Join(dir, "a", "binary"), filepath.Join(dir, "b", "binary"))).To(BeFalse())
+
+			Expect(os.WriteFile(filepath.Join(dir, "b", "binary"), []byte("fake-patched"), 0755)).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "a", "binary"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal("fake-binary"))
+		})
+	})
+})
+
+var _ = Describe("tarballCompressor with Dedup", func() {
+	It("deduplicates extracted files", func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs := boshsys.NewOsFileSystem(logger)
+		compressor := NewTarballCompressor(boshsys.NewExecCmdRunner(logger), fs)
+
+		srcDir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(srcDir, "one"), []byte("fake-binary"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(srcDir, "two"), []byte("fake-binary"), 0755)).To(Succeed())
+
+		tarball, err := compressor.CompressFilesInDir(srcDir)
+		Expect(err).ToNot(HaveOccurred())
+		defer compressor.CleanUp(tarball)
+
+		dstDir := GinkgoT().TempDir()
+		err = compressor.DecompressFileToDir(tarball, dstDir, CompressorOptions{Dedup: &DedupOptions{}})
+		Expect(err).ToNot(HaveOccurred())
+
+		stat1, err := os.Stat(filepath.Join(dstDir, "one"))
+		Expect(err).ToNot(HaveOccurred())
+		stat2, err := os.Stat(filepath.Join(dstDir, "two"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.SameFile(stat1, stat2)).To(BeTrue())
+	})
+})
@@ -0,0 +1,114 @@
+package fileutil
+
+import (
+	"bytes"
+	"os"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	editFileBackupSuffix = ".bak"
+	editFileTmpSuffix    = ".edit-tmp"
+)
+
+type EditFileOpts struct {
+	// Backup keeps the previous contents in path + ".bak"
+	Backup bool
+
+	// Atomic writes the new contents to a file next to path and renames
+	// it over path, so readers see either the old or the new contents
+	Atomic bool
+}
+
+// EditFile replaces the contents of path with the result of transform and
+// keeps the permissions of the file. Symlinks are followed so that the
+// file they point to is edited. Nothing is written when transform
+// returns the same contents, in which case changed is false.
+func EditFile(fs boshsys.FileSystem, path string, transform func([]byte) ([]byte, error), opts EditFileOpts) (changed bool, err error) {
+	targetPath, err := fs.ReadAndFollowLink(path)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Resolving '%s'", path)
+	}
+
+	stat, err := fs.Stat(targetPath)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Getting stats of '%s'", targetPath)
+	}
+
+	content, err := fs.ReadFile(targetPath)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Reading '%s'", targetPath)
+	}
+
+	newContent, err := transform(content)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Transforming '%s'", targetPath)
+	}
+
+	if bytes.Equal(content, newContent) {
+		return false, nil
+	}
+
+	mode := stat.Mode().Perm()
+
+	if opts.Backup {
+		err = writeFileWithMode(fs, targetPath+editFileBackupSuffix, content, mode)
+		if err != nil {
+			return false, bosherr.WrapErrorf(err, "Backing up '%s'", targetPath)
+		}
+	}
+
+	if !opts.Atomic {
+		err = writeFileWithMode(fs, targetPath, newContent, mode)
+		if err != nil {
+			return false, bosherr.WrapErrorf(err, "Writing '%s'", targetPath)
+		}
+
+		return true, nil
+	}
+
+	tmpPath := targetPath + editFileTmpSuffix
+
+	err = writeFileWithMode(fs, tmpPath, newContent, mode)
+	if err != nil {
+		_ = fs.RemoveAll(tmpPath)
+		return false, bosherr.WrapErrorf(err, "Writing '%s'", tmpPath)
+	}
+
+	err = fs.Rename(tmpPath, targetPath)
+	if err != nil {
+		_ = fs.RemoveAll(tmpPath)
+		return false, bosherr.WrapErrorf(err, "Replacing '%s'", targetPath)
+	}
+
+	return true, nil
+}
+
+// writeFileWithMode syncs the contents before returning so that a rename
+// afterwards can not expose an empty file after a crash
+func writeFileWithMode(fs boshsys.FileSystem, path string, content []byte, mode os.FileMode) error {
+	file, err := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(content)
+	if err == nil {
+		if syncer, ok := file.(interface{ Sync() error }); ok {
+			err = syncer.Sync()
+		}
+	}
+
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	// the mode passed to OpenFile is reduced by the umask
+	return fs.Chmod(path, mode)
+}
@@ -0,0 +1,108 @@
+package fileutil_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("EditFile", func() {
+	var (
+		fs   boshsys.FileSystem
+		path string
+	)
+
+	replace := func(old, new string) func([]byte) ([]byte, error) {
+		return func(content []byte) ([]byte, error) {
+			return bytes.ReplaceAll(content, []byte(old), []byte(new)), nil
+		}
+	}
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		path = filepath.Join(GinkgoT().TempDir(), "config")
+
+		Expect(os.WriteFile(path, []byte("port: 80\n"), 0640)).To(Succeed())
+		Expect(os.Chmod(path, 0640)).To(Succeed())
+	})
+
+	for _, atomic := range []bool{false, true} {
+		atomic := atomic
+
+		Context(fmt.Sprintf("when Atomic is %t", atomic), func() {
+			It("writes the transformed contents and keeps the permissions", func() {
+				changed, err := EditFile(fs, path, replace("80", "8080"), EditFileOpts{Atomic: atomic})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changed).To(BeTrue())
+
+				Expect(os.ReadFile(path)).To(Equal([]byte("port: 8080\n")))
+
+				if runtime.GOOS != "windows" {
+					stat, err := os.Stat(path)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0640)))
+				}
+
+				Expect(path + ".edit-tmp").ToNot(BeAnExistingFile())
+			})
+		})
+	}
+
+	It("keeps the previous contents when Backup is set", func() {
+		_, err := EditFile(fs, path, replace("80", "8080"), EditFileOpts{Backup: true, Atomic: true})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.ReadFile(path + ".bak")).To(Equal([]byte("port: 80\n")))
+	})
+
+	It("does not write anything when the contents do not change", func() {
+		changed, err := EditFile(fs, path, replace("443", "8443"), EditFileOpts{Backup: true, Atomic: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+
+		Expect(path + ".bak").ToNot(BeAnExistingFile())
+	})
+
+	It("leaves the file untouched when transform fails", func() {
+		_, err := EditFile(fs, path, func([]byte) ([]byte, error) {
+			return nil, errors.New("fake-transform-error")
+		}, EditFileOpts{Backup: true})
+		Expect(err).To(MatchError(ContainSubstring("fake-transform-error")))
+
+		Expect(os.ReadFile(path)).To(Equal([]byte("port: 80\n")))
+		Expect(path + ".bak").ToNot(BeAnExistingFile())
+	})
+
+	It("edits the target of symlinks", func() {
+		if runtime.GOOS == "windows" {
+			Skip("Creating symlinks requires privileges on Windows")
+		}
+
+		link := filepath.Join(filepath.Dir(path), "link")
+		Expect(os.Symlink(path, link)).To(Succeed())
+
+		_, err := EditFile(fs, link, replace("80", "8080"), EditFileOpts{Atomic: true})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.ReadFile(path)).To(Equal([]byte("port: 8080\n")))
+
+		stat, err := os.Lstat(link)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stat.Mode() & os.ModeSymlink).ToNot(BeZero())
+	})
+
+	It("returns an error when the file does not exist", func() {
+		_, err := EditFile(fs, path+"-missing", replace("80", "8080"), EditFileOpts{})
+		Expect(err).To(HaveOccurred())
+	})
+})
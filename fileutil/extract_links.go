@@ -0,0 +1,79 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/fileutil/pathutil"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// safeLinkTarget returns target cleaned so that ".." only appears at its
+// start, or an error when target is absolute or resolves outside of root
+// from the dir of linkPath. Cleaning keeps "link/.." from climbing out
+// of the dir another symlink points to.
+func safeLinkTarget(root, linkPath, target string) (string, error) {
+	if target == "" || pathutil.IsAbs(target) || pathutil.VolumeName(target) != "" {
+		return "", bosherr.Errorf("Link target '%s' of '%s' is not a relative path", target, linkPath)
+	}
+
+	cleaned := filepath.Clean(target)
+
+	linkDir, err := filepath.Rel(root, filepath.Dir(linkPath))
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Resolving link '%s'", linkPath)
+	}
+
+	_, err = pathutil.SafeJoin(root, linkDir, cleaned)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Link target '%s' of '%s'", target, linkPath)
+	}
+
+	return cleaned, nil
+}
+
+// checkNoSymlinkParents fails when one of the dirs between root and path
+// is a symlink so that extracting never writes outside of root. Dirs that
+// do not exist yet are fine since they are created as real dirs.
+func checkNoSymlinkParents(fs boshsys.FileSystem, root, path string) error {
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Resolving '%s'", path)
+	}
+
+	if rel == "." {
+		return nil
+	}
+
+	current := root
+	for _, component := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, component)
+
+		info, err := fs.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Checking parent '%s' of '%s'", current, path)
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return bosherr.Errorf("Refusing to extract '%s' through symlink '%s'", path, current)
+		}
+	}
+
+	return nil
+}
+
+// removeSymlink removes path when it is a symlink so that writing a file
+// to path replaces the link instead of writing to its target
+func removeSymlink(fs boshsys.FileSystem, path string) error {
+	info, err := fs.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	return fs.RemoveAll(path)
+}
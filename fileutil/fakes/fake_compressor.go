@@ -22,6 +22,10 @@ type FakeCompressor struct {
 	DecompressFileToDirErr          error
 	DecompressFileToDirCallBack     func()
 
+	ListTarballPath string
+	ListEntries     []boshcmd.ArchiveEntry
+	ListErr         error
+
 	CleanUpTarballPath string
 	CleanUpErr         error
 }
@@ -63,6 +67,11 @@ func (fc *FakeCompressor) DecompressFileToDir(tarballPath string, dir string, op
 	return fc.DecompressFileToDirErr
 }
 
+func (fc *FakeCompressor) List(tarballPath string) ([]boshcmd.ArchiveEntry, error) {
+	fc.ListTarballPath = tarballPath
+	return fc.ListEntries, fc.ListErr
+}
+
 func (fc *FakeCompressor) CleanUp(tarballPath string) error {
 	fc.CleanUpTarballPath = tarballPath
 	return fc.CleanUpErr
@@ -0,0 +1,164 @@
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ChunkerOpts bounds the size of chunks; zero values use the defaults
+// of 16 KiB, 64 KiB and 256 KiB
+type ChunkerOpts struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+func (o ChunkerOpts) withDefaults() ChunkerOpts {
+	if o.MinSize == 0 {
+		o.MinSize = 16 * 1024
+	}
+	if o.AvgSize == 0 {
+		o.AvgSize = 64 * 1024
+	}
+	if o.MaxSize == 0 {
+		o.MaxSize = 256 * 1024
+	}
+	return o
+}
+
+// fastCDCGear maps bytes to random values; it must never change since
+// that would move every chunk boundary and defeat deduplication
+var fastCDCGear = func() (gear [256]uint64) {
+	for i := range gear {
+		sum := sha256.Sum256([]byte{byte(i)})
+		gear[i] = binary.BigEndian.Uint64(sum[:8])
+	}
+	return
+}()
+
+// Chunker splits a stream into content defined chunks with FastCDC, so
+// that inserting or removing bytes only changes the chunks around the edit
+type Chunker struct {
+	reader io.Reader
+	opts   ChunkerOpts
+
+	// maskS makes cuts before AvgSize less likely, maskL makes
+	// cuts after it more likely (normalized chunking)
+	maskS uint64
+	maskL uint64
+
+	buf   []byte
+	start int
+	end   int
+	eof   bool
+}
+
+func NewChunker(reader io.Reader, opts ChunkerOpts) (*Chunker, error) {
+	opts = opts.withDefaults()
+
+	if opts.MinSize < 64 || opts.MinSize >= opts.AvgSize || opts.AvgSize >= opts.MaxSize {
+		return nil, bosherr.Errorf("Expected 64 <= min size < average size < max size but got %d, %d and %d",
+			opts.MinSize, opts.AvgSize, opts.MaxSize)
+	}
+
+	avgBits := bits.Len(uint(opts.AvgSize)) - 1
+
+	return &Chunker{
+		reader: reader,
+		opts:   opts,
+		maskS:  fastCDCMask(avgBits + 2),
+		maskL:  fastCDCMask(avgBits - 2),
+		buf:    make([]byte, opts.MaxSize),
+	}, nil
+}
+
+// fastCDCMask uses the high bits of the gear hash, which
+// depend on the most bytes
+func fastCDCMask(bits int) uint64 {
+	if bits < 1 {
+		bits = 1
+	}
+	return ((uint64(1) << bits) - 1) << (64 - bits)
+}
+
+// Next returns the next chunk, which is only valid until the following
+// call, or io.EOF after the last chunk
+func (c *Chunker) Next() ([]byte, error) {
+	err := c.fill()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+
+	return chunk, nil
+}
+
+// fill makes sure that a max size chunk is buffered unless the stream ends
+func (c *Chunker) fill() error {
+	if c.eof || c.end-c.start >= c.opts.MaxSize {
+		return nil
+	}
+
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+
+	for c.end < len(c.buf) {
+		n, err := c.reader.Read(c.buf[c.end:])
+		c.end += n
+
+		if err == io.EOF {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return bosherr.WrapError(err, "Reading chunk")
+		}
+	}
+
+	return nil
+}
+
+func (c *Chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.opts.MinSize {
+		return n
+	}
+	if n > c.opts.MaxSize {
+		n = c.opts.MaxSize
+	}
+
+	normal := c.opts.AvgSize
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	i := c.opts.MinSize
+
+	for ; i < normal; i++ {
+		fp = (fp << 1) + fastCDCGear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+
+	for ; i < n; i++ {
+		fp = (fp << 1) + fastCDCGear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,83 @@
+package fileutil_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/fileutil"
+)
+
+var _ = Describe("Chunker", func() {
+	opts := ChunkerOpts{MinSize: 1024, AvgSize: 4096, MaxSize: 16384}
+
+	randomData := func(size int, seed int64) []byte {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(seed)).Read(data)
+		return data
+	}
+
+	chunk := func(data []byte) [][]byte {
+		chunker, err := NewChunker(bytes.NewReader(data), opts)
+		Expect(err).ToNot(HaveOccurred())
+
+		var chunks [][]byte
+		for {
+			c, err := chunker.Next()
+			if err == io.EOF {
+				return chunks
+			}
+			Expect(err).ToNot(HaveOccurred())
+			chunks = append(chunks, append([]byte(nil), c...))
+		}
+	}
+
+	It("splits streams into chunks within the configured bounds", func() {
+		data := randomData(1024*1024, 1)
+
+		chunks := chunk(data)
+		Expect(bytes.Join(chunks, nil)).To(Equal(data))
+
+		for i, c := range chunks {
+			Expect(len(c)).To(BeNumerically("<=", opts.MaxSize))
+			if i < len(chunks)-1 {
+				Expect(len(c)).To(BeNumerically(">=", opts.MinSize))
+			}
+		}
+
+		averageSize := len(data) / len(chunks)
+		Expect(averageSize).To(BeNumerically("~", opts.AvgSize, opts.AvgSize/2))
+	})
+
+	It("returns io.EOF right away for empty streams", func() {
+		Expect(chunk(nil)).To(BeEmpty())
+	})
+
+	It("keeps chunks after an insertion unchanged", func() {
+		data := randomData(512*1024, 2)
+		edited := append(append(append([]byte(nil), data[:1000]...), []byte("fake-insertion")...), data[1000:]...)
+
+		original := map[string]bool{}
+		for _, c := range chunk(data) {
+			original[string(c)] = true
+		}
+
+		editedChunks := chunk(edited)
+		changed := 0
+		for _, c := range editedChunks {
+			if !original[string(c)] {
+				changed++
+			}
+		}
+
+		Expect(changed).To(BeNumerically("<=", 2))
+	})
+
+	It("rejects inconsistent sizes", func() {
+		_, err := NewChunker(bytes.NewReader(nil), ChunkerOpts{MinSize: 4096, AvgSize: 1024, MaxSize: 8192})
+		Expect(err).To(MatchError(ContainSubstring("Expected 64 <= min size < average size < max size")))
+	})
+})
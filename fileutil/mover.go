@@ -1,10 +1,13 @@
 package fileutil
 
 import (
+	"context"
 	"os"
 	"runtime"
 	"syscall"
 
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
@@ -16,7 +19,23 @@ func NewFileMover(fs boshsys.FileSystem) fileMover {
 	return fileMover{fs: fs}
 }
 
+// MoveOpts only apply when the file has to be copied to another device
+type MoveOpts struct {
+	// Context stops copying, the source is kept and the partial copy removed
+	Context context.Context
+
+	// Progress is called with the total number of bytes copied
+	Progress func(copied int64)
+}
+
 func (m fileMover) Move(oldPath, newPath string) error {
+	return m.MoveWithOpts(oldPath, newPath, MoveOpts{})
+}
+
+// MoveWithOpts renames the file or copies it when newPath is on another
+// device. A copy is synced and its digest verified before the source is
+// removed.
+func (m fileMover) MoveWithOpts(oldPath, newPath string, opts MoveOpts) error {
 	err := m.fs.Rename(oldPath, newPath)
 
 	le, ok := err.(*os.LinkError)
@@ -26,18 +45,79 @@ func (m fileMover) Move(oldPath, newPath string) error {
 
 	// 0x11 is Win32 Error Code ERROR_NOT_SAME_DEVICE (https://msdn.microsoft.com/en-us/library/cc231199.aspx)
 	if le.Err == syscall.Errno(0x12) || (runtime.GOOS == "windows" && le.Err == syscall.Errno(0x11)) {
-		err = m.fs.CopyFile(oldPath, newPath)
-		if err != nil {
-			return err
-		}
+		return m.moveAcrossDevices(oldPath, newPath, opts)
+	}
 
-		err = m.fs.RemoveAll(oldPath)
-		if err != nil {
-			return err
-		}
+	return err
+}
 
-		return nil
+func (m fileMover) moveAcrossDevices(oldPath, newPath string, opts MoveOpts) error {
+	digest, err := m.copy(oldPath, newPath, opts)
+	if err == nil {
+		err = m.verify(newPath, digest)
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying '%s' to '%s'", oldPath, newPath)
 	}
 
-	return err
+	return m.fs.RemoveAll(oldPath)
+}
+
+func (m fileMover) copy(oldPath, newPath string, opts MoveOpts) (boshcrypto.Digest, error) {
+	src, err := m.fs.OpenFile(oldPath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := m.fs.OpenFile(newPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return nil, err
+	}
+
+	_, digest, err := CopyWithDigestOpts(dst, src, boshsys.CopyOpts{Context: opts.Context, Progress: opts.Progress}, boshcrypto.DigestAlgorithmSHA256)
+
+	// some filesystems only report write errors once the data is synced
+	if syncer, ok := dst.(interface{ Sync() error }); ok && err == nil {
+		err = syncer.Sync()
+	}
+
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	// writing clears setuid and setgid and the umask drops bits at creation,
+	// so the full mode is only set once the data is in place
+	if err == nil {
+		err = m.fs.Chmod(newPath, info.Mode())
+	}
+
+	if err != nil {
+		_ = m.fs.RemoveAll(newPath)
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+func (m fileMover) verify(newPath string, digest boshcrypto.Digest) error {
+	file, err := m.fs.OpenFile(newPath, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = digest.Verify(file)
+	if err != nil {
+		_ = m.fs.RemoveAll(newPath)
+		return bosherr.WrapError(err, "Verifying copy")
+	}
+
+	return nil
 }
@@ -1,6 +1,7 @@
 package fileutil_test
 
 import (
+	"context"
 	"errors"
 	"os"
 	"syscall"
@@ -61,21 +62,70 @@ var _ = Describe("Mover", func() {
 			contents, err := fs.ReadFileString(newLocation)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(contents).To(Equal("some content"))
+		})
+
+		It("keeps the setuid and sticky bits of the file", func() {
+			mode := os.ModeSetuid | os.ModeSticky | 0755
+			Expect(fs.Chmod(oldLocation, mode)).To(Succeed())
+
+			err := mover.Move(oldLocation, newLocation)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.GetFileTestStat(newLocation).FileMode).To(Equal(mode))
+		})
 
-			Expect(fs.CopyFileCallCount).To(Equal(1))
+		Context("when setting the mode of the copy returns an error", func() {
+			BeforeEach(func() {
+				fs.ChmodErr = errors.New("chmod error")
+			})
+
+			It("returns an error and keeps the old file", func() {
+				err := mover.Move(oldLocation, newLocation)
+				Expect(err).To(MatchError(ContainSubstring("chmod error")))
+
+				Expect(fs.FileExists(oldLocation)).To(BeTrue())
+				Expect(fs.FileExists(newLocation)).To(BeFalse())
+			})
 		})
 
 		Context("when copying the file returns an error", func() {
 			BeforeEach(func() {
-				fs.CopyFileError = errors.New("copying error")
+				dst := fakes.NewFakeFile(newLocation, fs)
+				dst.WriteErr = errors.New("copying error")
+				fs.RegisterOpenFile(newLocation, dst)
 			})
 
-			It("returns an error", func() {
+			It("returns an error and keeps the old file", func() {
 				err := mover.Move(oldLocation, newLocation)
-				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(ContainSubstring("copying error")))
+
+				Expect(fs.FileExists(oldLocation)).To(BeTrue())
+				Expect(fs.FileExists(newLocation)).To(BeFalse())
 			})
 		})
 
+		It("reports progress", func() {
+			var progress []int64
+
+			err := NewFileMover(fs).MoveWithOpts(oldLocation, newLocation, MoveOpts{
+				Progress: func(copied int64) { progress = append(progress, copied) },
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(progress).To(Equal([]int64{int64(len("some content"))}))
+		})
+
+		It("keeps the old file when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := NewFileMover(fs).MoveWithOpts(oldLocation, newLocation, MoveOpts{Context: ctx})
+			Expect(err).To(MatchError(ContainSubstring("context canceled")))
+
+			Expect(fs.FileExists(oldLocation)).To(BeTrue())
+			Expect(fs.FileExists(newLocation)).To(BeFalse())
+		})
+
 		Context("when deleting the old file returns an error", func() {
 			BeforeEach(func() {
 				fs.RemoveAllStub = func(_ string) error {
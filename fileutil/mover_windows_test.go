@@ -50,18 +50,21 @@ var _ = Describe("Mover", func() {
 			contents, err := fs.ReadFileString(newLocation)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(contents).To(Equal("some content"))
-
-			Expect(fs.CopyFileCallCount).To(Equal(1))
 		})
 
 		Context("when copying the file returns an error", func() {
 			BeforeEach(func() {
-				fs.CopyFileError = errors.New("copying error")
+				dst := fakes.NewFakeFile(newLocation, fs)
+				dst.WriteErr = errors.New("copying error")
+				fs.RegisterOpenFile(newLocation, dst)
 			})
 
-			It("returns an error", func() {
+			It("returns an error and keeps the old file", func() {
 				err := mover.Move(oldLocation, newLocation)
-				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(ContainSubstring("copying error")))
+
+				Expect(fs.FileExists(oldLocation)).To(BeTrue())
+				Expect(fs.FileExists(newLocation)).To(BeFalse())
 			})
 		})
 
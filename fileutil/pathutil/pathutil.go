@@ -0,0 +1,182 @@
+package pathutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var reservedWindowsNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+const invalidWindowsChars = `<>:"|?*`
+
+type TraversalError struct {
+	Root string
+	Path string
+}
+
+func (e TraversalError) Error() string {
+	return fmt.Sprintf("Path '%s' escapes root '%s'", e.Path, e.Root)
+}
+
+type InvalidNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e InvalidNameError) Error() string {
+	return fmt.Sprintf("Invalid file name '%s': %s", e.Name, e.Reason)
+}
+
+// SafeJoin joins elems onto root and returns an error if any element is
+// absolute or if the cleaned result would resolve outside of root.
+// Symlinks are not resolved.
+func SafeJoin(root string, elems ...string) (string, error) {
+	rel := filepath.Join(elems...)
+
+	for _, elem := range elems {
+		if IsAbs(elem) || VolumeName(elem) != "" {
+			return "", TraversalError{Root: root, Path: elem}
+		}
+	}
+
+	if rel == ".." || strings.HasPrefix(ToSlash(rel), "../") {
+		return "", TraversalError{Root: root, Path: rel}
+	}
+
+	return filepath.Join(root, rel), nil
+}
+
+// VolumeName returns the leading drive letter ("C:") or UNC share
+// ("\\server\share") of path; it returns "" for Unix paths.
+func VolumeName(path string) string {
+	if len(path) >= 2 && path[1] == ':' && isLetter(path[0]) {
+		return path[:2]
+	}
+
+	if len(path) < 5 || !isSlash(path[0]) || !isSlash(path[1]) || isSlash(path[2]) {
+		return ""
+	}
+
+	// UNC path: \\server\share
+	serverEnd := indexSlash(path[2:])
+	if serverEnd == -1 {
+		return ""
+	}
+	serverEnd += 2
+
+	shareStart := serverEnd + 1
+	if shareStart >= len(path) || isSlash(path[shareStart]) {
+		return ""
+	}
+
+	shareEnd := indexSlash(path[shareStart:])
+	if shareEnd == -1 {
+		return path
+	}
+
+	return path[:shareStart+shareEnd]
+}
+
+// IsAbs reports whether path is absolute in either Unix or Windows syntax.
+func IsAbs(path string) bool {
+	volume := VolumeName(path)
+	if strings.HasPrefix(volume, `\\`) || strings.HasPrefix(volume, "//") {
+		return true
+	}
+
+	rest := path[len(volume):]
+	if volume != "" {
+		return len(rest) > 0 && isSlash(rest[0])
+	}
+
+	return len(rest) > 0 && rest[0] == '/'
+}
+
+// Split splits path into its volume, directory and file components,
+// treating both '/' and '\' as separators.
+func Split(path string) (volume, dir, file string) {
+	volume = VolumeName(path)
+	rest := path[len(volume):]
+
+	i := len(rest) - 1
+	for i >= 0 && !isSlash(rest[i]) {
+		i--
+	}
+
+	return volume, rest[:i+1], rest[i+1:]
+}
+
+// ToSlash replaces all backslashes with forward slashes.
+func ToSlash(path string) string {
+	return strings.Replace(path, `\`, "/", -1)
+}
+
+// ToBackslash replaces all forward slashes with backslashes.
+func ToBackslash(path string) string {
+	return strings.Replace(path, "/", `\`, -1)
+}
+
+// ValidateName checks a single path component for names that cannot be
+// created portably, including reserved Windows device names like CON or NUL.
+func ValidateName(name string) error {
+	if name == "" {
+		return InvalidNameError{Name: name, Reason: "name is empty"}
+	}
+
+	if name == "." || name == ".." {
+		return nil
+	}
+
+	for _, c := range name {
+		if c < 0x20 {
+			return InvalidNameError{Name: name, Reason: "contains control characters"}
+		}
+		if strings.ContainsRune(invalidWindowsChars, c) || c == '/' || c == '\\' {
+			return InvalidNameError{Name: name, Reason: fmt.Sprintf("contains invalid character '%c'", c)}
+		}
+	}
+
+	if strings.HasSuffix(name, " ") || strings.HasSuffix(name, ".") {
+		return InvalidNameError{Name: name, Reason: "ends with a space or a period"}
+	}
+
+	base := name
+	if i := strings.IndexByte(base, '.'); i != -1 {
+		base = base[:i]
+	}
+
+	if reservedWindowsNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		return InvalidNameError{Name: name, Reason: "is a reserved device name"}
+	}
+
+	return nil
+}
+
+// ValidatePath runs ValidateName against every component of path
+// (the volume name is skipped).
+func ValidatePath(path string) error {
+	volume := VolumeName(path)
+
+	for _, name := range strings.FieldsFunc(path[len(volume):], func(r rune) bool { return r == '/' || r == '\\' }) {
+		if err := ValidateName(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isSlash(c byte) bool { return c == '/' || c == '\\' }
+
+func isLetter(c byte) bool { return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') }
+
+func indexSlash(s string) int {
+	return strings.IndexAny(s, `/\`)
+}
@@ -0,0 +1,13 @@
+package pathutil_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPathutil(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Pathutil Suite")
+}
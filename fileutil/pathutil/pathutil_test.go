@@ -0,0 +1,129 @@
+package pathutil_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/fileutil/pathutil"
+)
+
+var _ = Describe("pathutil", func() {
+	Describe("SafeJoin", func() {
+		It("joins relative paths onto the root", func() {
+			path, err := SafeJoin("/fake-root", "a", "b/../c")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal(filepath.Join("/fake-root", "a", "c")))
+		})
+
+		It("allows '..' that stays inside the root", func() {
+			path, err := SafeJoin("/fake-root", "a/../b")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal(filepath.Join("/fake-root", "b")))
+		})
+
+		It("rejects paths that escape the root", func() {
+			_, err := SafeJoin("/fake-root", "a/../../etc")
+			Expect(err).To(Equal(TraversalError{Root: "/fake-root", Path: filepath.Join("..", "etc")}))
+
+			_, err = SafeJoin("/fake-root", "..")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects absolute elements in either syntax", func() {
+			for _, elem := range []string{"/etc/passwd", `C:\Windows`, "C:relative", `\\server\share\file`} {
+				_, err := SafeJoin("/fake-root", elem)
+				Expect(err).To(Equal(TraversalError{Root: "/fake-root", Path: elem}), elem)
+			}
+		})
+	})
+
+	Describe("VolumeName", func() {
+		It("returns drive letters and UNC shares", func() {
+			Expect(VolumeName(`C:\Windows`)).To(Equal("C:"))
+			Expect(VolumeName("d:/data")).To(Equal("d:"))
+			Expect(VolumeName(`\\server\share\dir`)).To(Equal(`\\server\share`))
+			Expect(VolumeName("//server/share")).To(Equal("//server/share"))
+		})
+
+		It("returns empty for Unix and malformed paths", func() {
+			Expect(VolumeName("/var/vcap")).To(BeEmpty())
+			Expect(VolumeName("relative")).To(BeEmpty())
+			Expect(VolumeName(`\\server`)).To(BeEmpty())
+			Expect(VolumeName(`\\\server\share`)).To(BeEmpty())
+		})
+	})
+
+	Describe("IsAbs", func() {
+		It("recognizes absolute paths in either syntax", func() {
+			Expect(IsAbs("/var/vcap")).To(BeTrue())
+			Expect(IsAbs(`C:\Windows`)).To(BeTrue())
+			Expect(IsAbs("C:/Windows")).To(BeTrue())
+			Expect(IsAbs(`\\server\share\file`)).To(BeTrue())
+		})
+
+		It("treats relative and drive-relative paths as relative", func() {
+			Expect(IsAbs("var/vcap")).To(BeFalse())
+			Expect(IsAbs("C:Windows")).To(BeFalse())
+			Expect(IsAbs("")).To(BeFalse())
+		})
+	})
+
+	Describe("Split", func() {
+		It("splits into volume, directory and file", func() {
+			volume, dir, file := Split(`C:\Windows\System32\cmd.exe`)
+			Expect(volume).To(Equal("C:"))
+			Expect(dir).To(Equal(`\Windows\System32\`))
+			Expect(file).To(Equal("cmd.exe"))
+
+			volume, dir, file = Split("/var/vcap/file")
+			Expect(volume).To(BeEmpty())
+			Expect(dir).To(Equal("/var/vcap/"))
+			Expect(file).To(Equal("file"))
+
+			volume, dir, file = Split("file")
+			Expect(volume).To(BeEmpty())
+			Expect(dir).To(BeEmpty())
+			Expect(file).To(Equal("file"))
+		})
+	})
+
+	Describe("ToSlash and ToBackslash", func() {
+		It("converts separators", func() {
+			Expect(ToSlash(`a\b/c`)).To(Equal("a/b/c"))
+			Expect(ToBackslash(`a\b/c`)).To(Equal(`a\b\c`))
+		})
+	})
+
+	Describe("ValidateName", func() {
+		It("accepts portable names", func() {
+			for _, name := range []string{"file.txt", "CONFIG", "com10", "nul-device", ".", ".."} {
+				Expect(ValidateName(name)).To(Succeed(), name)
+			}
+		})
+
+		It("rejects reserved device names regardless of case or extension", func() {
+			for _, name := range []string{"CON", "nul", "Com1", "lpt9.txt", "aux.tar.gz"} {
+				err := ValidateName(name)
+				Expect(err).To(Equal(InvalidNameError{Name: name, Reason: "is a reserved device name"}), name)
+			}
+		})
+
+		It("rejects invalid characters and trailing spaces or periods", func() {
+			for _, name := range []string{"", "a<b", "a:b", "a?b", "a*b", "a/b", `a\b`, "a\x01b", "trailing ", "trailing."} {
+				Expect(ValidateName(name)).To(HaveOccurred(), name)
+			}
+		})
+	})
+
+	Describe("ValidatePath", func() {
+		It("validates every component and skips the volume", func() {
+			Expect(ValidatePath(`C:\Users\vcap\file.txt`)).To(Succeed())
+			Expect(ValidatePath("/var/vcap/data")).To(Succeed())
+
+			err := ValidatePath(`C:\Users\NUL\file.txt`)
+			Expect(err).To(Equal(InvalidNameError{Name: "NUL", Reason: "is a reserved device name"}))
+		})
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"runtime"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/fileutil/pathutil"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
@@ -40,6 +41,9 @@ func (c tarballCompressor) CompressSpecificFilesInDir(dir string, files []string
 	}
 
 	for _, file := range files {
+		if _, err := pathutil.SafeJoin(dir, file); err != nil {
+			return "", bosherr.WrapErrorf(err, "Validating file '%s' to compress", file)
+		}
 		args = append(args, file)
 	}
 
@@ -63,6 +67,9 @@ func (c tarballCompressor) DecompressFileToDir(tarballPath string, dir string, o
 	}
 
 	if options.PathInArchive != "" {
+		if _, err := pathutil.SafeJoin(dir, options.PathInArchive); err != nil {
+			return bosherr.WrapError(err, "Validating path in archive")
+		}
 		args = append(args, options.PathInArchive)
 	}
 	_, _, _, err := c.cmdRunner.RunCommand("tar", args...)
@@ -70,6 +77,13 @@ func (c tarballCompressor) DecompressFileToDir(tarballPath string, dir string, o
 		return bosherr.WrapError(err, "Shelling out to tar")
 	}
 
+	if options.Dedup != nil {
+		_, err = NewDeduplicator(c.fs).Dedup(dir, *options.Dedup)
+		if err != nil {
+			return bosherr.WrapError(err, "Deduplicating extracted files")
+		}
+	}
+
 	return nil
 }
 
@@ -151,6 +151,12 @@ var _ = Describe("tarballCompressor", func() {
 	})
 
 	Describe("CompressSpecificFilesInDir", func() {
+		It("rejects files outside of the given directory", func() {
+			_, err := compressor.CompressSpecificFilesInDir(fixtureSrcDir(), []string{"app.stdout.log", "../../secret"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Validating file '../../secret' to compress"))
+		})
+
 		It("compresses the given files in the given directory", func() {
 			srcDir := fixtureSrcDir()
 			files := []string{
@@ -283,6 +289,16 @@ var _ = Describe("tarballCompressor", func() {
 			))
 		})
 
+		It("rejects a PathInArchive that escapes the destination", func() {
+			cmdRunner := fakesys.NewFakeCmdRunner()
+			compressor := NewTarballCompressor(cmdRunner, fs)
+
+			err := compressor.DecompressFileToDir(fixtureSrcTgz(), dstDir, CompressorOptions{PathInArchive: "../etc/passwd"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("escapes root"))
+			Expect(cmdRunner.RunCommands).To(BeEmpty())
+		})
+
 		It("uses StripComponents option", func() {
 			cmdRunner := fakesys.NewFakeCmdRunner()
 			compressor := NewTarballCompressor(cmdRunner, fs)
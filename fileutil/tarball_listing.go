@@ -0,0 +1,154 @@
+package fileutil
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const releaseManifestName = "release.MF"
+
+type releaseManifest struct {
+	Jobs             []releaseManifestItem `yaml:"jobs"`
+	Packages         []releaseManifestItem `yaml:"packages"`
+	CompiledPackages []releaseManifestItem `yaml:"compiled_packages"`
+	License          *releaseManifestItem  `yaml:"license"`
+}
+
+type releaseManifestItem struct {
+	Name string `yaml:"name"`
+	SHA1 string `yaml:"sha1"`
+}
+
+// List reads tar headers only, so it does not need tar on the PATH and
+// returns before anything is written to disk
+func (c tarballCompressor) List(tarballPath string) ([]ArchiveEntry, error) {
+	file, err := c.fs.OpenFile(tarballPath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening tarball '%s'", tarballPath)
+	}
+
+	defer file.Close()
+
+	reader, err := decompressingReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading tarball '%s'", tarballPath)
+	}
+
+	var entries []ArchiveEntry
+	var manifest *releaseManifest
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading tarball '%s'", tarballPath)
+		}
+
+		entries = append(entries, ArchiveEntry{
+			Path:     header.Name,
+			Size:     header.Size,
+			Mode:     header.FileInfo().Mode(),
+			UID:      header.Uid,
+			GID:      header.Gid,
+			Linkname: header.Linkname,
+			ModTime:  header.ModTime,
+		})
+
+		if header.Typeflag == tar.TypeReg && path.Clean(header.Name) == releaseManifestName {
+			manifest, err = parseReleaseManifest(tarReader)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Parsing '%s' in tarball '%s'", releaseManifestName, tarballPath)
+			}
+		}
+	}
+
+	if manifest != nil {
+		digests, err := manifest.digests()
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing '%s' in tarball '%s'", releaseManifestName, tarballPath)
+		}
+
+		for i, entry := range entries {
+			entries[i].Digest = digests[path.Clean(entry.Path)]
+		}
+	}
+
+	return entries, nil
+}
+
+// decompressingReader accepts gzipped and plain tarballs
+func decompressingReader(reader *bufio.Reader) (io.Reader, error) {
+	magic, err := reader.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(reader)
+	}
+
+	return reader, nil
+}
+
+func parseReleaseManifest(reader io.Reader) (*releaseManifest, error) {
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest releaseManifest
+
+	err = yaml.Unmarshal(contents, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+func (m releaseManifest) digests() (map[string]boshcrypto.Digest, error) {
+	digests := map[string]boshcrypto.Digest{}
+
+	add := func(dir string, items []releaseManifestItem) error {
+		for _, item := range items {
+			if item.SHA1 == "" {
+				continue
+			}
+
+			digest, err := boshcrypto.ParseMultipleDigest(strconv.Quote(item.SHA1))
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Parsing digest of '%s'", item.Name)
+			}
+
+			digests[path.Join(dir, item.Name+".tgz")] = digest
+		}
+		return nil
+	}
+
+	err := add("jobs", m.Jobs)
+	if err == nil {
+		err = add("packages", m.Packages)
+	}
+	if err == nil {
+		err = add("compiled_packages", m.CompiledPackages)
+	}
+	if err == nil && m.License != nil {
+		m.License.Name = "license"
+		err = add(".", []releaseManifestItem{*m.License})
+	}
+
+	return digests, err
+}
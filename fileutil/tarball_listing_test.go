@@ -0,0 +1,118 @@
+package fileutil_test
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	. "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("tarballCompressor List", func() {
+	var (
+		compressor Compressor
+		tmpDir     string
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		compressor = NewTarballCompressor(boshsys.NewExecCmdRunner(logger), boshsys.NewOsFileSystem(logger))
+		tmpDir = GinkgoT().TempDir()
+	})
+
+	writeTarball := func(files map[string]string, headers ...*tar.Header) string {
+		tarballPath := filepath.Join(tmpDir, "plain.tar")
+
+		file, err := os.Create(tarballPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+
+		writer := tar.NewWriter(file)
+		for _, header := range headers {
+			header.Size = int64(len(files[header.Name]))
+			Expect(writer.WriteHeader(header)).To(Succeed())
+			_, err = writer.Write([]byte(files[header.Name]))
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(writer.Close()).To(Succeed())
+
+		return tarballPath
+	}
+
+	It("lists entries of gzipped tarballs", func() {
+		entries, err := compressor.List(fixtureSrcTgz())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(8))
+
+		Expect(entries[3].Path).To(Equal("./not-nested-file"))
+		Expect(entries[3].Size).To(Equal(int64(16)))
+		Expect(entries[3].Mode).To(Equal(os.FileMode(0644)))
+		Expect(entries[3].Digest).To(BeNil())
+
+		Expect(entries[1].Path).To(Equal("./dir/"))
+		Expect(entries[1].Mode.IsDir()).To(BeTrue())
+	})
+
+	It("lists plain tarballs with owners and link names", func() {
+		tarballPath := writeTarball(nil,
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "target", Mode: 0777, Uid: 1000, Gid: 1001},
+		)
+
+		entries, err := compressor.List(tarballPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Linkname).To(Equal("target"))
+		Expect(entries[0].Mode & os.ModeSymlink).ToNot(BeZero())
+		Expect(entries[0].UID).To(Equal(1000))
+		Expect(entries[0].GID).To(Equal(1001))
+	})
+
+	It("adds digests from release.MF", func() {
+		files := map[string]string{
+			"./release.MF": "jobs:\n- name: web\n  sha1: sha256:abc123\npackages:\n- name: ruby\n  sha1: def456\n",
+		}
+
+		tarballPath := writeTarball(files,
+			&tar.Header{Name: "./jobs/web.tgz", Typeflag: tar.TypeReg, Mode: 0644},
+			&tar.Header{Name: "./release.MF", Typeflag: tar.TypeReg, Mode: 0644},
+			&tar.Header{Name: "./packages/ruby.tgz", Typeflag: tar.TypeReg, Mode: 0644},
+			&tar.Header{Name: "./packages/other.tgz", Typeflag: tar.TypeReg, Mode: 0644},
+		)
+
+		entries, err := compressor.List(tarballPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(entries[0].Digest.String()).To(Equal("sha256:abc123"))
+		Expect(entries[0].Digest.Algorithm()).To(Equal(boshcrypto.DigestAlgorithmSHA256))
+		Expect(entries[2].Digest.String()).To(Equal("def456"))
+		Expect(entries[3].Digest).To(BeNil())
+	})
+
+	It("returns an error for invalid release.MF files", func() {
+		files := map[string]string{"release.MF": "jobs: [{name: web, sha1: 'not valid'}]"}
+
+		tarballPath := writeTarball(files, &tar.Header{Name: "release.MF", Typeflag: tar.TypeReg, Mode: 0644})
+
+		_, err := compressor.List(tarballPath)
+		Expect(err).To(MatchError(ContainSubstring("Parsing digest of 'web'")))
+	})
+
+	It("returns an error for corrupt tarballs", func() {
+		tarballPath := filepath.Join(tmpDir, "corrupt.tgz")
+		Expect(os.WriteFile(tarballPath, []byte{0x1f, 0x8b, 0, 1, 2}, 0644)).To(Succeed())
+
+		_, err := compressor.List(tarballPath)
+		Expect(err).To(MatchError(ContainSubstring("Reading tarball")))
+	})
+
+	It("returns an error when the tarball does not exist", func() {
+		_, err := compressor.List(filepath.Join(tmpDir, "missing.tgz"))
+		Expect(err).To(MatchError(ContainSubstring("Opening tarball")))
+	})
+})
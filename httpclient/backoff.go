@@ -0,0 +1,89 @@
+package httpclient
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BackoffHook is called with every delay a client computed before it
+// waits, attempt starting at 1 for the first delay after a failure
+type BackoffHook func(attempt int, delay time.Duration)
+
+// jitteredBackoff doubles the delay for every attempt starting at min and
+// picks a random delay between min and that, never exceeding max
+type jitteredBackoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+
+	jitter *backoffJitter
+	hook   BackoffHook
+}
+
+func newJitteredBackoff(min, max time.Duration, jitter *backoffJitter, hook BackoffHook) *jitteredBackoff {
+	return &jitteredBackoff{min: min, max: max, jitter: jitter, hook: hook}
+}
+
+func (b *jitteredBackoff) Duration() time.Duration {
+	delay := b.next()
+
+	b.attempt++
+	if b.hook != nil {
+		b.hook(b.attempt, delay)
+	}
+
+	return delay
+}
+
+func (b *jitteredBackoff) Reset() {
+	b.attempt = 0
+}
+
+func (b *jitteredBackoff) next() time.Duration {
+	if b.min >= b.max {
+		return b.max
+	}
+
+	minf := float64(b.min)
+	durf := minf * math.Pow(2, float64(b.attempt))
+	durf = b.jitter.float64()*(durf-minf) + minf
+
+	if durf >= float64(b.max) {
+		return b.max
+	}
+
+	delay := time.Duration(durf)
+	if delay < b.min {
+		return b.min
+	}
+
+	return delay
+}
+
+// backoffJitter serializes access to a rand.Source, which is not safe for
+// concurrent use, since one client may back off in several goroutines
+type backoffJitter struct {
+	rand *rand.Rand
+	lock sync.Mutex
+}
+
+// newBackoffJitter uses the global random source when source is nil
+func newBackoffJitter(source rand.Source) *backoffJitter {
+	if source == nil {
+		return &backoffJitter{}
+	}
+	return &backoffJitter{rand: rand.New(source)}
+}
+
+func (j *backoffJitter) float64() float64 {
+	if j.rand == nil {
+		return rand.Float64()
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.rand.Float64()
+}
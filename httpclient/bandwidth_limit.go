@@ -0,0 +1,174 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+// BandwidthLimiter is a token bucket of bytes. One limiter can be shared
+// by several clients to limit the bandwidth of the whole process.
+type BandwidthLimiter struct {
+	bytesPerSecond float64
+	burst          int
+
+	tokens      float64
+	last        time.Time
+	timeService clock.Clock
+	lock        sync.Mutex
+}
+
+// NewBandwidthLimiter allows bytesPerSecond on average and up to burst
+// bytes at once. burst defaults to a second worth of bytes and timeService
+// to the system clock.
+func NewBandwidthLimiter(bytesPerSecond int64, burst int, timeService clock.Clock) *BandwidthLimiter {
+	if burst <= 0 {
+		burst = int(bytesPerSecond)
+	}
+
+	if timeService == nil {
+		timeService = clock.NewClock()
+	}
+
+	return &BandwidthLimiter{
+		bytesPerSecond: float64(bytesPerSecond),
+		burst:          burst,
+		tokens:         float64(burst),
+		last:           timeService.Now(),
+		timeService:    timeService,
+	}
+}
+
+// wait takes n bytes from the bucket and sleeps until they were earned
+func (l *BandwidthLimiter) wait(ctx context.Context, n int) error {
+	delay := l.reserve(n)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := l.timeService.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *BandwidthLimiter) reserve(n int) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.timeService.Now()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.bytesPerSecond
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+
+	// callers waiting at the same time queue up behind each other
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.bytesPerSecond * float64(time.Second))
+}
+
+type BandwidthLimitOpts struct {
+	// Upload limits request bodies
+	Upload *BandwidthLimiter
+
+	// Download limits response bodies
+	Download *BandwidthLimiter
+}
+
+// WithBandwidthLimit throttles request and response bodies, e.g. so that
+// background blob syncs do not saturate the network. It can be used twice
+// to apply a limit of the client and one shared with other clients.
+func WithBandwidthLimit(opts BandwidthLimitOpts) ClientOption {
+	return func(client *http.Client) {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+
+		client.Transport = &bandwidthLimitRoundTripper{next: next, opts: opts}
+	}
+}
+
+type bandwidthLimitRoundTripper struct {
+	next http.RoundTripper
+	opts BandwidthLimitOpts
+}
+
+func (t *bandwidthLimitRoundTripper) wrapped() *http.RoundTripper {
+	return &t.next
+}
+
+func (t *bandwidthLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	if t.opts.Upload != nil && req.Body != nil && req.Body != http.NoBody {
+		limiter := t.opts.Upload
+
+		limitedReq := req.Clone(ctx)
+		limitedReq.Body = &throttledBody{ReadCloser: req.Body, limiter: limiter, ctx: ctx}
+
+		if req.GetBody != nil {
+			limitedReq.GetBody = func() (io.ReadCloser, error) {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				return &throttledBody{ReadCloser: body, limiter: limiter, ctx: ctx}, nil
+			}
+		}
+
+		req = limitedReq
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if t.opts.Download != nil {
+		resp.Body = &throttledBody{ReadCloser: resp.Body, limiter: t.opts.Download, ctx: ctx}
+	}
+
+	return resp, nil
+}
+
+// throttledBody waits for the bytes it read so that the next read is
+// delayed until the limiter allows them
+type throttledBody struct {
+	io.ReadCloser
+
+	limiter *BandwidthLimiter
+	ctx     context.Context
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	// never take more than the bucket holds at once
+	if len(p) > b.limiter.burst {
+		p = p[:b.limiter.burst]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		waitErr := b.limiter.wait(b.ctx, n)
+		if waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,144 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+)
+
+var _ = Describe("bandwidth limits", func() {
+	var (
+		server          *httptest.Server
+		blob            []byte
+		fakeTimeService *fakeclock.FakeClock
+	)
+
+	BeforeEach(func() {
+		blob = bytes.Repeat([]byte("x"), 30*1024)
+		fakeTimeService = fakeclock.NewFakeClock(time.Now())
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PUT" {
+				io.Copy(io.Discard, r.Body)
+				return
+			}
+			w.Write(blob)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	// throttled moves the fake clock forward in small steps while bodies
+	// wait for the limiter and returns how far it moved until f is done
+	throttled := func(f func()) time.Duration {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			f()
+		}()
+
+		startedAt := fakeTimeService.Now()
+		for {
+			select {
+			case <-done:
+				return fakeTimeService.Since(startedAt)
+			default:
+			}
+
+			if fakeTimeService.WatcherCount() > 0 {
+				fakeTimeService.Increment(time.Millisecond)
+			} else {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+
+	download := func(client Client) {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(body).To(Equal(blob))
+	}
+
+	It("limits downloads", func() {
+		limiter := NewBandwidthLimiter(100*1024, 10*1024, fakeTimeService)
+		client := CreateDefaultClient(nil, WithBandwidthLimit(BandwidthLimitOpts{Download: limiter}))
+
+		// the first 10KiB are allowed at once
+		Expect(throttled(func() { download(client) })).To(BeNumerically("~", 200*time.Millisecond, 20*time.Millisecond))
+	})
+
+	It("limits uploads", func() {
+		limiter := NewBandwidthLimiter(100*1024, 10*1024, fakeTimeService)
+		client := CreateDefaultClient(nil, WithBandwidthLimit(BandwidthLimitOpts{Upload: limiter}))
+
+		req, err := http.NewRequest("PUT", server.URL, bytes.NewReader(blob))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(throttled(func() {
+			resp, err := client.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+		})).To(BeNumerically("~", 200*time.Millisecond, 20*time.Millisecond))
+	})
+
+	It("shares limiters between clients", func() {
+		limiter := NewBandwidthLimiter(100*1024, 10*1024, fakeTimeService)
+
+		clients := []Client{
+			CreateDefaultClient(nil, WithBandwidthLimit(BandwidthLimitOpts{Download: limiter})),
+			CreateDefaultClient(nil, WithBandwidthLimit(BandwidthLimitOpts{Download: limiter})),
+		}
+
+		duration := throttled(func() {
+			var wg sync.WaitGroup
+			for _, client := range clients {
+				wg.Add(1)
+				go func(client Client) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					download(client)
+				}(client)
+			}
+			wg.Wait()
+		})
+
+		// 60KiB at 100KiB/s after a burst of 10KiB
+		Expect(duration).To(BeNumerically(">=", 500*time.Millisecond))
+	})
+
+	It("stops waiting once the request context is done", func() {
+		limiter := NewBandwidthLimiter(1024, 1024, fakeTimeService)
+		client := CreateDefaultClient(nil, WithBandwidthLimit(BandwidthLimitOpts{Upload: limiter}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, "PUT", server.URL, strings.NewReader(string(blob)))
+		Expect(err).ToNot(HaveOccurred())
+
+		// the fake clock never moves, so only the context ends the wait
+		_, err = client.Do(req)
+		Expect(err).To(HaveOccurred())
+	})
+})
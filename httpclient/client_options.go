@@ -0,0 +1,39 @@
+package httpclient
+
+import (
+	"net/http"
+)
+
+// ClientOption customizes a client built by one of the client constructors.
+type ClientOption func(*http.Client)
+
+// WithDialContext replaces the dialer used to open connections, e.g. to reach
+// servers over AF_VSOCK or in-memory pipes. Proxy and TLS settings of the
+// client still apply; BOSH_ALL_PROXY is not used since it is implemented by the
+// default dialer.
+func WithDialContext(dialContext DialContextFunc) ClientOption {
+	return func(client *http.Client) {
+		switch transport := client.Transport.(type) {
+		case *http.Transport:
+			transport.DialContext = dialContext
+		case *connRecycler:
+			transport.dialContext = dialContext
+		case wrappingRoundTripper:
+			WithDialContext(dialContext)(&http.Client{Transport: *transport.wrapped()})
+		}
+	}
+}
+
+// wrappingRoundTripper is implemented by round trippers that options install
+// in front of the transport so that later options can still reach it
+type wrappingRoundTripper interface {
+	http.RoundTripper
+	wrapped() *http.RoundTripper
+}
+
+func applyClientOptions(client *http.Client, opts []ClientOption) *http.Client {
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
@@ -0,0 +1,130 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// pipeListener serves connections created in-memory by its dial func
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: "pipe", Net: "pipe"}
+}
+
+func (l *pipeListener) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	clientConn, serverConn := net.Pipe()
+
+	select {
+	case l.conns <- serverConn:
+		return clientConn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+var _ = Describe("WithDialContext", func() {
+	var (
+		listener *pipeListener
+		server   *http.Server
+		dialed   []string
+	)
+
+	BeforeEach(func() {
+		dialed = nil
+		listener = newPipeListener()
+		server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte("in-memory"))
+		})}
+		go server.Serve(listener)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	dialContext := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return listener.DialContext(ctx, network, address)
+	}
+
+	It("uses the dialer for default clients", func() {
+		client := CreateDefaultClient(nil, WithDialContext(dialContext))
+
+		resp, err := client.Get("http://fake-vsock-host:1234/")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readString(resp.Body)).To(Equal("in-memory"))
+
+		Expect(dialed).To(Equal([]string{"fake-vsock-host:1234"}))
+		Expect(client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify).To(BeFalse())
+	})
+
+	It("uses the dialer for insecure and mutual TLS clients", func() {
+		dialErr := errors.New("fake-dial-err")
+		failingDial := func(_ context.Context, _, _ string) (net.Conn, error) {
+			return nil, dialErr
+		}
+
+		identity, err := tls.LoadX509KeyPair("./assets/test_client.pem", "./assets/test_client.key")
+		Expect(err).ToNot(HaveOccurred())
+
+		clients := []*http.Client{
+			CreateDefaultClientInsecureSkipVerify(WithDialContext(failingDial)),
+			CreateKeepAliveDefaultClient(nil, WithDialContext(failingDial)),
+			CreateExternalDefaultClient(nil, WithDialContext(failingDial)),
+			NewMutualTLSClient(identity, x509.NewCertPool(), "fake-server", WithDialContext(failingDial)),
+		}
+
+		for _, client := range clients {
+			_, err := client.Get("https://fake-host/")
+			Expect(errors.Is(err, dialErr)).To(BeTrue())
+		}
+	})
+
+	It("uses the dialer configured in a client profile", func() {
+		registry := NewClientRegistry(boshlog.NewLogger(boshlog.LevelNone))
+		registry.Register("vsock", ClientProfile{DialContext: dialContext})
+
+		client, err := registry.Client("vsock")
+		Expect(err).ToNot(HaveOccurred())
+
+		req, err := http.NewRequest("GET", "http://fake-vsock-host/", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readString(resp.Body)).To(Equal("in-memory"))
+		Expect(dialed).To(Equal([]string{"fake-vsock-host:80"}))
+	})
+})
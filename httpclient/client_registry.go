@@ -0,0 +1,220 @@
+package httpclient
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// ClientProfile describes how a named client is constructed.
+// Zero values fall back to the same defaults as CreateDefaultClient.
+type ClientProfile struct {
+	CertPool           *x509.CertPool
+	InsecureSkipVerify bool
+	External           bool
+	DisableKeepAlives  bool
+
+	// Proxy defaults to http.ProxyFromEnvironment
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// ProxyConfig configures an authenticated proxy and overrides Proxy
+	ProxyConfig *ProxyConfig
+
+	// DialContext replaces the default dialer (see WithDialContext)
+	DialContext DialContextFunc
+
+	// MaxConnAge and MaxConnRequests recycle pooled connections
+	// (see WithMaxConnAge and WithMaxConnRequests)
+	MaxConnAge      time.Duration
+	MaxConnRequests int
+
+	// GzipRequests compresses request bodies (see WithGzipRequests)
+	GzipRequests *GzipRequestOpts
+
+	// DefaultHeaders and UserAgent are set on requests that do not set them
+	// (see WithDefaultHeaders and WithUserAgent)
+	DefaultHeaders http.Header
+	UserAgent      *UserAgent
+
+	// CookieJar stores session cookies across requests (see WithCookieJar)
+	CookieJar http.CookieJar
+
+	// Timeout is the overall per-request timeout (0 means no timeout)
+	Timeout time.Duration
+
+	// MaxAttempts > 1 wraps the client in a retry client
+	MaxAttempts uint
+	RetryDelay  time.Duration
+
+	// MaxRetryAfter caps Retry-After delays, defaults to DefaultMaxRetryAfter
+	// (see WithMaxRetryAfter)
+	MaxRetryAfter time.Duration
+
+	// NetworkSafeRetry only retries idempotent requests (see NewNetworkSafeRetryClient)
+	NetworkSafeRetry bool
+
+	// ResumeDownloads resumes interrupted GET bodies (see NewResumableRetryClient)
+	ResumeDownloads bool
+}
+
+type ClientRegistry struct {
+	logger boshlog.Logger
+
+	mu       sync.Mutex
+	profiles map[string]ClientProfile
+	clients  map[string]Client
+}
+
+var DefaultClientRegistry = NewClientRegistry(boshlog.NewLogger(boshlog.LevelNone))
+
+func NewClientRegistry(logger boshlog.Logger) *ClientRegistry {
+	return &ClientRegistry{
+		logger:   logger,
+		profiles: map[string]ClientProfile{},
+		clients:  map[string]Client{},
+	}
+}
+
+// Register adds or replaces the profile with the given name.
+// Clients previously built from a replaced profile are not affected.
+func (r *ClientRegistry) Register(name string, profile ClientProfile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.profiles[name] = profile
+	delete(r.clients, name)
+}
+
+func (r *ClientRegistry) Profile(name string) (ClientProfile, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	profile, found := r.profiles[name]
+	return profile, found
+}
+
+func (r *ClientRegistry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.profiles))
+	for name := range r.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Client returns the client for the named profile. The client is built on
+// first use and shared by all subsequent callers.
+func (r *ClientRegistry) Client(name string) (Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, found := r.clients[name]; found {
+		return client, nil
+	}
+
+	profile, found := r.profiles[name]
+	if !found {
+		return nil, bosherr.Errorf("HTTP client profile '%s' is not registered", name)
+	}
+
+	client, err := r.build(profile)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Building HTTP client '%s'", name)
+	}
+	r.clients[name] = client
+
+	return client, nil
+}
+
+func (r *ClientRegistry) HTTPClient(name string) (*HTTPClient, error) {
+	client, err := r.Client(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHTTPClient(client, r.logger), nil
+}
+
+func (r *ClientRegistry) build(profile ClientProfile) (Client, error) {
+	var opts []ClientOption
+	if profile.DialContext != nil {
+		opts = append(opts, WithDialContext(profile.DialContext))
+	}
+	if profile.MaxConnAge > 0 {
+		opts = append(opts, WithMaxConnAge(profile.MaxConnAge))
+	}
+	if profile.MaxConnRequests > 0 {
+		opts = append(opts, WithMaxConnRequests(profile.MaxConnRequests))
+	}
+	if profile.ProxyConfig != nil {
+		// invalid proxy URLs would fail every request, so reject them now
+		if _, err := profile.ProxyConfig.proxyFunc(); err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithProxyConfig(*profile.ProxyConfig))
+	}
+
+	httpClient := factory{}.New(profile.InsecureSkipVerify, profile.External, profile.DisableKeepAlives, profile.CertPool, opts...)
+	httpClient.Timeout = profile.Timeout
+
+	if profile.Proxy != nil && profile.ProxyConfig == nil {
+		transport := httpClient.Transport
+		if recycler, ok := transport.(*connRecycler); ok {
+			transport = recycler.transport
+		}
+		transport.(*http.Transport).Proxy = profile.Proxy
+	}
+
+	if profile.GzipRequests != nil {
+		WithGzipRequests(*profile.GzipRequests)(httpClient)
+	}
+
+	if len(profile.DefaultHeaders) > 0 {
+		WithDefaultHeaders(profile.DefaultHeaders)(httpClient)
+	}
+
+	if profile.UserAgent != nil {
+		WithUserAgent(*profile.UserAgent)(httpClient)
+	}
+
+	if profile.CookieJar != nil {
+		WithCookieJar(profile.CookieJar)(httpClient)
+	}
+
+	if profile.MaxAttempts <= 1 {
+		return httpClient, nil
+	}
+
+	var retryOpts []RetryClientOption
+	if profile.MaxRetryAfter > 0 {
+		retryOpts = append(retryOpts, WithMaxRetryAfter(profile.MaxRetryAfter))
+	}
+
+	if profile.ResumeDownloads {
+		return NewResumableRetryClient(httpClient, profile.MaxAttempts, profile.RetryDelay, r.logger, retryOpts...), nil
+	}
+
+	if profile.NetworkSafeRetry {
+		return NewNetworkSafeRetryClient(httpClient, profile.MaxAttempts, profile.RetryDelay, r.logger, retryOpts...), nil
+	}
+
+	return NewRetryClient(httpClient, profile.MaxAttempts, profile.RetryDelay, r.logger, retryOpts...), nil
+}
+
+func RegisterClientProfile(name string, profile ClientProfile) {
+	DefaultClientRegistry.Register(name, profile)
+}
+
+func ClientForProfile(name string) (Client, error) {
+	return DefaultClientRegistry.Client(name)
+}
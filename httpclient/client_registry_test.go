@@ -0,0 +1,124 @@
+package httpclient_test
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("ClientRegistry", func() {
+	var (
+		registry *ClientRegistry
+	)
+
+	BeforeEach(func() {
+		registry = NewClientRegistry(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	It("returns an error for unknown profiles", func() {
+		_, err := registry.Client("director")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("'director' is not registered"))
+	})
+
+	It("lists registered profile names", func() {
+		registry.Register("metadata", ClientProfile{})
+		registry.Register("blobstore", ClientProfile{})
+
+		Expect(registry.Names()).To(Equal([]string{"blobstore", "metadata"}))
+
+		profile, found := registry.Profile("metadata")
+		Expect(found).To(BeTrue())
+		Expect(profile).To(Equal(ClientProfile{}))
+	})
+
+	It("builds the client once and shares it", func() {
+		registry.Register("director", ClientProfile{})
+
+		client1, err := registry.Client("director")
+		Expect(err).ToNot(HaveOccurred())
+
+		client2, err := registry.Client("director")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(client1).To(BeIdenticalTo(client2))
+	})
+
+	It("rebuilds the client after the profile is replaced", func() {
+		registry.Register("director", ClientProfile{Timeout: time.Second})
+		client1, err := registry.Client("director")
+		Expect(err).ToNot(HaveOccurred())
+
+		registry.Register("director", ClientProfile{Timeout: time.Minute})
+		client2, err := registry.Client("director")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(client1.(*http.Client).Timeout).To(Equal(time.Second))
+		Expect(client2.(*http.Client).Timeout).To(Equal(time.Minute))
+	})
+
+	It("applies TLS, keep-alive and proxy settings", func() {
+		proxyURL, _ := url.Parse("http://proxy.example.com:3128")
+		registry.Register("metadata", ClientProfile{
+			InsecureSkipVerify: true,
+			DisableKeepAlives:  true,
+			Proxy:              http.ProxyURL(proxyURL),
+		})
+
+		client, err := registry.Client("metadata")
+		Expect(err).ToNot(HaveOccurred())
+
+		transport := client.(*http.Client).Transport.(*http.Transport)
+		Expect(transport.TLSClientConfig.InsecureSkipVerify).To(BeTrue())
+		Expect(transport.DisableKeepAlives).To(BeTrue())
+
+		req, _ := http.NewRequest("GET", "http://10.0.0.6", nil)
+		Expect(transport.Proxy(req)).To(Equal(proxyURL))
+	})
+
+	It("returns an error when the proxy config is invalid", func() {
+		registry.Register("director", ClientProfile{ProxyConfig: &ProxyConfig{URL: "://bad"}})
+
+		_, err := registry.Client("director")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Building HTTP client 'director'"))
+	})
+
+	It("wraps the client with retries when MaxAttempts is set", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+
+		server.AppendHandlers(
+			ghttp.RespondWith(http.StatusServiceUnavailable, ""),
+			ghttp.RespondWith(http.StatusOK, "ok"),
+		)
+
+		registry.Register("blobstore", ClientProfile{MaxAttempts: 3})
+
+		httpClient, err := registry.HTTPClient("blobstore")
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := httpClient.Get(server.URL())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(readString(resp.Body)).To(Equal("ok"))
+		Expect(server.ReceivedRequests()).To(HaveLen(2))
+	})
+
+	Describe("DefaultClientRegistry", func() {
+		It("is used by the package level helpers", func() {
+			RegisterClientProfile("fake-profile", ClientProfile{Timeout: 5 * time.Second})
+
+			client, err := ClientForProfile("fake-profile")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.(*http.Client).Timeout).To(Equal(5 * time.Second))
+		})
+	})
+})
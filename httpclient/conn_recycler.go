@@ -0,0 +1,177 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// WithMaxConnAge closes pooled connections once they are older than maxAge.
+// Idle connections are closed as soon as they expire, connections in use
+// are closed after their current request.
+func WithMaxConnAge(maxAge time.Duration) ClientOption {
+	return func(client *http.Client) {
+		if recycler := installConnRecycler(client); recycler != nil {
+			recycler.maxAge = maxAge
+		}
+	}
+}
+
+// WithMaxConnRequests closes pooled connections after they served maxRequests requests.
+func WithMaxConnRequests(maxRequests int) ClientOption {
+	return func(client *http.Client) {
+		if recycler := installConnRecycler(client); recycler != nil {
+			recycler.maxRequests = maxRequests
+		}
+	}
+}
+
+// connRecycler re-establishes long lived keep-alive connections before
+// stale load balancer backends or NAT timeouts break them
+type connRecycler struct {
+	next        http.RoundTripper
+	transport   *http.Transport
+	dialContext DialContextFunc
+
+	maxAge      time.Duration
+	maxRequests int
+}
+
+func installConnRecycler(client *http.Client) *connRecycler {
+	if recycler, ok := client.Transport.(*connRecycler); ok {
+		return recycler
+	}
+
+	if wrapper, ok := client.Transport.(wrappingRoundTripper); ok {
+		next := wrapper.wrapped()
+		inner := &http.Client{Transport: *next}
+		recycler := installConnRecycler(inner)
+		*next = inner.Transport
+		return recycler
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return nil
+	}
+
+	recycler := &connRecycler{
+		next:        transport,
+		transport:   transport,
+		dialContext: transport.DialContext,
+	}
+	if recycler.dialContext == nil {
+		recycler.dialContext = (&net.Dialer{}).DialContext
+	}
+
+	transport.DialContext = recycler.dial
+	client.Transport = recycler
+
+	return recycler
+}
+
+func (r *connRecycler) RoundTrip(req *http.Request) (*http.Response, error) {
+	var conn *recycledConn
+	var tracedReq *http.Request
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn = recycledConnOf(info.Conn)
+			if conn != nil && conn.use(r.maxRequests) {
+				// makes the transport send Connection: close and drop the connection afterwards
+				tracedReq.Close = true
+			}
+		},
+		PutIdleConn: func(err error) {
+			if conn != nil && err == nil {
+				conn.release()
+			}
+		},
+	}
+
+	tracedReq = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	return r.next.RoundTrip(tracedReq)
+}
+
+func (r *connRecycler) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := r.dialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	// new connections are dialed for a request so they start out in use
+	recycled := &recycledConn{Conn: conn, inUse: true}
+	if r.maxAge > 0 {
+		recycled.timer = time.AfterFunc(r.maxAge, recycled.expire)
+	}
+
+	return recycled, nil
+}
+
+func recycledConnOf(conn net.Conn) *recycledConn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	recycled, _ := conn.(*recycledConn)
+
+	return recycled
+}
+
+type recycledConn struct {
+	net.Conn
+	timer *time.Timer
+
+	mu       sync.Mutex
+	requests int
+	inUse    bool
+	expired  bool
+}
+
+// use reports whether the connection has to be closed after the current request
+func (c *recycledConn) use(maxRequests int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inUse = true
+	c.requests++
+
+	return c.expired || (maxRequests > 0 && c.requests >= maxRequests)
+}
+
+func (c *recycledConn) release() {
+	c.mu.Lock()
+	c.inUse = false
+	expired := c.expired
+	c.mu.Unlock()
+
+	if expired {
+		c.Close()
+	}
+}
+
+// expire closes idle connections right away; the transport notices the
+// closed connection and removes it from its pool
+func (c *recycledConn) expire() {
+	c.mu.Lock()
+	c.expired = true
+	inUse := c.inUse
+	c.mu.Unlock()
+
+	if !inUse {
+		c.Close()
+	}
+}
+
+func (c *recycledConn) Close() error {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+
+	return c.Conn.Close()
+}
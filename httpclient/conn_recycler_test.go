@@ -0,0 +1,138 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("connection recycling", func() {
+	var (
+		server *httptest.Server
+
+		connStatesLock sync.Mutex
+		newConns       int
+		closedConns    int
+	)
+
+	BeforeEach(func() {
+		newConns, closedConns = 0, 0
+
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte("fake-body"))
+		}))
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			connStatesLock.Lock()
+			defer connStatesLock.Unlock()
+
+			switch state {
+			case http.StateNew:
+				newConns++
+			case http.StateClosed:
+				closedConns++
+			}
+		}
+		server.Start()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	connCounts := func() (int, int) {
+		connStatesLock.Lock()
+		defer connStatesLock.Unlock()
+		return newConns, closedConns
+	}
+
+	get := func(client Client) {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Body.Close()).To(Succeed())
+		Expect(string(body)).To(Equal("fake-body"))
+	}
+
+	It("reuses connections without recycling options", func() {
+		client := CreateKeepAliveDefaultClient(nil)
+
+		for i := 0; i < 5; i++ {
+			get(client)
+		}
+
+		Expect(connCounts()).To(Equal(1))
+	})
+
+	It("closes connections after MaxConnRequests requests", func() {
+		client := CreateKeepAliveDefaultClient(nil, WithMaxConnRequests(2))
+
+		for i := 0; i < 5; i++ {
+			get(client)
+		}
+
+		newConns, _ := connCounts()
+		Expect(newConns).To(Equal(3))
+		Eventually(func() int { _, closed := connCounts(); return closed }).Should(Equal(2))
+	})
+
+	It("closes idle connections once they are older than MaxConnAge", func() {
+		client := CreateKeepAliveDefaultClient(nil, WithMaxConnAge(100*time.Millisecond))
+
+		get(client)
+		get(client)
+		Expect(connCounts()).To(Equal(1))
+
+		Eventually(func() int { _, closed := connCounts(); return closed }).Should(Equal(1))
+
+		get(client)
+		newConns, _ := connCounts()
+		Expect(newConns).To(Equal(2))
+	})
+
+	It("keeps recycling when the dialer is replaced afterwards", func() {
+		var dialed int
+		dialer := &net.Dialer{}
+
+		client := CreateKeepAliveDefaultClient(nil,
+			WithMaxConnRequests(1),
+			WithDialContext(func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialed++
+				return dialer.DialContext(ctx, network, address)
+			}),
+		)
+
+		get(client)
+		get(client)
+
+		Expect(dialed).To(Equal(2))
+	})
+
+	It("is configured through client profiles", func() {
+		registry := NewClientRegistry(boshlog.NewLogger(boshlog.LevelNone))
+		registry.Register("recycling", ClientProfile{MaxConnRequests: 1})
+
+		client, err := registry.Client("recycling")
+		Expect(err).ToNot(HaveOccurred())
+
+		get(client)
+		get(client)
+
+		newConns, _ := connCounts()
+		Expect(newConns).To(Equal(2))
+	})
+})
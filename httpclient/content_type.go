@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// contentTypeSnippetSize bounds how much of an unexpected body is kept
+// in a ContentTypeError
+const contentTypeSnippetSize = 512
+
+type expectedContentTypeKey struct{}
+
+type expectedContentType struct {
+	mediaTypes []string
+	json       bool
+}
+
+func (e expectedContentType) matches(mediaType string) bool {
+	if e.json && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return true
+	}
+
+	for _, expected := range e.mediaTypes {
+		if mediaType == expected {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e expectedContentType) String() string {
+	if e.json {
+		return "application/json"
+	}
+	return strings.Join(e.mediaTypes, ", ")
+}
+
+// ContentTypeError is returned by HTTPClient for requests customized with
+// ExpectJSON or ExpectContentType when the response has another content type,
+// e.g. the HTML page of a captive portal or a proxy
+type ContentTypeError struct {
+	Endpoint    string
+	StatusCode  int
+	ContentType string
+	Expected    string
+
+	// BodySnippet is the beginning of the response body
+	BodySnippet string
+}
+
+func (e ContentTypeError) Error() string {
+	return fmt.Sprintf(
+		"Expected '%s' to respond with content type '%s' but got '%s' (status %d): %s",
+		e.Endpoint, e.Expected, e.ContentType, e.StatusCode, e.BodySnippet,
+	)
+}
+
+func IsContentTypeError(err error) bool {
+	var contentTypeErr ContentTypeError
+	return errors.As(err, &contentTypeErr)
+}
+
+// ExpectJSON customizes requests of HTTPClient to accept JSON and to fail
+// with a ContentTypeError when the response is not application/json or
+// a +json media type
+func ExpectJSON() func(*http.Request) {
+	return expectContentType(expectedContentType{json: true}, "application/json")
+}
+
+// ExpectContentType customizes requests of HTTPClient to fail with
+// a ContentTypeError when the response has none of the media types
+func ExpectContentType(mediaTypes ...string) func(*http.Request) {
+	normalized := make([]string, len(mediaTypes))
+	for i, mediaType := range mediaTypes {
+		normalized[i] = strings.ToLower(mediaType)
+	}
+
+	return expectContentType(expectedContentType{mediaTypes: normalized}, strings.Join(mediaTypes, ", "))
+}
+
+func expectContentType(expected expectedContentType, accept string) func(*http.Request) {
+	return func(req *http.Request) {
+		if req.Header.Get("Accept") == "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		*req = *req.WithContext(context.WithValue(req.Context(), expectedContentTypeKey{}, expected))
+	}
+}
+
+// checkContentType closes the response body when returning an error
+func checkContentType(req *http.Request, resp *http.Response, redactedEndpoint string) (*http.Response, error) {
+	expected, ok := req.Context().Value(expectedContentTypeKey{}).(expectedContentType)
+	if !ok || req.Method == "HEAD" || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && expected.matches(strings.ToLower(mediaType)) {
+		return resp, nil
+	}
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, contentTypeSnippetSize))
+	resp.Body.Close()
+
+	return nil, ContentTypeError{
+		Endpoint:    redactedEndpoint,
+		StatusCode:  resp.StatusCode,
+		ContentType: contentType,
+		Expected:    expected.String(),
+		BodySnippet: strings.ToValidUTF8(string(snippet), string(utf8.RuneError)),
+	}
+}
@@ -0,0 +1,118 @@
+package httpclient_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("ExpectJSON and ExpectContentType", func() {
+	var (
+		server     *ghttp.Server
+		httpClient *HTTPClient
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		httpClient = NewHTTPClient(&http.Client{}, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	respondWith := func(status int, contentType, body string) {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.RespondWith(status, body, http.Header{"Content-Type": []string{contentType}}),
+		))
+	}
+
+	It("passes JSON responses through and asks for JSON", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyHeaderKV("Accept", "application/json"),
+			ghttp.RespondWith(http.StatusOK, `{"state":"running"}`, http.Header{"Content-Type": []string{"application/json; charset=utf-8"}}),
+		))
+
+		resp, err := httpClient.GetCustomized(server.URL()+"/info", ExpectJSON())
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(body)).To(Equal(`{"state":"running"}`))
+	})
+
+	It("accepts +json media types", func() {
+		respondWith(http.StatusOK, "application/problem+json", `{}`)
+
+		resp, err := httpClient.GetCustomized(server.URL()+"/info", ExpectJSON())
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+	})
+
+	It("returns a ContentTypeError with the beginning of the body", func() {
+		respondWith(http.StatusOK, "text/html", "<html><body>Please log in to the hotel wifi</body></html>"+strings.Repeat("x", 1000))
+
+		_, err := httpClient.PostCustomized(server.URL()+"/tasks?token=secret", []byte("{}"), ExpectJSON())
+		Expect(IsContentTypeError(err)).To(BeTrue())
+
+		contentTypeErr := err.(ContentTypeError)
+		Expect(contentTypeErr.StatusCode).To(Equal(http.StatusOK))
+		Expect(contentTypeErr.ContentType).To(Equal("text/html"))
+		Expect(contentTypeErr.BodySnippet).To(HavePrefix("<html><body>Please log in"))
+		Expect(contentTypeErr.BodySnippet).To(HaveLen(512))
+		Expect(err.Error()).To(ContainSubstring("content type 'application/json' but got 'text/html' (status 200)"))
+		Expect(err.Error()).ToNot(ContainSubstring("secret"))
+	})
+
+	It("validates error responses too", func() {
+		respondWith(http.StatusBadGateway, "text/plain", "upstream unavailable")
+
+		_, err := httpClient.DeleteCustomized(server.URL()+"/vms/1", ExpectJSON())
+		Expect(err).To(MatchError(ContainSubstring("(status 502): upstream unavailable")))
+	})
+
+	It("does not validate responses without content", func() {
+		respondWith(http.StatusNoContent, "", "")
+
+		resp, err := httpClient.PutCustomized(server.URL()+"/vms/1", []byte("{}"), ExpectJSON())
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+	})
+
+	It("matches any of the given media types case-insensitively", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyHeaderKV("Accept", "application/x-yaml, text/yaml"),
+			ghttp.RespondWith(http.StatusOK, "---", http.Header{"Content-Type": []string{"Text/YAML"}}),
+		))
+		respondWith(http.StatusOK, "application/json", "{}")
+
+		resp, err := httpClient.GetCustomized(server.URL()+"/manifest", ExpectContentType("application/x-yaml", "text/yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		_, err = httpClient.GetCustomized(server.URL()+"/manifest", ExpectContentType("application/x-yaml", "text/yaml"))
+		Expect(err).To(MatchError(ContainSubstring("content type 'application/x-yaml, text/yaml' but got 'application/json'")))
+	})
+
+	It("keeps an Accept header set by the caller", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyHeaderKV("Accept", "application/vnd.bosh+json"),
+			ghttp.RespondWith(http.StatusOK, "{}", http.Header{"Content-Type": []string{"application/vnd.bosh+json"}}),
+		))
+
+		resp, err := httpClient.GetCustomized(server.URL()+"/info", func(req *http.Request) {
+			req.Header.Set("Accept", "application/vnd.bosh+json")
+			ExpectJSON()(req)
+		})
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+	})
+})
@@ -0,0 +1,272 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// WithCookieJar sends cookies from jar with every request and stores cookies
+// of every response in it, including those of redirects. Unlike
+// http.Client.Jar it does not add cookies to the request passed to Do, so
+// that retry clients do not send cookies of earlier attempts again.
+func WithCookieJar(jar http.CookieJar) ClientOption {
+	return func(client *http.Client) {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+
+		client.Transport = &cookieRoundTripper{next: next, jar: jar}
+	}
+}
+
+type cookieRoundTripper struct {
+	next http.RoundTripper
+	jar  http.CookieJar
+}
+
+func (t *cookieRoundTripper) wrapped() *http.RoundTripper {
+	return &t.next
+}
+
+func (t *cookieRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if cookies := t.jar.Cookies(req.URL); len(cookies) > 0 {
+		// RoundTrippers must not modify the request
+		req = req.Clone(req.Context())
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		t.jar.SetCookies(req.URL, cookies)
+	}
+
+	return resp, nil
+}
+
+// PersistentCookieJar is a cookie jar that keeps its cookies in a file
+// encrypted with crypto.EncryptStream, so that sessions survive restarts.
+// The file is written whenever a response sets cookies.
+type PersistentCookieJar struct {
+	fs   boshsys.FileSystem
+	path string
+	key  []byte
+
+	jar     *cookiejar.Jar
+	cookies map[string]persistedCookie
+	lock    sync.Mutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+type persistedCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// NewPersistentCookieJar loads cookies from path if it exists.
+// key has to be crypto.StreamKeySize bytes long.
+func NewPersistentCookieJar(fs boshsys.FileSystem, path string, key []byte, logger boshlog.Logger) (*PersistentCookieJar, error) {
+	if len(key) != boshcrypto.StreamKeySize {
+		return nil, bosherr.Errorf("Expected cookie jar key of %d bytes but got %d", boshcrypto.StreamKeySize, len(key))
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating cookie jar")
+	}
+
+	j := &PersistentCookieJar{
+		fs:      fs,
+		path:    path,
+		key:     key,
+		jar:     jar,
+		cookies: map[string]persistedCookie{},
+		logTag:  "PersistentCookieJar",
+		logger:  logger,
+	}
+
+	err = j.load()
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Loading cookies from '%s'", path)
+	}
+
+	return j, nil
+}
+
+func (j *PersistentCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+func (j *PersistentCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		j.remember(u, cookie, now)
+	}
+
+	err := j.save()
+	if err != nil {
+		j.logger.Warn(j.logTag, "Failed to save cookies to '%s': %s", j.path, err)
+	}
+}
+
+// remember keeps the latest cookie for each name, domain and path.
+// Relative expiry is converted to an absolute time so that it
+// does not start over when the cookie is loaded again.
+func (j *PersistentCookieJar) remember(u *url.URL, cookie *http.Cookie, now time.Time) {
+	c := *cookie
+
+	domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+	if domain == "" {
+		domain = strings.ToLower(u.Hostname())
+	}
+
+	path := c.Path
+	if path == "" || !strings.HasPrefix(path, "/") {
+		path = defaultCookiePath(u.Path)
+	}
+
+	key := domain + ";" + path + ";" + c.Name
+
+	switch {
+	case c.MaxAge < 0:
+		delete(j.cookies, key)
+		return
+	case c.MaxAge > 0:
+		c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		c.MaxAge = 0
+	case !c.Expires.IsZero() && !c.Expires.After(now):
+		delete(j.cookies, key)
+		return
+	}
+
+	c.Raw = ""
+	c.RawExpires = ""
+
+	j.cookies[key] = persistedCookie{URL: (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(), Cookie: &c}
+}
+
+func (j *PersistentCookieJar) load() error {
+	if !j.fs.FileExists(j.path) {
+		return nil
+	}
+
+	encrypted, err := j.fs.ReadFile(j.path)
+	if err != nil {
+		return err
+	}
+
+	reader, err := boshcrypto.DecryptStream(bytes.NewReader(encrypted), j.key)
+	if err != nil {
+		return err
+	}
+
+	var persisted []persistedCookie
+	err = json.NewDecoder(reader).Decode(&persisted)
+	if err != nil {
+		return bosherr.WrapError(err, "Decrypting cookies")
+	}
+
+	// the decoder may not have read up to the authenticated end of the stream
+	_, err = io.Copy(io.Discard, reader)
+	if err != nil {
+		return bosherr.WrapError(err, "Decrypting cookies")
+	}
+
+	now := time.Now()
+	for _, p := range persisted {
+		if p.Cookie == nil || (!p.Cookie.Expires.IsZero() && !p.Cookie.Expires.After(now)) {
+			continue
+		}
+
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			continue
+		}
+
+		j.jar.SetCookies(u, []*http.Cookie{p.Cookie})
+		j.remember(u, p.Cookie, now)
+	}
+
+	return nil
+}
+
+func (j *PersistentCookieJar) save() error {
+	persisted := make([]persistedCookie, 0, len(j.cookies))
+	for _, p := range j.cookies {
+		persisted = append(persisted, p)
+	}
+
+	var encrypted bytes.Buffer
+
+	writer, err := boshcrypto.EncryptStream(&encrypted, j.key)
+	if err != nil {
+		return err
+	}
+
+	err = json.NewEncoder(writer).Encode(persisted)
+	if err != nil {
+		return bosherr.WrapError(err, "Encoding cookies")
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return bosherr.WrapError(err, "Encrypting cookies")
+	}
+
+	tmpPath := j.path + ".tmp"
+
+	file, err := j.fs.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(encrypted.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing '%s'", tmpPath)
+	}
+
+	return j.fs.Rename(tmpPath, j.path)
+}
+
+// defaultCookiePath implements RFC 6265 section 5.1.4
+func defaultCookiePath(urlPath string) string {
+	if !strings.HasPrefix(urlPath, "/") {
+		return "/"
+	}
+
+	i := strings.LastIndex(urlPath, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return urlPath[:i]
+}
@@ -0,0 +1,211 @@
+package httpclient_test
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("cookie jars", func() {
+	var (
+		server *httptest.Server
+
+		receivedLock  sync.Mutex
+		received      []string
+		failFirst     bool
+		flakyAttempts int
+	)
+
+	BeforeEach(func() {
+		received = nil
+		failFirst = false
+		flakyAttempts = 0
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedLock.Lock()
+			received = append(received, r.URL.Path+" "+r.Header.Get("Cookie"))
+			if r.URL.Path == "/flaky" {
+				flakyAttempts++
+			}
+			attempt := flakyAttempts
+			receivedLock.Unlock()
+
+			switch r.URL.Path {
+			case "/login":
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "fake-session", Path: "/"})
+				http.Redirect(w, r, "/home", http.StatusFound)
+			case "/flaky":
+				if failFirst && attempt == 1 {
+					http.SetCookie(w, &http.Cookie{Name: "lb", Value: "fake-backend", Path: "/"})
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			case "/logout":
+				http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func(client Client, path string) {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+	}
+
+	Describe("WithCookieJar", func() {
+		var jar http.CookieJar
+
+		BeforeEach(func() {
+			var err error
+			jar, err = cookiejar.New(nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("sends cookies set by redirects to the redirect target", func() {
+			client := CreateDefaultClient(nil, WithCookieJar(jar))
+
+			get(client, "/login")
+			get(client, "/other")
+
+			Expect(received).To(Equal([]string{
+				"/login ",
+				"/home session=fake-session",
+				"/other session=fake-session",
+			}))
+		})
+
+		It("sends cookies once on every retry attempt", func() {
+			failFirst = true
+
+			client := NewRetryClient(
+				CreateDefaultClient(nil, WithCookieJar(jar)),
+				2, time.Millisecond, boshlog.NewLogger(boshlog.LevelNone),
+			)
+
+			get(client, "/login")
+			get(client, "/flaky")
+
+			Expect(received).To(Equal([]string{
+				"/login ",
+				"/home session=fake-session",
+				"/flaky session=fake-session",
+				"/flaky session=fake-session; lb=fake-backend",
+			}))
+		})
+
+		It("is configured through client profiles", func() {
+			registry := NewClientRegistry(boshlog.NewLogger(boshlog.LevelNone))
+			registry.Register("director", ClientProfile{CookieJar: jar, UserAgent: &UserAgent{Product: "bosh-cli"}})
+
+			client, err := registry.Client("director")
+			Expect(err).ToNot(HaveOccurred())
+
+			get(client, "/login")
+			Expect(received).To(ConsistOf("/login ", "/home session=fake-session"))
+		})
+	})
+
+	Describe("PersistentCookieJar", func() {
+		var (
+			fs     boshsys.FileSystem
+			path   string
+			key    []byte
+			logger boshlog.Logger
+		)
+
+		BeforeEach(func() {
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			fs = boshsys.NewOsFileSystem(logger)
+			path = filepath.Join(GinkgoT().TempDir(), "cookies")
+
+			var err error
+			key, err = boshcrypto.RandomBytes(boshcrypto.StreamKeySize)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("keeps cookies across jars", func() {
+			jar, err := NewPersistentCookieJar(fs, path, key, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			get(CreateDefaultClient(nil, WithCookieJar(jar)), "/login")
+
+			contents, err := os.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).ToNot(ContainSubstring("fake-session"))
+
+			info, err := os.Stat(path)
+			Expect(err).ToNot(HaveOccurred())
+			if info.Mode().Perm() != 0666 {
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			}
+
+			reloaded, err := NewPersistentCookieJar(fs, path, key, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			get(CreateDefaultClient(nil, WithCookieJar(reloaded)), "/other")
+			Expect(received).To(ContainElement("/other session=fake-session"))
+		})
+
+		It("forgets deleted and expired cookies", func() {
+			jar, err := NewPersistentCookieJar(fs, path, key, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			u, err := url.Parse(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			jar.SetCookies(u, []*http.Cookie{
+				{Name: "short", Value: "fake", Expires: time.Now().Add(50 * time.Millisecond)},
+				{Name: "long", Value: "fake", MaxAge: 3600},
+			})
+
+			get(CreateDefaultClient(nil, WithCookieJar(jar)), "/login")
+			get(CreateDefaultClient(nil, WithCookieJar(jar)), "/logout")
+
+			time.Sleep(100 * time.Millisecond)
+
+			reloaded, err := NewPersistentCookieJar(fs, path, key, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			cookies := reloaded.Cookies(u)
+			Expect(cookies).To(HaveLen(1))
+			Expect(cookies[0].Name).To(Equal("long"))
+		})
+
+		It("returns an error when the file was encrypted with another key", func() {
+			jar, err := NewPersistentCookieJar(fs, path, key, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			get(CreateDefaultClient(nil, WithCookieJar(jar)), "/login")
+
+			otherKey, err := boshcrypto.RandomBytes(boshcrypto.StreamKeySize)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = NewPersistentCookieJar(fs, path, otherKey, logger)
+			Expect(err).To(MatchError(ContainSubstring("Loading cookies from")))
+		})
+
+		It("rejects keys of the wrong size", func() {
+			_, err := NewPersistentCookieJar(fs, path, []byte("short"), logger)
+			Expect(err).To(MatchError(ContainSubstring("Expected cookie jar key of 32 bytes")))
+		})
+	})
+})
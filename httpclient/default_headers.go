@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// UserAgent builds User-Agent values like "bosh-agent/7.1.0 (linux; amd64)"
+// so that access logs show which component and version sent a request
+type UserAgent struct {
+	Product string
+	Version string
+
+	// Platform defaults to "<GOOS>; <GOARCH>"
+	Platform string
+
+	// Comments are appended to the platform, e.g. a deployment name
+	Comments []string
+}
+
+func (u UserAgent) String() string {
+	product := u.Product
+	if u.Version != "" {
+		product += "/" + u.Version
+	}
+
+	platform := u.Platform
+	if platform == "" {
+		platform = runtime.GOOS + "; " + runtime.GOARCH
+	}
+
+	return product + " (" + strings.Join(append([]string{platform}, u.Comments...), "; ") + ")"
+}
+
+// WithDefaultHeaders sets headers on every request that does not set them
+// itself, including requests sent again by retry clients
+func WithDefaultHeaders(defaults http.Header) ClientOption {
+	headers := http.Header{}
+	for name, values := range defaults {
+		headers[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+
+	return func(client *http.Client) {
+		if defaulter, ok := client.Transport.(*headersRoundTripper); ok {
+			for name, values := range headers {
+				defaulter.headers[name] = values
+			}
+			return
+		}
+
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+
+		client.Transport = &headersRoundTripper{next: next, headers: headers}
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests that do not set it
+func WithUserAgent(userAgent UserAgent) ClientOption {
+	return WithDefaultHeaders(http.Header{"User-Agent": []string{userAgent.String()}})
+}
+
+type headersRoundTripper struct {
+	next    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headersRoundTripper) wrapped() *http.RoundTripper {
+	return &t.next
+}
+
+func (t *headersRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var missing []string
+	for name := range t.headers {
+		if _, found := req.Header[name]; !found {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	for _, name := range missing {
+		req.Header[name] = t.headers[name]
+	}
+
+	return t.next.RoundTrip(req)
+}
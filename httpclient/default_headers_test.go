@@ -0,0 +1,132 @@
+package httpclient_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("default headers", func() {
+	var (
+		server *httptest.Server
+
+		receivedLock sync.Mutex
+		received     []http.Header
+		failFirst    bool
+	)
+
+	BeforeEach(func() {
+		received = nil
+		failFirst = false
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedLock.Lock()
+			received = append(received, r.Header.Clone())
+			fail := failFirst && len(received) == 1
+			receivedLock.Unlock()
+
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func(client Client, header http.Header) {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).ToNot(HaveOccurred())
+		for name, values := range header {
+			req.Header[name] = values
+		}
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+	}
+
+	Describe("UserAgent", func() {
+		It("includes product, version and platform", func() {
+			userAgent := UserAgent{Product: "bosh-agent", Version: "7.1.0", Comments: []string{"fake-deployment"}}
+			Expect(userAgent.String()).To(Equal("bosh-agent/7.1.0 (" + runtime.GOOS + "; " + runtime.GOARCH + "; fake-deployment)"))
+
+			userAgent = UserAgent{Product: "bosh-agent", Platform: "fake-platform"}
+			Expect(userAgent.String()).To(Equal("bosh-agent (fake-platform)"))
+		})
+	})
+
+	It("sets default headers and the User-Agent on requests that do not set them", func() {
+		client := CreateDefaultClient(nil,
+			WithDefaultHeaders(http.Header{"x-bosh-agent-id": []string{"fake-agent-id"}, "X-Other": []string{"default"}}),
+			WithUserAgent(UserAgent{Product: "bosh-agent", Version: "7.1.0", Platform: "linux"}),
+		)
+
+		get(client, http.Header{"X-Other": []string{"explicit"}})
+
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Get("X-Bosh-Agent-Id")).To(Equal("fake-agent-id"))
+		Expect(received[0].Get("X-Other")).To(Equal("explicit"))
+		Expect(received[0].Get("User-Agent")).To(Equal("bosh-agent/7.1.0 (linux)"))
+	})
+
+	It("sets headers on every retry attempt", func() {
+		failFirst = true
+
+		client := NewRetryClient(
+			CreateDefaultClient(nil, WithUserAgent(UserAgent{Product: "bosh-agent", Platform: "linux"})),
+			2, time.Millisecond, boshlog.NewLogger(boshlog.LevelNone),
+		)
+
+		get(client, nil)
+
+		Expect(received).To(HaveLen(2))
+		Expect(received[0].Get("User-Agent")).To(Equal("bosh-agent (linux)"))
+		Expect(received[1].Get("User-Agent")).To(Equal("bosh-agent (linux)"))
+	})
+
+	It("is configured through client profiles and combines with other options", func() {
+		registry := NewClientRegistry(boshlog.NewLogger(boshlog.LevelNone))
+		registry.Register("agent", ClientProfile{
+			UserAgent:       &UserAgent{Product: "bosh-agent", Platform: "linux"},
+			DefaultHeaders:  http.Header{"X-Bosh-Agent-Id": []string{"fake-agent-id"}},
+			GzipRequests:    &GzipRequestOpts{},
+			MaxConnRequests: 1,
+		})
+
+		client, err := registry.Client("agent")
+		Expect(err).ToNot(HaveOccurred())
+
+		get(client, nil)
+
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Get("User-Agent")).To(Equal("bosh-agent (linux)"))
+		Expect(received[0].Get("X-Bosh-Agent-Id")).To(Equal("fake-agent-id"))
+	})
+
+	It("does not prevent replacing the dialer", func() {
+		dialed := false
+		client := CreateDefaultClient(nil,
+			WithUserAgent(UserAgent{Product: "bosh-agent"}),
+			WithDialContext(func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialed = true
+				return (&net.Dialer{}).DialContext(ctx, network, address)
+			}),
+		)
+
+		get(client, nil)
+
+		Expect(dialed).To(BeTrue())
+	})
+})
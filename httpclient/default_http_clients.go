@@ -26,32 +26,32 @@ type Client interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
-func CreateDefaultClient(certPool *x509.CertPool) *http.Client {
+func CreateDefaultClient(certPool *x509.CertPool, opts ...ClientOption) *http.Client {
 	insecureSkipVerify := false
 	external := false
 	disableKeepAlives := true
-	return factory{}.New(insecureSkipVerify, external, disableKeepAlives, certPool)
+	return factory{}.New(insecureSkipVerify, external, disableKeepAlives, certPool, opts...)
 }
 
-func CreateExternalDefaultClient(certPool *x509.CertPool) *http.Client {
+func CreateExternalDefaultClient(certPool *x509.CertPool, opts ...ClientOption) *http.Client {
 	insecureSkipVerify := false
 	external := true
 	disableKeepAlives := true
-	return factory{}.New(insecureSkipVerify, external, disableKeepAlives, certPool)
+	return factory{}.New(insecureSkipVerify, external, disableKeepAlives, certPool, opts...)
 }
 
-func CreateKeepAliveDefaultClient(certPool *x509.CertPool) *http.Client {
+func CreateKeepAliveDefaultClient(certPool *x509.CertPool, opts ...ClientOption) *http.Client {
 	insecureSkipVerify := false
 	external := true
 	disableKeepAlives := false
-	return factory{}.New(insecureSkipVerify, external, disableKeepAlives, certPool)
+	return factory{}.New(insecureSkipVerify, external, disableKeepAlives, certPool, opts...)
 }
 
-func CreateDefaultClientInsecureSkipVerify() *http.Client {
+func CreateDefaultClientInsecureSkipVerify(opts ...ClientOption) *http.Client {
 	insecureSkipVerify := true
 	external := false
 	disableKeepAlives := true
-	return factory{}.New(insecureSkipVerify, external, disableKeepAlives, nil)
+	return factory{}.New(insecureSkipVerify, external, disableKeepAlives, nil, opts...)
 }
 
 func ResetDialerContext() {
@@ -63,7 +63,7 @@ func ResetDialerContext() {
 
 type factory struct{}
 
-func (f factory) New(insecureSkipVerify, externalClient bool, disableKeepAlives bool, certPool *x509.CertPool) *http.Client {
+func (f factory) New(insecureSkipVerify, externalClient bool, disableKeepAlives bool, certPool *x509.CertPool, opts ...ClientOption) *http.Client {
 	serviceDefaults := tlsconfig.WithInternalServiceDefaults()
 	if externalClient {
 		serviceDefaults = tlsconfig.WithExternalServiceDefaults()
@@ -88,7 +88,7 @@ func (f factory) New(insecureSkipVerify, externalClient bool, disableKeepAlives
 		},
 	}
 
-	return client
+	return applyClientOptions(client, opts)
 }
 
 func WithInsecureSkipVerify(insecureSkipVerify bool) tlsconfig.TLSOption {
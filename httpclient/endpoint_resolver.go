@@ -0,0 +1,284 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type EndpointResolverOpts struct {
+	// Endpoints are base URLs such as https://10.0.0.6:25555 tried in order
+	Endpoints []string
+
+	// SRVName is looked up (e.g. _director._tcp.bosh.internal) on first use
+	// and again every time all known endpoints failed; its targets follow
+	// Endpoints
+	SRVName string
+
+	// SRVScheme is the scheme of endpoints resolved from SRV records,
+	// defaults to https
+	SRVScheme string
+
+	// LookupSRV defaults to net.LookupSRV
+	LookupSRV func(service, proto, name string) (string, []*net.SRV, error)
+
+	// HealthCheck is called before failing over to an endpoint and
+	// defaults to a TCPCheck of its host with HealthCheckTimeout
+	HealthCheck        func(endpoint *url.URL) error
+	HealthCheckTimeout time.Duration
+}
+
+// EndpointResolver picks a healthy endpoint among several addresses of
+// the same service and keeps using it until a request to it fails
+type EndpointResolver struct {
+	opts EndpointResolverOpts
+
+	static    []*url.URL
+	endpoints []*url.URL
+	current   int
+	next      int
+	resolved  bool
+	lock      sync.Mutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewEndpointResolver(opts EndpointResolverOpts, logger boshlog.Logger) (*EndpointResolver, error) {
+	if len(opts.Endpoints) == 0 && opts.SRVName == "" {
+		return nil, bosherr.Error("Creating endpoint resolver: no endpoints or SRV name given")
+	}
+
+	if opts.SRVScheme == "" {
+		opts.SRVScheme = "https"
+	}
+	if opts.LookupSRV == nil {
+		opts.LookupSRV = net.LookupSRV
+	}
+	if opts.HealthCheckTimeout == 0 {
+		opts.HealthCheckTimeout = DefaultProbeTimeout
+	}
+	if opts.HealthCheck == nil {
+		opts.HealthCheck = func(endpoint *url.URL) error {
+			return TCPCheck(endpointAddress(endpoint), opts.HealthCheckTimeout)
+		}
+	}
+
+	var static []*url.URL
+
+	for _, endpoint := range opts.Endpoints {
+		parsed, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing endpoint '%s'", scrubEndpointQuery(endpoint))
+		}
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return nil, bosherr.Errorf("Endpoint '%s' must include scheme and host", scrubEndpointQuery(endpoint))
+		}
+		static = append(static, parsed)
+	}
+
+	return &EndpointResolver{
+		opts:      opts,
+		static:    static,
+		endpoints: static,
+		current:   -1,
+		logTag:    "endpointResolver",
+		logger:    logger,
+	}, nil
+}
+
+// Endpoint returns the endpoint in use, or fails over to the next healthy
+// endpoint when there is none
+func (r *EndpointResolver) Endpoint() (*url.URL, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.current < 0 {
+		err := r.failover()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	endpoint := *r.endpoints[r.current]
+	return &endpoint, nil
+}
+
+// Failed stops using endpoint so that the next call to Endpoint fails over
+func (r *EndpointResolver) Failed(endpoint *url.URL) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.current < 0 || r.endpoints[r.current].String() != endpoint.String() {
+		return
+	}
+
+	r.logger.Warn(r.logTag, "Endpoint '%s' failed", endpoint.Redacted())
+
+	r.next = r.current + 1
+	r.current = -1
+}
+
+// failover health checks endpoints starting after the last one that failed.
+// SRV records are resolved again once every endpoint was tried.
+func (r *EndpointResolver) failover() error {
+	var lastErr error
+
+	if !r.resolved {
+		lastErr = r.refresh()
+	}
+
+	for round := 0; round < 2; round++ {
+		for ; r.next < len(r.endpoints); r.next++ {
+			candidate := r.endpoints[r.next]
+
+			lastErr = r.opts.HealthCheck(candidate)
+			if lastErr == nil {
+				r.logger.Info(r.logTag, "Using endpoint '%s'", candidate.Redacted())
+				r.current = r.next
+				return nil
+			}
+
+			r.logger.Warn(r.logTag, "Skipping unhealthy endpoint '%s': %s", candidate.Redacted(), lastErr)
+		}
+
+		r.next = 0
+
+		err := r.refresh()
+		if err != nil {
+			lastErr = err
+		}
+	}
+
+	if lastErr == nil {
+		return bosherr.Error("No endpoints available")
+	}
+
+	return bosherr.WrapError(lastErr, "No healthy endpoint available")
+}
+
+func (r *EndpointResolver) refresh() error {
+	r.endpoints = r.static
+	r.resolved = true
+
+	if r.opts.SRVName == "" {
+		return nil
+	}
+
+	_, records, err := r.opts.LookupSRV("", "", r.opts.SRVName)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Looking up SRV records of '%s'", r.opts.SRVName)
+	}
+
+	endpoints := append([]*url.URL(nil), r.static...)
+
+	for _, record := range records {
+		endpoints = append(endpoints, &url.URL{
+			Scheme: r.opts.SRVScheme,
+			Host:   net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))),
+		})
+	}
+
+	r.endpoints = endpoints
+
+	return nil
+}
+
+func (r *EndpointResolver) count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return len(r.endpoints)
+}
+
+// WithEndpointResolver sends requests to the endpoint picked by resolver,
+// keeping their path below the path of the endpoint. Requests that fail
+// to connect are sent to the next healthy endpoint when their body can
+// be sent again.
+func WithEndpointResolver(resolver *EndpointResolver) ClientOption {
+	return func(client *http.Client) {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+
+		client.Transport = &endpointRoundTripper{next: next, resolver: resolver}
+	}
+}
+
+type endpointRoundTripper struct {
+	next     http.RoundTripper
+	resolver *EndpointResolver
+}
+
+func (t *endpointRoundTripper) wrapped() *http.RoundTripper {
+	return &t.next
+}
+
+func (t *endpointRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var lastErr error
+
+	// an SRV refresh can add endpoints, so allow one more attempt
+	attempts := t.resolver.count() + 1
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		endpoint, err := t.resolver.Endpoint()
+		if err != nil {
+			if lastErr != nil {
+				return nil, bosherr.WrapComplexError(lastErr, err)
+			}
+			return nil, err
+		}
+
+		outReq := req.Clone(req.Context())
+		outReq.Host = ""
+		outReq.URL.Scheme = endpoint.Scheme
+		outReq.URL.Host = endpoint.Host
+		outReq.URL.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + strings.TrimPrefix(req.URL.Path, "/")
+		outReq.URL.RawPath = ""
+
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, lastErr
+			}
+
+			outReq.Body, err = req.GetBody()
+			if err != nil {
+				return nil, bosherr.WrapError(err, "Rewinding request body")
+			}
+		}
+
+		resp, err := t.next.RoundTrip(outReq)
+		if err == nil {
+			return resp, nil
+		}
+
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+
+		t.resolver.Failed(endpoint)
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+func endpointAddress(endpoint *url.URL) string {
+	if endpoint.Port() != "" {
+		return endpoint.Host
+	}
+
+	if endpoint.Scheme == "http" {
+		return net.JoinHostPort(endpoint.Hostname(), "80")
+	}
+
+	return net.JoinHostPort(endpoint.Hostname(), "443")
+}
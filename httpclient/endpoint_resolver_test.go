@@ -0,0 +1,189 @@
+package httpclient_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("EndpointResolver", func() {
+	var (
+		serverA  *httptest.Server
+		serverB  *httptest.Server
+		deadAddr string
+		logger   boshlog.Logger
+	)
+
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Write([]byte(name + " " + r.URL.Path + " " + string(body)))
+		}))
+	}
+
+	get := func(client *http.Client, path string) string {
+		resp, err := client.Get("http://director" + path)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return string(body)
+	}
+
+	BeforeEach(func() {
+		serverA = newServer("a")
+		serverB = newServer("b")
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		deadAddr = listener.Addr().String()
+		listener.Close()
+	})
+
+	AfterEach(func() {
+		serverA.Close()
+		serverB.Close()
+	})
+
+	It("requires endpoints", func() {
+		_, err := NewEndpointResolver(EndpointResolverOpts{}, logger)
+		Expect(err).To(MatchError(ContainSubstring("no endpoints or SRV name given")))
+
+		_, err = NewEndpointResolver(EndpointResolverOpts{Endpoints: []string{"10.0.0.6:25555"}}, logger)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("skips unhealthy endpoints", func() {
+		resolver, err := NewEndpointResolver(EndpointResolverOpts{
+			Endpoints: []string{"http://" + deadAddr, serverB.URL},
+		}, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		endpoint, err := resolver.Endpoint()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoint.String()).To(Equal(serverB.URL))
+	})
+
+	It("fails when no endpoint is healthy", func() {
+		resolver, err := NewEndpointResolver(EndpointResolverOpts{
+			Endpoints:   []string{serverA.URL, serverB.URL},
+			HealthCheck: func(*url.URL) error { return errors.New("fake-unhealthy") },
+		}, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = resolver.Endpoint()
+		Expect(err).To(MatchError(ContainSubstring("No healthy endpoint available: fake-unhealthy")))
+	})
+
+	Describe("WithEndpointResolver", func() {
+		var healthChecks []string
+
+		newClient := func(endpoints ...string) *http.Client {
+			healthChecks = nil
+
+			resolver, err := NewEndpointResolver(EndpointResolverOpts{
+				Endpoints: endpoints,
+				HealthCheck: func(endpoint *url.URL) error {
+					healthChecks = append(healthChecks, endpoint.Host)
+					return nil
+				},
+			}, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			return CreateDefaultClient(nil, WithEndpointResolver(resolver))
+		}
+
+		It("sends requests below the path of the endpoint", func() {
+			client := newClient(serverA.URL + "/api/")
+
+			Expect(get(client, "/info")).To(Equal("a /api/info "))
+		})
+
+		It("fails over when connecting fails and sticks to the new endpoint", func() {
+			client := newClient("http://"+deadAddr, serverA.URL, serverB.URL)
+
+			Expect(get(client, "/info")).To(Equal("a /info "))
+			Expect(get(client, "/info")).To(Equal("a /info "))
+			Expect(healthChecks).To(Equal([]string{deadAddr, strings.TrimPrefix(serverA.URL, "http://")}))
+
+			serverA.Close()
+
+			Expect(get(client, "/info")).To(Equal("b /info "))
+			Expect(get(client, "/info")).To(Equal("b /info "))
+		})
+
+		It("sends request bodies again after failing over", func() {
+			client := newClient("http://"+deadAddr, serverB.URL)
+
+			resp, err := client.Post("http://director/deployments", "text/plain", strings.NewReader("manifest"))
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal("b /deployments manifest"))
+		})
+
+		It("returns the last error when every endpoint fails", func() {
+			client := newClient("http://" + deadAddr)
+
+			_, err := client.Get("http://director/info")
+			Expect(err).To(MatchError(ContainSubstring("connection refused")))
+		})
+	})
+
+	Describe("SRV records", func() {
+		It("resolves endpoints from SRV records and resolves them again once all failed", func() {
+			lookups := 0
+			targets := []*url.URL{mustParseURL(serverA.URL), mustParseURL(serverB.URL)}
+
+			resolver, err := NewEndpointResolver(EndpointResolverOpts{
+				SRVName:   "_director._tcp.bosh.internal",
+				SRVScheme: "http",
+				LookupSRV: func(service, proto, name string) (string, []*net.SRV, error) {
+					Expect(name).To(Equal("_director._tcp.bosh.internal"))
+
+					target := targets[lookups%len(targets)]
+					lookups++
+
+					port, err := strconv.Atoi(target.Port())
+					Expect(err).ToNot(HaveOccurred())
+
+					return "", []*net.SRV{{Target: target.Hostname() + ".", Port: uint16(port)}}, nil
+				},
+			}, logger)
+			Expect(err).ToNot(HaveOccurred())
+
+			endpoint, err := resolver.Endpoint()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint.String()).To(Equal(serverA.URL))
+			Expect(lookups).To(Equal(1))
+
+			resolver.Failed(endpoint)
+
+			endpoint, err = resolver.Endpoint()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint.String()).To(Equal(serverB.URL))
+			Expect(lookups).To(Equal(2))
+		})
+	})
+})
+
+func mustParseURL(rawURL string) *url.URL {
+	parsed, err := url.Parse(rawURL)
+	Expect(err).ToNot(HaveOccurred())
+	return parsed
+}
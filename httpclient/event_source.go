@@ -0,0 +1,313 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// Event is a single server-sent event
+type Event struct {
+	ID   string
+	Type string
+	Data string
+}
+
+type EventSourceOpts struct {
+	// LastEventID is sent with the first request to resume a previous subscription
+	LastEventID string
+
+	// MinRetryDelay and MaxRetryDelay bound the exponential backoff between
+	// reconnects. A retry field sent by the server replaces MinRetryDelay.
+	MinRetryDelay time.Duration
+	MaxRetryDelay time.Duration
+
+	// MaxAttempts limits consecutive failed connection attempts, 0 retries forever
+	MaxAttempts int
+
+	// HeartbeatTimeout reconnects when the server sends nothing, not even
+	// a comment, for this long. 0 disables the timeout.
+	HeartbeatTimeout time.Duration
+
+	// CustomizeRequest is called for every request, e.g. to add authorization
+	CustomizeRequest func(*http.Request)
+
+	// RandSource makes the jitter of reconnect delays reproducible,
+	// e.g. rand.NewSource(1) in tests. Defaults to the global source.
+	RandSource rand.Source
+
+	// OnBackoff is called with every reconnect delay before waiting
+	OnBackoff BackoffHook
+
+	// Clock times reconnects and heartbeats, defaults to the real clock
+	Clock clock.Clock
+}
+
+// EventSource follows a text/event-stream endpoint and reconnects with
+// Last-Event-ID whenever the stream breaks
+type EventSource struct {
+	client   Client
+	endpoint string
+	opts     EventSourceOpts
+
+	jitter *backoffJitter
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewEventSource(client Client, endpoint string, opts EventSourceOpts, logger boshlog.Logger) *EventSource {
+	if opts.MinRetryDelay == 0 {
+		opts.MinRetryDelay = time.Second
+	}
+	if opts.MaxRetryDelay == 0 {
+		opts.MaxRetryDelay = 30 * time.Second
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.NewClock()
+	}
+
+	return &EventSource{
+		client:   client,
+		endpoint: endpoint,
+		opts:     opts,
+		jitter:   newBackoffJitter(opts.RandSource),
+		logTag:   "eventSource",
+		logger:   logger,
+	}
+}
+
+// Subscribe calls handler for every received event until ctx is done,
+// the server answers with 204 No Content, a non retryable error occurs
+// or handler returns an error, which is returned unchanged.
+func (s *EventSource) Subscribe(ctx context.Context, handler func(Event) error) error {
+	b := newJitteredBackoff(s.opts.MinRetryDelay, s.opts.MaxRetryDelay, s.jitter, s.opts.OnBackoff)
+
+	stream := &eventStream{lastEventID: s.opts.LastEventID}
+	redactedEndpoint := scrubEndpointQuery(s.endpoint)
+
+	for attempt := 1; ; attempt++ {
+		s.logger.Debug(s.logTag, "Connecting to event stream '%s' (attempt #%d)", redactedEndpoint, attempt)
+
+		retry, err := s.follow(ctx, stream, handler)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !retry {
+			return err
+		}
+
+		if stream.received {
+			// the connection worked, so only count failures since then
+			stream.received = false
+			attempt = 0
+			b.Reset()
+		}
+
+		if s.opts.MaxAttempts > 0 && attempt >= s.opts.MaxAttempts {
+			return bosherr.WrapErrorf(err, "Following event stream after %d attempts", attempt)
+		}
+
+		if stream.retry > 0 && stream.retry != b.min {
+			b.min = stream.retry
+			if b.max < b.min {
+				b.max = b.min
+			}
+			b.Reset()
+		}
+
+		delay := b.Duration()
+		s.logger.Debug(s.logTag, "Event stream '%s' broke, reconnecting in %s: %s", redactedEndpoint, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.opts.Clock.After(delay):
+		}
+	}
+}
+
+// follow reads the stream until it breaks and reports whether to reconnect
+func (s *EventSource) follow(ctx context.Context, stream *eventStream, handler func(Event) error) (bool, error) {
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, "GET", s.endpoint, nil)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Creating event stream request")
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if stream.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", stream.lastEventID)
+	}
+
+	if s.opts.CustomizeRequest != nil {
+		s.opts.CustomizeRequest(req)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, bosherr.WrapError(scrubErrorOutput(err), "Performing event stream request")
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return false, nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout:
+		return true, bosherr.Errorf("Event stream responded with status '%s'", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return false, bosherr.Errorf("Event stream responded with status '%s'", resp.Status)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return false, bosherr.Errorf("Expected content type 'text/event-stream' but got '%s'", resp.Header.Get("Content-Type"))
+	}
+
+	var body io.Reader = resp.Body
+	if s.opts.HeartbeatTimeout > 0 {
+		heartbeat := newHeartbeatReader(resp.Body, s.opts.HeartbeatTimeout, s.opts.Clock, cancel)
+		defer heartbeat.stop()
+		body = heartbeat
+	}
+
+	err = stream.read(body, handler)
+	if err != nil {
+		if handlerErr, ok := err.(eventHandlerError); ok {
+			return false, handlerErr.err
+		}
+		if reqCtx.Err() != nil && ctx.Err() == nil {
+			return true, bosherr.Errorf("No data received within heartbeat timeout of %s", s.opts.HeartbeatTimeout)
+		}
+		return true, bosherr.WrapError(err, "Reading event stream")
+	}
+
+	return true, bosherr.Error("Event stream was closed by the server")
+}
+
+type eventHandlerError struct{ err error }
+
+func (e eventHandlerError) Error() string { return e.err.Error() }
+
+// eventStream keeps the state that survives reconnects
+type eventStream struct {
+	lastEventID string
+	retry       time.Duration
+	received    bool
+}
+
+func (s *eventStream) read(body io.Reader, handler func(Event) error) error {
+	reader := bufio.NewReader(body)
+
+	var event Event
+	var data []string
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// an incomplete event at the end of the stream is discarded
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if data != nil {
+				event.ID = s.lastEventID
+				event.Data = strings.Join(data, "\n")
+				if event.Type == "" {
+					event.Type = "message"
+				}
+
+				s.received = true
+
+				err = handler(event)
+				if err != nil {
+					return eventHandlerError{err}
+				}
+			}
+
+			event, data = Event{}, nil
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				s.lastEventID = value
+			}
+		case "retry":
+			if millis, err := strconv.ParseUint(value, 10, 63); err == nil {
+				s.retry = time.Duration(millis) * time.Millisecond
+			}
+		}
+	}
+}
+
+// heartbeatReader calls expire when no data was read for timeout
+type heartbeatReader struct {
+	reader  io.Reader
+	timer   clock.Timer
+	timeout time.Duration
+	stopCh  chan struct{}
+}
+
+func newHeartbeatReader(reader io.Reader, timeout time.Duration, timeService clock.Clock, expire func()) *heartbeatReader {
+	r := &heartbeatReader{
+		reader:  reader,
+		timer:   timeService.NewTimer(timeout),
+		timeout: timeout,
+		stopCh:  make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-r.timer.C():
+			expire()
+		case <-r.stopCh:
+		}
+	}()
+
+	return r
+}
+
+func (r *heartbeatReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *heartbeatReader) stop() {
+	r.timer.Stop()
+	close(r.stopCh)
+}
@@ -0,0 +1,243 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("EventSource", func() {
+	var (
+		server  *httptest.Server
+		handler func(w http.ResponseWriter, r *http.Request, attempt int)
+
+		requestsLock  sync.Mutex
+		lastEventIDs  []string
+		opts          EventSourceOpts
+		errStopEvents = errors.New("fake-stop")
+	)
+
+	BeforeEach(func() {
+		lastEventIDs = nil
+		opts = EventSourceOpts{MinRetryDelay: time.Millisecond, MaxRetryDelay: 10 * time.Millisecond}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsLock.Lock()
+			lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+			attempt := len(lastEventIDs)
+			requestsLock.Unlock()
+
+			Expect(r.Header.Get("Accept")).To(Equal("text/event-stream"))
+
+			handler(w, r, attempt)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	stream := func(w http.ResponseWriter, body string) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(body))
+	}
+
+	subscribe := func(max int) ([]Event, error) {
+		var events []Event
+
+		eventSource := NewEventSource(http.DefaultClient, server.URL, opts, boshlog.NewLogger(boshlog.LevelNone))
+		err := eventSource.Subscribe(context.Background(), func(event Event) error {
+			events = append(events, event)
+			if len(events) == max {
+				return errStopEvents
+			}
+			return nil
+		})
+
+		return events, err
+	}
+
+	It("parses events", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, _ int) {
+			stream(w, ": comment\n\ndata: first\r\n\nid: 1\nevent: task\ndata: line 1\ndata:line 2\n\ndata: no id\n\n")
+		}
+
+		events, err := subscribe(3)
+		Expect(err).To(Equal(errStopEvents))
+		Expect(events).To(Equal([]Event{
+			{Type: "message", Data: "first"},
+			{ID: "1", Type: "task", Data: "line 1\nline 2"},
+			{ID: "1", Type: "message", Data: "no id"},
+		}))
+	})
+
+	It("reconnects with the last event ID", func() {
+		opts.LastEventID = "0"
+
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			switch attempt {
+			case 1:
+				stream(w, "id: 1\ndata: a\n\nid: 2\ndata: b\n\ndata: incomplete")
+			case 2:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				stream(w, "id: 3\ndata: c\n\n")
+			}
+		}
+
+		events, err := subscribe(3)
+		Expect(err).To(Equal(errStopEvents))
+		Expect(events[2]).To(Equal(Event{ID: "3", Type: "message", Data: "c"}))
+		Expect(lastEventIDs).To(Equal([]string{"0", "2", "2"}))
+	})
+
+	It("stops when the server responds with 204 No Content", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			if attempt == 1 {
+				stream(w, "data: a\n\n")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		events, err := subscribe(0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events).To(HaveLen(1))
+	})
+
+	It("returns non retryable errors", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, _ int) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		_, err := subscribe(0)
+		Expect(err).To(MatchError(ContainSubstring("Event stream responded with status '404 Not Found'")))
+		Expect(lastEventIDs).To(HaveLen(1))
+
+		handler = func(w http.ResponseWriter, r *http.Request, _ int) {
+			w.Write([]byte("data: a\n\n"))
+		}
+
+		_, err = subscribe(0)
+		Expect(err).To(MatchError(ContainSubstring("Expected content type 'text/event-stream'")))
+	})
+
+	It("gives up after MaxAttempts consecutive failures", func() {
+		opts.MaxAttempts = 3
+
+		handler = func(w http.ResponseWriter, r *http.Request, _ int) {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+
+		_, err := subscribe(0)
+		Expect(err).To(MatchError(ContainSubstring("Following event stream after 3 attempts")))
+		Expect(lastEventIDs).To(HaveLen(3))
+	})
+
+	It("passes the reconnect delays seeded by RandSource to OnBackoff", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, _ int) {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+
+		schedule := func() []time.Duration {
+			var delays []time.Duration
+
+			opts.MaxAttempts = 4
+			opts.RandSource = rand.NewSource(7)
+			opts.OnBackoff = func(_ int, delay time.Duration) { delays = append(delays, delay) }
+
+			_, err := subscribe(0)
+			Expect(err).To(HaveOccurred())
+
+			return delays
+		}
+
+		delays := schedule()
+		Expect(delays).To(HaveLen(3))
+		Expect(schedule()).To(Equal(delays))
+	})
+
+	It("waits for reconnects and heartbeats on the given clock", func() {
+		fakeTimeService := fakeclock.NewFakeClock(time.Now())
+		opts.Clock = fakeTimeService
+		opts.MinRetryDelay = time.Hour
+		opts.MaxRetryDelay = time.Hour
+		opts.HeartbeatTimeout = time.Minute
+
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			if attempt == 1 {
+				stream(w, "id: 1\ndata: a\n\n")
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+				return
+			}
+			stream(w, "data: b\n\n")
+		}
+
+		type result struct {
+			events []Event
+			err    error
+		}
+		resultCh := make(chan result)
+		go func() {
+			events, err := subscribe(2)
+			resultCh <- result{events, err}
+		}()
+
+		// heartbeat timer of the first connection
+		fakeTimeService.WaitForWatcherAndIncrement(time.Minute)
+		// reconnect delay
+		fakeTimeService.WaitForWatcherAndIncrement(time.Hour)
+
+		var res result
+		Eventually(resultCh).Should(Receive(&res))
+		Expect(res.err).To(Equal(errStopEvents))
+		Expect(res.events[1].Data).To(Equal("b"))
+		Expect(lastEventIDs).To(Equal([]string{"", "1"}))
+	})
+
+	It("reconnects when no heartbeat is received", func() {
+		opts.HeartbeatTimeout = 50 * time.Millisecond
+
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			if attempt == 1 {
+				stream(w, "id: 1\ndata: a\n\n")
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+				return
+			}
+			stream(w, "data: b\n\n")
+		}
+
+		events, err := subscribe(2)
+		Expect(err).To(Equal(errStopEvents))
+		Expect(events[1].Data).To(Equal("b"))
+		Expect(lastEventIDs).To(Equal([]string{"", "1"}))
+	})
+
+	It("returns when the context is done", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, _ int) {
+			stream(w, ": heartbeat\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		eventSource := NewEventSource(http.DefaultClient, server.URL, opts, boshlog.NewLogger(boshlog.LevelNone))
+		err := eventSource.Subscribe(ctx, func(Event) error { return nil })
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	})
+})
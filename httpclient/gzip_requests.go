@@ -0,0 +1,127 @@
+package httpclient
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultGzipMinSize is used when GzipRequestOpts.MinSize is not set, smaller
+// bodies usually do not get smaller when compressed
+const DefaultGzipMinSize = 1024
+
+type GzipRequestOpts struct {
+	// MinSize is the smallest body that is compressed. Bodies of unknown
+	// length are always compressed.
+	MinSize int64
+
+	// Level defaults to gzip.DefaultCompression
+	Level int
+
+	// ExcludeHosts lists hosts (with or without port) that do not accept
+	// compressed request bodies
+	ExcludeHosts []string
+}
+
+// WithGzipRequests compresses request bodies and sets Content-Encoding: gzip.
+// Bodies are compressed while they are sent, requests that can be rewound
+// (see http.Request.GetBody) are compressed again for retries.
+func WithGzipRequests(opts GzipRequestOpts) ClientOption {
+	if opts.MinSize == 0 {
+		opts.MinSize = DefaultGzipMinSize
+	}
+	if opts.Level == 0 {
+		opts.Level = gzip.DefaultCompression
+	}
+
+	return func(client *http.Client) {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+
+		client.Transport = &gzipRoundTripper{next: next, opts: opts}
+	}
+}
+
+type gzipRoundTripper struct {
+	next http.RoundTripper
+	opts GzipRequestOpts
+}
+
+func (t *gzipRoundTripper) wrapped() *http.RoundTripper {
+	return &t.next
+}
+
+func (t *gzipRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.compresses(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	level := t.opts.Level
+
+	compressedReq := req.Clone(req.Context())
+	compressedReq.Header.Set("Content-Encoding", "gzip")
+	compressedReq.Header.Del("Content-Length")
+	compressedReq.ContentLength = -1
+	compressedReq.Body = gzipBody(req.Body, level)
+
+	if req.GetBody != nil {
+		compressedReq.GetBody = func() (io.ReadCloser, error) {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			return gzipBody(body, level), nil
+		}
+	}
+
+	return t.next.RoundTrip(compressedReq)
+}
+
+func (t *gzipRoundTripper) compresses(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return false
+	}
+
+	if req.Header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	if req.ContentLength > 0 && req.ContentLength < t.opts.MinSize {
+		return false
+	}
+
+	for _, host := range t.opts.ExcludeHosts {
+		if strings.EqualFold(host, req.URL.Host) || strings.EqualFold(host, req.URL.Hostname()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// gzipBody compresses body while it is read
+func gzipBody(body io.ReadCloser, level int) io.ReadCloser {
+	reader, writer := io.Pipe()
+
+	go func() {
+		defer body.Close()
+
+		gzipWriter, err := gzip.NewWriterLevel(writer, level)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		_, err = io.Copy(gzipWriter, body)
+		if err == nil {
+			err = gzipWriter.Close()
+		}
+
+		writer.CloseWithError(err)
+	}()
+
+	return reader
+}
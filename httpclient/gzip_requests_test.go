@@ -0,0 +1,156 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("gzip request compression", func() {
+	type receivedRequest struct {
+		encoding string
+		body     string
+	}
+
+	var (
+		server *httptest.Server
+
+		receivedLock sync.Mutex
+		received     []receivedRequest
+		failFirst    bool
+	)
+
+	BeforeEach(func() {
+		received = nil
+		failFirst = false
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body io.Reader = r.Body
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				gzipReader, err := gzip.NewReader(r.Body)
+				Expect(err).ToNot(HaveOccurred())
+				body = gzipReader
+			}
+
+			contents, err := io.ReadAll(body)
+			Expect(err).ToNot(HaveOccurred())
+
+			receivedLock.Lock()
+			received = append(received, receivedRequest{encoding: r.Header.Get("Content-Encoding"), body: string(contents)})
+			fail := failFirst && len(received) == 1
+			receivedLock.Unlock()
+
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	post := func(client Client, body io.Reader) *http.Response {
+		req, err := http.NewRequest("POST", server.URL, body)
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		return resp
+	}
+
+	largeBody := strings.Repeat(`{"state":"running"}`, 100)
+
+	It("compresses bodies above the threshold", func() {
+		client := CreateDefaultClient(nil, WithGzipRequests(GzipRequestOpts{MinSize: 1000}))
+
+		post(client, strings.NewReader(largeBody))
+		post(client, strings.NewReader("small"))
+
+		Expect(received).To(Equal([]receivedRequest{
+			{encoding: "gzip", body: largeBody},
+			{encoding: "", body: "small"},
+		}))
+	})
+
+	It("compresses bodies of unknown length", func() {
+		client := CreateDefaultClient(nil, WithGzipRequests(GzipRequestOpts{}))
+
+		post(client, io.MultiReader(strings.NewReader("small")))
+
+		Expect(received).To(Equal([]receivedRequest{{encoding: "gzip", body: "small"}}))
+	})
+
+	It("does not compress bodies for excluded hosts", func() {
+		serverURL, err := url.Parse(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		client := CreateDefaultClient(nil, WithGzipRequests(GzipRequestOpts{ExcludeHosts: []string{serverURL.Hostname()}}))
+
+		post(client, strings.NewReader(largeBody))
+
+		Expect(received).To(Equal([]receivedRequest{{encoding: "", body: largeBody}}))
+	})
+
+	It("does not compress bodies that are already encoded", func() {
+		client := CreateDefaultClient(nil, WithGzipRequests(GzipRequestOpts{}))
+
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		gzipWriter.Write([]byte(largeBody))
+		gzipWriter.Close()
+
+		req, err := http.NewRequest("POST", server.URL, &compressed)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Content-Encoding", "gzip")
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		Expect(received).To(Equal([]receivedRequest{{encoding: "gzip", body: largeBody}}))
+	})
+
+	It("compresses the body again when requests are retried", func() {
+		failFirst = true
+
+		client := NewRetryClient(
+			CreateDefaultClient(nil, WithGzipRequests(GzipRequestOpts{})),
+			2, time.Millisecond, boshlog.NewLogger(boshlog.LevelNone),
+		)
+
+		resp := post(client, strings.NewReader(largeBody))
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		Expect(received).To(Equal([]receivedRequest{
+			{encoding: "gzip", body: largeBody},
+			{encoding: "gzip", body: largeBody},
+		}))
+	})
+
+	It("is configured through client profiles and combines with other options", func() {
+		registry := NewClientRegistry(boshlog.NewLogger(boshlog.LevelNone))
+		registry.Register("gzip", ClientProfile{GzipRequests: &GzipRequestOpts{}, MaxConnRequests: 1})
+
+		client, err := registry.Client("gzip")
+		Expect(err).ToNot(HaveOccurred())
+
+		post(client, strings.NewReader(largeBody))
+
+		Expect(received).To(Equal([]receivedRequest{{encoding: "gzip", body: largeBody}}))
+	})
+})
@@ -70,7 +70,7 @@ func (c *HTTPClient) PostCustomized(endpoint string, payload []byte, f func(*htt
 		return nil, bosherr.WrapError(scrubErrorOutput(err), "Performing POST request")
 	}
 
-	return response, nil
+	return checkContentType(request, response, redactedEndpoint)
 }
 
 func (c *HTTPClient) Put(endpoint string, payload []byte) (*http.Response, error) {
@@ -102,7 +102,7 @@ func (c *HTTPClient) PutCustomized(endpoint string, payload []byte, f func(*http
 		return nil, bosherr.WrapError(scrubErrorOutput(err), "Performing PUT request")
 	}
 
-	return response, nil
+	return checkContentType(request, response, redactedEndpoint)
 }
 
 func (c *HTTPClient) Get(endpoint string) (*http.Response, error) {
@@ -132,7 +132,7 @@ func (c *HTTPClient) GetCustomized(endpoint string, f func(*http.Request)) (*htt
 		return nil, bosherr.WrapError(scrubErrorOutput(err), "Performing GET request")
 	}
 
-	return response, nil
+	return checkContentType(request, response, redactedEndpoint)
 }
 
 func (c *HTTPClient) Delete(endpoint string) (*http.Response, error) {
@@ -161,7 +161,8 @@ func (c *HTTPClient) DeleteCustomized(endpoint string, f func(*http.Request)) (*
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Performing DELETE request")
 	}
-	return response, nil
+
+	return checkContentType(request, response, redactedEndpoint)
 }
 
 var scrubUserinfoRegex = regexp.MustCompile("(https?://.*:).*@")
@@ -0,0 +1,271 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// LongPollEvent is a single event of a long poll response. Cursor is the
+// cursor returned with the response and resumes polling after it.
+type LongPollEvent struct {
+	Cursor string
+	Data   json.RawMessage
+}
+
+// LongPollResponse is the decoded body of a successful long poll request
+type LongPollResponse struct {
+	Cursor string            `json:"cursor"`
+	Events []json.RawMessage `json:"events"`
+}
+
+type LongPollOpts struct {
+	// Cursor is sent with the first request to resume a previous poll
+	Cursor string
+
+	// CursorParam is the query parameter carrying the cursor, defaults to "cursor"
+	CursorParam string
+
+	// PollTimeout ends a request that got no response for this long and
+	// polls again right away, as if the server answered with 204 No Content.
+	// 0 leaves it to the server to end the request.
+	PollTimeout time.Duration
+
+	// MinRetryDelay and MaxRetryDelay bound the jittered exponential
+	// backoff between reconnects after failed requests
+	MinRetryDelay time.Duration
+	MaxRetryDelay time.Duration
+
+	// MaxAttempts limits consecutive failed requests, 0 retries forever
+	MaxAttempts int
+
+	// Decode reads a 200 OK response body, defaults to decoding a
+	// LongPollResponse from JSON
+	Decode func(io.Reader) (LongPollResponse, error)
+
+	// CustomizeRequest is called for every request, e.g. to add authorization
+	CustomizeRequest func(*http.Request)
+
+	// RandSource makes the jitter of reconnect delays reproducible,
+	// e.g. rand.NewSource(1) in tests. Defaults to the global source.
+	RandSource rand.Source
+
+	// OnBackoff is called with every reconnect delay before waiting
+	OnBackoff BackoffHook
+
+	// Clock times reconnects, defaults to the real clock
+	Clock clock.Clock
+}
+
+// LongPoll repeatedly GETs an endpoint that holds requests open until
+// events are available. Requests go through client, so its proxy settings
+// apply to every reconnect; idle connections are dropped after failures so
+// that reconnects do not reuse a broken connection.
+type LongPoll struct {
+	client   Client
+	endpoint string
+	opts     LongPollOpts
+
+	jitter *backoffJitter
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewLongPoll(client Client, endpoint string, opts LongPollOpts, logger boshlog.Logger) *LongPoll {
+	if opts.CursorParam == "" {
+		opts.CursorParam = "cursor"
+	}
+	if opts.MinRetryDelay == 0 {
+		opts.MinRetryDelay = time.Second
+	}
+	if opts.MaxRetryDelay == 0 {
+		opts.MaxRetryDelay = 30 * time.Second
+	}
+	if opts.Decode == nil {
+		opts.Decode = decodeLongPollResponse
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.NewClock()
+	}
+
+	return &LongPoll{
+		client:   client,
+		endpoint: endpoint,
+		opts:     opts,
+		jitter:   newBackoffJitter(opts.RandSource),
+		logTag:   "longPoll",
+		logger:   logger,
+	}
+}
+
+// Poll calls handler for every received event until ctx is done, a non
+// retryable error occurs or handler returns an error, which is returned unchanged.
+func (p *LongPoll) Poll(ctx context.Context, handler func(LongPollEvent) error) error {
+	b := newJitteredBackoff(p.opts.MinRetryDelay, p.opts.MaxRetryDelay, p.jitter, p.opts.OnBackoff)
+
+	cursor := p.opts.Cursor
+	redactedEndpoint := scrubEndpointQuery(p.endpoint)
+
+	for attempt := 1; ; attempt++ {
+		resp, retry, err := p.request(ctx, cursor)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err == nil {
+			attempt = 0
+			b.Reset()
+
+			for _, data := range resp.Events {
+				err = handler(LongPollEvent{Cursor: resp.Cursor, Data: data})
+				if err != nil {
+					return err
+				}
+			}
+
+			if resp.Cursor != "" {
+				cursor = resp.Cursor
+			}
+			continue
+		}
+
+		if !retry {
+			return err
+		}
+
+		if p.opts.MaxAttempts > 0 && attempt >= p.opts.MaxAttempts {
+			return bosherr.WrapErrorf(err, "Long polling after %d attempts", attempt)
+		}
+
+		p.closeIdleConnections()
+
+		delay := b.Duration()
+		p.logger.Debug(p.logTag, "Long poll of '%s' failed, reconnecting in %s: %s", redactedEndpoint, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.opts.Clock.After(delay):
+		}
+	}
+}
+
+// Events polls in the background and sends the received events on the
+// returned channel until ctx is done. When polling stops for another
+// reason the error is sent on the error channel. Both channels are
+// closed once polling stopped.
+func (p *LongPoll) Events(ctx context.Context) (<-chan LongPollEvent, <-chan error) {
+	eventsCh := make(chan LongPollEvent)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(errCh)
+		defer close(eventsCh)
+
+		err := p.Poll(ctx, func(event LongPollEvent) error {
+			select {
+			case eventsCh <- event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			errCh <- err
+		}
+	}()
+
+	return eventsCh, errCh
+}
+
+// request performs a single poll and reports whether a failure may be retried.
+// Empty polls and requests that hit PollTimeout return an empty response.
+func (p *LongPoll) request(ctx context.Context, cursor string) (LongPollResponse, bool, error) {
+	endpoint, err := url.Parse(p.endpoint)
+	if err != nil {
+		return LongPollResponse{}, false, bosherr.WrapError(err, "Parsing long poll endpoint")
+	}
+
+	if cursor != "" {
+		query := endpoint.Query()
+		query.Set(p.opts.CursorParam, cursor)
+		endpoint.RawQuery = query.Encode()
+	}
+
+	reqCtx := ctx
+	if p.opts.PollTimeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, p.opts.PollTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(reqCtx, "GET", endpoint.String(), nil)
+	if err != nil {
+		return LongPollResponse{}, false, bosherr.WrapError(err, "Creating long poll request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if p.opts.CustomizeRequest != nil {
+		p.opts.CustomizeRequest(req)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if p.pollTimedOut(ctx, reqCtx) {
+			return LongPollResponse{}, false, nil
+		}
+		return LongPollResponse{}, true, bosherr.WrapError(scrubErrorOutput(err), "Performing long poll request")
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent, resp.StatusCode == http.StatusNotModified:
+		return LongPollResponse{}, false, nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout:
+		return LongPollResponse{}, true, bosherr.Errorf("Long poll responded with status '%s'", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return LongPollResponse{}, false, bosherr.Errorf("Long poll responded with status '%s'", resp.Status)
+	}
+
+	pollResp, err := p.opts.Decode(resp.Body)
+	if err != nil {
+		if p.pollTimedOut(ctx, reqCtx) {
+			return LongPollResponse{}, false, nil
+		}
+		return LongPollResponse{}, true, bosherr.WrapError(err, "Decoding long poll response")
+	}
+
+	return pollResp, false, nil
+}
+
+func (p *LongPoll) pollTimedOut(ctx, reqCtx context.Context) bool {
+	return ctx.Err() == nil && errors.Is(reqCtx.Err(), context.DeadlineExceeded)
+}
+
+func (p *LongPoll) closeIdleConnections() {
+	if closer, ok := p.client.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+func decodeLongPollResponse(body io.Reader) (LongPollResponse, error) {
+	var resp LongPollResponse
+	err := json.NewDecoder(body).Decode(&resp)
+	if err == io.EOF {
+		return LongPollResponse{}, nil
+	}
+	return resp, err
+}
@@ -0,0 +1,245 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("LongPoll", func() {
+	var (
+		server  *httptest.Server
+		handler func(w http.ResponseWriter, r *http.Request, attempt int)
+
+		requestsLock sync.Mutex
+		cursors      []string
+		opts         LongPollOpts
+		errStopPoll  = errors.New("fake-stop")
+	)
+
+	BeforeEach(func() {
+		cursors = nil
+		opts = LongPollOpts{MinRetryDelay: time.Millisecond, MaxRetryDelay: 10 * time.Millisecond}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestsLock.Lock()
+			cursors = append(cursors, r.URL.Query().Get("cursor"))
+			attempt := len(cursors)
+			requestsLock.Unlock()
+
+			handler(w, r, attempt)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	requestedCursors := func() []string {
+		requestsLock.Lock()
+		defer requestsLock.Unlock()
+		return append([]string(nil), cursors...)
+	}
+
+	poll := func(max int) ([]string, error) {
+		var events []string
+
+		longPoll := NewLongPoll(http.DefaultClient, server.URL, opts, boshlog.NewLogger(boshlog.LevelNone))
+		err := longPoll.Poll(context.Background(), func(event LongPollEvent) error {
+			events = append(events, event.Cursor+":"+string(event.Data))
+			if len(events) == max {
+				return errStopPoll
+			}
+			return nil
+		})
+
+		return events, err
+	}
+
+	It("delivers events and polls again with the returned cursor", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			switch attempt {
+			case 1:
+				w.Write([]byte(`{"cursor":"c1","events":[{"a":1},{"a":2}]}`))
+			default:
+				w.Write([]byte(`{"cursor":"c2","events":["b"]}`))
+			}
+		}
+
+		opts.Cursor = "c0"
+
+		events, err := poll(3)
+		Expect(err).To(Equal(errStopPoll))
+		Expect(events).To(Equal([]string{`c1:{"a":1}`, `c1:{"a":2}`, `c2:"b"`}))
+		Expect(requestedCursors()).To(Equal([]string{"c0", "c1"}))
+	})
+
+	It("polls again with the same cursor when there are no events", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			if attempt < 3 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Write([]byte(`{"cursor":"c1","events":["a"]}`))
+		}
+
+		opts.Cursor = "c0"
+
+		events, err := poll(1)
+		Expect(err).To(Equal(errStopPoll))
+		Expect(events).To(Equal([]string{`c1:"a"`}))
+		Expect(requestedCursors()).To(Equal([]string{"c0", "c0", "c0"}))
+	})
+
+	It("polls again when a request hits the poll timeout", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			if attempt == 1 {
+				<-r.Context().Done()
+				return
+			}
+			w.Write([]byte(`{"cursor":"c1","events":["a"]}`))
+		}
+
+		opts.PollTimeout = 50 * time.Millisecond
+
+		events, err := poll(1)
+		Expect(err).To(Equal(errStopPoll))
+		Expect(events).To(Equal([]string{`c1:"a"`}))
+		Expect(requestedCursors()).To(HaveLen(2))
+	})
+
+	It("uses a custom cursor parameter and decoder", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			Expect(r.URL.Query().Get("since")).To(Equal("c0"))
+			w.Write([]byte("ignored"))
+		}
+
+		opts.Cursor = "c0"
+		opts.CursorParam = "since"
+		opts.Decode = func(_ io.Reader) (LongPollResponse, error) {
+			return LongPollResponse{Cursor: "c1", Events: []json.RawMessage{json.RawMessage(`"a"`)}}, nil
+		}
+
+		events, err := poll(1)
+		Expect(err).To(Equal(errStopPoll))
+		Expect(events).To(Equal([]string{`c1:"a"`}))
+	})
+
+	It("reconnects after server errors", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			if attempt < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`{"cursor":"c1","events":["a"]}`))
+		}
+
+		events, err := poll(1)
+		Expect(err).To(Equal(errStopPoll))
+		Expect(events).To(HaveLen(1))
+		Expect(requestedCursors()).To(HaveLen(3))
+	})
+
+	It("gives up after MaxAttempts consecutive failures", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		opts.MaxAttempts = 3
+
+		_, err := poll(1)
+		Expect(err).To(MatchError(ContainSubstring("Long polling after 3 attempts")))
+		Expect(err).To(MatchError(ContainSubstring("503 Service Unavailable")))
+		Expect(requestedCursors()).To(HaveLen(3))
+	})
+
+	It("computes the same reconnect delays for the same RandSource seed", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		schedule := func() []time.Duration {
+			var delays []time.Duration
+
+			opts.MaxAttempts = 5
+			opts.RandSource = rand.NewSource(42)
+			opts.OnBackoff = func(attempt int, delay time.Duration) {
+				Expect(attempt).To(Equal(len(delays) + 1))
+				delays = append(delays, delay)
+			}
+
+			_, err := poll(1)
+			Expect(err).To(HaveOccurred())
+
+			return delays
+		}
+
+		delays := schedule()
+		Expect(delays).To(HaveLen(4))
+		for _, delay := range delays {
+			Expect(delay).To(BeNumerically(">=", opts.MinRetryDelay))
+			Expect(delay).To(BeNumerically("<=", opts.MaxRetryDelay))
+		}
+
+		Expect(schedule()).To(Equal(delays))
+	})
+
+	It("does not retry client errors", func() {
+		handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		_, err := poll(1)
+		Expect(err).To(MatchError(ContainSubstring("Long poll responded with status '404 Not Found'")))
+		Expect(requestedCursors()).To(HaveLen(1))
+	})
+
+	Describe("Events", func() {
+		It("sends events on the channel until the context is done", func() {
+			handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.Write([]byte(`{"cursor":"c1","events":["a"]}`))
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			longPoll := NewLongPoll(http.DefaultClient, server.URL, opts, boshlog.NewLogger(boshlog.LevelNone))
+			eventsCh, errCh := longPoll.Events(ctx)
+
+			var event LongPollEvent
+			Eventually(eventsCh).Should(Receive(&event))
+			Expect(string(event.Data)).To(Equal(`"a"`))
+
+			cancel()
+
+			Eventually(eventsCh).Should(BeClosed())
+			Eventually(errCh).Should(BeClosed())
+		})
+
+		It("sends the error that stopped polling", func() {
+			handler = func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.WriteHeader(http.StatusForbidden)
+			}
+
+			longPoll := NewLongPoll(http.DefaultClient, server.URL, opts, boshlog.NewLogger(boshlog.LevelNone))
+			eventsCh, errCh := longPoll.Events(context.Background())
+
+			var err error
+			Eventually(errCh).Should(Receive(&err))
+			Expect(err).To(MatchError(ContainSubstring("403 Forbidden")))
+			Eventually(eventsCh).Should(BeClosed())
+		})
+	})
+})
@@ -10,7 +10,7 @@ import (
 	"github.com/pivotal-cf/paraphernalia/secure/tlsconfig"
 )
 
-func NewMutualTLSClient(identity tls.Certificate, caCertPool *x509.CertPool, serverName string) *http.Client {
+func NewMutualTLSClient(identity tls.Certificate, caCertPool *x509.CertPool, serverName string, opts ...ClientOption) *http.Client {
 	tlsConfig := tlsconfig.Build(
 		tlsconfig.WithIdentity(identity),
 		tlsconfig.WithInternalServiceDefaults(),
@@ -20,7 +20,7 @@ func NewMutualTLSClient(identity tls.Certificate, caCertPool *x509.CertPool, ser
 	clientConfig.BuildNameToCertificate()
 	clientConfig.ServerName = serverName
 
-	return &http.Client{
+	client := &http.Client{
 		Transport: &http.Transport{
 			DialContext: (&net.Dialer{
 				Timeout:   30 * time.Second,
@@ -35,4 +35,6 @@ func NewMutualTLSClient(identity tls.Certificate, caCertPool *x509.CertPool, ser
 		},
 		Timeout: 10 * time.Second,
 	}
+
+	return applyClientOptions(client, opts)
 }
@@ -0,0 +1,147 @@
+package httpclient
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// NoProxy decides which hosts are dialed directly instead of through
+// BOSH_ALL_PROXY. Entries are separated by commas or whitespace and follow
+// curl and Go conventions:
+//
+//   - "*" matches every host
+//   - "example.com", ".example.com" and "*.example.com" match example.com
+//     and all of its subdomains
+//   - "10.0.0.1", "::1" and "[::1]" match that IP address
+//   - "10.0.0.0/8" and "fd00::/8" match all IP addresses in the block
+//   - any of the above followed by ":port", e.g. "example.com:8443" or
+//     "[::1]:8443", only matches connections to that port
+//
+// Names are compared case-insensitively and never match IP addresses.
+type NoProxy struct {
+	all     bool
+	entries []noProxyEntry
+}
+
+type noProxyEntry struct {
+	domain string
+	ip     net.IP
+	ipNet  *net.IPNet
+	port   string
+}
+
+func ParseNoProxy(noProxy string) NoProxy {
+	var result NoProxy
+
+	fields := strings.FieldsFunc(noProxy, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+
+	for _, field := range fields {
+		field = strings.ToLower(field)
+
+		if field == "*" {
+			result.all = true
+			continue
+		}
+
+		if entry, ok := parseNoProxyEntry(field); ok {
+			result.entries = append(result.entries, entry)
+		}
+	}
+
+	return result
+}
+
+// NoProxyFromEnvironment parses NO_PROXY, or no_proxy when it is not set
+func NoProxyFromEnvironment() NoProxy {
+	noProxy := os.Getenv("NO_PROXY")
+	if len(noProxy) == 0 {
+		noProxy = os.Getenv("no_proxy")
+	}
+
+	return ParseNoProxy(noProxy)
+}
+
+func parseNoProxyEntry(field string) (noProxyEntry, bool) {
+	var entry noProxyEntry
+
+	if _, ipNet, err := net.ParseCIDR(field); err == nil {
+		entry.ipNet = ipNet
+		return entry, true
+	}
+
+	host, port := splitNoProxyHostPort(field)
+	entry.port = port
+
+	if _, ipNet, err := net.ParseCIDR(host); err == nil {
+		entry.ipNet = ipNet
+		return entry, true
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		entry.ip = ip
+		return entry, true
+	}
+
+	host = strings.TrimPrefix(host, "*")
+	host = strings.TrimPrefix(host, ".")
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return entry, false
+	}
+
+	entry.domain = host
+
+	return entry, true
+}
+
+// Matches reports whether host, optionally with a port, is dialed directly
+func (n NoProxy) Matches(host string) bool {
+	if n.all {
+		return true
+	}
+
+	host, port := splitNoProxyHostPort(strings.ToLower(host))
+	host = strings.TrimSuffix(host, ".")
+
+	if i := strings.IndexByte(host, '%'); i != -1 {
+		host = host[:i]
+	}
+
+	ip := net.ParseIP(host)
+
+	for _, entry := range n.entries {
+		if entry.port != "" && entry.port != port {
+			continue
+		}
+
+		switch {
+		case entry.ipNet != nil:
+			if ip != nil && entry.ipNet.Contains(ip) {
+				return true
+			}
+		case entry.ip != nil:
+			if ip != nil && entry.ip.Equal(ip) {
+				return true
+			}
+		default:
+			if ip == nil && (host == entry.domain || strings.HasSuffix(host, "."+entry.domain)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// splitNoProxyHostPort accepts hosts without ports
+// and IPv6 addresses with or without brackets
+func splitNoProxyHostPort(hostPort string) (string, string) {
+	if host, port, err := net.SplitHostPort(hostPort); err == nil {
+		return host, port
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]"), ""
+}
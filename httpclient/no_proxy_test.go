@@ -0,0 +1,99 @@
+package httpclient_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+)
+
+var _ = Describe("NoProxy", func() {
+	expectMatches := func(noProxy string, matching []string, notMatching []string) {
+		parsed := ParseNoProxy(noProxy)
+
+		for _, host := range matching {
+			Expect(parsed.Matches(host)).To(BeTrue(), "expected '%s' to match '%s'", noProxy, host)
+		}
+
+		for _, host := range notMatching {
+			Expect(parsed.Matches(host)).To(BeFalse(), "expected '%s' not to match '%s'", noProxy, host)
+		}
+	}
+
+	It("matches nothing when empty", func() {
+		expectMatches("", nil, []string{"example.com", "10.0.0.1", "[::1]:80"})
+	})
+
+	It("matches everything with *", func() {
+		expectMatches("foo.com, *", []string{"example.com", "10.0.0.1:22", "[::1]:80"}, nil)
+	})
+
+	It("matches names and their subdomains", func() {
+		expectMatches("example.com",
+			[]string{"example.com", "example.com:443", "api.example.com", "a.b.example.com:80", "EXAMPLE.com", "example.com."},
+			[]string{"badexample.com", "example.com.evil.org", "com", "93.184.216.34"},
+		)
+	})
+
+	It("treats leading dots and wildcards like plain names", func() {
+		for _, noProxy := range []string{".example.com", "*.example.com"} {
+			expectMatches(noProxy,
+				[]string{"example.com", "api.example.com:443"},
+				[]string{"badexample.com"},
+			)
+		}
+	})
+
+	It("matches IP literals", func() {
+		expectMatches("10.0.0.1, ::1, [fd00::2]",
+			[]string{"10.0.0.1", "10.0.0.1:25555", "[::1]:8080", "::1", "[fd00::2]:22", "fd00:0::2"},
+			[]string{"10.0.0.2", "[::2]:8080", "fd00::3"},
+		)
+	})
+
+	It("matches CIDR blocks", func() {
+		expectMatches("10.0.0.0/8,fd00::/8",
+			[]string{"10.1.2.3:443", "10.255.255.255", "[fd12::1]:443", "fd12::1"},
+			[]string{"11.0.0.1", "[fe80::1]:443", "10.example.com"},
+		)
+	})
+
+	It("only matches the port of port-qualified entries", func() {
+		expectMatches("example.com:8443, 10.0.0.1:22, [::1]:8080, 192.168.0.0/16:443",
+			[]string{"example.com:8443", "api.example.com:8443", "10.0.0.1:22", "[::1]:8080", "192.168.1.1:443"},
+			[]string{"example.com", "example.com:443", "10.0.0.1:23", "[::1]:80", "::1", "192.168.1.1:80"},
+		)
+	})
+
+	It("accepts whitespace as separator", func() {
+		expectMatches(" foo.com \t bar.com,\nbaz.com ", []string{"foo.com", "bar.com", "baz.com"}, []string{"qux.com"})
+	})
+
+	Describe("NoProxyFromEnvironment", func() {
+		BeforeEach(func() {
+			os.Unsetenv("NO_PROXY")
+			os.Unsetenv("no_proxy")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("NO_PROXY")
+			os.Unsetenv("no_proxy")
+		})
+
+		It("prefers NO_PROXY over no_proxy", func() {
+			os.Setenv("NO_PROXY", "upper.com")
+			os.Setenv("no_proxy", "lower.com")
+
+			Expect(NoProxyFromEnvironment().Matches("upper.com")).To(BeTrue())
+			Expect(NoProxyFromEnvironment().Matches("lower.com")).To(BeFalse())
+		})
+
+		It("falls back to no_proxy", func() {
+			os.Setenv("no_proxy", "lower.com")
+
+			Expect(NoProxyFromEnvironment().Matches("lower.com")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,170 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// DefaultProbeTimeout is used when probes are given no timeout
+const DefaultProbeTimeout = 10 * time.Second
+
+// TCPCheck succeeds when a TCP connection to addr can be opened within timeout.
+// Connections go through BOSH_ALL_PROXY like requests of the default clients.
+func TCPCheck(addr string, timeout time.Duration) error {
+	ctx, cancel := probeContext(timeout)
+	defer cancel()
+
+	conn, err := defaultDialerContextFunc(ctx, "tcp", addr)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Connecting to '%s'", addr)
+	}
+
+	return conn.Close()
+}
+
+type TLSCheckOpts struct {
+	Timeout time.Duration
+
+	// ServerName defaults to the host of the probed address
+	ServerName string
+
+	// RootCAs defaults to the system cert pool
+	RootCAs            *x509.CertPool
+	InsecureSkipVerify bool
+
+	// MinValidity fails the check when the server certificate expires
+	// sooner than that, 0 only requires the certificate to be valid now
+	MinValidity time.Duration
+}
+
+type TLSCheckResult struct {
+	Subject  string
+	NotAfter time.Time
+}
+
+// ExpiresIn returns how long the server certificate is still valid
+func (r TLSCheckResult) ExpiresIn() time.Duration {
+	return time.Until(r.NotAfter)
+}
+
+// TLSCheck completes a TLS handshake with addr and reports when the server
+// certificate expires
+func TLSCheck(addr string, opts TLSCheckOpts) (TLSCheckResult, error) {
+	ctx, cancel := probeContext(opts.Timeout)
+	defer cancel()
+
+	serverName := opts.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return TLSCheckResult{}, bosherr.WrapErrorf(err, "Parsing address '%s'", addr)
+		}
+		serverName = host
+	}
+
+	rawConn, err := defaultDialerContextFunc(ctx, "tcp", addr)
+	if err != nil {
+		return TLSCheckResult{}, bosherr.WrapErrorf(err, "Connecting to '%s'", addr)
+	}
+
+	conn := tls.Client(rawConn, &tls.Config{
+		ServerName:         serverName,
+		RootCAs:            opts.RootCAs,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	})
+	defer conn.Close()
+
+	err = conn.HandshakeContext(ctx)
+	if err != nil {
+		return TLSCheckResult{}, bosherr.WrapErrorf(err, "Performing TLS handshake with '%s'", addr)
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return TLSCheckResult{}, bosherr.Errorf("Server '%s' did not present a certificate", addr)
+	}
+
+	result := TLSCheckResult{Subject: certs[0].Subject.String(), NotAfter: certs[0].NotAfter}
+
+	if result.ExpiresIn() < opts.MinValidity {
+		return result, bosherr.Errorf(
+			"Certificate '%s' of '%s' expires at %s, less than %s from now",
+			result.Subject, addr, result.NotAfter.Format(time.RFC3339), opts.MinValidity,
+		)
+	}
+
+	return result, nil
+}
+
+type HTTPCheckOpts struct {
+	Timeout time.Duration
+
+	// ExpectedStatus defaults to 200 OK
+	ExpectedStatus int
+
+	// ExpectedBody must be contained in the response body when set
+	ExpectedBody string
+
+	// Client defaults to a client created with CreateDefaultClient(nil)
+	Client Client
+}
+
+// HTTPCheck sends a GET request to url and checks the response status and body
+func HTTPCheck(url string, opts HTTPCheckOpts) error {
+	ctx, cancel := probeContext(opts.Timeout)
+	defer cancel()
+
+	client := opts.Client
+	if client == nil {
+		client = CreateDefaultClient(nil)
+	}
+
+	expectedStatus := opts.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating request to '%s'", url)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return bosherr.WrapErrorf(scrubErrorOutput(err), "Requesting '%s'", scrubEndpointQuery(url))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		return bosherr.Errorf("Requesting '%s': expected status %d but got '%s'", scrubEndpointQuery(url), expectedStatus, resp.Status)
+	}
+
+	if opts.ExpectedBody != "" {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading response body of '%s'", scrubEndpointQuery(url))
+		}
+
+		if !strings.Contains(string(body), opts.ExpectedBody) {
+			return bosherr.Errorf("Requesting '%s': response body does not contain '%s'", scrubEndpointQuery(url), opts.ExpectedBody)
+		}
+	}
+
+	return nil
+}
+
+func probeContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+
+	return context.WithTimeout(context.Background(), timeout)
+}
@@ -0,0 +1,111 @@
+package httpclient_test
+
+import (
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+)
+
+var _ = Describe("Probes", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"state":"running"}`))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("TCPCheck", func() {
+		It("succeeds when the address accepts connections", func() {
+			server.Start()
+
+			Expect(TCPCheck(server.Listener.Addr().String(), time.Second)).To(Succeed())
+		})
+
+		It("fails when nothing listens on the address", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			addr := listener.Addr().String()
+			listener.Close()
+
+			err = TCPCheck(addr, time.Second)
+			Expect(err).To(MatchError(ContainSubstring("Connecting to '" + addr + "'")))
+		})
+	})
+
+	Describe("TLSCheck", func() {
+		var rootCAs *x509.CertPool
+
+		BeforeEach(func() {
+			server.StartTLS()
+
+			rootCAs = x509.NewCertPool()
+			rootCAs.AddCert(server.Certificate())
+		})
+
+		It("reports the expiry of the server certificate", func() {
+			result, err := TLSCheck(server.Listener.Addr().String(), TLSCheckOpts{ServerName: "example.com", RootCAs: rootCAs})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.NotAfter).To(Equal(server.Certificate().NotAfter))
+			Expect(result.ExpiresIn()).To(BeNumerically(">", 0))
+		})
+
+		It("fails when the certificate expires within MinValidity", func() {
+			result, err := TLSCheck(server.Listener.Addr().String(), TLSCheckOpts{
+				ServerName:  "example.com",
+				RootCAs:     rootCAs,
+				MinValidity: time.Until(server.Certificate().NotAfter) + time.Hour,
+			})
+			Expect(err).To(MatchError(ContainSubstring("less than")))
+			Expect(result.NotAfter).To(Equal(server.Certificate().NotAfter))
+		})
+
+		It("fails when the certificate is not trusted", func() {
+			_, err := TLSCheck(server.Listener.Addr().String(), TLSCheckOpts{ServerName: "example.com", RootCAs: x509.NewCertPool()})
+			Expect(err).To(MatchError(ContainSubstring("Performing TLS handshake")))
+		})
+	})
+
+	Describe("HTTPCheck", func() {
+		BeforeEach(func() {
+			server.Start()
+		})
+
+		It("succeeds when status and body match", func() {
+			Expect(HTTPCheck(server.URL+"/info", HTTPCheckOpts{ExpectedBody: "running"})).To(Succeed())
+			Expect(HTTPCheck(server.URL+"/missing", HTTPCheckOpts{ExpectedStatus: http.StatusNotFound})).To(Succeed())
+		})
+
+		It("fails when the status does not match", func() {
+			err := HTTPCheck(server.URL+"/missing", HTTPCheckOpts{})
+			Expect(err).To(MatchError(ContainSubstring("expected status 200 but got '404 Not Found'")))
+		})
+
+		It("fails when the body does not match", func() {
+			err := HTTPCheck(server.URL+"/info", HTTPCheckOpts{ExpectedBody: "stopped"})
+			Expect(err).To(MatchError(ContainSubstring("response body does not contain 'stopped'")))
+		})
+
+		It("redacts query params in errors", func() {
+			err := HTTPCheck(server.URL+"/missing?token=secret", HTTPCheckOpts{})
+			Expect(err).To(HaveOccurred())
+			Expect(strings.Contains(err.Error(), "secret")).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,204 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ProxyAuthenticator returns the Proxy-Authorization header value used for
+// requests sent through proxyURL to target (host:port). It is called for
+// every CONNECT and every plain HTTP request sent through the proxy, which
+// allows single round trip schemes such as Basic or pre-emptive Negotiate.
+type ProxyAuthenticator interface {
+	ProxyAuthorization(ctx context.Context, proxyURL *url.URL, target string) (string, error)
+}
+
+// ProxyAuthFunc adapts a function (e.g. a Negotiate/NTLM token provider)
+// to a ProxyAuthenticator.
+type ProxyAuthFunc func(ctx context.Context, proxyURL *url.URL, target string) (string, error)
+
+func (f ProxyAuthFunc) ProxyAuthorization(ctx context.Context, proxyURL *url.URL, target string) (string, error) {
+	return f(ctx, proxyURL, target)
+}
+
+type BasicProxyAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicProxyAuth) ProxyAuthorization(_ context.Context, _ *url.URL, _ string) (string, error) {
+	creds := base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))
+	return "Basic " + creds, nil
+}
+
+type ProxyConfig struct {
+	// URL of the proxy used for all requests. HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY are used when empty.
+	URL string
+
+	// Username and Password configure Basic authentication
+	// and take precedence over credentials embedded in the proxy URL
+	Username string
+	Password string
+
+	// Authenticator takes precedence over Username and Password
+	Authenticator ProxyAuthenticator
+}
+
+func (c ProxyConfig) authenticator() ProxyAuthenticator {
+	if c.Authenticator != nil {
+		return c.Authenticator
+	}
+
+	if c.Username != "" {
+		return BasicProxyAuth{Username: c.Username, Password: c.Password}
+	}
+
+	return nil
+}
+
+// Apply configures transport to use the proxy and its credentials.
+// The returned round tripper must be used instead of transport so that
+// plain HTTP requests sent through the proxy are authenticated as well.
+func (c ProxyConfig) Apply(transport *http.Transport) (http.RoundTripper, error) {
+	proxyFunc, err := c.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.apply(transport, proxyFunc), nil
+}
+
+// WithProxyConfig sends requests through the proxy of config (see Apply).
+// Requests fail when the proxy URL can not be parsed.
+func WithProxyConfig(config ProxyConfig) ClientOption {
+	return func(client *http.Client) {
+		switch transport := client.Transport.(type) {
+		case *http.Transport:
+			client.Transport = config.apply(transport, config.proxyFuncOrError())
+		case *connRecycler:
+			transport.next = config.apply(transport.transport, config.proxyFuncOrError())
+		case *proxyAuthRoundTripper:
+			// replaces the credentials of a previous proxy config
+			inner := &http.Client{Transport: transport.next}
+			WithProxyConfig(config)(inner)
+			client.Transport = inner.Transport
+		case wrappingRoundTripper:
+			next := transport.wrapped()
+			inner := &http.Client{Transport: *next}
+			WithProxyConfig(config)(inner)
+			*next = inner.Transport
+		}
+	}
+}
+
+func (c ProxyConfig) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if c.URL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing proxy URL '%s'", c.URL)
+	}
+
+	return http.ProxyURL(proxyURL), nil
+}
+
+func (c ProxyConfig) proxyFuncOrError() func(*http.Request) (*url.URL, error) {
+	proxyFunc, err := c.proxyFunc()
+	if err != nil {
+		return func(*http.Request) (*url.URL, error) { return nil, err }
+	}
+
+	return proxyFunc
+}
+
+func (c ProxyConfig) apply(transport *http.Transport, proxyFunc func(*http.Request) (*url.URL, error)) http.RoundTripper {
+	auth := c.authenticator()
+	if auth == nil {
+		transport.Proxy = proxyFunc
+		transport.GetProxyConnectHeader = nil
+		return transport
+	}
+
+	// the transport adds URL-embedded credentials after ours, so strip them
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxyFunc(req)
+		if proxyURL == nil || err != nil {
+			return proxyURL, err
+		}
+
+		withoutCreds := *proxyURL
+		withoutCreds.User = nil
+
+		return &withoutCreds, nil
+	}
+
+	transport.GetProxyConnectHeader = func(ctx context.Context, proxyURL *url.URL, target string) (http.Header, error) {
+		value, err := auth.ProxyAuthorization(ctx, proxyURL, target)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Authenticating with proxy")
+		}
+
+		return http.Header{"Proxy-Authorization": []string{value}}, nil
+	}
+
+	return &proxyAuthRoundTripper{next: transport, proxy: transport.Proxy, auth: auth}
+}
+
+// proxyAuthRoundTripper authenticates plain HTTP requests, which are sent
+// to the proxy directly instead of through a CONNECT tunnel.
+type proxyAuthRoundTripper struct {
+	next  http.RoundTripper
+	proxy func(*http.Request) (*url.URL, error)
+	auth  ProxyAuthenticator
+}
+
+func (rt *proxyAuthRoundTripper) wrapped() *http.RoundTripper {
+	return &rt.next
+}
+
+func (rt *proxyAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return rt.next.RoundTrip(req)
+	}
+
+	proxyURL, err := rt.proxy(req)
+	if err != nil || proxyURL == nil {
+		return rt.next.RoundTrip(req)
+	}
+
+	value, err := rt.auth.ProxyAuthorization(req.Context(), proxyURL, canonicalAddr(req.URL))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Authenticating with proxy")
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Proxy-Authorization", value)
+
+	return rt.next.RoundTrip(req)
+}
+
+func (rt *proxyAuthRoundTripper) CloseIdleConnections() {
+	if closer, ok := rt.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,239 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+)
+
+type authProxy struct {
+	server *httptest.Server
+
+	mu             sync.Mutex
+	authorizations []string
+}
+
+func newAuthProxy(expectedAuth string) *authProxy {
+	p := &authProxy{}
+
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p.mu.Lock()
+		p.authorizations = append(p.authorizations, req.Header.Get("Proxy-Authorization"))
+		p.mu.Unlock()
+
+		if req.Header.Get("Proxy-Authorization") != expectedAuth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		if req.Method != http.MethodConnect {
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+			return
+		}
+
+		targetConn, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		clientConn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			targetConn.Close()
+			return
+		}
+
+		go func() {
+			defer targetConn.Close()
+			defer clientConn.Close()
+			io.Copy(targetConn, clientConn)
+		}()
+		go io.Copy(clientConn, targetConn)
+	}))
+
+	return p
+}
+
+func (p *authProxy) Authorizations() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.authorizations
+}
+
+var _ = Describe("ProxyConfig", func() {
+	const basicAuth = "Basic dXNlcjpwQHNz" // user:p@ss
+
+	var (
+		proxy  *authProxy
+		server *ghttp.Server
+	)
+
+	newClient := func(config ProxyConfig) *http.Client {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+
+		roundTripper, err := config.Apply(transport)
+		Expect(err).ToNot(HaveOccurred())
+
+		return &http.Client{Transport: roundTripper}
+	}
+
+	BeforeEach(func() {
+		proxy = newAuthProxy(basicAuth)
+	})
+
+	AfterEach(func() {
+		proxy.server.Close()
+		if server != nil {
+			server.Close()
+		}
+	})
+
+	It("authenticates plain HTTP requests with Basic credentials", func() {
+		server = ghttp.NewServer()
+		server.AppendHandlers(ghttp.RespondWith(http.StatusOK, "through proxy"))
+
+		client := newClient(ProxyConfig{URL: proxy.server.URL, Username: "user", Password: "p@ss"})
+
+		resp, err := client.Get(server.URL())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(readString(resp.Body)).To(Equal("through proxy"))
+
+		Expect(proxy.Authorizations()).To(Equal([]string{basicAuth}))
+	})
+
+	It("authenticates CONNECT tunnels for HTTPS requests", func() {
+		server = ghttp.NewTLSServer()
+		server.AppendHandlers(ghttp.RespondWith(http.StatusOK, "through tunnel"))
+
+		client := newClient(ProxyConfig{URL: proxy.server.URL, Username: "user", Password: "p@ss"})
+
+		resp, err := client.Get(server.URL())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readString(resp.Body)).To(Equal("through tunnel"))
+
+		Expect(proxy.Authorizations()).To(Equal([]string{basicAuth}))
+	})
+
+	It("prefers configured credentials over credentials in the proxy URL", func() {
+		server = ghttp.NewTLSServer()
+		server.AppendHandlers(ghttp.RespondWith(http.StatusOK, "through tunnel"))
+
+		proxyURL, _ := url.Parse(proxy.server.URL)
+		proxyURL.User = url.UserPassword("wrong", "wrong")
+
+		client := newClient(ProxyConfig{URL: proxyURL.String(), Username: "user", Password: "p@ss"})
+
+		resp, err := client.Get(server.URL())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		resp.Body.Close()
+	})
+
+	It("uses the Authenticator hook with the proxy and target", func() {
+		server = ghttp.NewTLSServer()
+		server.AppendHandlers(ghttp.RespondWith(http.StatusOK, "through tunnel"))
+
+		var targets []string
+		client := newClient(ProxyConfig{
+			URL: proxy.server.URL,
+			Authenticator: ProxyAuthFunc(func(_ context.Context, proxyURL *url.URL, target string) (string, error) {
+				Expect(proxyURL.Host).To(Equal(proxy.server.Listener.Addr().String()))
+				targets = append(targets, target)
+				return basicAuth, nil
+			}),
+		})
+
+		resp, err := client.Get(server.URL())
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		Expect(targets).To(Equal([]string{server.Addr()}))
+	})
+
+	It("returns errors from the Authenticator", func() {
+		server = ghttp.NewServer()
+
+		client := newClient(ProxyConfig{
+			URL: proxy.server.URL,
+			Authenticator: ProxyAuthFunc(func(_ context.Context, _ *url.URL, _ string) (string, error) {
+				return "", errors.New("fake-token-err")
+			}),
+		})
+
+		_, err := client.Get(server.URL())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Authenticating with proxy: fake-token-err"))
+	})
+
+	It("rejects invalid proxy URLs", func() {
+		_, err := ProxyConfig{URL: "://bad"}.Apply(&http.Transport{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("WithProxyConfig", func() {
+		It("authenticates requests behind other options", func() {
+			server = ghttp.NewServer()
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, "through proxy"))
+
+			client := CreateDefaultClient(nil,
+				WithMaxConnAge(time.Minute),
+				WithDefaultHeaders(http.Header{"X-Fake": []string{"fake-value"}}),
+				WithProxyConfig(ProxyConfig{URL: proxy.server.URL, Username: "user", Password: "p@ss"}),
+			)
+
+			resp, err := client.Get(server.URL())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readString(resp.Body)).To(Equal("through proxy"))
+
+			Expect(proxy.Authorizations()).To(Equal([]string{basicAuth}))
+		})
+
+		It("replaces the credentials of a previous proxy config", func() {
+			server = ghttp.NewServer()
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, "through proxy"))
+
+			client := CreateDefaultClient(nil,
+				WithProxyConfig(ProxyConfig{URL: proxy.server.URL, Username: "wrong", Password: "wrong"}),
+				WithProxyConfig(ProxyConfig{URL: proxy.server.URL, Username: "user", Password: "p@ss"}),
+			)
+
+			resp, err := client.Get(server.URL())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			resp.Body.Close()
+
+			Expect(proxy.Authorizations()).To(Equal([]string{basicAuth}))
+		})
+
+		It("fails requests when the proxy URL is invalid", func() {
+			server = ghttp.NewServer()
+
+			client := CreateDefaultClient(nil, WithProxyConfig(ProxyConfig{URL: "://bad"}))
+
+			_, err := client.Get(server.URL())
+			Expect(err).To(MatchError(ContainSubstring("Parsing proxy URL '://bad'")))
+		})
+	})
+})
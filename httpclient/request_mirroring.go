@@ -0,0 +1,298 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// DefaultMirrorTimeout is used when RequestMirrorOpts.Timeout is not set
+const DefaultMirrorTimeout = 30 * time.Second
+
+type RequestMirrorOpts struct {
+	// BaseURL of the secondary endpoint, the path of mirrored requests is
+	// appended to its path
+	BaseURL *url.URL
+
+	// Percentage of requests between 0 and 100 that are mirrored
+	Percentage float64
+
+	// Methods that are mirrored, defaults to GET and HEAD so that mirroring
+	// does not change the state of the secondary
+	Methods []string
+
+	// Timeout of a mirrored request including waiting for the primary
+	// response to be read
+	Timeout time.Duration
+
+	// OnComparison is called with every comparison after it was logged
+	OnComparison func(MirrorComparison)
+
+	// Header is called with the headers of every mirrored request, e.g. to
+	// set the credentials of the secondary. The credentials of the primary
+	// (see mirrorCredentialHeaders) are removed before.
+	Header func(http.Header)
+
+	Logger boshlog.Logger
+}
+
+// MirrorComparison describes how the secondary answered a mirrored request
+type MirrorComparison struct {
+	Method string
+	Path   string
+
+	PrimaryStatus int
+	MirrorStatus  int
+
+	// MirrorErr is set when the mirrored request failed
+	MirrorErr error
+
+	// BodiesCompared is false when the primary body was not read to the
+	// end or either request failed
+	BodiesCompared bool
+	BodiesMatch    bool
+}
+
+func (c MirrorComparison) Matches() bool {
+	return c.MirrorErr == nil && c.PrimaryStatus == c.MirrorStatus && (!c.BodiesCompared || c.BodiesMatch)
+}
+
+// WithRequestMirroring sends a copy of a percentage of requests to a
+// secondary endpoint, e.g. to validate a new blobstore under real traffic
+// before migrating to it. Mirrored requests are sent in the background and
+// their responses are compared with the primary ones and logged; they
+// never change the primary response. Requests with a body are only
+// mirrored when the body can be rewound (see http.Request.GetBody).
+func WithRequestMirroring(opts RequestMirrorOpts) ClientOption {
+	if len(opts.Methods) == 0 {
+		opts.Methods = []string{http.MethodGet, http.MethodHead}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultMirrorTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = boshlog.NewLogger(boshlog.LevelNone)
+	}
+
+	return func(client *http.Client) {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+
+		client.Transport = &mirroringRoundTripper{
+			next:   next,
+			opts:   opts,
+			jitter: newBackoffJitter(nil),
+			logTag: "requestMirroring",
+		}
+	}
+}
+
+// mirrorCredentialHeaders are not sent to the secondary, like
+// http.Client does not send them along redirects to other hosts
+var mirrorCredentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+type mirroringRoundTripper struct {
+	next   http.RoundTripper
+	opts   RequestMirrorOpts
+	jitter *backoffJitter
+	logTag string
+}
+
+func (t *mirroringRoundTripper) wrapped() *http.RoundTripper {
+	return &t.next
+}
+
+func (t *mirroringRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.mirrors(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	mirrorReq, err := t.mirrorRequest(req)
+	if err != nil {
+		t.opts.Logger.Debug(t.logTag, "Not mirroring request: %s", err)
+		return t.next.RoundTrip(req)
+	}
+
+	primaryCh := make(chan mirroredPrimary, 1)
+
+	go t.mirror(mirrorReq, primaryCh)
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		primaryCh <- mirroredPrimary{err: err}
+		return resp, err
+	}
+
+	resp.Body = &mirroredBody{
+		ReadCloser: resp.Body,
+		hash:       sha256.New(),
+		status:     resp.StatusCode,
+		primaryCh:  primaryCh,
+	}
+
+	return resp, nil
+}
+
+func (t *mirroringRoundTripper) mirrors(req *http.Request) bool {
+	if t.opts.BaseURL == nil || t.opts.Percentage <= 0 {
+		return false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	methodMirrored := false
+	for _, method := range t.opts.Methods {
+		if strings.EqualFold(method, req.Method) {
+			methodMirrored = true
+			break
+		}
+	}
+	if !methodMirrored {
+		return false
+	}
+
+	return t.opts.Percentage >= 100 || t.jitter.float64()*100 < t.opts.Percentage
+}
+
+func (t *mirroringRoundTripper) mirrorRequest(req *http.Request) (*http.Request, error) {
+	// detached from the primary request so that it is not canceled with it
+	mirrorReq := req.Clone(context.Background())
+
+	mirrorURL := *t.opts.BaseURL
+	mirrorURL.Path = strings.TrimSuffix(mirrorURL.Path, "/") + req.URL.Path
+	mirrorURL.RawPath = ""
+	mirrorURL.RawQuery = req.URL.RawQuery
+
+	mirrorReq.URL = &mirrorURL
+	mirrorReq.Host = ""
+
+	for _, header := range mirrorCredentialHeaders {
+		mirrorReq.Header.Del(header)
+	}
+
+	if t.opts.Header != nil {
+		t.opts.Header(mirrorReq.Header)
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		mirrorReq.Body = body
+	}
+
+	return mirrorReq, nil
+}
+
+func (t *mirroringRoundTripper) mirror(req *http.Request, primaryCh <-chan mirroredPrimary) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.opts.Timeout)
+	defer cancel()
+
+	comparison := MirrorComparison{Method: req.Method, Path: req.URL.Path}
+
+	var mirrorSum []byte
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err == nil {
+		comparison.MirrorStatus = resp.StatusCode
+
+		hash := sha256.New()
+		_, err = io.Copy(hash, resp.Body)
+		resp.Body.Close()
+
+		mirrorSum = hash.Sum(nil)
+	}
+	comparison.MirrorErr = err
+
+	select {
+	case primary := <-primaryCh:
+		if primary.err != nil {
+			// nothing to compare with
+			return
+		}
+
+		comparison.PrimaryStatus = primary.status
+
+		if primary.sum != nil && comparison.MirrorErr == nil {
+			comparison.BodiesCompared = true
+			comparison.BodiesMatch = bytes.Equal(primary.sum, mirrorSum)
+		}
+	case <-ctx.Done():
+		t.opts.Logger.Debug(t.logTag, "Primary response of %s '%s' was not closed in time", comparison.Method, comparison.Path)
+		return
+	}
+
+	t.log(comparison)
+
+	if t.opts.OnComparison != nil {
+		t.opts.OnComparison(comparison)
+	}
+}
+
+func (t *mirroringRoundTripper) log(c MirrorComparison) {
+	switch {
+	case c.MirrorErr != nil:
+		t.opts.Logger.Warn(t.logTag, "Mirrored %s '%s' failed: %s", c.Method, c.Path, c.MirrorErr)
+	case c.PrimaryStatus != c.MirrorStatus:
+		t.opts.Logger.Warn(t.logTag, "Mirrored %s '%s' responded with status %d instead of %d", c.Method, c.Path, c.MirrorStatus, c.PrimaryStatus)
+	case c.BodiesCompared && !c.BodiesMatch:
+		t.opts.Logger.Warn(t.logTag, "Mirrored %s '%s' responded with a different body", c.Method, c.Path)
+	default:
+		t.opts.Logger.Debug(t.logTag, "Mirrored %s '%s' matches", c.Method, c.Path)
+	}
+}
+
+// mirroredPrimary is what the mirror compares against, sum is nil when
+// the body was not read to the end
+type mirroredPrimary struct {
+	status int
+	sum    []byte
+	err    error
+}
+
+// mirroredBody hashes the primary body while the caller reads it
+type mirroredBody struct {
+	io.ReadCloser
+
+	hash   hash.Hash
+	status int
+	eof    bool
+
+	primaryCh chan<- mirroredPrimary
+}
+
+func (b *mirroredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *mirroredBody) Close() error {
+	if b.primaryCh != nil {
+		primary := mirroredPrimary{status: b.status}
+		if b.eof {
+			primary.sum = b.hash.Sum(nil)
+		}
+
+		b.primaryCh <- primary
+		b.primaryCh = nil
+	}
+
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,175 @@
+package httpclient_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+)
+
+var _ = Describe("request mirroring", func() {
+	var (
+		primary   *httptest.Server
+		secondary *httptest.Server
+
+		secondaryBody   string
+		secondaryStatus int
+
+		lock           sync.Mutex
+		mirroredPaths  []string
+		mirroredHeader http.Header
+		comparisons    chan MirrorComparison
+	)
+
+	BeforeEach(func() {
+		secondaryBody = "fake-blob"
+		secondaryStatus = http.StatusOK
+		mirroredPaths = nil
+		comparisons = make(chan MirrorComparison, 10)
+
+		primary = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("fake-blob"))
+		}))
+
+		secondary = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			mirroredPaths = append(mirroredPaths, r.URL.RequestURI())
+			mirroredHeader = r.Header
+			lock.Unlock()
+
+			w.WriteHeader(secondaryStatus)
+			w.Write([]byte(secondaryBody))
+		}))
+	})
+
+	AfterEach(func() {
+		primary.Close()
+		secondary.Close()
+	})
+
+	newClientWithHeader := func(percentage float64, header func(http.Header)) Client {
+		baseURL, err := url.Parse(secondary.URL + "/migrated")
+		Expect(err).ToNot(HaveOccurred())
+
+		return CreateDefaultClient(nil, WithRequestMirroring(RequestMirrorOpts{
+			BaseURL:      baseURL,
+			Percentage:   percentage,
+			OnComparison: func(c MirrorComparison) { comparisons <- c },
+			Header:       header,
+		}))
+	}
+
+	newClient := func(percentage float64) Client {
+		return newClientWithHeader(percentage, nil)
+	}
+
+	get := func(client Client, path string) string {
+		req, err := http.NewRequest("GET", primary.URL+path, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		req.Header.Set("Authorization", "Bearer primary-token")
+		req.Header.Set("Proxy-Authorization", "Basic cHJveHk6c2VjcmV0")
+		req.Header.Set("Cookie", "session=primary")
+		req.Header.Set("X-Request-Id", "fake-request-id")
+
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+
+		return string(body)
+	}
+
+	It("mirrors requests to the secondary base URL and compares responses", func() {
+		Expect(get(newClient(100), "/blobs/fake-id?version=1")).To(Equal("fake-blob"))
+
+		var comparison MirrorComparison
+		Eventually(comparisons).Should(Receive(&comparison))
+
+		Expect(comparison.Matches()).To(BeTrue())
+		Expect(comparison.BodiesCompared).To(BeTrue())
+		Expect(comparison.Path).To(Equal("/migrated/blobs/fake-id"))
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(mirroredPaths).To(Equal([]string{"/migrated/blobs/fake-id?version=1"}))
+	})
+
+	It("does not send the credentials of the primary to the secondary", func() {
+		get(newClient(100), "/blobs/fake-id")
+		Eventually(comparisons).Should(Receive())
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(mirroredHeader).ToNot(HaveKey("Authorization"))
+		Expect(mirroredHeader).ToNot(HaveKey("Proxy-Authorization"))
+		Expect(mirroredHeader).ToNot(HaveKey("Cookie"))
+		Expect(mirroredHeader.Get("X-Request-Id")).To(Equal("fake-request-id"))
+	})
+
+	It("lets the headers of the secondary be set", func() {
+		client := newClientWithHeader(100, func(header http.Header) {
+			header.Set("Authorization", "Bearer secondary-token")
+		})
+
+		get(client, "/blobs/fake-id")
+		Eventually(comparisons).Should(Receive())
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(mirroredHeader.Get("Authorization")).To(Equal("Bearer secondary-token"))
+	})
+
+	It("reports differences without changing the primary response", func() {
+		secondaryStatus = http.StatusNotFound
+		secondaryBody = "not found"
+
+		Expect(get(newClient(100), "/blobs/fake-id")).To(Equal("fake-blob"))
+
+		var comparison MirrorComparison
+		Eventually(comparisons).Should(Receive(&comparison))
+
+		Expect(comparison.Matches()).To(BeFalse())
+		Expect(comparison.PrimaryStatus).To(Equal(http.StatusOK))
+		Expect(comparison.MirrorStatus).To(Equal(http.StatusNotFound))
+		Expect(comparison.BodiesMatch).To(BeFalse())
+	})
+
+	It("reports mirrored requests that fail", func() {
+		secondary.Close()
+
+		Expect(get(newClient(100), "/blobs/fake-id")).To(Equal("fake-blob"))
+
+		var comparison MirrorComparison
+		Eventually(comparisons).Should(Receive(&comparison))
+
+		Expect(comparison.MirrorErr).To(HaveOccurred())
+		Expect(comparison.Matches()).To(BeFalse())
+	})
+
+	It("does not mirror requests that are not selected", func() {
+		Expect(get(newClient(0), "/blobs/fake-id")).To(Equal("fake-blob"))
+
+		Consistently(comparisons).ShouldNot(Receive())
+	})
+
+	It("does not mirror methods that change state by default", func() {
+		req, err := http.NewRequest("POST", primary.URL+"/blobs", strings.NewReader("fake-blob"))
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := newClient(100).Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		Consistently(comparisons).ShouldNot(Receive())
+	})
+})
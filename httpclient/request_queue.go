@@ -0,0 +1,373 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+const (
+	// DefaultDedupKeyHeader carries the dedup key of queued requests so
+	// servers can ignore replays they already processed
+	DefaultDedupKeyHeader = "Idempotency-Key"
+
+	requestQueuedHeader = "X-Bosh-Request-Queued"
+)
+
+type RequestQueueOpts struct {
+	// Dir stores queued requests, one file per request
+	Dir string
+
+	// Endpoint limits queueing to requests whose URL starts with it,
+	// other requests are sent directly
+	Endpoint string
+
+	// MaxQueued drops the oldest requests once exceeded, 0 keeps all
+	MaxQueued int
+
+	// DedupKeyHeader defaults to DefaultDedupKeyHeader. Requests that already
+	// have the header keep their key and are only queued once.
+	DedupKeyHeader string
+
+	// Clock times Run, defaults to the real clock
+	Clock clock.Clock
+}
+
+// RequestQueue stores requests on disk when they cannot be sent and
+// replays them in order once the endpoint is reachable again
+type RequestQueue struct {
+	client  Client
+	fs      boshsys.FileSystem
+	uuidGen boshuuid.Generator
+	opts    RequestQueueOpts
+
+	lock    sync.Mutex
+	nextSeq uint64
+
+	logTag string
+	logger boshlog.Logger
+}
+
+type queuedRequest struct {
+	Key    string      `json:"key"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+func NewRequestQueue(
+	client Client,
+	fs boshsys.FileSystem,
+	uuidGen boshuuid.Generator,
+	opts RequestQueueOpts,
+	logger boshlog.Logger,
+) *RequestQueue {
+	if opts.DedupKeyHeader == "" {
+		opts.DedupKeyHeader = DefaultDedupKeyHeader
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.NewClock()
+	}
+
+	return &RequestQueue{
+		client:  client,
+		fs:      fs,
+		uuidGen: uuidGen,
+		opts:    opts,
+		logTag:  "requestQueue",
+		logger:  logger,
+	}
+}
+
+// IsQueued reports whether a response returned by RequestQueue.Do was
+// synthesized because the request was queued for later delivery
+func IsQueued(resp *http.Response) bool {
+	return resp != nil && resp.Header.Get(requestQueuedHeader) == "true"
+}
+
+// Do sends req, or queues it and returns a 202 Accepted response for which
+// IsQueued is true when the endpoint is unreachable. Requests are also
+// queued while older requests wait so that the order is kept.
+func (q *RequestQueue) Do(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.String(), q.opts.Endpoint) {
+		return q.client.Do(req)
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	queued, err := q.queuedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(queued) > 0 {
+		err = q.flush(queued)
+		if err != nil {
+			return q.enqueue(req, body, err)
+		}
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+
+		return q.enqueue(req, body, err)
+	}
+
+	return resp, nil
+}
+
+// Flush replays queued requests in order and stops at the first request
+// that cannot be delivered
+func (q *RequestQueue) Flush() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	queued, err := q.queuedFiles()
+	if err != nil {
+		return err
+	}
+
+	return q.flush(queued)
+}
+
+// Len returns the number of queued requests
+func (q *RequestQueue) Len() (int, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	queued, err := q.queuedFiles()
+	return len(queued), err
+}
+
+// Run flushes the queue every interval until ctx is done
+func (q *RequestQueue) Run(ctx context.Context, interval time.Duration) {
+	ticker := q.opts.Clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			err := q.Flush()
+			if err != nil {
+				q.logger.Debug(q.logTag, "Queued requests are not delivered yet: %s", err)
+			}
+		}
+	}
+}
+
+func (q *RequestQueue) enqueue(req *http.Request, body []byte, sendErr error) (*http.Response, error) {
+	var err error
+
+	key := req.Header.Get(q.opts.DedupKeyHeader)
+	if key == "" {
+		key, err = q.uuidGen.Generate()
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Generating dedup key")
+		}
+	}
+
+	queued, err := q.queuedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	alreadyQueued := false
+	for _, path := range queued {
+		if queuedKey(path) == key {
+			alreadyQueued = true
+			break
+		}
+	}
+
+	if !alreadyQueued {
+		header := req.Header.Clone()
+		header.Set(q.opts.DedupKeyHeader, key)
+
+		path, err := q.write(queuedRequest{
+			Key:    key,
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: header,
+			Body:   body,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		queued = append(queued, path)
+	}
+
+	for len(queued) > q.opts.MaxQueued && q.opts.MaxQueued > 0 {
+		q.logger.Warn(q.logTag, "Dropping queued request '%s', queue is limited to %d requests", queuedKey(queued[0]), q.opts.MaxQueued)
+
+		err = q.fs.RemoveAll(queued[0])
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Dropping queued request '%s'", queued[0])
+		}
+
+		queued = queued[1:]
+	}
+
+	q.logger.Debug(q.logTag, "Queued %s request '%s' for later delivery: %s", req.Method, key, scrubErrorOutput(sendErr))
+
+	return &http.Response{
+		Status:     "202 Accepted",
+		StatusCode: http.StatusAccepted,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{requestQueuedHeader: []string{"true"}, q.opts.DedupKeyHeader: []string{key}},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func (q *RequestQueue) flush(queued []string) error {
+	for _, path := range queued {
+		contents, err := q.fs.ReadFile(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading queued request '%s'", path)
+		}
+
+		var queuedReq queuedRequest
+
+		err = json.Unmarshal(contents, &queuedReq)
+		if err != nil {
+			q.logger.Error(q.logTag, "Dropping unreadable queued request '%s': %s", path, err)
+			q.fs.RemoveAll(path)
+			continue
+		}
+
+		req, err := http.NewRequest(queuedReq.Method, queuedReq.URL, bytes.NewReader(queuedReq.Body))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating queued request '%s'", queuedReq.Key)
+		}
+
+		req.Header = queuedReq.Header
+
+		resp, err := q.client.Do(req)
+		if err != nil {
+			return bosherr.WrapErrorf(scrubErrorOutput(err), "Replaying queued request '%s'", queuedReq.Key)
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 500 {
+			return bosherr.Errorf("Replaying queued request '%s': endpoint responded with status '%s'", queuedReq.Key, resp.Status)
+		}
+
+		if resp.StatusCode >= 400 {
+			q.logger.Error(q.logTag, "Dropping queued request '%s' rejected with status '%s'", queuedReq.Key, resp.Status)
+		}
+
+		err = q.fs.RemoveAll(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing replayed request '%s'", path)
+		}
+	}
+
+	return nil
+}
+
+// queuedFiles returns queued request files ordered by their sequence number
+func (q *RequestQueue) queuedFiles() ([]string, error) {
+	paths, err := q.fs.Glob(filepath.Join(q.opts.Dir, "*.json"))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing queued requests in '%s'", q.opts.Dir)
+	}
+
+	sort.Strings(paths)
+
+	if len(paths) > 0 {
+		seqStr, _, _ := strings.Cut(filepath.Base(paths[len(paths)-1]), "-")
+		seq, _ := strconv.ParseUint(seqStr, 10, 64)
+		if seq >= q.nextSeq {
+			q.nextSeq = seq + 1
+		}
+	}
+
+	return paths, nil
+}
+
+func (q *RequestQueue) write(queuedReq queuedRequest) (string, error) {
+	contents, err := json.Marshal(queuedReq)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Marshalling queued request")
+	}
+
+	err = q.fs.MkdirAll(q.opts.Dir, os.FileMode(0700))
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Creating request queue dir '%s'", q.opts.Dir)
+	}
+
+	// sequence numbers are zero padded so that names sort in queue order
+	name := fmt.Sprintf("%020d-%s.json", q.nextSeq, queuedReq.Key)
+	path := filepath.Join(q.opts.Dir, name)
+	tmpPath := filepath.Join(q.opts.Dir, "."+name+".tmp")
+
+	err = q.fs.WriteFile(tmpPath, contents)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Writing queued request '%s'", path)
+	}
+
+	err = q.fs.Rename(tmpPath, path)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Writing queued request '%s'", path)
+	}
+
+	q.nextSeq++
+
+	return path, nil
+}
+
+func queuedKey(path string) string {
+	_, key, _ := strings.Cut(strings.TrimSuffix(filepath.Base(path), ".json"), "-")
+	return key
+}
+
+// readRequestBody reads the body and replaces it so that req can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading request body")
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
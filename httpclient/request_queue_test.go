@@ -0,0 +1,208 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+)
+
+type queueTestClient struct {
+	down     bool
+	status   int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (c *queueTestClient) Do(req *http.Request) (*http.Response, error) {
+	if c.down {
+		return nil, errors.New("fake-network-down")
+	}
+
+	body := ""
+	if req.Body != nil {
+		bodyBytes, _ := io.ReadAll(req.Body)
+		body = string(bodyBytes)
+	}
+
+	c.requests = append(c.requests, req)
+	c.bodies = append(c.bodies, body)
+
+	status := c.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+var _ = Describe("RequestQueue", func() {
+	var (
+		client *queueTestClient
+		fs     boshsys.FileSystem
+		opts   RequestQueueOpts
+	)
+
+	BeforeEach(func() {
+		client = &queueTestClient{}
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = boshsys.NewOsFileSystem(logger)
+		opts = RequestQueueOpts{Dir: GinkgoT().TempDir(), Endpoint: "https://director"}
+	})
+
+	newQueue := func() *RequestQueue {
+		return NewRequestQueue(client, fs, fakeuuid.NewFakeGenerator(), opts, boshlog.NewLogger(boshlog.LevelNone))
+	}
+
+	post := func(queue *RequestQueue, url, body string) *http.Response {
+		req, err := http.NewRequest("POST", url, strings.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+
+		resp, err := queue.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+
+		return resp
+	}
+
+	queueLen := func(queue *RequestQueue) int {
+		n, err := queue.Len()
+		Expect(err).ToNot(HaveOccurred())
+		return n
+	}
+
+	It("sends requests directly while the endpoint is reachable", func() {
+		queue := newQueue()
+
+		resp := post(queue, "https://director/events", "event-1")
+		Expect(IsQueued(resp)).To(BeFalse())
+		Expect(client.bodies).To(Equal([]string{"event-1"}))
+		Expect(queueLen(queue)).To(Equal(0))
+	})
+
+	It("queues requests while the endpoint is unreachable and replays them in order", func() {
+		queue := newQueue()
+		client.down = true
+
+		resp := post(queue, "https://director/events", "event-1")
+		Expect(IsQueued(resp)).To(BeTrue())
+		Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+		Expect(resp.Header.Get("Idempotency-Key")).To(Equal("fake-uuid-0"))
+
+		post(queue, "https://director/events", "event-2")
+		Expect(queueLen(queue)).To(Equal(2))
+
+		client.down = false
+
+		resp = post(queue, "https://director/events", "event-3")
+		Expect(IsQueued(resp)).To(BeFalse())
+		Expect(client.bodies).To(Equal([]string{"event-1", "event-2", "event-3"}))
+		Expect(client.requests[0].Header.Get("Idempotency-Key")).To(Equal("fake-uuid-0"))
+		Expect(client.requests[1].Header.Get("Idempotency-Key")).To(Equal("fake-uuid-1"))
+		Expect(queueLen(queue)).To(Equal(0))
+	})
+
+	It("flushes the queue every interval of the given clock while running", func() {
+		fakeTimeService := fakeclock.NewFakeClock(time.Now())
+		opts.Clock = fakeTimeService
+		queue := newQueue()
+		client.down = true
+
+		post(queue, "https://director/events", "event-1")
+		Expect(queueLen(queue)).To(Equal(1))
+
+		client.down = false
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go queue.Run(ctx, time.Minute)
+
+		Consistently(func() int { return queueLen(queue) }).Should(Equal(1))
+
+		fakeTimeService.WaitForWatcherAndIncrement(time.Minute)
+		Eventually(func() int { return queueLen(queue) }).Should(Equal(0))
+	})
+
+	It("keeps queued requests across restarts", func() {
+		client.down = true
+		post(newQueue(), "https://director/events", "event-1")
+
+		client.down = false
+		queue := newQueue()
+		Expect(queueLen(queue)).To(Equal(1))
+
+		Expect(queue.Flush()).To(Succeed())
+		Expect(client.bodies).To(Equal([]string{"event-1"}))
+	})
+
+	It("queues requests with the same dedup key only once", func() {
+		queue := newQueue()
+		client.down = true
+
+		for i := 0; i < 2; i++ {
+			req, err := http.NewRequest("POST", "https://director/heartbeat", nil)
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Idempotency-Key", "heartbeat-1")
+
+			_, err = queue.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(queueLen(queue)).To(Equal(1))
+	})
+
+	It("drops the oldest requests beyond MaxQueued", func() {
+		opts.MaxQueued = 2
+		queue := newQueue()
+		client.down = true
+
+		post(queue, "https://director/events", "event-1")
+		post(queue, "https://director/events", "event-2")
+		post(queue, "https://director/events", "event-3")
+		Expect(queueLen(queue)).To(Equal(2))
+
+		client.down = false
+		Expect(queue.Flush()).To(Succeed())
+		Expect(client.bodies).To(Equal([]string{"event-2", "event-3"}))
+	})
+
+	It("keeps requests queued while the endpoint responds with server errors", func() {
+		queue := newQueue()
+		client.down = true
+		post(queue, "https://director/events", "event-1")
+
+		client.down = false
+		client.status = http.StatusServiceUnavailable
+
+		err := queue.Flush()
+		Expect(err).To(MatchError(ContainSubstring("endpoint responded with status")))
+		Expect(queueLen(queue)).To(Equal(1))
+
+		client.status = http.StatusBadRequest
+		Expect(queue.Flush()).To(Succeed())
+		Expect(queueLen(queue)).To(Equal(0))
+	})
+
+	It("does not queue requests to other endpoints", func() {
+		queue := newQueue()
+		client.down = true
+
+		req, err := http.NewRequest("GET", "https://other/", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = queue.Do(req)
+		Expect(err).To(MatchError("fake-network-down"))
+		Expect(queueLen(queue)).To(Equal(0))
+	})
+})
@@ -0,0 +1,229 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// ResumeMismatchError is returned from a resumed download when the server
+// can no longer serve the remainder of the original representation
+// (e.g. the content changed or ranges are not honored).
+type ResumeMismatchError struct {
+	URL    string
+	Offset int64
+	Reason string
+}
+
+func (e ResumeMismatchError) Error() string {
+	return fmt.Sprintf("Resuming download of '%s' at offset %d: %s", e.URL, e.Offset, e.Reason)
+}
+
+// resumableBody continues a GET response body that fails part way through
+// by requesting the remaining bytes with Range and If-Match.
+type resumableBody struct {
+	delegate Client
+	request  *http.Request
+	body     io.ReadCloser
+
+	offset          int64
+	total           int64
+	etag            string
+	contentEncoding string
+
+	attemptsLeft uint
+	retryDelay   time.Duration
+	timeService  clock.Clock
+	readErr      error
+
+	logger boshlog.Logger
+	logTag string
+}
+
+// isResumable only allows responses whose bytes on the wire are exactly the
+// bytes handed to the caller: transparently decompressed responses would make
+// offsets meaningless for a Range request.
+func isResumable(req *http.Request, resp *http.Response) bool {
+	if req.Method != "GET" || req.Header.Get("Range") != "" {
+		return false
+	}
+
+	if resp.StatusCode != http.StatusOK || resp.Uncompressed {
+		return false
+	}
+
+	if resp.Header.Get("Accept-Ranges") == "none" {
+		return false
+	}
+
+	etag := resp.Header.Get("ETag")
+	return etag != "" && !strings.HasPrefix(etag, "W/")
+}
+
+func newResumableBody(delegate Client, req *http.Request, resp *http.Response, attempts uint, retryDelay time.Duration, timeService clock.Clock, logger boshlog.Logger) *resumableBody {
+	return &resumableBody{
+		delegate:        delegate,
+		request:         req,
+		body:            resp.Body,
+		total:           resp.ContentLength,
+		etag:            resp.Header.Get("ETag"),
+		contentEncoding: resp.Header.Get("Content-Encoding"),
+		attemptsLeft:    attempts,
+		retryDelay:      retryDelay,
+		timeService:     timeService,
+		logger:          logger,
+		logTag:          "resumableBody",
+	}
+}
+
+func (b *resumableBody) Read(p []byte) (int, error) {
+	if b.readErr != nil {
+		err := b.resume()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := b.body.Read(p)
+	b.offset += int64(n)
+
+	if err == nil || err == io.EOF || !b.canResume() {
+		return n, err
+	}
+
+	b.readErr = err
+	if n > 0 {
+		return n, nil
+	}
+
+	return b.Read(p)
+}
+
+func (b *resumableBody) Close() error {
+	return b.body.Close()
+}
+
+func (b *resumableBody) canResume() bool {
+	return b.attemptsLeft > 0 && b.request.Context().Err() == nil
+}
+
+func (b *resumableBody) resume() error {
+	cause := b.readErr
+	b.readErr = nil
+
+	for b.attemptsLeft > 0 {
+		b.attemptsLeft--
+
+		select {
+		case <-b.timeService.After(b.retryDelay):
+		case <-b.request.Context().Done():
+			return bosherr.WrapErrorf(cause, "Reading response body at offset %d", b.offset)
+		}
+
+		b.logger.Debug(b.logTag, "Resuming %s at offset %d after: %s", formatRequest(b.request), b.offset, cause)
+
+		resp, err := b.delegate.Do(b.rangeRequest())
+		if err != nil {
+			cause = err
+			continue
+		}
+
+		err = b.verify(resp)
+		if err != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+
+			if _, ok := err.(ResumeMismatchError); ok {
+				return err
+			}
+
+			cause = err
+			continue
+		}
+
+		b.body.Close()
+		b.body = resp.Body
+
+		return nil
+	}
+
+	return bosherr.WrapErrorf(cause, "Reading response body at offset %d", b.offset)
+}
+
+func (b *resumableBody) rangeRequest() *http.Request {
+	req := b.request.Clone(b.request.Context())
+	req.Body = nil
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+	req.Header.Set("If-Match", b.etag)
+
+	return req
+}
+
+func (b *resumableBody) verify(resp *http.Response) error {
+	mismatch := func(format string, args ...interface{}) error {
+		return ResumeMismatchError{URL: b.request.URL.String(), Offset: b.offset, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return mismatch("content changed since the download started")
+	case resp.StatusCode >= 500:
+		return bosherr.Errorf("Resuming download failed: %s", formatResponse(resp))
+	case resp.StatusCode != http.StatusPartialContent:
+		return mismatch("expected partial content but got %s", formatResponse(resp))
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" && etag != b.etag {
+		return mismatch("ETag changed from '%s' to '%s'", b.etag, etag)
+	}
+
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != b.contentEncoding {
+		return mismatch("Content-Encoding changed from '%s' to '%s'", b.contentEncoding, encoding)
+	}
+
+	contentRange := resp.Header.Get("Content-Range")
+
+	start, total, err := parseContentRange(contentRange)
+	if err != nil {
+		return mismatch("invalid Content-Range '%s'", contentRange)
+	}
+
+	if start != b.offset || (b.total >= 0 && total >= 0 && total != b.total) {
+		return mismatch("unexpected Content-Range '%s'", contentRange)
+	}
+
+	return nil
+}
+
+// parseContentRange returns the first byte position and the complete
+// length (-1 when unknown) of a "bytes first-last/length" header.
+func parseContentRange(contentRange string) (int64, int64, error) {
+	var start, end int64
+
+	parts := strings.SplitN(strings.TrimPrefix(contentRange, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, bosherr.Errorf("Missing complete length")
+	}
+
+	_, err := fmt.Sscanf(parts[0], "%d-%d", &start, &end)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if parts[1] == "*" {
+		return start, -1, nil
+	}
+
+	var total int64
+	_, err = fmt.Sscanf(parts[1], "%d", &total)
+
+	return start, total, err
+}
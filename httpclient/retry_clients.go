@@ -1,20 +1,101 @@
 package httpclient
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/cloudfoundry/bosh-utils/errors"
+	"code.cloudfoundry.org/clock"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
-	boshretry "github.com/cloudfoundry/bosh-utils/retrystrategy"
 )
 
+// DefaultMaxRetryAfter caps how long retry clients wait when
+// a server asks to retry later (see WithMaxRetryAfter)
+const DefaultMaxRetryAfter = time.Minute
+
+// RetryAttempt describes a single attempt of a retry client
+type RetryAttempt struct {
+	// StatusCode is 0 when no response was received
+	StatusCode int
+	Err        error
+
+	// Delay is the time waited before the next attempt
+	Delay time.Duration
+}
+
+// RetriesExhaustedError is returned by retry clients when the last
+// attempt failed with an error and no attempts are left. Failed
+// responses without an error are still returned as they are.
+type RetriesExhaustedError struct {
+	Attempts   []RetryAttempt
+	TotalDelay time.Duration
+	Err        error
+}
+
+func (e RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("Giving up after %d attempts and waiting %s: %s", len(e.Attempts), e.TotalDelay, e.Err)
+}
+
+func (e RetriesExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+func IsRetriesExhaustedError(err error) bool {
+	var exhaustedErr RetriesExhaustedError
+	return errors.As(err, &exhaustedErr)
+}
+
 type retryClient struct {
 	delegate              Client
 	maxAttempts           uint
 	retryDelay            time.Duration
 	logger                boshlog.Logger
 	isResponseAttemptable func(*http.Response, error) (bool, error)
+	resumeDownloads       bool
+	maxRetryAfter         time.Duration
+	backoffHook           BackoffHook
+	timeService           clock.Clock
+}
+
+type RetryClientOption func(*retryClient)
+
+// WithRetryClock replaces the clock used to wait between attempts,
+// e.g. with a fakeclock in tests
+func WithRetryClock(timeService clock.Clock) RetryClientOption {
+	return func(client *retryClient) {
+		client.timeService = timeService
+	}
+}
+
+// WithMaxRetryAfter caps the delay requested by the Retry-After header of
+// 429 and 503 responses, which otherwise replaces the retry delay when it
+// is longer. Defaults to DefaultMaxRetryAfter; 0 ignores Retry-After.
+func WithMaxRetryAfter(max time.Duration) RetryClientOption {
+	return func(client *retryClient) {
+		client.maxRetryAfter = max
+	}
+}
+
+// WithBackoffHook is called with the delay before every retry,
+// e.g. to assert the retry schedule in tests
+func WithBackoffHook(hook BackoffHook) RetryClientOption {
+	return func(client *retryClient) {
+		client.backoffHook = hook
+	}
+}
+
+func applyRetryClientOptions(client *retryClient, opts []RetryClientOption) Client {
+	client.timeService = clock.NewClock()
+	client.maxRetryAfter = DefaultMaxRetryAfter
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
 }
 
 func NewRetryClient(
@@ -22,14 +103,15 @@ func NewRetryClient(
 	maxAttempts uint,
 	retryDelay time.Duration,
 	logger boshlog.Logger,
+	opts ...RetryClientOption,
 ) Client {
-	return &retryClient{
+	return applyRetryClientOptions(&retryClient{
 		delegate:              delegate,
 		maxAttempts:           maxAttempts,
 		retryDelay:            retryDelay,
 		logger:                logger,
 		isResponseAttemptable: nil,
-	}
+	}, opts)
 }
 
 func NewNetworkSafeRetryClient(
@@ -37,26 +119,145 @@ func NewNetworkSafeRetryClient(
 	maxAttempts uint,
 	retryDelay time.Duration,
 	logger boshlog.Logger,
+	opts ...RetryClientOption,
 ) Client {
-	return &retryClient{
+	return applyRetryClientOptions(&retryClient{
 		delegate:    delegate,
 		maxAttempts: maxAttempts,
 		retryDelay:  retryDelay,
 		logger:      logger,
 		isResponseAttemptable: func(resp *http.Response, err error) (bool, error) {
 			if err != nil || ((resp.Request.Method == "GET" || resp.Request.Method == "HEAD") && (resp.StatusCode == http.StatusGatewayTimeout || resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusBadGateway)) {
-				return true, errors.WrapError(err, "Retry")
+				return true, bosherr.WrapError(err, "Retry")
 			}
 
 			return false, nil
 		},
-	}
+	}, opts)
+}
+
+// NewResumableRetryClient behaves like NewRetryClient and additionally
+// resumes GET response bodies that fail part way through. The remaining
+// bytes are requested with Range and If-Match, so only responses with a
+// strong ETag that were not transparently decompressed are resumed.
+func NewResumableRetryClient(
+	delegate Client,
+	maxAttempts uint,
+	retryDelay time.Duration,
+	logger boshlog.Logger,
+	opts ...RetryClientOption,
+) Client {
+	return applyRetryClientOptions(&retryClient{
+		delegate:        delegate,
+		maxAttempts:     maxAttempts,
+		retryDelay:      retryDelay,
+		logger:          logger,
+		resumeDownloads: true,
+	}, opts)
 }
 
 func (r *retryClient) Do(req *http.Request) (*http.Response, error) {
 	requestRetryable := NewRequestRetryable(req, r.delegate, r.logger, r.isResponseAttemptable)
-	retryStrategy := boshretry.NewAttemptRetryStrategy(int(r.maxAttempts), r.retryDelay, requestRetryable, r.logger)
-	err := retryStrategy.Try()
 
-	return requestRetryable.Response(), err
+	err := r.try(requestRetryable)
+
+	resp := requestRetryable.Response()
+	if err == nil && r.resumeDownloads && r.maxAttempts > 1 && resp != nil && isResumable(req, resp) {
+		resp.Body = newResumableBody(r.delegate, req, resp, r.maxAttempts-1, r.retryDelay, r.timeService, r.logger)
+	}
+
+	return resp, err
+}
+
+func (r *retryClient) try(requestRetryable *RequestRetryable) error {
+	var attempts []RetryAttempt
+	var totalDelay time.Duration
+
+	for i := uint(0); i < r.maxAttempts; i++ {
+		shouldRetry, err := requestRetryable.Attempt()
+		if !shouldRetry {
+			return err
+		}
+
+		attempt := RetryAttempt{Err: err}
+		if resp := requestRetryable.Response(); resp != nil {
+			attempt.StatusCode = resp.StatusCode
+		}
+
+		if i == r.maxAttempts-1 {
+			attempts = append(attempts, attempt)
+			if err != nil {
+				return RetriesExhaustedError{Attempts: attempts, TotalDelay: totalDelay, Err: err}
+			}
+			return nil
+		}
+
+		attempt.Delay = r.delayAfter(requestRetryable.Response())
+		attempts = append(attempts, attempt)
+		totalDelay += attempt.Delay
+
+		if r.backoffHook != nil {
+			r.backoffHook(int(i)+1, attempt.Delay)
+		}
+
+		r.timeService.Sleep(attempt.Delay)
+	}
+
+	return nil
+}
+
+// delayAfter honors Retry-After of throttled and unavailable responses
+// up to maxRetryAfter, but never waits less than the retry delay
+func (r *retryClient) delayAfter(resp *http.Response) time.Duration {
+	if resp == nil || r.maxRetryAfter <= 0 {
+		return r.retryDelay
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return r.retryDelay
+	}
+
+	retryAfter, found := parseRetryAfter(resp.Header.Get("Retry-After"), r.timeService.Now())
+	if !found {
+		return r.retryDelay
+	}
+
+	if retryAfter > r.maxRetryAfter {
+		r.logger.Warn("clientRetryable", "Waiting %s instead of %s requested by Retry-After", r.maxRetryAfter, retryAfter)
+		retryAfter = r.maxRetryAfter
+	}
+
+	if retryAfter < r.retryDelay {
+		return r.retryDelay
+	}
+
+	return retryAfter
+}
+
+// parseRetryAfter accepts delay seconds and HTTP dates
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		if seconds > math.MaxInt64/int64(time.Second) {
+			seconds = math.MaxInt64 / int64(time.Second)
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+
+	return 0, true
 }
@@ -1,10 +1,14 @@
 package httpclient_test
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/clock/fakeclock"
+
 	"github.com/cloudfoundry/bosh-utils/httpclient"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 
@@ -68,6 +72,35 @@ var _ = Describe("RetryClients", func() {
 				Expect(server.ReceivedRequests()).To(HaveLen(1))
 			})
 
+			It("waits for the retry delay on the given clock", func() {
+				fakeTimeService := fakeclock.NewFakeClock(time.Now())
+				client := &http.Client{Transport: &http.Transport{}}
+				retryClient = httpclient.NewRetryClient(client, 3, time.Hour, boshlog.NewLogger(boshlog.LevelNone), httpclient.WithRetryClock(fakeTimeService))
+
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusInternalServerError, "fake-error"),
+					ghttp.RespondWith(http.StatusOK, "fake-response-body"),
+				)
+
+				req, err := http.NewRequest("GET", server.URL(), nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				respCh := make(chan *http.Response)
+				go func() {
+					defer GinkgoRecover()
+					resp, err := retryClient.Do(req)
+					Expect(err).NotTo(HaveOccurred())
+					respCh <- resp
+				}()
+
+				fakeTimeService.WaitForWatcherAndIncrement(time.Hour)
+
+				var resp *http.Response
+				Eventually(respCh).Should(Receive(&resp))
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+			})
+
 			It("retries for maxAttempts if request is failing", func() {
 				server.RouteToHandler("GET", "/", ghttp.RespondWith(http.StatusNotFound, "fake-response-body"))
 
@@ -80,6 +113,126 @@ var _ = Describe("RetryClients", func() {
 				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
 				Expect(server.ReceivedRequests()).To(HaveLen(maxAttempts))
 			})
+
+			Context("when the server responds with Retry-After", func() {
+				var (
+					fakeTimeService *fakeclock.FakeClock
+					respCh          chan *http.Response
+				)
+
+				doAsync := func(opts ...httpclient.RetryClientOption) {
+					client := &http.Client{Transport: &http.Transport{}}
+					opts = append(opts, httpclient.WithRetryClock(fakeTimeService))
+					retryClient = httpclient.NewRetryClient(client, 3, time.Second, boshlog.NewLogger(boshlog.LevelNone), opts...)
+
+					req, err := http.NewRequest("GET", server.URL(), nil)
+					Expect(err).NotTo(HaveOccurred())
+
+					go func() {
+						defer GinkgoRecover()
+						resp, err := retryClient.Do(req)
+						Expect(err).NotTo(HaveOccurred())
+						respCh <- resp
+					}()
+				}
+
+				BeforeEach(func() {
+					fakeTimeService = fakeclock.NewFakeClock(time.Now())
+					respCh = make(chan *http.Response)
+
+					server.AppendHandlers(
+						ghttp.RespondWith(http.StatusTooManyRequests, "slow down", http.Header{"Retry-After": {"120"}}),
+						ghttp.RespondWith(http.StatusOK, "fake-response-body"),
+					)
+				})
+
+				It("waits for the requested delay", func() {
+					doAsync(httpclient.WithMaxRetryAfter(time.Hour))
+
+					fakeTimeService.WaitForWatcherAndIncrement(time.Minute)
+					Consistently(respCh).ShouldNot(Receive())
+
+					fakeTimeService.Increment(time.Minute)
+					Eventually(respCh).Should(Receive())
+					Expect(server.ReceivedRequests()).To(HaveLen(2))
+				})
+
+				It("waits no longer than the maximum", func() {
+					doAsync(httpclient.WithMaxRetryAfter(10 * time.Second))
+
+					fakeTimeService.WaitForWatcherAndIncrement(10 * time.Second)
+					Eventually(respCh).Should(Receive())
+				})
+
+				It("waits no longer than DefaultMaxRetryAfter by default", func() {
+					doAsync()
+
+					fakeTimeService.WaitForWatcherAndIncrement(httpclient.DefaultMaxRetryAfter)
+					Eventually(respCh).Should(Receive())
+				})
+
+				It("ignores Retry-After when the maximum is 0", func() {
+					doAsync(httpclient.WithMaxRetryAfter(0))
+
+					fakeTimeService.WaitForWatcherAndIncrement(time.Second)
+					Eventually(respCh).Should(Receive())
+				})
+			})
+
+			It("returns the attempts when retries are exhausted with an error", func() {
+				serverURL := server.URL()
+				server.Close()
+
+				client := &http.Client{Transport: &http.Transport{}}
+				retryClient = httpclient.NewRetryClient(client, 3, time.Millisecond, boshlog.NewLogger(boshlog.LevelNone))
+
+				req, err := http.NewRequest("GET", serverURL, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = retryClient.Do(req)
+				Expect(err).To(HaveOccurred())
+				Expect(httpclient.IsRetriesExhaustedError(err)).To(BeTrue())
+				Expect(err.Error()).To(ContainSubstring("Giving up after 3 attempts and waiting 2ms"))
+
+				var exhaustedErr httpclient.RetriesExhaustedError
+				Expect(errors.As(err, &exhaustedErr)).To(BeTrue())
+				Expect(exhaustedErr.TotalDelay).To(Equal(2 * time.Millisecond))
+				Expect(exhaustedErr.Attempts).To(HaveLen(3))
+				for i, attempt := range exhaustedErr.Attempts {
+					Expect(attempt.StatusCode).To(Equal(0))
+					Expect(attempt.Err).To(HaveOccurred())
+					if i < 2 {
+						Expect(attempt.Delay).To(Equal(time.Millisecond))
+					} else {
+						Expect(attempt.Delay).To(BeZero())
+					}
+				}
+			})
+
+			It("passes every delay to the backoff hook", func() {
+				serverURL := server.URL()
+				server.Close()
+
+				type delay struct {
+					attempt int
+					delay   time.Duration
+				}
+				var delays []delay
+
+				client := &http.Client{Transport: &http.Transport{}}
+				retryClient = httpclient.NewRetryClient(client, 3, time.Millisecond, boshlog.NewLogger(boshlog.LevelNone),
+					httpclient.WithBackoffHook(func(attempt int, d time.Duration) {
+						delays = append(delays, delay{attempt, d})
+					}))
+
+				req, err := http.NewRequest("GET", serverURL, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = retryClient.Do(req)
+				Expect(err).To(HaveOccurred())
+
+				Expect(delays).To(Equal([]delay{{1, time.Millisecond}, {2, time.Millisecond}}))
+			})
 		})
 	})
 
@@ -264,4 +417,130 @@ var _ = Describe("RetryClients", func() {
 			}
 		})
 	})
+
+	Describe("ResumableRetryClient", func() {
+		var (
+			server      *ghttp.Server
+			retryClient httpclient.Client
+			content     string
+		)
+
+		truncatedResponse := func(etag string) http.HandlerFunc {
+			return func(w http.ResponseWriter, req *http.Request) {
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nETag: %s\r\nContent-Length: %d\r\n\r\n%s", etag, len(content), content[:4])
+				buf.Flush()
+			}
+		}
+
+		partialResponse := func(etag string, offset int) http.HandlerFunc {
+			return func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("ETag", etag)
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(content)-1, len(content)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(content[offset:]))
+			}
+		}
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			content = "fake-artifact-content"
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			client := &http.Client{Transport: &http.Transport{}}
+
+			retryClient = httpclient.NewResumableRetryClient(client, 3, 0, logger)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("resumes a body that fails part way through from the last offset", func() {
+			server.AppendHandlers(
+				truncatedResponse(`"fake-etag"`),
+				ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("Range", "bytes=4-"),
+					ghttp.VerifyHeaderKV("If-Match", `"fake-etag"`),
+					partialResponse(`"fake-etag"`, 4),
+				),
+			)
+
+			req, err := http.NewRequest("GET", server.URL(), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := retryClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(readString(resp.Body)).To(Equal(content))
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+
+		It("fails when the content changed since the download started", func() {
+			server.AppendHandlers(
+				truncatedResponse(`"fake-etag"`),
+				ghttp.RespondWith(http.StatusPreconditionFailed, ""),
+			)
+
+			req, err := http.NewRequest("GET", server.URL(), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := retryClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = ioutil.ReadAll(resp.Body)
+			Expect(err).To(BeAssignableToTypeOf(httpclient.ResumeMismatchError{}))
+			Expect(err.(httpclient.ResumeMismatchError).Offset).To(Equal(int64(4)))
+		})
+
+		It("fails when the server does not continue at the requested offset", func() {
+			server.AppendHandlers(
+				truncatedResponse(`"fake-etag"`),
+				partialResponse(`"fake-etag"`, 0),
+			)
+
+			req, err := http.NewRequest("GET", server.URL(), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := retryClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = ioutil.ReadAll(resp.Body)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unexpected Content-Range 'bytes 0-20/21'"))
+		})
+
+		It("retries resuming up to the remaining attempts", func() {
+			server.AppendHandlers(
+				truncatedResponse(`"fake-etag"`),
+				ghttp.RespondWith(http.StatusServiceUnavailable, ""),
+				partialResponse(`"fake-etag"`, 4),
+			)
+
+			req, err := http.NewRequest("GET", server.URL(), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := retryClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(readString(resp.Body)).To(Equal(content))
+			Expect(server.ReceivedRequests()).To(HaveLen(3))
+		})
+
+		It("does not resume responses without a strong ETag", func() {
+			server.AppendHandlers(truncatedResponse(`W/"fake-etag"`))
+
+			req, err := http.NewRequest("GET", server.URL(), nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := retryClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = ioutil.ReadAll(resp.Body)
+			Expect(err).To(HaveOccurred())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
 })
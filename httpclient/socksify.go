@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	proxy "github.com/cloudfoundry/socks5-proxy"
 
@@ -25,6 +26,16 @@ func (f DialContextFunc) DialContext(ctx context.Context, network, address strin
 	return f(ctx, network, address)
 }
 
+// SOCKS5DialContextFuncFromEnvironment dials through the proxy in
+// BOSH_ALL_PROXY, either a SOCKS5 proxy ("socks5://host:port") or an SSH
+// jumpbox ("ssh+socks5://user@host:port?private-key=path"). The private key
+// may be in any format understood by crypto.ParsePrivateKey; encrypted keys
+// are decrypted with BOSH_ALL_PROXY_KEY_PASSPHRASE.
+//
+// Hostnames are resolved by the proxy, which matters when only the jumpbox
+// can resolve them. Use "socks5h://" or "?resolve=remote" to make this
+// explicit, or "?resolve=local" to resolve hostnames before dialing the
+// proxy like curl does for "socks5://".
 func SOCKS5DialContextFuncFromEnvironment(origDialer *net.Dialer, socks5Proxy ProxyDialer) DialContextFunc {
 	allProxy := os.Getenv("BOSH_ALL_PROXY")
 	if len(allProxy) == 0 {
@@ -49,6 +60,11 @@ func SOCKS5DialContextFuncFromEnvironment(origDialer *net.Dialer, socks5Proxy Pr
 			username = proxyURL.User.Username()
 		}
 
+		resolveLocally, err := proxyResolvesLocally(proxyURL.Scheme, queryMap)
+		if err != nil {
+			return errorDialFunc(err, "Parsing BOSH_ALL_PROXY query params")
+		}
+
 		proxySSHKeyPath := queryMap.Get("private-key")
 		if proxySSHKeyPath == "" {
 			return errorDialFunc(
@@ -62,11 +78,16 @@ func SOCKS5DialContextFuncFromEnvironment(origDialer *net.Dialer, socks5Proxy Pr
 			return errorDialFunc(err, "Reading private key file for SOCKS5 Proxy")
 		}
 
+		proxySSHKey, err = proxySSHKeyPEM(proxySSHKey, os.Getenv("BOSH_ALL_PROXY_KEY_PASSPHRASE"))
+		if err != nil {
+			return errorDialFunc(err, "Parsing private key file for SOCKS5 Proxy")
+		}
+
 		var (
 			dialer proxy.DialFunc
 			mut    sync.RWMutex
 		)
-		return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return resolvingDialFunc(resolveLocally, func(ctx context.Context, network, address string) (net.Conn, error) {
 			mut.RLock()
 			haveDialer := dialer != nil
 			mut.RUnlock()
@@ -85,7 +106,7 @@ func SOCKS5DialContextFuncFromEnvironment(origDialer *net.Dialer, socks5Proxy Pr
 				dialer = proxyDialer
 			}
 			return dialer(network, address)
-		}
+		})
 	}
 
 	proxyURL, err := url.Parse(allProxy)
@@ -93,23 +114,98 @@ func SOCKS5DialContextFuncFromEnvironment(origDialer *net.Dialer, socks5Proxy Pr
 		return errorDialFunc(err, "Parsing BOSH_ALL_PROXY url")
 	}
 
+	queryMap, err := url.ParseQuery(proxyURL.RawQuery)
+	if err != nil {
+		return errorDialFunc(err, "Parsing BOSH_ALL_PROXY query params")
+	}
+
+	resolveLocally, err := proxyResolvesLocally(proxyURL.Scheme, queryMap)
+	if err != nil {
+		return errorDialFunc(err, "Parsing BOSH_ALL_PROXY query params")
+	}
+
 	proxy, err := goproxy.FromURL(proxyURL, origDialer)
 	if err != nil {
 		return errorDialFunc(err, "Parsing BOSH_ALL_PROXY url")
 	}
 
-	perHost := goproxy.NewPerHost(proxy, origDialer)
+	noProxy := NoProxyFromEnvironment()
+
+	proxyDialFunc := resolvingDialFunc(resolveLocally, func(ctx context.Context, network, address string) (net.Conn, error) {
+		if contextDialer, ok := proxy.(goproxy.ContextDialer); ok {
+			return contextDialer.DialContext(ctx, network, address)
+		}
+
+		return proxy.Dial(network, address)
+	})
 
-	noProxy := os.Getenv("NO_PROXY")
-	if len(noProxy) == 0 {
-		noProxy = os.Getenv("no_proxy")
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if noProxy.Matches(address) {
+			return origDialer.DialContext(ctx, network, address)
+		}
+
+		return proxyDialFunc(ctx, network, address)
 	}
+}
 
-	if len(noProxy) != 0 {
-		perHost.AddFromString(noProxy)
+// proxySSHKeyPEM converts keys to unencrypted PKCS#8 since the SOCKS5 proxy
+// only parses unencrypted PEM keys itself. Keys that can not be parsed here
+// are left for the proxy to report unless a passphrase was given.
+func proxySSHKeyPEM(key []byte, passphrase string) ([]byte, error) {
+	signer, err := boshcrypto.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	if err != nil {
+		if passphrase != "" || boshcrypto.IsPassphraseMissingError(err) {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	return boshcrypto.MarshalPrivateKeyPEM(signer)
+}
+
+func proxyResolvesLocally(scheme string, query url.Values) (bool, error) {
+	switch query.Get("resolve") {
+	case "", "remote":
+		return false, nil
+	case "local":
+		if scheme == "socks5h" {
+			return false, bosherr.Error("Query param 'resolve=local' conflicts with the socks5h scheme")
+		}
+		return true, nil
+	default:
+		return false, bosherr.Errorf("Expected query param 'resolve' to be 'local' or 'remote' but got '%s'", query.Get("resolve"))
+	}
+}
+
+// resolvingDialFunc resolves hostnames before passing them to dial
+// when resolveLocally is set, trying each address in turn
+func resolvingDialFunc(resolveLocally bool, dial DialContextFunc) DialContextFunc {
+	if !resolveLocally {
+		return dial
 	}
 
-	return perHost.DialContext
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, address)
+		}
+
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Resolving '%s' before dialing SOCKS5 proxy", host)
+		}
+
+		var lastErr error
+		for _, addr := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(addr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+
+		return nil, lastErr
+	}
 }
 
 func errorDialFunc(err error, cause string) DialContextFunc {
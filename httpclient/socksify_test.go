@@ -2,7 +2,11 @@ package httpclient_test
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
@@ -11,10 +15,12 @@ import (
 
 	"errors"
 
+	boshcrypto "github.com/cloudfoundry/bosh-utils/crypto"
 	. "github.com/cloudfoundry/bosh-utils/httpclient"
 	proxy "github.com/cloudfoundry/socks5-proxy"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
 )
 
 var _ = Describe("Socksify", func() {
@@ -108,6 +114,135 @@ var _ = Describe("Socksify", func() {
 				Expect(proxyDialer.DialerCall.Receives.URL).To(Equal("localhost:12345"))
 			})
 
+			Context("when the private key is encrypted", func() {
+				var privateKeyPath string
+
+				BeforeEach(func() {
+					_, key, err := ed25519.GenerateKey(rand.Reader)
+					Expect(err).NotTo(HaveOccurred())
+
+					block, err := ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte("fake-passphrase"))
+					Expect(err).NotTo(HaveOccurred())
+
+					tempDir, err := ioutil.TempDir("", "")
+					Expect(err).NotTo(HaveOccurred())
+					privateKeyPath = filepath.Join(tempDir, "test.key")
+					err = ioutil.WriteFile(privateKeyPath, pem.EncodeToMemory(block), 0600)
+					Expect(err).NotTo(HaveOccurred())
+
+					os.Setenv("BOSH_ALL_PROXY", fmt.Sprintf("ssh+socks5://localhost:12345?private-key=%s", privateKeyPath))
+				})
+
+				AfterEach(func() {
+					os.Unsetenv("BOSH_ALL_PROXY_KEY_PASSPHRASE")
+				})
+
+				It("passes the decrypted key to the proxy", func() {
+					os.Setenv("BOSH_ALL_PROXY_KEY_PASSPHRASE", "fake-passphrase")
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+
+					_, err := dialFunc(ctx, "", "")
+					Expect(err).To(MatchError("proxy dialer"))
+
+					_, err = ssh.ParsePrivateKey([]byte(proxyDialer.DialerCall.Receives.Key))
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("returns a dialer that returns an error without passphrase", func() {
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+
+					_, err := dialFunc(ctx, "", "")
+					Expect(err).To(MatchError(ContainSubstring("Parsing private key file for SOCKS5 Proxy")))
+					Expect(boshcrypto.IsPassphraseMissingError(err)).To(BeTrue())
+					Expect(proxyDialer.DialerCall.CallCount).To(Equal(0))
+				})
+
+				It("returns a dialer that returns an error with the wrong passphrase", func() {
+					os.Setenv("BOSH_ALL_PROXY_KEY_PASSPHRASE", "wrong-passphrase")
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+
+					_, err := dialFunc(ctx, "", "")
+					Expect(err).To(MatchError(ContainSubstring("Parsing private key file for SOCKS5 Proxy")))
+					Expect(proxyDialer.DialerCall.CallCount).To(Equal(0))
+				})
+			})
+
+			Context("when resolving hostnames", func() {
+				var (
+					privateKeyPath string
+					dialedAddress  string
+				)
+
+				BeforeEach(func() {
+					tempDir, err := ioutil.TempDir("", "")
+					Expect(err).NotTo(HaveOccurred())
+					privateKeyPath = filepath.Join(tempDir, "test.key")
+					err = ioutil.WriteFile(privateKeyPath, []byte("some-key"), 0600)
+					Expect(err).NotTo(HaveOccurred())
+
+					proxyDialer.DialerCall.Returns.DialFunc = proxy.DialFunc(func(network, address string) (net.Conn, error) {
+						dialedAddress = address
+						return nil, errors.New("proxy dialer")
+					})
+				})
+
+				dialWithProxy := func(allProxy string) {
+					os.Setenv("BOSH_ALL_PROXY", allProxy)
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+
+					_, err := dialFunc(ctx, "tcp", "localhost:25555")
+					Expect(err).To(MatchError("proxy dialer"))
+				}
+
+				It("leaves hostnames to the proxy by default", func() {
+					dialWithProxy(fmt.Sprintf("ssh+socks5://localhost:12345?private-key=%s", privateKeyPath))
+					Expect(dialedAddress).To(Equal("localhost:25555"))
+				})
+
+				It("leaves hostnames to the proxy with the socks5h scheme", func() {
+					dialWithProxy(fmt.Sprintf("ssh+socks5h://localhost:12345?private-key=%s", privateKeyPath))
+					Expect(dialedAddress).To(Equal("localhost:25555"))
+				})
+
+				It("leaves hostnames to the proxy with resolve=remote", func() {
+					dialWithProxy(fmt.Sprintf("ssh+socks5://localhost:12345?private-key=%s&resolve=remote", privateKeyPath))
+					Expect(dialedAddress).To(Equal("localhost:25555"))
+				})
+
+				It("resolves hostnames before dialing with resolve=local", func() {
+					dialWithProxy(fmt.Sprintf("ssh+socks5://localhost:12345?private-key=%s&resolve=local", privateKeyPath))
+					Expect(dialedAddress).To(Or(Equal("127.0.0.1:25555"), Equal("[::1]:25555")))
+				})
+
+				It("does not resolve IP addresses", func() {
+					os.Setenv("BOSH_ALL_PROXY", fmt.Sprintf("ssh+socks5://localhost:12345?private-key=%s&resolve=local", privateKeyPath))
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+
+					_, err := dialFunc(ctx, "tcp", "10.0.0.1:25555")
+					Expect(err).To(MatchError("proxy dialer"))
+					Expect(dialedAddress).To(Equal("10.0.0.1:25555"))
+				})
+
+				It("rejects resolve=local with the socks5h scheme", func() {
+					os.Setenv("BOSH_ALL_PROXY", fmt.Sprintf("ssh+socks5h://localhost:12345?private-key=%s&resolve=local", privateKeyPath))
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+
+					_, err := dialFunc(ctx, "tcp", "localhost:25555")
+					Expect(err).To(MatchError(ContainSubstring("conflicts with the socks5h scheme")))
+				})
+
+				It("rejects unknown resolve values", func() {
+					os.Setenv("BOSH_ALL_PROXY", fmt.Sprintf("ssh+socks5://localhost:12345?private-key=%s&resolve=proxy", privateKeyPath))
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+
+					_, err := dialFunc(ctx, "tcp", "localhost:25555")
+					Expect(err).To(MatchError(SatisfyAll(
+						ContainSubstring("Parsing BOSH_ALL_PROXY query params"),
+						ContainSubstring("'local' or 'remote' but got 'proxy'"),
+					)))
+				})
+			})
+
 			Context("when the URL after the ssh+ prefix cannot be parsed", func() {
 				BeforeEach(func() {
 					os.Setenv("BOSH_ALL_PROXY", fmt.Sprintf("ssh+:cannot-start-with-colon"))
@@ -181,6 +316,95 @@ var _ = Describe("Socksify", func() {
 				})
 			})
 
+			Context("when NO_PROXY is set", func() {
+				var listener net.Listener
+
+				BeforeEach(func() {
+					var err error
+					listener, err = net.Listen("tcp", "127.0.0.1:0")
+					Expect(err).NotTo(HaveOccurred())
+
+					os.Setenv("BOSH_ALL_PROXY", "socks5://127.0.0.1:1")
+					os.Setenv("NO_PROXY", "localhost, 127.0.0.0/8")
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+				})
+
+				AfterEach(func() {
+					listener.Close()
+					os.Unsetenv("NO_PROXY")
+				})
+
+				It("dials matching hosts directly", func() {
+					conn, err := dialFunc(ctx, "tcp", listener.Addr().String())
+					Expect(err).NotTo(HaveOccurred())
+					conn.Close()
+				})
+
+				It("dials other hosts through the proxy", func() {
+					_, err := dialFunc(ctx, "tcp", "10.0.0.1:80")
+					Expect(err).To(MatchError(ContainSubstring("127.0.0.1:1")))
+				})
+			})
+
+			Context("when the URL uses the socks5h scheme", func() {
+				var listener net.Listener
+
+				BeforeEach(func() {
+					var err error
+					listener, err = net.Listen("tcp", "127.0.0.1:0")
+					Expect(err).NotTo(HaveOccurred())
+
+					os.Setenv("BOSH_ALL_PROXY", "socks5h://"+listener.Addr().String())
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+				})
+
+				AfterEach(func() {
+					listener.Close()
+				})
+
+				It("sends hostnames to the proxy", func() {
+					requests := make(chan []byte, 1)
+					go func() {
+						defer GinkgoRecover()
+
+						conn, err := listener.Accept()
+						Expect(err).NotTo(HaveOccurred())
+						defer conn.Close()
+
+						greeting := make([]byte, 3)
+						_, err = io.ReadFull(conn, greeting)
+						Expect(err).NotTo(HaveOccurred())
+						_, err = conn.Write([]byte{5, 0})
+						Expect(err).NotTo(HaveOccurred())
+
+						request := make([]byte, 4+1+len("director.internal")+2)
+						_, err = io.ReadFull(conn, request)
+						Expect(err).NotTo(HaveOccurred())
+						requests <- request
+					}()
+
+					_, err := dialFunc(ctx, "tcp", "director.internal:25555")
+					Expect(err).To(HaveOccurred())
+
+					var request []byte
+					Eventually(requests).Should(Receive(&request))
+					Expect(request[3]).To(Equal(byte(3)), "expected a domain name address type")
+					Expect(string(request[5 : 5+request[4]])).To(Equal("director.internal"))
+				})
+			})
+
+			Context("when the URL has an invalid resolve query param", func() {
+				BeforeEach(func() {
+					os.Setenv("BOSH_ALL_PROXY", "socks5://127.0.0.1:1?resolve=proxy")
+					dialFunc = SOCKS5DialContextFuncFromEnvironment(&origDial, proxyDialer)
+				})
+
+				It("returns a dialer that returns the query param error", func() {
+					_, err := dialFunc(ctx, "", "")
+					Expect(err).To(MatchError(ContainSubstring("Parsing BOSH_ALL_PROXY query params")))
+				})
+			})
+
 			Context("when the URL is not a valid proxy scheme", func() {
 				BeforeEach(func() {
 					os.Setenv("BOSH_ALL_PROXY", fmt.Sprintf("foo://cannot-start-with-colon"))
@@ -0,0 +1,145 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type WarmupResult struct {
+	Host string
+
+	// StatusCode of the HEAD request; any status means the host is reachable
+	StatusCode int
+
+	// Reused is true when an idle connection to the host was already open
+	Reused bool
+
+	// ConnectDuration is how long it took to get a connection, including
+	// the proxy or SSH tunnel of BOSH_ALL_PROXY and the TLS handshake
+	ConnectDuration time.Duration
+	Duration        time.Duration
+
+	Err error
+}
+
+// Warmup sends a HEAD request to every host in parallel so that the
+// connections, TLS sessions and the SSH tunnel of BOSH_ALL_PROXY are set
+// up before the first real request. Hosts are URLs, "https://" is assumed
+// when they have no scheme. Connections are only kept for later requests
+// when the client has keep-alives enabled.
+//
+// The results are in the order of hosts. The error combines the errors of
+// all hosts that could not be reached and says whether connecting, the TLS
+// handshake or the request failed.
+func (c *HTTPClient) Warmup(ctx context.Context, hosts []string) ([]WarmupResult, error) {
+	results := make([]WarmupResult, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			results[i] = c.warmup(ctx, host)
+		}(i, host)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return results, bosherr.NewMultiError(errs...)
+	}
+
+	return results, nil
+}
+
+func (c *HTTPClient) warmup(ctx context.Context, host string) WarmupResult {
+	result := WarmupResult{Host: host}
+
+	endpoint := host
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	redactedEndpoint := endpoint
+	if !c.opts.NoRedactUrlQuery {
+		redactedEndpoint = scrubEndpointQuery(endpoint)
+	}
+
+	c.logger.Debug(c.logTag, "Warming up connection to '%s'", redactedEndpoint)
+
+	var (
+		lock    sync.Mutex
+		gotConn bool
+		tlsErr  error
+	)
+
+	start := time.Now()
+
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			tlsErr = err
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+			gotConn = true
+			result.Reused = info.Reused
+			result.ConnectDuration = time.Since(start)
+		},
+	}
+
+	request, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), "HEAD", endpoint, nil)
+	if err != nil {
+		result.Err = bosherr.WrapErrorf(err, "Creating warmup request to '%s'", redactedEndpoint)
+		return result
+	}
+
+	response, err := c.client.Do(request)
+	result.Duration = time.Since(start)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err != nil {
+		err = scrubErrorOutput(err)
+
+		switch {
+		case tlsErr != nil:
+			result.Err = bosherr.WrapErrorf(err, "Performing TLS handshake with '%s'", redactedEndpoint)
+		case !gotConn:
+			result.Err = bosherr.WrapErrorf(err, "Connecting to '%s'", redactedEndpoint)
+		default:
+			result.Err = bosherr.WrapErrorf(err, "Requesting '%s'", redactedEndpoint)
+		}
+
+		c.logger.Debug(c.logTag, "Warming up '%s' failed after %s: %s", redactedEndpoint, result.Duration, result.Err)
+		return result
+	}
+
+	// the connection only goes back to the idle pool once the body is read
+	_, _ = io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	result.StatusCode = response.StatusCode
+
+	c.logger.Debug(c.logTag, "Warmed up '%s' in %s (connected in %s, reused %t)",
+		redactedEndpoint, result.Duration, result.ConnectDuration, result.Reused)
+
+	return result
+}
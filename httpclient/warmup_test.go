@@ -0,0 +1,97 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("Warmup", func() {
+	var (
+		server   *httptest.Server
+		requests int32
+		client   *HTTPClient
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			Expect(r.Method).To(Equal("HEAD"))
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(server.Certificate())
+
+		client = NewHTTPClient(CreateKeepAliveDefaultClient(rootCAs), boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("connects to every host and keeps the connections for later requests", func() {
+		host := strings.TrimPrefix(server.URL, "https://")
+
+		results, err := client.Warmup(context.Background(), []string{server.URL, host})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Host).To(Equal(server.URL))
+		Expect(results[1].Host).To(Equal(host))
+		for _, result := range results {
+			Expect(result.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(result.ConnectDuration).To(BeNumerically(">", 0))
+			Expect(result.Duration).To(BeNumerically(">=", result.ConnectDuration))
+		}
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+
+		results, err = client.Warmup(context.Background(), []string{server.URL})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].Reused).To(BeTrue())
+	})
+
+	It("reports hosts that can not be connected to", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		closedHost := "https://" + listener.Addr().String()
+		listener.Close()
+
+		results, err := client.Warmup(context.Background(), []string{server.URL, closedHost})
+		Expect(err).To(MatchError(ContainSubstring("Connecting to '" + closedHost + "'")))
+
+		Expect(results[0].Err).ToNot(HaveOccurred())
+		Expect(results[1].Err).To(HaveOccurred())
+		Expect(results[1].StatusCode).To(BeZero())
+	})
+
+	It("reports failed TLS handshakes", func() {
+		client = NewHTTPClient(CreateKeepAliveDefaultClient(x509.NewCertPool()), boshlog.NewLogger(boshlog.LevelNone))
+
+		results, err := client.Warmup(context.Background(), []string{server.URL})
+		Expect(err).To(MatchError(ContainSubstring("Performing TLS handshake with '" + server.URL + "'")))
+		Expect(results[0].Err).To(HaveOccurred())
+	})
+
+	It("stops when the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		results, err := client.Warmup(ctx, []string{server.URL})
+		Expect(err).To(MatchError(ContainSubstring("context canceled")))
+		Expect(results[0].Err).To(HaveOccurred())
+	})
+})
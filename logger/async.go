@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log"
@@ -84,6 +85,15 @@ func (l *asyncLogger) FlushTimeout(d time.Duration) error {
 	}
 }
 
+func (l *asyncLogger) Close(ctx context.Context) error {
+	err := runUntilDone(ctx, l.Flush)
+	if err != nil {
+		return err
+	}
+
+	return flushWriter(ctx, l.writer.w)
+}
+
 func NewAsyncWriterLogger(level LogLevel, ioWriter io.Writer) Logger {
 	wout := newAsyncWriter(ioWriter)
 	return &asyncLogger{
@@ -121,12 +131,20 @@ func (l *asyncLogger) ErrorWithDetails(tag, msg string, args ...interface{}) {
 }
 
 func (l *asyncLogger) HandlePanic(tag string) {
-	if l.log.recoverPanic(tag) {
+	if e := recover(); e != nil {
+		l.log.logPanic(tag, e)
 		l.FlushTimeout(time.Second * 30)
 		os.Exit(2)
 	}
 }
 
+func (l *asyncLogger) RecoverAndLog(tag string) {
+	if e := recover(); e != nil {
+		l.log.logPanic(tag, e)
+		l.FlushTimeout(time.Second * 30)
+	}
+}
+
 func (l *asyncLogger) ToggleForcedDebug() {
 	l.log.ToggleForcedDebug()
 }
@@ -0,0 +1,182 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// replayMu keeps channels of loggers that can not take a block of output
+// at once from replaying their entries at the same time
+var replayMu sync.Mutex
+
+type channelLogger struct {
+	parent Logger
+
+	// log formats entries into buffer with the settings of parent,
+	// which are written to parent with write
+	log    *logger
+	buffer bytes.Buffer
+	write  func([]byte) error
+
+	// entries are replayed to parents of other types
+	entries     []func(Logger)
+	entriesLock sync.Mutex
+}
+
+// NewChannelLogger returns a logger for one of several tasks running in
+// parallel, e.g. blob downloads or compilations. Its entries are kept until
+// Close writes them to parent in one piece, so that they do not interleave
+// with entries of other tasks. Entries logged after Close are kept until it
+// is called again.
+func NewChannelLogger(parent Logger) Logger {
+	c := &channelLogger{parent: parent}
+
+	switch p := parent.(type) {
+	case *logger:
+		c.log = p.formattingInto(&c.buffer)
+		c.write = func(block []byte) error {
+			p.loggerMu.Lock()
+			defer p.loggerMu.Unlock()
+
+			_, err := p.logger.Writer().Write(block)
+			return err
+		}
+
+	case *asyncLogger:
+		c.log = p.log.formattingInto(&c.buffer)
+		c.write = func(block []byte) error {
+			_, err := p.writer.Write(block)
+			return err
+		}
+	}
+
+	return c
+}
+
+// formattingInto returns a logger with the same settings writing to w
+func (l *logger) formattingInto(w io.Writer) *logger {
+	l.loggerMu.Lock()
+	defer l.loggerMu.Unlock()
+
+	return &logger{
+		level:           l.level,
+		logger:          log.New(w, "", 0),
+		forcedDebug:     l.forcedDebug,
+		timestampFormat: l.timestampFormat,
+		tags:            l.tags,
+		format:          l.format,
+		color:           l.color,
+	}
+}
+
+func (c *channelLogger) entry(fn func(Logger)) {
+	if c.log != nil {
+		fn(c.log)
+		return
+	}
+
+	c.entriesLock.Lock()
+	c.entries = append(c.entries, fn)
+	c.entriesLock.Unlock()
+}
+
+func (c *channelLogger) Debug(tag, msg string, args ...interface{}) {
+	c.entry(func(l Logger) { l.Debug(tag, msg, args...) })
+}
+
+func (c *channelLogger) DebugWithDetails(tag, msg string, args ...interface{}) {
+	c.entry(func(l Logger) { l.DebugWithDetails(tag, msg, args...) })
+}
+
+func (c *channelLogger) Info(tag, msg string, args ...interface{}) {
+	c.entry(func(l Logger) { l.Info(tag, msg, args...) })
+}
+
+func (c *channelLogger) Warn(tag, msg string, args ...interface{}) {
+	c.entry(func(l Logger) { l.Warn(tag, msg, args...) })
+}
+
+func (c *channelLogger) Error(tag, msg string, args ...interface{}) {
+	c.entry(func(l Logger) { l.Error(tag, msg, args...) })
+}
+
+func (c *channelLogger) ErrorWithDetails(tag, msg string, args ...interface{}) {
+	c.entry(func(l Logger) { l.ErrorWithDetails(tag, msg, args...) })
+}
+
+// HandlePanic writes the entries of the channel before exiting
+func (c *channelLogger) HandlePanic(tag string) {
+	if e := recover(); e != nil {
+		c.ErrorWithDetails(tag, "Panic: %s", panicMessage(e), debug.Stack())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		c.Close(ctx)
+		cancel()
+
+		os.Exit(2)
+	}
+}
+
+func (c *channelLogger) RecoverAndLog(tag string) {
+	if e := recover(); e != nil {
+		c.ErrorWithDetails(tag, "Panic: %s", panicMessage(e), debug.Stack())
+	}
+}
+
+func (c *channelLogger) ToggleForcedDebug() {
+	if c.log != nil {
+		c.log.ToggleForcedDebug()
+	}
+}
+
+func (c *channelLogger) UseRFC3339Timestamps() {
+	if c.log != nil {
+		c.log.UseRFC3339Timestamps()
+	}
+}
+
+func (c *channelLogger) UseTags(tags []LogTag) {
+	if c.log != nil {
+		c.log.UseTags(tags)
+	}
+}
+
+// Flush and FlushTimeout do not write entries before the task is done
+func (c *channelLogger) Flush() error                       { return nil }
+func (c *channelLogger) FlushTimeout(_ time.Duration) error { return nil }
+
+func (c *channelLogger) Close(ctx context.Context) error {
+	if c.log == nil {
+		c.entriesLock.Lock()
+		entries := c.entries
+		c.entries = nil
+		c.entriesLock.Unlock()
+
+		return runUntilDone(ctx, func() error {
+			replayMu.Lock()
+			defer replayMu.Unlock()
+
+			for _, entry := range entries {
+				entry(c.parent)
+			}
+			return nil
+		})
+	}
+
+	c.log.loggerMu.Lock()
+	block := append([]byte(nil), c.buffer.Bytes()...)
+	c.buffer.Reset()
+	c.log.loggerMu.Unlock()
+
+	if len(block) == 0 {
+		return nil
+	}
+
+	return runUntilDone(ctx, func() error { return c.write(block) })
+}
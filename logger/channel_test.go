@@ -0,0 +1,119 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/cloudfoundry/bosh-utils/logger/loggerfakes"
+)
+
+var _ = Describe("NewChannelLogger", func() {
+	var (
+		out    *bytes.Buffer
+		parent Logger
+	)
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		parent = NewWriterLogger(LevelInfo, out)
+	})
+
+	It("keeps entries until the channel is closed", func() {
+		channel := NewChannelLogger(parent)
+
+		channel.Info("download", "fetching blob")
+		Expect(out.String()).To(BeEmpty())
+
+		Expect(channel.Close(context.Background())).To(Succeed())
+		Expect(out.String()).To(MatchRegexp(expectedLogFormat("download", "INFO - fetching blob")))
+	})
+
+	It("writes entries of each channel contiguously", func() {
+		first := NewChannelLogger(parent)
+		second := NewChannelLogger(parent)
+
+		var wg sync.WaitGroup
+		task := func(channel Logger, name string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				channel.Info(name, "line %d", i)
+			}
+		}
+
+		wg.Add(2)
+		go task(first, "first")
+		go task(second, "second")
+		wg.Wait()
+
+		parent.Info("parent", "between tasks")
+		Expect(second.Close(context.Background())).To(Succeed())
+		Expect(first.Close(context.Background())).To(Succeed())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(201))
+		Expect(lines[0]).To(ContainSubstring("[parent]"))
+		for i := 1; i <= 100; i++ {
+			Expect(lines[i]).To(HavePrefix("[second]"))
+			Expect(lines[100+i]).To(HavePrefix("[first]"))
+		}
+		Expect(lines[100]).To(HaveSuffix("line 99"))
+	})
+
+	It("applies the level and tags of the parent", func() {
+		parent.UseTags([]LogTag{{Name: "verbose", LogLevel: LevelDebug}})
+		channel := NewChannelLogger(parent)
+
+		channel.Debug("quiet", "hidden")
+		channel.Debug("verbose", "shown")
+		Expect(channel.Close(context.Background())).To(Succeed())
+
+		Expect(out.String()).ToNot(ContainSubstring("hidden"))
+		Expect(out.String()).To(ContainSubstring("DEBUG - shown"))
+	})
+
+	It("keeps entries logged after Close for the next Close", func() {
+		channel := NewChannelLogger(parent)
+
+		channel.Info("task", "first")
+		Expect(channel.Close(context.Background())).To(Succeed())
+		channel.Info("task", "second")
+		Expect(out.String()).ToNot(ContainSubstring("second"))
+
+		Expect(channel.Close(context.Background())).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("INFO - second"))
+	})
+
+	It("writes to async loggers in one piece", func() {
+		parent = NewAsyncWriterLogger(LevelDebug, out)
+		channel := NewChannelLogger(parent)
+
+		channel.Warn("task", "slow")
+		channel.Error("task", "failed")
+		Expect(channel.Close(context.Background())).To(Succeed())
+		Expect(parent.Flush()).To(Succeed())
+
+		Expect(out.String()).To(MatchRegexp(expectedLogFormat("task", "WARN - slow") + expectedLogFormat("task", "ERROR - failed")))
+	})
+
+	It("replays entries to other loggers", func() {
+		fakeLogger := &loggerfakes.FakeLogger{}
+		channel := NewChannelLogger(fakeLogger)
+
+		channel.Info("task", "msg %d", 1)
+		Expect(fakeLogger.InfoCallCount()).To(Equal(0))
+
+		Expect(channel.Close(context.Background())).To(Succeed())
+		Expect(fakeLogger.InfoCallCount()).To(Equal(1))
+
+		tag, msg, args := fakeLogger.InfoArgsForCall(0)
+		Expect(tag).To(Equal("task"))
+		Expect(msg).To(Equal("msg %d"))
+		Expect(args).To(Equal([]interface{}{1}))
+	})
+})
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format selects how log entries are rendered
+type Format int
+
+const (
+	// FormatLegacy is the dense "[tag] timestamp LEVEL - message" format
+	FormatLegacy Format = iota
+	// FormatPlain renders "15:04:05.000 LEVEL [tag] message" with continuation
+	// lines of multi-line messages indented
+	FormatPlain
+	// FormatHuman is FormatPlain with colorized levels
+	FormatHuman
+	// FormatDev is FormatHuman with the file:line of the caller
+	FormatDev
+)
+
+const (
+	shortTimeFormat = "15:04:05.000"
+	consoleIndent   = "    "
+	colorReset      = "\x1b[0m"
+	colorDim        = "\x1b[2m"
+)
+
+var levelColors = map[LogLevel]string{
+	LevelDebug: "\x1b[90m",
+	LevelInfo:  "\x1b[36m",
+	LevelWarn:  "\x1b[33m",
+	LevelError: "\x1b[31m",
+}
+
+// NewFormattedWriterLogger returns a logger rendering entries in format.
+// Colors are left out when the NO_COLOR environment variable is set.
+func NewFormattedWriterLogger(level LogLevel, writer io.Writer, format Format) Logger {
+	if format == FormatLegacy {
+		return NewWriterLogger(level, writer)
+	}
+
+	_, noColor := os.LookupEnv("NO_COLOR")
+
+	return &logger{
+		level:           level,
+		logger:          log.New(writer, "", 0),
+		timestampFormat: shortTimeFormat,
+		format:          format,
+		color:           format != FormatPlain && !noColor,
+	}
+}
+
+func (l *logger) printConsole(level LogLevel, tag, msg string) {
+	var b strings.Builder
+
+	l.loggerMu.Lock()
+	defer l.loggerMu.Unlock()
+
+	b.WriteString(l.paint(colorDim, time.Now().Format(l.timestampFormat)))
+	b.WriteString(" ")
+	b.WriteString(l.paint(levelColors[level], padLevel(level)))
+	b.WriteString(" [")
+	b.WriteString(tag)
+	b.WriteString("] ")
+
+	if l.format == FormatDev {
+		b.WriteString(l.paint(colorDim, callerLocation()))
+		b.WriteString(" ")
+	}
+
+	b.WriteString(strings.ReplaceAll(strings.TrimRight(msg, "\n"), "\n", "\n"+consoleIndent))
+
+	l.logger.SetPrefix("")
+	l.logger.Output(2, b.String())
+}
+
+func (l *logger) paint(color, s string) string {
+	if !l.color || color == "" {
+		return s
+	}
+
+	return color + s + colorReset
+}
+
+func padLevel(level LogLevel) string {
+	s := AsString(level)
+	return s + strings.Repeat(" ", len("ERROR")-len(s))
+}
+
+// callerLocation finds the first frame outside of this package,
+// which works regardless of how many wrapping loggers were involved
+func callerLocation() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	for {
+		frame, more := frames.Next()
+
+		if !isLoggerFrame(frame.Function) {
+			return filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		}
+
+		if !more {
+			return "???"
+		}
+	}
+}
+
+func isLoggerFrame(function string) bool {
+	const pkg = "github.com/cloudfoundry/bosh-utils/logger."
+
+	return strings.HasPrefix(function, pkg) || strings.HasPrefix(function, "runtime.")
+}
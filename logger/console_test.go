@@ -0,0 +1,106 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("NewFormattedWriterLogger", func() {
+	var buffer *bytes.Buffer
+
+	BeforeEach(func() {
+		buffer = bytes.NewBufferString("")
+		os.Unsetenv("NO_COLOR")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("NO_COLOR")
+	})
+
+	It("uses the legacy format with FormatLegacy", func() {
+		logger := NewFormattedWriterLogger(LevelDebug, buffer, FormatLegacy)
+		logger.Info("TAG", "some %s info", "awesome")
+
+		Expect(buffer.String()).To(MatchRegexp(expectedLogFormat("TAG", "INFO - some awesome info")))
+	})
+
+	Context("with FormatPlain", func() {
+		It("writes short timestamps, levels and tags", func() {
+			logger := NewFormattedWriterLogger(LevelDebug, buffer, FormatPlain)
+			logger.Debug("TAG", "some %s debug", "awesome")
+			logger.Error("TAG", "some error")
+
+			Expect(buffer.String()).To(MatchRegexp(
+				"^[0-9]{2}:[0-9]{2}:[0-9]{2}\\.[0-9]{3} DEBUG \\[TAG\\] some awesome debug\n" +
+					"[0-9]{2}:[0-9]{2}:[0-9]{2}\\.[0-9]{3} ERROR \\[TAG\\] some error\n$",
+			))
+		})
+
+		It("indents continuation lines of multi-line messages", func() {
+			logger := NewFormattedWriterLogger(LevelDebug, buffer, FormatPlain)
+			logger.ErrorWithDetails("TAG", "failed", "line one\nline two")
+
+			Expect(buffer.String()).To(HaveSuffix(
+				"ERROR [TAG] failed\n    ********************\n    line one\n    line two\n    ********************\n",
+			))
+		})
+
+		It("honors the log level and RFC3339 timestamps", func() {
+			logger := NewFormattedWriterLogger(LevelWarn, buffer, FormatPlain)
+			logger.UseRFC3339Timestamps()
+			logger.Info("TAG", "hidden")
+			logger.Warn("TAG", "shown")
+
+			Expect(buffer.String()).To(MatchRegexp("^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9:.]+Z WARN  \\[TAG\\] shown\n$"))
+		})
+	})
+
+	Context("with FormatHuman", func() {
+		It("colorizes levels", func() {
+			logger := NewFormattedWriterLogger(LevelDebug, buffer, FormatHuman)
+			logger.Warn("TAG", "careful")
+
+			Expect(buffer.String()).To(ContainSubstring("\x1b[33mWARN \x1b[0m [TAG] careful\n"))
+		})
+
+		It("leaves out colors when NO_COLOR is set", func() {
+			os.Setenv("NO_COLOR", "1")
+
+			logger := NewFormattedWriterLogger(LevelDebug, buffer, FormatHuman)
+			logger.Warn("TAG", "careful")
+
+			Expect(buffer.String()).ToNot(ContainSubstring("\x1b["))
+			Expect(buffer.String()).To(ContainSubstring(" WARN  [TAG] careful\n"))
+		})
+	})
+
+	Context("with FormatDev", func() {
+		BeforeEach(func() {
+			os.Setenv("NO_COLOR", "1")
+		})
+
+		It("includes the location of the caller", func() {
+			logger := NewFormattedWriterLogger(LevelDebug, buffer, FormatDev)
+			logger.Info("TAG", "here")
+			logger.DebugWithDetails("TAG", "details", "block")
+
+			Expect(buffer.String()).To(MatchRegexp("INFO  \\[TAG\\] console_test\\.go:[0-9]+ here\n"))
+			Expect(buffer.String()).To(MatchRegexp("DEBUG \\[TAG\\] console_test\\.go:[0-9]+ details\n"))
+		})
+
+		It("keeps the caller location through channel loggers", func() {
+			logger := NewFormattedWriterLogger(LevelDebug, buffer, FormatDev)
+			channel := NewChannelLogger(logger)
+			channel.Info("TAG", "buffered")
+			Expect(channel.Close(context.Background())).To(Succeed())
+
+			Expect(buffer.String()).To(MatchRegexp("INFO  \\[TAG\\] console_test\\.go:[0-9]+ buffered\n"))
+		})
+	})
+})
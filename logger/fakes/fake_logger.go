@@ -2,6 +2,7 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -9,6 +10,17 @@ import (
 )
 
 type FakeLogger struct {
+	CloseStub        func(context.Context) error
+	closeMutex       sync.RWMutex
+	closeArgsForCall []struct {
+		arg1 context.Context
+	}
+	closeReturns struct {
+		result1 error
+	}
+	closeReturnsOnCall map[int]struct {
+		result1 error
+	}
 	DebugStub        func(string, string, ...interface{})
 	debugMutex       sync.RWMutex
 	debugArgsForCall []struct {
@@ -63,6 +75,11 @@ type FakeLogger struct {
 	handlePanicArgsForCall []struct {
 		arg1 string
 	}
+	RecoverAndLogStub        func(string)
+	recoverAndLogMutex       sync.RWMutex
+	recoverAndLogArgsForCall []struct {
+		arg1 string
+	}
 	InfoStub        func(string, string, ...interface{})
 	infoMutex       sync.RWMutex
 	infoArgsForCall []struct {
@@ -94,6 +111,66 @@ type FakeLogger struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeLogger) Close(arg1 context.Context) error {
+	fake.closeMutex.Lock()
+	ret, specificReturn := fake.closeReturnsOnCall[len(fake.closeArgsForCall)]
+	fake.closeArgsForCall = append(fake.closeArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.recordInvocation("Close", []interface{}{arg1})
+	fake.closeMutex.Unlock()
+	if fake.CloseStub != nil {
+		return fake.CloseStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.closeReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeLogger) CloseCallCount() int {
+	fake.closeMutex.RLock()
+	defer fake.closeMutex.RUnlock()
+	return len(fake.closeArgsForCall)
+}
+
+func (fake *FakeLogger) CloseCalls(stub func(context.Context) error) {
+	fake.closeMutex.Lock()
+	defer fake.closeMutex.Unlock()
+	fake.CloseStub = stub
+}
+
+func (fake *FakeLogger) CloseArgsForCall(i int) context.Context {
+	fake.closeMutex.RLock()
+	defer fake.closeMutex.RUnlock()
+	argsForCall := fake.closeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeLogger) CloseReturns(result1 error) {
+	fake.closeMutex.Lock()
+	defer fake.closeMutex.Unlock()
+	fake.CloseStub = nil
+	fake.closeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeLogger) CloseReturnsOnCall(i int, result1 error) {
+	fake.closeMutex.Lock()
+	defer fake.closeMutex.Unlock()
+	fake.CloseStub = nil
+	if fake.closeReturnsOnCall == nil {
+		fake.closeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.closeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeLogger) Debug(arg1 string, arg2 string, arg3 ...interface{}) {
 	fake.debugMutex.Lock()
 	fake.debugArgsForCall = append(fake.debugArgsForCall, struct {
@@ -109,6 +186,8 @@ func (fake *FakeLogger) Debug(arg1 string, arg2 string, arg3 ...interface{}) {
 }
 
 func (fake *FakeLogger) DebugCallCount() int {
+	fake.closeMutex.RLock()
+	defer fake.closeMutex.RUnlock()
 	fake.debugMutex.RLock()
 	defer fake.debugMutex.RUnlock()
 	return len(fake.debugArgsForCall)
@@ -369,6 +448,37 @@ func (fake *FakeLogger) HandlePanicArgsForCall(i int) string {
 	return argsForCall.arg1
 }
 
+func (fake *FakeLogger) RecoverAndLog(arg1 string) {
+	fake.recoverAndLogMutex.Lock()
+	fake.recoverAndLogArgsForCall = append(fake.recoverAndLogArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("RecoverAndLog", []interface{}{arg1})
+	fake.recoverAndLogMutex.Unlock()
+	if fake.RecoverAndLogStub != nil {
+		fake.RecoverAndLogStub(arg1)
+	}
+}
+
+func (fake *FakeLogger) RecoverAndLogCallCount() int {
+	fake.recoverAndLogMutex.RLock()
+	defer fake.recoverAndLogMutex.RUnlock()
+	return len(fake.recoverAndLogArgsForCall)
+}
+
+func (fake *FakeLogger) RecoverAndLogCalls(stub func(string)) {
+	fake.recoverAndLogMutex.Lock()
+	defer fake.recoverAndLogMutex.Unlock()
+	fake.RecoverAndLogStub = stub
+}
+
+func (fake *FakeLogger) RecoverAndLogArgsForCall(i int) string {
+	fake.recoverAndLogMutex.RLock()
+	defer fake.recoverAndLogMutex.RUnlock()
+	argsForCall := fake.recoverAndLogArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeLogger) Info(arg1 string, arg2 string, arg3 ...interface{}) {
 	fake.infoMutex.Lock()
 	fake.infoArgsForCall = append(fake.infoArgsForCall, struct {
@@ -522,6 +632,8 @@ func (fake *FakeLogger) Invocations() map[string][][]interface{} {
 	defer fake.flushTimeoutMutex.RUnlock()
 	fake.handlePanicMutex.RLock()
 	defer fake.handlePanicMutex.RUnlock()
+	fake.recoverAndLogMutex.RLock()
+	defer fake.recoverAndLogMutex.RUnlock()
 	fake.infoMutex.RLock()
 	defer fake.infoMutex.RUnlock()
 	fake.toggleForcedDebugMutex.RLock()
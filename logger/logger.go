@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -62,11 +63,17 @@ type Logger interface {
 	Error(tag, msg string, args ...interface{})
 	ErrorWithDetails(tag, msg string, args ...interface{})
 	HandlePanic(tag string)
+	RecoverAndLog(tag string)
 	ToggleForcedDebug()
 	UseRFC3339Timestamps()
 	UseTags(tags []LogTag)
 	Flush() error
 	FlushTimeout(time.Duration) error
+
+	// Close flushes buffered entries and writers that buffer output
+	// (e.g. network.Sink) until ctx is done. Writers are not closed
+	// and entries logged afterwards are still written.
+	Close(ctx context.Context) error
 }
 
 type logger struct {
@@ -76,6 +83,8 @@ type logger struct {
 	loggerMu        sync.Mutex
 	timestampFormat string
 	tags            []LogTag
+	format          Format
+	color           bool
 }
 
 type LogTag struct {
@@ -114,13 +123,16 @@ func (l *logger) UseTags(tags []LogTag) {
 func (l *logger) Flush() error                       { return nil }
 func (l *logger) FlushTimeout(_ time.Duration) error { return nil }
 
+func (l *logger) Close(ctx context.Context) error {
+	return flushWriter(ctx, l.logger.Writer())
+}
+
 func (l *logger) Debug(tag, msg string, args ...interface{}) {
 	if l.getLogLevel(tag) > LevelDebug && !l.forcedDebug {
 		return
 	}
 
-	msg = "DEBUG - " + msg
-	l.printf(tag, msg, args...)
+	l.printf(LevelDebug, tag, msg, args...)
 }
 
 // DebugWithDetails will automatically change the format of the message
@@ -135,8 +147,7 @@ func (l *logger) Info(tag, msg string, args ...interface{}) {
 		return
 	}
 
-	msg = "INFO - " + msg
-	l.printf(tag, msg, args...)
+	l.printf(LevelInfo, tag, msg, args...)
 }
 
 func (l *logger) Warn(tag, msg string, args ...interface{}) {
@@ -144,8 +155,7 @@ func (l *logger) Warn(tag, msg string, args ...interface{}) {
 		return
 	}
 
-	msg = "WARN - " + msg
-	l.printf(tag, msg, args...)
+	l.printf(LevelWarn, tag, msg, args...)
 }
 
 func (l *logger) Error(tag, msg string, args ...interface{}) {
@@ -153,8 +163,7 @@ func (l *logger) Error(tag, msg string, args ...interface{}) {
 		return
 	}
 
-	msg = "ERROR - " + msg
-	l.printf(tag, msg, args...)
+	l.printf(LevelError, tag, msg, args...)
 }
 
 // ErrorWithDetails will automatically change the format of the message
@@ -164,41 +173,43 @@ func (l *logger) ErrorWithDetails(tag, msg string, args ...interface{}) {
 	l.Error(tag, msg, args...)
 }
 
-func (l *logger) recoverPanic(tag string) (didPanic bool) {
-	if e := recover(); e != nil {
-		var msg string
-		switch obj := e.(type) {
-		case string:
-			msg = obj
-		case fmt.Stringer:
-			msg = obj.String()
-		case error:
-			msg = obj.Error()
-		default:
-			msg = fmt.Sprintf("%#v", obj)
-		}
-		l.ErrorWithDetails(tag, "Panic: %s", msg, debug.Stack())
-		return true
-	}
-	return false
+func (l *logger) logPanic(tag string, e interface{}) {
+	l.ErrorWithDetails(tag, "Panic: %s", panicMessage(e), debug.Stack())
 }
 
+// HandlePanic logs a panic with its stack trace and exits.
+// It has to be deferred directly for recover to take effect.
 func (l *logger) HandlePanic(tag string) {
-	if l.recoverPanic(tag) {
+	if e := recover(); e != nil {
+		l.logPanic(tag, e)
 		os.Exit(2)
 	}
 }
 
+// RecoverAndLog logs a panic with its stack trace and lets the
+// program continue. Like HandlePanic it has to be deferred directly.
+func (l *logger) RecoverAndLog(tag string) {
+	if e := recover(); e != nil {
+		l.logPanic(tag, e)
+	}
+}
+
 func (l *logger) ToggleForcedDebug() {
 	l.forcedDebug = !l.forcedDebug
 }
 
-func (l *logger) printf(tag, msg string, args ...interface{}) {
+func (l *logger) printf(level LogLevel, tag, msg string, args ...interface{}) {
 	s := fmt.Sprintf(msg, args...)
+
+	if l.format != FormatLegacy {
+		l.printConsole(level, tag, s)
+		return
+	}
+
 	l.loggerMu.Lock()
 	timestamp := time.Now().Format(l.timestampFormat)
 	l.logger.SetPrefix("[" + tag + "] " + timestamp + " ")
-	l.logger.Output(2, s)
+	l.logger.Output(2, AsString(level)+" - "+s)
 	l.loggerMu.Unlock()
 }
 
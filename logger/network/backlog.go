@@ -0,0 +1,274 @@
+package network
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// backlog holds framed entries that were not delivered yet.
+// Append drops the oldest entries to stay within its size limit and
+// returns the number of bytes that were dropped. Oldest returns the
+// oldest entries, or nil when there are none, and an ID to Remove them
+// once they were delivered. Remove does nothing when they were dropped
+// in the meantime.
+type backlog interface {
+	Append(frame []byte) (int64, error)
+	Oldest() (uint64, []byte, error)
+	Remove(id uint64) error
+	Size() int64
+	Close() error
+}
+
+type memoryBacklog struct {
+	maxBytes int64
+	size     int64
+	frames   [][]byte
+
+	// firstID is the ID of frames[0]
+	firstID uint64
+}
+
+func newMemoryBacklog(maxBytes int64) *memoryBacklog {
+	return &memoryBacklog{maxBytes: maxBytes}
+}
+
+func (b *memoryBacklog) Append(frame []byte) (int64, error) {
+	frameSize := int64(len(frame))
+	if frameSize > b.maxBytes {
+		return frameSize, nil
+	}
+
+	var dropped int64
+	for b.size+frameSize > b.maxBytes {
+		dropped += int64(len(b.frames[0]))
+		b.size -= int64(len(b.frames[0]))
+		b.frames = b.frames[1:]
+		b.firstID++
+	}
+
+	b.frames = append(b.frames, frame)
+	b.size += frameSize
+
+	return dropped, nil
+}
+
+func (b *memoryBacklog) Oldest() (uint64, []byte, error) {
+	if len(b.frames) == 0 {
+		return 0, nil, nil
+	}
+	return b.firstID, b.frames[0], nil
+}
+
+func (b *memoryBacklog) Remove(id uint64) error {
+	if len(b.frames) > 0 && id == b.firstID {
+		b.size -= int64(len(b.frames[0]))
+		b.frames = b.frames[1:]
+		b.firstID++
+	}
+	return nil
+}
+
+func (b *memoryBacklog) Size() int64  { return b.size }
+func (b *memoryBacklog) Close() error { return nil }
+
+type segment struct {
+	seq  uint64
+	path string
+	size int64
+}
+
+// diskBacklog appends frames to numbered segment files so that the oldest
+// entries can be dropped by deleting whole segments. Segments left behind
+// by a previous process are picked up and delivered first.
+type diskBacklog struct {
+	fs         boshsys.FileSystem
+	dir        string
+	maxBytes   int64
+	segmentMax int64
+
+	size     int64
+	segments []segment
+	current  boshsys.File
+	nextSeq  uint64
+}
+
+const (
+	segmentExt        = ".buf"
+	segmentsPerBuffer = 8
+)
+
+func newDiskBacklog(fs boshsys.FileSystem, dir string, maxBytes int64) (*diskBacklog, error) {
+	err := fs.MkdirAll(dir, os.FileMode(0700))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Creating log buffer dir '%s'", dir)
+	}
+
+	b := &diskBacklog{
+		fs:         fs,
+		dir:        dir,
+		maxBytes:   maxBytes,
+		segmentMax: maxBytes / segmentsPerBuffer,
+	}
+
+	paths, err := fs.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing log buffer dir '%s'", dir)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		var seq uint64
+		_, err := fmt.Sscanf(filepath.Base(path), "%020d"+segmentExt, &seq)
+		if err != nil {
+			continue
+		}
+
+		info, err := fs.Stat(path)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading log buffer segment '%s'", path)
+		}
+
+		b.segments = append(b.segments, segment{seq: seq, path: path, size: info.Size()})
+		b.size += info.Size()
+		b.nextSeq = seq + 1
+	}
+
+	return b, nil
+}
+
+func (b *diskBacklog) Append(frame []byte) (int64, error) {
+	frameSize := int64(len(frame))
+	if frameSize > b.maxBytes {
+		return frameSize, nil
+	}
+
+	var dropped int64
+	for b.size+frameSize > b.maxBytes && len(b.segments) > 0 {
+		droppedSize, err := b.removeOldest()
+		if err != nil {
+			return dropped + frameSize, err
+		}
+		dropped += droppedSize
+	}
+
+	if b.current == nil || b.segments[len(b.segments)-1].size+frameSize > b.segmentMax {
+		err := b.openSegment()
+		if err != nil {
+			return dropped + frameSize, err
+		}
+	}
+
+	_, err := b.current.Write(frame)
+	if err != nil {
+		return dropped + frameSize, bosherr.WrapErrorf(err, "Writing log buffer segment '%s'", b.current.Name())
+	}
+
+	b.segments[len(b.segments)-1].size += frameSize
+	b.size += frameSize
+
+	return dropped, nil
+}
+
+// Oldest returns whole segments; a segment that fails part way through
+// is sent again, so delivery is at-least-once. The segment still being
+// appended to is closed first so that later entries go to a new one.
+func (b *diskBacklog) Oldest() (uint64, []byte, error) {
+	if len(b.segments) == 0 {
+		return 0, nil, nil
+	}
+
+	if len(b.segments) == 1 {
+		err := b.closeCurrent()
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	oldest := b.segments[0]
+
+	content, err := b.fs.ReadFile(oldest.path)
+	if err != nil {
+		return 0, nil, bosherr.WrapErrorf(err, "Reading log buffer segment '%s'", oldest.path)
+	}
+
+	if content == nil {
+		content = []byte{}
+	}
+
+	return oldest.seq, content, nil
+}
+
+func (b *diskBacklog) Remove(id uint64) error {
+	if len(b.segments) == 0 || b.segments[0].seq != id {
+		return nil
+	}
+
+	_, err := b.removeOldest()
+	return err
+}
+
+func (b *diskBacklog) Size() int64 { return b.size }
+
+func (b *diskBacklog) Close() error {
+	return b.closeCurrent()
+}
+
+func (b *diskBacklog) openSegment() error {
+	err := b.closeCurrent()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(b.dir, fmt.Sprintf("%020d"+segmentExt, b.nextSeq))
+
+	file, err := b.fs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0600))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening log buffer segment '%s'", path)
+	}
+
+	b.current = file
+	b.segments = append(b.segments, segment{seq: b.nextSeq, path: path})
+	b.nextSeq++
+
+	return nil
+}
+
+func (b *diskBacklog) removeOldest() (int64, error) {
+	oldest := b.segments[0]
+
+	if len(b.segments) == 1 {
+		err := b.closeCurrent()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err := b.fs.RemoveAll(oldest.path)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Removing log buffer segment '%s'", oldest.path)
+	}
+
+	b.segments = b.segments[1:]
+	b.size -= oldest.size
+
+	return oldest.size, nil
+}
+
+func (b *diskBacklog) closeCurrent() error {
+	if b.current == nil {
+		return nil
+	}
+
+	err := b.current.Close()
+	b.current = nil
+	if err != nil {
+		return bosherr.WrapError(err, "Closing log buffer segment")
+	}
+
+	return nil
+}
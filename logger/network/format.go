@@ -0,0 +1,121 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	// FormatSyslog frames RFC5424 messages with octet counting (RFC6587)
+	FormatSyslog Format = iota
+	// FormatJSON writes one JSON object per line
+	FormatJSON
+)
+
+const (
+	syslogFacilityUser = 1
+	syslogTimeFormat   = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+type entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Hostname  string    `json:"hostname"`
+	AppName   string    `json:"app_name"`
+	ProcID    string    `json:"proc_id"`
+	Level     string    `json:"level"`
+	Tag       string    `json:"tag"`
+	Message   string    `json:"message"`
+
+	severity int
+}
+
+var levelMarkers = []struct {
+	marker   string
+	level    string
+	severity int
+}{
+	{" DEBUG - ", "DEBUG", 7},
+	{" INFO - ", "INFO", 6},
+	{" WARN - ", "WARN", 4},
+	{" ERROR - ", "ERROR", 3},
+}
+
+// parseLine splits a line produced by boshlog's writer logger
+// ("[tag] timestamp LEVEL - message") into its parts. Lines that do not
+// follow that layout are forwarded as INFO messages.
+func parseLine(line string) entry {
+	line = strings.TrimSuffix(line, "\n")
+	e := entry{Level: "INFO", severity: 6, Message: line}
+
+	rest := line
+	if strings.HasPrefix(rest, "[") {
+		if i := strings.Index(rest, "] "); i != -1 {
+			e.Tag = rest[1:i]
+			rest = rest[i+1:]
+		}
+	}
+
+	markerIdx := -1
+	for _, m := range levelMarkers {
+		i := strings.Index(rest, m.marker)
+		if i != -1 && (markerIdx == -1 || i < markerIdx) {
+			markerIdx = i
+			e.Level = m.level
+			e.severity = m.severity
+			e.Message = rest[i+len(m.marker):]
+		}
+	}
+
+	return e
+}
+
+func (f Format) frame(e entry) ([]byte, error) {
+	switch f {
+	case FormatSyslog:
+		msg := fmt.Sprintf("<%d>1 %s %s %s %s %s - %s",
+			syslogFacilityUser*8+e.severity,
+			e.Timestamp.Format(syslogTimeFormat),
+			syslogHeaderField(e.Hostname, 255),
+			syslogHeaderField(e.AppName, 48),
+			syslogHeaderField(e.ProcID, 128),
+			syslogHeaderField(e.Tag, 32),
+			e.Message,
+		)
+		return []byte(fmt.Sprintf("%d %s", len(msg), msg)), nil
+
+	case FormatJSON:
+		bytes, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		return append(bytes, '\n'), nil
+
+	default:
+		return nil, fmt.Errorf("Unknown log forwarding format '%d'", f)
+	}
+}
+
+// syslogHeaderField returns value restricted to printable ASCII without
+// spaces and truncated to max, or the NILVALUE "-" when empty.
+func syslogHeaderField(value string, max int) string {
+	if value == "" {
+		return "-"
+	}
+
+	field := []byte(value)
+	for i, c := range field {
+		if c < 33 || c > 126 {
+			field[i] = '_'
+		}
+	}
+
+	if len(field) > max {
+		field = field[:max]
+	}
+
+	return string(field)
+}
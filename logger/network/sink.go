@@ -0,0 +1,381 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	DefaultMaxBacklogBytes   = 64 * 1024 * 1024
+	DefaultDialTimeout       = 10 * time.Second
+	DefaultWriteTimeout      = 10 * time.Second
+	DefaultMinReconnectDelay = 500 * time.Millisecond
+	DefaultMaxReconnectDelay = 30 * time.Second
+)
+
+type Config struct {
+	// Address of the collector (host:port)
+	Address string
+
+	// TLSConfig enables TLS when set, plain TCP is used otherwise
+	TLSConfig *tls.Config
+
+	Format Format
+
+	// AppName defaults to the executable name, Hostname to os.Hostname()
+	AppName  string
+	Hostname string
+
+	// BufferDir keeps entries on disk until they are delivered so they
+	// survive restarts.
+	// Entries are buffered in memory when it is empty.
+	BufferDir string
+
+	// MaxBacklogBytes bounds undelivered entries; the oldest are dropped first
+	MaxBacklogBytes int64
+
+	DialTimeout       time.Duration
+	WriteTimeout      time.Duration
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
+}
+
+// Sink is an io.Writer that forwards each written log line to a remote
+// collector. Writes never block on the network: entries are appended to
+// the backlog and a background goroutine connects and delivers them.
+type Sink struct {
+	config Config
+	procID string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// backlogLock guards backlog and closed; it is never held while
+	// writing to the collector
+	backlogLock sync.Mutex
+	backlog     backlog
+	closed      bool
+
+	wakeCh  chan struct{}
+	flushCh chan chan error
+	closeCh chan chan error
+	doneCh  chan struct{}
+
+	conn           net.Conn
+	reconnectDelay time.Duration
+
+	droppedBytes int64
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// New returns a logger that forwards to the configured collector and the
+// sink backing it. User is responsible for closing the returned sink.
+func New(level boshlog.LogLevel, config Config, fs boshsys.FileSystem) (boshlog.Logger, *Sink, error) {
+	sink, err := NewSink(config, fs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return boshlog.NewWriterLogger(level, sink), sink, nil
+}
+
+func NewSink(config Config, fs boshsys.FileSystem) (*Sink, error) {
+	if config.Address == "" {
+		return nil, bosherr.Error("Log forwarding address must be specified")
+	}
+
+	config = withDefaults(config)
+
+	_, err := config.Format.frame(entry{})
+	if err != nil {
+		return nil, err
+	}
+
+	var bl backlog = newMemoryBacklog(config.MaxBacklogBytes)
+	if config.BufferDir != "" {
+		bl, err = newDiskBacklog(fs, config.BufferDir, config.MaxBacklogBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &Sink{
+		config:         config,
+		procID:         strconv.Itoa(os.Getpid()),
+		ctx:            ctx,
+		cancel:         cancel,
+		backlog:        bl,
+		wakeCh:         make(chan struct{}, 1),
+		flushCh:        make(chan chan error),
+		closeCh:        make(chan chan error),
+		doneCh:         make(chan struct{}),
+		reconnectDelay: config.MinReconnectDelay,
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+func withDefaults(config Config) Config {
+	if config.AppName == "" {
+		config.AppName = filepath.Base(os.Args[0])
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.MaxBacklogBytes <= 0 {
+		config.MaxBacklogBytes = DefaultMaxBacklogBytes
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = DefaultDialTimeout
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = DefaultWriteTimeout
+	}
+	if config.MinReconnectDelay <= 0 {
+		config.MinReconnectDelay = DefaultMinReconnectDelay
+	}
+	if config.MaxReconnectDelay < config.MinReconnectDelay {
+		config.MaxReconnectDelay = DefaultMaxReconnectDelay
+		if config.MaxReconnectDelay < config.MinReconnectDelay {
+			config.MaxReconnectDelay = config.MinReconnectDelay
+		}
+	}
+	return config
+}
+
+func (s *Sink) Write(p []byte) (int, error) {
+	e := parseLine(string(p))
+	e.Timestamp = time.Now().UTC()
+	e.Hostname = s.config.Hostname
+	e.AppName = s.config.AppName
+	e.ProcID = s.procID
+
+	frame, err := s.config.Format.frame(e)
+	if err != nil {
+		return 0, err
+	}
+
+	s.backlogLock.Lock()
+	if s.closed {
+		s.backlogLock.Unlock()
+		return 0, errors.New("logger: network sink is closed")
+	}
+	dropped, _ := s.backlog.Append(frame)
+	s.backlogLock.Unlock()
+
+	atomic.AddInt64(&s.droppedBytes, dropped)
+
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+
+	return len(p), nil
+}
+
+// Flush waits until all written entries were sent while the collector is
+// reachable, which includes waiting for a connection attempt in progress.
+// It returns an error when entries are still waiting for the collector.
+func (s *Sink) Flush() error {
+	ch := make(chan error, 1)
+
+	select {
+	case s.flushCh <- ch:
+		return <-ch
+	case <-s.doneCh:
+		return nil
+	}
+}
+
+// Close stops forwarding. Entries buffered in memory are lost; entries
+// buffered on disk are sent by the next sink using the same BufferDir.
+func (s *Sink) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+
+		ch := make(chan error, 1)
+		s.closeCh <- ch
+		s.closeErr = <-ch
+	})
+	return s.closeErr
+}
+
+// DroppedBytes returns how much framed log data was discarded to keep
+// the backlog within MaxBacklogBytes.
+func (s *Sink) DroppedBytes() int64 {
+	return atomic.LoadInt64(&s.droppedBytes)
+}
+
+// run owns the connection. It delivers the backlog oldest first while
+// connected and answers flushes once the backlog is empty or the
+// collector is unreachable.
+func (s *Sink) run() {
+	defer close(s.doneCh)
+
+	var (
+		reconnectCh <-chan time.Time
+		flushes     []chan error
+	)
+
+	for {
+		if s.conn == nil && reconnectCh == nil {
+			err := s.connect()
+			if err != nil {
+				reconnectCh = s.reconnectAfter()
+			}
+		}
+
+		if s.conn != nil {
+			delivered, err := s.deliverOldest()
+			if err != nil {
+				s.disconnect()
+				reconnectCh = s.reconnectAfter()
+			}
+
+			if delivered {
+				select {
+				case ch := <-s.closeCh:
+					ch <- s.shutdown()
+					return
+				default:
+					continue
+				}
+			}
+		}
+
+		s.answer(flushes)
+		flushes = nil
+
+		select {
+		case <-s.wakeCh:
+
+		case <-reconnectCh:
+			reconnectCh = nil
+
+		case ch := <-s.flushCh:
+			flushes = append(flushes, ch)
+
+		case ch := <-s.closeCh:
+			ch <- s.shutdown()
+			return
+		}
+	}
+}
+
+func (s *Sink) answer(flushes []chan error) {
+	for _, ch := range flushes {
+		ch <- s.pendingErr()
+	}
+}
+
+func (s *Sink) connect() error {
+	dialer := &net.Dialer{Timeout: s.config.DialTimeout}
+
+	var conn net.Conn
+	var err error
+
+	if s.config.TLSConfig != nil {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.config.TLSConfig}
+		conn, err = tlsDialer.DialContext(s.ctx, "tcp", s.config.Address)
+	} else {
+		conn, err = dialer.DialContext(s.ctx, "tcp", s.config.Address)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.conn = conn
+	s.reconnectDelay = s.config.MinReconnectDelay
+
+	return nil
+}
+
+// deliverOldest sends the oldest entries of the backlog and returns
+// whether there were any
+func (s *Sink) deliverOldest() (bool, error) {
+	s.backlogLock.Lock()
+	id, frames, err := s.backlog.Oldest()
+	s.backlogLock.Unlock()
+
+	if err != nil || frames == nil {
+		return false, err
+	}
+
+	err = s.write(frames)
+	if err != nil {
+		return true, err
+	}
+
+	s.backlogLock.Lock()
+	defer s.backlogLock.Unlock()
+
+	return true, s.backlog.Remove(id)
+}
+
+func (s *Sink) write(frame []byte) error {
+	err := s.conn.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Write(frame)
+	return err
+}
+
+func (s *Sink) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *Sink) reconnectAfter() <-chan time.Time {
+	reconnectCh := time.After(s.reconnectDelay)
+
+	s.reconnectDelay *= 2
+	if s.reconnectDelay > s.config.MaxReconnectDelay {
+		s.reconnectDelay = s.config.MaxReconnectDelay
+	}
+
+	return reconnectCh
+}
+
+func (s *Sink) pendingErr() error {
+	s.backlogLock.Lock()
+	size := s.backlog.Size()
+	s.backlogLock.Unlock()
+
+	if size > 0 {
+		return bosherr.Errorf("%d bytes of log entries are waiting for '%s'", size, s.config.Address)
+	}
+	return nil
+}
+
+func (s *Sink) shutdown() error {
+	s.disconnect()
+
+	s.backlogLock.Lock()
+	defer s.backlogLock.Unlock()
+
+	s.closed = true
+	return s.backlog.Close()
+}
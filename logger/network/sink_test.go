@@ -0,0 +1,349 @@
+package network_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/logger/network"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type collector struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	received []byte
+}
+
+func startCollector(address string, tlsConfig *tls.Config) *collector {
+	listener, err := net.Listen("tcp", address)
+	Expect(err).ToNot(HaveOccurred())
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	c := &collector{listener: listener}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				buf := make([]byte, 4096)
+				for {
+					n, err := conn.Read(buf)
+					c.mu.Lock()
+					c.received = append(c.received, buf[:n]...)
+					c.mu.Unlock()
+					if err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return c
+}
+
+func (c *collector) Address() string { return c.listener.Addr().String() }
+
+func (c *collector) Received() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(c.received)
+}
+
+func (c *collector) JSONEntries() []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(c.Received()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (c *collector) Close() { c.listener.Close() }
+
+func unusedAddress() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func messages(entries []map[string]interface{}) []string {
+	var msgs []string
+	for _, entry := range entries {
+		msgs = append(msgs, entry["message"].(string))
+	}
+	return msgs
+}
+
+var _ = Describe("Sink", func() {
+	var (
+		fs     boshsys.FileSystem
+		config Config
+	)
+
+	BeforeEach(func() {
+		fs = boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		config = Config{
+			Format:            FormatJSON,
+			AppName:           "fake-app",
+			Hostname:          "fake-host",
+			MinReconnectDelay: 10 * time.Millisecond,
+			MaxReconnectDelay: 20 * time.Millisecond,
+		}
+	})
+
+	It("requires an address", func() {
+		_, _, err := New(boshlog.LevelDebug, config, fs)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("forwards JSON lines", func() {
+		collector := startCollector("127.0.0.1:0", nil)
+		defer collector.Close()
+
+		config.Address = collector.Address()
+		logger, sink, err := New(boshlog.LevelDebug, config, fs)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		logger.Warn("fake-tag", "some %s message", "awesome")
+		logger.DebugWithDetails("fake-tag", "details", "line1\nline2")
+		Expect(sink.Flush()).To(Succeed())
+
+		Eventually(collector.JSONEntries).Should(HaveLen(2))
+
+		entries := collector.JSONEntries()
+		Expect(entries[0]).To(HaveKeyWithValue("level", "WARN"))
+		Expect(entries[0]).To(HaveKeyWithValue("tag", "fake-tag"))
+		Expect(entries[0]).To(HaveKeyWithValue("message", "some awesome message"))
+		Expect(entries[0]).To(HaveKeyWithValue("hostname", "fake-host"))
+		Expect(entries[0]).To(HaveKeyWithValue("app_name", "fake-app"))
+		Expect(entries[1]).To(HaveKeyWithValue("level", "DEBUG"))
+		Expect(entries[1]["message"]).To(ContainSubstring("line1\nline2"))
+	})
+
+	It("forwards octet-counted RFC5424 syslog messages", func() {
+		collector := startCollector("127.0.0.1:0", nil)
+		defer collector.Close()
+
+		config.Address = collector.Address()
+		config.Format = FormatSyslog
+		logger, sink, err := New(boshlog.LevelDebug, config, fs)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		logger.Error("fake tag", "something failed")
+
+		Eventually(collector.Received).Should(MatchRegexp(
+			`^\d+ <11>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}Z fake-host fake-app \d+ fake_tag - something failed$`,
+		))
+
+		received := collector.Received()
+		length := received[:strings.Index(received, " ")]
+		Expect(length).To(Equal(strconv.Itoa(len(received) - len(length) - 1)))
+	})
+
+	It("forwards over TLS", func() {
+		serverConfig, clientConfig := tlsConfigs()
+
+		collector := startCollector("127.0.0.1:0", serverConfig)
+		defer collector.Close()
+
+		config.Address = collector.Address()
+		config.TLSConfig = clientConfig
+		logger, sink, err := New(boshlog.LevelDebug, config, fs)
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		logger.Info("fake-tag", "over tls")
+
+		Eventually(func() []string { return messages(collector.JSONEntries()) }).Should(Equal([]string{"over tls"}))
+	})
+
+	It("does not block writes while connecting", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+
+		// accepts connections but never answers the TLS handshake
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+
+		_, clientConfig := tlsConfigs()
+
+		config.Address = listener.Addr().String()
+		config.TLSConfig = clientConfig
+		config.DialTimeout = time.Minute
+		logger, sink, err := New(boshlog.LevelDebug, config, fs)
+		Expect(err).ToNot(HaveOccurred())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 2000; i++ {
+				logger.Info("fake-tag", "message-%d", i)
+			}
+		}()
+		Eventually(done, 5*time.Second).Should(BeClosed())
+
+		closed := make(chan error, 1)
+		go func() { closed <- sink.Close() }()
+		Eventually(closed, 5*time.Second).Should(Receive(BeNil()))
+	})
+
+	Context("when the collector is unreachable", func() {
+		var bufferDir string
+
+		BeforeEach(func() {
+			var err error
+			bufferDir, err = os.MkdirTemp("", "network-logger-test")
+			Expect(err).ToNot(HaveOccurred())
+
+			config.Address = unusedAddress()
+			config.BufferDir = bufferDir
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(bufferDir)
+		})
+
+		It("buffers entries on disk and delivers them in order once connected", func() {
+			logger, sink, err := New(boshlog.LevelDebug, config, fs)
+			Expect(err).ToNot(HaveOccurred())
+			defer sink.Close()
+
+			logger.Info("fake-tag", "first")
+			logger.Info("fake-tag", "second")
+
+			err = sink.Flush()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("waiting for '" + config.Address + "'"))
+
+			segments, err := filepath.Glob(filepath.Join(bufferDir, "*.buf"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segments).ToNot(BeEmpty())
+
+			collector := startCollector(config.Address, nil)
+			defer collector.Close()
+
+			Eventually(func() []string { return messages(collector.JSONEntries()) }).Should(Equal([]string{"first", "second"}))
+
+			logger.Info("fake-tag", "third")
+			Eventually(func() []string { return messages(collector.JSONEntries()) }).Should(Equal([]string{"first", "second", "third"}))
+
+			Expect(sink.Flush()).To(Succeed())
+			Expect(filepath.Glob(filepath.Join(bufferDir, "*.buf"))).To(BeEmpty())
+		})
+
+		It("delivers entries buffered by a previous sink", func() {
+			logger, sink, err := New(boshlog.LevelDebug, config, fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			logger.Info("fake-tag", "from previous run")
+			Expect(sink.Flush()).ToNot(Succeed())
+			Expect(sink.Close()).To(Succeed())
+
+			collector := startCollector(config.Address, nil)
+			defer collector.Close()
+
+			_, sink, err = New(boshlog.LevelDebug, config, fs)
+			Expect(err).ToNot(HaveOccurred())
+			defer sink.Close()
+
+			Eventually(func() []string { return messages(collector.JSONEntries()) }).Should(Equal([]string{"from previous run"}))
+		})
+
+		It("drops the oldest entries to stay within MaxBacklogBytes", func() {
+			config.BufferDir = ""
+			config.MaxBacklogBytes = 1024
+
+			logger, sink, err := New(boshlog.LevelDebug, config, fs)
+			Expect(err).ToNot(HaveOccurred())
+			defer sink.Close()
+
+			for i := 0; i < 50; i++ {
+				logger.Info("fake-tag", "message-%d", i)
+			}
+			Expect(sink.Flush()).ToNot(Succeed())
+			Expect(sink.DroppedBytes()).To(BeNumerically(">", 0))
+
+			collector := startCollector(config.Address, nil)
+			defer collector.Close()
+
+			Eventually(func() []string { return messages(collector.JSONEntries()) }).Should(ContainElement("message-49"))
+
+			received := messages(collector.JSONEntries())
+			Expect(received).ToNot(ContainElement("message-0"))
+			Expect(len(received)).To(BeNumerically("<", 50))
+		})
+	})
+})
+
+func tlsConfigs() (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+
+	return serverConfig, &tls.Config{RootCAs: pool}
+}
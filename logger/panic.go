@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// WrapGoroutine returns fn guarded so that a panic is logged with its
+// stack trace under tag before the goroutine dies. When rePanic is true
+// the panic is raised again after logging and the logger was flushed.
+//
+//	go boshlog.WrapGoroutine(logger, logTag, false, func() { ... })()
+func WrapGoroutine(logger Logger, tag string, rePanic bool, fn func()) func() {
+	return func() {
+		defer func() {
+			if e := recover(); e != nil {
+				logger.ErrorWithDetails(tag, "Panic: %s", panicMessage(e), debug.Stack())
+				_ = logger.Flush()
+
+				if rePanic {
+					panic(e)
+				}
+			}
+		}()
+
+		fn()
+	}
+}
+
+func panicMessage(e interface{}) string {
+	switch obj := e.(type) {
+	case string:
+		return obj
+	case fmt.Stringer:
+		return obj.String()
+	case error:
+		return obj.Error()
+	default:
+		return fmt.Sprintf("%#v", obj)
+	}
+}
@@ -0,0 +1,89 @@
+package logger_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("panic logging", func() {
+	var (
+		buf    *bytes.Buffer
+		logger Logger
+	)
+
+	BeforeEach(func() {
+		buf = bytes.NewBufferString("")
+		logger = NewWriterLogger(LevelError, buf)
+	})
+
+	Describe("RecoverAndLog", func() {
+		It("logs the panic with a stack trace and recovers", func() {
+			func() {
+				defer logger.RecoverAndLog("fake-tag")
+				panic("fake-panic")
+			}()
+
+			Expect(buf.String()).To(ContainSubstring("[fake-tag]"))
+			Expect(buf.String()).To(ContainSubstring("ERROR - Panic: fake-panic"))
+			Expect(buf.String()).To(ContainSubstring("panic_test.go"))
+		})
+
+		It("does nothing without a panic", func() {
+			func() {
+				defer logger.RecoverAndLog("fake-tag")
+			}()
+
+			Expect(buf.String()).To(BeEmpty())
+		})
+
+		It("recovers with the async logger", func() {
+			asyncLogger := NewAsyncWriterLogger(LevelError, buf)
+
+			func() {
+				defer asyncLogger.RecoverAndLog("fake-tag")
+				panic(errors.New("fake-error"))
+			}()
+
+			Expect(buf.String()).To(ContainSubstring("Panic: fake-error"))
+		})
+	})
+
+	Describe("WrapGoroutine", func() {
+		It("logs panics of the wrapped function", func() {
+			done := make(chan struct{})
+
+			go func() {
+				defer close(done)
+				WrapGoroutine(logger, "fake-tag", false, func() {
+					panic(errors.New("fake-error"))
+				})()
+			}()
+
+			Eventually(done).Should(BeClosed())
+			Expect(buf.String()).To(ContainSubstring("[fake-tag]"))
+			Expect(buf.String()).To(ContainSubstring("Panic: fake-error"))
+		})
+
+		It("re-panics after logging when asked to", func() {
+			wrapped := WrapGoroutine(logger, "fake-tag", true, func() {
+				panic("fake-panic")
+			})
+
+			Expect(wrapped).To(PanicWith("fake-panic"))
+			Expect(buf.String()).To(ContainSubstring("Panic: fake-panic"))
+		})
+
+		It("runs the wrapped function", func() {
+			called := false
+			WrapGoroutine(logger, "fake-tag", false, func() { called = true })()
+
+			Expect(called).To(BeTrue())
+			Expect(buf.String()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const shutdownLogTag = "shutdown"
+
+type ShutdownOpts struct {
+	// Timeout bounds how long flushing may delay the exit, defaults to 5s
+	Timeout time.Duration
+
+	// Signals default to SIGINT and SIGTERM
+	Signals []os.Signal
+
+	// Exit is called with 128 + the signal number once the logger is
+	// closed, defaults to os.Exit
+	Exit func(code int)
+}
+
+// FlushOnSignal closes logger when one of the signals is received and then
+// exits, so that the last entries before a shutdown are not lost in buffers.
+// The returned function stops listening for the signals.
+func FlushOnSignal(logger Logger, opts ShutdownOpts) (stop func()) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	if opts.Exit == nil {
+		opts.Exit = os.Exit
+	}
+
+	signalCh := make(chan os.Signal, 1)
+	stopCh := make(chan struct{})
+
+	signal.Notify(signalCh, opts.Signals...)
+
+	go func() {
+		select {
+		case sig := <-signalCh:
+			logger.Info(shutdownLogTag, "Received signal '%s', flushing logs before exiting", sig)
+
+			ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+			err := logger.Close(ctx)
+			cancel()
+
+			if err != nil {
+				os.Stderr.WriteString("logger: flushing on shutdown: " + err.Error() + "\n")
+			}
+
+			opts.Exit(exitCode(sig))
+		case <-stopCh:
+		}
+	}()
+
+	var stopOnce sync.Once
+
+	return func() {
+		stopOnce.Do(func() {
+			signal.Stop(signalCh)
+			close(stopCh)
+		})
+	}
+}
+
+func exitCode(sig os.Signal) int {
+	if sysSig, ok := sig.(syscall.Signal); ok {
+		return 128 + int(sysSig)
+	}
+	return 1
+}
+
+type flusher interface {
+	Flush() error
+}
+
+func flushWriter(ctx context.Context, w io.Writer) error {
+	if f, ok := w.(flusher); ok {
+		return runUntilDone(ctx, f.Flush)
+	}
+	return nil
+}
+
+// runUntilDone returns when fn returns or ctx is done, whatever happens first
+func runUntilDone(ctx context.Context, fn func() error) error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- fn()
+	}()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return errors.New("logger: flush did not finish: " + ctx.Err().Error())
+	}
+}
@@ -0,0 +1,87 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// bufferingWriter only passes entries on when flushed, like network.Sink
+type bufferingWriter struct {
+	sync.Mutex
+	pending bytes.Buffer
+	flushed bytes.Buffer
+	block   chan struct{}
+}
+
+func (w *bufferingWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	return w.pending.Write(p)
+}
+
+func (w *bufferingWriter) Flush() error {
+	if w.block != nil {
+		<-w.block
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	_, err := w.pending.WriteTo(&w.flushed)
+	return err
+}
+
+func (w *bufferingWriter) Flushed() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.flushed.String()
+}
+
+var _ = Describe("Close", func() {
+	var writer *bufferingWriter
+
+	BeforeEach(func() {
+		writer = &bufferingWriter{}
+	})
+
+	It("flushes the writer of a logger", func() {
+		logger := NewWriterLogger(LevelDebug, writer)
+		logger.Error("fake-tag", "last words")
+
+		Expect(logger.Close(context.Background())).To(Succeed())
+		Expect(writer.Flushed()).To(ContainSubstring("last words"))
+	})
+
+	It("flushes queued entries and the writer of an async logger", func() {
+		logger := NewAsyncWriterLogger(LevelDebug, writer)
+		for i := 0; i < 100; i++ {
+			logger.Error("fake-tag", "entry %d", i)
+		}
+
+		Expect(logger.Close(context.Background())).To(Succeed())
+		Expect(writer.Flushed()).To(ContainSubstring("entry 99"))
+	})
+
+	It("returns an error when flushing does not finish before ctx is done", func() {
+		writer.block = make(chan struct{})
+		defer close(writer.block)
+
+		logger := NewWriterLogger(LevelDebug, writer)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		Expect(logger.Close(ctx)).To(MatchError("logger: flush did not finish: context deadline exceeded"))
+	})
+
+	It("succeeds for writers that do not buffer", func() {
+		logger := NewWriterLogger(LevelDebug, &bytes.Buffer{})
+		Expect(logger.Close(context.Background())).To(Succeed())
+	})
+})
@@ -0,0 +1,66 @@
+//go:build !windows
+// +build !windows
+
+package logger_test
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("FlushOnSignal", func() {
+	It("flushes the logger and exits when a signal is received", func() {
+		writer := &bufferingWriter{}
+		logger := NewAsyncWriterLogger(LevelDebug, writer)
+
+		exitCodes := make(chan int, 1)
+		stop := FlushOnSignal(logger, ShutdownOpts{
+			Signals: []os.Signal{syscall.SIGUSR1},
+			Exit:    func(code int) { exitCodes <- code },
+		})
+		defer stop()
+
+		logger.Error("fake-tag", "last words")
+
+		Expect(syscall.Kill(os.Getpid(), syscall.SIGUSR1)).To(Succeed())
+
+		Eventually(exitCodes).Should(Receive(Equal(128 + int(syscall.SIGUSR1))))
+		Expect(writer.Flushed()).To(ContainSubstring("last words"))
+		Expect(writer.Flushed()).To(ContainSubstring("Received signal 'user defined signal 1'"))
+	})
+
+	It("exits even when flushing does not finish within the timeout", func() {
+		writer := &bufferingWriter{block: make(chan struct{})}
+		defer close(writer.block)
+
+		exitCodes := make(chan int, 1)
+		stop := FlushOnSignal(NewWriterLogger(LevelDebug, writer), ShutdownOpts{
+			Timeout: 10 * time.Millisecond,
+			Signals: []os.Signal{syscall.SIGUSR2},
+			Exit:    func(code int) { exitCodes <- code },
+		})
+		defer stop()
+
+		Expect(syscall.Kill(os.Getpid(), syscall.SIGUSR2)).To(Succeed())
+
+		Eventually(exitCodes).Should(Receive())
+	})
+
+	It("stops listening for signals", func() {
+		exitCodes := make(chan int, 1)
+		stop := FlushOnSignal(NewWriterLogger(LevelNone, &bufferingWriter{}), ShutdownOpts{
+			Signals: []os.Signal{syscall.SIGUSR1},
+			Exit:    func(code int) { exitCodes <- code },
+		})
+		stop()
+		stop()
+
+		Consistently(exitCodes, 50*time.Millisecond).ShouldNot(Receive())
+	})
+})
@@ -3,6 +3,8 @@ package retrystrategy
 import (
 	"time"
 
+	"code.cloudfoundry.org/clock"
+
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
@@ -10,6 +12,7 @@ type attemptRetryStrategy struct {
 	maxAttempts int
 	delay       time.Duration
 	retryable   Retryable
+	timeService Clock
 	logger      boshlog.Logger
 	logTag      string
 }
@@ -19,11 +22,22 @@ func NewAttemptRetryStrategy(
 	delay time.Duration,
 	retryable Retryable,
 	logger boshlog.Logger,
+) RetryStrategy {
+	return NewAttemptRetryStrategyWithClock(maxAttempts, delay, retryable, clock.NewClock(), logger)
+}
+
+func NewAttemptRetryStrategyWithClock(
+	maxAttempts int,
+	delay time.Duration,
+	retryable Retryable,
+	timeService Clock,
+	logger boshlog.Logger,
 ) RetryStrategy {
 	return &attemptRetryStrategy{
 		maxAttempts: maxAttempts,
 		delay:       delay,
 		retryable:   retryable,
+		timeService: timeService,
 		logger:      logger,
 		logTag:      "attemptRetryStrategy",
 	}
@@ -41,7 +55,7 @@ func (s *attemptRetryStrategy) Try() error {
 			return err
 		}
 
-		time.Sleep(s.delay)
+		s.timeService.Sleep(s.delay)
 	}
 
 	return err
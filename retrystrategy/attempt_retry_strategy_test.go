@@ -3,7 +3,9 @@ package retrystrategy_test
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"code.cloudfoundry.org/clock/fakeclock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -121,5 +123,25 @@ var _ = Describe("AttemptRetryStrategy", func() {
 				Expect(retryable.Attempts).To(Equal(1))
 			})
 		})
+
+		It("waits for the delay on the given clock between attempts", func() {
+			retryable := newSimpleRetryable([]attemptOutput{
+				{ShouldRetry: true, AttemptErr: errors.New("first-error")},
+				{ShouldRetry: true, AttemptErr: errors.New("second-error")},
+				{ShouldRetry: false, AttemptErr: nil},
+			})
+			fakeTimeService := fakeclock.NewFakeClock(time.Now())
+
+			attemptRetryStrategy := NewAttemptRetryStrategyWithClock(10, time.Hour, retryable, fakeTimeService, logger)
+
+			errCh := make(chan error)
+			go func() { errCh <- attemptRetryStrategy.Try() }()
+
+			fakeTimeService.WaitForWatcherAndIncrement(time.Hour)
+			fakeTimeService.WaitForWatcherAndIncrement(time.Hour)
+
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(retryable.Attempts).To(Equal(3))
+		})
 	})
 })
@@ -0,0 +1,44 @@
+package system
+
+import (
+	"sync"
+)
+
+// RunFunc starts a command
+type RunFunc func(cmd Command) (Process, error)
+
+// CmdHook wraps the start of every command, e.g. to audit, measure or
+// refuse commands. It may change the command, return its own error
+// instead of calling next or wrap the returned Process to observe its
+// Result.
+type CmdHook func(next RunFunc) RunFunc
+
+// CmdHookAdder is implemented by the runner returned by NewExecCmdRunner
+type CmdHookAdder interface {
+	// AddHook adds a hook that runs after the hooks added before it
+	AddHook(hook CmdHook)
+}
+
+type cmdHooks struct {
+	hooks []CmdHook
+	lock  sync.RWMutex
+}
+
+func (h *cmdHooks) add(hook CmdHook) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.hooks = append(h.hooks, hook)
+}
+
+// wrap puts the first added hook outermost
+func (h *cmdHooks) wrap(run RunFunc) RunFunc {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for i := len(h.hooks) - 1; i >= 0; i-- {
+		run = h.hooks[i](run)
+	}
+
+	return run
+}
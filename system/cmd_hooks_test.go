@@ -0,0 +1,140 @@
+package system_test
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type observedProcess struct {
+	Process
+	onResult func(Result)
+}
+
+func (p observedProcess) Wait() <-chan Result {
+	resultCh := make(chan Result, 1)
+	go func() {
+		result := <-p.Process.Wait()
+		p.onResult(result)
+		resultCh <- result
+	}()
+	return resultCh
+}
+
+var _ = Describe("execCmdRunner hooks", func() {
+	var (
+		runner CmdRunner
+		hooks  CmdHookAdder
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip("Uses unix commands")
+		}
+
+		runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+		hooks = runner.(CmdHookAdder)
+	})
+
+	It("wraps every command with the hooks in the order they were added", func() {
+		var calls []string
+
+		for _, name := range []string{"audit", "metrics"} {
+			name := name
+			hooks.AddHook(func(next RunFunc) RunFunc {
+				return func(cmd Command) (Process, error) {
+					calls = append(calls, name+" "+cmd.Name)
+					return next(cmd)
+				}
+			})
+		}
+
+		stdout, _, _, err := runner.RunCommand("echo", "hi")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("hi\n"))
+
+		Expect(calls).To(Equal([]string{"audit echo", "metrics echo"}))
+	})
+
+	It("lets hooks refuse commands", func() {
+		hooks.AddHook(func(next RunFunc) RunFunc {
+			return func(cmd Command) (Process, error) {
+				if cmd.Name == "rm" && strings.Join(cmd.Args, " ") == "-rf /" {
+					return nil, errors.New("Refusing to remove /")
+				}
+				return next(cmd)
+			}
+		})
+
+		_, _, _, err := runner.RunCommand("rm", "-rf", "/")
+		Expect(err).To(MatchError("Refusing to remove /"))
+
+		_, err = runner.RunComplexCommandAsync(Command{Name: "rm", Args: []string{"-rf", "/"}})
+		Expect(err).To(MatchError("Refusing to remove /"))
+	})
+
+	It("lets hooks observe results", func() {
+		var exitStatuses []int
+
+		hooks.AddHook(func(next RunFunc) RunFunc {
+			return func(cmd Command) (Process, error) {
+				process, err := next(cmd)
+				if err != nil {
+					return nil, err
+				}
+				return observedProcess{Process: process, onResult: func(result Result) {
+					exitStatuses = append(exitStatuses, result.ExitStatus)
+				}}, nil
+			}
+		})
+
+		_, _, _, err := runner.RunCommand("false")
+		Expect(err).To(HaveOccurred())
+
+		Expect(exitStatuses).To(Equal([]int{1}))
+	})
+
+	It("runs every pipeline stage through the hooks", func() {
+		var names []string
+
+		hooks.AddHook(func(next RunFunc) RunFunc {
+			return func(cmd Command) (Process, error) {
+				names = append(names, cmd.Name)
+				return next(cmd)
+			}
+		})
+
+		results, err := runner.RunPipeline(Command{Name: "echo", Args: []string{"hi"}}, Command{Name: "cat"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[1].Stdout).To(Equal("hi\n"))
+
+		Expect(names).To(Equal([]string{"echo", "cat"}))
+	})
+
+	It("tees output lines to a logger with WithOutputTee", func() {
+		logBuffer := &bytes.Buffer{}
+		hooks.AddHook(WithOutputTee(boshlog.NewWriterLogger(boshlog.LevelInfo, logBuffer), "fake-tag"))
+
+		var lines []string
+
+		stdout, _, _, err := runner.RunComplexCommand(Command{
+			Name:           "/bin/sh",
+			Args:           []string{"-c", "echo out-1; echo err-1 >&2; echo out-2"},
+			StdoutLineFunc: func(line string) { lines = append(lines, line) },
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("out-1\nout-2\n"))
+		Expect(lines).To(Equal([]string{"out-1", "out-2"}))
+
+		Expect(logBuffer.String()).To(MatchRegexp(`\[fake-tag\] .* INFO - sh stdout: out-1\n`))
+		Expect(logBuffer.String()).To(MatchRegexp(`\[fake-tag\] .* INFO - sh stderr: err-1\n`))
+		Expect(logBuffer.String()).To(MatchRegexp(`\[fake-tag\] .* INFO - sh stdout: out-2\n`))
+	})
+})
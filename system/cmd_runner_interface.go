@@ -2,7 +2,11 @@ package system
 
 import (
 	"io"
+	"os"
+	"syscall"
 	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 type Command struct {
@@ -11,6 +15,11 @@ type Command struct {
 	Env            map[string]string
 	UseIsolatedEnv bool
 
+	// EnvFiles are read with ReadEnvFile right before the command starts,
+	// e.g. to keep credentials out of code. Later files replace variables
+	// of earlier ones and Env replaces variables of all files.
+	EnvFiles []string
+
 	WorkingDir string
 
 	// On Linux when enabled inherits process group
@@ -19,12 +28,105 @@ type Command struct {
 	// Don't echo stdout/stderr
 	Quiet bool
 
+	// CPUSet restricts the command to the given logical CPUs.
+	// On Windows CPU n refers to processor n%64 of processor group n/64
+	// and all CPUs have to belong to the same group.
+	CPUSet []int
+
+	// NUMANode restricts the command to the CPUs of the given NUMA node.
+	// When combined with CPUSet only CPUs present in both are used.
+	NUMANode *int
+
+	// Limits restricts the resources the command may use
+	Limits ResourceLimits
+
+	// Priority lowers or raises the CPU and IO priority of the command,
+	// e.g. so that background maintenance does not starve other processes
+	Priority ProcessPriority
+
 	Stdin io.Reader
 
 	// Full stdout and stderr will be captured to memory
 	// and returned in the Result unless custom Stdout/Stderr are specified.
 	Stdout io.Writer
 	Stderr io.Writer
+
+	// MaxOutputBytes limits how much of stdout and stderr each is captured
+	// to memory; the rest is dropped. Custom Stdout/Stderr, LogOutput and
+	// line funcs still receive the whole output. 0 uses the limit of the
+	// runner, see ExecCmdRunnerOpts.
+	MaxOutputBytes int64
+
+	// LogOutput additionally logs every stdout and stderr line while the command runs
+	LogOutput *LogOutput
+
+	// StdoutLineFunc and StderrLineFunc are called with every line as soon
+	// as the command wrote it, without the line ending. A last line without
+	// newline is passed once the command exited. They are called from the
+	// goroutine copying the output and should return quickly.
+	StdoutLineFunc func(line string)
+	StderrLineFunc func(line string)
+
+	// InactivityTimeout kills the command when it writes nothing to stdout
+	// or stderr for that long. The Result error is an InactivityTimeoutError.
+	InactivityTimeout time.Duration
+
+	// Timeout kills the command together with the processes it started
+	// once it runs longer than that. The Result error is a CommandTimeoutError
+	// and still contains the output captured until then.
+	Timeout time.Duration
+
+	// Termination decides how the command is stopped by Process.Terminate
+	// and when Timeout or InactivityTimeout expire
+	Termination TerminationPolicy
+
+	// Preconditions are waited for in order before the command is started
+	Preconditions []Precondition
+
+	// WindowsConsole decides which console the command gets on Windows.
+	// It is ignored on other platforms.
+	WindowsConsole WindowsConsoleMode
+}
+
+// TerminationPolicy asks commands to exit with SIGTERM, or CTRL_BREAK on
+// Windows, and kills them once GracePeriod passed. Without a GracePeriod
+// commands are killed right away.
+//
+// On Windows CTRL_BREAK only reaches commands sharing the console of the
+// current process; others are killed after the GracePeriod.
+type TerminationPolicy struct {
+	GracePeriod time.Duration
+}
+
+type WindowsConsoleMode string
+
+const (
+	// WindowsConsoleInherit shares the console of the current process.
+	// It is the default, like for commands started with os/exec.
+	WindowsConsoleInherit WindowsConsoleMode = ""
+
+	// WindowsConsoleAuto gives commands a hidden console when the current
+	// process has none, e.g. when it runs as a service, and otherwise
+	// lets them share the console of the current process
+	WindowsConsoleAuto WindowsConsoleMode = "auto"
+
+	// WindowsConsoleHidden gives commands a new console without a window
+	WindowsConsoleHidden WindowsConsoleMode = "hidden"
+
+	// WindowsConsoleDetached starts commands without any console;
+	// output written to the console instead of stdout/stderr is lost
+	WindowsConsoleDetached WindowsConsoleMode = "detached"
+)
+
+type LogOutput struct {
+	Logger boshlog.Logger
+
+	// Tag defaults to the command name
+	Tag string
+
+	// StdoutLevel defaults to LevelInfo and StderrLevel to LevelWarn
+	StdoutLevel *boshlog.LogLevel
+	StderrLevel *boshlog.LogLevel
 }
 
 type Process interface {
@@ -35,15 +137,50 @@ type Process interface {
 	// TerminateNicely can be called multiple times.
 	// It must only be called after Wait().
 	TerminateNicely(killGracePeriod time.Duration) error
+
+	// Terminate calls TerminateNicely with the grace period of Command.Termination.
+	// It must only be called after Wait().
+	Terminate() error
+
+	// SampleUsage returns CPU and memory usage of the process while it is running.
+	// It returns an error once the process has exited or on unsupported platforms.
+	SampleUsage() (ProcessUsage, error)
+
+	// Pid returns the ID of the started process, e.g. to write a pidfile
+	Pid() int
+
+	// Signal sends sig to the process only, not to the processes it started.
+	// On Windows only os.Kill is supported.
+	Signal(sig os.Signal) error
 }
 
 type Result struct {
 	// Full stdout and stderr are captured to memory
+	// unless they are limited by Command.MaxOutputBytes
 	Stdout string
 	Stderr string
 
+	// StdoutTruncated and StderrTruncated report whether output
+	// was dropped because of Command.MaxOutputBytes
+	StdoutTruncated bool
+	StderrTruncated bool
+
 	ExitStatus int
 	Error      error
+
+	PID        int
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// Duration is the wall-clock time between start and exit
+	Duration time.Duration
+
+	// Signal is the signal that terminated the command, 0 when it exited.
+	// Commands never exit because of a signal on Windows.
+	Signal syscall.Signal
+
+	// Usage is the CPU time, memory and IO the command used
+	Usage CommandUsage
 }
 
 type CmdRunner interface {
@@ -56,6 +193,13 @@ type CmdRunner interface {
 
 	RunComplexCommandAsync(cmd Command) (Process, error)
 
+	// RunPipeline runs the commands at the same time with the stdout of
+	// each command connected to the stdin of the next one, like a shell
+	// pipeline. Only the first command may set Stdin and only the last one
+	// Stdout. Results are returned for every stage; only the last one
+	// contains stdout. err wraps the error of the first failing stage.
+	RunPipeline(cmds ...Command) (results []Result, err error)
+
 	RunCommand(cmdName string, args ...string) (stdout, stderr string, exitStatus int, err error)
 
 	RunCommandQuietly(cmdName string, args ...string) (stdout, stderr string, exitStatus int, err error)
@@ -63,4 +207,8 @@ type CmdRunner interface {
 	RunCommandWithInput(input, cmdName string, args ...string) (stdout, stderr string, exitStatus int, err error)
 
 	CommandExists(cmdName string) (exists bool)
+
+	// LookPath resolves cmdName like exec.LookPath and returns
+	// a CommandNotFoundError listing the searched PATH entries when it is missing
+	LookPath(cmdName string, opts LookPathOpts) (CommandLookup, error)
 }
@@ -0,0 +1,215 @@
+package system
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// BlockDevice is a device listed by `lsblk --json --bytes`
+type BlockDevice struct {
+	Name      string
+	Path      string
+	Type      string
+	SizeBytes uint64
+	ReadOnly  bool
+	Removable bool
+	FSType    string
+	Label     string
+	UUID      string
+	Serial    string
+	Children  []BlockDevice
+
+	// Mountpoint is the first of Mountpoints. Older lsblk
+	// versions only list one mountpoint.
+	Mountpoint  string
+	Mountpoints []string
+}
+
+func (d *BlockDevice) UnmarshalJSON(data []byte) error {
+	var device struct {
+		Name        string        `json:"name"`
+		Path        string        `json:"path"`
+		Type        string        `json:"type"`
+		Size        lsblkUint     `json:"size"`
+		RO          lsblkBool     `json:"ro"`
+		RM          lsblkBool     `json:"rm"`
+		FSType      string        `json:"fstype"`
+		Label       string        `json:"label"`
+		UUID        string        `json:"uuid"`
+		Serial      string        `json:"serial"`
+		Mountpoint  string        `json:"mountpoint"`
+		Mountpoints []*string     `json:"mountpoints"`
+		Children    []BlockDevice `json:"children"`
+	}
+
+	err := json.Unmarshal(data, &device)
+	if err != nil {
+		return err
+	}
+
+	*d = BlockDevice{
+		Name:      device.Name,
+		Path:      device.Path,
+		Type:      device.Type,
+		SizeBytes: uint64(device.Size),
+		ReadOnly:  bool(device.RO),
+		Removable: bool(device.RM),
+		FSType:    device.FSType,
+		Label:     device.Label,
+		UUID:      device.UUID,
+		Serial:    device.Serial,
+		Children:  device.Children,
+	}
+
+	for _, mountpoint := range device.Mountpoints {
+		if mountpoint != nil {
+			d.Mountpoints = append(d.Mountpoints, *mountpoint)
+		}
+	}
+
+	if device.Mountpoint != "" && len(d.Mountpoints) == 0 {
+		d.Mountpoints = []string{device.Mountpoint}
+	}
+
+	if len(d.Mountpoints) > 0 {
+		d.Mountpoint = d.Mountpoints[0]
+	}
+
+	return nil
+}
+
+// ParseLsblkJSON parses the output of `lsblk --json --bytes` with any
+// selection of columns. Older lsblk versions print every value as a
+// string; numbers and booleans are accepted in both forms.
+func ParseLsblkJSON(output string) ([]BlockDevice, error) {
+	var result struct {
+		BlockDevices []BlockDevice `json:"blockdevices"`
+	}
+
+	err := json.Unmarshal([]byte(output), &result)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing lsblk output")
+	}
+
+	return result.BlockDevices, nil
+}
+
+// NetworkInterface is an interface listed by `ip -json addr`
+type NetworkInterface struct {
+	Index     int              `json:"ifindex"`
+	Name      string           `json:"ifname"`
+	Flags     []string         `json:"flags"`
+	MTU       int              `json:"mtu"`
+	OperState string           `json:"operstate"`
+	LinkType  string           `json:"link_type"`
+	MAC       string           `json:"address"`
+	Addresses []NetworkAddress `json:"addr_info"`
+}
+
+type NetworkAddress struct {
+	// Family is inet or inet6
+	Family    string `json:"family"`
+	IP        string `json:"local"`
+	PrefixLen int    `json:"prefixlen"`
+	Broadcast string `json:"broadcast"`
+	Scope     string `json:"scope"`
+	Label     string `json:"label"`
+	Dynamic   bool   `json:"dynamic"`
+}
+
+// ParseIPAddrJSON parses the output of `ip -json addr`. Entries some
+// ip versions print for interfaces without addresses are kept.
+func ParseIPAddrJSON(output string) ([]NetworkInterface, error) {
+	var interfaces []NetworkInterface
+
+	err := json.Unmarshal([]byte(output), &interfaces)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing ip addr output")
+	}
+
+	return interfaces, nil
+}
+
+// ParseCimInstancesJSON parses the output of
+// `Get-CimInstance ... | ConvertTo-Json` into instances, which must be a
+// pointer to a slice. ConvertTo-Json prints nothing for no instances and
+// a single object instead of an array for one instance; both are handled.
+func ParseCimInstancesJSON(output string, instances interface{}) error {
+	trimmed := bytes.TrimSpace([]byte(output))
+
+	switch {
+	case len(trimmed) == 0:
+		trimmed = []byte("[]")
+	case trimmed[0] == '{':
+		trimmed = append(append([]byte("["), trimmed...), ']')
+	}
+
+	err := json.Unmarshal(trimmed, instances)
+	if err != nil {
+		return bosherr.WrapError(err, "Parsing CIM instances")
+	}
+
+	return nil
+}
+
+// LogicalDisk is a Win32_LogicalDisk instance, see ParseCimInstancesJSON
+type LogicalDisk struct {
+	DeviceID   string `json:"DeviceID"`
+	DriveType  int    `json:"DriveType"`
+	FileSystem string `json:"FileSystem"`
+	VolumeName string `json:"VolumeName"`
+	Size       uint64 `json:"Size"`
+	FreeSpace  uint64 `json:"FreeSpace"`
+}
+
+// NetworkAdapterConfiguration is a Win32_NetworkAdapterConfiguration
+// instance, see ParseCimInstancesJSON
+type NetworkAdapterConfiguration struct {
+	Index            int      `json:"Index"`
+	Description      string   `json:"Description"`
+	MACAddress       string   `json:"MACAddress"`
+	IPEnabled        bool     `json:"IPEnabled"`
+	DHCPEnabled      bool     `json:"DHCPEnabled"`
+	IPAddress        []string `json:"IPAddress"`
+	IPSubnet         []string `json:"IPSubnet"`
+	DefaultIPGateway []string `json:"DefaultIPGateway"`
+	DNSServers       []string `json:"DNSServerSearchOrder"`
+}
+
+// lsblkUint accepts numbers, numeric strings and null
+type lsblkUint uint64
+
+func (u *lsblkUint) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "null" || value == "" {
+		*u = 0
+		return nil
+	}
+
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return bosherr.Errorf("Expected a number of bytes but got '%s', run lsblk with --bytes", value)
+	}
+
+	*u = lsblkUint(n)
+	return nil
+}
+
+// lsblkBool accepts booleans, "0"/"1" and null
+type lsblkBool bool
+
+func (b *lsblkBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true", "1":
+		*b = true
+	case "false", "0", "null", "":
+		*b = false
+	default:
+		return bosherr.Errorf("Expected a boolean but got '%s'", data)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package system_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("command output parsers", func() {
+	readGolden := func(name string) string {
+		contents, err := os.ReadFile(filepath.Join("test_assets", "command_output", name))
+		Expect(err).ToNot(HaveOccurred())
+		return string(contents)
+	}
+
+	Describe("ParseLsblkJSON", func() {
+		It("parses devices with their children", func() {
+			devices, err := ParseLsblkJSON(readGolden("lsblk.json"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(devices).To(Equal([]BlockDevice{
+				{
+					Name:      "sda",
+					Path:      "/dev/sda",
+					Type:      "disk",
+					SizeBytes: 10737418240,
+					Serial:    "QM00001",
+					Children: []BlockDevice{
+						{
+							Name:        "sda1",
+							Path:        "/dev/sda1",
+							Type:        "part",
+							SizeBytes:   10736369664,
+							FSType:      "ext4",
+							Label:       "cloudimg-rootfs",
+							UUID:        "0a3a1b6c-5c5e-4f43-9e4f-4c2b1f1d2e3f",
+							Mountpoint:  "/",
+							Mountpoints: []string{"/", "/var/vcap/data/root_bind"},
+						},
+					},
+				},
+				{
+					Name:      "sr0",
+					Path:      "/dev/sr0",
+					Type:      "rom",
+					SizeBytes: 1073741312,
+					Removable: true,
+					FSType:    "iso9660",
+					Label:     "CONFIG-2",
+					UUID:      "2026-10-16-08-00-00-00",
+					Serial:    "QM00003",
+				},
+			}))
+		})
+
+		It("parses the string values of older lsblk versions", func() {
+			devices, err := ParseLsblkJSON(readGolden("lsblk_legacy.json"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(devices).To(Equal([]BlockDevice{
+				{
+					Name:      "xvda",
+					Type:      "disk",
+					SizeBytes: 8589934592,
+					Children: []BlockDevice{
+						{
+							Name:        "xvda1",
+							Type:        "part",
+							SizeBytes:   8588886016,
+							FSType:      "ext4",
+							Mountpoint:  "/",
+							Mountpoints: []string{"/"},
+						},
+					},
+				},
+				{
+					Name:      "xvdb",
+					Type:      "disk",
+					SizeBytes: 4294967296,
+					ReadOnly:  true,
+				},
+			}))
+		})
+
+		It("returns an error when sizes are not in bytes", func() {
+			_, err := ParseLsblkJSON(`{"blockdevices": [{"name": "sda", "size": "10G"}]}`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("run lsblk with --bytes"))
+		})
+
+		It("returns an error for invalid output", func() {
+			_, err := ParseLsblkJSON("NAME MAJ:MIN RM SIZE")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing lsblk output"))
+		})
+	})
+
+	Describe("ParseIPAddrJSON", func() {
+		It("parses interfaces with their addresses", func() {
+			interfaces, err := ParseIPAddrJSON(readGolden("ip_addr.json"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(interfaces).To(Equal([]NetworkInterface{
+				{
+					Index:     1,
+					Name:      "lo",
+					Flags:     []string{"LOOPBACK", "UP", "LOWER_UP"},
+					MTU:       65536,
+					OperState: "UNKNOWN",
+					LinkType:  "loopback",
+					MAC:       "00:00:00:00:00:00",
+					Addresses: []NetworkAddress{
+						{Family: "inet", IP: "127.0.0.1", PrefixLen: 8, Scope: "host", Label: "lo"},
+						{Family: "inet6", IP: "::1", PrefixLen: 128, Scope: "host"},
+					},
+				},
+				{
+					Index:     2,
+					Name:      "eth0",
+					Flags:     []string{"BROADCAST", "MULTICAST", "UP", "LOWER_UP"},
+					MTU:       1500,
+					OperState: "UP",
+					LinkType:  "ether",
+					MAC:       "42:01:0a:00:00:05",
+					Addresses: []NetworkAddress{
+						{Family: "inet", IP: "10.0.0.5", PrefixLen: 24, Broadcast: "10.0.0.255", Scope: "global", Label: "eth0", Dynamic: true},
+						{Family: "inet6", IP: "fe80::4001:aff:fe00:5", PrefixLen: 64, Scope: "link"},
+					},
+				},
+				{},
+				{
+					Index:     3,
+					Name:      "eth1",
+					Flags:     []string{"BROADCAST", "MULTICAST"},
+					MTU:       1500,
+					OperState: "DOWN",
+					LinkType:  "ether",
+					MAC:       "42:01:0a:00:01:05",
+					Addresses: []NetworkAddress{},
+				},
+			}))
+		})
+
+		It("returns an error for invalid output", func() {
+			_, err := ParseIPAddrJSON("1: lo: <LOOPBACK,UP,LOWER_UP>")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing ip addr output"))
+		})
+	})
+
+	Describe("ParseCimInstancesJSON", func() {
+		It("parses an array of instances", func() {
+			var adapters []NetworkAdapterConfiguration
+
+			err := ParseCimInstancesJSON(readGolden("cim_network_adapters.json"), &adapters)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(adapters).To(Equal([]NetworkAdapterConfiguration{
+				{
+					Index:       1,
+					Description: "Microsoft Kernel Debug Network Adapter",
+					DHCPEnabled: true,
+				},
+				{
+					Index:            4,
+					Description:      "AWS PV Network Device #0",
+					MACAddress:       "0A:1B:2C:3D:4E:5F",
+					IPEnabled:        true,
+					IPAddress:        []string{"10.0.16.5", "fe80::a5d1:5b1:87c3:3fbc"},
+					IPSubnet:         []string{"255.255.255.0", "64"},
+					DefaultIPGateway: []string{"10.0.16.1"},
+					DNSServers:       []string{"10.0.0.2"},
+				},
+			}))
+		})
+
+		It("parses a single instance that is not wrapped in an array", func() {
+			var disks []LogicalDisk
+
+			err := ParseCimInstancesJSON(readGolden("cim_logical_disk_single.json"), &disks)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(disks).To(Equal([]LogicalDisk{
+				{
+					DeviceID:   "C:",
+					DriveType:  3,
+					FileSystem: "NTFS",
+					VolumeName: "Windows",
+					Size:       136251727872,
+					FreeSpace:  98563952640,
+				},
+			}))
+		})
+
+		It("parses empty output as no instances", func() {
+			var disks []LogicalDisk
+
+			err := ParseCimInstancesJSON(" \r\n", &disks)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disks).To(BeEmpty())
+		})
+
+		It("returns an error for invalid output", func() {
+			var disks []LogicalDisk
+
+			err := ParseCimInstancesJSON("DeviceID DriveType", &disks)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing CIM instances"))
+		})
+	})
+})
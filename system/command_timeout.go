@@ -0,0 +1,23 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CommandTimeoutError is the Result error of commands that were killed
+// because they ran longer than Command.Timeout
+type CommandTimeoutError struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (e CommandTimeoutError) Error() string {
+	return fmt.Sprintf("Command '%s' was killed after running for %s", e.Command, e.Timeout)
+}
+
+func IsCommandTimeoutError(err error) bool {
+	var timeoutErr CommandTimeoutError
+	return errors.As(err, &timeoutErr)
+}
@@ -0,0 +1,73 @@
+package system
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+const copyBufferSize = 32 * 1024
+
+var copyBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
+type CopyOpts struct {
+	// Context stops the copy before the next read once it is done
+	Context context.Context
+
+	// Progress is called with the total number of bytes copied
+	// after every write
+	Progress func(copied int64)
+}
+
+// CopyStream copies src to dst like io.Copy with buffers shared across
+// calls. Without Context and Progress it keeps the ReaderFrom and WriterTo
+// fast paths, e.g. copy_file_range between files on Linux.
+func CopyStream(dst io.Writer, src io.Reader, opts CopyOpts) (int64, error) {
+	bufPtr := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(bufPtr)
+
+	buf := *bufPtr
+
+	if opts.Context == nil && opts.Progress == nil {
+		return io.CopyBuffer(dst, src, buf)
+	}
+
+	var written int64
+
+	for {
+		if opts.Context != nil {
+			if err := opts.Context.Err(); err != nil {
+				return written, err
+			}
+		}
+
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			m, err := dst.Write(buf[:n])
+			written += int64(m)
+
+			if opts.Progress != nil {
+				opts.Progress(written)
+			}
+
+			if err != nil {
+				return written, err
+			}
+			if m != n {
+				return written, io.ErrShortWrite
+			}
+		}
+
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package system_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing/iotest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type shortWriter struct{}
+
+func (shortWriter) Write(p []byte) (int, error) {
+	return len(p) / 2, nil
+}
+
+var _ = Describe("CopyStream", func() {
+	content := strings.Repeat("0123456789", 10000)
+
+	It("copies everything", func() {
+		var dst bytes.Buffer
+
+		written, err := CopyStream(&dst, strings.NewReader(content), CopyOpts{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(written).To(Equal(int64(len(content))))
+		Expect(dst.String()).To(Equal(content))
+	})
+
+	It("reports progress after every write", func() {
+		var dst bytes.Buffer
+		var progress []int64
+
+		written, err := CopyStream(&dst, iotest.HalfReader(strings.NewReader(content)), CopyOpts{
+			Progress: func(copied int64) { progress = append(progress, copied) },
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(written).To(Equal(int64(len(content))))
+		Expect(dst.String()).To(Equal(content))
+
+		Expect(len(progress)).To(BeNumerically(">", 1))
+		Expect(progress[len(progress)-1]).To(Equal(int64(len(content))))
+		for i := 1; i < len(progress); i++ {
+			Expect(progress[i]).To(BeNumerically(">", progress[i-1]))
+		}
+	})
+
+	It("stops once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var dst bytes.Buffer
+		written, err := CopyStream(&dst, iotest.OneByteReader(strings.NewReader(content)), CopyOpts{
+			Context: ctx,
+			Progress: func(copied int64) {
+				if copied == 10 {
+					cancel()
+				}
+			},
+		})
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(written).To(Equal(int64(10)))
+		Expect(dst.String()).To(Equal(content[:10]))
+	})
+
+	It("returns read errors", func() {
+		src := io.MultiReader(strings.NewReader("data"), iotest.ErrReader(errors.New("fake-read-error")))
+
+		written, err := CopyStream(io.Discard, src, CopyOpts{Progress: func(int64) {}})
+		Expect(err).To(MatchError("fake-read-error"))
+		Expect(written).To(Equal(int64(4)))
+	})
+
+	It("returns an error for short writes", func() {
+		_, err := CopyStream(shortWriter{}, strings.NewReader(content), CopyOpts{Progress: func(int64) {}})
+		Expect(err).To(MatchError(io.ErrShortWrite))
+	})
+})
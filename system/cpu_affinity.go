@@ -0,0 +1,95 @@
+package system
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// cpuAffinity is the placement requested through Command.CPUSet and Command.NUMANode
+type cpuAffinity struct {
+	cpuSet   []int
+	numaNode *int
+}
+
+func (a cpuAffinity) requested() bool {
+	return len(a.cpuSet) > 0 || a.numaNode != nil
+}
+
+// resolve returns the sorted CPUs the command may run on
+func (a cpuAffinity) resolve(numaNodeCPUs func(node int) ([]int, error)) ([]int, error) {
+	allowed := map[int]bool{}
+
+	for _, cpu := range a.cpuSet {
+		if cpu < 0 {
+			return nil, bosherr.Errorf("Invalid CPU %d", cpu)
+		}
+		allowed[cpu] = true
+	}
+
+	if a.numaNode != nil {
+		if *a.numaNode < 0 {
+			return nil, bosherr.Errorf("Invalid NUMA node %d", *a.numaNode)
+		}
+
+		nodeCPUs, err := numaNodeCPUs(*a.numaNode)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Getting CPUs of NUMA node %d", *a.numaNode)
+		}
+
+		nodeAllowed := map[int]bool{}
+		for _, cpu := range nodeCPUs {
+			if len(a.cpuSet) == 0 || allowed[cpu] {
+				nodeAllowed[cpu] = true
+			}
+		}
+		allowed = nodeAllowed
+	}
+
+	if len(allowed) == 0 {
+		return nil, bosherr.Error("No CPUs left to run command on")
+	}
+
+	cpus := make([]int, 0, len(allowed))
+	for cpu := range allowed {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+
+	return cpus, nil
+}
+
+// parseCPUList parses the kernel's list format, e.g. "0-3,8,10-11"
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return cpus, nil
+	}
+
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing CPU list '%s'", list)
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Parsing CPU list '%s'", list)
+			}
+		}
+
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
@@ -0,0 +1,84 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func (p *execProcess) startCmd() error {
+	if !p.affinity.requested() && !p.priority.requested() && !p.limits.requested() {
+		return p.cmd.Start()
+	}
+
+	var cpus []int
+	var set unix.CPUSet
+
+	if p.affinity.requested() {
+		var err error
+
+		cpus, err = p.affinity.resolve(numaNodeCPUs)
+		if err != nil {
+			return err
+		}
+
+		for _, cpu := range cpus {
+			set.Set(cpu)
+		}
+	}
+
+	errCh := make(chan error, 1)
+
+	// The child inherits the affinity and priority of the thread that
+	// forks it, which also traces it until the limits are set. The thread
+	// is never unlocked so the runtime discards it once the goroutine
+	// returns instead of reusing the restricted thread.
+	go func() {
+		runtime.LockOSThread()
+
+		if cpus != nil {
+			err := unix.SchedSetaffinity(0, &set)
+			if err != nil {
+				errCh <- bosherr.WrapErrorf(err, "Setting CPU affinity to %v", cpus)
+				return
+			}
+		}
+
+		err := p.priority.applyToThread()
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		p.limits.prepare(p.cmd)
+
+		err = p.cmd.Start()
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		err = p.limits.apply(p.cmd.Process.Pid)
+		if err != nil {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+		}
+
+		errCh <- err
+	}()
+
+	return <-errCh
+}
+
+func numaNodeCPUs(node int) ([]int, error) {
+	cpuList, err := os.ReadFile(fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", node))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCPUList(string(cpuList))
+}
@@ -0,0 +1,55 @@
+package system_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("execCmdRunner CPU affinity", func() {
+	var runner CmdRunner
+
+	BeforeEach(func() {
+		runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	It("restricts the command to CPUSet", func() {
+		stdout, _, _, err := runner.RunComplexCommand(Command{
+			Name:   "cat",
+			Args:   []string{"/proc/self/status"},
+			CPUSet: []int{0},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring("Cpus_allowed_list:\t0\n"))
+	})
+
+	It("restricts the command to the CPUs of NUMANode", func() {
+		node := 0
+
+		stdout, _, _, err := runner.RunComplexCommand(Command{
+			Name:     "cat",
+			Args:     []string{"/proc/self/status"},
+			CPUSet:   []int{0},
+			NUMANode: &node,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring("Cpus_allowed_list:\t0\n"))
+	})
+
+	It("returns an error for unknown NUMA nodes", func() {
+		node := 4095
+
+		_, _, _, err := runner.RunComplexCommand(Command{Name: "true", NUMANode: &node})
+		Expect(err).To(MatchError(ContainSubstring("Getting CPUs of NUMA node 4095")))
+	})
+
+	It("returns an error for CPUs that do not exist", func() {
+		_, _, _, err := runner.RunComplexCommand(Command{Name: "true", CPUSet: []int{4095}})
+		Expect(err).To(MatchError(ContainSubstring("Setting CPU affinity to [4095]")))
+
+		_, _, _, err = runner.RunComplexCommand(Command{Name: "true", CPUSet: []int{-1}})
+		Expect(err).To(MatchError(ContainSubstring("Invalid CPU -1")))
+	})
+})
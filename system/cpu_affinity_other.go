@@ -0,0 +1,20 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package system
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func (p *execProcess) startCmd() error {
+	if p.affinity.requested() {
+		return bosherr.Error("CPU affinity is not supported on this platform")
+	}
+
+	if p.priority.requested() {
+		return bosherr.Error("Process priority is not supported on this platform")
+	}
+
+	return p.cmd.Start()
+}
@@ -0,0 +1,114 @@
+package system
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	processSetInformation = 0x0200
+
+	// a KAFFINITY mask holds one bit per processor of a group
+	processorsPerGroup = int(unsafe.Sizeof(uintptr(0)) * 8)
+)
+
+var (
+	procGetCurrentThread           = kernel32DLL.NewProc("GetCurrentThread")
+	procSetThreadGroupAffinity     = kernel32DLL.NewProc("SetThreadGroupAffinity")
+	procSetProcessAffinityMask     = kernel32DLL.NewProc("SetProcessAffinityMask")
+	procGetNumaNodeProcessorMaskEx = kernel32DLL.NewProc("GetNumaNodeProcessorMaskEx")
+)
+
+// groupAffinity mirrors GROUP_AFFINITY
+type groupAffinity struct {
+	Mask     uintptr
+	Group    uint16
+	Reserved [3]uint16
+}
+
+func (p *execProcess) startCmd() error {
+	if !p.affinity.requested() {
+		return p.cmd.Start()
+	}
+
+	cpus, err := p.affinity.resolve(numaNodeCPUs)
+	if err != nil {
+		return err
+	}
+
+	affinity := groupAffinity{Group: uint16(cpus[0] / processorsPerGroup)}
+	for _, cpu := range cpus {
+		if cpu/processorsPerGroup != int(affinity.Group) {
+			return bosherr.Errorf("CPUs %v span multiple processor groups", cpus)
+		}
+		affinity.Mask |= 1 << uint(cpu%processorsPerGroup)
+	}
+
+	errCh := make(chan error, 1)
+
+	// Starting from a thread bound to the group places the child in that
+	// group, the process mask set below fails should it end up elsewhere.
+	// The thread is never unlocked so the runtime discards it once
+	// the goroutine returns instead of reusing the restricted thread.
+	go func() {
+		runtime.LockOSThread()
+
+		thread, _, _ := procGetCurrentThread.Call()
+		r, _, err := procSetThreadGroupAffinity.Call(thread, uintptr(unsafe.Pointer(&affinity)), 0)
+		if r == 0 {
+			errCh <- bosherr.WrapErrorf(err, "Setting thread group affinity to %v", cpus)
+			return
+		}
+
+		errCh <- p.cmd.Start()
+	}()
+
+	err = <-errCh
+	if err != nil {
+		return err
+	}
+
+	err = setProcessAffinityMask(p.cmd.Process.Pid, affinity.Mask)
+	if err != nil {
+		_ = p.cmd.Process.Kill()
+		return bosherr.WrapErrorf(err, "Setting CPU affinity to %v", cpus)
+	}
+
+	return nil
+}
+
+func setProcessAffinityMask(pid int, mask uintptr) error {
+	handle, err := syscall.OpenProcess(processSetInformation|processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening process %d", pid)
+	}
+	defer syscall.CloseHandle(handle)
+
+	r, _, err := procSetProcessAffinityMask.Call(uintptr(handle), mask)
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
+
+func numaNodeCPUs(node int) ([]int, error) {
+	var affinity groupAffinity
+
+	r, _, err := procGetNumaNodeProcessorMaskEx.Call(uintptr(uint16(node)), uintptr(unsafe.Pointer(&affinity)))
+	if r == 0 {
+		return nil, err
+	}
+
+	var cpus []int
+	for i := 0; i < processorsPerGroup; i++ {
+		if affinity.Mask&(1<<uint(i)) != 0 {
+			cpus = append(cpus, int(affinity.Group)*processorsPerGroup+i)
+		}
+	}
+
+	return cpus, nil
+}
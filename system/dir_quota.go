@@ -0,0 +1,281 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type DirQuotaAction int
+
+const (
+	// DirQuotaFail returns a DirQuotaExceededError
+	DirQuotaFail DirQuotaAction = iota
+	// DirQuotaDeleteOldest removes the files with the oldest modification
+	// time until the directory fits into its quota
+	DirQuotaDeleteOldest
+	// DirQuotaCallback leaves freeing space to DirQuotaPolicy.Callback
+	DirQuotaCallback
+)
+
+type DirQuotaPolicy struct {
+	Action DirQuotaAction
+
+	// Callback is called with the usage exceeding the quota,
+	// which is measured again afterwards
+	Callback func(DirUsage) error
+
+	// Keep excludes files from DirQuotaDeleteOldest,
+	// e.g. the log file that is currently written to
+	Keep func(path string) bool
+}
+
+type DirUsageFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// DirUsage describes the files below a directory, oldest first
+type DirUsage struct {
+	Path  string
+	Bytes int64
+	Files []DirUsageFile
+}
+
+// DirQuotaExceededError is returned when a directory still exceeds
+// its quota after the policy was applied
+type DirQuotaExceededError struct {
+	Path      string
+	MaxBytes  int64
+	UsedBytes int64
+}
+
+func (e DirQuotaExceededError) Error() string {
+	return fmt.Sprintf("Directory '%s' uses %d bytes which exceeds its quota of %d bytes", e.Path, e.UsedBytes, e.MaxBytes)
+}
+
+func IsDirQuotaExceededError(err error) bool {
+	var quotaErr DirQuotaExceededError
+	return errors.As(err, &quotaErr)
+}
+
+// DirQuotaEnforcer keeps directories below a size limit. It remembers the
+// entries of every directory it measured and only lists a directory again
+// once its modification time changed; files are still stat'ed every time
+// since they may have grown.
+type DirQuotaEnforcer struct {
+	fs FileSystem
+
+	listings map[string]dirListing
+	lock     sync.Mutex
+}
+
+type dirListing struct {
+	modTime time.Time
+	files   []string
+	dirs    []string
+}
+
+func NewDirQuotaEnforcer(fs FileSystem) *DirQuotaEnforcer {
+	return &DirQuotaEnforcer{
+		fs:       fs,
+		listings: map[string]dirListing{},
+	}
+}
+
+// EnforceDirQuota applies policy once without keeping an index around
+func EnforceDirQuota(fs FileSystem, path string, maxBytes int64, policy DirQuotaPolicy) (DirUsage, error) {
+	return NewDirQuotaEnforcer(fs).Enforce(path, maxBytes, policy)
+}
+
+// Enforce measures path and applies policy when it uses more than maxBytes.
+// It returns the usage after the policy was applied.
+func (e *DirQuotaEnforcer) Enforce(path string, maxBytes int64, policy DirQuotaPolicy) (DirUsage, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	usage, err := e.measure(path)
+	if err != nil {
+		return usage, bosherr.WrapErrorf(err, "Measuring usage of '%s'", path)
+	}
+
+	if usage.Bytes <= maxBytes {
+		return usage, nil
+	}
+
+	switch policy.Action {
+	case DirQuotaDeleteOldest:
+		usage, err = e.deleteOldest(usage, maxBytes, policy.Keep)
+		if err != nil {
+			return usage, err
+		}
+
+	case DirQuotaCallback:
+		if policy.Callback == nil {
+			return usage, bosherr.Errorf("Enforcing quota of '%s': no callback given", path)
+		}
+
+		err = policy.Callback(usage)
+		if err != nil {
+			return usage, bosherr.WrapErrorf(err, "Freeing space in '%s'", path)
+		}
+
+		usage, err = e.measure(path)
+		if err != nil {
+			return usage, bosherr.WrapErrorf(err, "Measuring usage of '%s'", path)
+		}
+	}
+
+	if usage.Bytes > maxBytes {
+		return usage, DirQuotaExceededError{Path: path, MaxBytes: maxBytes, UsedBytes: usage.Bytes}
+	}
+
+	return usage, nil
+}
+
+func (e *DirQuotaEnforcer) deleteOldest(usage DirUsage, maxBytes int64, keep func(string) bool) (DirUsage, error) {
+	var remaining []DirUsageFile
+
+	for i, file := range usage.Files {
+		if usage.Bytes <= maxBytes {
+			remaining = append(remaining, usage.Files[i:]...)
+			break
+		}
+
+		if keep != nil && keep(file.Path) {
+			remaining = append(remaining, file)
+			continue
+		}
+
+		err := e.fs.RemoveAll(file.Path)
+		if err != nil {
+			usage.Files = append(remaining, usage.Files[i:]...)
+			return usage, bosherr.WrapErrorf(err, "Removing '%s' to enforce quota of '%s'", file.Path, usage.Path)
+		}
+
+		usage.Bytes -= file.Size
+	}
+
+	usage.Files = remaining
+
+	return usage, nil
+}
+
+func (e *DirQuotaEnforcer) measure(path string) (DirUsage, error) {
+	usage := DirUsage{Path: path}
+
+	err := e.measureDir(path, &usage)
+	if err != nil {
+		return usage, err
+	}
+
+	sort.Slice(usage.Files, func(i, j int) bool {
+		a, b := usage.Files[i], usage.Files[j]
+		if a.ModTime.Equal(b.ModTime) {
+			return a.Path < b.Path
+		}
+		return a.ModTime.Before(b.ModTime)
+	})
+
+	return usage, nil
+}
+
+func (e *DirQuotaEnforcer) measureDir(dir string, usage *DirUsage) error {
+	info, err := e.fs.Lstat(dir)
+	if err != nil {
+		delete(e.listings, dir)
+		if os.IsNotExist(err) && dir != usage.Path {
+			return nil
+		}
+		return err
+	}
+
+	listing, found := e.listings[dir]
+	if !found || info.ModTime().IsZero() || !listing.modTime.Equal(info.ModTime()) {
+		listing, err = e.list(dir, info.ModTime())
+		if err != nil {
+			return err
+		}
+		e.listings[dir] = listing
+	}
+
+	for _, file := range listing.files {
+		fileInfo, err := e.fs.Lstat(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		usage.Bytes += fileInfo.Size()
+		usage.Files = append(usage.Files, DirUsageFile{
+			Path:    file,
+			Size:    fileInfo.Size(),
+			ModTime: fileInfo.ModTime(),
+		})
+	}
+
+	for _, subdir := range listing.dirs {
+		err := e.measureDir(subdir, usage)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *DirQuotaEnforcer) list(dir string, modTime time.Time) (dirListing, error) {
+	listing := dirListing{modTime: modTime}
+
+	entries, err := e.fs.Glob(filepath.Join(escapeGlob(dir), "*"))
+	if err != nil {
+		return listing, err
+	}
+
+	for _, entry := range entries {
+		info, err := e.fs.Lstat(entry)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return listing, err
+		}
+
+		if info.IsDir() {
+			listing.dirs = append(listing.dirs, entry)
+		} else {
+			listing.files = append(listing.files, entry)
+		}
+	}
+
+	return listing, nil
+}
+
+// escapeGlob quotes glob metacharacters; backslashes are
+// path separators on Windows so they can not be escaped there
+func escapeGlob(path string) string {
+	if runtime.GOOS == "windows" {
+		return path
+	}
+
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package system_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("DirQuotaEnforcer", func() {
+	var (
+		dir      string
+		fs       FileSystem
+		enforcer *DirQuotaEnforcer
+	)
+
+	writeFile := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, make([]byte, size), 0644)).To(Succeed())
+
+		modTime := time.Now().Add(-age)
+		Expect(os.Chtimes(path, modTime, modTime)).To(Succeed())
+
+		return path
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		fs = NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		enforcer = NewDirQuotaEnforcer(fs)
+	})
+
+	It("measures files in nested directories oldest first", func() {
+		newest := writeFile("newest", 10, time.Minute)
+		oldest := writeFile("nested/deeper/oldest", 20, time.Hour)
+		writeFile("nested/middle", 30, 30*time.Minute)
+
+		usage, err := enforcer.Enforce(dir, 100, DirQuotaPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(usage.Path).To(Equal(dir))
+		Expect(usage.Bytes).To(Equal(int64(60)))
+		Expect(usage.Files).To(HaveLen(3))
+		Expect(usage.Files[0].Path).To(Equal(oldest))
+		Expect(usage.Files[0].Size).To(Equal(int64(20)))
+		Expect(usage.Files[2].Path).To(Equal(newest))
+	})
+
+	It("notices files that grew or were added since the last measurement", func() {
+		path := writeFile("log", 10, time.Minute)
+
+		usage, err := enforcer.Enforce(dir, 100, DirQuotaPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(usage.Bytes).To(Equal(int64(10)))
+
+		Expect(os.WriteFile(path, make([]byte, 40), 0644)).To(Succeed())
+		usage, err = enforcer.Enforce(dir, 100, DirQuotaPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(usage.Bytes).To(Equal(int64(40)))
+
+		writeFile("nested/other", 5, time.Minute)
+		Expect(os.Chtimes(dir, time.Now().Add(time.Second), time.Now().Add(time.Second))).To(Succeed())
+		usage, err = enforcer.Enforce(dir, 100, DirQuotaPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(usage.Bytes).To(Equal(int64(45)))
+	})
+
+	It("fails when the quota is exceeded by default", func() {
+		writeFile("file", 50, time.Minute)
+
+		usage, err := enforcer.Enforce(dir, 40, DirQuotaPolicy{Action: DirQuotaFail})
+		Expect(err).To(HaveOccurred())
+		Expect(IsDirQuotaExceededError(err)).To(BeTrue())
+		Expect(err).To(MatchError(DirQuotaExceededError{Path: dir, MaxBytes: 40, UsedBytes: 50}))
+		Expect(usage.Bytes).To(Equal(int64(50)))
+		Expect(filepath.Join(dir, "file")).To(BeAnExistingFile())
+	})
+
+	It("deletes the oldest files until the directory fits", func() {
+		oldest := writeFile("a/oldest", 30, 3*time.Hour)
+		older := writeFile("older", 30, 2*time.Hour)
+		kept := writeFile("kept", 30, 4*time.Hour)
+		newest := writeFile("newest", 30, time.Hour)
+
+		usage, err := enforcer.Enforce(dir, 70, DirQuotaPolicy{
+			Action: DirQuotaDeleteOldest,
+			Keep:   func(path string) bool { return path == kept },
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(usage.Bytes).To(Equal(int64(60)))
+		Expect(usage.Files).To(HaveLen(2))
+
+		Expect(oldest).ToNot(BeAnExistingFile())
+		Expect(older).ToNot(BeAnExistingFile())
+		Expect(kept).To(BeAnExistingFile())
+		Expect(newest).To(BeAnExistingFile())
+
+		usage, err = enforcer.Enforce(dir, 70, DirQuotaPolicy{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(usage.Bytes).To(Equal(int64(60)))
+	})
+
+	It("fails when kept files alone exceed the quota", func() {
+		writeFile("kept", 50, time.Hour)
+
+		_, err := enforcer.Enforce(dir, 40, DirQuotaPolicy{
+			Action: DirQuotaDeleteOldest,
+			Keep:   func(string) bool { return true },
+		})
+		Expect(IsDirQuotaExceededError(err)).To(BeTrue())
+	})
+
+	It("lets callbacks free space and measures again", func() {
+		path := writeFile("file", 50, time.Minute)
+
+		var exceeded DirUsage
+		usage, err := enforcer.Enforce(dir, 40, DirQuotaPolicy{
+			Action: DirQuotaCallback,
+			Callback: func(usage DirUsage) error {
+				exceeded = usage
+				return os.Truncate(path, 10)
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exceeded.Bytes).To(Equal(int64(50)))
+		Expect(usage.Bytes).To(Equal(int64(10)))
+	})
+
+	It("returns callback errors", func() {
+		writeFile("file", 50, time.Minute)
+
+		_, err := enforcer.Enforce(dir, 40, DirQuotaPolicy{
+			Action:   DirQuotaCallback,
+			Callback: func(DirUsage) error { return errors.New("fake-err") },
+		})
+		Expect(err).To(MatchError(ContainSubstring("fake-err")))
+	})
+
+	It("returns an error when the directory does not exist", func() {
+		_, err := EnforceDirQuota(fs, filepath.Join(dir, "missing"), 40, DirQuotaPolicy{})
+		Expect(err).To(MatchError(ContainSubstring("Measuring usage of")))
+	})
+})
@@ -0,0 +1,69 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiskSpace describes the filesystem containing a path. Inode counts are
+// zero on filesystems that do not have a fixed number of inodes.
+type DiskSpace struct {
+	TotalBytes      uint64
+	AvailableBytes  uint64
+	TotalInodes     uint64
+	AvailableInodes uint64
+}
+
+type TempDirWithSpaceOpts struct {
+	// RequiredInodes is only checked on filesystems that report inode counts
+	RequiredInodes uint64
+
+	// Candidates are checked after the configured temp root (or os.TempDir())
+	Candidates []string
+}
+
+type TempRootCandidate struct {
+	Path  string
+	Space DiskSpace
+	Err   error
+}
+
+// InsufficientSpaceError is returned when none of the candidate temp roots
+// has enough free space or inodes.
+type InsufficientSpaceError struct {
+	RequiredBytes  uint64
+	RequiredInodes uint64
+	Candidates     []TempRootCandidate
+}
+
+func (e InsufficientSpaceError) Error() string {
+	var details []string
+
+	for _, candidate := range e.Candidates {
+		switch {
+		case candidate.Err != nil:
+			details = append(details, fmt.Sprintf("'%s': %s", candidate.Path, candidate.Err))
+		case candidate.Space.AvailableBytes < e.RequiredBytes:
+			details = append(details, fmt.Sprintf("'%s' has %d bytes available (%d bytes short)",
+				candidate.Path, candidate.Space.AvailableBytes, e.RequiredBytes-candidate.Space.AvailableBytes))
+		default:
+			details = append(details, fmt.Sprintf("'%s' has %d inodes available (%d inodes short)",
+				candidate.Path, candidate.Space.AvailableInodes, e.RequiredInodes-candidate.Space.AvailableInodes))
+		}
+	}
+
+	required := fmt.Sprintf("%d bytes", e.RequiredBytes)
+	if e.RequiredInodes > 0 {
+		required += fmt.Sprintf(" and %d inodes", e.RequiredInodes)
+	}
+
+	return fmt.Sprintf("No temp root has %s available: %s", required, strings.Join(details, ", "))
+}
+
+func (s DiskSpace) Fits(requiredBytes, requiredInodes uint64) bool {
+	if s.AvailableBytes < requiredBytes {
+		return false
+	}
+
+	return s.TotalInodes == 0 || s.AvailableInodes >= requiredInodes
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package system
+
+import (
+	"errors"
+)
+
+func diskSpace(path string) (DiskSpace, error) {
+	return DiskSpace{}, errors.New("Disk space is not supported on this platform")
+}
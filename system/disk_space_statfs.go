@@ -0,0 +1,26 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package system
+
+import (
+	"syscall"
+)
+
+func diskSpace(path string) (DiskSpace, error) {
+	var stat syscall.Statfs_t
+
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return DiskSpace{}, err
+	}
+
+	blockSize := uint64(stat.Bsize)
+
+	return DiskSpace{
+		TotalBytes:      uint64(stat.Blocks) * blockSize,
+		AvailableBytes:  uint64(stat.Bavail) * blockSize,
+		TotalInodes:     uint64(stat.Files),
+		AvailableInodes: uint64(stat.Ffree),
+	}, nil
+}
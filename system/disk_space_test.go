@@ -0,0 +1,82 @@
+package system_test
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("TempDirWithSpace", func() {
+	var (
+		osFs     FileSystem
+		tempRoot string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempRoot, err = os.MkdirTemp("", "temp-dir-with-space")
+		Expect(err).ToNot(HaveOccurred())
+
+		osFs = createOsFs()
+		Expect(osFs.ChangeTempRoot(tempRoot)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempRoot)
+	})
+
+	It("reports the space of the filesystem containing a path", func() {
+		space, err := osFs.DiskSpace(tempRoot)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(space.TotalBytes).To(BeNumerically(">", 0))
+		Expect(space.AvailableBytes).To(BeNumerically("<=", space.TotalBytes))
+	})
+
+	It("creates the dir in the temp root when it has enough space", func() {
+		path, err := osFs.TempDirWithSpace("fake-prefix", 1024)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(filepath.Dir(path)).To(Equal(tempRoot))
+		Expect(filepath.Base(path)).To(HavePrefix("fake-prefix"))
+		Expect(path).To(BeADirectory())
+	})
+
+	It("returns an error naming the shortfall of every candidate", func() {
+		missingDir := filepath.Join(tempRoot, "does-not-exist")
+
+		_, err := osFs.TempDirWithSpace("fake-prefix", math.MaxUint64, TempDirWithSpaceOpts{Candidates: []string{missingDir}})
+		Expect(err).To(HaveOccurred())
+
+		var spaceErr InsufficientSpaceError
+		Expect(errors.As(err, &spaceErr)).To(BeTrue())
+		Expect(spaceErr.RequiredBytes).To(Equal(uint64(math.MaxUint64)))
+		Expect(spaceErr.Candidates).To(HaveLen(2))
+		Expect(spaceErr.Candidates[0].Path).To(Equal(tempRoot))
+		Expect(spaceErr.Candidates[0].Err).ToNot(HaveOccurred())
+		Expect(spaceErr.Candidates[1].Path).To(Equal(missingDir))
+		Expect(spaceErr.Candidates[1].Err).To(HaveOccurred())
+
+		Expect(err.Error()).To(ContainSubstring("'" + tempRoot + "' has "))
+		Expect(err.Error()).To(ContainSubstring("bytes short"))
+	})
+})
+
+var _ = Describe("DiskSpace", func() {
+	It("fits when bytes and inodes are available", func() {
+		space := DiskSpace{AvailableBytes: 100, TotalInodes: 10, AvailableInodes: 5}
+
+		Expect(space.Fits(100, 5)).To(BeTrue())
+		Expect(space.Fits(101, 0)).To(BeFalse())
+		Expect(space.Fits(0, 6)).To(BeFalse())
+	})
+
+	It("ignores inodes on filesystems that do not report them", func() {
+		Expect(DiskSpace{AvailableBytes: 100}.Fits(100, 1000)).To(BeTrue())
+	})
+})
@@ -0,0 +1,29 @@
+package system
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = kernel32DLL.NewProc("GetDiskFreeSpaceExW")
+
+func diskSpace(path string) (DiskSpace, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskSpace{}, err
+	}
+
+	var availableToCaller, total, totalFree uint64
+
+	r, _, err := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&availableToCaller)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if r == 0 {
+		return DiskSpace{}, err
+	}
+
+	return DiskSpace{TotalBytes: total, AvailableBytes: availableToCaller}, nil
+}
@@ -0,0 +1,152 @@
+package system
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// DryRunCmdRunner logs commands instead of running them and returns
+// canned results, e.g. to implement a --dry-run flag. Commands without a
+// canned result succeed without output.
+type DryRunCmdRunner struct {
+	results map[string]Result
+	lock    sync.Mutex
+
+	logger boshlog.Logger
+	logTag string
+}
+
+func NewDryRunCmdRunner(logger boshlog.Logger) *DryRunCmdRunner {
+	return &DryRunCmdRunner{
+		results: map[string]Result{},
+		logger:  logger,
+		logTag:  "DryRunCmdRunner",
+	}
+}
+
+// SetResult returns result for every run of the command given as name
+// followed by its arguments, separated by spaces
+func (r *DryRunCmdRunner) SetResult(cmd string, result Result) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.results[cmd] = result
+}
+
+func (r *DryRunCmdRunner) RunComplexCommand(cmd Command) (string, string, int, error) {
+	result := r.run(cmd)
+	return result.Stdout, result.Stderr, result.ExitStatus, result.Error
+}
+
+func (r *DryRunCmdRunner) RunComplexCommandAsync(cmd Command) (Process, error) {
+	return replayProcess{result: r.run(cmd)}, nil
+}
+
+// RunPipeline logs every stage as a separate command. Only the canned
+// stdout of the last stage is returned.
+func (r *DryRunCmdRunner) RunPipeline(cmds ...Command) ([]Result, error) {
+	results := make([]Result, len(cmds))
+
+	for i, cmd := range cmds {
+		results[i] = r.run(cmd)
+
+		if i < len(cmds)-1 {
+			results[i].Stdout = ""
+		}
+	}
+
+	return results, pipelineError(cmds, results)
+}
+
+func (r *DryRunCmdRunner) RunCommand(cmdName string, args ...string) (string, string, int, error) {
+	return r.RunComplexCommand(Command{Name: cmdName, Args: args})
+}
+
+func (r *DryRunCmdRunner) RunCommandQuietly(cmdName string, args ...string) (string, string, int, error) {
+	return r.RunComplexCommand(Command{Name: cmdName, Args: args, Quiet: true})
+}
+
+func (r *DryRunCmdRunner) RunCommandWithInput(input, cmdName string, args ...string) (string, string, int, error) {
+	return r.RunComplexCommand(Command{Name: cmdName, Args: args, Stdin: strings.NewReader(input)})
+}
+
+// CommandExists pretends every command exists
+func (r *DryRunCmdRunner) CommandExists(cmdName string) bool {
+	return true
+}
+
+// LookPath resolves every command to its name
+func (r *DryRunCmdRunner) LookPath(cmdName string, _ LookPathOpts) (CommandLookup, error) {
+	return CommandLookup{Name: cmdName, Path: cmdName}, nil
+}
+
+func (r *DryRunCmdRunner) run(cmd Command) Result {
+	cmdString := formatTranscriptCommand(cmd.Name, cmd.Args)
+
+	r.logger.Info(r.logTag, "Would run command '%s'%s", cmdString, formatDryRunDetails(cmd))
+
+	// do not leave writers feeding stdin blocked
+	if cmd.Stdin != nil {
+		io.Copy(io.Discard, cmd.Stdin)
+	}
+
+	r.lock.Lock()
+	result := r.results[cmdString]
+	r.lock.Unlock()
+
+	// like execProcess, output sent to custom writers is not part of the result
+	if cmd.Stdout != nil {
+		io.WriteString(cmd.Stdout, result.Stdout)
+		result.Stdout = ""
+	}
+
+	if cmd.Stderr != nil {
+		io.WriteString(cmd.Stderr, result.Stderr)
+		result.Stderr = ""
+	}
+
+	return result
+}
+
+func formatDryRunDetails(cmd Command) string {
+	var details []string
+
+	if cmd.WorkingDir != "" {
+		details = append(details, fmt.Sprintf("in '%s'", cmd.WorkingDir))
+	}
+
+	if len(cmd.Env) > 0 || cmd.UseIsolatedEnv {
+		names := make([]string, 0, len(cmd.Env))
+		for name := range cmd.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		env := make([]string, len(names))
+		for i, name := range names {
+			env[i] = name + "=" + cmd.Env[name]
+		}
+
+		kind := "env"
+		if cmd.UseIsolatedEnv {
+			kind = "isolated env"
+		}
+
+		details = append(details, fmt.Sprintf("with %s [%s]", kind, strings.Join(env, " ")))
+	}
+
+	if len(cmd.EnvFiles) > 0 {
+		details = append(details, fmt.Sprintf("with env files [%s]", strings.Join(cmd.EnvFiles, " ")))
+	}
+
+	if len(details) == 0 {
+		return ""
+	}
+
+	return " " + strings.Join(details, " ")
+}
@@ -0,0 +1,87 @@
+package system_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("DryRunCmdRunner", func() {
+	var (
+		logBuffer *bytes.Buffer
+		runner    *DryRunCmdRunner
+	)
+
+	BeforeEach(func() {
+		logBuffer = &bytes.Buffer{}
+		runner = NewDryRunCmdRunner(boshlog.NewWriterLogger(boshlog.LevelInfo, logBuffer))
+	})
+
+	It("logs the command, env and working dir that would run", func() {
+		_, _, _, err := runner.RunComplexCommand(Command{
+			Name:       "mkfs",
+			Args:       []string{"-t", "ext4", "/dev/sdb"},
+			Env:        map[string]string{"LC_ALL": "C", "B": "2"},
+			WorkingDir: "/var/vcap",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(logBuffer.String()).To(ContainSubstring("Would run command 'mkfs -t ext4 /dev/sdb' in '/var/vcap' with env [B=2 LC_ALL=C]"))
+	})
+
+	It("returns canned results", func() {
+		runner.SetResult("blkid /dev/sdb", Result{Stdout: "fake-uuid", ExitStatus: 2, Error: errors.New("fake-err")})
+
+		stdout, _, exitStatus, err := runner.RunCommand("blkid", "/dev/sdb")
+		Expect(err).To(MatchError("fake-err"))
+		Expect(stdout).To(Equal("fake-uuid"))
+		Expect(exitStatus).To(Equal(2))
+
+		stdout, _, exitStatus, err = runner.RunCommand("blkid", "/dev/sdc")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(BeEmpty())
+		Expect(exitStatus).To(Equal(0))
+	})
+
+	It("writes canned output to custom writers and consumes stdin", func() {
+		runner.SetResult("cat", Result{Stdout: "fake-stdout"})
+
+		stdin := strings.NewReader("fake-stdin")
+		stdout := &bytes.Buffer{}
+
+		process, err := runner.RunComplexCommandAsync(Command{Name: "cat", Stdin: stdin, Stdout: stdout})
+		Expect(err).ToNot(HaveOccurred())
+
+		result := <-process.Wait()
+		Expect(result.Stdout).To(BeEmpty())
+		Expect(stdout.String()).To(Equal("fake-stdout"))
+		Expect(stdin.Len()).To(Equal(0))
+	})
+
+	It("returns canned results for every pipeline stage", func() {
+		runner.SetResult("dpkg -l", Result{Stdout: "fake-packages"})
+		runner.SetResult("grep vim", Result{Stdout: "fake-vim"})
+
+		results, err := runner.RunPipeline(Command{Name: "dpkg", Args: []string{"-l"}}, Command{Name: "grep", Args: []string{"vim"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].Stdout).To(BeEmpty())
+		Expect(results[1].Stdout).To(Equal("fake-vim"))
+
+		Expect(logBuffer.String()).To(ContainSubstring("Would run command 'dpkg -l'"))
+		Expect(logBuffer.String()).To(ContainSubstring("Would run command 'grep vim'"))
+	})
+
+	It("pretends every command exists", func() {
+		Expect(runner.CommandExists("fake-cmd")).To(BeTrue())
+
+		lookup, err := runner.LookPath("fake-cmd", LookPathOpts{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lookup.Path).To(Equal("fake-cmd"))
+	})
+})
@@ -0,0 +1,233 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// EnvBuilder constructs the environment of a command from layers that are
+// applied in the order the methods are called, e.g.
+//
+//	env := NewEnvBuilder().
+//		WithOSEnv().
+//		Allow("HOME", "TMPDIR", "LC_*").
+//		SetAll(jobEnv).
+//		PrependPath("/var/vcap/bosh/bin").
+//		Locale("C.UTF-8").
+//		Build()
+//
+// Variable names are compared case-insensitively on Windows, where a
+// replaced variable takes the name it was last set with.
+type EnvBuilder struct {
+	vars       []envVar
+	index      map[string]int
+	ignoreCase bool
+}
+
+type envVar struct {
+	key   string
+	value string
+}
+
+func NewEnvBuilder() *EnvBuilder {
+	return &EnvBuilder{
+		index:      map[string]int{},
+		ignoreCase: runtime.GOOS == "windows",
+	}
+}
+
+// WithOSEnv adds the environment of the current process
+func (b *EnvBuilder) WithOSEnv() *EnvBuilder {
+	return b.WithEnv(os.Environ())
+}
+
+// WithEnv adds key=value pairs; entries without '=' are ignored.
+// Keys may start with '=' like the "=C:" working dir variables on Windows.
+func (b *EnvBuilder) WithEnv(env []string) *EnvBuilder {
+	for _, kv := range env {
+		if kv == "" {
+			continue
+		}
+		if n := strings.IndexByte(kv[1:], '='); n != -1 {
+			b.Set(kv[:n+1], kv[n+2:])
+		}
+	}
+	return b
+}
+
+// Allow removes every variable that does not match one of the patterns.
+// A pattern ending with '*' matches all names with that prefix.
+func (b *EnvBuilder) Allow(patterns ...string) *EnvBuilder {
+	var allowed []string
+	for _, v := range b.vars {
+		if b.matches(v.key, patterns) {
+			allowed = append(allowed, v.key)
+		}
+	}
+	return b.keepOnly(allowed)
+}
+
+// Set adds or replaces a variable
+func (b *EnvBuilder) Set(key, value string) *EnvBuilder {
+	if i, found := b.index[b.fold(key)]; found {
+		b.vars[i] = envVar{key: key, value: value}
+		return b
+	}
+
+	b.index[b.fold(key)] = len(b.vars)
+	b.vars = append(b.vars, envVar{key: key, value: value})
+	return b
+}
+
+// SetAll sets the variables in the order of their sorted names. When
+// several names only differ in case on Windows the first one wins.
+func (b *EnvBuilder) SetAll(env map[string]string) *EnvBuilder {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if !seen[b.fold(k)] {
+			b.Set(k, env[k])
+			seen[b.fold(k)] = true
+		}
+	}
+	return b
+}
+
+// Unset removes the variables if present
+func (b *EnvBuilder) Unset(keys ...string) *EnvBuilder {
+	removed := map[string]bool{}
+	for _, k := range keys {
+		removed[b.fold(k)] = true
+	}
+
+	var kept []string
+	for _, v := range b.vars {
+		if !removed[b.fold(v.key)] {
+			kept = append(kept, v.key)
+		}
+	}
+	return b.keepOnly(kept)
+}
+
+// Get returns the value of a variable
+func (b *EnvBuilder) Get(key string) (string, bool) {
+	if i, found := b.index[b.fold(key)]; found {
+		return b.vars[i].value, true
+	}
+	return "", false
+}
+
+// PrependPath puts dirs in front of PATH, moving them there if PATH
+// already contained them
+func (b *EnvBuilder) PrependPath(dirs ...string) *EnvBuilder {
+	return b.setPath(dirs, b.pathWithout(dirs))
+}
+
+// AppendPath puts dirs at the end of PATH, moving them there if PATH
+// already contained them
+func (b *EnvBuilder) AppendPath(dirs ...string) *EnvBuilder {
+	return b.setPath(b.pathWithout(dirs), dirs)
+}
+
+// Locale sets LANG and LC_ALL to locale and removes LANGUAGE and the other
+// LC_ variables so that the command output does not depend on the locale
+// settings of the machine
+func (b *EnvBuilder) Locale(locale string) *EnvBuilder {
+	var kept []string
+	for _, v := range b.vars {
+		name := b.fold(v.key)
+		if name != b.fold("LANGUAGE") && !strings.HasPrefix(name, b.fold("LC_")) {
+			kept = append(kept, v.key)
+		}
+	}
+	b.keepOnly(kept)
+
+	return b.Set("LANG", locale).Set("LC_ALL", locale)
+}
+
+// Build returns the variables as key=value pairs for exec.Cmd.Env
+func (b *EnvBuilder) Build() []string {
+	env := make([]string, 0, len(b.vars))
+	for _, v := range b.vars {
+		env = append(env, v.key+"="+v.value)
+	}
+	return env
+}
+
+func (b *EnvBuilder) pathWithout(dirs []string) []string {
+	current, _ := b.Get("PATH")
+
+	var kept []string
+	for _, dir := range filepath.SplitList(current) {
+		if !b.containsDir(dirs, dir) {
+			kept = append(kept, dir)
+		}
+	}
+	return kept
+}
+
+func (b *EnvBuilder) setPath(front, back []string) *EnvBuilder {
+	dirs := append(append([]string{}, front...), back...)
+
+	key := "PATH"
+	if i, found := b.index[b.fold(key)]; found {
+		key = b.vars[i].key
+	}
+
+	return b.Set(key, strings.Join(dirs, string(os.PathListSeparator)))
+}
+
+func (b *EnvBuilder) containsDir(dirs []string, dir string) bool {
+	for _, d := range dirs {
+		if b.fold(d) == b.fold(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *EnvBuilder) keepOnly(keys []string) *EnvBuilder {
+	vars := b.vars
+	b.vars = nil
+	b.index = map[string]int{}
+
+	kept := map[string]bool{}
+	for _, k := range keys {
+		kept[b.fold(k)] = true
+	}
+
+	for _, v := range vars {
+		if kept[b.fold(v.key)] {
+			b.Set(v.key, v.value)
+		}
+	}
+	return b
+}
+
+func (b *EnvBuilder) matches(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(b.fold(key), b.fold(strings.TrimSuffix(pattern, "*"))) {
+				return true
+			}
+		} else if b.fold(key) == b.fold(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *EnvBuilder) fold(s string) string {
+	if b.ignoreCase {
+		return strings.ToUpper(s)
+	}
+	return s
+}
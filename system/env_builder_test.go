@@ -0,0 +1,151 @@
+package system_test
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("EnvBuilder", func() {
+	var (
+		builder *EnvBuilder
+	)
+
+	BeforeEach(func() {
+		builder = NewEnvBuilder()
+	})
+
+	joinPath := func(dirs ...string) string {
+		return strings.Join(dirs, string(os.PathListSeparator))
+	}
+
+	It("builds an empty environment by default", func() {
+		Expect(builder.Build()).To(BeEmpty())
+	})
+
+	It("includes the environment of the current process", func() {
+		os.Setenv("_ENV_BUILDER_TEST", "value")
+		defer os.Unsetenv("_ENV_BUILDER_TEST")
+
+		Expect(builder.WithOSEnv().Build()).To(ContainElement("_ENV_BUILDER_TEST=value"))
+	})
+
+	It("lets later layers replace earlier values in place", func() {
+		env := builder.
+			WithEnv([]string{"A=1", "B=2", "invalid", "C=x=y"}).
+			Set("A", "3").
+			SetAll(map[string]string{"D": "4"}).
+			Build()
+
+		Expect(env).To(Equal([]string{"A=3", "B=2", "C=x=y", "D=4"}))
+	})
+
+	It("keeps variables whose names start with '='", func() {
+		env := builder.WithEnv([]string{`=C:=C:\work`, "=ExitCode=00000000", "=", "A=1"}).Build()
+
+		Expect(env).To(Equal([]string{`=C:=C:\work`, "=ExitCode=00000000", "A=1"}))
+	})
+
+	It("removes variables", func() {
+		env := builder.WithEnv([]string{"A=1", "B=2", "C=3"}).Unset("A", "C", "missing").Build()
+
+		Expect(env).To(Equal([]string{"B=2"}))
+	})
+
+	It("keeps only the allowed variables", func() {
+		env := builder.
+			WithEnv([]string{"HOME=/home", "SECRET=s", "LC_TIME=C", "LC_NUMERIC=C"}).
+			Allow("HOME", "LC_*").
+			Build()
+
+		Expect(env).To(Equal([]string{"HOME=/home", "LC_TIME=C", "LC_NUMERIC=C"}))
+	})
+
+	It("returns the current value of a variable", func() {
+		builder.Set("A", "1")
+
+		value, found := builder.Get("A")
+		Expect(found).To(BeTrue())
+		Expect(value).To(Equal("1"))
+
+		_, found = builder.Get("B")
+		Expect(found).To(BeFalse())
+	})
+
+	Describe("PATH", func() {
+		It("prepends and appends directories", func() {
+			builder.Set("PATH", joinPath("/usr/bin", "/bin"))
+
+			builder.PrependPath("/a", "/b").AppendPath("/c")
+
+			value, _ := builder.Get("PATH")
+			Expect(value).To(Equal(joinPath("/a", "/b", "/usr/bin", "/bin", "/c")))
+		})
+
+		It("moves directories that are already present instead of duplicating them", func() {
+			builder.Set("PATH", joinPath("/usr/bin", "/a", "/bin"))
+
+			builder.PrependPath("/a").AppendPath("/usr/bin")
+
+			value, _ := builder.Get("PATH")
+			Expect(value).To(Equal(joinPath("/a", "/bin", "/usr/bin")))
+		})
+
+		It("sets PATH when it was not set", func() {
+			Expect(builder.AppendPath("/a").Build()).To(Equal([]string{"PATH=/a"}))
+		})
+
+		It("keeps the name of the existing variable on Windows", func() {
+			if !Windows {
+				Skip("Windows only test")
+			}
+
+			env := builder.Set("Path", `C:\Windows`).PrependPath(`C:\bosh`).Build()
+			Expect(env).To(Equal([]string{`Path=C:\bosh;C:\Windows`}))
+		})
+	})
+
+	Describe("Locale", func() {
+		It("sets LANG and LC_ALL and removes the other locale variables", func() {
+			env := builder.
+				WithEnv([]string{"LANG=de_DE.UTF-8", "LANGUAGE=de", "LC_TIME=de_DE", "HOME=/home"}).
+				Locale("C.UTF-8").
+				Build()
+
+			Expect(env).To(Equal([]string{"LANG=C.UTF-8", "HOME=/home", "LC_ALL=C.UTF-8"}))
+		})
+	})
+
+	Describe("variable names", func() {
+		It("are case-sensitive on *Nix", func() {
+			if Windows {
+				Skip("*Nix only test")
+			}
+
+			env := builder.WithEnv([]string{"FOO=1"}).Set("foo", "2").Build()
+			Expect(env).To(Equal([]string{"FOO=1", "foo=2"}))
+		})
+
+		It("are case-insensitive on Windows", func() {
+			if !Windows {
+				Skip("Windows only test")
+			}
+
+			env := builder.WithEnv([]string{"FOO=1"}).Set("foo", "2").Build()
+			Expect(env).To(Equal([]string{"foo=2"}))
+		})
+
+		It("use the first sorted duplicate of SetAll on Windows", func() {
+			if !Windows {
+				Skip("Windows only test")
+			}
+
+			env := builder.SetAll(map[string]string{"_bar": "second", "_BAR": "first"}).Build()
+			Expect(env).To(Equal([]string{"_BAR=first"}))
+		})
+	})
+})
@@ -0,0 +1,224 @@
+package system
+
+import (
+	"os"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ReadEnvFile reads KEY=VALUE pairs from a dotenv style file:
+//
+//	# comments and blank lines are ignored
+//	export TOKEN=abc # "export " and trailing comments are optional
+//	PASSWORD='literal $value'
+//	CERT="-----BEGIN CERTIFICATE-----\n..."
+//
+// Single quoted values are taken as is. Double quoted values support the
+// escapes \n, \r, \t, \", \\ and \$. Both may span several lines.
+// Variables are not expanded. Later pairs replace earlier ones.
+func ReadEnvFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading env file '%s'", path)
+	}
+
+	env, err := parseEnvFile(string(content))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing env file '%s'", path)
+	}
+
+	return env, nil
+}
+
+// withEnvFiles returns cmd with the variables of its EnvFiles added to Env
+func withEnvFiles(cmd Command) (Command, error) {
+	if len(cmd.EnvFiles) == 0 {
+		return cmd, nil
+	}
+
+	env := map[string]string{}
+
+	for _, path := range cmd.EnvFiles {
+		fileEnv, err := ReadEnvFile(path)
+		if err != nil {
+			return cmd, err
+		}
+
+		for k, v := range fileEnv {
+			env[k] = v
+		}
+	}
+
+	// Env takes precedence and the map of the caller is left untouched
+	for k, v := range cmd.Env {
+		env[k] = v
+	}
+
+	cmd.Env = env
+	cmd.EnvFiles = nil
+
+	return cmd, nil
+}
+
+type envFileParser struct {
+	content string
+	pos     int
+	line    int
+}
+
+func parseEnvFile(content string) (map[string]string, error) {
+	p := &envFileParser{content: strings.ReplaceAll(content, "\r\n", "\n"), line: 1}
+	env := map[string]string{}
+
+	for !p.done() {
+		p.skipSpaces()
+
+		switch {
+		case p.done():
+		case p.peek() == '\n':
+			p.next()
+		case p.peek() == '#':
+			p.skipLine()
+		default:
+			line := p.line
+
+			key, value, err := p.pair()
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Line %d", line)
+			}
+
+			env[key] = value
+		}
+	}
+
+	return env, nil
+}
+
+func (p *envFileParser) pair() (string, string, error) {
+	if strings.HasPrefix(p.content[p.pos:], "export ") {
+		p.pos += len("export ")
+		p.skipSpaces()
+	}
+
+	start := p.pos
+	for !p.done() && p.peek() != '=' && p.peek() != '\n' {
+		p.next()
+	}
+
+	key := strings.TrimRight(p.content[start:p.pos], " \t")
+	if p.done() || p.peek() != '=' {
+		return "", "", bosherr.Errorf("Expected '=' after '%s'", key)
+	}
+
+	if key == "" || strings.ContainsAny(key, " \t") {
+		return "", "", bosherr.Errorf("Invalid variable name '%s'", key)
+	}
+
+	p.next()
+	p.skipSpaces()
+
+	var value string
+	var err error
+
+	switch {
+	case p.done():
+	case p.peek() == '\'':
+		value, err = p.quoted('\'')
+	case p.peek() == '"':
+		value, err = p.quoted('"')
+	default:
+		return key, p.unquoted(), nil
+	}
+
+	if err != nil {
+		return "", "", bosherr.WrapErrorf(err, "Value of '%s'", key)
+	}
+
+	// only a comment may follow the closing quote
+	p.skipSpaces()
+	if !p.done() && p.peek() != '\n' && p.peek() != '#' {
+		return "", "", bosherr.Errorf("Unexpected characters after the value of '%s'", key)
+	}
+	p.skipLine()
+
+	return key, value, nil
+}
+
+// unquoted reads until the end of the line or a comment starting after a space
+func (p *envFileParser) unquoted() string {
+	start := p.pos
+	for !p.done() && p.peek() != '\n' {
+		if p.peek() == '#' && p.pos > start && strings.ContainsRune(" \t", rune(p.content[p.pos-1])) {
+			break
+		}
+		p.next()
+	}
+
+	value := strings.TrimRight(p.content[start:p.pos], " \t")
+	p.skipLine()
+
+	return value
+}
+
+func (p *envFileParser) quoted(quote byte) (string, error) {
+	line := p.line
+	p.next()
+
+	var value strings.Builder
+	for !p.done() {
+		c := p.next()
+
+		switch {
+		case c == quote:
+			return value.String(), nil
+		case c == '\\' && quote == '"' && !p.done():
+			escaped := p.next()
+			switch escaped {
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case '"', '\\', '$':
+				value.WriteByte(escaped)
+			default:
+				value.WriteByte('\\')
+				value.WriteByte(escaped)
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+
+	return "", bosherr.Errorf("Missing closing %c of the quote opened on line %d", quote, line)
+}
+
+func (p *envFileParser) done() bool {
+	return p.pos >= len(p.content)
+}
+
+func (p *envFileParser) peek() byte {
+	return p.content[p.pos]
+}
+
+func (p *envFileParser) next() byte {
+	c := p.content[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *envFileParser) skipSpaces() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.next()
+	}
+}
+
+func (p *envFileParser) skipLine() {
+	for !p.done() && p.next() != '\n' {
+	}
+}
@@ -0,0 +1,92 @@
+package system_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("ReadEnvFile", func() {
+	readEnv := func(content string) (map[string]string, error) {
+		path := filepath.Join(GinkgoT().TempDir(), "test.env")
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return ReadEnvFile(path)
+	}
+
+	It("reads pairs and ignores comments and blank lines", func() {
+		env, err := readEnv(`
+# a comment
+  FOO=bar
+export EXPORTED=yes
+
+EMPTY=
+SPACED = padded value  # trailing comment
+HASH=a#b
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(env).To(Equal(map[string]string{
+			"FOO":      "bar",
+			"EXPORTED": "yes",
+			"EMPTY":    "",
+			"SPACED":   "padded value",
+			"HASH":     "a#b",
+		}))
+	})
+
+	It("takes single quoted values as is", func() {
+		env, err := readEnv("PASSWORD='p@ss $word \\n # not a comment'\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(env).To(HaveKeyWithValue("PASSWORD", `p@ss $word \n # not a comment`))
+	})
+
+	It("replaces escapes in double quoted values", func() {
+		env, err := readEnv(`MSG="say \"hi\"\n\ttab \\ \$HOME" # comment` + "\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(env).To(HaveKeyWithValue("MSG", "say \"hi\"\n\ttab \\ $HOME"))
+	})
+
+	It("reads quoted values spanning several lines", func() {
+		env, err := readEnv("CERT=\"-----BEGIN-----\r\nabc\r\n-----END-----\"\r\nNEXT=1\r\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(env).To(Equal(map[string]string{
+			"CERT": "-----BEGIN-----\nabc\n-----END-----",
+			"NEXT": "1",
+		}))
+	})
+
+	It("lets later pairs replace earlier ones", func() {
+		env, err := readEnv("FOO=first\nFOO=second")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(env).To(HaveKeyWithValue("FOO", "second"))
+	})
+
+	It("reports the line of invalid pairs", func() {
+		_, err := readEnv("FOO=bar\n\nnot a pair\n")
+		Expect(err).To(MatchError(ContainSubstring("Line 3")))
+		Expect(err).To(MatchError(ContainSubstring("Expected '='")))
+	})
+
+	It("fails for invalid names", func() {
+		_, err := readEnv("MY VAR=1\n")
+		Expect(err).To(MatchError(ContainSubstring("Invalid variable name 'MY VAR'")))
+	})
+
+	It("fails for unterminated quotes", func() {
+		_, err := readEnv("FOO=bar\nSECRET=\"abc\n")
+		Expect(err).To(MatchError(ContainSubstring("Missing closing \" of the quote opened on line 2")))
+	})
+
+	It("fails for characters after quoted values", func() {
+		_, err := readEnv("FOO='bar'baz\n")
+		Expect(err).To(MatchError(ContainSubstring("Unexpected characters after the value of 'FOO'")))
+	})
+
+	It("fails for missing files", func() {
+		_, err := ReadEnvFile(filepath.Join(GinkgoT().TempDir(), "missing.env"))
+		Expect(err).To(MatchError(ContainSubstring("Reading env file")))
+	})
+})
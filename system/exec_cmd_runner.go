@@ -1,26 +1,42 @@
 package system
 
 import (
-	"os"
+	"io"
 	"os/exec"
 	"runtime"
 	"strings"
 
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+type ExecCmdRunnerOpts struct {
+	// MaxOutputBytes is used for commands that do not set
+	// Command.MaxOutputBytes. 0 captures the whole output.
+	MaxOutputBytes int64
+}
+
 type execCmdRunner struct {
 	logger boshlog.Logger
+	opts   ExecCmdRunnerOpts
+	hooks  *cmdHooks
 }
 
+// NewExecCmdRunner returns a runner that also implements CmdHookAdder
 func NewExecCmdRunner(logger boshlog.Logger) CmdRunner {
-	return execCmdRunner{logger}
+	return NewExecCmdRunnerWithOpts(logger, ExecCmdRunnerOpts{})
 }
 
-func (r execCmdRunner) RunComplexCommand(cmd Command) (string, string, int, error) {
-	process := NewExecProcess(r.buildComplexCommand(cmd), cmd.KeepAttached, cmd.Quiet, r.logger)
+func NewExecCmdRunnerWithOpts(logger boshlog.Logger, opts ExecCmdRunnerOpts) CmdRunner {
+	return execCmdRunner{logger: logger, opts: opts, hooks: &cmdHooks{}}
+}
+
+func (r execCmdRunner) AddHook(hook CmdHook) {
+	r.hooks.add(hook)
+}
 
-	err := process.Start()
+func (r execCmdRunner) RunComplexCommand(cmd Command) (string, string, int, error) {
+	process, err := r.start(cmd)
 	if err != nil {
 		return "", "", -1, err
 	}
@@ -31,9 +47,28 @@ func (r execCmdRunner) RunComplexCommand(cmd Command) (string, string, int, erro
 }
 
 func (r execCmdRunner) RunComplexCommandAsync(cmd Command) (Process, error) {
-	process := NewExecProcess(r.buildComplexCommand(cmd), cmd.KeepAttached, cmd.Quiet, r.logger)
+	return r.start(cmd)
+}
 
-	err := process.Start()
+// start runs cmd through the hooks
+func (r execCmdRunner) start(cmd Command) (Process, error) {
+	return r.hooks.wrap(r.startProcess)(cmd)
+}
+
+func (r execCmdRunner) startProcess(cmd Command) (Process, error) {
+	err := waitForPreconditions(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err = withEnvFiles(cmd)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Loading env files of '%s'", cmd.Name)
+	}
+
+	process := r.newProcess(cmd)
+
+	err = process.Start()
 	if err != nil {
 		return nil, err
 	}
@@ -59,10 +94,76 @@ func (r execCmdRunner) RunCommandWithInput(input, cmdName string, args ...string
 }
 
 func (r execCmdRunner) CommandExists(cmdName string) bool {
-	_, err := exec.LookPath(cmdName)
+	_, err := r.LookPath(cmdName, LookPathOpts{})
 	return err == nil
 }
 
+func (r execCmdRunner) LookPath(cmdName string, opts LookPathOpts) (CommandLookup, error) {
+	return lookPath(cmdName, opts)
+}
+
+func (r execCmdRunner) newProcess(cmd Command) *execProcess {
+	process := NewExecProcess(r.buildComplexCommand(cmd), cmd.KeepAttached, cmd.Quiet, r.logger)
+	process.affinity = cpuAffinity{cpuSet: cmd.CPUSet, numaNode: cmd.NUMANode}
+	process.limits = cmd.Limits
+	process.priority = cmd.Priority
+	process.timeout = cmd.Timeout
+	process.termination = cmd.Termination
+
+	maxOutputBytes := cmd.MaxOutputBytes
+	if maxOutputBytes == 0 {
+		maxOutputBytes = r.opts.MaxOutputBytes
+	}
+	process.stdoutWriter.max = maxOutputBytes
+	process.stderrWriter.max = maxOutputBytes
+
+	var stdoutTees, stderrTees []io.Writer
+
+	if cmd.LogOutput != nil {
+		stdoutLogger, stderrLogger := newLogLineWriters(*cmd.LogOutput, cmd.Name)
+
+		stdoutTees = append(stdoutTees, stdoutLogger)
+		stderrTees = append(stderrTees, stderrLogger)
+		process.lineWriters = append(process.lineWriters, stdoutLogger, stderrLogger)
+	}
+
+	if cmd.StdoutLineFunc != nil {
+		stdoutLines := newLineFuncWriter(cmd.StdoutLineFunc)
+
+		stdoutTees = append(stdoutTees, stdoutLines)
+		process.lineWriters = append(process.lineWriters, stdoutLines)
+	}
+
+	if cmd.StderrLineFunc != nil {
+		stderrLines := newLineFuncWriter(cmd.StderrLineFunc)
+
+		stderrTees = append(stderrTees, stderrLines)
+		process.lineWriters = append(process.lineWriters, stderrLines)
+	}
+
+	if cmd.InactivityTimeout > 0 {
+		process.watchdog = newInactivityWatchdog(cmd.InactivityTimeout)
+
+		stdoutTees = append(stdoutTees, process.watchdog)
+		stderrTees = append(stderrTees, process.watchdog)
+	}
+
+	if len(stdoutTees) > 0 || len(stderrTees) > 0 {
+		stdout, stderr := process.cmd.Stdout, process.cmd.Stderr
+		if stdout == nil {
+			stdout = process.stdoutWriter
+		}
+		if stderr == nil {
+			stderr = process.stderrWriter
+		}
+
+		process.cmd.Stdout = io.MultiWriter(append([]io.Writer{stdout}, stdoutTees...)...)
+		process.cmd.Stderr = io.MultiWriter(append([]io.Writer{stderr}, stderrTees...)...)
+	}
+
+	return process
+}
+
 func (r execCmdRunner) buildComplexCommand(cmd Command) *exec.Cmd {
 	execCmd := newExecCmd(cmd.Name, cmd.Args...)
 
@@ -80,15 +181,23 @@ func (r execCmdRunner) buildComplexCommand(cmd Command) *exec.Cmd {
 
 	execCmd.Dir = cmd.WorkingDir
 
-	var env []string
-	if !cmd.UseIsolatedEnv {
-		env = os.Environ()
-	}
+	setWindowsConsole(execCmd, cmd.WindowsConsole)
+
 	if cmd.UseIsolatedEnv && runtime.GOOS == "windows" {
 		panic("UseIsolatedEnv is not supported on Windows")
 	}
 
-	execCmd.Env = mergeEnv(env, cmd.Env)
+	execCmd.Env = commandEnv(cmd)
 
 	return execCmd
 }
+
+// commandEnv starts from the environment of the current process unless
+// UseIsolatedEnv is set, with Command.Env taking precedence
+func commandEnv(cmd Command) []string {
+	builder := NewEnvBuilder()
+	if !cmd.UseIsolatedEnv {
+		builder.WithOSEnv()
+	}
+	return builder.SetAll(cmd.Env).Build()
+}
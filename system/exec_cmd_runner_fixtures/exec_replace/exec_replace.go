@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// Usage: [EXEC_REPLACE_WORKING_DIR=<dir>] exec_replace <preserved-file> <command> [args...]
+func main() {
+	preserved, err := os.Create(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("pid: %d\n", os.Getpid())
+
+	err = boshsys.ExecReplace(
+		boshsys.Command{
+			Name: os.Args[2],
+			Args: os.Args[3:],
+			Env:  map[string]string{"PRESERVED_FD": strconv.Itoa(int(preserved.Fd()))},
+
+			WorkingDir: os.Getenv("EXEC_REPLACE_WORKING_DIR"),
+		},
+		boshsys.ExecReplaceOpts{
+			Logger:        boshlog.NewLogger(boshlog.LevelNone),
+			PreserveFiles: []*os.File{preserved},
+		},
+	)
+
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(3)
+}
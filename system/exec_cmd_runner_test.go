@@ -3,8 +3,10 @@ package system_test
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -137,6 +139,55 @@ var _ = Describe("execCmdRunner", func() {
 			Expect(status).To(Equal(0))
 		})
 
+		It("lets commands inherit the windows console by default", func() {
+			Expect(Command{}.WindowsConsole).To(Equal(WindowsConsoleInherit))
+		})
+
+		It("captures output of complex commands with any windows console", func() {
+			for _, mode := range []WindowsConsoleMode{WindowsConsoleAuto, WindowsConsoleInherit, WindowsConsoleHidden, WindowsConsoleDetached} {
+				cmd := GetPlatformCommand("env")
+				cmd.WindowsConsole = mode
+				stdout, _, status, err := runner.RunComplexCommand(cmd)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(parseEnvFields(stdout, true)).To(HaveKeyWithValue("FOO", "BAR"), string(mode))
+				Expect(status).To(Equal(0))
+
+				cmd = GetPlatformCommand("stderr")
+				cmd.WindowsConsole = mode
+				_, stderr, _, err := runner.RunComplexCommand(cmd)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stderr).To(ContainSubstring("error-output"), string(mode))
+			}
+		})
+
+		It("runs complex command with env files", func() {
+			dir := GinkgoT().TempDir()
+			first := filepath.Join(dir, "first.env")
+			second := filepath.Join(dir, "second.env")
+			Expect(os.WriteFile(first, []byte("FROM_FILE=first\nOVERRIDDEN=first\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(second, []byte("OVERRIDDEN=second\nFOO=from-file\n"), 0600)).To(Succeed())
+
+			cmd := GetPlatformCommand("env")
+			cmd.EnvFiles = []string{first, second}
+			stdout, _, status, err := runner.RunComplexCommand(cmd)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).To(Equal(0))
+
+			envVars := parseEnvFields(stdout, true)
+			Expect(envVars).To(HaveKeyWithValue("FROM_FILE", "first"))
+			Expect(envVars).To(HaveKeyWithValue("OVERRIDDEN", "second"))
+			Expect(envVars).To(HaveKeyWithValue("FOO", "BAR"))
+			Expect(cmd.Env).To(Equal(map[string]string{"FOO": "BAR"}))
+		})
+
+		It("does not start commands whose env files can not be read", func() {
+			cmd := GetPlatformCommand("env")
+			cmd.EnvFiles = []string{filepath.Join(GinkgoT().TempDir(), "missing.env")}
+
+			_, _, _, err := runner.RunComplexCommand(cmd)
+			Expect(err).To(MatchError(ContainSubstring("Loading env files")))
+		})
+
 		It("runs complex command with specific env", func() {
 			cmd := GetPlatformCommand("env")
 			cmd.UseIsolatedEnv = true
@@ -280,6 +331,152 @@ var _ = Describe("execCmdRunner", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(string(stderrContents)).To(ContainSubstring("fake-err"))
 		})
+
+		Context("with LogOutput", func() {
+			var logger *loggerfakes.FakeLogger
+
+			BeforeEach(func() {
+				logger = &loggerfakes.FakeLogger{}
+			})
+
+			It("logs stdout lines as info and stderr lines as warnings under the command tag", func() {
+				cmd := Command{
+					Name:      CatExePath,
+					Args:      []string{"-stdout", "fake-out", "-stderr", "fake-err"},
+					LogOutput: &LogOutput{Logger: logger, Tag: "fake-tag"},
+				}
+
+				stdout, stderr, _, err := runner.RunComplexCommand(cmd)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stdout).To(ContainSubstring("fake-out"))
+				Expect(stderr).To(ContainSubstring("fake-err"))
+
+				Expect(logger.InfoCallCount()).To(Equal(1))
+				tag, msg, args := logger.InfoArgsForCall(0)
+				Expect(tag).To(Equal("fake-tag"))
+				Expect(fmt.Sprintf(msg, args...)).To(Equal("fake-out"))
+
+				Expect(logger.WarnCallCount()).To(Equal(1))
+				tag, msg, args = logger.WarnArgsForCall(0)
+				Expect(tag).To(Equal("fake-tag"))
+				Expect(fmt.Sprintf(msg, args...)).To(Equal("fake-err"))
+			})
+
+			It("logs every line including an unterminated last line with configured levels", func() {
+				level := boshlog.LevelDebug
+				cmd := Command{
+					Name:      CatExePath,
+					Stdin:     strings.NewReader("line 1\nline 2\n100%"),
+					LogOutput: &LogOutput{Logger: logger, StdoutLevel: &level},
+				}
+
+				_, _, _, err := runner.RunComplexCommand(cmd)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(logger.InfoCallCount()).To(Equal(0))
+
+				var lines []string
+				for i := 0; i < logger.DebugCallCount(); i++ {
+					tag, msg, args := logger.DebugArgsForCall(i)
+					if tag == filepath.Base(CatExePath) {
+						lines = append(lines, fmt.Sprintf(msg, args...))
+					}
+				}
+				Expect(lines).To(Equal([]string{"line 1", "line 2", "100%"}))
+			})
+		})
+
+		Context("with StdoutLineFunc and StderrLineFunc", func() {
+			It("passes every line including an unterminated last line", func() {
+				var lines []string
+
+				stdout, _, _, err := runner.RunComplexCommand(Command{
+					Name:           CatExePath,
+					Stdin:          strings.NewReader("line 1\r\nline 2\n100%"),
+					StdoutLineFunc: func(line string) { lines = append(lines, line) },
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stdout).To(Equal("line 1\r\nline 2\n100%"))
+
+				Expect(lines).To(Equal([]string{"line 1", "line 2", "100%"}))
+			})
+
+			It("passes stdout and stderr lines to their own func", func() {
+				var stdoutLines, stderrLines []string
+
+				_, stderr, _, err := runner.RunComplexCommand(Command{
+					Name:           CatExePath,
+					Args:           []string{"-stdout", "fake-out", "-stderr", "fake-err"},
+					StdoutLineFunc: func(line string) { stdoutLines = append(stdoutLines, line) },
+					StderrLineFunc: func(line string) { stderrLines = append(stderrLines, line) },
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stderr).To(ContainSubstring("fake-err"))
+
+				Expect(stdoutLines).To(Equal([]string{"fake-out"}))
+				Expect(stderrLines).To(Equal([]string{"fake-err"}))
+			})
+		})
+
+		Context("with MaxOutputBytes", func() {
+			input := strings.Repeat("0123456789", 10000)
+
+			It("captures only the first bytes and reports the truncation", func() {
+				process, err := runner.RunComplexCommandAsync(Command{
+					Name:           CatExePath,
+					Stdin:          strings.NewReader(input),
+					MaxOutputBytes: 15,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				result := <-process.Wait()
+				Expect(result.Error).ToNot(HaveOccurred())
+				Expect(result.Stdout).To(Equal("012345678901234"))
+				Expect(result.StdoutTruncated).To(BeTrue())
+				Expect(result.StderrTruncated).To(BeFalse())
+			})
+
+			It("still passes the whole output to line funcs", func() {
+				var lines []string
+
+				stdout, _, _, err := runner.RunComplexCommand(Command{
+					Name:           CatExePath,
+					Stdin:          strings.NewReader(input),
+					MaxOutputBytes: 15,
+					StdoutLineFunc: func(line string) { lines = append(lines, line) },
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stdout).To(HaveLen(15))
+				Expect(lines).To(Equal([]string{input}))
+			})
+
+			It("uses the limit of the runner by default", func() {
+				runner = NewExecCmdRunnerWithOpts(boshlog.NewLogger(boshlog.LevelNone), ExecCmdRunnerOpts{MaxOutputBytes: 5})
+
+				process, err := runner.RunComplexCommandAsync(Command{
+					Name:  CatExePath,
+					Stdin: strings.NewReader(input),
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				result := <-process.Wait()
+				Expect(result.Stdout).To(Equal("01234"))
+				Expect(result.StdoutTruncated).To(BeTrue())
+			})
+
+			It("does not truncate output that fits", func() {
+				process, err := runner.RunComplexCommandAsync(Command{
+					Name:           CatExePath,
+					Stdin:          strings.NewReader("short"),
+					MaxOutputBytes: 5,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				result := <-process.Wait()
+				Expect(result.Stdout).To(Equal("short"))
+				Expect(result.StdoutTruncated).To(BeFalse())
+			})
+		})
 	})
 
 	Describe("RunComplexCommandAsync", func() {
@@ -307,6 +504,25 @@ var _ = Describe("execCmdRunner", func() {
 			Expect(result.Stderr).To(Equal("STDERR\n"))
 		})
 
+		It("reports the PID and when the command ran", func() {
+			started := time.Now()
+
+			process, err := runner.RunComplexCommandAsync(Command{
+				Name:  CatExePath,
+				Stdin: strings.NewReader("input"),
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			result := <-process.Wait()
+			Expect(result.Error).ToNot(HaveOccurred())
+			Expect(result.PID).To(BeNumerically(">", 0))
+			Expect(result.StartedAt).To(BeTemporally(">=", started))
+			Expect(result.FinishedAt).To(BeTemporally(">=", result.StartedAt))
+			Expect(result.FinishedAt).To(BeTemporally("<=", time.Now()))
+			Expect(result.Duration).To(Equal(result.FinishedAt.Sub(result.StartedAt)))
+			Expect(result.Signal).To(BeZero())
+		})
+
 		It("returns error and sets status to exit status of command if it exits with non-0 status", func() {
 			cmd := GetPlatformCommand("exit")
 			process, err := runner.RunComplexCommandAsync(cmd)
@@ -435,4 +651,68 @@ var _ = Describe("execCmdRunner", func() {
 			Expect(runner.CommandExists("absolutely-does-not-exist-ever-please-unicorns")).To(BeFalse())
 		})
 	})
+
+	Describe("LookPath", func() {
+		var binDir string
+
+		BeforeEach(func() {
+			if runtime.GOOS == "windows" {
+				Skip("Uses unix executables")
+			}
+
+			binDir = GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(binDir, "fake-tool"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+		})
+
+		It("returns the absolute path and the searched PATH entries", func() {
+			GinkgoT().Setenv("PATH", "/nonexistent"+string(os.PathListSeparator)+binDir)
+
+			lookup, err := runner.LookPath("fake-tool", LookPathOpts{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lookup).To(Equal(CommandLookup{
+				Name:          "fake-tool",
+				Path:          filepath.Join(binDir, "fake-tool"),
+				SearchedPaths: []string{"/nonexistent", binDir},
+			}))
+		})
+
+		It("returns a CommandNotFoundError listing the searched PATH entries", func() {
+			GinkgoT().Setenv("PATH", "/nonexistent"+string(os.PathListSeparator)+binDir)
+
+			_, err := runner.LookPath("absolutely-does-not-exist-ever-please-unicorns", LookPathOpts{})
+			Expect(err).To(MatchError("Command 'absolutely-does-not-exist-ever-please-unicorns' not found in PATH (searched /nonexistent, " + binDir + ")"))
+			Expect(IsCommandNotFoundError(err)).To(BeTrue())
+		})
+
+		Context("when the command resolves relative to the working directory", func() {
+			BeforeEach(func() {
+				wd, err := os.Getwd()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(os.Chdir(binDir)).To(Succeed())
+				DeferCleanup(os.Chdir, wd)
+
+				GinkgoT().Setenv("PATH", ".")
+			})
+
+			It("reports that the match came from the working directory", func() {
+				lookup, err := runner.LookPath("fake-tool", LookPathOpts{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lookup.FromWorkingDir).To(BeTrue())
+				Expect(filepath.IsAbs(lookup.Path)).To(BeTrue())
+				Expect(filepath.Base(lookup.Path)).To(Equal("fake-tool"))
+			})
+
+			It("rejects the match in strict mode", func() {
+				_, err := runner.LookPath("fake-tool", LookPathOpts{Strict: true})
+				Expect(err).To(MatchError(ContainSubstring("relative to the working directory")))
+			})
+		})
+
+		It("does not search PATH for commands with a path", func() {
+			lookup, err := runner.LookPath(filepath.Join(binDir, "fake-tool"), LookPathOpts{Strict: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lookup.SearchedPaths).To(BeEmpty())
+			Expect(lookup.FromWorkingDir).To(BeFalse())
+		})
+	})
 })
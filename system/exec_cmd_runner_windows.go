@@ -2,47 +2,53 @@ package system
 
 import (
 	"os/exec"
-	"sort"
-	"strings"
+	"syscall"
 )
 
+const (
+	createNoWindow  = 0x08000000
+	detachedProcess = 0x00000008
+)
+
+var procGetConsoleWindow = kernel32DLL.NewProc("GetConsoleWindow")
+
 func newExecCmd(name string, args ...string) *exec.Cmd {
 	return exec.Command(name, args...)
 }
 
-// mergeEnv case-insensitive merge of system and command environments variables.
-// Command variables override any system variable with the same key.
-func mergeEnv(sysEnv []string, cmdEnv map[string]string) []string {
+// setWindowsConsole keeps console programs started by a service from
+// attaching to a console of their own on an invisible desktop, where
+// anything they write to the console instead of their std handles is lost
+func setWindowsConsole(execCmd *exec.Cmd, mode WindowsConsoleMode) {
+	if mode == WindowsConsoleAuto {
+		mode = WindowsConsoleInherit
+		if !hasConsole() {
+			mode = WindowsConsoleHidden
+		}
+	}
 
-	// sort keys so that duplicates are filtered in deterministic order
-	keys := make([]string, 0, len(cmdEnv))
-	for k := range cmdEnv {
-		keys = append(keys, k)
+	if mode == WindowsConsoleInherit {
+		return
 	}
-	sort.Strings(keys)
-
-	var env []string
-	seen := make(map[string]bool) // seen env keys
-
-	// add vars from cmdEnv - skipping duplicates
-	for _, k := range keys {
-		v := cmdEnv[k] // value
-		uk := strings.ToUpper(k)
-		if !seen[uk] {
-			env = append(env, k+"="+v)
-			seen[uk] = true
-		}
+
+	if execCmd.SysProcAttr == nil {
+		execCmd.SysProcAttr = &syscall.SysProcAttr{}
 	}
-	// add vars from sysEnv - skipping duplicates and keys present in cmdEnv
-	for _, kv := range sysEnv {
-		if n := strings.IndexByte(kv, '='); n != -1 {
-			k := kv[:n] // key
-			uk := strings.ToUpper(k)
-			if !seen[uk] {
-				env = append(env, kv)
-				seen[uk] = true
-			}
-		}
+
+	switch mode {
+	case WindowsConsoleHidden:
+		execCmd.SysProcAttr.CreationFlags |= createNoWindow
+		execCmd.SysProcAttr.HideWindow = true
+	case WindowsConsoleDetached:
+		execCmd.SysProcAttr.CreationFlags |= detachedProcess
+	}
+}
+
+func hasConsole() bool {
+	if procGetConsoleWindow.Find() != nil {
+		return false
 	}
-	return env
+
+	window, _, _ := procGetConsoleWindow.Call()
+	return window != 0
 }
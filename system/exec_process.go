@@ -2,9 +2,12 @@ package system
 
 import (
 	"bytes"
+	"os"
 	"os/exec"
 	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -16,12 +19,25 @@ const (
 
 type execProcess struct {
 	cmd          *exec.Cmd
-	stdoutWriter *bytes.Buffer
-	stderrWriter *bytes.Buffer
+	stdoutWriter *outputBuffer
+	stderrWriter *outputBuffer
 	keepAttached bool
 	quiet        bool
 	pid          int
 	pgid         int
+	startedAt    time.Time
+	affinity     cpuAffinity
+	limits       ResourceLimits
+	priority     ProcessPriority
+	lineWriters  []*lineWriter
+	watchdog     *inactivityWatchdog
+	tree         processTree
+	usageHandle  processUsageHandle
+	timeout      time.Duration
+	timeoutTimer *time.Timer
+	timedOut     int32
+	termination  TerminationPolicy
+	doneCh       chan struct{}
 	logger       boshlog.Logger
 	waitCh       chan Result
 }
@@ -29,14 +45,31 @@ type execProcess struct {
 func NewExecProcess(cmd *exec.Cmd, keepAttached bool, quiet bool, logger boshlog.Logger) *execProcess {
 	return &execProcess{
 		cmd:          cmd,
-		stdoutWriter: bytes.NewBufferString(""),
-		stderrWriter: bytes.NewBufferString(""),
+		stdoutWriter: &outputBuffer{},
+		stderrWriter: &outputBuffer{},
 		keepAttached: keepAttached,
 		quiet:        quiet,
 		logger:       logger,
+		doneCh:       make(chan struct{}),
 	}
 }
 
+func (p *execProcess) Pid() int {
+	return p.pid
+}
+
+func (p *execProcess) Signal(sig os.Signal) error {
+	err := p.cmd.Process.Signal(sig)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Sending %s to process %d", sig, p.pid)
+	}
+	return nil
+}
+
+func (p *execProcess) Terminate() error {
+	return p.TerminateNicely(p.termination.GracePeriod)
+}
+
 func (p *execProcess) Wait() <-chan Result {
 	if p.waitCh != nil {
 		panic("Wait() must be called only once")
@@ -45,9 +78,9 @@ func (p *execProcess) Wait() <-chan Result {
 	// Use buffer=1 to allow goroutine below to finish
 	p.waitCh = make(chan Result, 1)
 
-	go func() {
+	go boshlog.WrapGoroutine(p.logger, execProcessLogTag, true, func() {
 		p.waitCh <- p.wait()
-	}()
+	})()
 
 	return p.waitCh
 }
@@ -55,6 +88,24 @@ func (p *execProcess) Wait() <-chan Result {
 func (p *execProcess) wait() Result {
 	// err will be non-nil if command exits with non-0 status
 	err := p.cmd.Wait()
+	finishedAt := time.Now()
+	close(p.doneCh)
+
+	usage := p.finalUsage()
+
+	if p.watchdog != nil {
+		p.watchdog.stop()
+	}
+
+	if p.timeoutTimer != nil {
+		p.timeoutTimer.Stop()
+	}
+
+	p.releaseProcessTree()
+
+	for _, lineWriter := range p.lineWriters {
+		lineWriter.flush()
+	}
 
 	stdout := string(p.stdoutWriter.Bytes())
 	if !p.quiet {
@@ -67,25 +118,116 @@ func (p *execProcess) wait() Result {
 	}
 
 	exitStatus := -1
+	var signal syscall.Signal
 	waitStatus := p.cmd.ProcessState.Sys().(syscall.WaitStatus)
 
 	if waitStatus.Exited() {
 		exitStatus = waitStatus.ExitStatus()
 	} else if waitStatus.Signaled() {
-		exitStatus = 128 + int(waitStatus.Signal())
+		signal = waitStatus.Signal()
+		exitStatus = 128 + int(signal)
 	}
 
 	p.logger.Debug(execProcessLogTag, "Successful: %t (%d)", err == nil, exitStatus)
 
+	if p.watchdog != nil && p.watchdog.hasFired() {
+		err = InactivityTimeoutError{Command: strings.Join(p.cmd.Args, " "), Timeout: p.watchdog.timeout}
+	}
+
+	if atomic.LoadInt32(&p.timedOut) == 1 {
+		err = CommandTimeoutError{Command: strings.Join(p.cmd.Args, " "), Timeout: p.timeout}
+	}
+
 	if err != nil {
 		cmdString := strings.Join(p.cmd.Args, " ")
 		err = bosherr.WrapComplexError(err, NewExecError(cmdString, stdout, stderr))
 	}
 
 	return Result{
-		Stdout:     stdout,
-		Stderr:     stderr,
-		ExitStatus: exitStatus,
-		Error:      err,
+		Stdout:          stdout,
+		Stderr:          stderr,
+		StdoutTruncated: p.stdoutWriter.truncated,
+		StderrTruncated: p.stderrWriter.truncated,
+		ExitStatus:      exitStatus,
+		Error:           err,
+		PID:             p.pid,
+		StartedAt:       p.startedAt,
+		FinishedAt:      finishedAt,
+		Duration:        finishedAt.Sub(p.startedAt),
+		Signal:          signal,
+		Usage:           usage,
+	}
+}
+
+// startWatchdog kills the process once it stops writing output
+// for Command.InactivityTimeout or runs longer than Command.Timeout
+func (p *execProcess) startWatchdog() {
+	if p.timeout > 0 {
+		p.timeoutTimer = time.AfterFunc(p.timeout, func() {
+			atomic.StoreInt32(&p.timedOut, 1)
+			p.logger.Error(execProcessLogTag, "Terminating process with PID '%d': still running after %s", p.pid, p.timeout)
+			p.terminateAfterGracePeriod()
+		})
+	}
+
+	if p.watchdog == nil {
+		return
+	}
+
+	p.watchdog.start(func() {
+		p.logger.Error(execProcessLogTag, "Terminating process with PID '%d': no output for %s", p.pid, p.watchdog.timeout)
+		p.terminateAfterGracePeriod()
+	})
+}
+
+// terminateAfterGracePeriod asks the process to exit and kills it once the
+// grace period of its termination policy passed. Unlike TerminateNicely
+// it does not require Wait() to be called.
+func (p *execProcess) terminateAfterGracePeriod() {
+	if p.termination.GracePeriod > 0 {
+		err := p.interrupt()
+		if err == nil {
+			select {
+			case <-p.doneCh:
+				return
+			case <-time.After(p.termination.GracePeriod):
+			}
+		} else {
+			p.logger.Error(execProcessLogTag, "Failed to interrupt process with PID '%d': %s", p.pid, err)
+		}
+	}
+
+	err := p.kill()
+	if err != nil {
+		p.logger.Error(execProcessLogTag, "Failed to kill process with PID '%d': %s", p.pid, err)
 	}
 }
+
+// outputBuffer keeps at most max bytes of output, or all of it when max is 0.
+// Writes never fail so that the command and other writers of a
+// MultiWriter keep receiving the whole output.
+type outputBuffer struct {
+	buf       bytes.Buffer
+	max       int64
+	truncated bool
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if b.max > 0 {
+		remaining := b.max - int64(b.buf.Len())
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+			b.truncated = true
+		}
+	}
+
+	b.buf.Write(p)
+
+	return n, nil
+}
+
+func (b *outputBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
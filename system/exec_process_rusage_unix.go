@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package system
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+type processUsageHandle struct{}
+
+// finalUsage reads the rusage of the exited process
+func (p *execProcess) finalUsage() CommandUsage {
+	rusage, ok := p.cmd.ProcessState.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return CommandUsage{}
+	}
+
+	// ru_maxrss is in bytes on Darwin and in KiB elsewhere
+	maxRSS := uint64(rusage.Maxrss)
+	if runtime.GOOS != "darwin" {
+		maxRSS *= 1024
+	}
+
+	return CommandUsage{
+		UserTime:    time.Duration(rusage.Utime.Nano()),
+		SystemTime:  time.Duration(rusage.Stime.Nano()),
+		MaxRSSBytes: maxRSS,
+	}
+}
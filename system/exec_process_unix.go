@@ -26,11 +26,22 @@ func (p *execProcess) Start() error {
 		p.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	}
 
-	err := p.cmd.Start()
+	err := p.limits.validate()
+	if err == nil {
+		err = p.priority.validate()
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Starting command '%s'", cmdString)
+	}
+
+	err = p.startCmd()
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Starting command '%s'", cmdString)
 	}
 
+	p.startedAt = time.Now()
+	p.pid = p.cmd.Process.Pid
+
 	if !p.keepAttached {
 		p.pgid = p.cmd.Process.Pid
 	} else {
@@ -41,9 +52,35 @@ func (p *execProcess) Start() error {
 		}
 	}
 
+	p.startWatchdog()
+
 	return nil
 }
 
+// processTree is the process group of detached processes, see signalGroup
+type processTree struct{}
+
+func (p *execProcess) releaseProcessTree() {}
+
+// interrupt sends SIGTERM like kill sends SIGKILL
+func (p *execProcess) interrupt() error {
+	if p.keepAttached {
+		return p.cmd.Process.Signal(syscall.SIGTERM)
+	}
+
+	return p.signalGroup(syscall.SIGTERM)
+}
+
+// kill does not touch the process group of attached processes since
+// it is the group of the current process
+func (p *execProcess) kill() error {
+	if p.keepAttached {
+		return p.cmd.Process.Kill()
+	}
+
+	return p.signalGroup(syscall.SIGKILL)
+}
+
 // TerminateNicely can be called multiple times simultaneously from different goroutines
 func (p *execProcess) TerminateNicely(killGracePeriod time.Duration) error {
 	// Make sure process is being waited on for process state reaping to occur
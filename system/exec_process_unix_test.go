@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -20,6 +21,37 @@ import (
 )
 
 var _ = Describe("execProcess", func() {
+	Describe("Pid and Signal", func() {
+		It("sends signals to the started process", func() {
+			runner := NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+			ready := make(chan struct{}, 1)
+
+			process, err := runner.RunComplexCommandAsync(Command{
+				Name: "sh",
+				Args: []string{"-c", `trap 'echo reloaded; exit 0' HUP; echo ready; while true; do sleep 0.1; done`},
+				StdoutLineFunc: func(line string) {
+					if line == "ready" {
+						ready <- struct{}{}
+					}
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(process.Pid()).To(BeNumerically(">", 0))
+
+			Eventually(ready).Should(Receive())
+
+			Expect(process.Signal(syscall.SIGHUP)).To(Succeed())
+
+			result := <-process.Wait()
+			Expect(result.Error).ToNot(HaveOccurred())
+			Expect(result.Stdout).To(Equal("ready\nreloaded\n"))
+			Expect(result.PID).To(Equal(process.Pid()))
+
+			err = process.Signal(syscall.SIGHUP)
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("Sending hangup to process %d", result.PID))))
+		})
+	})
+
 	Describe("TerminateNicely", func() {
 		var (
 			buildDir string
@@ -178,4 +210,190 @@ var _ = Describe("execProcess", func() {
 			})
 		}
 	})
+
+	Describe("InactivityTimeout", func() {
+		var runner CmdRunner
+
+		BeforeEach(func() {
+			runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+		})
+
+		It("kills commands that produce no output for the timeout", func() {
+			started := time.Now()
+
+			stdout, _, exitStatus, err := runner.RunComplexCommand(Command{
+				Name:              "sh",
+				Args:              []string{"-c", "echo started; sleep 10"},
+				InactivityTimeout: 200 * time.Millisecond,
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(IsInactivityTimeoutError(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("was killed after producing no output for 200ms"))
+			Expect(stdout).To(Equal("started\n"))
+			Expect(exitStatus).To(Equal(128 + 9))
+			Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+		})
+
+		It("does not kill commands that keep producing output", func() {
+			_, _, _, err := runner.RunComplexCommand(Command{
+				Name:              "sh",
+				Args:              []string{"-c", "for i in 1 2 3 4 5 6; do echo $i >&2; sleep 0.1; done"},
+				InactivityTimeout: 400 * time.Millisecond,
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("kills only the command when it is attached to the current process group", func() {
+			process, err := runner.RunComplexCommandAsync(Command{
+				Name:              "sleep",
+				Args:              []string{"10"},
+				KeepAttached:      true,
+				InactivityTimeout: 100 * time.Millisecond,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			result := <-process.Wait()
+			Expect(IsInactivityTimeoutError(result.Error)).To(BeTrue())
+		})
+	})
+
+	Describe("Terminate", func() {
+		It("terminates the command with the grace period of its termination policy", func() {
+			runner := NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+
+			process, err := runner.RunComplexCommandAsync(Command{
+				Name:        "sh",
+				Args:        []string{"-c", "trap 'echo terminated; exit 3' TERM; echo started; sleep 10 & wait"},
+				Termination: TerminationPolicy{GracePeriod: 5 * time.Second},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			waitCh := process.Wait()
+			time.Sleep(200 * time.Millisecond)
+
+			started := time.Now()
+			Expect(process.Terminate()).To(Succeed())
+			Expect(time.Since(started)).To(BeNumerically("<", 4*time.Second))
+
+			result := <-waitCh
+			Expect(result.Stdout).To(Equal("started\nterminated\n"))
+			Expect(result.ExitStatus).To(Equal(3))
+		})
+	})
+
+	Describe("StdoutLineFunc", func() {
+		It("is called while the command is still running", func() {
+			runner := NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+			linesCh := make(chan string, 10)
+
+			process, err := runner.RunComplexCommandAsync(Command{
+				Name:           "sh",
+				Args:           []string{"-c", "echo progress; sleep 10 & wait"},
+				StdoutLineFunc: func(line string) { linesCh <- line },
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			waitCh := process.Wait()
+			Eventually(linesCh, 5*time.Second).Should(Receive(Equal("progress")))
+
+			Expect(process.TerminateNicely(time.Second)).To(Succeed())
+			<-waitCh
+		})
+	})
+
+	Describe("Result", func() {
+		It("reports the signal that terminated the command", func() {
+			runner := NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+
+			process, err := runner.RunComplexCommandAsync(Command{
+				Name: "sh",
+				Args: []string{"-c", "kill -TERM $$"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			result := <-process.Wait()
+			Expect(result.Signal).To(Equal(syscall.SIGTERM))
+			Expect(result.ExitStatus).To(Equal(128 + 15))
+		})
+	})
+
+	Describe("Timeout", func() {
+		var runner CmdRunner
+
+		BeforeEach(func() {
+			runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+		})
+
+		It("kills commands and their children when they run longer than the timeout", func() {
+			started := time.Now()
+
+			stdout, stderr, exitStatus, err := runner.RunComplexCommand(Command{
+				Name:    "sh",
+				Args:    []string{"-c", "echo out; echo err >&2; sleep 10 & wait"},
+				Timeout: 200 * time.Millisecond,
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(IsCommandTimeoutError(err)).To(BeTrue())
+			Expect(IsInactivityTimeoutError(err)).To(BeFalse())
+			Expect(err.Error()).To(ContainSubstring("was killed after running for 200ms"))
+			Expect(stdout).To(Equal("out\n"))
+			Expect(stderr).To(Equal("err\n"))
+			Expect(exitStatus).To(Equal(128 + 9))
+			Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+		})
+
+		It("kills commands that keep producing output", func() {
+			process, err := runner.RunComplexCommandAsync(Command{
+				Name:              "sh",
+				Args:              []string{"-c", "while true; do echo tick; sleep 0.05; done"},
+				InactivityTimeout: time.Second,
+				Timeout:           300 * time.Millisecond,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			result := <-process.Wait()
+			Expect(IsCommandTimeoutError(result.Error)).To(BeTrue())
+			Expect(result.Stdout).To(HavePrefix("tick\n"))
+		})
+
+		It("gives commands the grace period of the termination policy to exit", func() {
+			started := time.Now()
+
+			stdout, _, exitStatus, err := runner.RunComplexCommand(Command{
+				Name:        "sh",
+				Args:        []string{"-c", "trap 'echo terminated; exit 3' TERM; sleep 10 & wait"},
+				Timeout:     200 * time.Millisecond,
+				Termination: TerminationPolicy{GracePeriod: 5 * time.Second},
+			})
+			Expect(IsCommandTimeoutError(err)).To(BeTrue())
+			Expect(stdout).To(Equal("terminated\n"))
+			Expect(exitStatus).To(Equal(3))
+			Expect(time.Since(started)).To(BeNumerically("<", 4*time.Second))
+		})
+
+		It("kills commands that do not exit within the grace period", func() {
+			started := time.Now()
+
+			_, _, exitStatus, err := runner.RunComplexCommand(Command{
+				Name:        "sh",
+				Args:        []string{"-c", "trap '' TERM; sleep 10 & wait"},
+				Timeout:     200 * time.Millisecond,
+				Termination: TerminationPolicy{GracePeriod: 300 * time.Millisecond},
+			})
+			Expect(IsCommandTimeoutError(err)).To(BeTrue())
+			Expect(exitStatus).To(Equal(128 + 9))
+			Expect(time.Since(started)).To(BeNumerically(">=", 500*time.Millisecond))
+			Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+		})
+
+		It("does not affect commands that finish in time", func() {
+			stdout, _, _, err := runner.RunComplexCommand(Command{
+				Name:    "sh",
+				Args:    []string{"-c", "echo done"},
+				Timeout: 5 * time.Second,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdout).To(Equal("done\n"))
+		})
+	})
 })
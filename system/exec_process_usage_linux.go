@@ -0,0 +1,85 @@
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// USER_HZ is 100 on all supported Linux architectures
+const clockTicksPerSecond = 100
+
+func (p *execProcess) SampleUsage() (ProcessUsage, error) {
+	if p.cmd.Process == nil {
+		return ProcessUsage{}, bosherr.Error("Sampling usage of process that has not been started")
+	}
+
+	return readProcStatUsage(p.cmd.Process.Pid)
+}
+
+func readProcStatUsage(pid int) (ProcessUsage, error) {
+	statPath := fmt.Sprintf("/proc/%d/stat", pid)
+
+	contents, err := ioutil.ReadFile(statPath)
+	if err != nil {
+		return ProcessUsage{}, bosherr.WrapErrorf(err, "Reading %s", statPath)
+	}
+
+	// Command name (2nd field) may contain spaces and parens
+	stat := string(contents)
+	commEnd := strings.LastIndexByte(stat, ')')
+	if commEnd == -1 {
+		return ProcessUsage{}, bosherr.Errorf("Parsing %s: missing command name", statPath)
+	}
+
+	// fields[0] is the 3rd field (state)
+	fields := strings.Fields(stat[commEnd+1:])
+	if len(fields) < 22 {
+		return ProcessUsage{}, bosherr.Errorf("Parsing %s: expected at least 24 fields", statPath)
+	}
+
+	parse := func(i int) (uint64, error) {
+		value, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return 0, bosherr.WrapErrorf(err, "Parsing %s field %d", statPath, i+3)
+		}
+		return value, nil
+	}
+
+	utime, err := parse(11)
+	if err != nil {
+		return ProcessUsage{}, err
+	}
+
+	stime, err := parse(12)
+	if err != nil {
+		return ProcessUsage{}, err
+	}
+
+	vsize, err := parse(20)
+	if err != nil {
+		return ProcessUsage{}, err
+	}
+
+	rssPages, err := parse(21)
+	if err != nil {
+		return ProcessUsage{}, err
+	}
+
+	return ProcessUsage{
+		UserTime:   ticksToDuration(utime),
+		SystemTime: ticksToDuration(stime),
+		RSSBytes:   rssPages * uint64(os.Getpagesize()),
+		VMSBytes:   vsize,
+		SampledAt:  time.Now(),
+	}, nil
+}
+
+func ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * time.Second / clockTicksPerSecond
+}
@@ -0,0 +1,69 @@
+package system_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("execProcess", func() {
+	Describe("SampleUsage", func() {
+		var runner CmdRunner
+
+		BeforeEach(func() {
+			runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+		})
+
+		It("reads memory usage of the running process", func() {
+			process, err := runner.RunComplexCommandAsync(Command{Name: "sleep", Args: []string{"5"}})
+			Expect(err).ToNot(HaveOccurred())
+
+			waitCh := process.Wait()
+			defer process.TerminateNicely(time.Second)
+
+			var usage ProcessUsage
+			Eventually(func() uint64 {
+				usage, err = process.SampleUsage()
+				Expect(err).ToNot(HaveOccurred())
+				return usage.RSSBytes
+			}).Should(BeNumerically(">", 0))
+			Expect(usage.VMSBytes).To(BeNumerically(">=", usage.RSSBytes))
+			Expect(usage.SampledAt).To(BeTemporally("~", time.Now(), time.Second))
+
+			Expect(process.TerminateNicely(time.Second)).To(Succeed())
+			<-waitCh
+		})
+
+		It("returns an error after the process exited", func() {
+			process, err := runner.RunComplexCommandAsync(Command{Name: "true"})
+			Expect(err).ToNot(HaveOccurred())
+
+			<-process.Wait()
+
+			_, err = process.SampleUsage()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Result.Usage", func() {
+		It("reports the CPU time and peak memory of the exited command", func() {
+			runner := NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+			process, err := runner.RunComplexCommandAsync(Command{
+				Name: "sh",
+				Args: []string{"-c", "i=0; while [ $i -lt 300000 ]; do i=$((i+1)); done"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			result := <-process.Wait()
+			Expect(result.Error).ToNot(HaveOccurred())
+
+			Expect(result.Usage.CPUTime()).To(BeNumerically(">", 0))
+			Expect(result.Usage.CPUTime()).To(BeNumerically("<=", result.Duration+10*time.Millisecond))
+			Expect(result.Usage.MaxRSSBytes).To(BeNumerically(">", 1024*1024))
+		})
+	})
+})
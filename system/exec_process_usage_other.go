@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package system
+
+import (
+	"runtime"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func (p *execProcess) SampleUsage() (ProcessUsage, error) {
+	return ProcessUsage{}, bosherr.Errorf("Sampling process usage is not supported on %s", runtime.GOOS)
+}
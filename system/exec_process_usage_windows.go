@@ -0,0 +1,129 @@
+package system
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const processQueryLimitedInformation = 0x1000
+
+var (
+	procGetProcessMemoryInfo = kernel32DLL.NewProc("K32GetProcessMemoryInfo")
+	procGetProcessIoCounters = kernel32DLL.NewProc("GetProcessIoCounters")
+)
+
+// processMemoryCounters mirrors PROCESS_MEMORY_COUNTERS
+type processMemoryCounters struct {
+	CB                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// processUsageHandle keeps the exited process around so that its peak
+// memory and IO counters can still be read once Wait released its handle
+type processUsageHandle struct {
+	handle syscall.Handle
+}
+
+func (h *processUsageHandle) open(pid int) error {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+
+	h.handle = handle
+	return nil
+}
+
+// finalUsage combines the times reported by Wait with the peak memory and
+// IO counters of the process
+func (p *execProcess) finalUsage() CommandUsage {
+	var usage CommandUsage
+
+	if rusage, ok := p.cmd.ProcessState.SysUsage().(*syscall.Rusage); ok && rusage != nil {
+		usage.UserTime = filetimeToDuration(rusage.UserTime)
+		usage.SystemTime = filetimeToDuration(rusage.KernelTime)
+	}
+
+	handle := p.usageHandle.handle
+	if handle == 0 {
+		return usage
+	}
+
+	defer syscall.CloseHandle(handle)
+	p.usageHandle.handle = 0
+
+	var counters processMemoryCounters
+	counters.CB = uint32(unsafe.Sizeof(counters))
+	r, _, _ := procGetProcessMemoryInfo.Call(uintptr(handle), uintptr(unsafe.Pointer(&counters)), uintptr(counters.CB))
+	if r != 0 {
+		usage.MaxRSSBytes = uint64(counters.PeakWorkingSetSize)
+	}
+
+	var io ioCounters
+	r, _, _ = procGetProcessIoCounters.Call(uintptr(handle), uintptr(unsafe.Pointer(&io)))
+	if r != 0 {
+		usage.ReadOperations = io.ReadOperationCount
+		usage.WriteOperations = io.WriteOperationCount
+		usage.ReadBytes = io.ReadTransferCount
+		usage.WriteBytes = io.WriteTransferCount
+	}
+
+	return usage
+}
+
+func (p *execProcess) SampleUsage() (ProcessUsage, error) {
+	if p.cmd.Process == nil {
+		return ProcessUsage{}, bosherr.Error("Sampling usage of process that has not been started")
+	}
+
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(p.cmd.Process.Pid))
+	if err != nil {
+		return ProcessUsage{}, bosherr.WrapErrorf(err, "Opening process %d", p.cmd.Process.Pid)
+	}
+	defer syscall.CloseHandle(handle)
+
+	return sampleProcessHandleUsage(handle)
+}
+
+func sampleProcessHandleUsage(handle syscall.Handle) (ProcessUsage, error) {
+	var creation, exit, kernel, user syscall.Filetime
+	err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user)
+	if err != nil {
+		return ProcessUsage{}, bosherr.WrapError(err, "Getting process times")
+	}
+
+	var counters processMemoryCounters
+	counters.CB = uint32(unsafe.Sizeof(counters))
+	r, _, err := procGetProcessMemoryInfo.Call(
+		uintptr(handle),
+		uintptr(unsafe.Pointer(&counters)),
+		uintptr(counters.CB),
+	)
+	if r == 0 {
+		return ProcessUsage{}, bosherr.WrapError(err, "Getting process memory info")
+	}
+
+	return ProcessUsage{
+		UserTime:   filetimeToDuration(user),
+		SystemTime: filetimeToDuration(kernel),
+		RSSBytes:   uint64(counters.WorkingSetSize),
+		VMSBytes:   uint64(counters.PagefileUsage),
+		SampledAt:  time.Now(),
+	}, nil
+}
+
+// filetimeToDuration converts FILETIME durations in 100ns units
+func filetimeToDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
@@ -2,11 +2,55 @@ package system
 
 import (
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	"unsafe"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
+const (
+	processTerminate    = 0x0001
+	processSetQuota     = 0x0100
+	threadSuspendResume = 0x0002
+
+	createSuspended       = 0x00000004
+	createNewProcessGroup = 0x00000200
+	ctrlBreakEvent        = 1
+
+	th32csSnapThread = 0x00000004
+)
+
+var (
+	procCreateJobObjectW         = kernel32DLL.NewProc("CreateJobObjectW")
+	procAssignProcessToJobObject = kernel32DLL.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject       = kernel32DLL.NewProc("TerminateJobObject")
+	procGenerateConsoleCtrlEvent = kernel32DLL.NewProc("GenerateConsoleCtrlEvent")
+	procThread32First            = kernel32DLL.NewProc("Thread32First")
+	procThread32Next             = kernel32DLL.NewProc("Thread32Next")
+	procOpenThread               = kernel32DLL.NewProc("OpenThread")
+	procResumeThread             = kernel32DLL.NewProc("ResumeThread")
+)
+
+type threadEntry32 struct {
+	Size           uint32
+	Usage          uint32
+	ThreadID       uint32
+	OwnerProcessID uint32
+	BasePri        int32
+	DeltaPri       int32
+	Flags          uint32
+}
+
+// processTree is a Job Object holding the process and every process it
+// starts. The process is started suspended and only resumed once it was
+// assigned to the job, so that it cannot start processes outside of it.
+type processTree struct {
+	job  syscall.Handle
+	lock sync.Mutex
+}
+
 func (p *execProcess) Start() error {
 	if p.cmd.Stdout == nil {
 		p.cmd.Stdout = p.stdoutWriter
@@ -17,15 +61,212 @@ func (p *execProcess) Start() error {
 	cmdString := strings.Join(p.cmd.Args, " ")
 	p.logger.Debug(execProcessLogTag, "Running command: %s", cmdString)
 
-	err := p.cmd.Start()
+	// CTRL_BREAK can only be sent to the root of a process group
+	if p.termination.GracePeriod > 0 && !p.keepAttached {
+		if p.cmd.SysProcAttr == nil {
+			p.cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		p.cmd.SysProcAttr.CreationFlags |= createNewProcessGroup
+	}
+
+	err := p.limits.validate()
+	if err == nil {
+		err = p.priority.validate()
+	}
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Starting command %s", cmdString)
+	}
+
+	priorityFlags, err := p.priority.creationFlags()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Starting command %s", cmdString)
+	}
+	if priorityFlags != 0 {
+		if p.cmd.SysProcAttr == nil {
+			p.cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		p.cmd.SysProcAttr.CreationFlags |= priorityFlags
+	}
+
+	// limits need a job even for attached processes
+	useJob := !p.keepAttached || p.limits.requested()
+	if useJob {
+		if p.cmd.SysProcAttr == nil {
+			p.cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		p.cmd.SysProcAttr.CreationFlags |= createSuspended
+	}
+
+	err = p.startCmd()
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Starting command %s", cmdString)
 	}
 
+	p.startedAt = time.Now()
 	p.pid = p.cmd.Process.Pid
+
+	if useJob {
+		err = p.tree.assign(p.pid, p.limits)
+		if err != nil && p.limits.requested() {
+			p.cmd.Process.Kill()
+			p.cmd.Wait()
+			return bosherr.WrapErrorf(err, "Limiting resources of command %s", cmdString)
+		}
+		if err != nil {
+			p.logger.Error(execProcessLogTag, "Failed to track child processes of PID '%d': %s", p.pid, err)
+		}
+
+		err = resumeProcess(p.pid)
+		if err != nil {
+			p.kill()
+			p.cmd.Wait()
+			return bosherr.WrapErrorf(err, "Starting command %s", cmdString)
+		}
+	}
+
+	err = p.usageHandle.open(p.pid)
+	if err != nil {
+		p.logger.Error(execProcessLogTag, "Failed to open PID '%d' to report its usage: %s", p.pid, err)
+	}
+
+	p.startWatchdog()
+
 	return nil
 }
 
+// interrupt sends CTRL_BREAK to processes started in their own process group
+func (p *execProcess) interrupt() error {
+	if p.cmd.SysProcAttr == nil || p.cmd.SysProcAttr.CreationFlags&createNewProcessGroup == 0 {
+		return bosherr.Error("Process was not started in its own process group")
+	}
+
+	r, _, err := procGenerateConsoleCtrlEvent.Call(ctrlBreakEvent, uintptr(p.pid))
+	if r == 0 {
+		return bosherr.WrapError(err, "Sending CTRL_BREAK")
+	}
+
+	return nil
+}
+
+// kill terminates the whole job when the process was assigned to one
+func (p *execProcess) kill() error {
+	terminated, err := p.tree.terminate()
+	if terminated {
+		return err
+	}
+
+	return p.cmd.Process.Kill()
+}
+
+func (p *execProcess) releaseProcessTree() {
+	p.tree.close()
+}
+
+func (t *processTree) assign(pid int, limits ResourceLimits) error {
+	job, _, err := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return bosherr.WrapError(err, "Creating job object")
+	}
+
+	err = limits.setOn(syscall.Handle(job))
+	if err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return err
+	}
+
+	process, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(pid))
+	if err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return bosherr.WrapErrorf(err, "Opening process %d", pid)
+	}
+	defer syscall.CloseHandle(process)
+
+	r, _, err := procAssignProcessToJobObject.Call(job, uintptr(process))
+	if r == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return bosherr.WrapErrorf(err, "Assigning process %d to job object", pid)
+	}
+
+	t.lock.Lock()
+	t.job = syscall.Handle(job)
+	t.lock.Unlock()
+
+	return nil
+}
+
+// resumeProcess resumes the threads of a process started suspended
+func resumeProcess(pid int) error {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(th32csSnapThread, 0)
+	if err != nil {
+		return bosherr.WrapError(err, "Listing threads")
+	}
+	defer syscall.CloseHandle(snapshot)
+
+	entry := threadEntry32{Size: uint32(unsafe.Sizeof(threadEntry32{}))}
+
+	r, _, err := procThread32First.Call(uintptr(snapshot), uintptr(unsafe.Pointer(&entry)))
+	for r != 0 {
+		if entry.OwnerProcessID == uint32(pid) {
+			err = resumeThread(entry.ThreadID)
+			if err != nil {
+				return err
+			}
+		}
+
+		r, _, err = procThread32Next.Call(uintptr(snapshot), uintptr(unsafe.Pointer(&entry)))
+	}
+
+	if err != syscall.ERROR_NO_MORE_FILES {
+		return bosherr.WrapErrorf(err, "Listing threads of process %d", pid)
+	}
+
+	return nil
+}
+
+func resumeThread(id uint32) error {
+	thread, _, err := procOpenThread.Call(threadSuspendResume, 0, uintptr(id))
+	if thread == 0 {
+		return bosherr.WrapErrorf(err, "Opening thread %d", id)
+	}
+	defer syscall.CloseHandle(syscall.Handle(thread))
+
+	r, _, err := procResumeThread.Call(thread)
+	if int32(r) == -1 {
+		return bosherr.WrapErrorf(err, "Resuming thread %d", id)
+	}
+
+	return nil
+}
+
+// terminate uses exit code 1 like os.Process.Kill. It returns false
+// when there is no job.
+func (t *processTree) terminate() (bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.job == 0 {
+		return false, nil
+	}
+
+	r, _, err := procTerminateJobObject.Call(uintptr(t.job), 1)
+	if r == 0 {
+		return true, bosherr.WrapError(err, "Terminating job object")
+	}
+
+	return true, nil
+}
+
+// close lets processes that are still running in the job continue
+func (t *processTree) close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.job != 0 {
+		syscall.CloseHandle(t.job)
+		t.job = 0
+	}
+}
+
 func (p *execProcess) TerminateNicely(killGracePeriod time.Duration) error {
 	p.logger.Debug(execProcessLogTag, "Terminating process with PID '%d'", p.pid)
 
@@ -46,7 +287,15 @@ func (p *execProcess) TerminateNicely(killGracePeriod time.Duration) error {
 		return nil
 	}
 
-	err := p.cmd.Process.Kill()
+	if killGracePeriod > 0 && p.interrupt() == nil {
+		select {
+		case <-p.doneCh:
+			return nil
+		case <-time.After(killGracePeriod):
+		}
+	}
+
+	err := p.kill()
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Terminating process: %#v", err)
 	}
@@ -6,6 +6,8 @@ package system_test
 import (
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -36,6 +38,20 @@ var _ = Describe("execCmdRunner", func() {
 			Expect(result.Error).ToNot(HaveOccurred())
 			Expect(result.ExitStatus).To(Equal(0))
 		})
+
+		It("resumes attached processes assigned to a job for their limits", func() {
+			runner := NewExecCmdRunner(logger)
+
+			stdout, _, exitStatus, err := runner.RunComplexCommand(Command{
+				Name:         "cmd.exe",
+				Args:         []string{"/C", "echo fake-output"},
+				KeepAttached: true,
+				Limits:       ResourceLimits{MaxProcesses: 10},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exitStatus).To(Equal(0))
+			Expect(stdout).To(ContainSubstring("fake-output"))
+		})
 	})
 
 	Describe("TerminateNicely", func() {
@@ -62,6 +78,34 @@ var _ = Describe("execCmdRunner", func() {
 			})
 		})
 
+		Context("when the process started child processes", func() {
+			It("kills the child processes too", func() {
+				script := fmt.Sprintf("start /B %s & %s", WindowsExePath, WindowsExePath)
+				execProcess := NewExecProcess(exec.Command("cmd.exe", "/C", script), false, false, logger)
+				err := execProcess.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				imageFilter := fmt.Sprintf("IMAGENAME eq %s", filepath.Base(WindowsExePath))
+				countProcesses := func() int {
+					output, err := exec.Command("tasklist", "/NH", "/FI", imageFilter).Output()
+					Expect(err).ToNot(HaveOccurred())
+					return strings.Count(string(output), filepath.Base(WindowsExePath))
+				}
+				Eventually(countProcesses, 10*time.Second).Should(Equal(2))
+
+				waitCh := execProcess.Wait()
+
+				err = execProcess.TerminateNicely(1 * time.Minute)
+				Expect(err).ToNot(HaveOccurred())
+
+				var result Result
+				Eventually(waitCh, 10*time.Second).Should(Receive(&result))
+				Expect(result.ExitStatus).To(Equal(1))
+
+				Eventually(countProcesses, 10*time.Second).Should(Equal(0))
+			})
+		})
+
 		Context("when process does not exist", func() {
 			It("returns no error", func() {
 				execProcess := NewExecProcess(exec.Command(WindowsExePath), false, false, logger)
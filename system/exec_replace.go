@@ -0,0 +1,60 @@
+package system
+
+import (
+	"os"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type ExecReplaceOpts struct {
+	// Logger is flushed before the process is handed over
+	Logger boshlog.Logger
+
+	// PreserveFiles stay open in the new program under their current
+	// descriptors; stdin, stdout and stderr are always kept
+	PreserveFiles []*os.File
+}
+
+// ExecReplace hands the current process over to cmd, e.g. for bootstrap
+// binaries that start the real agent once they prepared the machine.
+// Name, Args, Env, EnvFiles, UseIsolatedEnv and WorkingDir of cmd are used.
+//
+// On Unix the process is replaced with execve and keeps its PID.
+// Windows can not replace processes, so cmd is started with the same
+// std handles and the current process exits with its exit code once it is done.
+//
+// ExecReplace only returns when cmd could not be started. The working
+// directory, signals and files of the current process are unchanged then.
+func ExecReplace(cmd Command, opts ExecReplaceOpts) error {
+	lookup, err := lookPath(cmd.Name, LookPathOpts{})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Replacing process with '%s'", cmd.Name)
+	}
+
+	// lookup.Path is absolute, so it is not resolved from cmd.WorkingDir
+	path := lookup.Path
+
+	cmd, err = withEnvFiles(cmd)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Replacing process with '%s'", cmd.Name)
+	}
+
+	env := commandEnv(cmd)
+
+	if opts.Logger != nil {
+		opts.Logger.Debug("ExecReplace", "Replacing process with '%s' %v", path, cmd.Args)
+
+		err = opts.Logger.Flush()
+		if err != nil {
+			return bosherr.WrapError(err, "Flushing logger")
+		}
+	}
+
+	err = execReplace(path, append([]string{cmd.Name}, cmd.Args...), env, cmd.WorkingDir, opts.PreserveFiles)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Replacing process with '%s'", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package system_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("ExecReplace", func() {
+	var preservedPath string
+
+	BeforeEach(func() {
+		if Windows {
+			Skip("Windows hands processes over instead of replacing them")
+		}
+
+		preservedPath = filepath.Join(GinkgoT().TempDir(), "preserved")
+	})
+
+	It("replaces the process keeping its pid and preserved files", func() {
+		output, err := exec.Command(ExecReplaceExePath, preservedPath, "sh", "-c", `echo "pid: $$"; echo preserved >&$PRESERVED_FD`).Output()
+		Expect(err).ToNot(HaveOccurred())
+
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[1]).To(Equal(lines[0]))
+
+		contents, err := os.ReadFile(preservedPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("preserved\n"))
+	})
+
+	It("passes on the exit status of the new program", func() {
+		err := exec.Command(ExecReplaceExePath, preservedPath, "sh", "-c", "exit 7").Run()
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*exec.ExitError).ExitCode()).To(Equal(7))
+	})
+
+	It("returns an error without replacing the process when the command does not exist", func() {
+		output, err := exec.Command(ExecReplaceExePath, preservedPath, "does-not-exist-exec-replace").CombinedOutput()
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*exec.ExitError).ExitCode()).To(Equal(3))
+		Expect(string(output)).To(ContainSubstring("Command 'does-not-exist-exec-replace' not found"))
+	})
+
+	It("resolves relative commands before changing the working directory", func() {
+		dir := GinkgoT().TempDir()
+		workingDir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\npwd\n"), 0700)).To(Succeed())
+
+		cmd := exec.Command(ExecReplaceExePath, preservedPath, "./tool")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "EXEC_REPLACE_WORKING_DIR="+workingDir)
+
+		output, err := cmd.Output()
+		Expect(err).ToNot(HaveOccurred())
+
+		resolvedDir, err := filepath.EvalSymlinks(workingDir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(output)).To(HaveSuffix(resolvedDir + "\n"))
+	})
+
+	It("returns a CommandNotFoundError for missing commands", func() {
+		err := ExecReplace(Command{Name: "does-not-exist-exec-replace"}, ExecReplaceOpts{})
+		Expect(IsCommandNotFoundError(err)).To(BeTrue())
+	})
+})
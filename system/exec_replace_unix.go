@@ -0,0 +1,76 @@
+//go:build !windows
+// +build !windows
+
+package system
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// execReplace restores what it changed for the exec when the exec fails
+func execReplace(path string, argv, env []string, workingDir string, preserveFiles []*os.File) (err error) {
+	// ignored signals stay ignored across execve while caught ones are
+	// reset to their defaults by it
+	var ignored []os.Signal
+	for sig := syscall.Signal(1); sig < 65; sig++ {
+		if signal.Ignored(sig) {
+			ignored = append(ignored, sig)
+		}
+	}
+
+	if len(ignored) > 0 {
+		signal.Reset(ignored...)
+		defer func() {
+			if err != nil {
+				signal.Ignore(ignored...)
+			}
+		}()
+	}
+
+	// Go opens all files close-on-exec
+	for _, file := range preserveFiles {
+		fd := file.Fd()
+
+		var flags int
+		flags, err = unix.FcntlInt(fd, unix.F_GETFD, 0)
+		if err != nil {
+			return err
+		}
+
+		_, err = unix.FcntlInt(fd, unix.F_SETFD, flags&^unix.FD_CLOEXEC)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				unix.FcntlInt(fd, unix.F_SETFD, flags)
+			}
+		}()
+	}
+
+	if workingDir != "" {
+		var previousDir string
+		previousDir, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		err = os.Chdir(workingDir)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				os.Chdir(previousDir)
+			}
+		}()
+	}
+
+	return syscall.Exec(path, argv, env)
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+// +build !windows
+
+package system_test
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("ExecReplace on Unix", func() {
+	It("leaves the current process as it was when the exec fails", func() {
+		dir := GinkgoT().TempDir()
+		broken := filepath.Join(dir, "broken")
+		Expect(os.WriteFile(broken, []byte("#!/does/not/exist\n"), 0700)).To(Succeed())
+
+		preserved, err := os.Create(filepath.Join(dir, "preserved"))
+		Expect(err).ToNot(HaveOccurred())
+		defer preserved.Close()
+
+		signal.Ignore(syscall.SIGUSR2)
+		defer signal.Reset(syscall.SIGUSR2)
+
+		previousDir, err := os.Getwd()
+		Expect(err).ToNot(HaveOccurred())
+
+		err = ExecReplace(Command{Name: broken, WorkingDir: dir}, ExecReplaceOpts{PreserveFiles: []*os.File{preserved}})
+		Expect(err).To(HaveOccurred())
+
+		Expect(os.Getwd()).To(Equal(previousDir))
+		Expect(signal.Ignored(syscall.SIGUSR2)).To(BeTrue())
+
+		flags, err := unix.FcntlInt(preserved.Fd(), unix.F_GETFD, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(flags & unix.FD_CLOEXEC).ToNot(BeZero())
+	})
+})
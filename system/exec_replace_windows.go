@@ -0,0 +1,46 @@
+package system
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+func execReplace(path string, argv, env []string, workingDir string, preserveFiles []*os.File) error {
+	cmd := &exec.Cmd{
+		Path:   path,
+		Args:   argv,
+		Env:    env,
+		Dir:    workingDir,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+
+	if len(preserveFiles) > 0 {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		for _, file := range preserveFiles {
+			cmd.SysProcAttr.AdditionalInheritedHandles = append(cmd.SysProcAttr.AdditionalInheritedHandles, syscall.Handle(file.Fd()))
+		}
+	}
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	// Ctrl+C reaches the whole console group, so it is left to cmd
+	signal.Ignore(os.Interrupt)
+
+	err = cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+	return nil
+}
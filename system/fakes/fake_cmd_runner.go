@@ -1,8 +1,10 @@
 package fakes
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +20,7 @@ type FakeCmdRunner struct {
 	processesLock sync.Mutex
 
 	RunComplexCommands   []boshsys.Command
+	RunPipelines         [][]boshsys.Command
 	RunCommands          [][]string
 	RunCommandsWithInput [][]string
 	RunCommandsQuietly   [][]string
@@ -25,6 +28,11 @@ type FakeCmdRunner struct {
 
 	CommandExistsValue bool
 	AvailableCommands  map[string]bool
+
+	// LookPathResults are returned by LookPath, commands that exist
+	// otherwise resolve to /usr/bin/<name>
+	LookPathResults map[string]boshsys.CommandLookup
+	LookPathOpts    []boshsys.LookPathOpts
 }
 
 type FakeCmdCallback func()
@@ -50,6 +58,16 @@ type FakeProcess struct {
 	TerminatedNicelyCallBack       func(*FakeProcess)
 	TerminateNicelyKillGracePeriod time.Duration
 	TerminateNicelyErr             error
+	TerminateGracePeriod           time.Duration
+
+	SampleUsageResult    boshsys.ProcessUsage
+	SampleUsageErr       error
+	SampleUsageCallCount int
+
+	PidResult int
+
+	Signals   []os.Signal
+	SignalErr error
 
 	Stdout io.Writer
 	Stderr io.Writer
@@ -78,6 +96,25 @@ func (p *FakeProcess) TerminateNicely(killGracePeriod time.Duration) error {
 	return p.TerminateNicelyErr
 }
 
+// Terminate behaves like TerminateNicely with the TerminateGracePeriod
+func (p *FakeProcess) Terminate() error {
+	return p.TerminateNicely(p.TerminateGracePeriod)
+}
+
+func (p *FakeProcess) SampleUsage() (boshsys.ProcessUsage, error) {
+	p.SampleUsageCallCount++
+	return p.SampleUsageResult, p.SampleUsageErr
+}
+
+func (p *FakeProcess) Pid() int {
+	return p.PidResult
+}
+
+func (p *FakeProcess) Signal(sig os.Signal) error {
+	p.Signals = append(p.Signals, sig)
+	return p.SignalErr
+}
+
 func NewFakeCmdRunner() *FakeCmdRunner {
 	return &FakeCmdRunner{
 		AvailableCommands:   map[string]bool{},
@@ -107,9 +144,24 @@ func (r *FakeCmdRunner) RunComplexCommand(cmd boshsys.Command) (string, string,
 		cmd.Stderr.Write([]byte(stderr))
 	}
 
+	callLineFunc(cmd.StdoutLineFunc, stdout)
+	callLineFunc(cmd.StderrLineFunc, stderr)
+
 	return stdout, stderr, exitstatus, err
 }
 
+func callLineFunc(lineFunc func(string), output string) {
+	if lineFunc == nil || output == "" {
+		return
+	}
+
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line != "" {
+			lineFunc(strings.TrimSuffix(line, "\n"))
+		}
+	}
+}
+
 func (r *FakeCmdRunner) RunComplexCommandAsync(cmd boshsys.Command) (boshsys.Process, error) {
 	r.processesLock.Lock()
 	defer r.processesLock.Unlock()
@@ -139,6 +191,47 @@ func (r *FakeCmdRunner) RunComplexCommandAsync(cmd boshsys.Command) (boshsys.Pro
 	panic(fmt.Sprintf("Failed to find available process for %s", fullCmd))
 }
 
+// RunPipeline looks up the results of every stage like RunComplexCommand.
+// Only the stdout of the last stage is returned.
+func (r *FakeCmdRunner) RunPipeline(cmds ...boshsys.Command) ([]boshsys.Result, error) {
+	r.commandResultsLock.Lock()
+	defer r.commandResultsLock.Unlock()
+
+	r.RunPipelines = append(r.RunPipelines, cmds)
+
+	var err error
+	results := make([]boshsys.Result, len(cmds))
+
+	for i, cmd := range cmds {
+		runCmd := append([]string{cmd.Name}, cmd.Args...)
+
+		r.runCallbackForCmd(runCmd)
+
+		stdout, stderr, exitStatus, stageErr := r.getOutputsForCmd(runCmd)
+		if i < len(cmds)-1 {
+			stdout = ""
+		}
+
+		if cmd.Stdout != nil {
+			cmd.Stdout.Write([]byte(stdout))
+			stdout = ""
+		}
+
+		if cmd.Stderr != nil {
+			cmd.Stderr.Write([]byte(stderr))
+			stderr = ""
+		}
+
+		results[i] = boshsys.Result{Stdout: stdout, Stderr: stderr, ExitStatus: exitStatus, Error: stageErr}
+
+		if err == nil {
+			err = stageErr
+		}
+	}
+
+	return results, err
+}
+
 func (r *FakeCmdRunner) RunCommand(cmdName string, args ...string) (string, string, int, error) {
 	r.commandResultsLock.Lock()
 	defer r.commandResultsLock.Unlock()
@@ -188,6 +281,20 @@ func (r *FakeCmdRunner) CommandExists(cmdName string) bool {
 	return r.CommandExistsValue || r.AvailableCommands[cmdName]
 }
 
+func (r *FakeCmdRunner) LookPath(cmdName string, opts boshsys.LookPathOpts) (boshsys.CommandLookup, error) {
+	r.LookPathOpts = append(r.LookPathOpts, opts)
+
+	if lookup, found := r.LookPathResults[cmdName]; found {
+		return lookup, nil
+	}
+
+	if !r.CommandExists(cmdName) {
+		return boshsys.CommandLookup{Name: cmdName}, boshsys.CommandNotFoundError{Name: cmdName, Err: errors.New("fake-not-found")}
+	}
+
+	return boshsys.CommandLookup{Name: cmdName, Path: "/usr/bin/" + cmdName}, nil
+}
+
 func (r *FakeCmdRunner) AddCmdResult(fullCmd string, result FakeCmdResult) {
 	r.commandResultsLock.Lock()
 	defer r.commandResultsLock.Unlock()
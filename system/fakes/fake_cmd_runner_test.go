@@ -53,4 +53,20 @@ var _ = Describe("FakeCmdRunner", func() {
 			Expect(runner.RunCommands).To(Equal([][]string{{"foo", "bar"}, {"foo", "bar"}}))
 		})
 	})
+	Describe("RunComplexCommand", func() {
+		It("passes the output lines to the line funcs", func() {
+			runner.AddCmdResult("foo", FakeCmdResult{Stdout: "line1\nline2\n", Stderr: "err1"})
+
+			var stdoutLines, stderrLines []string
+			_, _, _, err := runner.RunComplexCommand(Command{
+				Name:           "foo",
+				StdoutLineFunc: func(line string) { stdoutLines = append(stdoutLines, line) },
+				StderrLineFunc: func(line string) { stderrLines = append(stderrLines, line) },
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(stdoutLines).To(Equal([]string{"line1", "line2"}))
+			Expect(stderrLines).To(Equal([]string{"err1"}))
+		})
+	})
 })
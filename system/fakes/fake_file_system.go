@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	gopath "path"
 	"path/filepath"
@@ -47,6 +48,9 @@ type FakeFileSystem struct {
 	openFileRegistry *FakeFileRegistry
 	OpenFileErr      error
 
+	OpenFileWithShareModeCallCount int
+	OpenFileWithShareModeShareMode boshsys.ShareMode
+
 	ReadFileError             error
 	ReadFileWithOptsCallCount int
 	readFileErrorByPath       map[string]error
@@ -58,6 +62,9 @@ type FakeFileSystem struct {
 
 	SymlinkError error
 
+	HardlinkError     error
+	HardlinkCallCount int
+
 	MkdirAllError       error
 	mkdirAllErrorByPath map[string]error
 	MkdirAllCallCount   int
@@ -69,6 +76,8 @@ type FakeFileSystem struct {
 	ChmodErr       error
 	ChmodCallCount int
 
+	ChtimesErr error
+
 	CopyFileError     error
 	CopyFileCallCount int
 
@@ -81,6 +90,9 @@ type FakeFileSystem struct {
 
 	RemoveAllStub removeAllFn
 
+	RemoveOnRebootError error
+	RemoveOnRebootPaths []string
+
 	ReadAndFollowLinkError error
 	ReadlinkError          error
 
@@ -88,6 +100,7 @@ type FakeFileSystem struct {
 	StatCallCount         int
 
 	TempFileError           error
+	TempFileInMemoryError   error
 	TempFileErrorsByPrefix  map[string]error
 	ReturnTempFile          boshsys.File
 	ReturnTempFiles         []boshsys.File
@@ -97,6 +110,10 @@ type FakeFileSystem struct {
 	TempDirDirs  []string
 	TempDirError error
 
+	TempDirWithSpaceErr error
+	DiskSpaceErr        error
+	diskSpaces          map[string]boshsys.DiskSpace
+
 	GlobErr  error
 	GlobStub globFn
 	GlobErrs map[string]error
@@ -104,8 +121,23 @@ type FakeFileSystem struct {
 
 	WalkErr error
 
+	IsReadOnlyErr error
+	readOnlyPaths map[string]bool
+
+	StatxErr error
+
 	TempRootPath   string
 	strictTempRoot bool
+
+	// TempNameFunc names temp files and dirs that are not configured through
+	// ReturnTempFile(s) or TempDirDir(s). n is increased until the name is
+	// not used yet. Defaults to <temp root>/<prefix><n>.
+	TempNameFunc    func(prefix string, n int) string
+	tempNameCounter int
+	tempPaths       map[string]bool
+
+	events     []FakeFileSystemEvent
+	eventState map[string]FakeFileStats
 }
 
 type FakeFileStats struct {
@@ -116,8 +148,10 @@ type FakeFileStats struct {
 	Username  string
 	Groupname string
 
-	ModTime time.Time
-	Open    bool
+	ModTime    time.Time
+	AccessTime time.Time
+	BirthTime  time.Time
+	Open       bool
 
 	SymlinkTarget string
 
@@ -165,6 +199,9 @@ type FakeFile struct {
 	CloseErr error
 
 	StatErr error
+
+	SyncErr       error
+	SyncCallCount int
 }
 
 func NewFakeFile(path string, fs *FakeFileSystem) *FakeFile {
@@ -192,6 +229,7 @@ func (f *FakeFile) Write(contents []byte) (int, error) {
 
 	f.fs.filesLock.Lock()
 	defer f.fs.filesLock.Unlock()
+	defer f.fs.recordEvent("Write", nil, f.path)
 
 	stats := f.fs.getOrCreateFile(f.path)
 	stats.Content = contents
@@ -234,6 +272,11 @@ func (f *FakeFile) Close() error {
 	return f.CloseErr
 }
 
+func (f *FakeFile) Sync() error {
+	f.SyncCallCount++
+	return f.SyncErr
+}
+
 func (f FakeFile) Stat() (os.FileInfo, error) {
 	return FakeFileInfo{file: f}, f.StatErr
 }
@@ -248,6 +291,9 @@ func NewFakeFileSystem() *FakeFileSystem {
 		mkdirAllErrorByPath:    map[string]error{},
 		WriteFileErrors:        map[string]error{},
 		TempFileErrorsByPrefix: map[string]error{},
+		readOnlyPaths:          map[string]bool{},
+		diskSpaces:             map[string]boshsys.DiskSpace{},
+		tempPaths:              map[string]bool{},
 	}
 }
 
@@ -280,10 +326,11 @@ func (fs *FakeFileSystem) RegisterMkdirAllError(path string, err error) {
 	fs.mkdirAllErrorByPath[path] = err
 }
 
-func (fs *FakeFileSystem) MkdirAll(path string, perm os.FileMode) error {
+func (fs *FakeFileSystem) MkdirAll(path string, perm os.FileMode) (err error) {
 	fs.MkdirAllCallCount++
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("MkdirAll", &err, path)
 
 	if fs.MkdirAllError != nil {
 		return fs.MkdirAllError
@@ -295,6 +342,10 @@ func (fs *FakeFileSystem) MkdirAll(path string, perm os.FileMode) error {
 		return fs.mkdirAllErrorByPath[path]
 	}
 
+	if err := fs.readOnlyErr(path); err != nil {
+		return err
+	}
+
 	return fs.mkdir(path, perm)
 }
 
@@ -350,28 +401,41 @@ func (fs *FakeFileSystem) FindFileStats(path string) (*FakeFileStats, error) {
 	return nil, fmt.Errorf("Path does not exist: %s", path)
 }
 
-func (fs *FakeFileSystem) OpenFile(path string, flag int, perm os.FileMode) (boshsys.File, error) {
+func (fs *FakeFileSystem) OpenFile(path string, flag int, perm os.FileMode) (file boshsys.File, err error) {
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("OpenFile", &err, path)
 
 	if fs.OpenFileErr != nil {
 		return nil, fs.OpenFileErr
 	}
 
-	// Make sure to record a reference for FileExist, etc. to work
-	stats := fs.getOrCreateFile(path)
-	stats.FileMode = perm
-	stats.Flags = flag
-	stats.FileType = FakeFileTypeFile
+	// Opening an existing file or dir without O_CREATE leaves it as is
+	if existing := fs.fileRegistry.Get(path); existing == nil || flag&os.O_CREATE != 0 {
+		// Make sure to record a reference for FileExist, etc. to work
+		stats := fs.getOrCreateFile(path)
+		stats.FileMode = perm
+		stats.Flags = flag
+		stats.FileType = FakeFileTypeFile
+	}
 
 	openFile := fs.openFileRegistry.Get(path)
 	if openFile != nil {
 		return openFile, nil
 	}
-	file := NewFakeFile(path, fs)
+	fakeFile := NewFakeFile(path, fs)
 
-	fs.RegisterOpenFile(path, file)
-	return file, nil
+	fs.RegisterOpenFile(path, fakeFile)
+	return fakeFile, nil
+}
+
+func (fs *FakeFileSystem) OpenFileWithShareMode(path string, flag int, perm os.FileMode, share boshsys.ShareMode) (boshsys.File, error) {
+	fs.filesLock.Lock()
+	fs.OpenFileWithShareModeCallCount++
+	fs.OpenFileWithShareModeShareMode = share
+	fs.filesLock.Unlock()
+
+	return fs.OpenFile(path, flag, perm)
 }
 
 func (fs *FakeFileSystem) Stat(path string) (os.FileInfo, error) {
@@ -462,10 +526,53 @@ func (fs *FakeFileSystem) Lstat(path string) (os.FileInfo, error) {
 	return NewFakeFile(path, fs).Stat()
 }
 
-func (fs *FakeFileSystem) Chown(path, username string) error {
+func (fs *FakeFileSystem) Statx(path string) (boshsys.FileStat, error) {
+	if fs.StatxErr != nil {
+		return boshsys.FileStat{}, fs.StatxErr
+	}
+
+	info, err := fs.StatHelper(path)
+	if err != nil {
+		return boshsys.FileStat{}, err
+	}
+
+	return fs.fileStat(path, info), nil
+}
+
+func (fs *FakeFileSystem) Lstatx(path string) (boshsys.FileStat, error) {
+	if fs.StatxErr != nil {
+		return boshsys.FileStat{}, fs.StatxErr
+	}
+
+	info, err := fs.Lstat(path)
+	if err != nil {
+		return boshsys.FileStat{}, err
+	}
+
+	return fs.fileStat(path, info), nil
+}
+
+func (fs *FakeFileSystem) fileStat(path string, info os.FileInfo) boshsys.FileStat {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	stat := boshsys.FileStat{FileInfo: info, UID: -1, GID: -1, Nlink: 1}
+
+	if stats := fs.fileRegistry.Get(path); stats != nil {
+		stat.User = stats.Username
+		stat.Group = stats.Groupname
+		stat.AccessTime = stats.AccessTime
+		stat.BirthTime = stats.BirthTime
+	}
+
+	return stat
+}
+
+func (fs *FakeFileSystem) Chown(path, username string) (err error) {
 	fs.ChownCallCount++
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("Chown", &err, path, username)
 
 	// check early to avoid requiring file presence
 	if fs.ChownErr != nil {
@@ -486,10 +593,11 @@ func (fs *FakeFileSystem) Chown(path, username string) error {
 	return nil
 }
 
-func (fs *FakeFileSystem) Chmod(path string, perm os.FileMode) error {
+func (fs *FakeFileSystem) Chmod(path string, perm os.FileMode) (err error) {
 	fs.ChmodCallCount++
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("Chmod", &err, path, perm.String())
 
 	// check early to avoid requiring file presence
 	if fs.ChmodErr != nil {
@@ -505,25 +613,64 @@ func (fs *FakeFileSystem) Chmod(path string, perm os.FileMode) error {
 	return nil
 }
 
+// Chtimes sets the times of a registered file. Unlike osFileSystem it can
+// set the birth time on every platform.
+func (fs *FakeFileSystem) Chtimes(path string, times boshsys.FileTimes) (err error) {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("Chtimes", &err, path)
+
+	if fs.ChtimesErr != nil {
+		return fs.ChtimesErr
+	}
+
+	stats := fs.fileRegistry.Get(path)
+	if stats == nil {
+		return fmt.Errorf("Path does not exist: %s", path)
+	}
+
+	if !times.AccessTime.IsZero() {
+		stats.AccessTime = times.AccessTime
+	}
+	if !times.ModTime.IsZero() {
+		stats.ModTime = times.ModTime
+	}
+	if !times.BirthTime.IsZero() {
+		stats.BirthTime = times.BirthTime
+	}
+
+	return nil
+}
+
 func (fs *FakeFileSystem) WriteFileString(path, content string) error {
 	return fs.WriteFile(path, []byte(content))
 }
 
 func (fs *FakeFileSystem) WriteFileQuietly(path string, content []byte) error {
 	fs.WriteFileQuietlyCallCount++
-	return fs.writeFile(path, content)
+	return fs.writeFile(path, content, false)
 }
 
 func (fs *FakeFileSystem) WriteFile(path string, content []byte) error {
 	fs.WriteFileCallCount++
-	return fs.writeFile(path, content)
+	return fs.writeFile(path, content, false)
 }
 
-func (fs *FakeFileSystem) writeFile(path string, content []byte) error {
+func (fs *FakeFileSystem) WriteFileWithOpts(path string, content []byte, opts boshsys.WriteOpts) error {
+	if opts.Quiet {
+		fs.WriteFileQuietlyCallCount++
+	} else {
+		fs.WriteFileCallCount++
+	}
+	return fs.writeFile(path, content, opts.NoFollow)
+}
+
+func (fs *FakeFileSystem) writeFile(path string, content []byte, noFollow bool) (err error) {
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("WriteFile", &err, path)
 
-	err := fs.WriteFileError
+	err = fs.WriteFileError
 	if err != nil {
 		return err
 	}
@@ -533,6 +680,16 @@ func (fs *FakeFileSystem) writeFile(path string, content []byte) error {
 		return err
 	}
 
+	err = fs.readOnlyErr(path)
+	if err != nil {
+		return err
+	}
+
+	err = fs.noFollowErr(path, noFollow)
+	if err != nil {
+		return err
+	}
+
 	path = fs.fileRegistry.UnifiedPath(path)
 	parent := gopath.Dir(path)
 	if parent != "." {
@@ -545,6 +702,19 @@ func (fs *FakeFileSystem) writeFile(path string, content []byte) error {
 	return nil
 }
 
+func (fs *FakeFileSystem) noFollowErr(path string, noFollow bool) error {
+	if !noFollow {
+		return nil
+	}
+
+	stats := fs.fileRegistry.Get(path)
+	if stats != nil && stats.FileType == FakeFileTypeSymlink {
+		return boshsys.SymlinkError{Path: path}
+	}
+
+	return nil
+}
+
 func (fs *FakeFileSystem) writeDir(path string) error {
 	parent := gopath.Dir(path)
 
@@ -558,19 +728,25 @@ func (fs *FakeFileSystem) writeDir(path string) error {
 	return nil
 }
 
-func (fs *FakeFileSystem) ConvergeFileContents(path string, content []byte, opts ...boshsys.ConvergeFileContentsOpts) (bool, error) {
+func (fs *FakeFileSystem) ConvergeFileContents(path string, content []byte, opts ...boshsys.ConvergeFileContentsOpts) (changed bool, err error) {
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("ConvergeFileContents", &err, path)
 
 	if fs.WriteFileError != nil {
 		return false, fs.WriteFileError
 	}
 
-	err := fs.WriteFileErrors[path]
+	err = fs.WriteFileErrors[path]
 	if err != nil {
 		return false, err
 	}
 
+	err = fs.noFollowErr(path, len(opts) > 0 && opts[0].NoFollow)
+	if err != nil {
+		return true, err
+	}
+
 	if len(opts) > 0 && opts[0].DryRun {
 		stats := fs.fileRegistry.Get(path)
 		if stats == nil {
@@ -643,10 +819,52 @@ func (fs *FakeFileSystem) FileExists(path string) bool {
 	return fs.GetFileTestStat(path) != nil
 }
 
-func (fs *FakeFileSystem) Rename(oldPath, newPath string) error {
+// RegisterReadOnlyPath makes path and everything below it behave
+// as if it lived on a read-only filesystem
+func (fs *FakeFileSystem) RegisterReadOnlyPath(path string) {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	fs.readOnlyPaths[fs.fileRegistry.UnifiedPath(path)] = true
+}
+
+func (fs *FakeFileSystem) IsReadOnly(path string) (bool, error) {
+	if fs.IsReadOnlyErr != nil {
+		return false, fs.IsReadOnlyErr
+	}
+
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
 
+	return fs.isReadOnly(path), nil
+}
+
+func (fs *FakeFileSystem) isReadOnly(path string) bool {
+	path = fs.fileRegistry.UnifiedPath(path)
+	for {
+		if fs.readOnlyPaths[path] {
+			return true
+		}
+		parent := gopath.Dir(path)
+		if parent == path {
+			return false
+		}
+		path = parent
+	}
+}
+
+func (fs *FakeFileSystem) readOnlyErr(path string) error {
+	if fs.isReadOnly(path) {
+		return boshsys.NewReadOnlyFilesystemError(path, syscall.EROFS)
+	}
+	return nil
+}
+
+func (fs *FakeFileSystem) Rename(oldPath, newPath string) (err error) {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("Rename", &err, oldPath, newPath)
+
 	if fs.RenameStub != nil {
 		err := fs.RenameStub(oldPath, newPath)
 		if err != nil {
@@ -692,6 +910,7 @@ func (fs *FakeFileSystem) Rename(oldPath, newPath string) error {
 func (fs *FakeFileSystem) Symlink(oldPath, newPath string) (err error) {
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("Symlink", &err, oldPath, newPath)
 
 	if fs.SymlinkError == nil {
 		stats := fs.getOrCreateFile(newPath)
@@ -705,6 +924,35 @@ func (fs *FakeFileSystem) Symlink(oldPath, newPath string) (err error) {
 	return
 }
 
+func (fs *FakeFileSystem) Hardlink(oldPath, newPath string) (err error) {
+	fs.HardlinkCallCount++
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("Hardlink", &err, oldPath, newPath)
+
+	if fs.HardlinkError != nil {
+		return fs.HardlinkError
+	}
+
+	stats := fs.fileRegistry.Get(oldPath)
+	if stats == nil {
+		return fmt.Errorf("Path does not exist: %s", oldPath)
+	}
+
+	if stats.FileType == FakeFileTypeDir {
+		return fmt.Errorf("Cannot hardlink directory: %s", oldPath)
+	}
+
+	if fs.fileRegistry.Get(newPath) != nil {
+		return fmt.Errorf("Path already exists: %s", newPath)
+	}
+
+	// both paths share the same stats so that writes are visible through either
+	fs.fileRegistry.Register(newPath, stats)
+
+	return nil
+}
+
 func (fs *FakeFileSystem) ReadAndFollowLink(symlinkPath string) (string, error) {
 	targetPath, err := fs.readAndFollowLink(symlinkPath)
 	if err != nil {
@@ -768,10 +1016,11 @@ func (fs *FakeFileSystem) readAndFollowLink(symlinkPath string) (string, error)
 	return fs.readAndFollowLink(gopath.Join(dirPath, stat.SymlinkTarget))
 }
 
-func (fs *FakeFileSystem) CopyFile(srcPath, dstPath string) error {
+func (fs *FakeFileSystem) CopyFile(srcPath, dstPath string) (err error) {
 	fs.CopyFileCallCount++
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("CopyFile", &err, srcPath, dstPath)
 
 	if fs.CopyFileError != nil {
 		return fs.CopyFileError
@@ -786,9 +1035,16 @@ func (fs *FakeFileSystem) CopyFile(srcPath, dstPath string) error {
 	return nil
 }
 
-func (fs *FakeFileSystem) CopyDir(srcPath, dstPath string) error {
+// CopyFileWithOpts always keeps the times because copies share the stats
+// of their source
+func (fs *FakeFileSystem) CopyFileWithOpts(srcPath, dstPath string, _ boshsys.CopyFileOpts) error {
+	return fs.CopyFile(srcPath, dstPath)
+}
+
+func (fs *FakeFileSystem) CopyDir(srcPath, dstPath string) (err error) {
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("CopyDir", &err, srcPath, dstPath)
 
 	if fs.CopyDirError != nil {
 		return fs.CopyDirError
@@ -809,6 +1065,82 @@ func (fs *FakeFileSystem) CopyDir(srcPath, dstPath string) error {
 	return nil
 }
 
+// CopyDirWithOpts always keeps the times like CopyFileWithOpts
+func (fs *FakeFileSystem) CopyDirWithOpts(srcPath, dstPath string, _ boshsys.CopyFileOpts) error {
+	return fs.CopyDir(srcPath, dstPath)
+}
+
+// TempDirWithSpace checks the temp root and candidates against the disk
+// space registered with RegisterDiskSpace; the temp root itself is created
+// the same way as TempDir.
+func (fs *FakeFileSystem) TempDirWithSpace(prefix string, requiredBytes uint64, opts ...boshsys.TempDirWithSpaceOpts) (string, error) {
+	if fs.TempDirWithSpaceErr != nil {
+		return "", fs.TempDirWithSpaceErr
+	}
+
+	var opt boshsys.TempDirWithSpaceOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	roots := append([]string{fs.TempRootPath}, opt.Candidates...)
+	spaceErr := boshsys.InsufficientSpaceError{RequiredBytes: requiredBytes, RequiredInodes: opt.RequiredInodes}
+
+	for i, root := range roots {
+		space, err := fs.DiskSpace(root)
+		if err == nil && space.Fits(requiredBytes, opt.RequiredInodes) {
+			if i == 0 {
+				return fs.TempDir(prefix)
+			}
+
+			uuid, err := gouuid.NewV4()
+			if err != nil {
+				return "", err
+			}
+
+			path := filepath.Join(root, prefix+uuid.String())
+			return path, fs.MkdirAll(path, os.ModePerm)
+		}
+
+		spaceErr.Candidates = append(spaceErr.Candidates, boshsys.TempRootCandidate{Path: root, Space: space, Err: err})
+	}
+
+	return "", spaceErr
+}
+
+// RegisterDiskSpace sets the space reported for path and everything below it.
+// Paths without registered space report unlimited space.
+func (fs *FakeFileSystem) RegisterDiskSpace(path string, space boshsys.DiskSpace) {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	fs.diskSpaces[fs.fileRegistry.UnifiedPath(path)] = space
+}
+
+func (fs *FakeFileSystem) DiskSpace(path string) (boshsys.DiskSpace, error) {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	if fs.DiskSpaceErr != nil {
+		return boshsys.DiskSpace{}, fs.DiskSpaceErr
+	}
+
+	path = fs.fileRegistry.UnifiedPath(path)
+	for {
+		if space, found := fs.diskSpaces[path]; found {
+			return space, nil
+		}
+
+		parent := gopath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+
+	return boshsys.DiskSpace{TotalBytes: math.MaxUint64, AvailableBytes: math.MaxUint64}, nil
+}
+
 func (fs *FakeFileSystem) ChangeTempRoot(tempRootPath string) error {
 	if fs.ChangeTempRootErr != nil {
 		return fs.ChangeTempRootErr
@@ -824,6 +1156,7 @@ func (fs *FakeFileSystem) EnableStrictTempRootBehavior() {
 func (fs *FakeFileSystem) TempFile(prefix string) (file boshsys.File, err error) {
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("TempFile", &err, prefix)
 
 	if fs.TempFileError != nil {
 		return nil, fs.TempFileError
@@ -845,22 +1178,31 @@ func (fs *FakeFileSystem) TempFile(prefix string) (file boshsys.File, err error)
 		file = fs.ReturnTempFiles[0]
 		fs.ReturnTempFiles = fs.ReturnTempFiles[1:]
 	} else {
-		file, err = os.Open(os.DevNull)
-		if err != nil {
-			err = bosherr.WrapError(err, fmt.Sprintf("Opening %s", os.DevNull))
-			return
-		}
+		path := fs.uniqueTempPath(prefix)
+		fs.getOrCreateFile(path).FileType = FakeFileTypeFile
+		file = NewFakeFile(path, fs)
 	}
 
 	// Make sure to record a reference for FileExist, etc. to work
 	stats := fs.getOrCreateFile(file.Name())
 	stats.FileType = FakeFileTypeFile
+	fs.tempPaths[file.Name()] = true
 	return
 }
 
-func (fs *FakeFileSystem) TempDir(prefix string) (string, error) {
+// TempFileInMemory returns temp files like TempFile
+func (fs *FakeFileSystem) TempFileInMemory(prefix string) (boshsys.File, error) {
+	if fs.TempFileInMemoryError != nil {
+		return nil, fs.TempFileInMemoryError
+	}
+
+	return fs.TempFile(prefix)
+}
+
+func (fs *FakeFileSystem) TempDir(prefix string) (path string, err error) {
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("TempDir", &err, prefix)
 
 	if fs.TempDirError != nil {
 		return "", fs.TempDirError
@@ -870,7 +1212,6 @@ func (fs *FakeFileSystem) TempDir(prefix string) (string, error) {
 		return "", errors.New("Temp file was requested without having set a temp root")
 	}
 
-	var path string
 	if len(fs.TempDirDir) > 0 {
 		path = fs.TempDirDir
 	} else if fs.TempDirDirs != nil {
@@ -880,22 +1221,77 @@ func (fs *FakeFileSystem) TempDir(prefix string) (string, error) {
 		path = fs.TempDirDirs[0]
 		fs.TempDirDirs = fs.TempDirDirs[1:]
 	} else {
-		uuid, err := gouuid.NewV4()
-		if err != nil {
-			return "", err
-		}
-
-		path = uuid.String()
+		path = fs.uniqueTempPath(prefix)
 	}
 
 	// Make sure to record a reference for FileExist, etc. to work
 	stats := fs.getOrCreateFile(path)
 	stats.FileType = FakeFileTypeDir
+	fs.tempPaths[path] = true
 
 	return path, nil
 }
 
-func (fs *FakeFileSystem) RemoveAll(path string) error {
+// uniqueTempPath must be called with filesLock held so that concurrent
+// callers never get the same name
+func (fs *FakeFileSystem) uniqueTempPath(prefix string) string {
+	nameFunc := fs.TempNameFunc
+	if nameFunc == nil {
+		root := fs.TempRootPath
+		if root == "" {
+			root = os.TempDir()
+		}
+
+		nameFunc = func(prefix string, n int) string {
+			return filepath.Join(root, fmt.Sprintf("%s%d", prefix, n))
+		}
+	}
+
+	for {
+		fs.tempNameCounter++
+
+		path := nameFunc(prefix, fs.tempNameCounter)
+		if !fs.tempPaths[path] && fs.fileRegistry.Get(path) == nil {
+			return path
+		}
+	}
+}
+
+// LeakedTempPaths returns temp files and dirs created by TempFile and TempDir
+// that were not removed yet
+func (fs *FakeFileSystem) LeakedTempPaths() []string {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	leaked := []string{}
+	for path := range fs.tempPaths {
+		if fs.fileRegistry.Get(path) != nil {
+			leaked = append(leaked, path)
+		}
+	}
+
+	sort.Strings(leaked)
+
+	return leaked
+}
+
+// TestingT is satisfied by *testing.T and GinkgoT()
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertNoLeaks fails t when temp files or dirs were not removed
+func (fs *FakeFileSystem) AssertNoLeaks(t TestingT) {
+	t.Helper()
+
+	leaked := fs.LeakedTempPaths()
+	if len(leaked) > 0 {
+		t.Errorf("Expected all temp files and dirs to be removed, but found: %s", strings.Join(leaked, ", "))
+	}
+}
+
+func (fs *FakeFileSystem) RemoveAll(path string) (err error) {
 	if path == "" {
 		panic("RemoveAll requires path")
 	}
@@ -909,11 +1305,29 @@ func (fs *FakeFileSystem) RemoveAll(path string) error {
 
 	fs.filesLock.Lock()
 	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("RemoveAll", &err, path)
 
 	path = fs.fileRegistry.UnifiedPath(path)
 	return fs.removeAll(path)
 }
 
+// RemoveOnReboot removes the file right away like the Unix implementation
+func (fs *FakeFileSystem) RemoveOnReboot(path string) (err error) {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+	defer fs.recordEvent("RemoveOnReboot", &err, path)
+
+	fs.RemoveOnRebootPaths = append(fs.RemoveOnRebootPaths, path)
+
+	if fs.RemoveOnRebootError != nil {
+		return fs.RemoveOnRebootError
+	}
+
+	fs.fileRegistry.Remove(fs.fileRegistry.UnifiedPath(path))
+
+	return nil
+}
+
 func (fs *FakeFileSystem) removeAll(path string) error {
 	fileInfo := fs.fileRegistry.Get(path)
 	if fileInfo != nil {
@@ -0,0 +1,224 @@
+package fakes
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FakeFileSystemEvent is an operation that may have changed the fake file
+// system, recorded once EnableEventLog was called
+type FakeFileSystemEvent struct {
+	Seq     int
+	Op      string
+	Args    []string
+	Err     error
+	Changes []FakeFileSystemChange
+}
+
+// FakeFileSystemChange describes how a path changed during an event.
+// Before is nil for created paths and After is nil for removed paths.
+type FakeFileSystemChange struct {
+	Path   string
+	Before *FakeFileStats
+	After  *FakeFileStats
+}
+
+// EnableEventLog starts recording every operation that may change the
+// file system. The current files are recorded as event 0 so that
+// StateAt can rebuild the state after any later event.
+func (fs *FakeFileSystem) EnableEventLog() {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	fs.events = nil
+	fs.eventState = map[string]FakeFileStats{}
+	fs.recordEvent("EnableEventLog", nil)
+}
+
+// Events returns the recorded events in the order they happened
+func (fs *FakeFileSystem) Events() []FakeFileSystemEvent {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	return append([]FakeFileSystemEvent(nil), fs.events...)
+}
+
+// StateAt replays the changes of events 0 to seq and returns the files
+// as they were right after event seq
+func (fs *FakeFileSystem) StateAt(seq int) (map[string]FakeFileStats, error) {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	return fs.stateAt(seq)
+}
+
+// FormatEvents lists every recorded event with the paths it changed
+func (fs *FakeFileSystem) FormatEvents() string {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	var b strings.Builder
+
+	for _, event := range fs.events {
+		fmt.Fprintf(&b, "#%d %s", event.Seq, event.Op)
+		for _, arg := range event.Args {
+			fmt.Fprintf(&b, " %q", arg)
+		}
+		if event.Err != nil {
+			fmt.Fprintf(&b, " -> error: %s", event.Err)
+		}
+		b.WriteString("\n")
+
+		for _, change := range event.Changes {
+			switch {
+			case change.Before == nil:
+				fmt.Fprintf(&b, "  + %s %s\n", change.Path, formatFakeFileStats(*change.After))
+			case change.After == nil:
+				fmt.Fprintf(&b, "  - %s\n", change.Path)
+			default:
+				fmt.Fprintf(&b, "  ~ %s %s\n", change.Path, formatFakeFileStats(*change.After))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// FormatStateAt lists the files as they were right after event seq
+func (fs *FakeFileSystem) FormatStateAt(seq int) (string, error) {
+	fs.filesLock.Lock()
+	defer fs.filesLock.Unlock()
+
+	state, err := fs.stateAt(seq)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, path := range sortedStatePaths(state) {
+		fmt.Fprintf(&b, "%s %s\n", path, formatFakeFileStats(state[path]))
+	}
+
+	return b.String(), nil
+}
+
+func (fs *FakeFileSystem) stateAt(seq int) (map[string]FakeFileStats, error) {
+	if seq < 0 || seq >= len(fs.events) {
+		return nil, fmt.Errorf("Event %d was not recorded, %d events are available", seq, len(fs.events))
+	}
+
+	state := map[string]FakeFileStats{}
+	for _, event := range fs.events[:seq+1] {
+		for _, change := range event.Changes {
+			if change.After == nil {
+				delete(state, change.Path)
+			} else {
+				state[change.Path] = copyFakeFileStats(*change.After)
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// recordEvent must be called with filesLock held. It compares the files
+// with the state after the previous event to find out what changed.
+func (fs *FakeFileSystem) recordEvent(op string, err *error, args ...string) {
+	if fs.eventState == nil {
+		return
+	}
+
+	event := FakeFileSystemEvent{Seq: len(fs.events), Op: op, Args: args}
+	if err != nil {
+		event.Err = *err
+	}
+
+	files := fs.fileRegistry.GetAll()
+
+	for path, stats := range files {
+		after := copyFakeFileStats(*stats)
+
+		before, found := fs.eventState[path]
+		if found && sameFakeFileStats(before, after) {
+			continue
+		}
+
+		change := FakeFileSystemChange{Path: path, After: &after}
+		if found {
+			change.Before = &before
+		}
+		event.Changes = append(event.Changes, change)
+
+		fs.eventState[path] = after
+	}
+
+	for path, stats := range fs.eventState {
+		if _, found := files[path]; !found {
+			before := stats
+			event.Changes = append(event.Changes, FakeFileSystemChange{Path: path, Before: &before})
+			delete(fs.eventState, path)
+		}
+	}
+
+	sort.Slice(event.Changes, func(i, j int) bool {
+		return event.Changes[i].Path < event.Changes[j].Path
+	})
+
+	fs.events = append(fs.events, event)
+}
+
+// copyFakeFileStats does not keep whether the file is open since
+// closing a file is not recorded as an event
+func copyFakeFileStats(stats FakeFileStats) FakeFileStats {
+	stats.Open = false
+	if stats.Content != nil {
+		stats.Content = append([]byte(nil), stats.Content...)
+	}
+	return stats
+}
+
+func sameFakeFileStats(a, b FakeFileStats) bool {
+	return a.FileType == b.FileType &&
+		a.FileMode == b.FileMode &&
+		a.Flags == b.Flags &&
+		a.Username == b.Username &&
+		a.Groupname == b.Groupname &&
+		a.ModTime.Equal(b.ModTime) &&
+		a.AccessTime.Equal(b.AccessTime) &&
+		a.BirthTime.Equal(b.BirthTime) &&
+		a.SymlinkTarget == b.SymlinkTarget &&
+		bytes.Equal(a.Content, b.Content)
+}
+
+func formatFakeFileStats(stats FakeFileStats) string {
+	desc := fmt.Sprintf("%s %s", stats.FileType, stats.FileMode)
+
+	if stats.Username != "" || stats.Groupname != "" {
+		desc += fmt.Sprintf(" %s:%s", stats.Username, stats.Groupname)
+	}
+
+	switch stats.FileType {
+	case FakeFileTypeSymlink:
+		desc += " -> " + stats.SymlinkTarget
+	case FakeFileTypeFile:
+		const maxContent = 40
+		if len(stats.Content) > maxContent {
+			desc += fmt.Sprintf(" %q... (%d bytes)", stats.Content[:maxContent], len(stats.Content))
+		} else {
+			desc += fmt.Sprintf(" %q", stats.Content)
+		}
+	}
+
+	return desc
+}
+
+func sortedStatePaths(state map[string]FakeFileStats) []string {
+	paths := make([]string, 0, len(state))
+	for path := range state {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
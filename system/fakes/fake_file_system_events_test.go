@@ -0,0 +1,188 @@
+package fakes_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("FakeFileSystem event log", func() {
+	var (
+		fs *FakeFileSystem
+	)
+
+	BeforeEach(func() {
+		fs = NewFakeFileSystem()
+	})
+
+	It("does not record events unless enabled", func() {
+		err := fs.WriteFileString("/a", "content")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fs.Events()).To(BeEmpty())
+	})
+
+	It("records the existing files as the first event", func() {
+		err := fs.WriteFileString("/dir/a", "content")
+		Expect(err).ToNot(HaveOccurred())
+
+		fs.EnableEventLog()
+
+		events := fs.Events()
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Op).To(Equal("EnableEventLog"))
+		Expect(events[0].Changes).To(HaveLen(2))
+		Expect(events[0].Changes[0].Path).To(Equal("/dir"))
+		Expect(events[0].Changes[1].Path).To(Equal("/dir/a"))
+		Expect(events[0].Changes[1].After.StringContents()).To(Equal("content"))
+	})
+
+	It("records the paths changed by each operation", func() {
+		fs.EnableEventLog()
+
+		Expect(fs.WriteFileString("/dir/a", "content")).To(Succeed())
+		Expect(fs.Rename("/dir/a", "/dir/b")).To(Succeed())
+		Expect(fs.Chmod("/dir/b", 0600)).To(Succeed())
+		Expect(fs.RemoveAll("/dir")).To(Succeed())
+
+		events := fs.Events()
+		Expect(events).To(HaveLen(5))
+
+		Expect(events[1].Op).To(Equal("WriteFile"))
+		Expect(events[1].Args).To(Equal([]string{"/dir/a"}))
+		Expect(events[1].Changes).To(HaveLen(2))
+
+		Expect(events[2].Op).To(Equal("Rename"))
+		Expect(events[2].Changes).To(HaveLen(2))
+		Expect(events[2].Changes[0].Path).To(Equal("/dir/a"))
+		Expect(events[2].Changes[0].After).To(BeNil())
+		Expect(events[2].Changes[1].Path).To(Equal("/dir/b"))
+		Expect(events[2].Changes[1].Before).To(BeNil())
+
+		Expect(events[3].Op).To(Equal("Chmod"))
+		Expect(events[3].Changes).To(HaveLen(1))
+		Expect(events[3].Changes[0].Before.FileMode).To(BeZero())
+		Expect(events[3].Changes[0].After.FileMode).To(BeEquivalentTo(0600))
+
+		Expect(events[4].Op).To(Equal("RemoveAll"))
+		Expect(events[4].Changes).To(HaveLen(2))
+	})
+
+	It("records failed operations with their error", func() {
+		fs.EnableEventLog()
+		fs.WriteFileError = errors.New("fake-write-error")
+
+		Expect(fs.WriteFileString("/a", "content")).ToNot(Succeed())
+
+		events := fs.Events()
+		Expect(events).To(HaveLen(2))
+		Expect(events[1].Err).To(MatchError("fake-write-error"))
+		Expect(events[1].Changes).To(BeEmpty())
+	})
+
+	It("records writes to open files", func() {
+		fs.EnableEventLog()
+
+		file, err := fs.OpenFile("/a", 0, 0644)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.Write([]byte("written"))
+		Expect(err).ToNot(HaveOccurred())
+
+		events := fs.Events()
+		Expect(events).To(HaveLen(3))
+		Expect(events[2].Op).To(Equal("Write"))
+		Expect(events[2].Changes[0].After.StringContents()).To(Equal("written"))
+	})
+
+	Describe("StateAt", func() {
+		It("rebuilds the files as they were after an event", func() {
+			Expect(fs.WriteFileString("/a", "first")).To(Succeed())
+			fs.EnableEventLog()
+
+			Expect(fs.WriteFileString("/a", "second")).To(Succeed())
+			Expect(fs.CopyFile("/a", "/b")).To(Succeed())
+			Expect(fs.RemoveAll("/a")).To(Succeed())
+
+			state, err := fs.StateAt(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(state).To(HaveLen(2))
+			Expect(state["/a"].StringContents()).To(Equal("first"))
+
+			state, err = fs.StateAt(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(state).To(HaveLen(3))
+			Expect(state["/a"].StringContents()).To(Equal("second"))
+			Expect(state["/b"].StringContents()).To(Equal("second"))
+
+			state, err = fs.StateAt(3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(state).To(HaveKey("/b"))
+			Expect(state).ToNot(HaveKey("/a"))
+		})
+
+		It("is not affected by later changes to the same file", func() {
+			fs.EnableEventLog()
+
+			file, err := fs.OpenFile("/a", 0, 0644)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = file.Write([]byte("first"))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = file.Write([]byte("second"))
+			Expect(err).ToNot(HaveOccurred())
+
+			state, err := fs.StateAt(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(state["/a"].StringContents()).To(Equal("first"))
+		})
+
+		It("returns an error for events that were not recorded", func() {
+			fs.EnableEventLog()
+
+			_, err := fs.StateAt(1)
+			Expect(err).To(MatchError(ContainSubstring("Event 1 was not recorded")))
+		})
+	})
+
+	Describe("FormatEvents", func() {
+		It("lists the events with their changes", func() {
+			fs.EnableEventLog()
+
+			Expect(fs.WriteFileString("/a", "content")).To(Succeed())
+			Expect(fs.Symlink("/a", "/link")).To(Succeed())
+			Expect(fs.Chown("/a", "vcap:admin")).To(Succeed())
+			fs.ChmodErr = errors.New("fake-chmod-error")
+			Expect(fs.Chmod("/a", 0600)).ToNot(Succeed())
+			Expect(fs.RemoveAll("/a")).To(Succeed())
+
+			Expect(fs.FormatEvents()).To(Equal(`#0 EnableEventLog
+#1 WriteFile "/a"
+  + / dir ----------
+  + /a file ---------- "content"
+#2 Symlink "/a" "/link"
+  + /link symlink L--------- -> /a
+#3 Chown "/a" "vcap:admin"
+  ~ /a file ---------- vcap:admin "content"
+#4 Chmod "/a" "-rw-------" -> error: fake-chmod-error
+#5 RemoveAll "/a"
+  - /a
+`))
+		})
+	})
+
+	Describe("FormatStateAt", func() {
+		It("lists the files after an event", func() {
+			fs.EnableEventLog()
+
+			Expect(fs.MkdirAll("/dir", 0755)).To(Succeed())
+			Expect(fs.WriteFileString("/dir/a", "0123456789012345678901234567890123456789extra")).To(Succeed())
+
+			Expect(fs.FormatStateAt(2)).To(Equal(`/ dir -rwxr-xr-x
+/dir dir -rwxr-xr-x
+/dir/a file ---------- "0123456789012345678901234567890123456789"... (45 bytes)
+`))
+		})
+	})
+})
@@ -2,8 +2,10 @@ package fakes_test
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -13,6 +15,16 @@ import (
 	. "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
+type fakeTestingT struct {
+	errors []string
+}
+
+func (t *fakeTestingT) Helper() {}
+
+func (t *fakeTestingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
 var _ = Describe("FakeFileSystem", func() {
 	var (
 		fs *FakeFileSystem
@@ -22,6 +34,44 @@ var _ = Describe("FakeFileSystem", func() {
 		fs = NewFakeFileSystem()
 	})
 
+	Describe("IsReadOnly", func() {
+		It("returns false by default", func() {
+			readOnly, err := fs.IsReadOnly("/potato")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readOnly).To(BeFalse())
+		})
+
+		It("returns true for registered read-only paths and their children", func() {
+			fs.RegisterReadOnlyPath("/var/vcap")
+
+			readOnly, err := fs.IsReadOnly("/var/vcap/data")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readOnly).To(BeTrue())
+
+			readOnly, err = fs.IsReadOnly("/var/other")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readOnly).To(BeFalse())
+		})
+
+		It("fails writes below read-only paths with a ReadOnlyFilesystemError", func() {
+			fs.RegisterReadOnlyPath("/var/vcap")
+
+			err := fs.WriteFileString("/var/vcap/file", "content")
+			Expect(boshsys.IsReadOnlyFilesystemError(err)).To(BeTrue())
+
+			err = fs.MkdirAll("/var/vcap/dir", 0750)
+			Expect(boshsys.IsReadOnlyFilesystemError(err)).To(BeTrue())
+			Expect(fs.FileExists("/var/vcap/dir")).To(BeFalse())
+		})
+
+		It("returns IsReadOnlyErr when set", func() {
+			fs.IsReadOnlyErr = errors.New("fake-err")
+
+			_, err := fs.IsReadOnly("/potato")
+			Expect(err).To(MatchError("fake-err"))
+		})
+	})
+
 	Describe("MkdirAll", func() {
 		It("creates a directory", func() {
 			err := fs.MkdirAll("/potato", 0750)
@@ -107,6 +157,40 @@ var _ = Describe("FakeFileSystem", func() {
 		})
 	})
 
+	Describe("RemoveOnReboot", func() {
+		It("removes the file and records the path", func() {
+			fs.WriteFileString("/fake-bin", "fake-content")
+
+			Expect(fs.RemoveOnReboot("/fake-bin")).To(Succeed())
+			Expect(fs.FileExists("/fake-bin")).To(BeFalse())
+			Expect(fs.RemoveOnRebootPaths).To(Equal([]string{"/fake-bin"}))
+		})
+
+		It("returns the configured error", func() {
+			fs.WriteFileString("/fake-bin", "fake-content")
+			fs.RemoveOnRebootError = errors.New("fake-err")
+
+			Expect(fs.RemoveOnReboot("/fake-bin")).To(MatchError("fake-err"))
+			Expect(fs.FileExists("/fake-bin")).To(BeTrue())
+		})
+	})
+
+	Describe("WriteFileWithOpts", func() {
+		It("refuses to write through symlinks with NoFollow", func() {
+			fs.WriteFileString("/fake-target", "fake-content")
+			Expect(fs.Symlink("/fake-target", "/fake-link")).To(Succeed())
+
+			err := fs.WriteFileWithOpts("/fake-link", []byte("new-content"), boshsys.WriteOpts{NoFollow: true})
+			Expect(boshsys.IsSymlinkError(err)).To(BeTrue())
+
+			_, err = fs.ConvergeFileContents("/fake-link", []byte("new-content"), boshsys.ConvergeFileContentsOpts{NoFollow: true})
+			Expect(boshsys.IsSymlinkError(err)).To(BeTrue())
+
+			Expect(fs.WriteFileWithOpts("/fake-other", []byte("new-content"), boshsys.WriteOpts{NoFollow: true})).To(Succeed())
+			Expect(fs.ReadFileString("/fake-other")).To(Equal("new-content"))
+		})
+	})
+
 	Describe("RemoveAll", func() {
 		It("removes the specified file", func() {
 			fs.WriteFileString("foobar", "asdfghjk")
@@ -344,6 +428,59 @@ var _ = Describe("FakeFileSystem", func() {
 		})
 	})
 
+	Describe("OpenFileWithShareMode", func() {
+		It("opens the file and records the share mode", func() {
+			file, err := fs.OpenFileWithShareMode("/file", os.O_CREATE|os.O_WRONLY, 0644, boshsys.ShareRead|boshsys.ShareDelete)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = file.Write([]byte("contents"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.ReadFileString("/file")).To(Equal("contents"))
+			Expect(fs.OpenFileWithShareModeCallCount).To(Equal(1))
+			Expect(fs.OpenFileWithShareModeShareMode).To(Equal(boshsys.ShareRead | boshsys.ShareDelete))
+		})
+	})
+
+	Describe("Hardlink", func() {
+		It("shares contents between both paths", func() {
+			err := fs.WriteFileString("/original", "contents")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = fs.Hardlink("/original", "/link")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = fs.WriteFileString("/link", "new contents")
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err := fs.ReadFileString("/original")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(Equal("new contents"))
+			Expect(fs.HardlinkCallCount).To(Equal(1))
+		})
+
+		It("fails when the new path exists", func() {
+			fs.WriteFileString("/original", "contents")
+			fs.WriteFileString("/link", "other")
+
+			err := fs.Hardlink("/original", "/link")
+			Expect(err).To(MatchError("Path already exists: /link"))
+		})
+
+		It("fails for directories and missing paths", func() {
+			fs.MkdirAll("/dir", 0750)
+
+			Expect(fs.Hardlink("/dir", "/link")).To(MatchError("Cannot hardlink directory: /dir"))
+			Expect(fs.Hardlink("/missing", "/link")).To(MatchError("Path does not exist: /missing"))
+		})
+
+		It("returns HardlinkError when set", func() {
+			fs.HardlinkError = errors.New("fake-err")
+
+			Expect(fs.Hardlink("/original", "/link")).To(MatchError("fake-err"))
+		})
+	})
+
 	Describe("ReadAndFollowLink", func() {
 		Context("when the target exists", func() {
 			It("returns the target", func() {
@@ -701,6 +838,167 @@ var _ = Describe("FakeFileSystem", func() {
 		})
 	})
 
+	Describe("Statx", func() {
+		It("returns file info with the owner names of the file", func() {
+			err := fs.WriteFileString("/file", "content")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.Chown("/file", "vcap:vcap-group")).To(Succeed())
+
+			stat, err := fs.Statx("/file")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat.Size()).To(Equal(int64(7)))
+			Expect(stat.User).To(Equal("vcap"))
+			Expect(stat.Group).To(Equal("vcap-group"))
+		})
+
+		It("returns StatxErr when set", func() {
+			fs.StatxErr = errors.New("fake-statx-err")
+
+			_, err := fs.Lstatx("/file")
+			Expect(err).To(MatchError("fake-statx-err"))
+		})
+	})
+
+	Describe("Chtimes", func() {
+		It("sets the non-zero times of the file", func() {
+			Expect(fs.WriteFileString("/file", "content")).To(Succeed())
+			modTime := time.Date(2021, 6, 7, 8, 9, 10, 11, time.UTC)
+			birthTime := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+
+			Expect(fs.Chtimes("/file", boshsys.FileTimes{ModTime: modTime, BirthTime: birthTime})).To(Succeed())
+
+			stat, err := fs.Statx("/file")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat.ModTime()).To(Equal(modTime))
+			Expect(stat.BirthTime).To(Equal(birthTime))
+			Expect(stat.AccessTime.IsZero()).To(BeTrue())
+		})
+
+		It("fails for missing files", func() {
+			Expect(fs.Chtimes("/missing", boshsys.FileTimes{ModTime: time.Now()})).ToNot(Succeed())
+		})
+
+		It("returns ChtimesErr when set", func() {
+			fs.ChtimesErr = errors.New("fake-chtimes-err")
+
+			Expect(fs.Chtimes("/file", boshsys.FileTimes{})).To(MatchError("fake-chtimes-err"))
+		})
+	})
+
+	Describe("TempDirWithSpace", func() {
+		It("uses the temp root when no disk space is registered", func() {
+			fs.TempDirDir = "/fake-temp-dir"
+
+			path, err := fs.TempDirWithSpace("fake-prefix", 1024)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal("/fake-temp-dir"))
+		})
+
+		It("falls back to candidates with enough space", func() {
+			Expect(fs.ChangeTempRoot("/tmp")).To(Succeed())
+			fs.RegisterDiskSpace("/tmp", boshsys.DiskSpace{AvailableBytes: 10})
+			fs.RegisterDiskSpace("/var/vcap/data", boshsys.DiskSpace{AvailableBytes: 2048})
+
+			path, err := fs.TempDirWithSpace("fake-prefix", 1024, boshsys.TempDirWithSpaceOpts{Candidates: []string{"/var/vcap/data/tmp"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(HavePrefix("/var/vcap/data/tmp/fake-prefix"))
+			Expect(fs.FileExists(path)).To(BeTrue())
+		})
+
+		It("returns an InsufficientSpaceError when no candidate fits", func() {
+			Expect(fs.ChangeTempRoot("/tmp")).To(Succeed())
+			fs.RegisterDiskSpace("/tmp", boshsys.DiskSpace{AvailableBytes: 10})
+
+			_, err := fs.TempDirWithSpace("fake-prefix", 1024)
+			Expect(err).To(Equal(boshsys.InsufficientSpaceError{
+				RequiredBytes: 1024,
+				Candidates:    []boshsys.TempRootCandidate{{Path: "/tmp", Space: boshsys.DiskSpace{AvailableBytes: 10}}},
+			}))
+			Expect(err.Error()).To(Equal("No temp root has 1024 bytes available: '/tmp' has 10 bytes available (1014 bytes short)"))
+		})
+	})
+
+	Describe("TempFile and TempDir", func() {
+		It("returns unique names under concurrent use", func() {
+			Expect(fs.ChangeTempRoot("/tmp")).To(Succeed())
+
+			paths := make(chan string, 100)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					file, err := fs.TempFile("fake-prefix")
+					Expect(err).ToNot(HaveOccurred())
+					paths <- file.Name()
+
+					dir, err := fs.TempDir("fake-prefix")
+					Expect(err).ToNot(HaveOccurred())
+					paths <- dir
+				}()
+			}
+			wg.Wait()
+			close(paths)
+
+			unique := map[string]bool{}
+			for path := range paths {
+				Expect(path).To(HavePrefix("/tmp/fake-prefix"))
+				unique[path] = true
+			}
+			Expect(unique).To(HaveLen(100))
+		})
+
+		It("records contents written to temp files", func() {
+			file, err := fs.TempFile("fake-prefix")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = file.Write([]byte("fake-contents"))
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err := fs.ReadFileString(file.Name())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(Equal("fake-contents"))
+		})
+
+		It("names temp files and dirs with TempNameFunc and skips existing names", func() {
+			fs.TempNameFunc = func(prefix string, n int) string {
+				return fmt.Sprintf("/scratch/%s-%03d", prefix, n)
+			}
+			Expect(fs.WriteFileString("/scratch/fake-prefix-001", "existing")).To(Succeed())
+
+			dir, err := fs.TempDir("fake-prefix")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dir).To(Equal("/scratch/fake-prefix-002"))
+
+			file, err := fs.TempFile("fake-prefix")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Name()).To(Equal("/scratch/fake-prefix-003"))
+		})
+
+		It("reports temp files and dirs that were not removed", func() {
+			file, err := fs.TempFile("fake-prefix")
+			Expect(err).ToNot(HaveOccurred())
+			dir, err := fs.TempDir("fake-prefix")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.RemoveAll(dir)).To(Succeed())
+			Expect(fs.LeakedTempPaths()).To(Equal([]string{file.Name()}))
+
+			t := &fakeTestingT{}
+			fs.AssertNoLeaks(t)
+			Expect(t.errors).To(Equal([]string{"Expected all temp files and dirs to be removed, but found: " + file.Name()}))
+
+			Expect(fs.RemoveAll(file.Name())).To(Succeed())
+
+			t = &fakeTestingT{}
+			fs.AssertNoLeaks(t)
+			Expect(t.errors).To(BeEmpty())
+		})
+	})
+
 	Describe("ConvergeFileContents", func() {
 		It("converges file contents", func() {
 			err := fs.WriteFileString("/file", "content1")
@@ -0,0 +1,93 @@
+package fakes
+
+import (
+	"strings"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// FakeHostsFile keeps entries in memory and follows the same rules
+// for adding and removing hostnames as the real hosts file
+type FakeHostsFile struct {
+	HostsEntries []boshsys.HostsEntry
+
+	EntriesErr error
+	AddErr     error
+	RemoveErr  error
+}
+
+func NewFakeHostsFile() *FakeHostsFile {
+	return &FakeHostsFile{}
+}
+
+func (h *FakeHostsFile) Entries() ([]boshsys.HostsEntry, error) {
+	return h.HostsEntries, h.EntriesErr
+}
+
+func (h *FakeHostsFile) Add(ip string, hostnames ...string) (bool, error) {
+	if h.AddErr != nil {
+		return false, h.AddErr
+	}
+
+	changed := h.remove(func(entryIP string) bool { return entryIP != ip }, hostnames)
+
+	for i, entry := range h.HostsEntries {
+		if entry.IP == ip {
+			for _, hostname := range hostnames {
+				if !containsHostname(entry.Hostnames, hostname) {
+					h.HostsEntries[i].Hostnames = append(h.HostsEntries[i].Hostnames, hostname)
+					changed = true
+				}
+			}
+			return changed, nil
+		}
+	}
+
+	h.HostsEntries = append(h.HostsEntries, boshsys.HostsEntry{IP: ip, Hostnames: hostnames})
+
+	return true, nil
+}
+
+func (h *FakeHostsFile) Remove(hostnames ...string) (bool, error) {
+	if h.RemoveErr != nil {
+		return false, h.RemoveErr
+	}
+
+	return h.remove(func(string) bool { return true }, hostnames), nil
+}
+
+func (h *FakeHostsFile) remove(matchesIP func(string) bool, hostnames []string) bool {
+	changed := false
+
+	var entries []boshsys.HostsEntry
+	for _, entry := range h.HostsEntries {
+		if matchesIP(entry.IP) {
+			var kept []string
+			for _, hostname := range entry.Hostnames {
+				if containsHostname(hostnames, hostname) {
+					changed = true
+				} else {
+					kept = append(kept, hostname)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			entry.Hostnames = kept
+		}
+		entries = append(entries, entry)
+	}
+
+	h.HostsEntries = entries
+
+	return changed
+}
+
+func containsHostname(hostnames []string, hostname string) bool {
+	for _, h := range hostnames {
+		if strings.EqualFold(h, hostname) {
+			return true
+		}
+	}
+	return false
+}
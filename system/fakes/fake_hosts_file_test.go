@@ -0,0 +1,40 @@
+package fakes_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	. "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("FakeHostsFile", func() {
+	It("adds and removes hostnames like the real hosts file", func() {
+		hostsFile := NewFakeHostsFile()
+
+		changed, err := hostsFile.Add("10.0.0.4", "director", "uaa")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+
+		changed, err = hostsFile.Add("10.0.0.5", "director")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+
+		changed, err = hostsFile.Add("10.0.0.5", "director")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+
+		Expect(hostsFile.Entries()).To(Equal([]boshsys.HostsEntry{
+			{IP: "10.0.0.4", Hostnames: []string{"uaa"}},
+			{IP: "10.0.0.5", Hostnames: []string{"director"}},
+		}))
+
+		changed, err = hostsFile.Remove("uaa")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+
+		Expect(hostsFile.Entries()).To(Equal([]boshsys.HostsEntry{
+			{IP: "10.0.0.5", Hostnames: []string{"director"}},
+		}))
+	})
+})
@@ -0,0 +1,49 @@
+package fakes
+
+import (
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type FakePrivileges struct {
+	Root     bool
+	Elevated bool
+
+	// Capabilities lists the capabilities HasCapability reports as present
+	Capabilities []boshsys.Capability
+
+	CanBindPrivilegedPortsValue bool
+
+	IsElevatedErr             error
+	HasCapabilityErr          error
+	CanBindPrivilegedPortsErr error
+}
+
+func NewFakePrivileges() *FakePrivileges {
+	return &FakePrivileges{}
+}
+
+func (p *FakePrivileges) IsRoot() bool {
+	return p.Root
+}
+
+func (p *FakePrivileges) IsElevated() (bool, error) {
+	return p.Elevated, p.IsElevatedErr
+}
+
+func (p *FakePrivileges) HasCapability(capability boshsys.Capability) (bool, error) {
+	if p.HasCapabilityErr != nil {
+		return false, p.HasCapabilityErr
+	}
+
+	for _, c := range p.Capabilities {
+		if c == capability {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (p *FakePrivileges) CanBindPrivilegedPorts() (bool, error) {
+	return p.CanBindPrivilegedPortsValue, p.CanBindPrivilegedPortsErr
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package system
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// tryLockFile takes an exclusive advisory lock that is released when
+// the file is closed, returning errFileLocked when it is held elsewhere
+func tryLockFile(file *os.File) error {
+	err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return errFileLocked
+	}
+	return err
+}
+
+func unlockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,54 @@
+package system
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+
+	// lockOffsetHigh places the locked byte far beyond the contents so that
+	// other processes can still read them despite mandatory locking
+	lockOffsetHigh = 0x7fffffff
+)
+
+var (
+	procLockFileEx   = kernel32DLL.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32DLL.NewProc("UnlockFileEx")
+)
+
+func tryLockFile(file *os.File) error {
+	overlapped := syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+
+	r1, _, err := procLockFileEx.Call(
+		file.Fd(),
+		uintptr(lockfileExclusiveLock|lockfileFailImmediately),
+		0,
+		1,
+		0,
+		uintptr(unsafe.Pointer(&overlapped)),
+	)
+	if r1 == 0 {
+		if err == errorLockViolation || err == syscall.ERROR_IO_PENDING {
+			return errFileLocked
+		}
+		return err
+	}
+
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	overlapped := syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+
+	r1, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r1 == 0 {
+		return err
+	}
+
+	return nil
+}
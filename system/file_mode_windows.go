@@ -0,0 +1,114 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	fsWrapper "github.com/charlievieth/fs"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	sddlRevision1                    = 1
+	daclSecurityInformation          = 0x00000004
+	protectedDaclSecurityInformation = 0x80000000
+)
+
+var (
+	advapi32DLL = syscall.NewLazyDLL("advapi32.dll")
+
+	procConvertStringSecurityDescriptorToSecurityDescriptorW = advapi32DLL.NewProc("ConvertStringSecurityDescriptorToSecurityDescriptorW")
+	procSetFileSecurityW                                     = advapi32DLL.NewProc("SetFileSecurityW")
+	procLocalFree                                            = kernel32DLL.NewProc("LocalFree")
+)
+
+// applyMode sets mode on a newly created file or dir. Windows has no mode
+// bits so they are translated into a protected DACL granting owner bits
+// to OWNER RIGHTS, group bits to BUILTIN\Users and other bits to Everyone.
+// SYSTEM and Administrators always keep full access.
+func applyMode(path string, mode os.FileMode) error {
+	err := setFileSDDL(path, modeSDDL(mode))
+	if err != nil {
+		return err
+	}
+
+	// only toggles the read-only attribute
+	return fsWrapper.Chmod(path, mode)
+}
+
+func modeSDDL(mode os.FileMode) string {
+	sddl := "D:P(A;;FA;;;SY)(A;;FA;;;BA)"
+
+	trustees := []struct {
+		sid   string
+		shift uint
+	}{
+		{"OW", 6},
+		{"BU", 3},
+		{"WD", 0},
+	}
+
+	for _, trustee := range trustees {
+		rights := modeRights(mode.Perm() >> trustee.shift)
+		if rights != "" {
+			sddl += fmt.Sprintf("(A;;%s;;;%s)", rights, trustee.sid)
+		}
+	}
+
+	return sddl
+}
+
+func modeRights(bits os.FileMode) string {
+	var rights strings.Builder
+
+	if bits&04 != 0 {
+		rights.WriteString("FR")
+	}
+	if bits&02 != 0 {
+		rights.WriteString("FW")
+	}
+	if bits&01 != 0 {
+		rights.WriteString("FX")
+	}
+
+	return rights.String()
+}
+
+func setFileSDDL(path, sddl string) error {
+	sddlPtr, err := syscall.UTF16PtrFromString(sddl)
+	if err != nil {
+		return err
+	}
+
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	var securityDescriptor uintptr
+	r, _, err := procConvertStringSecurityDescriptorToSecurityDescriptorW.Call(
+		uintptr(unsafe.Pointer(sddlPtr)),
+		sddlRevision1,
+		uintptr(unsafe.Pointer(&securityDescriptor)),
+		0,
+	)
+	if r == 0 {
+		return bosherr.WrapErrorf(err, "Converting security descriptor '%s'", sddl)
+	}
+	defer procLocalFree.Call(securityDescriptor)
+
+	r, _, err = procSetFileSecurityW.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		daclSecurityInformation|protectedDaclSecurityInformation,
+		securityDescriptor,
+	)
+	if r == 0 {
+		return bosherr.WrapErrorf(err, "Setting security descriptor of '%s'", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package system
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+)
+
+// FileStat extends os.FileInfo with ownership and identity details that
+// otherwise require casting FileInfo.Sys() per platform. Fields that are
+// not available on the current platform are left at their zero value,
+// except UID and GID which are -1.
+type FileStat struct {
+	os.FileInfo
+
+	UID   int
+	GID   int
+	User  string
+	Group string
+
+	Device uint64
+	Inode  uint64
+	Nlink  uint64
+
+	AccessTime time.Time
+	ChangeTime time.Time
+
+	// BirthTime is zero when the platform or filesystem does not record it
+	BirthTime time.Time
+
+	// WindowsAttributes holds FILE_ATTRIBUTE_* flags (Windows only)
+	WindowsAttributes uint32
+}
+
+func (s FileStat) HasBirthTime() bool {
+	return !s.BirthTime.IsZero()
+}
+
+func newFileStat(info os.FileInfo) FileStat {
+	return FileStat{FileInfo: info, UID: -1, GID: -1}
+}
+
+// lookupOwnerNames resolves User and Group from UID and GID;
+// ids without a matching account are left unnamed.
+func (s *FileStat) lookupOwnerNames() {
+	if s.UID >= 0 {
+		if u, err := user.LookupId(strconv.Itoa(s.UID)); err == nil {
+			s.User = u.Username
+		}
+	}
+
+	if s.GID >= 0 {
+		if g, err := user.LookupGroupId(strconv.Itoa(s.GID)); err == nil {
+			s.Group = g.Name
+		}
+	}
+}
+
+// FileTimes are the times set by Chtimes
+type FileTimes struct {
+	AccessTime time.Time
+	ModTime    time.Time
+
+	// BirthTime can only be set on Windows, where it is the creation time
+	BirthTime time.Time
+}
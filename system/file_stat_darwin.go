@@ -0,0 +1,24 @@
+package system
+
+import (
+	"syscall"
+	"time"
+)
+
+func fillFileStat(path string, followSymlinks bool, stat *FileStat) {
+	st, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+
+	stat.UID = int(st.Uid)
+	stat.GID = int(st.Gid)
+	stat.Device = uint64(st.Dev)
+	stat.Inode = uint64(st.Ino)
+	stat.Nlink = uint64(st.Nlink)
+	stat.AccessTime = time.Unix(st.Atimespec.Sec, st.Atimespec.Nsec)
+	stat.ChangeTime = time.Unix(st.Ctimespec.Sec, st.Ctimespec.Nsec)
+	stat.BirthTime = time.Unix(st.Birthtimespec.Sec, st.Birthtimespec.Nsec)
+
+	stat.lookupOwnerNames()
+}
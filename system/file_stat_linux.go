@@ -0,0 +1,37 @@
+package system
+
+import (
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func fillFileStat(path string, followSymlinks bool, stat *FileStat) {
+	st, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+
+	stat.UID = int(st.Uid)
+	stat.GID = int(st.Gid)
+	stat.Device = uint64(st.Dev)
+	stat.Inode = uint64(st.Ino)
+	stat.Nlink = uint64(st.Nlink)
+	stat.AccessTime = time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+	stat.ChangeTime = time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec))
+
+	// birth time is only exposed through statx(2) (kernel 4.11+)
+	flags := 0
+	if !followSymlinks {
+		flags = unix.AT_SYMLINK_NOFOLLOW
+	}
+
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, path, flags, unix.STATX_BTIME, &stx)
+	if err == nil && stx.Mask&unix.STATX_BTIME != 0 {
+		stat.BirthTime = time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec))
+	}
+
+	stat.lookupOwnerNames()
+}
@@ -0,0 +1,6 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package system
+
+func fillFileStat(path string, followSymlinks bool, stat *FileStat) {}
@@ -0,0 +1,45 @@
+package system
+
+import (
+	"syscall"
+	"time"
+)
+
+func fillFileStat(path string, followSymlinks bool, stat *FileStat) {
+	data, ok := stat.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return
+	}
+
+	stat.WindowsAttributes = data.FileAttributes
+	stat.AccessTime = time.Unix(0, data.LastAccessTime.Nanoseconds())
+	stat.BirthTime = time.Unix(0, data.CreationTime.Nanoseconds())
+
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return
+	}
+
+	flags := uint32(syscall.FILE_FLAG_BACKUP_SEMANTICS)
+	if !followSymlinks {
+		flags |= syscall.FILE_FLAG_OPEN_REPARSE_POINT
+	}
+
+	handle, err := syscall.CreateFile(pathPtr, 0,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, flags, 0)
+	if err != nil {
+		return
+	}
+	defer syscall.CloseHandle(handle)
+
+	var info syscall.ByHandleFileInformation
+	err = syscall.GetFileInformationByHandle(handle, &info)
+	if err != nil {
+		return
+	}
+
+	stat.Device = uint64(info.VolumeSerialNumber)
+	stat.Inode = uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow)
+	stat.Nlink = uint64(info.NumberOfLinks)
+}
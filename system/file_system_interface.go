@@ -16,6 +16,17 @@ type File interface {
 	Name() string
 }
 
+// ShareMode controls which access other openers of a file get on Windows
+type ShareMode uint32
+
+const (
+	ShareRead   ShareMode = 0x1 // FILE_SHARE_READ
+	ShareWrite  ShareMode = 0x2 // FILE_SHARE_WRITE
+	ShareDelete ShareMode = 0x4 // FILE_SHARE_DELETE, also allows renaming the file
+
+	ShareAll = ShareRead | ShareWrite | ShareDelete
+)
+
 type FileSystem interface {
 	HomeDir(username string) (path string, err error)
 	ExpandPath(path string) (expandedPath string, err error)
@@ -25,14 +36,33 @@ type FileSystem interface {
 	MkdirAll(path string, perm os.FileMode) error
 	RemoveAll(fileOrDir string) error
 
+	// RemoveOnReboot removes a file or empty dir even while it is in use,
+	// e.g. a running binary that is being upgraded, so that path can be
+	// reused right away. On Unix path is unlinked and its contents are
+	// freed once the last process closes it. On Windows a path that is in
+	// use is renamed and deleted on the next reboot, which requires
+	// administrator privileges.
+	RemoveOnReboot(path string) error
+
 	Chown(path, username string) error
 	Chmod(path string, perm os.FileMode) error
 
+	// Chtimes changes the times of path with nanosecond precision where the
+	// filesystem keeps it. Zero times are left unchanged.
+	Chtimes(path string, times FileTimes) error
+
 	OpenFile(path string, flag int, perm os.FileMode) (File, error)
 
+	// OpenFileWithShareMode opens path like OpenFile but lets other processes
+	// access the file as allowed by share while it is open, e.g. ShareDelete
+	// so that a log file can be rotated. Unix does not lock open files, so
+	// share is ignored there.
+	OpenFileWithShareMode(path string, flag int, perm os.FileMode, share ShareMode) (File, error)
+
 	WriteFileString(path, content string) error
 	WriteFile(path string, content []byte) error
 	WriteFileQuietly(path string, content []byte) error
+	WriteFileWithOpts(path string, content []byte, opts WriteOpts) error
 	ConvergeFileContents(path string, content []byte, opts ...ConvergeFileContentsOpts) (written bool, err error)
 
 	ReadFileString(path string) (content string, err error)
@@ -44,6 +74,15 @@ type FileSystem interface {
 	StatWithOpts(path string, opts StatOpts) (os.FileInfo, error)
 	Lstat(path string) (os.FileInfo, error)
 
+	// Statx and Lstatx return ownership, identity and timestamp details
+	// in addition to the regular FileInfo
+	Statx(path string) (FileStat, error)
+	Lstatx(path string) (FileStat, error)
+
+	// IsReadOnly returns true when path lives on a filesystem
+	// that is mounted read-only or on a write-protected volume
+	IsReadOnly(path string) (bool, error)
+
 	Rename(oldPath, newPath string) error
 
 	// After Symlink file at newPath will be pointing to file at oldPath.
@@ -51,15 +90,33 @@ type FileSystem interface {
 	// to make newPath a symlink to the file at oldPath.
 	Symlink(oldPath, newPath string) error
 
+	// Hardlink makes newPath another name for the file at oldPath.
+	// Unlike Symlink it fails when newPath already exists.
+	Hardlink(oldPath, newPath string) error
+
 	ReadAndFollowLink(symlinkPath string) (targetPath string, err error)
 	Readlink(symlinkPath string) (targetPath string, err error)
 
 	CopyFile(srcPath, dstPath string) error
+	CopyFileWithOpts(srcPath, dstPath string, opts CopyFileOpts) error
 	CopyDir(srcPath, dstPath string) error
+	CopyDirWithOpts(srcPath, dstPath string, opts CopyFileOpts) error
 
 	// Returns *unique* temporary file/dir with a custom prefix
 	TempFile(prefix string) (file File, err error)
 	TempDir(prefix string) (path string, err error)
+
+	// TempFileInMemory returns a temp file that is never written to
+	// persistent disk, e.g. for decrypted keys. Its contents are overwritten
+	// with zeros when it is closed. Other processes can open it by its name.
+	// On Windows this is best effort: the file is only kept in the file
+	// cache and may be written to disk when memory runs low.
+	TempFileInMemory(prefix string) (file File, err error)
+
+	// TempDirWithSpace creates a temp dir in the first candidate temp root
+	// with enough free space, or returns an InsufficientSpaceError
+	TempDirWithSpace(prefix string, requiredBytes uint64, opts ...TempDirWithSpaceOpts) (path string, err error)
+	DiskSpace(path string) (DiskSpace, error)
 	ChangeTempRoot(path string) error
 
 	Glob(pattern string) (matches []string, err error)
@@ -0,0 +1,305 @@
+package system
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type HostsEntry struct {
+	IP        string
+	Hostnames []string
+}
+
+// HostsFile edits the static host name lookup table. Lines it does not
+// need to change, including comments, are kept as they are.
+type HostsFile interface {
+	Entries() ([]HostsEntry, error)
+
+	// Add makes hostnames resolve to ip. The hostnames are removed from
+	// entries of other IPs. changed is false when nothing had to be written.
+	Add(ip string, hostnames ...string) (changed bool, err error)
+
+	// Remove removes hostnames from all entries and drops entries
+	// that are left without hostnames
+	Remove(hostnames ...string) (changed bool, err error)
+}
+
+// DefaultHostsFilePath is /etc/hosts, or the hosts file below
+// %SystemRoot% on Windows
+func DefaultHostsFilePath() string {
+	if runtime.GOOS == "windows" {
+		systemRoot := os.Getenv("SystemRoot")
+		if systemRoot == "" {
+			systemRoot = `C:\Windows`
+		}
+		return filepath.Join(systemRoot, "System32", "drivers", "etc", "hosts")
+	}
+
+	return "/etc/hosts"
+}
+
+type hostsFile struct {
+	path   string
+	fs     FileSystem
+	runner CmdRunner
+	lock   sync.Mutex
+
+	logTag string
+	logger boshlog.Logger
+}
+
+// NewHostsFile edits the hosts file at path. Changes are written to a
+// temporary file that replaces path. On Windows the DNS client cache is
+// flushed with runner afterwards.
+func NewHostsFile(path string, fs FileSystem, runner CmdRunner, logger boshlog.Logger) HostsFile {
+	return &hostsFile{
+		path:   path,
+		fs:     fs,
+		runner: runner,
+		logTag: "hostsFile",
+		logger: logger,
+	}
+}
+
+func (h *hostsFile) Entries() ([]HostsEntry, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	lines, _, err := h.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []HostsEntry
+	for _, line := range lines {
+		if line.ip != "" {
+			entries = append(entries, HostsEntry{IP: line.ip, Hostnames: append([]string(nil), line.hostnames...)})
+		}
+	}
+
+	return entries, nil
+}
+
+func (h *hostsFile) Add(ip string, hostnames ...string) (bool, error) {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false, bosherr.Errorf("Invalid IP '%s'", ip)
+	}
+
+	if len(hostnames) == 0 {
+		return false, bosherr.Error("Must provide at least one hostname")
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	lines, eol, err := h.read()
+	if err != nil {
+		return false, err
+	}
+
+	var ipLine *hostsLine
+	missing := append([]string(nil), hostnames...)
+
+	for i := range lines {
+		line := &lines[i]
+		if line.ip == "" {
+			continue
+		}
+
+		if net.ParseIP(line.ip).Equal(parsedIP) {
+			if ipLine == nil {
+				ipLine = line
+			}
+			missing = withoutHostnames(missing, line.hostnames)
+		} else {
+			line.removeHostnames(hostnames)
+		}
+	}
+
+	if len(missing) > 0 {
+		if ipLine != nil {
+			ipLine.hostnames = append(ipLine.hostnames, missing...)
+			ipLine.modified = true
+		} else {
+			lines = append(lines, hostsLine{ip: ip, hostnames: missing, modified: true})
+		}
+	}
+
+	return h.write(lines, eol)
+}
+
+func (h *hostsFile) Remove(hostnames ...string) (bool, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	lines, eol, err := h.read()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range lines {
+		lines[i].removeHostnames(hostnames)
+	}
+
+	return h.write(lines, eol)
+}
+
+// read returns no lines when the file does not exist yet
+func (h *hostsFile) read() ([]hostsLine, string, error) {
+	if !h.fs.FileExists(h.path) {
+		return nil, "\n", nil
+	}
+
+	content, err := h.fs.ReadFileString(h.path)
+	if err != nil {
+		return nil, "", bosherr.WrapErrorf(err, "Reading hosts file '%s'", h.path)
+	}
+
+	eol := "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+	}
+
+	var lines []hostsLine
+	if content != "" {
+		for _, raw := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+			lines = append(lines, parseHostsLine(strings.TrimSuffix(raw, "\r")))
+		}
+	}
+
+	return lines, eol, nil
+}
+
+func (h *hostsFile) write(lines []hostsLine, eol string) (bool, error) {
+	changed := false
+	for _, line := range lines {
+		if line.modified {
+			changed = true
+			break
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	var content strings.Builder
+	for _, line := range lines {
+		if line.modified && len(line.hostnames) == 0 {
+			continue
+		}
+		content.WriteString(line.String())
+		content.WriteString(eol)
+	}
+
+	perm := os.FileMode(0644)
+	if stat, err := h.fs.Stat(h.path); err == nil {
+		perm = stat.Mode().Perm()
+	}
+
+	tmpPath := h.path + ".bosh-tmp"
+
+	err := h.fs.WriteFileString(tmpPath, content.String())
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Writing '%s'", tmpPath)
+	}
+
+	err = h.fs.Chmod(tmpPath, perm)
+	if err != nil {
+		_ = h.fs.RemoveAll(tmpPath)
+		return false, bosherr.WrapErrorf(err, "Changing permissions of '%s'", tmpPath)
+	}
+
+	err = h.fs.Rename(tmpPath, h.path)
+	if err != nil {
+		_ = h.fs.RemoveAll(tmpPath)
+		return false, bosherr.WrapErrorf(err, "Replacing hosts file '%s'", h.path)
+	}
+
+	if runtime.GOOS == "windows" {
+		_, _, _, err = h.runner.RunCommand("ipconfig", "/flushdns")
+		if err != nil {
+			return true, bosherr.WrapError(err, "Flushing DNS cache")
+		}
+	}
+
+	h.logger.Debug(h.logTag, "Updated hosts file '%s'", h.path)
+
+	return true, nil
+}
+
+type hostsLine struct {
+	raw       string
+	ip        string
+	hostnames []string
+	comment   string
+	modified  bool
+}
+
+// parseHostsLine leaves ip empty for blank lines and comments
+func parseHostsLine(raw string) hostsLine {
+	line := hostsLine{raw: raw}
+
+	entry := raw
+	if i := strings.IndexByte(raw, '#'); i != -1 {
+		entry, line.comment = raw[:i], raw[i:]
+	}
+
+	fields := strings.Fields(entry)
+	if len(fields) >= 2 {
+		line.ip = fields[0]
+		line.hostnames = fields[1:]
+	}
+
+	return line
+}
+
+func (l *hostsLine) removeHostnames(hostnames []string) {
+	if l.ip == "" {
+		return
+	}
+
+	kept := withoutHostnames(l.hostnames, hostnames)
+	if len(kept) != len(l.hostnames) {
+		l.hostnames = kept
+		l.modified = true
+	}
+}
+
+func (l hostsLine) String() string {
+	if !l.modified {
+		return l.raw
+	}
+
+	s := l.ip + "\t" + strings.Join(l.hostnames, " ")
+	if l.comment != "" {
+		s += " " + l.comment
+	}
+	return s
+}
+
+// withoutHostnames compares case-insensitively like DNS does
+func withoutHostnames(hostnames, removed []string) []string {
+	var kept []string
+	for _, hostname := range hostnames {
+		found := false
+		for _, r := range removed {
+			if strings.EqualFold(hostname, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			kept = append(kept, hostname)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,166 @@
+package system_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("HostsFile", func() {
+	var (
+		path      string
+		runner    *fakesys.FakeCmdRunner
+		hostsFile HostsFile
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "hosts")
+
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		runner = fakesys.NewFakeCmdRunner()
+		hostsFile = NewHostsFile(path, NewOsFileSystem(logger), runner, logger)
+	})
+
+	writeHosts := func(content string) {
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	readHosts := func() string {
+		content, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		return string(content)
+	}
+
+	Describe("Entries", func() {
+		It("parses entries and skips comments", func() {
+			writeHosts("# comment\n127.0.0.1\tlocalhost localhost.localdomain # loopback\n\n::1 ip6-localhost\n")
+
+			entries, err := hostsFile.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(Equal([]HostsEntry{
+				{IP: "127.0.0.1", Hostnames: []string{"localhost", "localhost.localdomain"}},
+				{IP: "::1", Hostnames: []string{"ip6-localhost"}},
+			}))
+		})
+
+		It("returns no entries when the file does not exist", func() {
+			entries, err := hostsFile.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Describe("Add", func() {
+		It("adds a new entry and keeps other lines unchanged", func() {
+			writeHosts("# comment\n127.0.0.1   localhost\n")
+
+			changed, err := hostsFile.Add("10.0.0.5", "director", "director.bosh")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+
+			Expect(readHosts()).To(Equal("# comment\n127.0.0.1   localhost\n10.0.0.5\tdirector director.bosh\n"))
+		})
+
+		It("adds hostnames to an existing entry of the same IP", func() {
+			writeHosts("10.0.0.5 director # bosh\n")
+
+			_, err := hostsFile.Add("10.0.0.5", "DIRECTOR", "uaa")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(readHosts()).To(Equal("10.0.0.5\tdirector uaa # bosh\n"))
+		})
+
+		It("moves hostnames from entries of other IPs", func() {
+			writeHosts("10.0.0.4 director\n10.0.0.6 uaa other\n")
+
+			_, err := hostsFile.Add("10.0.0.5", "director", "uaa")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(readHosts()).To(Equal("10.0.0.6\tother\n10.0.0.5\tdirector uaa\n"))
+		})
+
+		It("does not write the file when the entry is already present", func() {
+			writeHosts("10.0.0.5  director\n")
+
+			changed, err := hostsFile.Add("10.0.0.5", "director")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeFalse())
+
+			Expect(readHosts()).To(Equal("10.0.0.5  director\n"))
+		})
+
+		It("creates the file when it does not exist", func() {
+			_, err := hostsFile.Add("::1", "localhost")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(readHosts()).To(Equal("::1\tlocalhost\n"))
+		})
+
+		It("keeps Windows line endings", func() {
+			writeHosts("127.0.0.1 localhost\r\n")
+
+			_, err := hostsFile.Add("10.0.0.5", "director")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(readHosts()).To(Equal("127.0.0.1 localhost\r\n10.0.0.5\tdirector\r\n"))
+		})
+
+		It("keeps the permissions of the file", func() {
+			if Windows {
+				Skip("Windows does not have unix permissions")
+			}
+
+			writeHosts("127.0.0.1 localhost\n")
+			Expect(os.Chmod(path, 0604)).To(Succeed())
+
+			_, err := hostsFile.Add("10.0.0.5", "director")
+			Expect(err).ToNot(HaveOccurred())
+
+			stat, err := os.Stat(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat.Mode().Perm()).To(Equal(os.FileMode(0604)))
+		})
+
+		It("flushes the DNS cache on Windows", func() {
+			_, err := hostsFile.Add("10.0.0.5", "director")
+			Expect(err).ToNot(HaveOccurred())
+
+			if Windows {
+				Expect(runner.RunCommands).To(Equal([][]string{{"ipconfig", "/flushdns"}}))
+			} else {
+				Expect(runner.RunCommands).To(BeEmpty())
+			}
+		})
+
+		It("rejects invalid IPs", func() {
+			_, err := hostsFile.Add("not-an-ip", "director")
+			Expect(err).To(MatchError("Invalid IP 'not-an-ip'"))
+		})
+	})
+
+	Describe("Remove", func() {
+		It("removes hostnames and drops entries left without hostnames", func() {
+			writeHosts("# comment\n10.0.0.5 director uaa\n10.0.0.6 Director\n")
+
+			changed, err := hostsFile.Remove("director")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+
+			Expect(readHosts()).To(Equal("# comment\n10.0.0.5\tuaa\n"))
+		})
+
+		It("does not write the file when the hostnames are not present", func() {
+			writeHosts("10.0.0.5  uaa\n")
+
+			changed, err := hostsFile.Remove("director")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// InactivityTimeoutError is the Result error of commands that were killed
+// because they did not write to stdout or stderr for Command.InactivityTimeout
+type InactivityTimeoutError struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (e InactivityTimeoutError) Error() string {
+	return fmt.Sprintf("Command '%s' was killed after producing no output for %s", e.Command, e.Timeout)
+}
+
+func IsInactivityTimeoutError(err error) bool {
+	var timeoutErr InactivityTimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+// inactivityWatchdog is written to together with stdout and stderr
+// and calls kill once nothing was written for timeout
+type inactivityWatchdog struct {
+	timeout      time.Duration
+	lastActivity int64
+	fired        int32
+	stopCh       chan struct{}
+}
+
+func newInactivityWatchdog(timeout time.Duration) *inactivityWatchdog {
+	return &inactivityWatchdog{timeout: timeout, stopCh: make(chan struct{})}
+}
+
+func (w *inactivityWatchdog) Write(p []byte) (int, error) {
+	atomic.StoreInt64(&w.lastActivity, time.Now().UnixNano())
+	return len(p), nil
+}
+
+func (w *inactivityWatchdog) start(kill func()) {
+	atomic.StoreInt64(&w.lastActivity, time.Now().UnixNano())
+
+	go func() {
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-w.stopCh:
+				return
+
+			case <-timer.C:
+				idle := time.Since(time.Unix(0, atomic.LoadInt64(&w.lastActivity)))
+				if idle < w.timeout {
+					timer.Reset(w.timeout - idle)
+					continue
+				}
+
+				atomic.StoreInt32(&w.fired, 1)
+				kill()
+				return
+			}
+		}
+	}()
+}
+
+func (w *inactivityWatchdog) stop() {
+	close(w.stopCh)
+}
+
+func (w *inactivityWatchdog) hasFired() bool {
+	return atomic.LoadInt32(&w.fired) == 1
+}
@@ -0,0 +1,118 @@
+package system
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// lineWriter passes complete lines written to it to emit, the last line
+// is only passed on flush if it does not end with a newline
+type lineWriter struct {
+	emit func(line []byte)
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func newLineFuncWriter(lineFunc func(line string)) *lineWriter {
+	return &lineWriter{emit: func(line []byte) { lineFunc(string(line)) }}
+}
+
+func newLogLineWriters(output LogOutput, cmdName string) (*lineWriter, *lineWriter) {
+	tag := output.Tag
+	if tag == "" {
+		tag = filepath.Base(cmdName)
+	}
+
+	stdoutLevel := boshlog.LevelInfo
+	if output.StdoutLevel != nil {
+		stdoutLevel = *output.StdoutLevel
+	}
+
+	stderrLevel := boshlog.LevelWarn
+	if output.StderrLevel != nil {
+		stderrLevel = *output.StderrLevel
+	}
+
+	return &lineWriter{emit: logLine(output.Logger, tag, stdoutLevel)},
+		&lineWriter{emit: logLine(output.Logger, tag, stderrLevel)}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial = append(w.partial, p...)
+
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.emit(bytes.TrimSuffix(w.partial[:i], []byte("\r")))
+		w.partial = w.partial[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.partial) > 0 {
+		w.emit(w.partial)
+		w.partial = nil
+	}
+}
+
+func logLine(logger boshlog.Logger, tag string, level boshlog.LogLevel) func([]byte) {
+	return func(line []byte) {
+		switch level {
+		case boshlog.LevelDebug:
+			logger.Debug(tag, "%s", line)
+		case boshlog.LevelInfo:
+			logger.Info(tag, "%s", line)
+		case boshlog.LevelWarn:
+			logger.Warn(tag, "%s", line)
+		case boshlog.LevelError:
+			logger.Error(tag, "%s", line)
+		}
+	}
+}
+
+// WithOutputTee is a CmdHook that logs every stdout and stderr line of
+// every command as soon as it was written, prefixed with the command name
+// and stream, e.g. to debug commands that hang. Output is still captured
+// and the line funcs of the command are still called.
+func WithOutputTee(logger boshlog.Logger, tag string) CmdHook {
+	return func(next RunFunc) RunFunc {
+		return func(cmd Command) (Process, error) {
+			name := filepath.Base(cmd.Name)
+
+			cmd.StdoutLineFunc = teeLines(cmd.StdoutLineFunc, func(line string) {
+				logger.Info(tag, "%s stdout: %s", name, line)
+			})
+			cmd.StderrLineFunc = teeLines(cmd.StderrLineFunc, func(line string) {
+				logger.Info(tag, "%s stderr: %s", name, line)
+			})
+
+			return next(cmd)
+		}
+	}
+}
+
+func teeLines(lineFunc, tee func(line string)) func(line string) {
+	if lineFunc == nil {
+		return tee
+	}
+
+	return func(line string) {
+		tee(line)
+		lineFunc(line)
+	}
+}
@@ -0,0 +1,88 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type LookPathOpts struct {
+	// Strict rejects commands that resolve relative to the working directory,
+	// e.g. through "." in PATH or the implicit current directory on Windows
+	Strict bool
+}
+
+// CommandLookup describes how a command name was resolved
+type CommandLookup struct {
+	Name string
+
+	// Path is absolute
+	Path string
+
+	// SearchedPaths are the PATH entries considered, empty when
+	// Name already contains a path separator
+	SearchedPaths []string
+
+	// FromWorkingDir is true when Name was resolved relative to the working directory
+	FromWorkingDir bool
+}
+
+// CommandNotFoundError is returned by LookPath when no executable was found
+type CommandNotFoundError struct {
+	Name          string
+	SearchedPaths []string
+	Err           error
+}
+
+func (e CommandNotFoundError) Error() string {
+	if len(e.SearchedPaths) == 0 {
+		return fmt.Sprintf("Command '%s' not found: %s", e.Name, e.Err)
+	}
+	return fmt.Sprintf("Command '%s' not found in PATH (searched %s)", e.Name, strings.Join(e.SearchedPaths, ", "))
+}
+
+func (e CommandNotFoundError) Unwrap() error { return e.Err }
+
+func IsCommandNotFoundError(err error) bool {
+	var notFoundErr CommandNotFoundError
+	return errors.As(err, &notFoundErr)
+}
+
+func lookPath(cmdName string, opts LookPathOpts) (CommandLookup, error) {
+	lookup := CommandLookup{Name: cmdName}
+
+	if !strings.ContainsAny(cmdName, `/\`) {
+		lookup.SearchedPaths = filepath.SplitList(os.Getenv("PATH"))
+	}
+
+	path, err := exec.LookPath(cmdName)
+	if errors.Is(err, exec.ErrDot) {
+		lookup.FromWorkingDir = true
+		err = nil
+	}
+	if err != nil {
+		return lookup, CommandNotFoundError{Name: cmdName, SearchedPaths: lookup.SearchedPaths, Err: err}
+	}
+
+	if !filepath.IsAbs(path) {
+		lookup.FromWorkingDir = true
+
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return lookup, bosherr.WrapErrorf(err, "Making path of command '%s' absolute", cmdName)
+		}
+	}
+
+	lookup.Path = path
+
+	if opts.Strict && lookup.FromWorkingDir {
+		return lookup, bosherr.Errorf("Command '%s' resolved to '%s' relative to the working directory", cmdName, path)
+	}
+
+	return lookup, nil
+}
@@ -0,0 +1,60 @@
+package system
+
+import (
+	"os"
+	"sync"
+)
+
+// memoryFile is returned by TempFileInMemory. Close overwrites its contents
+// with zeros before releasing it, so that secrets do not linger in memory
+// that is handed out to other processes later.
+type memoryFile struct {
+	*os.File
+
+	// remove is set for files that have a path which has to be removed,
+	// e.g. files in /dev/shm
+	remove bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func (f *memoryFile) Close() error {
+	f.closeOnce.Do(func() {
+		shredErr := shredFile(f.File)
+		f.closeErr = f.File.Close()
+
+		if f.remove {
+			if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) && f.closeErr == nil {
+				f.closeErr = err
+			}
+		}
+
+		if shredErr != nil {
+			f.closeErr = shredErr
+		}
+	})
+
+	return f.closeErr
+}
+
+func shredFile(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	zeros := make([]byte, 32*1024)
+	for offset := int64(0); offset < info.Size(); offset += int64(len(zeros)) {
+		chunk := zeros
+		if remaining := info.Size() - offset; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+
+		if _, err := f.WriteAt(chunk, offset); err != nil {
+			return err
+		}
+	}
+
+	return f.Truncate(0)
+}
@@ -0,0 +1,21 @@
+package system
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// tempFileInMemory prefers memfd_create, whose files never have a path on
+// disk; other processes can still open them through /proc/<pid>/fd. Kernels
+// without memfd_create fall back to the tmpfs mounted at /dev/shm.
+func (fs *osFileSystem) tempFileInMemory(prefix string) (*memoryFile, error) {
+	fd, err := unix.MemfdCreate(prefix, unix.MFD_CLOEXEC)
+	if err == nil {
+		path := fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), fd)
+		return &memoryFile{File: os.NewFile(uintptr(fd), path)}, nil
+	}
+
+	return tempFileInShm(prefix)
+}
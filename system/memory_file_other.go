@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package system
+
+func (fs *osFileSystem) tempFileInMemory(prefix string) (*memoryFile, error) {
+	return tempFileInShm(prefix)
+}
@@ -0,0 +1,86 @@
+package system_test
+
+import (
+	"io"
+	"os"
+	"runtime"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("TempFileInMemory", func() {
+	var fs FileSystem
+
+	BeforeEach(func() {
+		fs = NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	It("reads back what was written", func() {
+		file, err := fs.TempFileInMemory("memory-file-test")
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+
+		_, err = file.Write([]byte("fake-secret"))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = file.Seek(0, io.SeekStart)
+		Expect(err).ToNot(HaveOccurred())
+
+		contents, err := io.ReadAll(file)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("fake-secret"))
+	})
+
+	It("does not create files on disk on Linux", func() {
+		if runtime.GOOS != "linux" {
+			Skip("memfd_create and /dev/shm are only available on Linux")
+		}
+
+		file, err := fs.TempFileInMemory("memory-file-test")
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+
+		Expect(file.Name()).To(Or(HavePrefix("/proc/"), HavePrefix("/dev/shm/")))
+	})
+
+	Context("when other processes open the file", func() {
+		BeforeEach(func() {
+			if runtime.GOOS == "windows" {
+				Skip("Files that are deleted on close can not be opened again by name on Windows")
+			}
+		})
+
+		It("shares its contents and shreds them on close", func() {
+			file, err := fs.TempFileInMemory("memory-file-test")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = file.Write([]byte("fake-secret"))
+			Expect(err).ToNot(HaveOccurred())
+
+			other, err := os.Open(file.Name())
+			Expect(err).ToNot(HaveOccurred())
+			defer other.Close()
+
+			contents, err := io.ReadAll(other)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("fake-secret"))
+
+			Expect(file.Close()).To(Succeed())
+			Expect(file.Close()).To(Succeed())
+
+			_, err = other.Seek(0, io.SeekStart)
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err = io.ReadAll(other)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(BeEmpty())
+
+			_, err = os.Stat(file.Name())
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package system
+
+import (
+	"io/ioutil"
+	"os"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var shmDirs = []string{"/dev/shm", "/run/shm"}
+
+// tempFileInShm creates a file in the first tmpfs found at a well known
+// location. Other temp roots are not used since they may be on disk.
+func tempFileInShm(prefix string) (*memoryFile, error) {
+	for _, dir := range shmDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+
+		file, err := ioutil.TempFile(dir, prefix)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Creating temp file in '%s'", dir)
+		}
+
+		return &memoryFile{File: file, remove: true}, nil
+	}
+
+	return nil, bosherr.Error("No memory backed file system found")
+}
@@ -0,0 +1,48 @@
+package system
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	fileAttributeTemporary = 0x100      // FILE_ATTRIBUTE_TEMPORARY
+	fileFlagDeleteOnClose  = 0x04000000 // FILE_FLAG_DELETE_ON_CLOSE
+)
+
+// tempFileInMemory creates a temporary file that Windows keeps in the file
+// cache instead of writing it out unless memory runs low. Windows has no
+// file system that is guaranteed to stay in memory, so unlike on Linux the
+// contents may reach the disk. It is deleted once the last handle to it is
+// closed, even if the process crashes.
+func (fs *osFileSystem) tempFileInMemory(prefix string) (*memoryFile, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(fs.tempRootOrDefault(), prefix+hex.EncodeToString(suffix))
+
+	pathp, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	handle, err := syscall.CreateFile(
+		pathp,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		uint32(ShareAll),
+		nil,
+		syscall.CREATE_NEW,
+		fileAttributeTemporary|fileFlagDeleteOnClose,
+		0,
+	)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	return &memoryFile{File: os.NewFile(uintptr(handle), path)}, nil
+}
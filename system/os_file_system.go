@@ -2,7 +2,6 @@ package system
 
 import (
 	"bytes"
-	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -14,6 +13,7 @@ import (
 	"github.com/bmatcuk/doublestar"
 	fsWrapper "github.com/charlievieth/fs"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/fileutil/pathutil"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
@@ -22,6 +22,22 @@ type osFileSystem struct {
 	logTag           string
 	tempRoot         string
 	requiresTempRoot bool
+	umask            *os.FileMode
+	exactModes       bool
+}
+
+type OsFileSystemOpts struct {
+	StrictTempRoot bool
+
+	// Umask replaces the process umask for files and directories created
+	// through OpenFile, MkdirAll and the functions built on them.
+	// The resulting modes are enforced after creation.
+	Umask *os.FileMode
+
+	// ExactModes enforces the requested permissions after creation so that
+	// a restrictive process umask does not strip them. Note that WriteFile
+	// requests 0666 for files and 0777 for parent dirs, so combine it with Umask.
+	ExactModes bool
 }
 
 func NewOsFileSystem(logger boshlog.Logger) FileSystem {
@@ -32,6 +48,16 @@ func NewOsFileSystemWithStrictTempRoot(logger boshlog.Logger) FileSystem {
 	return &osFileSystem{logger: logger, logTag: "File System", requiresTempRoot: true}
 }
 
+func NewOsFileSystemWithOpts(logger boshlog.Logger, opts OsFileSystemOpts) FileSystem {
+	return &osFileSystem{
+		logger:           logger,
+		logTag:           "File System",
+		requiresTempRoot: opts.StrictTempRoot,
+		umask:            opts.Umask,
+		exactModes:       opts.ExactModes,
+	}
+}
+
 func (fs *osFileSystem) HomeDir(username string) (string, error) {
 	fs.logger.Debug(fs.logTag, "Getting HomeDir for %s", username)
 	dir, err := fs.homeDir(username)
@@ -63,7 +89,27 @@ func (fs *osFileSystem) ExpandPath(path string) (string, error) {
 
 func (fs *osFileSystem) MkdirAll(path string, perm os.FileMode) (err error) {
 	fs.logger.Debug(fs.logTag, "Making dir %s with perm %#o", path, perm)
-	return fsWrapper.MkdirAll(path, perm)
+
+	mode, enforce := fs.createMode(perm)
+	if !enforce {
+		return wrapReadOnlyErr(path, fsWrapper.MkdirAll(path, perm))
+	}
+
+	missingDirs := missingDirs(path)
+
+	err = fsWrapper.MkdirAll(path, mode)
+	if err != nil {
+		return wrapReadOnlyErr(path, err)
+	}
+
+	for _, dir := range missingDirs {
+		err = applyMode(dir, mode)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Enforcing mode %#o of '%s'", mode, dir)
+		}
+	}
+
+	return nil
 }
 
 func (fs *osFileSystem) Chown(path, username string) error {
@@ -73,15 +119,106 @@ func (fs *osFileSystem) Chown(path, username string) error {
 
 func (fs *osFileSystem) Chmod(path string, perm os.FileMode) (err error) {
 	fs.logger.Debug(fs.logTag, "Chmod %s to %d", path, perm)
-	return fsWrapper.Chmod(path, perm)
+	return wrapReadOnlyErr(path, fsWrapper.Chmod(path, perm))
+}
+
+func (fs *osFileSystem) Chtimes(path string, times FileTimes) error {
+	fs.logger.Debug(fs.logTag, "Chtimes %s to access time '%s' mod time '%s' birth time '%s'",
+		path, times.AccessTime, times.ModTime, times.BirthTime)
+
+	if !times.BirthTime.IsZero() && !canSetBirthTime {
+		return bosherr.Errorf("Setting the birth time of '%s' is not supported on this platform", path)
+	}
+
+	return wrapReadOnlyErr(path, fs.chtimes(path, times))
+}
+
+// timesOf returns the times of path that can be set by Chtimes
+func (fs *osFileSystem) timesOf(path string) (FileTimes, error) {
+	stat, err := fs.statx(path, fsWrapper.Stat, true)
+	if err != nil {
+		return FileTimes{}, err
+	}
+
+	times := FileTimes{AccessTime: stat.AccessTime, ModTime: stat.ModTime()}
+	if canSetBirthTime {
+		times.BirthTime = stat.BirthTime
+	}
+
+	return times, nil
 }
 
 func (fs *osFileSystem) openFile(path string, flag int, perm os.FileMode) (*os.File, error) {
-	return fsWrapper.OpenFile(path, flag, perm)
+	file, err := fsWrapper.OpenFile(path, flag, perm)
+	if err != nil && isWriteFlag(flag) {
+		return nil, wrapReadOnlyErr(path, err)
+	}
+	return file, err
 }
 
 func (fs *osFileSystem) OpenFile(path string, flag int, perm os.FileMode) (File, error) {
-	return fs.openFile(path, flag, perm)
+	return fs.openFileWithMode(path, flag, perm, fs.openFile)
+}
+
+func (fs *osFileSystem) OpenFileWithShareMode(path string, flag int, perm os.FileMode, share ShareMode) (File, error) {
+	return fs.openFileWithMode(path, flag, perm, func(path string, flag int, perm os.FileMode) (*os.File, error) {
+		return fs.openFileWithShareMode(path, flag, perm, share)
+	})
+}
+
+func (fs *osFileSystem) openFileWithMode(path string, flag int, perm os.FileMode, open func(string, int, os.FileMode) (*os.File, error)) (File, error) {
+	mode, enforce := fs.createMode(perm)
+	if !enforce || flag&os.O_CREATE == 0 {
+		return open(path, flag, perm)
+	}
+
+	// modes of existing files are left alone
+	_, statErr := fsWrapper.Lstat(path)
+
+	file, err := open(path, flag, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if os.IsNotExist(statErr) {
+		err = applyMode(path, mode)
+		if err != nil {
+			file.Close()
+			return nil, bosherr.WrapErrorf(err, "Enforcing mode %#o of '%s'", mode, path)
+		}
+	}
+
+	return file, nil
+}
+
+// createMode returns the permissions created files and dirs end up with
+// and whether they have to be enforced after creation
+func (fs *osFileSystem) createMode(perm os.FileMode) (os.FileMode, bool) {
+	if fs.umask != nil {
+		return perm &^ *fs.umask, true
+	}
+
+	return perm, fs.exactModes
+}
+
+// missingDirs returns path and its ancestors that do not exist yet
+func missingDirs(path string) []string {
+	var missing []string
+
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		_, err := fsWrapper.Lstat(dir)
+		if !os.IsNotExist(err) {
+			break
+		}
+
+		missing = append(missing, dir)
+
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	return missing
 }
 
 type StatOpts struct {
@@ -104,67 +241,145 @@ func (fs *osFileSystem) Lstat(path string) (os.FileInfo, error) {
 	return fsWrapper.Lstat(path)
 }
 
+func (fs *osFileSystem) Statx(path string) (FileStat, error) {
+	fs.logger.Debug(fs.logTag, "Statx '%s'", path)
+	return fs.statx(path, fsWrapper.Stat, true)
+}
+
+func (fs *osFileSystem) Lstatx(path string) (FileStat, error) {
+	fs.logger.Debug(fs.logTag, "Lstatx '%s'", path)
+	return fs.statx(path, fsWrapper.Lstat, false)
+}
+
+func (fs *osFileSystem) statx(path string, statFunc func(string) (os.FileInfo, error), followSymlinks bool) (FileStat, error) {
+	info, err := statFunc(path)
+	if err != nil {
+		return FileStat{}, err
+	}
+
+	stat := newFileStat(info)
+	fillFileStat(path, followSymlinks, &stat)
+
+	return stat, nil
+}
+
 func (fs *osFileSystem) WriteFileString(path, content string) (err error) {
 	return fs.WriteFile(path, []byte(content))
 }
 
 func (fs *osFileSystem) WriteFileQuietly(path string, content []byte) error {
-	return fs.writeFileHelper(path, content, false)
+	return fs.WriteFileWithOpts(path, content, WriteOpts{Quiet: true})
 }
 
 func (fs *osFileSystem) WriteFile(path string, content []byte) error {
-	return fs.writeFileHelper(path, content, true)
+	return fs.WriteFileWithOpts(path, content, WriteOpts{})
+}
+
+type WriteOpts struct {
+	Quiet bool
+
+	// NoFollow fails with a SymlinkError instead of writing to the target
+	// of path when path is a symlink, e.g. one planted by an unprivileged
+	// user in a writable dir. Parent dirs are still followed.
+	NoFollow bool
+
+	// PreserveTimes keeps the times of an existing file so that rewriting
+	// it does not look like a change to mtime based syncs
+	PreserveTimes bool
 }
 
-func (fs *osFileSystem) writeFileHelper(path string, content []byte, logDebug bool) error {
-	if logDebug {
+func (fs *osFileSystem) WriteFileWithOpts(path string, content []byte, opts WriteOpts) error {
+	if !opts.Quiet {
 		fs.logger.Debug(fs.logTag, "Writing %s", path)
 	}
 
-	err := fs.MkdirAll(filepath.Dir(path), os.ModePerm)
+	err := validateWritePath(path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Validating path %s", path)
+	}
+
+	err = fs.MkdirAll(filepath.Dir(path), os.ModePerm)
 	if err != nil {
 		return bosherr.WrapError(err, "Creating dir to write file")
 	}
 
-	file, err := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	var times FileTimes
+	if opts.PreserveTimes {
+		times, err = fs.timesOf(path)
+		if err != nil && !os.IsNotExist(err) {
+			return bosherr.WrapErrorf(err, "Reading times of file %s", path)
+		}
+	}
+
+	open := fs.openFile
+	if opts.NoFollow {
+		open = fs.openFileNoFollow
+	}
+
+	file, err := fs.openFileWithMode(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666, open)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Creating file %s", path)
 	}
 
-	defer file.Close()
-
-	if logDebug {
+	if !opts.Quiet {
 		fs.logger.DebugWithDetails(fs.logTag, "Write content", content)
 	}
 
 	_, err = file.Write(content)
 	if err != nil {
+		file.Close()
 		return bosherr.WrapErrorf(err, "Writing content to file %s", path)
 	}
 
+	// closing may update the times on some platforms
+	err = file.Close()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Closing file %s", path)
+	}
+
+	err = fs.chtimes(path, times)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Preserving times of file %s", path)
+	}
+
 	return nil
 }
 
 type ConvergeFileContentsOpts struct {
 	DryRun bool
+
+	// NoFollow works like WriteOpts.NoFollow and also applies to dry runs
+	NoFollow bool
 }
 
 func (fs *osFileSystem) ConvergeFileContents(path string, content []byte, opts ...ConvergeFileContentsOpts) (bool, error) {
-	actuallyConverge := true
+	var convergeOpts ConvergeFileContentsOpts
 
 	if len(opts) > 0 {
-		actuallyConverge = !opts[0].DryRun
+		convergeOpts = opts[0]
+	}
+
+	actuallyConverge := !convergeOpts.DryRun
+	writeOpts := WriteOpts{NoFollow: convergeOpts.NoFollow}
+
+	stat, open := fs.Stat, fs.openFile
+	if convergeOpts.NoFollow {
+		stat, open = fs.Lstat, fs.openFileNoFollow
+	}
+
+	fi, err := stat(path)
+	if err == nil && convergeOpts.NoFollow && fi.Mode()&os.ModeSymlink != 0 {
+		return true, bosherr.WrapErrorf(SymlinkError{Path: path}, "Converging file %s", path)
 	}
 
-	fi, err := fs.Stat(path)
 	if err != nil || fi.Size() != int64(len(content)) {
 		if actuallyConverge {
-			return true, fs.WriteFile(path, content)
+			return true, fs.WriteFileWithOpts(path, content, writeOpts)
 		}
 		return true, nil
 	}
 
-	file, err := fs.openFile(path, os.O_CREATE|os.O_RDWR, 0666)
+	file, err := open(path, os.O_CREATE|os.O_RDWR, 0666)
 	if err != nil {
 		return true, bosherr.WrapErrorf(err, "Creating file %s", path)
 	}
@@ -183,7 +398,7 @@ func (fs *osFileSystem) ConvergeFileContents(path string, content []byte, opts .
 	if actuallyConverge {
 		fs.logger.Debug(fs.logTag, "File %s will be overwritten", path)
 		file.Close()
-		return true, fs.WriteFile(path, content)
+		return true, fs.WriteFileWithOpts(path, content, writeOpts)
 	}
 
 	return true, nil
@@ -242,11 +457,22 @@ func (fs *osFileSystem) FileExists(path string) bool {
 	return true
 }
 
+func (fs *osFileSystem) IsReadOnly(path string) (bool, error) {
+	fs.logger.Debug(fs.logTag, "Checking if filesystem containing %s is read-only", path)
+
+	readOnly, err := fs.isReadOnly(path)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Checking if filesystem containing '%s' is read-only", path)
+	}
+
+	return readOnly, nil
+}
+
 func (fs *osFileSystem) Rename(oldPath, newPath string) (err error) {
 	fs.logger.Debug(fs.logTag, "Renaming %s to %s", oldPath, newPath)
 
 	fs.RemoveAll(newPath)
-	return fsWrapper.Rename(oldPath, newPath)
+	return wrapReadOnlyErr(newPath, fsWrapper.Rename(oldPath, newPath))
 }
 
 func (fs *osFileSystem) Symlink(oldPath, newPath string) error {
@@ -277,7 +503,12 @@ func (fs *osFileSystem) Symlink(oldPath, newPath string) error {
 		fs.MkdirAll(containingDir, os.FileMode(0700))
 	}
 
-	return fsWrapper.Symlink(source, target)
+	return wrapReadOnlyErr(target, fsWrapper.Symlink(source, target))
+}
+
+func (fs *osFileSystem) Hardlink(oldPath, newPath string) error {
+	fs.logger.Debug(fs.logTag, "Hardlinking oldPath %s with newPath %s", oldPath, newPath)
+	return wrapReadOnlyErr(newPath, fsWrapper.Link(oldPath, newPath))
 }
 
 func (fs *osFileSystem) ReadAndFollowLink(symlinkPath string) (targetPath string, err error) {
@@ -289,8 +520,29 @@ func (fs *osFileSystem) Readlink(symlinkPath string) (targetPath string, err err
 }
 
 func (fs *osFileSystem) CopyFile(srcPath, dstPath string) error {
+	return fs.CopyFileWithOpts(srcPath, dstPath, CopyFileOpts{})
+}
+
+type CopyFileOpts struct {
+	// PreserveTimes gives copies the access and modification times of their
+	// source, and the creation time on Windows. Otherwise copies get the
+	// current time like any new file.
+	PreserveTimes bool
+}
+
+func (fs *osFileSystem) CopyFileWithOpts(srcPath, dstPath string, opts CopyFileOpts) error {
 	fs.logger.Debug(fs.logTag, "Copying file '%s' to '%s'", srcPath, dstPath)
 
+	// read the times before copying updates the access time
+	var times FileTimes
+	if opts.PreserveTimes {
+		var err error
+		times, err = fs.timesOf(srcPath)
+		if err != nil {
+			return bosherr.WrapError(err, "Reading times of source path")
+		}
+	}
+
 	srcFile, err := fs.OpenFile(srcPath, os.O_RDONLY, 0)
 	if err != nil {
 		return bosherr.WrapError(err, "Opening source path")
@@ -307,17 +559,31 @@ func (fs *osFileSystem) CopyFile(srcPath, dstPath string) error {
 		return bosherr.WrapError(err, "Creating destination file")
 	}
 
-	defer dstFile.Close()
-
-	_, err = io.Copy(dstFile, srcFile)
+	_, err = CopyStream(dstFile, srcFile, CopyOpts{})
 	if err != nil {
+		dstFile.Close()
 		return bosherr.WrapError(err, "Copying file")
 	}
 
+	err = dstFile.Close()
+	if err != nil {
+		return bosherr.WrapError(err, "Closing destination file")
+	}
+
+	err = fs.chtimes(dstPath, times)
+	if err != nil {
+		return bosherr.WrapError(err, "Preserving times of destination file")
+	}
+
 	return nil
 }
 
 func (fs *osFileSystem) CopyDir(srcPath, dstPath string) error {
+	return fs.CopyDirWithOpts(srcPath, dstPath, CopyFileOpts{})
+}
+
+// CopyDirWithOpts applies opts to every file and dir it copies
+func (fs *osFileSystem) CopyDirWithOpts(srcPath, dstPath string, opts CopyFileOpts) error {
 	fs.logger.Debug(fs.logTag, "Copying dir '%s' to '%s'", srcPath, dstPath)
 
 	sourceInfo, err := fs.Stat(srcPath)
@@ -325,6 +591,14 @@ func (fs *osFileSystem) CopyDir(srcPath, dstPath string) error {
 		return bosherr.WrapErrorf(err, "Reading dir stats for '%s'", srcPath)
 	}
 
+	var times FileTimes
+	if opts.PreserveTimes {
+		times, err = fs.timesOf(srcPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading dir times for '%s'", srcPath)
+		}
+	}
+
 	// create destination dir with same permissions as source dir
 	err = fs.MkdirAll(dstPath, sourceInfo.Mode())
 	if err != nil {
@@ -338,21 +612,30 @@ func (fs *osFileSystem) CopyDir(srcPath, dstPath string) error {
 
 	for _, file := range files {
 		fileSrcPath := filepath.Join(srcPath, file.Name())
-		fileDstPath := filepath.Join(dstPath, file.Name())
+		fileDstPath, err := pathutil.SafeJoin(dstPath, file.Name())
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Copying '%s'", fileSrcPath)
+		}
 
 		if file.IsDir() {
-			err = fs.CopyDir(fileSrcPath, fileDstPath)
+			err = fs.CopyDirWithOpts(fileSrcPath, fileDstPath, opts)
 			if err != nil {
 				return bosherr.WrapErrorf(err, "Copying sub-dir '%s' to '%s'", fileSrcPath, fileDstPath)
 			}
 		} else {
-			err = fs.CopyFile(fileSrcPath, fileDstPath)
+			err = fs.CopyFileWithOpts(fileSrcPath, fileDstPath, opts)
 			if err != nil {
 				return bosherr.WrapErrorf(err, "Copying file '%s' to '%s'", fileSrcPath, fileDstPath)
 			}
 		}
 	}
 
+	// adding the contents changed the mod time of the dir
+	err = fs.chtimes(dstPath, times)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Preserving times of dir '%s'", dstPath)
+	}
+
 	return nil
 }
 
@@ -376,7 +659,20 @@ func (fs *osFileSystem) TempFile(prefix string) (file File, err error) {
 	if fs.tempRoot == "" && fs.requiresTempRoot {
 		return nil, errors.New("Set a temp directory root with ChangeTempRoot before making temp files")
 	}
-	return ioutil.TempFile(fs.tempRoot, prefix)
+	osFile, err := ioutil.TempFile(fs.tempRoot, prefix)
+	if err != nil {
+		return nil, wrapReadOnlyErr(fs.tempRootOrDefault(), err)
+	}
+	return osFile, nil
+}
+
+func (fs *osFileSystem) TempFileInMemory(prefix string) (File, error) {
+	fs.logger.Debug(fs.logTag, "Creating in-memory temp file with prefix %s", prefix)
+	file, err := fs.tempFileInMemory(prefix)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating in-memory temp file")
+	}
+	return file, nil
 }
 
 func (fs *osFileSystem) TempDir(prefix string) (path string, err error) {
@@ -384,7 +680,60 @@ func (fs *osFileSystem) TempDir(prefix string) (path string, err error) {
 	if fs.tempRoot == "" && fs.requiresTempRoot {
 		return "", errors.New("Set a temp directory root with ChangeTempRoot before making temp directories")
 	}
-	return ioutil.TempDir(fs.tempRoot, prefix)
+	path, err = ioutil.TempDir(fs.tempRoot, prefix)
+	return path, wrapReadOnlyErr(fs.tempRootOrDefault(), err)
+}
+
+func (fs *osFileSystem) TempDirWithSpace(prefix string, requiredBytes uint64, opts ...TempDirWithSpaceOpts) (string, error) {
+	fs.logger.Debug(fs.logTag, "Creating temp dir with prefix %s and %d bytes available", prefix, requiredBytes)
+
+	var opt TempDirWithSpaceOpts
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	var roots []string
+	if fs.tempRoot != "" || !fs.requiresTempRoot {
+		roots = append(roots, fs.tempRootOrDefault())
+	}
+	roots = append(roots, opt.Candidates...)
+
+	if len(roots) == 0 {
+		return "", errors.New("Set a temp directory root with ChangeTempRoot before making temp directories")
+	}
+
+	spaceErr := InsufficientSpaceError{RequiredBytes: requiredBytes, RequiredInodes: opt.RequiredInodes}
+
+	for _, root := range roots {
+		space, err := fs.DiskSpace(root)
+		if err == nil && space.Fits(requiredBytes, opt.RequiredInodes) {
+			var path string
+			path, err = ioutil.TempDir(root, prefix)
+			if err == nil {
+				return path, nil
+			}
+			err = wrapReadOnlyErr(root, err)
+		}
+
+		spaceErr.Candidates = append(spaceErr.Candidates, TempRootCandidate{Path: root, Space: space, Err: err})
+	}
+
+	return "", spaceErr
+}
+
+func (fs *osFileSystem) DiskSpace(path string) (DiskSpace, error) {
+	space, err := diskSpace(path)
+	if err != nil {
+		return DiskSpace{}, bosherr.WrapErrorf(err, "Getting disk space for '%s'", path)
+	}
+	return space, nil
+}
+
+func (fs *osFileSystem) tempRootOrDefault() string {
+	if fs.tempRoot == "" {
+		return os.TempDir()
+	}
+	return fs.tempRoot
 }
 
 func (fs *osFileSystem) ChangeTempRoot(tempRootPath string) error {
@@ -398,10 +747,15 @@ func (fs *osFileSystem) ChangeTempRoot(tempRootPath string) error {
 
 func (fs *osFileSystem) RemoveAll(fileOrDir string) (err error) {
 	fs.logger.Debug(fs.logTag, "Remove all %s", fileOrDir)
-	err = fsWrapper.RemoveAll(fileOrDir)
+	err = wrapReadOnlyErr(fileOrDir, fsWrapper.RemoveAll(fileOrDir))
 	return
 }
 
+func (fs *osFileSystem) RemoveOnReboot(path string) error {
+	fs.logger.Debug(fs.logTag, "Removing %s on reboot", path)
+	return wrapReadOnlyErr(path, fs.removeOnReboot(path))
+}
+
 func (fs *osFileSystem) Glob(pattern string) (matches []string, err error) {
 	fs.logger.Debug(fs.logTag, "Glob '%s'", pattern)
 	return filepath.Glob(pattern)
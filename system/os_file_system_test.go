@@ -10,6 +10,7 @@ import (
 	osuser "os/user"
 	"path/filepath"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -202,6 +203,36 @@ var _ = Describe("OS FileSystem", func() {
 		Expect(readFile(createdFile)).To(Equal("testing new file"))
 	})
 
+	Describe("OpenFileWithShareMode", func() {
+		It("opens the file like OpenFile", func() {
+			osFs := createOsFs()
+			testPath := filepath.Join(GinkgoT().TempDir(), "OpenFileWithShareModeTestFile")
+
+			file, err := osFs.OpenFileWithShareMode(testPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, os.FileMode(0644), ShareAll)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = file.Write([]byte("first"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+
+			file, err = osFs.OpenFileWithShareMode(testPath, os.O_WRONLY|os.O_APPEND, 0, ShareRead)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = file.Write([]byte(" second"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+
+			Expect(osFs.ReadFileString(testPath)).To(Equal("first second"))
+		})
+
+		It("returns an error when the file does not exist", func() {
+			osFs := createOsFs()
+
+			_, err := osFs.OpenFileWithShareMode(filepath.Join(GinkgoT().TempDir(), "missing"), os.O_RDONLY, 0, ShareAll)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
 	Describe("Stat", func() {
 		It("returns file info", func() {
 			osFs := createOsFs()
@@ -223,6 +254,40 @@ var _ = Describe("OS FileSystem", func() {
 		})
 	})
 
+	Describe("Statx", func() {
+		It("returns file info with identity details", func() {
+			osFs := createOsFs()
+			testPath := filepath.Join(TempDir, "StatxTestFile")
+			linkPath := filepath.Join(TempDir, "StatxTestLink")
+
+			Expect(os.WriteFile(testPath, []byte("content"), 0644)).To(Succeed())
+			defer os.Remove(testPath)
+			Expect(os.Link(testPath, linkPath)).To(Succeed())
+			defer os.Remove(linkPath)
+
+			stat, err := osFs.Statx(testPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			osInfo, err := os.Stat(testPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.SameFile(stat.FileInfo, osInfo)).To(BeTrue())
+
+			Expect(stat.Size()).To(Equal(int64(7)))
+			Expect(stat.Inode).ToNot(BeZero())
+			Expect(stat.Nlink).To(Equal(uint64(2)))
+
+			linkStat, err := osFs.Statx(linkPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(linkStat.Inode).To(Equal(stat.Inode))
+		})
+
+		It("returns an error when the file does not exist", func() {
+			osFs := createOsFs()
+			_, err := osFs.Statx(filepath.Join(TempDir, "does-not-exist"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
 	Describe("ConvergeFileContents", func() {
 		It("converges file", func() {
 			osFs := createOsFs()
@@ -734,6 +799,38 @@ var _ = Describe("OS FileSystem", func() {
 		assert.NotEqual(GinkgoT(), file1.Name(), file2.Name())
 	})
 
+	Describe("Hardlink", func() {
+		It("makes the new path refer to the same file", func() {
+			osFs := createOsFs()
+			dir := GinkgoT().TempDir()
+			oldPath := filepath.Join(dir, "original")
+			newPath := filepath.Join(dir, "link")
+
+			err := osFs.WriteFileString(oldPath, "some content")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = osFs.Hardlink(oldPath, newPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			oldStats, err := os.Stat(oldPath)
+			Expect(err).ToNot(HaveOccurred())
+			newStats, err := os.Stat(newPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.SameFile(oldStats, newStats)).To(BeTrue())
+		})
+
+		It("fails when the new path already exists", func() {
+			osFs := createOsFs()
+			dir := GinkgoT().TempDir()
+
+			osFs.WriteFileString(filepath.Join(dir, "original"), "some content")
+			osFs.WriteFileString(filepath.Join(dir, "link"), "other content")
+
+			err := osFs.Hardlink(filepath.Join(dir, "original"), filepath.Join(dir, "link"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	It("temp dir", func() {
 		osFs := createOsFs()
 
@@ -938,6 +1035,114 @@ var _ = Describe("OS FileSystem", func() {
 		})
 	})
 
+	Describe("times", func() {
+		var (
+			osFs    FileSystem
+			dir     string
+			atime   time.Time
+			mtime   time.Time
+			modTime func(path string) time.Time
+		)
+
+		BeforeEach(func() {
+			osFs = createOsFs()
+			dir = GinkgoT().TempDir()
+
+			// 100ns is the precision of Windows FILETIMEs
+			atime = time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+			mtime = time.Date(2021, 6, 7, 8, 9, 10, 1100, time.UTC)
+
+			modTime = func(path string) time.Time {
+				info, err := os.Stat(path)
+				Expect(err).ToNot(HaveOccurred())
+				return info.ModTime()
+			}
+		})
+
+		It("sets the times with nanosecond precision", func() {
+			path := filepath.Join(dir, "file")
+			Expect(osFs.WriteFileString(path, "content")).To(Succeed())
+
+			Expect(osFs.Chtimes(path, FileTimes{AccessTime: atime, ModTime: mtime})).To(Succeed())
+
+			stat, err := osFs.Statx(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat.ModTime()).To(BeTemporally("==", mtime))
+			if !stat.AccessTime.IsZero() {
+				Expect(stat.AccessTime).To(BeTemporally("==", atime))
+			}
+		})
+
+		It("leaves zero times unchanged", func() {
+			path := filepath.Join(dir, "file")
+			Expect(osFs.WriteFileString(path, "content")).To(Succeed())
+			Expect(osFs.Chtimes(path, FileTimes{ModTime: mtime})).To(Succeed())
+
+			Expect(osFs.Chtimes(path, FileTimes{AccessTime: atime})).To(Succeed())
+
+			Expect(modTime(path)).To(BeTemporally("==", mtime))
+		})
+
+		It("sets the times of dirs", func() {
+			Expect(osFs.Chtimes(dir, FileTimes{ModTime: mtime})).To(Succeed())
+
+			Expect(modTime(dir)).To(BeTemporally("==", mtime))
+		})
+
+		It("preserves the times of copied files when asked", func() {
+			srcPath := filepath.Join(dir, "src")
+			Expect(osFs.WriteFileString(srcPath, "content")).To(Succeed())
+			Expect(osFs.Chtimes(srcPath, FileTimes{AccessTime: atime, ModTime: mtime})).To(Succeed())
+
+			Expect(osFs.CopyFile(srcPath, filepath.Join(dir, "copy"))).To(Succeed())
+			Expect(modTime(filepath.Join(dir, "copy"))).ToNot(BeTemporally("==", mtime))
+
+			dstPath := filepath.Join(dir, "preserved")
+			Expect(osFs.CopyFileWithOpts(srcPath, dstPath, CopyFileOpts{PreserveTimes: true})).To(Succeed())
+
+			Expect(osFs.ReadFileString(dstPath)).To(Equal("content"))
+			Expect(modTime(dstPath)).To(BeTemporally("==", mtime))
+		})
+
+		It("preserves the times of copied dirs and their contents when asked", func() {
+			srcPath := filepath.Join(dir, "src")
+			Expect(osFs.WriteFileString(filepath.Join(srcPath, "sub", "file"), "content")).To(Succeed())
+
+			for _, path := range []string{"sub/file", "sub", "."} {
+				Expect(osFs.Chtimes(filepath.Join(srcPath, path), FileTimes{ModTime: mtime})).To(Succeed())
+			}
+
+			dstPath := filepath.Join(dir, "dst")
+			Expect(osFs.CopyDirWithOpts(srcPath, dstPath, CopyFileOpts{PreserveTimes: true})).To(Succeed())
+
+			for _, path := range []string{"sub/file", "sub", "."} {
+				Expect(modTime(filepath.Join(dstPath, path))).To(BeTemporally("==", mtime), path)
+			}
+		})
+
+		It("preserves the times of rewritten files when asked", func() {
+			path := filepath.Join(dir, "file")
+			Expect(osFs.WriteFileString(path, "old")).To(Succeed())
+			Expect(osFs.Chtimes(path, FileTimes{ModTime: mtime})).To(Succeed())
+
+			Expect(osFs.WriteFileWithOpts(path, []byte("new"), WriteOpts{PreserveTimes: true})).To(Succeed())
+
+			Expect(osFs.ReadFileString(path)).To(Equal("new"))
+			Expect(modTime(path)).To(BeTemporally("==", mtime))
+
+			Expect(osFs.WriteFile(path, []byte("newer"))).To(Succeed())
+			Expect(modTime(path)).ToNot(BeTemporally("==", mtime))
+		})
+
+		It("writes new files when asked to preserve their times", func() {
+			path := filepath.Join(dir, "new")
+
+			Expect(osFs.WriteFileWithOpts(path, []byte("new"), WriteOpts{PreserveTimes: true})).To(Succeed())
+
+			Expect(osFs.ReadFileString(path)).To(Equal("new"))
+		})
+	})
+
 	It("remove all", func() {
 		osFs := createOsFs()
 
@@ -5,7 +5,9 @@ package system
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"syscall"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -21,6 +23,19 @@ func (fs *osFileSystem) homeDir(username string) (string, error) {
 	return homeDir, nil
 }
 
+func (fs *osFileSystem) openFileWithShareMode(path string, flag int, perm os.FileMode, _ ShareMode) (*os.File, error) {
+	return fs.openFile(path, flag, perm)
+}
+
+// openFileNoFollow fails with ELOOP instead of following a symlink
+func (fs *osFileSystem) openFileNoFollow(path string, flag int, perm os.FileMode) (*os.File, error) {
+	file, err := fs.openFile(path, flag|syscall.O_NOFOLLOW, perm)
+	if errors.Is(err, syscall.ELOOP) {
+		return nil, SymlinkError{Path: path}
+	}
+	return file, err
+}
+
 func (fs *osFileSystem) chown(path, owner string) error {
 	if owner == "" {
 		return errors.New("Failed to lookup user ''")
@@ -52,3 +67,22 @@ func (fs *osFileSystem) chown(path, owner string) error {
 func (fs *osFileSystem) symlinkPaths(oldPath, newPath string) (old, new string, err error) {
 	return oldPath, newPath, nil
 }
+
+func validateWritePath(path string) error {
+	return nil
+}
+
+// applyMode sets mode on a newly created file or dir
+func applyMode(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+// canSetBirthTime is false because utimensat cannot change the birth time
+const canSetBirthTime = false
+
+func (fs *osFileSystem) chtimes(path string, times FileTimes) error {
+	if times.AccessTime.IsZero() && times.ModTime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(path, times.AccessTime, times.ModTime)
+}
@@ -5,6 +5,7 @@ package system_test
 
 import (
 	"os"
+	"os/user"
 	"path/filepath"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -12,6 +13,10 @@ import (
 
 	"runtime"
 	"syscall"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
 )
 
 var _ = Describe("OS FileSystem", func() {
@@ -101,6 +106,54 @@ var _ = Describe("OS FileSystem", func() {
 		})
 	})
 
+	Describe("Statx", func() {
+		It("returns the owner of the file", func() {
+			testPath := filepath.Join(os.TempDir(), "StatxOwnerTestFile")
+			Expect(os.WriteFile(testPath, []byte{}, 0644)).To(Succeed())
+			defer os.Remove(testPath)
+
+			osFs := createOsFs()
+			stat, err := osFs.Statx(testPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(stat.UID).To(Equal(os.Getuid()))
+			Expect(stat.GID).To(Equal(os.Getgid()))
+			Expect(stat.ChangeTime).ToNot(BeZero())
+
+			if currentUser, err := user.Current(); err == nil {
+				Expect(stat.User).To(Equal(currentUser.Username))
+			}
+		})
+
+		It("does not follow symlinks with Lstatx", func() {
+			targetPath := filepath.Join(os.TempDir(), "LstatxTarget")
+			linkPath := filepath.Join(os.TempDir(), "LstatxLink")
+			Expect(os.WriteFile(targetPath, []byte{}, 0644)).To(Succeed())
+			defer os.Remove(targetPath)
+			Expect(os.Symlink(targetPath, linkPath)).To(Succeed())
+			defer os.Remove(linkPath)
+
+			osFs := createOsFs()
+			targetStat, err := osFs.Statx(linkPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(targetStat.Mode().IsRegular()).To(BeTrue())
+
+			linkStat, err := osFs.Lstatx(linkPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(linkStat.Mode() & os.ModeSymlink).ToNot(BeZero())
+			Expect(linkStat.Inode).ToNot(Equal(targetStat.Inode))
+		})
+	})
+
+	It("refuses to set the birth time", func() {
+		osFs := createOsFs()
+		path := filepath.Join(GinkgoT().TempDir(), "file")
+		Expect(osFs.WriteFileString(path, "content")).To(Succeed())
+
+		err := osFs.Chtimes(path, FileTimes{BirthTime: time.Now()})
+		Expect(err).To(MatchError(ContainSubstring("Setting the birth time")))
+	})
+
 	Describe("CopyDir", func() {
 		It("keeps the permissions", func() {
 			osFs := createOsFs()
@@ -127,4 +180,160 @@ var _ = Describe("OS FileSystem", func() {
 			Expect(fi.Mode()).To(Equal(os.FileMode(0400)))
 		})
 	})
+
+	Describe("creation modes", func() {
+		var (
+			dir      string
+			oldUmask int
+			newOsFs  func(opts OsFileSystemOpts) FileSystem
+			fileMode func(path string) os.FileMode
+		)
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			oldUmask = syscall.Umask(077)
+
+			newOsFs = func(opts OsFileSystemOpts) FileSystem {
+				return NewOsFileSystemWithOpts(boshlog.NewLogger(boshlog.LevelNone), opts)
+			}
+
+			fileMode = func(path string) os.FileMode {
+				info, err := os.Stat(path)
+				Expect(err).ToNot(HaveOccurred())
+				return info.Mode().Perm()
+			}
+		})
+
+		AfterEach(func() {
+			syscall.Umask(oldUmask)
+		})
+
+		It("applies the process umask by default", func() {
+			file, err := newOsFs(OsFileSystemOpts{}).OpenFile(filepath.Join(dir, "file"), os.O_CREATE|os.O_WRONLY, 0644)
+			Expect(err).ToNot(HaveOccurred())
+			file.Close()
+
+			Expect(fileMode(filepath.Join(dir, "file"))).To(Equal(os.FileMode(0600)))
+		})
+
+		It("enforces requested modes with ExactModes", func() {
+			osFs := newOsFs(OsFileSystemOpts{ExactModes: true})
+
+			file, err := osFs.OpenFile(filepath.Join(dir, "file"), os.O_CREATE|os.O_WRONLY, 0644)
+			Expect(err).ToNot(HaveOccurred())
+			file.Close()
+
+			err = osFs.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fileMode(filepath.Join(dir, "file"))).To(Equal(os.FileMode(0644)))
+			Expect(fileMode(filepath.Join(dir, "a"))).To(Equal(os.FileMode(0755)))
+			Expect(fileMode(filepath.Join(dir, "a", "b"))).To(Equal(os.FileMode(0755)))
+		})
+
+		It("uses Umask instead of the process umask", func() {
+			umask := os.FileMode(027)
+			osFs := newOsFs(OsFileSystemOpts{Umask: &umask})
+
+			err := osFs.WriteFileString(filepath.Join(dir, "nested", "file"), "content")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fileMode(filepath.Join(dir, "nested", "file"))).To(Equal(os.FileMode(0640)))
+			Expect(fileMode(filepath.Join(dir, "nested"))).To(Equal(os.FileMode(0750)))
+		})
+
+		It("does not change modes of existing files and dirs", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "file"), []byte("content"), 0600)).To(Succeed())
+			Expect(os.Chmod(dir, 0700)).To(Succeed())
+
+			osFs := newOsFs(OsFileSystemOpts{ExactModes: true})
+
+			err := osFs.WriteFileString(filepath.Join(dir, "file"), "new content")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fileMode(filepath.Join(dir, "file"))).To(Equal(os.FileMode(0600)))
+			Expect(fileMode(dir)).To(Equal(os.FileMode(0700)))
+		})
+	})
+
+	Describe("RemoveOnReboot", func() {
+		It("unlinks the file while open handles keep reading it", func() {
+			osFs := createOsFs()
+			testPath := filepath.Join(GinkgoT().TempDir(), "in-use")
+			Expect(osFs.WriteFileString(testPath, "old-binary")).To(Succeed())
+
+			file, err := os.Open(testPath)
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+
+			Expect(osFs.RemoveOnReboot(testPath)).To(Succeed())
+			Expect(osFs.FileExists(testPath)).To(BeFalse())
+
+			Expect(osFs.WriteFileString(testPath, "new-binary")).To(Succeed())
+			Expect(readFile(file)).To(Equal("old-binary"))
+			Expect(osFs.ReadFileString(testPath)).To(Equal("new-binary"))
+		})
+
+		It("succeeds when the path does not exist", func() {
+			osFs := createOsFs()
+			Expect(osFs.RemoveOnReboot(filepath.Join(GinkgoT().TempDir(), "missing"))).To(Succeed())
+		})
+
+		It("does not remove dirs that are not empty", func() {
+			osFs := createOsFs()
+			dir := GinkgoT().TempDir()
+			Expect(osFs.WriteFileString(filepath.Join(dir, "file"), "")).To(Succeed())
+
+			Expect(osFs.RemoveOnReboot(dir)).ToNot(Succeed())
+			Expect(osFs.FileExists(dir)).To(BeTrue())
+		})
+	})
+
+	Describe("NoFollow", func() {
+		var (
+			osFs   FileSystem
+			target string
+			link   string
+		)
+
+		BeforeEach(func() {
+			osFs = createOsFs()
+			dir := GinkgoT().TempDir()
+
+			target = filepath.Join(dir, "shadow")
+			Expect(osFs.WriteFileString(target, "root:x:0:0")).To(Succeed())
+
+			link = filepath.Join(dir, "job.conf")
+			Expect(os.Symlink(target, link)).To(Succeed())
+		})
+
+		It("refuses to write through a planted symlink", func() {
+			err := osFs.WriteFileWithOpts(link, []byte("pwned"), WriteOpts{NoFollow: true})
+			Expect(err).To(HaveOccurred())
+			Expect(IsSymlinkError(err)).To(BeTrue())
+
+			Expect(osFs.ReadFileString(target)).To(Equal("root:x:0:0"))
+		})
+
+		It("refuses to converge through a planted symlink", func() {
+			_, err := osFs.ConvergeFileContents(link, []byte("root:x:0:0"), ConvergeFileContentsOpts{NoFollow: true})
+			Expect(IsSymlinkError(err)).To(BeTrue())
+
+			_, err = osFs.ConvergeFileContents(link, []byte("pwned"), ConvergeFileContentsOpts{NoFollow: true, DryRun: true})
+			Expect(IsSymlinkError(err)).To(BeTrue())
+
+			Expect(osFs.ReadFileString(target)).To(Equal("root:x:0:0"))
+		})
+
+		It("writes regular files", func() {
+			path := filepath.Join(filepath.Dir(link), "other.conf")
+
+			Expect(osFs.WriteFileWithOpts(path, []byte("old"), WriteOpts{NoFollow: true})).To(Succeed())
+
+			written, err := osFs.ConvergeFileContents(path, []byte("new"), ConvergeFileContentsOpts{NoFollow: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(BeTrue())
+			Expect(osFs.ReadFileString(path)).To(Equal("new"))
+		})
+	})
 })
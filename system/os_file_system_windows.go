@@ -7,10 +7,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/fileutil/pathutil"
 )
 
+var kernel32DLL = syscall.NewLazyDLL("kernel32.dll")
+
 func (fs *osFileSystem) homeDir(username string) (string, error) {
 	u, err := user.Current()
 	if err != nil {
@@ -56,3 +61,162 @@ func (fs *osFileSystem) symlinkPaths(oldPath, newPath string) (old, new string,
 	}
 	return
 }
+
+// validateWritePath rejects paths that Windows would silently map to a
+// device (e.g. C:\data\NUL.txt) instead of creating a file.
+func validateWritePath(path string) error {
+	return pathutil.ValidatePath(path)
+}
+
+// openFileWithShareMode mirrors syscall.Open, which always shares files
+// for reading and writing but never for deletion
+func (fs *osFileSystem) openFileWithShareMode(path string, flag int, perm os.FileMode, share ShareMode) (*os.File, error) {
+	return createFile(path, flag, perm, share, 0)
+}
+
+// openFileNoFollow opens the reparse point itself instead of its target
+// and refuses to use it. The file is truncated only after that check.
+func (fs *osFileSystem) openFileNoFollow(path string, flag int, perm os.FileMode) (*os.File, error) {
+	file, err := createFile(path, flag&^os.O_TRUNC, perm, ShareRead|ShareWrite, syscall.FILE_FLAG_OPEN_REPARSE_POINT)
+	if err != nil {
+		return nil, err
+	}
+
+	var info syscall.ByHandleFileInformation
+
+	err = syscall.GetFileInformationByHandle(syscall.Handle(file.Fd()), &info)
+	if err != nil {
+		file.Close()
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	if info.FileAttributes&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0 {
+		file.Close()
+		return nil, SymlinkError{Path: path}
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		err = file.Truncate(0)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+// longPathPtr returns path with the long path prefix for the Win32 APIs
+func longPathPtr(path string) (*uint16, error) {
+	longPath, err := absPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(longPath, `\\`) {
+		longPath = `\\?\` + longPath
+	}
+
+	return syscall.UTF16PtrFromString(longPath)
+}
+
+func createFile(path string, flag int, perm os.FileMode, share ShareMode, extraAttrs uint32) (*os.File, error) {
+	pathp, err := longPathPtr(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	var access uint32
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		access = syscall.GENERIC_READ
+	case os.O_WRONLY:
+		access = syscall.GENERIC_WRITE
+	case os.O_RDWR:
+		access = syscall.GENERIC_READ | syscall.GENERIC_WRITE
+	}
+
+	if flag&os.O_CREATE != 0 {
+		access |= syscall.GENERIC_WRITE
+	}
+
+	if flag&os.O_APPEND != 0 {
+		access &^= syscall.GENERIC_WRITE
+		access |= syscall.FILE_APPEND_DATA
+	}
+
+	var createMode uint32
+	switch {
+	case flag&(os.O_CREATE|os.O_EXCL) == (os.O_CREATE | os.O_EXCL):
+		createMode = syscall.CREATE_NEW
+	case flag&(os.O_CREATE|os.O_TRUNC) == (os.O_CREATE | os.O_TRUNC):
+		createMode = syscall.CREATE_ALWAYS
+	case flag&os.O_CREATE == os.O_CREATE:
+		createMode = syscall.OPEN_ALWAYS
+	case flag&os.O_TRUNC == os.O_TRUNC:
+		createMode = syscall.TRUNCATE_EXISTING
+	default:
+		createMode = syscall.OPEN_EXISTING
+	}
+
+	attrs := uint32(syscall.FILE_ATTRIBUTE_NORMAL)
+	if perm&0200 == 0 {
+		attrs = syscall.FILE_ATTRIBUTE_READONLY
+	}
+	attrs |= extraAttrs
+
+	handle, err := syscall.CreateFile(pathp, access, uint32(share), nil, createMode, attrs, 0)
+	if err != nil {
+		err = &os.PathError{Op: "open", Path: path, Err: err}
+		if isWriteFlag(flag) {
+			return nil, wrapReadOnlyErr(path, err)
+		}
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(handle), path), nil
+}
+
+const (
+	fileWriteAttributes = 0x100
+
+	canSetBirthTime = true
+)
+
+// chtimes sets the FILETIMEs, which have a precision of 100ns, through a
+// handle so that the creation time can be changed and dirs are supported
+func (fs *osFileSystem) chtimes(path string, times FileTimes) error {
+	if times == (FileTimes{}) {
+		return nil
+	}
+
+	pathp, err := longPathPtr(path)
+	if err != nil {
+		return &os.PathError{Op: "chtimes", Path: path, Err: err}
+	}
+
+	handle, err := syscall.CreateFile(pathp, fileWriteAttributes,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return &os.PathError{Op: "chtimes", Path: path, Err: err}
+	}
+	defer syscall.CloseHandle(handle)
+
+	err = syscall.SetFileTime(handle, filetime(times.BirthTime), filetime(times.AccessTime), filetime(times.ModTime))
+	if err != nil {
+		return &os.PathError{Op: "chtimes", Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// filetime returns nil for zero times, which SetFileTime leaves unchanged
+func filetime(t time.Time) *syscall.Filetime {
+	if t.IsZero() {
+		return nil
+	}
+
+	ft := syscall.NsecToFiletime(t.UnixNano())
+	return &ft
+}
@@ -5,6 +5,7 @@ import (
 	"path"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -12,6 +13,8 @@ import (
 	"io/ioutil"
 
 	fsWrapper "github.com/charlievieth/fs"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
 )
 
 var _ = Describe("Windows Specific tests", func() {
@@ -25,6 +28,25 @@ var _ = Describe("Windows Specific tests", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("sets and preserves the creation time", func() {
+		osFs := createOsFs()
+		dir := GinkgoT().TempDir()
+		birthTime := time.Date(2019, 1, 2, 3, 4, 5, 600, time.UTC)
+
+		srcPath := filepath.Join(dir, "src")
+		Expect(osFs.WriteFileString(srcPath, "content")).To(Succeed())
+		Expect(osFs.Chtimes(srcPath, FileTimes{BirthTime: birthTime})).To(Succeed())
+
+		dstPath := filepath.Join(dir, "dst")
+		Expect(osFs.CopyFileWithOpts(srcPath, dstPath, CopyFileOpts{PreserveTimes: true})).To(Succeed())
+
+		for _, path := range []string{srcPath, dstPath} {
+			stat, err := osFs.Statx(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stat.BirthTime).To(BeTemporally("==", birthTime))
+		}
+	})
+
 	Describe("CopyDir", func() {
 		It("doesn't keep the permissions because they do not behave the same in windows", func() {
 			osFs := createOsFs()
@@ -78,6 +100,35 @@ var _ = Describe("Windows Specific tests", func() {
 		Expect(os.IsNotExist(err)).To(BeTrue())
 	})
 
+	Describe("OpenFileWithShareMode", func() {
+		It("allows renaming open files with ShareDelete", func() {
+			osFs := createOsFs()
+			dir := GinkgoT().TempDir()
+
+			file, err := osFs.OpenFileWithShareMode(filepath.Join(dir, "current.log"), os.O_WRONLY|os.O_CREATE, 0644, ShareRead|ShareWrite|ShareDelete)
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+
+			Expect(osFs.Rename(filepath.Join(dir, "current.log"), filepath.Join(dir, "rotated.log"))).To(Succeed())
+		})
+
+		It("denies access not covered by the share mode", func() {
+			osFs := createOsFs()
+			testPath := filepath.Join(GinkgoT().TempDir(), "exclusive.log")
+
+			file, err := osFs.OpenFileWithShareMode(testPath, os.O_WRONLY|os.O_CREATE, 0644, ShareRead)
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+
+			_, err = osFs.OpenFile(testPath, os.O_WRONLY, 0644)
+			Expect(err).To(HaveOccurred())
+
+			readFile, err := osFs.OpenFile(testPath, os.O_RDONLY, 0)
+			Expect(err).ToNot(HaveOccurred())
+			readFile.Close()
+		})
+	})
+
 	// Alert future developers that a previously unimplemented
 	// function in the os package is now implemented on Windows.
 	It("fails if os features are implemented in Windows", func() {
@@ -0,0 +1,274 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type overlayMount struct {
+	prefix string
+	fs     FileSystem
+}
+
+// overlayFileSystem serves paths under mounted prefixes from their own
+// file system, e.g. a fake that injects failures, and all other paths
+// from the real file system
+type overlayFileSystem struct {
+	real   FileSystem
+	mounts []overlayMount
+}
+
+// NewOverlayFileSystem returns a FileSystem that serves paths under the
+// prefixes in overrides from the mapped file system and everything else from
+// real. The longest matching prefix wins. Temp files and dirs are always
+// created on real, and Glob, RecursiveGlob and Walk are served entirely by
+// the file system their pattern or root maps to.
+func NewOverlayFileSystem(real FileSystem, overrides map[string]FileSystem) FileSystem {
+	mounts := []overlayMount{}
+	for prefix, fs := range overrides {
+		mounts = append(mounts, overlayMount{prefix: filepath.Clean(prefix), fs: fs})
+	}
+
+	sort.Slice(mounts, func(i, j int) bool {
+		return len(mounts[i].prefix) > len(mounts[j].prefix)
+	})
+
+	return &overlayFileSystem{real: real, mounts: mounts}
+}
+
+func (o *overlayFileSystem) fsFor(path string) FileSystem {
+	path = filepath.Clean(path)
+
+	for _, mount := range o.mounts {
+		if path == mount.prefix || strings.HasPrefix(path, strings.TrimSuffix(mount.prefix, string(filepath.Separator))+string(filepath.Separator)) {
+			return mount.fs
+		}
+	}
+
+	return o.real
+}
+
+func (o *overlayFileSystem) HomeDir(username string) (string, error) {
+	return o.real.HomeDir(username)
+}
+
+func (o *overlayFileSystem) ExpandPath(path string) (string, error) {
+	return o.real.ExpandPath(path)
+}
+
+func (o *overlayFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return o.fsFor(path).MkdirAll(path, perm)
+}
+
+func (o *overlayFileSystem) RemoveAll(fileOrDir string) error {
+	return o.fsFor(fileOrDir).RemoveAll(fileOrDir)
+}
+
+func (o *overlayFileSystem) RemoveOnReboot(path string) error {
+	return o.fsFor(path).RemoveOnReboot(path)
+}
+
+func (o *overlayFileSystem) Chown(path, username string) error {
+	return o.fsFor(path).Chown(path, username)
+}
+
+func (o *overlayFileSystem) Chmod(path string, perm os.FileMode) error {
+	return o.fsFor(path).Chmod(path, perm)
+}
+
+func (o *overlayFileSystem) Chtimes(path string, times FileTimes) error {
+	return o.fsFor(path).Chtimes(path, times)
+}
+
+func (o *overlayFileSystem) OpenFile(path string, flag int, perm os.FileMode) (File, error) {
+	return o.fsFor(path).OpenFile(path, flag, perm)
+}
+
+func (o *overlayFileSystem) OpenFileWithShareMode(path string, flag int, perm os.FileMode, share ShareMode) (File, error) {
+	return o.fsFor(path).OpenFileWithShareMode(path, flag, perm, share)
+}
+
+func (o *overlayFileSystem) WriteFileString(path, content string) error {
+	return o.fsFor(path).WriteFileString(path, content)
+}
+
+func (o *overlayFileSystem) WriteFile(path string, content []byte) error {
+	return o.fsFor(path).WriteFile(path, content)
+}
+
+func (o *overlayFileSystem) WriteFileQuietly(path string, content []byte) error {
+	return o.fsFor(path).WriteFileQuietly(path, content)
+}
+
+func (o *overlayFileSystem) WriteFileWithOpts(path string, content []byte, opts WriteOpts) error {
+	return o.fsFor(path).WriteFileWithOpts(path, content, opts)
+}
+
+func (o *overlayFileSystem) ConvergeFileContents(path string, content []byte, opts ...ConvergeFileContentsOpts) (bool, error) {
+	return o.fsFor(path).ConvergeFileContents(path, content, opts...)
+}
+
+func (o *overlayFileSystem) ReadFileString(path string) (string, error) {
+	return o.fsFor(path).ReadFileString(path)
+}
+
+func (o *overlayFileSystem) ReadFile(path string) ([]byte, error) {
+	return o.fsFor(path).ReadFile(path)
+}
+
+func (o *overlayFileSystem) ReadFileWithOpts(path string, opts ReadOpts) ([]byte, error) {
+	return o.fsFor(path).ReadFileWithOpts(path, opts)
+}
+
+func (o *overlayFileSystem) FileExists(path string) bool {
+	return o.fsFor(path).FileExists(path)
+}
+
+func (o *overlayFileSystem) Stat(path string) (os.FileInfo, error) {
+	return o.fsFor(path).Stat(path)
+}
+
+func (o *overlayFileSystem) StatWithOpts(path string, opts StatOpts) (os.FileInfo, error) {
+	return o.fsFor(path).StatWithOpts(path, opts)
+}
+
+func (o *overlayFileSystem) Lstat(path string) (os.FileInfo, error) {
+	return o.fsFor(path).Lstat(path)
+}
+
+func (o *overlayFileSystem) Statx(path string) (FileStat, error) {
+	return o.fsFor(path).Statx(path)
+}
+
+func (o *overlayFileSystem) Lstatx(path string) (FileStat, error) {
+	return o.fsFor(path).Lstatx(path)
+}
+
+func (o *overlayFileSystem) IsReadOnly(path string) (bool, error) {
+	return o.fsFor(path).IsReadOnly(path)
+}
+
+func (o *overlayFileSystem) Rename(oldPath, newPath string) error {
+	fs, err := o.sameFS("Renaming", oldPath, newPath)
+	if err != nil {
+		return err
+	}
+
+	return fs.Rename(oldPath, newPath)
+}
+
+// Symlink is served by the file system of newPath, oldPath is only the link target
+func (o *overlayFileSystem) Symlink(oldPath, newPath string) error {
+	return o.fsFor(newPath).Symlink(oldPath, newPath)
+}
+
+func (o *overlayFileSystem) Hardlink(oldPath, newPath string) error {
+	fs, err := o.sameFS("Hardlinking", oldPath, newPath)
+	if err != nil {
+		return err
+	}
+
+	return fs.Hardlink(oldPath, newPath)
+}
+
+func (o *overlayFileSystem) ReadAndFollowLink(symlinkPath string) (string, error) {
+	return o.fsFor(symlinkPath).ReadAndFollowLink(symlinkPath)
+}
+
+func (o *overlayFileSystem) Readlink(symlinkPath string) (string, error) {
+	return o.fsFor(symlinkPath).Readlink(symlinkPath)
+}
+
+func (o *overlayFileSystem) CopyFile(srcPath, dstPath string) error {
+	return o.CopyFileWithOpts(srcPath, dstPath, CopyFileOpts{})
+}
+
+func (o *overlayFileSystem) CopyFileWithOpts(srcPath, dstPath string, opts CopyFileOpts) error {
+	srcFS, dstFS := o.fsFor(srcPath), o.fsFor(dstPath)
+	if srcFS == dstFS {
+		return srcFS.CopyFileWithOpts(srcPath, dstPath, opts)
+	}
+
+	var times FileTimes
+	if opts.PreserveTimes {
+		stat, err := srcFS.Statx(srcPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading times of '%s'", srcPath)
+		}
+		times = FileTimes{AccessTime: stat.AccessTime, ModTime: stat.ModTime()}
+	}
+
+	contents, err := srcFS.ReadFile(srcPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading '%s'", srcPath)
+	}
+
+	err = dstFS.WriteFile(dstPath, contents)
+	if err != nil || !opts.PreserveTimes {
+		return err
+	}
+
+	return dstFS.Chtimes(dstPath, times)
+}
+
+func (o *overlayFileSystem) CopyDir(srcPath, dstPath string) error {
+	return o.CopyDirWithOpts(srcPath, dstPath, CopyFileOpts{})
+}
+
+func (o *overlayFileSystem) CopyDirWithOpts(srcPath, dstPath string, opts CopyFileOpts) error {
+	fs, err := o.sameFS("Copying", srcPath, dstPath)
+	if err != nil {
+		return err
+	}
+
+	return fs.CopyDirWithOpts(srcPath, dstPath, opts)
+}
+
+func (o *overlayFileSystem) TempFile(prefix string) (File, error) {
+	return o.real.TempFile(prefix)
+}
+
+func (o *overlayFileSystem) TempFileInMemory(prefix string) (File, error) {
+	return o.real.TempFileInMemory(prefix)
+}
+
+func (o *overlayFileSystem) TempDir(prefix string) (string, error) {
+	return o.real.TempDir(prefix)
+}
+
+func (o *overlayFileSystem) TempDirWithSpace(prefix string, requiredBytes uint64, opts ...TempDirWithSpaceOpts) (string, error) {
+	return o.real.TempDirWithSpace(prefix, requiredBytes, opts...)
+}
+
+func (o *overlayFileSystem) DiskSpace(path string) (DiskSpace, error) {
+	return o.fsFor(path).DiskSpace(path)
+}
+
+func (o *overlayFileSystem) ChangeTempRoot(path string) error {
+	return o.real.ChangeTempRoot(path)
+}
+
+func (o *overlayFileSystem) Glob(pattern string) ([]string, error) {
+	return o.fsFor(pattern).Glob(pattern)
+}
+
+func (o *overlayFileSystem) RecursiveGlob(pattern string) ([]string, error) {
+	return o.fsFor(pattern).RecursiveGlob(pattern)
+}
+
+func (o *overlayFileSystem) Walk(root string, walkFunc filepath.WalkFunc) error {
+	return o.fsFor(root).Walk(root, walkFunc)
+}
+
+func (o *overlayFileSystem) sameFS(action, oldPath, newPath string) (FileSystem, error) {
+	fs := o.fsFor(oldPath)
+	if fs != o.fsFor(newPath) {
+		return nil, bosherr.Errorf("%s '%s' to '%s': paths are served by different overlay file systems", action, oldPath, newPath)
+	}
+
+	return fs, nil
+}
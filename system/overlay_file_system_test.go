@@ -0,0 +1,105 @@
+package system_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("OverlayFileSystem", func() {
+	var (
+		realDir   string
+		fakeDir   string
+		realFS    FileSystem
+		fakeFS    *fakesys.FakeFileSystem
+		overlayFS FileSystem
+	)
+
+	BeforeEach(func() {
+		realDir = GinkgoT().TempDir()
+		fakeDir = filepath.Join(realDir, "agent")
+
+		realFS = NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		fakeFS = fakesys.NewFakeFileSystem()
+
+		overlayFS = NewOverlayFileSystem(realFS, map[string]FileSystem{fakeDir: fakeFS})
+	})
+
+	It("serves paths under overridden prefixes from the override", func() {
+		Expect(overlayFS.WriteFileString(filepath.Join(fakeDir, "settings.json"), "fake-settings")).To(Succeed())
+
+		Expect(fakeFS.FileExists(filepath.Join(fakeDir, "settings.json"))).To(BeTrue())
+		Expect(realFS.FileExists(filepath.Join(fakeDir, "settings.json"))).To(BeFalse())
+
+		contents, err := overlayFS.ReadFileString(filepath.Join(fakeDir, "settings.json"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contents).To(Equal("fake-settings"))
+	})
+
+	It("serves all other paths from the real file system", func() {
+		Expect(overlayFS.WriteFileString(filepath.Join(realDir, "agent-other"), "real")).To(Succeed())
+
+		Expect(realFS.FileExists(filepath.Join(realDir, "agent-other"))).To(BeTrue())
+		Expect(fakeFS.FileExists(filepath.Join(realDir, "agent-other"))).To(BeFalse())
+	})
+
+	It("returns errors injected into the override", func() {
+		fakeFS.WriteFileError = errors.New("fake-write-error")
+
+		err := overlayFS.WriteFileString(filepath.Join(fakeDir, "settings.json"), "fake-settings")
+		Expect(err).To(MatchError("fake-write-error"))
+
+		Expect(overlayFS.WriteFileString(filepath.Join(realDir, "settings.json"), "real")).To(Succeed())
+	})
+
+	It("prefers the longest matching prefix", func() {
+		nestedFS := fakesys.NewFakeFileSystem()
+		overlayFS = NewOverlayFileSystem(realFS, map[string]FileSystem{
+			fakeDir:                          fakeFS,
+			filepath.Join(fakeDir, "nested"): nestedFS,
+		})
+
+		Expect(overlayFS.WriteFileString(filepath.Join(fakeDir, "nested", "file"), "nested")).To(Succeed())
+		Expect(overlayFS.WriteFileString(filepath.Join(fakeDir, "file"), "fake")).To(Succeed())
+
+		Expect(nestedFS.FileExists(filepath.Join(fakeDir, "nested", "file"))).To(BeTrue())
+		Expect(fakeFS.FileExists(filepath.Join(fakeDir, "nested", "file"))).To(BeFalse())
+		Expect(fakeFS.FileExists(filepath.Join(fakeDir, "file"))).To(BeTrue())
+	})
+
+	It("copies files between file systems", func() {
+		srcPath := filepath.Join(realDir, "src")
+		Expect(realFS.WriteFileString(srcPath, "contents")).To(Succeed())
+
+		Expect(overlayFS.CopyFile(srcPath, filepath.Join(fakeDir, "dst"))).To(Succeed())
+
+		contents, err := fakeFS.ReadFileString(filepath.Join(fakeDir, "dst"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(contents).To(Equal("contents"))
+	})
+
+	It("does not rename across file systems", func() {
+		srcPath := filepath.Join(realDir, "src")
+		Expect(realFS.WriteFileString(srcPath, "contents")).To(Succeed())
+
+		err := overlayFS.Rename(srcPath, filepath.Join(fakeDir, "dst"))
+		Expect(err).To(MatchError(ContainSubstring("paths are served by different overlay file systems")))
+		Expect(realFS.FileExists(srcPath)).To(BeTrue())
+	})
+
+	It("creates temp files on the real file system", func() {
+		file, err := overlayFS.TempFile("overlay")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(file.Name())
+		defer file.Close()
+
+		Expect(realFS.FileExists(file.Name())).To(BeTrue())
+	})
+})
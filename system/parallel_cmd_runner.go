@@ -0,0 +1,121 @@
+package system
+
+import (
+	"context"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/work"
+)
+
+// ParallelCmdRunner runs independent commands at the same time,
+// e.g. to format several disks
+type ParallelCmdRunner struct {
+	runner      CmdRunner
+	concurrency int
+}
+
+func NewParallelCmdRunner(runner CmdRunner, concurrency int) ParallelCmdRunner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return ParallelCmdRunner{runner: runner, concurrency: concurrency}
+}
+
+// Run runs at most concurrency commands at a time and returns their
+// results in the order of cmds. A failing command does not stop the
+// others. Once ctx is done no more commands are started and running ones
+// are stopped according to their Command.Termination. Commands that did
+// not start get an ExitStatus of -1 and an Error.
+//
+// The error lists every command that failed or did not run.
+func (r ParallelCmdRunner) Run(ctx context.Context, cmds []Command) ([]Result, error) {
+	results := make([]Result, len(cmds))
+	started := make([]bool, len(cmds))
+
+	var lock sync.Mutex
+	var running sync.WaitGroup
+	var closed bool
+
+	tasks := make([]work.Task, len(cmds))
+
+	for i, cmd := range cmds {
+		i, cmd := i, cmd
+
+		tasks[i] = work.Task{
+			Name: formatTranscriptCommand(cmd.Name, cmd.Args),
+			Do: func(ctx context.Context) error {
+				// the pool may give up on a task before it runs
+				lock.Lock()
+				if closed || ctx.Err() != nil {
+					lock.Unlock()
+					return nil
+				}
+				started[i] = true
+				running.Add(1)
+				lock.Unlock()
+
+				defer running.Done()
+
+				results[i] = r.run(ctx, cmd)
+
+				return nil
+			},
+		}
+	}
+
+	pool := work.Pool{Count: r.concurrency}
+	pool.ParallelDoContext(ctx, tasks...)
+
+	lock.Lock()
+	closed = true
+	lock.Unlock()
+
+	running.Wait()
+
+	var errs []error
+
+	for i := range results {
+		if !started[i] {
+			results[i] = Result{ExitStatus: -1, Error: bosherr.WrapError(ctx.Err(), "Command was not started")}
+		}
+
+		if results[i].Error != nil {
+			errs = append(errs, bosherr.WrapErrorf(results[i].Error, "Running command #%d '%s'", i+1, tasks[i].Name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return results, bosherr.NewMultiError(errs...)
+	}
+
+	return results, nil
+}
+
+func (r ParallelCmdRunner) run(ctx context.Context, cmd Command) Result {
+	process, err := r.runner.RunComplexCommandAsync(cmd)
+	if err != nil {
+		return Result{ExitStatus: -1, Error: err}
+	}
+
+	resultCh := process.Wait()
+
+	select {
+	case result := <-resultCh:
+		return result
+	case <-ctx.Done():
+	}
+
+	err = process.Terminate()
+	if err != nil {
+		return Result{ExitStatus: -1, Error: bosherr.WrapError(err, "Terminating command")}
+	}
+
+	result := <-resultCh
+	if result.Error == nil {
+		result.Error = bosherr.WrapError(ctx.Err(), "Command was terminated")
+	}
+
+	return result
+}
@@ -0,0 +1,111 @@
+package system_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("ParallelCmdRunner", func() {
+	Context("with a fake runner", func() {
+		var (
+			inner *fakesys.FakeCmdRunner
+		)
+
+		BeforeEach(func() {
+			inner = fakesys.NewFakeCmdRunner()
+		})
+
+		It("returns results in the order of the commands", func() {
+			inner.AddProcess("mkfs sdb", &fakesys.FakeProcess{WaitResult: Result{Stdout: "fake-sdb"}})
+			inner.AddProcess("mkfs sdc", &fakesys.FakeProcess{WaitResult: Result{Stdout: "fake-sdc"}})
+			inner.AddProcess("mkfs sdd", &fakesys.FakeProcess{WaitResult: Result{Stdout: "fake-sdd"}})
+
+			results, err := NewParallelCmdRunner(inner, 2).Run(context.Background(), []Command{
+				{Name: "mkfs", Args: []string{"sdb"}},
+				{Name: "mkfs", Args: []string{"sdc"}},
+				{Name: "mkfs", Args: []string{"sdd"}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(results).To(HaveLen(3))
+			Expect(results[0].Stdout).To(Equal("fake-sdb"))
+			Expect(results[1].Stdout).To(Equal("fake-sdc"))
+			Expect(results[2].Stdout).To(Equal("fake-sdd"))
+		})
+
+		It("runs every command when some fail", func() {
+			inner.AddProcess("mkfs sdb", &fakesys.FakeProcess{StartErr: errors.New("fake-start-err")})
+			inner.AddProcess("mkfs sdc", &fakesys.FakeProcess{WaitResult: Result{ExitStatus: 1, Error: errors.New("fake-err")}})
+			inner.AddProcess("mkfs sdd", &fakesys.FakeProcess{WaitResult: Result{Stdout: "fake-sdd"}})
+
+			results, err := NewParallelCmdRunner(inner, 1).Run(context.Background(), []Command{
+				{Name: "mkfs", Args: []string{"sdb"}},
+				{Name: "mkfs", Args: []string{"sdc"}},
+				{Name: "mkfs", Args: []string{"sdd"}},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Running command #1 'mkfs sdb': fake-start-err"))
+			Expect(err.Error()).To(ContainSubstring("Running command #2 'mkfs sdc': fake-err"))
+			Expect(err.Error()).ToNot(ContainSubstring("#3"))
+
+			Expect(results[0].ExitStatus).To(Equal(-1))
+			Expect(results[1].ExitStatus).To(Equal(1))
+			Expect(results[2].Stdout).To(Equal("fake-sdd"))
+		})
+	})
+
+	Context("with the exec runner", func() {
+		var (
+			inner CmdRunner
+		)
+
+		BeforeEach(func() {
+			if runtime.GOOS == "windows" {
+				Skip("Uses unix commands")
+			}
+
+			inner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+		})
+
+		It("runs at most concurrency commands at a time", func() {
+			sleep := Command{Name: "sleep", Args: []string{"0.3"}}
+
+			startedAt := time.Now()
+
+			results, err := NewParallelCmdRunner(inner, 2).Run(context.Background(), []Command{sleep, sleep, sleep, sleep})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(4))
+
+			Expect(time.Since(startedAt)).To(BeNumerically(">=", 600*time.Millisecond))
+			Expect(time.Since(startedAt)).To(BeNumerically("<", 1200*time.Millisecond))
+		})
+
+		It("terminates running commands and skips the rest once the context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			sleep := Command{Name: "sleep", Args: []string{"10"}}
+
+			startedAt := time.Now()
+
+			results, err := NewParallelCmdRunner(inner, 2).Run(ctx, []Command{sleep, sleep, sleep})
+			Expect(err).To(HaveOccurred())
+			Expect(time.Since(startedAt)).To(BeNumerically("<", 5*time.Second))
+
+			Expect(results[0].Error).To(HaveOccurred())
+			Expect(results[1].Error).To(HaveOccurred())
+			Expect(results[2].ExitStatus).To(Equal(-1))
+			Expect(results[2].Error).To(MatchError(ContainSubstring("Command was not started")))
+			Expect(errors.Is(results[2].Error, context.DeadlineExceeded)).To(BeTrue())
+		})
+	})
+})
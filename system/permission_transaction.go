@@ -0,0 +1,201 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const permissionModeMask = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+type PermissionChangeKind string
+
+const (
+	PermissionChangeMode  PermissionChangeKind = "mode"
+	PermissionChangeOwner PermissionChangeKind = "owner"
+)
+
+// PermissionChange is a single audited Chmod or Chown. Previous values are
+// captured before the change is applied; PreviousOwner is empty when the
+// owner could not be determined (e.g. on Windows).
+type PermissionChange struct {
+	Kind PermissionChangeKind
+	Path string
+
+	PreviousMode  os.FileMode
+	PreviousOwner string
+
+	Mode  os.FileMode
+	Owner string
+
+	// Err is set when the change could not be applied
+	Err error
+
+	ChangedAt time.Time
+}
+
+// PermissionTransaction applies Chmod/Chown operations while recording the
+// previous mode and owner of every path so that a partially applied set of
+// changes can be rolled back. The recorded changes are never modified.
+type PermissionTransaction struct {
+	fs FileSystem
+
+	lock    sync.Mutex
+	changes []PermissionChange
+	done    bool
+}
+
+func NewPermissionTransaction(fs FileSystem) *PermissionTransaction {
+	return &PermissionTransaction{fs: fs}
+}
+
+func (t *PermissionTransaction) Chmod(path string, perm os.FileMode) error {
+	return t.apply(PermissionChange{Kind: PermissionChangeMode, Path: path, Mode: perm})
+}
+
+func (t *PermissionTransaction) Chown(path, owner string) error {
+	return t.apply(PermissionChange{Kind: PermissionChangeOwner, Path: path, Owner: owner})
+}
+
+// ChmodTree sets dirPerm on root and all directories below it and filePerm
+// on all other files. Symlinks are not followed.
+func (t *PermissionTransaction) ChmodTree(root string, dirPerm, filePerm os.FileMode) error {
+	return t.walk(root, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			return t.Chmod(path, dirPerm)
+		}
+		return t.Chmod(path, filePerm)
+	})
+}
+
+// ChownTree changes the owner of root and everything below it.
+// Symlinks are not followed.
+func (t *PermissionTransaction) ChownTree(root, owner string) error {
+	return t.walk(root, func(path string, _ os.FileInfo) error {
+		return t.Chown(path, owner)
+	})
+}
+
+// Changes returns a copy of all recorded changes in the order they were made
+func (t *PermissionTransaction) Changes() []PermissionChange {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return append([]PermissionChange(nil), t.changes...)
+}
+
+// Commit keeps all applied changes. No further changes can be made.
+func (t *PermissionTransaction) Commit() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.done {
+		return bosherr.Error("Permission transaction already finished")
+	}
+
+	t.done = true
+	return nil
+}
+
+// Rollback restores previous modes and owners in reverse order. It continues
+// past errors and returns all of them. No further changes can be made.
+func (t *PermissionTransaction) Rollback() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.done {
+		return bosherr.Error("Permission transaction already finished")
+	}
+	t.done = true
+
+	var errs []error
+
+	for i := len(t.changes) - 1; i >= 0; i-- {
+		change := t.changes[i]
+		if change.Err != nil {
+			continue
+		}
+
+		switch change.Kind {
+		case PermissionChangeMode:
+			err := t.fs.Chmod(change.Path, change.PreviousMode)
+			if err != nil {
+				errs = append(errs, bosherr.WrapErrorf(err, "Restoring mode of '%s'", change.Path))
+			}
+		case PermissionChangeOwner:
+			if change.PreviousOwner == "" {
+				continue
+			}
+
+			err := t.fs.Chown(change.Path, change.PreviousOwner)
+			if err != nil {
+				errs = append(errs, bosherr.WrapErrorf(err, "Restoring owner of '%s'", change.Path))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return bosherr.NewMultiError(errs...)
+	}
+
+	return nil
+}
+
+func (t *PermissionTransaction) apply(change PermissionChange) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.done {
+		return bosherr.Error("Permission transaction already finished")
+	}
+
+	// Chmod and Chown follow symlinks so the target's state is recorded
+	stat, err := t.fs.Statx(change.Path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Recording permissions of '%s'", change.Path)
+	}
+
+	change.PreviousMode = stat.Mode() & permissionModeMask
+	change.PreviousOwner = previousOwner(stat)
+	change.ChangedAt = time.Now()
+
+	switch change.Kind {
+	case PermissionChangeMode:
+		change.Err = t.fs.Chmod(change.Path, change.Mode)
+	case PermissionChangeOwner:
+		change.Err = t.fs.Chown(change.Path, change.Owner)
+	}
+
+	t.changes = append(t.changes, change)
+
+	return change.Err
+}
+
+func (t *PermissionTransaction) walk(root string, fn func(string, os.FileInfo) error) error {
+	return t.fs.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		return fn(path, info)
+	})
+}
+
+func previousOwner(stat FileStat) string {
+	if stat.User != "" && stat.Group != "" {
+		return stat.User + ":" + stat.Group
+	}
+
+	if stat.UID >= 0 && stat.GID >= 0 {
+		return fmt.Sprintf("%d:%d", stat.UID, stat.GID)
+	}
+
+	return ""
+}
@@ -0,0 +1,122 @@
+package system_test
+
+import (
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("PermissionTransaction", func() {
+	var (
+		fs *fakesys.FakeFileSystem
+		tx *PermissionTransaction
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		Expect(fs.MkdirAll("/jobs/sub", os.FileMode(0750))).To(Succeed())
+		Expect(fs.WriteFileString("/jobs/sub/config", "fake-config")).To(Succeed())
+		Expect(fs.Chmod("/jobs", os.FileMode(0750))).To(Succeed())
+		Expect(fs.Chmod("/jobs/sub/config", os.FileMode(0640))).To(Succeed())
+		Expect(fs.Chown("/jobs/sub/config", "vcap:vcap")).To(Succeed())
+
+		tx = NewPermissionTransaction(fs)
+	})
+
+	mode := func(path string) os.FileMode {
+		return fs.GetFileTestStat(path).FileMode
+	}
+
+	It("records previous modes and owners of every change", func() {
+		Expect(tx.Chmod("/jobs/sub/config", os.FileMode(0600))).To(Succeed())
+		Expect(tx.Chown("/jobs/sub/config", "root:root")).To(Succeed())
+
+		changes := tx.Changes()
+		Expect(changes).To(HaveLen(2))
+
+		Expect(changes[0].Kind).To(Equal(PermissionChangeMode))
+		Expect(changes[0].Path).To(Equal("/jobs/sub/config"))
+		Expect(changes[0].PreviousMode).To(Equal(os.FileMode(0640)))
+		Expect(changes[0].Mode).To(Equal(os.FileMode(0600)))
+		Expect(changes[0].ChangedAt).ToNot(BeZero())
+
+		Expect(changes[1].Kind).To(Equal(PermissionChangeOwner))
+		Expect(changes[1].PreviousOwner).To(Equal("vcap:vcap"))
+		Expect(changes[1].Owner).To(Equal("root:root"))
+
+		changes[0].Path = "modified"
+		Expect(tx.Changes()[0].Path).To(Equal("/jobs/sub/config"))
+	})
+
+	It("applies modes across a tree", func() {
+		Expect(tx.ChmodTree("/jobs", os.FileMode(0700), os.FileMode(0600))).To(Succeed())
+
+		Expect(mode("/jobs")).To(Equal(os.FileMode(0700)))
+		Expect(mode("/jobs/sub")).To(Equal(os.FileMode(0700)))
+		Expect(mode("/jobs/sub/config")).To(Equal(os.FileMode(0600)))
+		Expect(tx.Changes()).To(HaveLen(3))
+
+		Expect(tx.Commit()).To(Succeed())
+		Expect(mode("/jobs/sub/config")).To(Equal(os.FileMode(0600)))
+	})
+
+	It("rolls back partially applied changes", func() {
+		Expect(tx.ChmodTree("/jobs", os.FileMode(0700), os.FileMode(0600))).To(Succeed())
+		Expect(tx.Chmod("/jobs/sub/config", os.FileMode(0400))).To(Succeed())
+
+		fs.ChownErr = errors.New("fake-chown-err")
+		err := tx.ChownTree("/jobs", "root:root")
+		Expect(err).To(MatchError("fake-chown-err"))
+		Expect(tx.Changes()[4].Err).To(MatchError("fake-chown-err"))
+		fs.ChownErr = nil
+
+		Expect(tx.Rollback()).To(Succeed())
+
+		Expect(mode("/jobs")).To(Equal(os.FileMode(0750)))
+		Expect(mode("/jobs/sub")).To(Equal(os.FileMode(0750)))
+		Expect(mode("/jobs/sub/config")).To(Equal(os.FileMode(0640)))
+	})
+
+	It("restores previous owners on rollback", func() {
+		Expect(tx.Chown("/jobs/sub/config", "root:root")).To(Succeed())
+		Expect(tx.Rollback()).To(Succeed())
+
+		Expect(fs.GetFileTestStat("/jobs/sub/config").Username).To(Equal("vcap"))
+		Expect(fs.GetFileTestStat("/jobs/sub/config").Groupname).To(Equal("vcap"))
+	})
+
+	It("continues rolling back after errors and returns all of them", func() {
+		Expect(tx.Chmod("/jobs", os.FileMode(0700))).To(Succeed())
+		Expect(tx.Chmod("/jobs/sub", os.FileMode(0700))).To(Succeed())
+
+		fs.ChmodErr = errors.New("fake-chmod-err")
+
+		err := tx.Rollback()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Restoring mode of '/jobs/sub': fake-chmod-err"))
+		Expect(err.Error()).To(ContainSubstring("Restoring mode of '/jobs': fake-chmod-err"))
+		Expect(fs.ChmodCallCount).To(Equal(6))
+	})
+
+	It("returns an error when the previous state cannot be recorded", func() {
+		fs.StatxErr = errors.New("fake-statx-err")
+
+		err := tx.Chmod("/jobs", os.FileMode(0700))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Recording permissions of '/jobs': fake-statx-err"))
+		Expect(mode("/jobs")).To(Equal(os.FileMode(0750)))
+		Expect(tx.Changes()).To(BeEmpty())
+	})
+
+	It("does not allow changes once finished", func() {
+		Expect(tx.Commit()).To(Succeed())
+
+		Expect(tx.Chmod("/jobs", os.FileMode(0700))).To(MatchError("Permission transaction already finished"))
+		Expect(tx.Rollback()).To(MatchError("Permission transaction already finished"))
+	})
+})
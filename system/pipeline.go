@@ -0,0 +1,140 @@
+package system
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// RunPipeline connects the commands with OS pipes so that intermediate
+// output is never held in memory. All commands run at the same time.
+func (r execCmdRunner) RunPipeline(cmds ...Command) ([]Result, error) {
+	err := validatePipeline(cmds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cmd := range cmds {
+		err := waitForPreconditions(cmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	processes := make([]Process, 0, len(cmds))
+	writers := make([]*os.File, len(cmds))
+
+	// abort stops the stages that were already started
+	abort := func(err error) ([]Result, error) {
+		for _, process := range processes {
+			resultCh := process.Wait()
+			process.Terminate()
+			<-resultCh
+		}
+		for _, writer := range writers {
+			if writer != nil {
+				writer.Close()
+			}
+		}
+		return nil, err
+	}
+
+	var reader *os.File
+
+	for i, cmd := range cmds {
+		if reader != nil {
+			cmd.Stdin = reader
+		}
+
+		if i < len(cmds)-1 {
+			nextReader, writer, err := os.Pipe()
+			if err != nil {
+				reader.Close()
+				return abort(bosherr.WrapError(err, "Creating pipe"))
+			}
+
+			cmd.Stdout = writer
+			writers[i] = writer
+			reader = nextReader
+		} else {
+			reader = nil
+		}
+
+		// already waited for
+		cmd.Preconditions = nil
+
+		process, err := r.start(cmd)
+
+		// the started process holds its own copy of the read end
+		if cmd.Stdin != nil && i > 0 {
+			cmd.Stdin.(*os.File).Close()
+		}
+
+		if err != nil {
+			if reader != nil {
+				reader.Close()
+			}
+			return abort(bosherr.WrapErrorf(err, "Starting pipeline stage %d", i+1))
+		}
+
+		processes = append(processes, process)
+	}
+
+	results := make([]Result, len(processes))
+
+	var wg sync.WaitGroup
+
+	for i, process := range processes {
+		wg.Add(1)
+		go func(i int, process Process, resultCh <-chan Result) {
+			defer wg.Done()
+			results[i] = <-resultCh
+
+			// the next stage sees EOF once no write end is left open
+			if writers[i] != nil {
+				writers[i].Close()
+			}
+		}(i, process, process.Wait())
+	}
+
+	wg.Wait()
+
+	return results, pipelineError(cmds, results)
+}
+
+func validatePipeline(cmds []Command) error {
+	if len(cmds) == 0 {
+		return bosherr.Error("Pipeline needs at least one command")
+	}
+
+	for i, cmd := range cmds {
+		if i > 0 && cmd.Stdin != nil {
+			return bosherr.Errorf("Pipeline stage %d '%s' must not set Stdin", i+1, cmd.Name)
+		}
+		if i < len(cmds)-1 && cmd.Stdout != nil {
+			return bosherr.Errorf("Pipeline stage %d '%s' must not set Stdout", i+1, cmd.Name)
+		}
+	}
+
+	return nil
+}
+
+// pipelineError wraps the error of the first failing stage
+func pipelineError(cmds []Command, results []Result) error {
+	for i, result := range results {
+		if result.Error != nil {
+			return bosherr.WrapErrorf(result.Error, "Running pipeline stage %d of '%s'", i+1, formatPipeline(cmds))
+		}
+	}
+	return nil
+}
+
+func formatPipeline(cmds []Command) string {
+	stages := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		stages[i] = strings.Join(append([]string{cmd.Name}, cmd.Args...), " ")
+	}
+	return strings.Join(stages, " | ")
+}
@@ -0,0 +1,122 @@
+package system_test
+
+import (
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("execCmdRunner RunPipeline", func() {
+	var (
+		runner CmdRunner
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip("Pipelines are tested with unix tools")
+		}
+
+		runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	It("connects the stdout of each command to the stdin of the next one", func() {
+		results, err := runner.RunPipeline(
+			Command{Name: "printf", Args: []string{`b\na\nc\n`}},
+			Command{Name: "sort"},
+			Command{Name: "head", Args: []string{"-n", "2"}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(results).To(HaveLen(3))
+		Expect(results[0].Stdout).To(BeEmpty())
+		Expect(results[1].Stdout).To(BeEmpty())
+		Expect(results[2].Stdout).To(Equal("a\nb\n"))
+	})
+
+	It("passes stdin to the first command", func() {
+		results, err := runner.RunPipeline(
+			Command{Name: "cat", Stdin: strings.NewReader("fake-input")},
+			Command{Name: "tr", Args: []string{"a-z", "A-Z"}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[1].Stdout).To(Equal("FAKE-INPUT"))
+	})
+
+	It("streams output between commands", func() {
+		results, err := runner.RunPipeline(
+			Command{Name: "head", Args: []string{"-c", "50000000", "/dev/zero"}},
+			Command{Name: "wc", Args: []string{"-c"}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.TrimSpace(results[1].Stdout)).To(Equal("50000000"))
+	})
+
+	It("returns the exit status and stderr of every stage", func() {
+		results, err := runner.RunPipeline(
+			Command{Name: "bash", Args: []string{"-c", "echo fake-output; echo fake-stderr >&2; exit 3"}},
+			Command{Name: "cat"},
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Running pipeline stage 1 of 'bash -c"))
+
+		Expect(results[0].ExitStatus).To(Equal(3))
+		Expect(results[0].Stderr).To(Equal("fake-stderr\n"))
+		Expect(results[1].ExitStatus).To(Equal(0))
+		Expect(results[1].Stdout).To(Equal("fake-output\n"))
+	})
+
+	It("lets writers stop once the reading command exited", func() {
+		done := make(chan struct{})
+
+		var results []Result
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+
+			results, _ = runner.RunPipeline(
+				Command{Name: "yes"},
+				Command{Name: "head", Args: []string{"-n", "1"}},
+			)
+		}()
+
+		Eventually(done, 10*time.Second).Should(BeClosed())
+		Expect(results[0].Signal).To(Equal(syscall.SIGPIPE))
+		Expect(results[1].Stdout).To(Equal("y\n"))
+	})
+
+	It("kills started commands when a later one cannot be started", func() {
+		startedAt := time.Now()
+
+		_, err := runner.RunPipeline(
+			Command{Name: "sleep", Args: []string{"10"}},
+			Command{Name: "fake-missing-command"},
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Starting pipeline stage 2"))
+		Expect(time.Since(startedAt)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	It("returns an error when inner stages set stdin or stdout", func() {
+		_, err := runner.RunPipeline(
+			Command{Name: "echo"},
+			Command{Name: "cat", Stdin: strings.NewReader("fake-input")},
+		)
+		Expect(err).To(MatchError("Pipeline stage 2 'cat' must not set Stdin"))
+
+		_, err = runner.RunPipeline(
+			Command{Name: "echo", Stdout: &strings.Builder{}},
+			Command{Name: "cat"},
+		)
+		Expect(err).To(MatchError("Pipeline stage 1 'echo' must not set Stdout"))
+
+		_, err = runner.RunPipeline()
+		Expect(err).To(MatchError("Pipeline needs at least one command"))
+	})
+})
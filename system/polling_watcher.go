@@ -0,0 +1,239 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type PollingWatcherOpts struct {
+	// MinInterval is used while files keep changing; defaults to 250ms
+	MinInterval time.Duration
+
+	// MaxInterval is reached by doubling the interval after every poll
+	// without changes; defaults to 5s
+	MaxInterval time.Duration
+}
+
+type pollingSnapshot struct {
+	modTime time.Time
+	size    int64
+	mode    os.FileMode
+}
+
+// pollingWatcher detects changes by comparing modification times, sizes and
+// modes of files, for file systems without change notifications like NFS
+type pollingWatcher struct {
+	fs   FileSystem
+	opts PollingWatcherOpts
+
+	// roots maps watched paths to snapshots of themselves and their entries
+	roots     map[string]map[string]pollingSnapshot
+	rootsLock sync.Mutex
+
+	events  chan WatchEvent
+	errors  chan error
+	closeCh chan struct{}
+	doneCh  chan struct{}
+	closed  sync.Once
+}
+
+func NewPollingWatcher(fs FileSystem, opts PollingWatcherOpts) Watcher {
+	if opts.MinInterval <= 0 {
+		opts.MinInterval = 250 * time.Millisecond
+	}
+	if opts.MaxInterval < opts.MinInterval {
+		opts.MaxInterval = 5 * time.Second
+		if opts.MaxInterval < opts.MinInterval {
+			opts.MaxInterval = opts.MinInterval
+		}
+	}
+
+	w := &pollingWatcher{
+		fs:      fs,
+		opts:    opts,
+		roots:   map[string]map[string]pollingSnapshot{},
+		events:  make(chan WatchEvent),
+		errors:  make(chan error, 1),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// Add takes the initial snapshot of path, which does not have to exist yet
+func (w *pollingWatcher) Add(path string) error {
+	snapshot, err := w.scan(path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Watching '%s'", path)
+	}
+
+	w.rootsLock.Lock()
+	defer w.rootsLock.Unlock()
+
+	if _, found := w.roots[path]; !found {
+		w.roots[path] = snapshot
+	}
+
+	return nil
+}
+
+func (w *pollingWatcher) Remove(path string) error {
+	w.rootsLock.Lock()
+	defer w.rootsLock.Unlock()
+
+	if _, found := w.roots[path]; !found {
+		return bosherr.Errorf("Path '%s' is not watched", path)
+	}
+
+	delete(w.roots, path)
+
+	return nil
+}
+
+func (w *pollingWatcher) Events() <-chan WatchEvent { return w.events }
+func (w *pollingWatcher) Errors() <-chan error      { return w.errors }
+
+func (w *pollingWatcher) Close() error {
+	w.closed.Do(func() {
+		close(w.closeCh)
+		<-w.doneCh
+		close(w.events)
+		close(w.errors)
+	})
+
+	return nil
+}
+
+func (w *pollingWatcher) run() {
+	defer close(w.doneCh)
+
+	interval := w.opts.MinInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case <-timer.C:
+		}
+
+		events := w.poll()
+
+		for _, event := range events {
+			select {
+			case w.events <- event:
+			case <-w.closeCh:
+				return
+			}
+		}
+
+		if len(events) > 0 {
+			interval = w.opts.MinInterval
+		} else if interval *= 2; interval > w.opts.MaxInterval {
+			interval = w.opts.MaxInterval
+		}
+
+		timer.Reset(interval)
+	}
+}
+
+func (w *pollingWatcher) poll() []WatchEvent {
+	w.rootsLock.Lock()
+	defer w.rootsLock.Unlock()
+
+	var events []WatchEvent
+
+	for root, old := range w.roots {
+		current, err := w.scan(root)
+		if err != nil {
+			w.reportError(bosherr.WrapErrorf(err, "Polling '%s'", root))
+			continue
+		}
+
+		events = append(events, diffPollingSnapshots(old, current)...)
+		w.roots[root] = current
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+
+	return events
+}
+
+func (w *pollingWatcher) reportError(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
+
+func (w *pollingWatcher) scan(root string) (map[string]pollingSnapshot, error) {
+	snapshot := map[string]pollingSnapshot{}
+
+	if !w.fs.FileExists(root) {
+		return snapshot, nil
+	}
+
+	info, err := w.fs.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		snapshot[root] = pollingSnapshot{modTime: info.ModTime(), size: info.Size(), mode: info.Mode()}
+		return snapshot, nil
+	}
+
+	// changes of entries are reported instead of writes to the directory
+	snapshot[root] = pollingSnapshot{mode: info.Mode()}
+
+	entries, err := w.fs.Glob(filepath.Join(root, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		info, err := w.fs.Lstat(entry)
+		if err != nil {
+			// removed since listing the directory
+			continue
+		}
+
+		snapshot[entry] = pollingSnapshot{modTime: info.ModTime(), size: info.Size(), mode: info.Mode()}
+	}
+
+	return snapshot, nil
+}
+
+func diffPollingSnapshots(old, current map[string]pollingSnapshot) []WatchEvent {
+	var events []WatchEvent
+
+	for path, snapshot := range current {
+		oldSnapshot, found := old[path]
+
+		switch {
+		case !found:
+			events = append(events, WatchEvent{Path: path, Op: WatchCreate})
+		case !snapshot.modTime.Equal(oldSnapshot.modTime) || snapshot.size != oldSnapshot.size:
+			events = append(events, WatchEvent{Path: path, Op: WatchWrite})
+		case snapshot.mode != oldSnapshot.mode:
+			events = append(events, WatchEvent{Path: path, Op: WatchChmod})
+		}
+	}
+
+	for path := range old {
+		if _, found := current[path]; !found {
+			events = append(events, WatchEvent{Path: path, Op: WatchRemove})
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,101 @@
+package system_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("PollingWatcher", func() {
+	var (
+		dir     string
+		fs      FileSystem
+		watcher Watcher
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		fs = NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		watcher = NewPollingWatcher(fs, PollingWatcherOpts{MinInterval: 10 * time.Millisecond, MaxInterval: 40 * time.Millisecond})
+	})
+
+	AfterEach(func() {
+		Expect(watcher.Close()).To(Succeed())
+	})
+
+	It("reports entries of watched directories being created, written and removed", func() {
+		Expect(watcher.Add(dir)).To(Succeed())
+
+		path := filepath.Join(dir, "file")
+		Expect(os.WriteFile(path, []byte("a"), 0644)).To(Succeed())
+		Eventually(watcher.Events()).Should(Receive(Equal(WatchEvent{Path: path, Op: WatchCreate})))
+
+		Expect(os.WriteFile(path, []byte("longer"), 0644)).To(Succeed())
+		Eventually(watcher.Events()).Should(Receive(Equal(WatchEvent{Path: path, Op: WatchWrite})))
+
+		Expect(os.Remove(path)).To(Succeed())
+		Eventually(watcher.Events()).Should(Receive(Equal(WatchEvent{Path: path, Op: WatchRemove})))
+
+		Consistently(watcher.Events(), 100*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("reports watched files that do not exist yet", func() {
+		path := filepath.Join(dir, "later")
+		Expect(watcher.Add(path)).To(Succeed())
+
+		Expect(os.WriteFile(path, []byte("a"), 0644)).To(Succeed())
+		Eventually(watcher.Events()).Should(Receive(Equal(WatchEvent{Path: path, Op: WatchCreate})))
+	})
+
+	It("reports mode changes", func() {
+		if Windows {
+			Skip("Windows only knows read only files")
+		}
+
+		path := filepath.Join(dir, "file")
+		Expect(os.WriteFile(path, []byte("a"), 0644)).To(Succeed())
+		Expect(watcher.Add(path)).To(Succeed())
+
+		Expect(os.Chmod(path, 0600)).To(Succeed())
+		Eventually(watcher.Events()).Should(Receive(Equal(WatchEvent{Path: path, Op: WatchChmod})))
+	})
+
+	It("slows down while nothing changes and speeds up after changes", func() {
+		watcher.Close()
+		watcher = NewPollingWatcher(fs, PollingWatcherOpts{MinInterval: 10 * time.Millisecond, MaxInterval: 2 * time.Second})
+		Expect(watcher.Add(dir)).To(Succeed())
+
+		// the interval grows to 1.28s after 8 idle polls
+		time.Sleep(1300 * time.Millisecond)
+
+		Expect(os.WriteFile(filepath.Join(dir, "first"), []byte("a"), 0644)).To(Succeed())
+		Consistently(watcher.Events(), 300*time.Millisecond).ShouldNot(Receive())
+		Eventually(watcher.Events(), 3*time.Second).Should(Receive())
+
+		Expect(os.WriteFile(filepath.Join(dir, "second"), []byte("a"), 0644)).To(Succeed())
+		Eventually(watcher.Events(), 200*time.Millisecond).Should(Receive())
+	})
+
+	It("stops reporting removed paths", func() {
+		Expect(watcher.Add(dir)).To(Succeed())
+		Expect(watcher.Remove(dir)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(dir, "file"), []byte("a"), 0644)).To(Succeed())
+		Consistently(watcher.Events(), 100*time.Millisecond).ShouldNot(Receive())
+
+		Expect(watcher.Remove(dir)).To(MatchError(ContainSubstring("is not watched")))
+	})
+
+	It("closes its channels on Close", func() {
+		Expect(watcher.Close()).To(Succeed())
+
+		Eventually(watcher.Events()).Should(BeClosed())
+		Eventually(watcher.Errors()).Should(BeClosed())
+	})
+})
@@ -0,0 +1,127 @@
+package system
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	defaultPreconditionTimeout = 30 * time.Second
+	preconditionPollInterval   = 100 * time.Millisecond
+)
+
+// Precondition is waited for before a command is started. Exactly one of
+// PathExists, PortListening or FileContains has to be set.
+type Precondition struct {
+	PathExists string
+
+	// PortListening is a host:port that has to accept TCP connections
+	PortListening string
+
+	// FileContains is a path that has to contain Contains
+	FileContains string
+	Contains     string
+
+	// Timeout defaults to 30s
+	Timeout time.Duration
+}
+
+func (p Precondition) String() string {
+	switch {
+	case p.PathExists != "":
+		return fmt.Sprintf("path '%s' to exist", p.PathExists)
+	case p.PortListening != "":
+		return fmt.Sprintf("'%s' to accept connections", p.PortListening)
+	case p.FileContains != "":
+		return fmt.Sprintf("'%s' to contain '%s'", p.FileContains, p.Contains)
+	default:
+		return "empty precondition"
+	}
+}
+
+// PreconditionError is returned instead of starting a command
+// when one of its preconditions was not met in time
+type PreconditionError struct {
+	Command      string
+	Precondition Precondition
+	LastErr      error
+}
+
+func (e PreconditionError) Error() string {
+	msg := fmt.Sprintf("Command '%s' was not started: timed out waiting for %s", e.Command, e.Precondition)
+	if e.LastErr != nil {
+		msg += ": " + e.LastErr.Error()
+	}
+	return msg
+}
+
+func (e PreconditionError) Unwrap() error { return e.LastErr }
+
+func waitForPreconditions(cmd Command) error {
+	for _, precondition := range cmd.Preconditions {
+		err := waitForPrecondition(precondition)
+		if err != nil {
+			return PreconditionError{Command: cmd.Name, Precondition: precondition, LastErr: err}
+		}
+	}
+
+	return nil
+}
+
+// waitForPrecondition returns the reason the precondition was not met
+// the last time it was checked
+func waitForPrecondition(precondition Precondition) error {
+	timeout := precondition.Timeout
+	if timeout == 0 {
+		timeout = defaultPreconditionTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		met, err := checkPrecondition(precondition, time.Until(deadline))
+		if met {
+			return nil
+		}
+
+		if time.Now().Add(preconditionPollInterval).After(deadline) {
+			return err
+		}
+
+		time.Sleep(preconditionPollInterval)
+	}
+}
+
+func checkPrecondition(precondition Precondition, remaining time.Duration) (bool, error) {
+	switch {
+	case precondition.PathExists != "":
+		_, err := os.Stat(precondition.PathExists)
+		return err == nil, err
+
+	case precondition.PortListening != "":
+		if remaining > time.Second {
+			remaining = time.Second
+		}
+
+		conn, err := net.DialTimeout("tcp", precondition.PortListening, remaining)
+		if err != nil {
+			return false, err
+		}
+
+		return true, conn.Close()
+
+	case precondition.FileContains != "":
+		contents, err := os.ReadFile(precondition.FileContains)
+		if err != nil {
+			return false, err
+		}
+
+		return bytes.Contains(contents, []byte(precondition.Contains)), nil
+
+	default:
+		return false, fmt.Errorf("PathExists, PortListening or FileContains must be set")
+	}
+}
@@ -0,0 +1,88 @@
+package system_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("Command.Preconditions", func() {
+	var (
+		runner CmdRunner
+		dir    string
+	)
+
+	BeforeEach(func() {
+		runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+		dir = GinkgoT().TempDir()
+	})
+
+	cmdWith := func(preconditions ...Precondition) Command {
+		return Command{Name: CatExePath, Args: []string{os.DevNull}, Preconditions: preconditions}
+	}
+
+	It("runs the command once a path appears", func() {
+		path := filepath.Join(dir, "ready")
+		time.AfterFunc(200*time.Millisecond, func() {
+			defer GinkgoRecover()
+			Expect(os.WriteFile(path, nil, 0644)).To(Succeed())
+		})
+
+		_, _, exitStatus, err := runner.RunComplexCommand(cmdWith(Precondition{PathExists: path, Timeout: 5 * time.Second}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exitStatus).To(Equal(0))
+	})
+
+	It("runs the command once a file contains the expected string", func() {
+		path := filepath.Join(dir, "log")
+		Expect(os.WriteFile(path, []byte("starting\n"), 0644)).To(Succeed())
+		time.AfterFunc(200*time.Millisecond, func() {
+			defer GinkgoRecover()
+			Expect(os.WriteFile(path, []byte("starting\nready\n"), 0644)).To(Succeed())
+		})
+
+		_, _, _, err := runner.RunComplexCommand(cmdWith(Precondition{FileContains: path, Contains: "ready", Timeout: 5 * time.Second}))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("runs the command once a port accepts connections", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+
+		_, _, _, err = runner.RunComplexCommand(cmdWith(Precondition{PortListening: listener.Addr().String(), Timeout: 5 * time.Second}))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("does not start the command when a precondition times out", func() {
+		path := filepath.Join(dir, "never")
+
+		_, _, exitStatus, err := runner.RunComplexCommand(cmdWith(Precondition{PathExists: path, Timeout: 300 * time.Millisecond}))
+		Expect(err).To(HaveOccurred())
+		Expect(exitStatus).To(Equal(-1))
+		Expect(err.Error()).To(ContainSubstring("timed out waiting for path '" + path + "' to exist"))
+
+		var preconditionErr PreconditionError
+		Expect(errors.As(err, &preconditionErr)).To(BeTrue())
+		Expect(preconditionErr.Precondition.PathExists).To(Equal(path))
+		Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+	})
+
+	It("checks preconditions before starting async commands", func() {
+		_, err := runner.RunComplexCommandAsync(cmdWith(Precondition{FileContains: filepath.Join(dir, "missing"), Contains: "x", Timeout: 200 * time.Millisecond}))
+		Expect(err).To(BeAssignableToTypeOf(PreconditionError{}))
+	})
+
+	It("fails empty preconditions", func() {
+		_, _, _, err := runner.RunComplexCommand(cmdWith(Precondition{Timeout: 100 * time.Millisecond}))
+		Expect(err).To(MatchError(ContainSubstring("PathExists, PortListening or FileContains must be set")))
+	})
+})
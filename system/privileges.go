@@ -0,0 +1,121 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Privileges answers privilege questions up front, so callers can fail
+// with an actionable error instead of interpreting EPERM afterwards
+type Privileges interface {
+	// IsRoot reports an effective uid of 0 on unix, on Windows it is
+	// the same as IsElevated
+	IsRoot() bool
+
+	// IsElevated reports whether the process runs with a full (UAC elevated)
+	// administrator token on Windows and as root elsewhere
+	IsElevated() (bool, error)
+
+	// HasCapability checks the effective Linux capabilities of the process.
+	// Other platforms have no capabilities and report IsElevated instead.
+	HasCapability(capability Capability) (bool, error)
+
+	// CanBindPrivilegedPorts reports whether ports below 1024 can be bound
+	CanBindPrivilegedPorts() (bool, error)
+}
+
+// Capability is a Linux capability number as defined in linux/capability.h
+type Capability int
+
+const (
+	CapChown          Capability = 0
+	CapDacOverride    Capability = 1
+	CapFowner         Capability = 3
+	CapKill           Capability = 5
+	CapSetgid         Capability = 6
+	CapSetuid         Capability = 7
+	CapNetBindService Capability = 10
+	CapNetAdmin       Capability = 12
+	CapNetRaw         Capability = 13
+	CapSysChroot      Capability = 18
+	CapSysAdmin       Capability = 21
+	CapSysResource    Capability = 24
+)
+
+var capabilityNames = map[Capability]string{
+	CapChown:          "CAP_CHOWN",
+	CapDacOverride:    "CAP_DAC_OVERRIDE",
+	CapFowner:         "CAP_FOWNER",
+	CapKill:           "CAP_KILL",
+	CapSetgid:         "CAP_SETGID",
+	CapSetuid:         "CAP_SETUID",
+	CapNetBindService: "CAP_NET_BIND_SERVICE",
+	CapNetAdmin:       "CAP_NET_ADMIN",
+	CapNetRaw:         "CAP_NET_RAW",
+	CapSysChroot:      "CAP_SYS_CHROOT",
+	CapSysAdmin:       "CAP_SYS_ADMIN",
+	CapSysResource:    "CAP_SYS_RESOURCE",
+}
+
+func (c Capability) String() string {
+	if name, found := capabilityNames[c]; found {
+		return name
+	}
+	return fmt.Sprintf("CAP_%d", int(c))
+}
+
+// InsufficientPrivilegesError is returned by the Require helpers
+type InsufficientPrivilegesError struct {
+	Operation   string
+	Required    string
+	Remediation string
+}
+
+func (e InsufficientPrivilegesError) Error() string {
+	return fmt.Sprintf("%s requires %s: %s", e.Operation, e.Required, e.Remediation)
+}
+
+type privileges struct{}
+
+func NewPrivileges() Privileges {
+	return privileges{}
+}
+
+// RequireCapability returns an InsufficientPrivilegesError when the process
+// lacks capability, operation describes what the caller is about to do
+func RequireCapability(p Privileges, capability Capability, operation string) error {
+	has, err := p.HasCapability(capability)
+	if err != nil {
+		return err
+	}
+
+	if has {
+		return nil
+	}
+
+	return InsufficientPrivilegesError{
+		Operation: operation,
+		Required:  capability.String(),
+		Remediation: fmt.Sprintf("run as root or grant the capability with 'setcap %s+ep <binary>'",
+			strings.ToLower(capability.String())),
+	}
+}
+
+// RequireElevated returns an InsufficientPrivilegesError unless the process
+// runs as root or as an elevated administrator
+func RequireElevated(p Privileges, operation string) error {
+	elevated, err := p.IsElevated()
+	if err != nil {
+		return err
+	}
+
+	if elevated {
+		return nil
+	}
+
+	return InsufficientPrivilegesError{
+		Operation:   operation,
+		Required:    "elevated privileges",
+		Remediation: "run as root, or from an elevated administrator prompt on Windows",
+	}
+}
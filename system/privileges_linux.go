@@ -0,0 +1,72 @@
+package system
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func (p privileges) IsRoot() bool {
+	return os.Geteuid() == 0
+}
+
+func (p privileges) IsElevated() (bool, error) {
+	return p.IsRoot(), nil
+}
+
+// HasCapability reads the effective set since root may run with a reduced
+// bounding set, e.g. in containers
+func (p privileges) HasCapability(capability Capability) (bool, error) {
+	capEff, err := procStatusField("CapEff")
+	if err != nil {
+		return false, err
+	}
+
+	mask, err := strconv.ParseUint(capEff, 16, 64)
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Parsing effective capabilities '%s'", capEff)
+	}
+
+	return mask&(1<<uint(capability)) != 0, nil
+}
+
+func (p privileges) CanBindPrivilegedPorts() (bool, error) {
+	has, err := p.HasCapability(CapNetBindService)
+	if err != nil || has {
+		return has, err
+	}
+
+	// only available since Linux 4.11
+	start, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start")
+	if err != nil {
+		return false, nil
+	}
+
+	return strings.TrimSpace(string(start)) == "0", nil
+}
+
+func procStatusField(name string) (string, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return "", bosherr.WrapError(err, "Opening /proc/self/status")
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		field, value, found := strings.Cut(scanner.Text(), ":")
+		if found && field == name {
+			return strings.TrimSpace(value), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", bosherr.WrapError(err, "Reading /proc/self/status")
+	}
+
+	return "", bosherr.Errorf("Finding '%s' in /proc/self/status", name)
+}
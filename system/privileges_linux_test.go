@@ -0,0 +1,27 @@
+package system_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("Privileges on Linux", func() {
+	It("reports effective capabilities", func() {
+		privileges := NewPrivileges()
+
+		has, err := privileges.HasCapability(CapSysAdmin)
+		Expect(err).ToNot(HaveOccurred())
+
+		if os.Geteuid() != 0 {
+			Expect(has).To(BeFalse())
+		}
+
+		has, err = privileges.HasCapability(Capability(63))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeFalse())
+	})
+})
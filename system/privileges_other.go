@@ -0,0 +1,24 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package system
+
+import (
+	"os"
+)
+
+func (p privileges) IsRoot() bool {
+	return os.Geteuid() == 0
+}
+
+func (p privileges) IsElevated() (bool, error) {
+	return p.IsRoot(), nil
+}
+
+func (p privileges) HasCapability(_ Capability) (bool, error) {
+	return p.IsRoot(), nil
+}
+
+func (p privileges) CanBindPrivilegedPorts() (bool, error) {
+	return p.IsRoot(), nil
+}
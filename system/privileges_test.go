@@ -0,0 +1,68 @@
+package system_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("Privileges", func() {
+	var fakePrivileges *fakesys.FakePrivileges
+
+	BeforeEach(func() {
+		fakePrivileges = fakesys.NewFakePrivileges()
+	})
+
+	It("names capabilities", func() {
+		Expect(CapNetBindService.String()).To(Equal("CAP_NET_BIND_SERVICE"))
+		Expect(Capability(40).String()).To(Equal("CAP_40"))
+	})
+
+	Describe("RequireCapability", func() {
+		It("succeeds when the capability is present", func() {
+			fakePrivileges.Capabilities = []Capability{CapNetAdmin}
+
+			Expect(RequireCapability(fakePrivileges, CapNetAdmin, "Configuring routes")).To(Succeed())
+		})
+
+		It("returns an actionable error when the capability is missing", func() {
+			err := RequireCapability(fakePrivileges, CapNetAdmin, "Configuring routes")
+			Expect(err).To(BeAssignableToTypeOf(InsufficientPrivilegesError{}))
+			Expect(err.Error()).To(Equal("Configuring routes requires CAP_NET_ADMIN: run as root or grant the capability with 'setcap cap_net_admin+ep <binary>'"))
+		})
+
+		It("returns errors checking the capability", func() {
+			fakePrivileges.HasCapabilityErr = errors.New("fake-err")
+
+			Expect(RequireCapability(fakePrivileges, CapNetAdmin, "Configuring routes")).To(MatchError("fake-err"))
+		})
+	})
+
+	Describe("RequireElevated", func() {
+		It("returns an error unless elevated", func() {
+			err := RequireElevated(fakePrivileges, "Installing service")
+			Expect(err).To(MatchError(ContainSubstring("Installing service requires elevated privileges")))
+
+			fakePrivileges.Elevated = true
+			Expect(RequireElevated(fakePrivileges, "Installing service")).To(Succeed())
+		})
+	})
+
+	It("checks the privileges of the current process", func() {
+		privileges := NewPrivileges()
+
+		elevated, err := privileges.IsElevated()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(elevated).To(Equal(privileges.IsRoot()))
+
+		if privileges.IsRoot() {
+			canBind, err := privileges.CanBindPrivilegedPorts()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(canBind).To(BeTrue())
+		}
+	})
+})
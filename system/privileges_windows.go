@@ -0,0 +1,50 @@
+package system
+
+import (
+	"syscall"
+	"unsafe"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func (p privileges) IsRoot() bool {
+	elevated, _ := p.IsElevated()
+	return elevated
+}
+
+// IsElevated checks the token of the process, since members of the
+// Administrators group run with a filtered token unless UAC elevated them
+func (p privileges) IsElevated() (bool, error) {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return false, bosherr.WrapError(err, "Getting current process")
+	}
+
+	var token syscall.Token
+	err = syscall.OpenProcessToken(process, syscall.TOKEN_QUERY, &token)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Opening process token")
+	}
+
+	defer token.Close()
+
+	var elevation uint32
+	var returnedLen uint32
+
+	err = syscall.GetTokenInformation(token, syscall.TokenElevation,
+		(*byte)(unsafe.Pointer(&elevation)), uint32(unsafe.Sizeof(elevation)), &returnedLen)
+	if err != nil {
+		return false, bosherr.WrapError(err, "Getting token elevation")
+	}
+
+	return elevation != 0, nil
+}
+
+func (p privileges) HasCapability(_ Capability) (bool, error) {
+	return p.IsElevated()
+}
+
+// CanBindPrivilegedPorts is always true, Windows does not restrict low ports
+func (p privileges) CanBindPrivilegedPorts() (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,92 @@
+package system
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// IOPriorityClass mirrors the Linux IO scheduling classes
+type IOPriorityClass int
+
+const (
+	IOPriorityClassDefault IOPriorityClass = iota
+	IOPriorityClassRealtime
+	IOPriorityClassBestEffort
+	IOPriorityClassIdle
+)
+
+// PriorityClass is the process priority class on Windows
+type PriorityClass int
+
+const (
+	PriorityClassDefault PriorityClass = iota
+	PriorityClassIdle
+	PriorityClassBelowNormal
+	PriorityClassNormal
+	PriorityClassAboveNormal
+	PriorityClassHigh
+)
+
+// ProcessPriority is given to the command before it runs and inherited by
+// the processes it starts. Zero values keep the priority of the current
+// process. Raising the priority usually needs privileges.
+type ProcessPriority struct {
+	// Nice is the CPU niceness from -20 (highest) to 19 (lowest).
+	// On Windows it picks the priority class unless Class is set.
+	Nice int
+
+	// IOClass and IOLevel set the IO priority on Linux. IOLevel goes from
+	// 0 (highest) to 7 and is ignored by IOPriorityClassIdle.
+	IOClass IOPriorityClass
+	IOLevel int
+
+	// Class is the priority class on Windows. It is ignored on other platforms.
+	Class PriorityClass
+}
+
+func (p ProcessPriority) requested() bool {
+	return p != ProcessPriority{}
+}
+
+func (p ProcessPriority) validate() error {
+	if p.Nice < -20 || p.Nice > 19 {
+		return bosherr.Errorf("Invalid niceness %d", p.Nice)
+	}
+
+	if p.IOClass < IOPriorityClassDefault || p.IOClass > IOPriorityClassIdle {
+		return bosherr.Errorf("Invalid IO priority class %d", p.IOClass)
+	}
+
+	if p.IOLevel < 0 || p.IOLevel > 7 {
+		return bosherr.Errorf("Invalid IO priority level %d", p.IOLevel)
+	}
+
+	if p.IOLevel != 0 && p.IOClass == IOPriorityClassDefault {
+		return bosherr.Error("IO priority level requires an IO priority class")
+	}
+
+	if p.Class < PriorityClassDefault || p.Class > PriorityClassHigh {
+		return bosherr.Errorf("Invalid priority class %d", p.Class)
+	}
+
+	return nil
+}
+
+// windowsClass maps Nice onto the priority classes when Class is not set
+func (p ProcessPriority) windowsClass() PriorityClass {
+	if p.Class != PriorityClassDefault {
+		return p.Class
+	}
+
+	switch {
+	case p.Nice >= 15:
+		return PriorityClassIdle
+	case p.Nice > 0:
+		return PriorityClassBelowNormal
+	case p.Nice <= -15:
+		return PriorityClassHigh
+	case p.Nice < 0:
+		return PriorityClassAboveNormal
+	}
+
+	return PriorityClassDefault
+}
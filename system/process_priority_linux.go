@@ -0,0 +1,36 @@
+package system
+
+import (
+	"golang.org/x/sys/unix"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// applyToThread sets the priority of the calling thread, which processes
+// forked from it inherit. The thread has to be locked.
+func (p ProcessPriority) applyToThread() error {
+	tid := unix.Gettid()
+
+	if p.Nice != 0 {
+		err := unix.Setpriority(unix.PRIO_PROCESS, tid, p.Nice)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Setting niceness to %d", p.Nice)
+		}
+	}
+
+	if p.IOClass != IOPriorityClassDefault {
+		ioprio := uintptr(p.IOClass)<<ioprioClassShift | uintptr(p.IOLevel)
+
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprio)
+		if errno != 0 {
+			return bosherr.WrapErrorf(errno, "Setting IO priority to class %d level %d", p.IOClass, p.IOLevel)
+		}
+	}
+
+	return nil
+}
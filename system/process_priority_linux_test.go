@@ -0,0 +1,61 @@
+package system_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("execCmdRunner process priority", func() {
+	var runner CmdRunner
+
+	BeforeEach(func() {
+		runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	It("runs the command with the requested niceness", func() {
+		current, _, _, err := runner.RunCommand("nice")
+		Expect(err).ToNot(HaveOccurred())
+
+		stdout, _, _, err := runner.RunComplexCommand(Command{
+			Name:     "nice",
+			Priority: ProcessPriority{Nice: 19},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.TrimSpace(stdout)).To(Equal("19"))
+
+		current2, _, _, err := runner.RunCommand("nice")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(current2).To(Equal(current))
+	})
+
+	It("runs the command with the requested IO priority", func() {
+		stdout, _, _, err := runner.RunComplexCommand(Command{
+			Name:     "sh",
+			Args:     []string{"-c", "ionice -p $$"},
+			Priority: ProcessPriority{IOClass: IOPriorityClassBestEffort, IOLevel: 7},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.TrimSpace(stdout)).To(Equal("best-effort: prio 7"))
+
+		stdout, _, _, err = runner.RunComplexCommand(Command{
+			Name:     "sh",
+			Args:     []string{"-c", "ionice -p $$"},
+			Priority: ProcessPriority{IOClass: IOPriorityClassIdle},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.TrimSpace(stdout)).To(Equal("idle"))
+	})
+
+	It("returns an error for invalid priorities", func() {
+		_, _, _, err := runner.RunComplexCommand(Command{Name: "true", Priority: ProcessPriority{Nice: 20}})
+		Expect(err).To(MatchError(ContainSubstring("Invalid niceness 20")))
+
+		_, _, _, err = runner.RunComplexCommand(Command{Name: "true", Priority: ProcessPriority{IOLevel: 3}})
+		Expect(err).To(MatchError(ContainSubstring("IO priority level requires an IO priority class")))
+	})
+})
@@ -0,0 +1,22 @@
+package system
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var priorityClassCreationFlags = map[PriorityClass]uint32{
+	PriorityClassIdle:        0x00000040,
+	PriorityClassBelowNormal: 0x00004000,
+	PriorityClassNormal:      0x00000020,
+	PriorityClassAboveNormal: 0x00008000,
+	PriorityClassHigh:        0x00000080,
+}
+
+// creationFlags returns the flag that starts the process in its priority class
+func (p ProcessPriority) creationFlags() (uint32, error) {
+	if p.IOClass != IOPriorityClassDefault {
+		return 0, bosherr.Error("IO priority is not supported on Windows")
+	}
+
+	return priorityClassCreationFlags[p.windowsClass()], nil
+}
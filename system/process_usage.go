@@ -0,0 +1,92 @@
+package system
+
+import (
+	"sync"
+	"time"
+)
+
+type ProcessUsage struct {
+	UserTime   time.Duration
+	SystemTime time.Duration
+
+	// Resident and virtual memory sizes in bytes
+	RSSBytes uint64
+	VMSBytes uint64
+
+	SampledAt time.Time
+}
+
+func (u ProcessUsage) CPUTime() time.Duration {
+	return u.UserTime + u.SystemTime
+}
+
+// CommandUsage are the resources a command used until it exited. On Unix
+// they include the children it waited for. Fields that are not reported
+// on the current platform are 0.
+type CommandUsage struct {
+	UserTime   time.Duration
+	SystemTime time.Duration
+
+	// MaxRSSBytes is the peak resident set size, the peak working set on Windows
+	MaxRSSBytes uint64
+
+	// IO counters of the process (Windows only)
+	ReadOperations  uint64
+	WriteOperations uint64
+	ReadBytes       uint64
+	WriteBytes      uint64
+}
+
+func (u CommandUsage) CPUTime() time.Duration {
+	return u.UserTime + u.SystemTime
+}
+
+// UsageSampler periodically samples usage of a running Process
+// and feeds each sample to a callback. Sampling stops when Stop is called
+// or once the process cannot be sampled anymore (e.g. it exited).
+type UsageSampler struct {
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	doneCh   chan struct{}
+}
+
+func StartUsageSampler(process Process, interval time.Duration, callback func(ProcessUsage)) *UsageSampler {
+	s := &UsageSampler{
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.doneCh)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				usage, err := process.SampleUsage()
+				if err != nil {
+					return
+				}
+				callback(usage)
+			}
+		}
+	}()
+
+	return s
+}
+
+// Stop can be called multiple times; it blocks until
+// the callback is no longer being invoked.
+func (s *UsageSampler) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	<-s.doneCh
+}
+
+// Done is closed once sampling has stopped.
+func (s *UsageSampler) Done() <-chan struct{} {
+	return s.doneCh
+}
@@ -0,0 +1,69 @@
+package system_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("ProcessUsage", func() {
+	It("sums user and system time", func() {
+		usage := ProcessUsage{UserTime: 2 * time.Second, SystemTime: time.Second}
+		Expect(usage.CPUTime()).To(Equal(3 * time.Second))
+	})
+})
+
+var _ = Describe("UsageSampler", func() {
+	var (
+		process *fakesys.FakeProcess
+		samples []ProcessUsage
+		lock    sync.Mutex
+	)
+
+	BeforeEach(func() {
+		process = &fakesys.FakeProcess{
+			SampleUsageResult: ProcessUsage{RSSBytes: 1024},
+		}
+		samples = nil
+	})
+
+	callback := func(usage ProcessUsage) {
+		lock.Lock()
+		defer lock.Unlock()
+		samples = append(samples, usage)
+	}
+
+	sampleCount := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(samples)
+	}
+
+	It("periodically feeds samples to the callback until stopped", func() {
+		sampler := StartUsageSampler(process, time.Millisecond, callback)
+
+		Eventually(sampleCount).Should(BeNumerically(">=", 2))
+		sampler.Stop()
+		sampler.Stop()
+
+		count := sampleCount()
+		Consistently(sampleCount, 20*time.Millisecond).Should(Equal(count))
+		Expect(samples[0].RSSBytes).To(Equal(uint64(1024)))
+	})
+
+	It("stops sampling when the process cannot be sampled anymore", func() {
+		process.SampleUsageErr = errors.New("fake-err")
+
+		sampler := StartUsageSampler(process, time.Millisecond, callback)
+
+		Eventually(sampler.Done()).Should(BeClosed())
+		Expect(sampleCount()).To(Equal(0))
+		Expect(process.SampleUsageCallCount).To(Equal(1))
+	})
+})
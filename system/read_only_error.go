@@ -0,0 +1,49 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ReadOnlyFilesystemError is returned by write operations that failed
+// because the underlying filesystem is mounted read-only (EROFS) or the
+// volume is write-protected on Windows.
+type ReadOnlyFilesystemError struct {
+	Path string
+	Err  error
+}
+
+func NewReadOnlyFilesystemError(path string, err error) ReadOnlyFilesystemError {
+	return ReadOnlyFilesystemError{Path: path, Err: err}
+}
+
+func (e ReadOnlyFilesystemError) Error() string {
+	return fmt.Sprintf("Filesystem containing '%s' is read-only: %s", e.Path, e.Err)
+}
+
+func (e ReadOnlyFilesystemError) Unwrap() error { return e.Err }
+
+func IsReadOnlyFilesystemError(err error) bool {
+	var readOnlyErr ReadOnlyFilesystemError
+	return errors.As(err, &readOnlyErr)
+}
+
+// wrapReadOnlyErr converts errors caused by a read-only filesystem
+// into ReadOnlyFilesystemError and passes everything else through.
+func wrapReadOnlyErr(path string, err error) error {
+	if err == nil || IsReadOnlyFilesystemError(err) {
+		return err
+	}
+
+	var errno syscallErrno
+	if errors.As(err, &errno) && isReadOnlyErrno(errno) {
+		return NewReadOnlyFilesystemError(path, err)
+	}
+
+	return err
+}
+
+func isWriteFlag(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
@@ -0,0 +1,62 @@
+package system_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("ReadOnlyFilesystemError", func() {
+	It("includes the path and the underlying error", func() {
+		err := NewReadOnlyFilesystemError("/fake-path", syscall.EROFS)
+		Expect(err.Error()).To(ContainSubstring("/fake-path"))
+		Expect(errors.Is(err, syscall.EROFS)).To(BeTrue())
+	})
+
+	It("is detected through wrapped errors", func() {
+		err := bosherr.WrapError(NewReadOnlyFilesystemError("/fake-path", syscall.EROFS), "Writing file")
+		Expect(IsReadOnlyFilesystemError(err)).To(BeTrue())
+	})
+
+	It("is not detected for other errors", func() {
+		Expect(IsReadOnlyFilesystemError(errors.New("fake-error"))).To(BeFalse())
+		Expect(IsReadOnlyFilesystemError(nil)).To(BeFalse())
+	})
+})
+
+var _ = Describe("IsReadOnly", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "bosh-utils-")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	It("returns false for writable filesystems", func() {
+		readOnly, err := createOsFs().IsReadOnly(tempDir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readOnly).To(BeFalse())
+	})
+
+	It("returns an error when the path does not exist", func() {
+		if Windows {
+			Skip("Volume lookup does not require the path to exist on Windows")
+		}
+
+		_, err := createOsFs().IsReadOnly(filepath.Join(tempDir, "missing"))
+		Expect(err).To(HaveOccurred())
+	})
+})
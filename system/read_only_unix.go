@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package system
+
+import (
+	"syscall"
+)
+
+type syscallErrno = syscall.Errno
+
+const accessWriteOk = 0x2
+
+func isReadOnlyErrno(errno syscall.Errno) bool {
+	return errno == syscall.EROFS
+}
+
+func (fs *osFileSystem) isReadOnly(path string) (bool, error) {
+	err := syscall.Access(path, accessWriteOk)
+	if err == syscall.EROFS {
+		return true, nil
+	}
+	if err == syscall.ENOENT {
+		return false, err
+	}
+	return false, nil
+}
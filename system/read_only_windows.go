@@ -0,0 +1,58 @@
+package system
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+type syscallErrno = syscall.Errno
+
+const (
+	errorWriteProtect  = syscall.Errno(19)
+	fileReadOnlyVolume = 0x00080000
+)
+
+var (
+	procGetVolumePathNameW    = kernel32DLL.NewProc("GetVolumePathNameW")
+	procGetVolumeInformationW = kernel32DLL.NewProc("GetVolumeInformationW")
+)
+
+func isReadOnlyErrno(errno syscall.Errno) bool {
+	return errno == errorWriteProtect
+}
+
+func (fs *osFileSystem) isReadOnly(path string) (bool, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	pathPtr, err := syscall.UTF16PtrFromString(absPath)
+	if err != nil {
+		return false, err
+	}
+
+	volumePath := make([]uint16, syscall.MAX_PATH+1)
+	r, _, err := procGetVolumePathNameW.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&volumePath[0])),
+		uintptr(len(volumePath)),
+	)
+	if r == 0 {
+		return false, err
+	}
+
+	var flags uint32
+	r, _, err = procGetVolumeInformationW.Call(
+		uintptr(unsafe.Pointer(&volumePath[0])),
+		0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&flags)),
+		0, 0,
+	)
+	if r == 0 {
+		return false, err
+	}
+
+	return flags&fileReadOnlyVolume != 0, nil
+}
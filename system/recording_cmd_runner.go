@@ -0,0 +1,261 @@
+package system
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// CmdTranscriptEntry is a single executed command and its result.
+// Transcripts are stored as one JSON encoded entry per line.
+type CmdTranscriptEntry struct {
+	Name       string            `json:"name"`
+	Args       []string          `json:"args,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	EnvFiles   []string          `json:"env_files,omitempty"`
+	WorkingDir string            `json:"working_dir,omitempty"`
+	Stdin      string            `json:"stdin,omitempty"`
+
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitStatus int    `json:"exit_status"`
+
+	Error     string     `json:"error,omitempty"`
+	ExecError *ExecError `json:"exec_error,omitempty"`
+
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// RecordingCmdRunner appends every command run through it, together with its
+// result, to a transcript file. Transcripts include command environments and
+// stdin so they may contain credentials.
+type RecordingCmdRunner struct {
+	delegate       CmdRunner
+	fs             FileSystem
+	transcriptPath string
+	logger         boshlog.Logger
+	logTag         string
+
+	writeLock sync.Mutex
+}
+
+func NewRecordingCmdRunner(delegate CmdRunner, fs FileSystem, transcriptPath string, logger boshlog.Logger) *RecordingCmdRunner {
+	return &RecordingCmdRunner{
+		delegate:       delegate,
+		fs:             fs,
+		transcriptPath: transcriptPath,
+		logger:         logger,
+		logTag:         "RecordingCmdRunner",
+	}
+}
+
+func (r *RecordingCmdRunner) RunComplexCommand(cmd Command) (string, string, int, error) {
+	recording, cmd := r.begin(cmd)
+
+	stdout, stderr, exitStatus, err := r.delegate.RunComplexCommand(cmd)
+	recording.finish(Result{Stdout: stdout, Stderr: stderr, ExitStatus: exitStatus, Error: err})
+
+	return stdout, stderr, exitStatus, err
+}
+
+func (r *RecordingCmdRunner) RunComplexCommandAsync(cmd Command) (Process, error) {
+	recording, cmd := r.begin(cmd)
+
+	process, err := r.delegate.RunComplexCommandAsync(cmd)
+	if err != nil {
+		recording.finish(Result{ExitStatus: -1, Error: err})
+		return nil, err
+	}
+
+	return &recordingProcess{Process: process, recording: recording}, nil
+}
+
+// RunPipeline records every stage as a separate command. Output passed
+// between stages is not recorded.
+func (r *RecordingCmdRunner) RunPipeline(cmds ...Command) ([]Result, error) {
+	recordings := make([]*cmdRecording, len(cmds))
+	stages := make([]Command, len(cmds))
+
+	for i, cmd := range cmds {
+		recordings[i], stages[i] = r.begin(cmd)
+	}
+
+	results, err := r.delegate.RunPipeline(stages...)
+
+	for i, recording := range recordings {
+		if i < len(results) {
+			recording.finish(results[i])
+		} else {
+			recording.finish(Result{ExitStatus: -1, Error: err})
+		}
+	}
+
+	return results, err
+}
+
+func (r *RecordingCmdRunner) RunCommand(cmdName string, args ...string) (string, string, int, error) {
+	recording, _ := r.begin(Command{Name: cmdName, Args: args})
+
+	stdout, stderr, exitStatus, err := r.delegate.RunCommand(cmdName, args...)
+	recording.finish(Result{Stdout: stdout, Stderr: stderr, ExitStatus: exitStatus, Error: err})
+
+	return stdout, stderr, exitStatus, err
+}
+
+func (r *RecordingCmdRunner) RunCommandQuietly(cmdName string, args ...string) (string, string, int, error) {
+	recording, _ := r.begin(Command{Name: cmdName, Args: args})
+
+	stdout, stderr, exitStatus, err := r.delegate.RunCommandQuietly(cmdName, args...)
+	recording.finish(Result{Stdout: stdout, Stderr: stderr, ExitStatus: exitStatus, Error: err})
+
+	return stdout, stderr, exitStatus, err
+}
+
+func (r *RecordingCmdRunner) RunCommandWithInput(input, cmdName string, args ...string) (string, string, int, error) {
+	recording, _ := r.begin(Command{Name: cmdName, Args: args})
+	recording.entry.Stdin = input
+
+	stdout, stderr, exitStatus, err := r.delegate.RunCommandWithInput(input, cmdName, args...)
+	recording.finish(Result{Stdout: stdout, Stderr: stderr, ExitStatus: exitStatus, Error: err})
+
+	return stdout, stderr, exitStatus, err
+}
+
+func (r *RecordingCmdRunner) CommandExists(cmdName string) bool {
+	return r.delegate.CommandExists(cmdName)
+}
+
+func (r *RecordingCmdRunner) LookPath(cmdName string, opts LookPathOpts) (CommandLookup, error) {
+	return r.delegate.LookPath(cmdName, opts)
+}
+
+// begin returns a copy of cmd whose stdin and custom stdout/stderr
+// are teed into the recording.
+func (r *RecordingCmdRunner) begin(cmd Command) (*cmdRecording, Command) {
+	recording := &cmdRecording{
+		runner: r,
+		entry: CmdTranscriptEntry{
+			Name:       cmd.Name,
+			Args:       cmd.Args,
+			Env:        cmd.Env,
+			EnvFiles:   cmd.EnvFiles,
+			WorkingDir: cmd.WorkingDir,
+			StartedAt:  time.Now(),
+		},
+	}
+
+	if cmd.Stdin != nil {
+		recording.stdin = &bytes.Buffer{}
+		cmd.Stdin = io.TeeReader(cmd.Stdin, recording.stdin)
+	}
+
+	if cmd.Stdout != nil {
+		recording.stdout = &bytes.Buffer{}
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, recording.stdout)
+	}
+
+	if cmd.Stderr != nil {
+		recording.stderr = &bytes.Buffer{}
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, recording.stderr)
+	}
+
+	return recording, cmd
+}
+
+// record never fails the command; transcript errors are only logged
+func (r *RecordingCmdRunner) record(entry CmdTranscriptEntry) {
+	err := r.appendEntry(entry)
+	if err != nil {
+		r.logger.Error(r.logTag, "Recording command '%s': %s", entry.Name, err)
+	}
+}
+
+func (r *RecordingCmdRunner) appendEntry(entry CmdTranscriptEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling transcript entry")
+	}
+
+	r.writeLock.Lock()
+	defer r.writeLock.Unlock()
+
+	file, err := r.fs.OpenFile(r.transcriptPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0600))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening transcript '%s'", r.transcriptPath)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing transcript '%s'", r.transcriptPath)
+	}
+
+	return nil
+}
+
+type cmdRecording struct {
+	runner *RecordingCmdRunner
+	entry  CmdTranscriptEntry
+
+	stdin  *bytes.Buffer
+	stdout *bytes.Buffer
+	stderr *bytes.Buffer
+}
+
+func (c *cmdRecording) finish(result Result) {
+	entry := c.entry
+	entry.Duration = time.Since(entry.StartedAt)
+
+	entry.Stdout = result.Stdout
+	entry.Stderr = result.Stderr
+	entry.ExitStatus = result.ExitStatus
+
+	if c.stdin != nil {
+		entry.Stdin = c.stdin.String()
+	}
+
+	if c.stdout != nil {
+		entry.Stdout = c.stdout.String()
+	}
+
+	if c.stderr != nil {
+		entry.Stderr = c.stderr.String()
+	}
+
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+
+		var execErr ExecError
+		if errors.As(result.Error, &execErr) {
+			entry.ExecError = &execErr
+		}
+	}
+
+	c.runner.record(entry)
+}
+
+type recordingProcess struct {
+	Process
+	recording *cmdRecording
+}
+
+func (p *recordingProcess) Wait() <-chan Result {
+	resultCh := make(chan Result, 1)
+	waitCh := p.Process.Wait()
+
+	go func() {
+		result := <-waitCh
+		p.recording.finish(result)
+		resultCh <- result
+	}()
+
+	return resultCh
+}
@@ -0,0 +1,213 @@
+package system_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("RecordingCmdRunner", func() {
+	var (
+		fs             FileSystem
+		delegate       *fakesys.FakeCmdRunner
+		transcriptPath string
+		runner         *RecordingCmdRunner
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fs = NewOsFileSystem(logger)
+		delegate = fakesys.NewFakeCmdRunner()
+		transcriptPath = filepath.Join(GinkgoT().TempDir(), "transcript.jsonl")
+		runner = NewRecordingCmdRunner(delegate, fs, transcriptPath, logger)
+	})
+
+	newReplay := func() *ReplayCmdRunner {
+		replay, err := NewReplayCmdRunner(fs, transcriptPath)
+		Expect(err).ToNot(HaveOccurred())
+		return replay
+	}
+
+	It("records commands and their results so they can be replayed", func() {
+		delegate.AddCmdResult("ls -l", fakesys.FakeCmdResult{Stdout: "first-listing"})
+		delegate.AddCmdResult("ls -l", fakesys.FakeCmdResult{Stdout: "second-listing"})
+		delegate.AddCmdResult("fake-input cat", fakesys.FakeCmdResult{Stdout: "fake-input", Stderr: "fake-stderr"})
+
+		stdout, _, _, err := runner.RunCommand("ls", "-l")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("first-listing"))
+
+		_, _, _, err = runner.RunCommandWithInput("fake-input", "cat")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, _, _, err = runner.RunCommandQuietly("ls", "-l")
+		Expect(err).ToNot(HaveOccurred())
+
+		replay := newReplay()
+		Expect(replay.Remaining()).To(HaveLen(3))
+		Expect(replay.Remaining()[1].Stdin).To(Equal("fake-input"))
+
+		stdout, stderr, exitStatus, err := replay.RunCommandWithInput("fake-input", "cat")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("fake-input"))
+		Expect(stderr).To(Equal("fake-stderr"))
+		Expect(exitStatus).To(Equal(0))
+
+		stdout, _, _, err = replay.RunCommand("ls", "-l")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("first-listing"))
+
+		stdout, _, _, err = replay.RunCommand("ls", "-l")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("second-listing"))
+
+		Expect(replay.Remaining()).To(BeEmpty())
+
+		_, _, exitStatus, err = replay.RunCommand("ls", "-l")
+		Expect(err).To(MatchError("No recorded result for command 'ls -l'"))
+		Expect(exitStatus).To(Equal(-1))
+	})
+
+	It("records command details and output sent to custom writers", func() {
+		delegate.AddCmdResult("bash -c fake-script", fakesys.FakeCmdResult{Stdout: "fake-stdout"})
+
+		_, _, _, err := runner.RunComplexCommand(Command{
+			Name:       "bash",
+			Args:       []string{"-c", "fake-script"},
+			Env:        map[string]string{"FOO": "BAR"},
+			WorkingDir: "/fake-dir",
+			Stdin:      strings.NewReader("fake-stdin"),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		entry := newReplay().Remaining()[0]
+		Expect(entry.Name).To(Equal("bash"))
+		Expect(entry.Args).To(Equal([]string{"-c", "fake-script"}))
+		Expect(entry.Env).To(Equal(map[string]string{"FOO": "BAR"}))
+		Expect(entry.WorkingDir).To(Equal("/fake-dir"))
+		Expect(entry.Stdout).To(Equal("fake-stdout"))
+		Expect(entry.StartedAt).ToNot(BeZero())
+
+		stdout := &bytes.Buffer{}
+		replayedStdout, _, _, err := newReplay().RunComplexCommand(Command{
+			Name:   "bash",
+			Args:   []string{"-c", "fake-script"},
+			Stdout: stdout,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replayedStdout).To(BeEmpty())
+		Expect(stdout.String()).To(Equal("fake-stdout"))
+	})
+
+	It("replays failures including exec errors", func() {
+		execErr := NewExecError("false", "fake-stdout", "fake-stderr")
+		delegate.AddCmdResult("false", fakesys.FakeCmdResult{
+			ExitStatus: 1,
+			Error:      bosherr.WrapComplexError(errors.New("exit status 1"), execErr),
+		})
+
+		_, _, _, recordedErr := runner.RunCommand("false")
+		Expect(recordedErr).To(HaveOccurred())
+
+		_, _, exitStatus, err := newReplay().RunCommand("false")
+		Expect(err).To(MatchError(recordedErr.Error()))
+		Expect(exitStatus).To(Equal(1))
+
+		var replayedExecErr ExecError
+		Expect(errors.As(err, &replayedExecErr)).To(BeTrue())
+		Expect(replayedExecErr).To(Equal(execErr))
+	})
+
+	It("records async processes once they finish", func() {
+		delegate.AddProcess("sleep 1", &fakesys.FakeProcess{
+			WaitResult: Result{Stdout: "fake-async-stdout", ExitStatus: 0},
+		})
+
+		process, err := runner.RunComplexCommandAsync(Command{Name: "sleep", Args: []string{"1"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fs.FileExists(transcriptPath)).To(BeFalse())
+
+		result := <-process.Wait()
+		Expect(result.Stdout).To(Equal("fake-async-stdout"))
+
+		replayedProcess, err := newReplay().RunComplexCommandAsync(Command{Name: "sleep", Args: []string{"1"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect((<-replayedProcess.Wait()).Stdout).To(Equal("fake-async-stdout"))
+	})
+
+	It("records every stage of pipelines", func() {
+		delegate.AddCmdResult("ls -l", fakesys.FakeCmdResult{Stdout: "fake-listing"})
+		delegate.AddCmdResult("grep fake", fakesys.FakeCmdResult{Stdout: "fake-match", ExitStatus: 1, Error: errors.New("fake-grep-err")})
+
+		results, err := runner.RunPipeline(Command{Name: "ls", Args: []string{"-l"}}, Command{Name: "grep", Args: []string{"fake"}})
+		Expect(err).To(MatchError("fake-grep-err"))
+		Expect(results).To(HaveLen(2))
+
+		replay := newReplay()
+		Expect(replay.Remaining()).To(HaveLen(2))
+
+		results, err = replay.RunPipeline(Command{Name: "ls", Args: []string{"-l"}}, Command{Name: "grep", Args: []string{"fake"}})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Running pipeline stage 2 of 'ls -l | grep fake': fake-grep-err"))
+		Expect(results[0].Stdout).To(BeEmpty())
+		Expect(results[1].Stdout).To(Equal("fake-match"))
+		Expect(results[1].ExitStatus).To(Equal(1))
+	})
+
+	It("does not fail commands when the transcript cannot be written", func() {
+		delegate.AddCmdResult("ls", fakesys.FakeCmdResult{Stdout: "fake-stdout"})
+		runner = NewRecordingCmdRunner(delegate, fs, filepath.Join(transcriptPath, "missing", "transcript"), boshlog.NewLogger(boshlog.LevelNone))
+
+		stdout, _, _, err := runner.RunCommand("ls")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("fake-stdout"))
+	})
+})
+
+var _ = Describe("ReplayCmdRunner", func() {
+	var (
+		fs             FileSystem
+		transcriptPath string
+	)
+
+	BeforeEach(func() {
+		fs = NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		transcriptPath = filepath.Join(GinkgoT().TempDir(), "transcript.jsonl")
+	})
+
+	It("returns an error when the transcript is missing", func() {
+		_, err := NewReplayCmdRunner(fs, transcriptPath)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Reading transcript"))
+	})
+
+	It("returns an error when the transcript is malformed", func() {
+		Expect(os.WriteFile(transcriptPath, []byte("{\"name\":\"ls\"}\n\nnot-json\n"), 0600)).To(Succeed())
+
+		_, err := NewReplayCmdRunner(fs, transcriptPath)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("line 3"))
+	})
+
+	It("reports recorded commands as existing", func() {
+		Expect(os.WriteFile(transcriptPath, []byte("{\"name\":\"ls\"}\n"), 0600)).To(Succeed())
+
+		replay, err := NewReplayCmdRunner(fs, transcriptPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replay.CommandExists("ls")).To(BeTrue())
+		Expect(replay.CommandExists("cat")).To(BeFalse())
+
+		_, err = replay.LookPath("cat", LookPathOpts{})
+		Expect(IsCommandNotFoundError(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package system
+
+import (
+	"os"
+)
+
+// removeOnReboot unlinks path right away; processes that have it open
+// keep reading the old contents until they close it
+func (fs *osFileSystem) removeOnReboot(path string) error {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package system
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const moveFileDelayUntilReboot = 0x4 // MOVEFILE_DELAY_UNTIL_REBOOT
+
+var procMoveFileExW = kernel32DLL.NewProc("MoveFileExW")
+
+// removeOnReboot deletes path right away when nothing has it open.
+// Otherwise it renames path out of the way, which Windows allows even for
+// running binaries, and asks the session manager to delete it on reboot.
+func (fs *osFileSystem) removeOnReboot(path string) error {
+	err := os.Remove(path)
+	if err == nil || os.IsNotExist(err) {
+		return nil
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	pendingPath := path + ".pending-delete-" + hex.EncodeToString(suffix)
+
+	err = os.Rename(path, pendingPath)
+	if err != nil {
+		// files opened without FILE_SHARE_DELETE cannot be renamed
+		fs.logger.Debug(fs.logTag, "Failed to rename %s before removing it on reboot: %s", path, err)
+		pendingPath = path
+	}
+
+	pendingPathp, err := syscall.UTF16PtrFromString(pendingPath)
+	if err != nil {
+		return &os.PathError{Op: "MoveFileEx", Path: pendingPath, Err: err}
+	}
+
+	r, _, err := procMoveFileExW.Call(uintptr(unsafe.Pointer(pendingPathp)), 0, moveFileDelayUntilReboot)
+	if r == 0 {
+		return &os.PathError{Op: "MoveFileEx", Path: pendingPath, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// ReplayCmdRunner serves results from a transcript written by
+// RecordingCmdRunner instead of running commands. Each recorded entry is
+// used once; commands are matched by name and arguments in recorded order.
+type ReplayCmdRunner struct {
+	entries []CmdTranscriptEntry
+	used    []bool
+
+	lock sync.Mutex
+}
+
+func NewReplayCmdRunner(fs FileSystem, transcriptPath string) (*ReplayCmdRunner, error) {
+	contents, err := fs.ReadFile(transcriptPath)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading transcript '%s'", transcriptPath)
+	}
+
+	var entries []CmdTranscriptEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(nil, len(contents)+1)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry CmdTranscriptEntry
+
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Parsing transcript '%s' line %d", transcriptPath, lineNum)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading transcript '%s'", transcriptPath)
+	}
+
+	return &ReplayCmdRunner{entries: entries, used: make([]bool, len(entries))}, nil
+}
+
+func (r *ReplayCmdRunner) RunComplexCommand(cmd Command) (string, string, int, error) {
+	result, err := r.replay(cmd)
+	if err != nil {
+		return "", "", -1, err
+	}
+
+	return result.Stdout, result.Stderr, result.ExitStatus, result.Error
+}
+
+func (r *ReplayCmdRunner) RunComplexCommandAsync(cmd Command) (Process, error) {
+	result, err := r.replay(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return replayProcess{result: result}, nil
+}
+
+// RunPipeline replays every stage as a separate command. Only the
+// recorded stdout of the last stage is returned.
+func (r *ReplayCmdRunner) RunPipeline(cmds ...Command) ([]Result, error) {
+	results := make([]Result, len(cmds))
+
+	for i, cmd := range cmds {
+		result, err := r.replay(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		if i < len(cmds)-1 {
+			result.Stdout = ""
+		}
+
+		results[i] = result
+	}
+
+	return results, pipelineError(cmds, results)
+}
+
+func (r *ReplayCmdRunner) RunCommand(cmdName string, args ...string) (string, string, int, error) {
+	return r.RunComplexCommand(Command{Name: cmdName, Args: args})
+}
+
+func (r *ReplayCmdRunner) RunCommandQuietly(cmdName string, args ...string) (string, string, int, error) {
+	return r.RunComplexCommand(Command{Name: cmdName, Args: args})
+}
+
+func (r *ReplayCmdRunner) RunCommandWithInput(_, cmdName string, args ...string) (string, string, int, error) {
+	return r.RunComplexCommand(Command{Name: cmdName, Args: args})
+}
+
+// CommandExists reports whether the transcript contains the command
+func (r *ReplayCmdRunner) CommandExists(cmdName string) bool {
+	for _, entry := range r.entries {
+		if entry.Name == cmdName {
+			return true
+		}
+	}
+	return false
+}
+
+// LookPath resolves commands contained in the transcript to their name
+func (r *ReplayCmdRunner) LookPath(cmdName string, _ LookPathOpts) (CommandLookup, error) {
+	if !r.CommandExists(cmdName) {
+		return CommandLookup{Name: cmdName}, CommandNotFoundError{Name: cmdName, Err: bosherr.Error("not in transcript")}
+	}
+	return CommandLookup{Name: cmdName, Path: cmdName}, nil
+}
+
+// Remaining returns recorded entries that have not been replayed yet
+func (r *ReplayCmdRunner) Remaining() []CmdTranscriptEntry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var remaining []CmdTranscriptEntry
+	for i, entry := range r.entries {
+		if !r.used[i] {
+			remaining = append(remaining, entry)
+		}
+	}
+	return remaining
+}
+
+func (r *ReplayCmdRunner) replay(cmd Command) (Result, error) {
+	entry, found := r.next(cmd)
+	if !found {
+		return Result{}, bosherr.Errorf("No recorded result for command '%s'", formatTranscriptCommand(cmd.Name, cmd.Args))
+	}
+
+	if cmd.Stdin != nil {
+		io.Copy(io.Discard, cmd.Stdin)
+	}
+
+	result := Result{
+		Stdout:     entry.Stdout,
+		Stderr:     entry.Stderr,
+		ExitStatus: entry.ExitStatus,
+		Error:      newRecordedError(entry),
+	}
+
+	// like execProcess, output sent to custom writers is not part of the result
+	if cmd.Stdout != nil {
+		io.WriteString(cmd.Stdout, entry.Stdout)
+		result.Stdout = ""
+	}
+
+	if cmd.Stderr != nil {
+		io.WriteString(cmd.Stderr, entry.Stderr)
+		result.Stderr = ""
+	}
+
+	return result, nil
+}
+
+func (r *ReplayCmdRunner) next(cmd Command) (CmdTranscriptEntry, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i, entry := range r.entries {
+		if r.used[i] || entry.Name != cmd.Name {
+			continue
+		}
+
+		if len(entry.Args) == 0 && len(cmd.Args) == 0 || reflect.DeepEqual(entry.Args, cmd.Args) {
+			r.used[i] = true
+			return entry, true
+		}
+	}
+
+	return CmdTranscriptEntry{}, false
+}
+
+type replayProcess struct {
+	result Result
+}
+
+func (p replayProcess) Wait() <-chan Result {
+	resultCh := make(chan Result, 1)
+	resultCh <- p.result
+	return resultCh
+}
+
+func (p replayProcess) TerminateNicely(_ time.Duration) error {
+	return nil
+}
+
+func (p replayProcess) Terminate() error {
+	return nil
+}
+
+func (p replayProcess) SampleUsage() (ProcessUsage, error) {
+	return ProcessUsage{}, bosherr.Error("Replayed processes cannot be sampled")
+}
+
+// Pid returns the recorded PID
+func (p replayProcess) Pid() int {
+	return p.result.PID
+}
+
+func (p replayProcess) Signal(_ os.Signal) error {
+	return bosherr.Error("Replayed processes cannot be signaled")
+}
+
+// recordedError reproduces a recorded error message while still
+// allowing callers to inspect the recorded ExecError.
+type recordedError struct {
+	message   string
+	execError *ExecError
+}
+
+func newRecordedError(entry CmdTranscriptEntry) error {
+	if entry.Error == "" {
+		return nil
+	}
+
+	return recordedError{message: entry.Error, execError: entry.ExecError}
+}
+
+func (e recordedError) Error() string {
+	return e.message
+}
+
+func (e recordedError) Unwrap() []error {
+	if e.execError == nil {
+		return nil
+	}
+	return []error{*e.execError}
+}
+
+func formatTranscriptCommand(name string, args []string) string {
+	return strings.Join(append([]string{name}, args...), " ")
+}
@@ -0,0 +1,35 @@
+package system
+
+import (
+	"time"
+)
+
+// ResourceLimits are in place before the command runs. Zero values mean
+// no limit.
+//
+// On Linux the command is traced so that it stops right after its exec,
+// its limits are set with prlimit and it is then let go, so ptrace has to
+// be allowed. Child processes inherit them, each of which gets the same
+// limits. On Windows they are set on the Job Object of the command.
+// Other platforms do not support them.
+type ResourceLimits struct {
+	// MaxMemory limits the address space of each process in bytes on Linux
+	// and the committed memory of each process on Windows
+	MaxMemory uint64
+
+	// MaxOpenFiles limits the file descriptors of each process.
+	// It is not supported on Windows.
+	MaxOpenFiles uint64
+
+	// MaxCPUTime limits the CPU time of each process, rounded up to
+	// seconds on Linux. On Windows only user mode time is counted.
+	MaxCPUTime time.Duration
+
+	// MaxProcesses limits the processes of the user running the command
+	// on Linux and the processes running in the Job Object on Windows
+	MaxProcesses uint64
+}
+
+func (l ResourceLimits) requested() bool {
+	return l != ResourceLimits{}
+}
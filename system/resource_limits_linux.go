@@ -0,0 +1,78 @@
+package system
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func (l ResourceLimits) validate() error {
+	return nil
+}
+
+// prepare makes cmd stop right after its exec so that apply can set the
+// limits before the command runs
+func (l ResourceLimits) prepare(cmd *exec.Cmd) {
+	if !l.requested() {
+		return
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+}
+
+// apply sets the limits of the stopped process pid started with a cmd
+// passed to prepare and lets it continue. It has to be called from the
+// locked thread that started the process since that thread traces it.
+func (l ResourceLimits) apply(pid int) error {
+	if !l.requested() {
+		return nil
+	}
+
+	var status unix.WaitStatus
+
+	_, err := unix.Wait4(pid, &status, 0, nil)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Waiting for process %d to stop", pid)
+	}
+
+	if !status.Stopped() {
+		return bosherr.Errorf("Expected process %d to stop before running but it did not", pid)
+	}
+
+	limits := []struct {
+		resource int
+		name     string
+		value    uint64
+	}{
+		{unix.RLIMIT_AS, "memory", l.MaxMemory},
+		{unix.RLIMIT_NOFILE, "open files", l.MaxOpenFiles},
+		{unix.RLIMIT_CPU, "CPU time", uint64((l.MaxCPUTime + time.Second - 1) / time.Second)},
+		{unix.RLIMIT_NPROC, "processes", l.MaxProcesses},
+	}
+
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+
+		err = unix.Prlimit(pid, limit.resource, &unix.Rlimit{Cur: limit.value, Max: limit.value}, nil)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Limiting %s to %d", limit.name, limit.value)
+		}
+	}
+
+	// detaching drops the SIGTRAP of the exec and resumes the process
+	err = unix.PtraceDetach(pid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Resuming process %d", pid)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package system_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("execCmdRunner resource limits", func() {
+	var runner CmdRunner
+
+	BeforeEach(func() {
+		runner = NewExecCmdRunner(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	limitPattern := func(name, value string) string {
+		return `(?m)^` + regexp.QuoteMeta(name) + `\s+` + value + `\s+` + value + `\s`
+	}
+
+	It("applies the limits to the command and its children", func() {
+		stdout, _, _, err := runner.RunComplexCommand(Command{
+			Name: "sh",
+			Args: []string{"-c", "cat /proc/self/limits"},
+			Limits: ResourceLimits{
+				MaxMemory:    1 << 30,
+				MaxOpenFiles: 64,
+				MaxCPUTime:   1500 * time.Millisecond,
+				MaxProcesses: 1000,
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(stdout).To(MatchRegexp(limitPattern("Max address space", "1073741824")))
+		Expect(stdout).To(MatchRegexp(limitPattern("Max open files", "64")))
+		Expect(stdout).To(MatchRegexp(limitPattern("Max cpu time", "2")))
+		Expect(stdout).To(MatchRegexp(limitPattern("Max processes", "1000")))
+	})
+
+	It("leaves resources unlimited by default", func() {
+		stdout, _, _, err := runner.RunComplexCommand(Command{
+			Name: "cat",
+			Args: []string{"/proc/self/limits"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(MatchRegexp(limitPattern("Max cpu time", "unlimited")))
+	})
+
+	It("sets the limits before the command runs", func() {
+		stdout, _, _, err := runner.RunComplexCommand(Command{
+			Name:   "cat",
+			Args:   []string{"/proc/self/limits", "/proc/self/environ"},
+			Env:    map[string]string{"FAKE_ENV": "fake-value"},
+			Limits: ResourceLimits{MaxOpenFiles: 64},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(stdout).To(MatchRegexp(limitPattern("Max open files", "64")))
+		Expect(stdout).To(ContainSubstring("FAKE_ENV=fake-value"))
+	})
+
+	It("applies the limits to commands started at the same time", func() {
+		done := make(chan string, 8)
+		for i := 0; i < cap(done); i++ {
+			go func(maxOpenFiles uint64) {
+				defer GinkgoRecover()
+
+				stdout, _, _, err := runner.RunComplexCommand(Command{
+					Name:   "cat",
+					Args:   []string{"/proc/self/limits"},
+					Limits: ResourceLimits{MaxOpenFiles: maxOpenFiles},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stdout).To(MatchRegexp(limitPattern("Max open files", strconv.FormatUint(maxOpenFiles, 10))))
+				done <- stdout
+			}(uint64(64 + i))
+		}
+
+		for i := 0; i < cap(done); i++ {
+			Eventually(done, 10*time.Second).Should(Receive())
+		}
+	})
+
+	It("does not run the command when the limits cannot be set", func() {
+		dir := GinkgoT().TempDir()
+
+		// more open files than fs.nr_open allows, even for root
+		_, _, _, err := runner.RunComplexCommand(Command{
+			Name:   "touch",
+			Args:   []string{filepath.Join(dir, "ran")},
+			Limits: ResourceLimits{MaxOpenFiles: 1 << 40},
+		})
+		Expect(err).To(MatchError(ContainSubstring("Limiting open files to 1099511627776")))
+		Expect(filepath.Join(dir, "ran")).ToNot(BeAnExistingFile())
+	})
+
+	It("reports commands that cannot be executed", func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "not-executable"), []byte("#!/bin/sh\n"), 0600)).To(Succeed())
+
+		_, _, _, err := runner.RunComplexCommand(Command{
+			Name:   filepath.Join(dir, "not-executable"),
+			Limits: ResourceLimits{MaxOpenFiles: 64},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
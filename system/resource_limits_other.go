@@ -0,0 +1,16 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package system
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+func (l ResourceLimits) validate() error {
+	if l.requested() {
+		return bosherr.Error("Resource limits are not supported on this platform")
+	}
+
+	return nil
+}
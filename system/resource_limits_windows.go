@@ -0,0 +1,95 @@
+package system
+
+import (
+	"syscall"
+	"unsafe"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	jobObjectExtendedLimitInformationClass = 9
+
+	jobObjectLimitProcessTime   = 0x00000002
+	jobObjectLimitActiveProcess = 0x00000008
+	jobObjectLimitProcessMemory = 0x00000100
+)
+
+var (
+	procSetInformationJobObject = kernel32DLL.NewProc("SetInformationJobObject")
+)
+
+type jobObjectBasicLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+}
+
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+type jobObjectExtendedLimitInformation struct {
+	BasicLimitInformation jobObjectBasicLimitInformation
+	IoInfo                ioCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+func (l ResourceLimits) validate() error {
+	if l.MaxOpenFiles > 0 {
+		return bosherr.Error("Limiting open files is not supported on Windows")
+	}
+
+	return nil
+}
+
+// setOn sets the limits on a job before processes are assigned to it
+func (l ResourceLimits) setOn(job syscall.Handle) error {
+	var info jobObjectExtendedLimitInformation
+
+	if l.MaxMemory > 0 {
+		info.BasicLimitInformation.LimitFlags |= jobObjectLimitProcessMemory
+		info.ProcessMemoryLimit = uintptr(l.MaxMemory)
+	}
+
+	if l.MaxCPUTime > 0 {
+		// user time is counted in 100 nanosecond intervals
+		info.BasicLimitInformation.LimitFlags |= jobObjectLimitProcessTime
+		info.BasicLimitInformation.PerProcessUserTimeLimit = int64(l.MaxCPUTime / 100)
+	}
+
+	if l.MaxProcesses > 0 {
+		info.BasicLimitInformation.LimitFlags |= jobObjectLimitActiveProcess
+		info.BasicLimitInformation.ActiveProcessLimit = uint32(l.MaxProcesses)
+	}
+
+	if info.BasicLimitInformation.LimitFlags == 0 {
+		return nil
+	}
+
+	r, _, err := procSetInformationJobObject.Call(
+		uintptr(job),
+		jobObjectExtendedLimitInformationClass,
+		uintptr(unsafe.Pointer(&info)),
+		unsafe.Sizeof(info),
+	)
+	if r == 0 {
+		return bosherr.WrapError(err, "Setting job object limits")
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package system
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/retrystrategy"
+)
+
+// CmdRetryStrategyFactory creates the strategy used to retry a single
+// command, e.g. by partially applying retrystrategy.NewAttemptRetryStrategy
+type CmdRetryStrategyFactory func(retryable retrystrategy.Retryable) retrystrategy.RetryStrategy
+
+// CmdRetryableFunc decides whether a failed command is run again
+type CmdRetryableFunc func(stdout, stderr string, exitStatus int, err error) bool
+
+type retryableCmdRunner struct {
+	CmdRunner
+	strategy    CmdRetryStrategyFactory
+	isRetryable CmdRetryableFunc
+}
+
+// NewRetryableCmdRunner runs commands again while they fail and
+// isRetryable returns true, as long as the strategy allows it. A nil
+// isRetryable retries every failed command. The result of the last run
+// is returned.
+//
+// Stdin that cannot be seeked is read into memory so that every run gets
+// all of it. Custom Stdout and Stderr writers receive the output of every
+// run. Async commands are not retried.
+func NewRetryableCmdRunner(inner CmdRunner, strategy CmdRetryStrategyFactory, isRetryable CmdRetryableFunc) CmdRunner {
+	if isRetryable == nil {
+		isRetryable = func(_, _ string, _ int, _ error) bool { return true }
+	}
+
+	return retryableCmdRunner{
+		CmdRunner:   inner,
+		strategy:    strategy,
+		isRetryable: isRetryable,
+	}
+}
+
+func (r retryableCmdRunner) RunComplexCommand(cmd Command) (string, string, int, error) {
+	rewind, err := rewindableStdin(&cmd)
+	if err != nil {
+		return "", "", -1, err
+	}
+
+	return r.retry(func() (string, string, int, error) {
+		err := rewind()
+		if err != nil {
+			return "", "", -1, err
+		}
+		return r.CmdRunner.RunComplexCommand(cmd)
+	})
+}
+
+func (r retryableCmdRunner) RunCommand(cmdName string, args ...string) (string, string, int, error) {
+	return r.retry(func() (string, string, int, error) {
+		return r.CmdRunner.RunCommand(cmdName, args...)
+	})
+}
+
+func (r retryableCmdRunner) RunCommandQuietly(cmdName string, args ...string) (string, string, int, error) {
+	return r.retry(func() (string, string, int, error) {
+		return r.CmdRunner.RunCommandQuietly(cmdName, args...)
+	})
+}
+
+func (r retryableCmdRunner) RunCommandWithInput(input, cmdName string, args ...string) (string, string, int, error) {
+	return r.retry(func() (string, string, int, error) {
+		return r.CmdRunner.RunCommandWithInput(input, cmdName, args...)
+	})
+}
+
+// RunPipeline runs the whole pipeline again when isRetryable returns
+// true for the first failing stage
+func (r retryableCmdRunner) RunPipeline(cmds ...Command) ([]Result, error) {
+	var results []Result
+	var rewind func() error
+
+	if len(cmds) > 0 {
+		cmds = append([]Command{}, cmds...)
+
+		var err error
+		rewind, err = rewindableStdin(&cmds[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	attempt := &retryableCmd{
+		run: func() (bool, error) {
+			if rewind != nil {
+				err := rewind()
+				if err != nil {
+					return false, err
+				}
+			}
+
+			var err error
+			results, err = r.CmdRunner.RunPipeline(cmds...)
+			if err == nil {
+				return false, nil
+			}
+
+			for _, result := range results {
+				if result.Error != nil {
+					return r.isRetryable(result.Stdout, result.Stderr, result.ExitStatus, result.Error), err
+				}
+			}
+
+			return r.isRetryable("", "", -1, err), err
+		},
+	}
+
+	err := r.strategy(attempt).Try()
+
+	return results, err
+}
+
+func (r retryableCmdRunner) retry(run func() (string, string, int, error)) (string, string, int, error) {
+	var stdout, stderr string
+	exitStatus := -1
+
+	attempt := &retryableCmd{
+		run: func() (bool, error) {
+			var err error
+			stdout, stderr, exitStatus, err = run()
+			if err == nil {
+				return false, nil
+			}
+			return r.isRetryable(stdout, stderr, exitStatus, err), err
+		},
+	}
+
+	err := r.strategy(attempt).Try()
+
+	return stdout, stderr, exitStatus, err
+}
+
+// retryableCmd gives strategies a descriptive type to log
+type retryableCmd struct {
+	run func() (bool, error)
+}
+
+func (c *retryableCmd) Attempt() (bool, error) {
+	return c.run()
+}
+
+// rewindableStdin makes sure cmd.Stdin can be read again by every run.
+// The returned func has to be called before each run.
+func rewindableStdin(cmd *Command) (func() error, error) {
+	if cmd.Stdin == nil {
+		return func() error { return nil }, nil
+	}
+
+	seeker, ok := cmd.Stdin.(io.ReadSeeker)
+
+	var start int64
+	var err error
+
+	if ok {
+		// e.g. pipes are files that cannot be seeked
+		start, err = seeker.Seek(0, io.SeekCurrent)
+		ok = err == nil
+	}
+
+	if !ok {
+		input, err := io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading stdin of command '%s'", strings.Join(append([]string{cmd.Name}, cmd.Args...), " "))
+		}
+
+		seeker = bytes.NewReader(input)
+		cmd.Stdin = seeker
+		start = 0
+	}
+
+	return func() error {
+		_, err := seeker.Seek(start, io.SeekStart)
+		if err != nil {
+			return bosherr.WrapError(err, "Rewinding stdin")
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,109 @@
+package system_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/cloudfoundry/bosh-utils/retrystrategy"
+	. "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("RetryableCmdRunner", func() {
+	var (
+		inner       *fakesys.FakeCmdRunner
+		isRetryable CmdRetryableFunc
+		runner      CmdRunner
+	)
+
+	strategy := func(retryable retrystrategy.Retryable) retrystrategy.RetryStrategy {
+		return retrystrategy.NewAttemptRetryStrategy(3, 0, retryable, boshlog.NewLogger(boshlog.LevelNone))
+	}
+
+	BeforeEach(func() {
+		inner = fakesys.NewFakeCmdRunner()
+		isRetryable = func(_, stderr string, _ int, _ error) bool {
+			return strings.Contains(stderr, "Could not get lock")
+		}
+	})
+
+	JustBeforeEach(func() {
+		runner = NewRetryableCmdRunner(inner, strategy, isRetryable)
+	})
+
+	It("retries commands while they fail retryably", func() {
+		inner.AddCmdResult("apt-get install", fakesys.FakeCmdResult{Stderr: "E: Could not get lock", ExitStatus: 100, Error: errors.New("fake-err")})
+		inner.AddCmdResult("apt-get install", fakesys.FakeCmdResult{Stdout: "fake-installed"})
+
+		stdout, _, exitStatus, err := runner.RunCommand("apt-get", "install")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(Equal("fake-installed"))
+		Expect(exitStatus).To(Equal(0))
+		Expect(inner.RunCommands).To(HaveLen(2))
+	})
+
+	It("returns the last result once the strategy gives up", func() {
+		inner.AddCmdResult("apt-get install", fakesys.FakeCmdResult{Stderr: "E: Could not get lock", ExitStatus: 100, Error: errors.New("fake-err"), Sticky: true})
+
+		_, stderr, exitStatus, err := runner.RunCommandQuietly("apt-get", "install")
+		Expect(err).To(MatchError("fake-err"))
+		Expect(stderr).To(Equal("E: Could not get lock"))
+		Expect(exitStatus).To(Equal(100))
+		Expect(inner.RunCommandsQuietly).To(HaveLen(3))
+	})
+
+	It("does not retry failures that are not retryable", func() {
+		inner.AddCmdResult("apt-get install", fakesys.FakeCmdResult{Stderr: "E: Unable to locate package", ExitStatus: 100, Error: errors.New("fake-err")})
+
+		_, _, _, err := runner.RunCommand("apt-get", "install")
+		Expect(err).To(MatchError("fake-err"))
+		Expect(inner.RunCommands).To(HaveLen(1))
+	})
+
+	Context("without isRetryable", func() {
+		BeforeEach(func() {
+			isRetryable = nil
+		})
+
+		It("retries every failure", func() {
+			inner.AddCmdResult("fake-input cat", fakesys.FakeCmdResult{Error: errors.New("fake-err")})
+			inner.AddCmdResult("fake-input cat", fakesys.FakeCmdResult{Stdout: "fake-input"})
+
+			stdout, _, _, err := runner.RunCommandWithInput("fake-input", "cat")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdout).To(Equal("fake-input"))
+		})
+	})
+
+	It("passes the whole stdin to every run", func() {
+		var inputs []string
+		inner.SetCmdCallback("cat", func() {
+			cmd := inner.RunComplexCommands[len(inner.RunComplexCommands)-1]
+			input, err := io.ReadAll(cmd.Stdin)
+			Expect(err).ToNot(HaveOccurred())
+			inputs = append(inputs, string(input))
+		})
+		inner.AddCmdResult("cat", fakesys.FakeCmdResult{Stderr: "Could not get lock", Error: errors.New("fake-err")})
+		inner.AddCmdResult("cat", fakesys.FakeCmdResult{})
+
+		_, _, _, err := runner.RunComplexCommand(Command{Name: "cat", Stdin: io.MultiReader(strings.NewReader("fake-input"))})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(inputs).To(Equal([]string{"fake-input", "fake-input"}))
+	})
+
+	It("retries pipelines when the first failing stage is retryable", func() {
+		inner.AddCmdResult("dpkg -l", fakesys.FakeCmdResult{Stderr: "Could not get lock", Error: errors.New("fake-err")})
+		inner.AddCmdResult("dpkg -l", fakesys.FakeCmdResult{})
+		inner.AddCmdResult("grep vim", fakesys.FakeCmdResult{Stdout: "fake-vim", Sticky: true})
+
+		results, err := runner.RunPipeline(Command{Name: "dpkg", Args: []string{"-l"}}, Command{Name: "grep", Args: []string{"vim"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[1].Stdout).To(Equal("fake-vim"))
+		Expect(inner.RunPipelines).To(HaveLen(2))
+	})
+})
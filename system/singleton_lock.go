@@ -0,0 +1,319 @@
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var errFileLocked = errors.New("file is locked")
+
+// SingletonLockMetadata is written into the lock file by its holder
+type SingletonLockMetadata struct {
+	PID        int       `json:"pid"`
+	Hostname   string    `json:"hostname"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	Heartbeat  time.Time `json:"heartbeat"`
+}
+
+// SingletonLockHeldError is returned by TryAcquire while another live
+// process holds the lock
+type SingletonLockHeldError struct {
+	Path   string
+	Holder SingletonLockMetadata
+}
+
+func (e SingletonLockHeldError) Error() string {
+	return fmt.Sprintf("Lock '%s' is held by pid %d on '%s' since %s",
+		e.Path, e.Holder.PID, e.Holder.Hostname, e.Holder.AcquiredAt.Format(time.RFC3339))
+}
+
+func IsSingletonLockHeldError(err error) bool {
+	var heldErr SingletonLockHeldError
+	return errors.As(err, &heldErr)
+}
+
+// SingletonLock makes sure only one process works on some state at a time.
+// The holder refreshes a heartbeat in the lock file every ttl/3; a lock
+// whose heartbeat is older than ttl is taken over even when its file lock
+// is still held, e.g. by a child process that inherited the descriptor.
+// Taking over stale locks uses a second file next to the lock file with
+// the suffix ".takeover". On Windows the lock file can not be replaced while
+// it is open, so stale locks are only taken over once the holder closed it.
+type SingletonLock struct {
+	path string
+	ttl  time.Duration
+
+	file   *os.File
+	stopCh chan struct{}
+	doneCh chan struct{}
+	lostCh chan struct{}
+	lock   sync.Mutex
+}
+
+// NewSingletonLock returns an unacquired lock; ttl 0 never takes over locks
+func NewSingletonLock(path string, ttl time.Duration) *SingletonLock {
+	return &SingletonLock{path: path, ttl: ttl}
+}
+
+// TryAcquire takes the lock without waiting for it
+func (l *SingletonLock) TryAcquire() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file != nil {
+		return bosherr.Errorf("Lock '%s' is already acquired", l.path)
+	}
+
+	// another process may take over a stale lock at the same time,
+	// so the lock is only ours when the path still names our file
+	for attempt := 0; attempt < 3; attempt++ {
+		file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, os.FileMode(0644))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Opening lock '%s'", l.path)
+		}
+
+		err = tryLockFile(file)
+		if err == errFileLocked {
+			holder := readSingletonLockMetadata(file)
+			staleInfo, _ := file.Stat()
+			file.Close()
+
+			if !l.isStale(holder) {
+				return SingletonLockHeldError{Path: l.path, Holder: holder}
+			}
+
+			err = l.removeStale(staleInfo)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Taking over stale lock '%s'", l.path)
+			}
+			continue
+		}
+		if err != nil {
+			file.Close()
+			return bosherr.WrapErrorf(err, "Locking '%s'", l.path)
+		}
+
+		if !l.pathNames(file) {
+			file.Close()
+			continue
+		}
+
+		hostname, _ := os.Hostname()
+		now := time.Now().UTC()
+		metadata := SingletonLockMetadata{PID: os.Getpid(), Hostname: hostname, AcquiredAt: now, Heartbeat: now}
+
+		err = writeSingletonLockMetadata(file, metadata)
+		if err != nil {
+			file.Close()
+			return bosherr.WrapErrorf(err, "Writing lock '%s'", l.path)
+		}
+
+		l.file = file
+		l.stopCh = make(chan struct{})
+		l.doneCh = make(chan struct{})
+		l.lostCh = make(chan struct{})
+
+		if l.ttl > 0 {
+			go l.heartbeat(file, metadata, l.stopCh, l.doneCh, l.lostCh)
+		} else {
+			close(l.doneCh)
+		}
+
+		return nil
+	}
+
+	return bosherr.Errorf("Acquiring lock '%s': lock file kept changing", l.path)
+}
+
+// Lost is closed when another process took over the lock, e.g. because
+// heartbeats could not be written in time
+func (l *SingletonLock) Lost() <-chan struct{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.lostCh
+}
+
+// Holder reads the metadata of the current holder of the lock
+func (l *SingletonLock) Holder() (SingletonLockMetadata, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return SingletonLockMetadata{}, bosherr.WrapErrorf(err, "Opening lock '%s'", l.path)
+	}
+
+	defer file.Close()
+
+	return readSingletonLockMetadata(file), nil
+}
+
+// Release stops the heartbeat, clears the metadata and unlocks the lock file.
+// The file is kept so that a waiting process can not lock a removed file.
+func (l *SingletonLock) Release() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	close(l.stopCh)
+	<-l.doneCh
+
+	var err error
+
+	if l.pathNames(l.file) {
+		err = l.file.Truncate(0)
+	}
+
+	unlockFile(l.file)
+
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	l.file = nil
+
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Releasing lock '%s'", l.path)
+	}
+
+	return nil
+}
+
+func (l *SingletonLock) heartbeat(file *os.File, metadata SingletonLockMetadata, stopCh, doneCh, lostCh chan struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+
+		case <-ticker.C:
+			if !l.pathNames(file) {
+				close(lostCh)
+				return
+			}
+
+			metadata.Heartbeat = time.Now().UTC()
+			writeSingletonLockMetadata(file, metadata)
+		}
+	}
+}
+
+func (l *SingletonLock) isStale(holder SingletonLockMetadata) bool {
+	return l.ttl > 0 && time.Since(holder.Heartbeat) > l.ttl
+}
+
+// removeStale removes the lock file if it still is the stale one. Processes
+// taking over stale locks do so one at a time holding the lock of a second
+// file, so that none of them removes a lock file another one just created.
+func (l *SingletonLock) removeStale(stale os.FileInfo) error {
+	takeover, err := os.OpenFile(l.path+".takeover", os.O_RDWR|os.O_CREATE, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+
+	defer takeover.Close()
+
+	for attempt := 0; ; attempt++ {
+		err = tryLockFile(takeover)
+		if err != errFileLocked || attempt == 100 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return err
+	}
+
+	defer unlockFile(takeover)
+
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	// the holder may have written a heartbeat since
+	holder := readSingletonLockMetadata(file)
+	file.Close()
+
+	if stale == nil || !os.SameFile(info, stale) || !l.isStale(holder) {
+		return nil
+	}
+
+	err = os.Remove(l.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *SingletonLock) pathNames(file *os.File) bool {
+	pathInfo, err := os.Stat(l.path)
+	if err != nil {
+		return false
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(pathInfo, fileInfo)
+}
+
+func writeSingletonLockMetadata(file *os.File, metadata SingletonLockMetadata) error {
+	contents, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteAt(contents, 0)
+	if err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// readSingletonLockMetadata falls back to the modification time of the file
+// as heartbeat when it is being written or was left empty
+func readSingletonLockMetadata(file *os.File) SingletonLockMetadata {
+	var metadata SingletonLockMetadata
+
+	contents, err := io.ReadAll(io.NewSectionReader(file, 0, 1<<20))
+	if err == nil && json.Unmarshal(contents, &metadata) == nil && !metadata.Heartbeat.IsZero() {
+		return metadata
+	}
+
+	if info, err := file.Stat(); err == nil {
+		metadata.Heartbeat = info.ModTime()
+	}
+
+	return metadata
+}
@@ -0,0 +1,149 @@
+package system_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-utils/system"
+)
+
+var _ = Describe("SingletonLock", func() {
+	var lockPath string
+
+	BeforeEach(func() {
+		lockPath = filepath.Join(GinkgoT().TempDir(), "agent.lock")
+	})
+
+	It("records the holder and keeps others out until released", func() {
+		first := NewSingletonLock(lockPath, time.Minute)
+		Expect(first.TryAcquire()).To(Succeed())
+
+		holder, err := first.Holder()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(holder.PID).To(Equal(os.Getpid()))
+		Expect(holder.Heartbeat).To(BeTemporally("~", time.Now(), time.Second))
+
+		second := NewSingletonLock(lockPath, time.Minute)
+
+		err = second.TryAcquire()
+		Expect(IsSingletonLockHeldError(err)).To(BeTrue())
+		Expect(err.(SingletonLockHeldError).Holder.PID).To(Equal(os.Getpid()))
+
+		Expect(first.Release()).To(Succeed())
+		Expect(second.TryAcquire()).To(Succeed())
+		Expect(second.Release()).To(Succeed())
+	})
+
+	It("does not acquire twice", func() {
+		lock := NewSingletonLock(lockPath, 0)
+		Expect(lock.TryAcquire()).To(Succeed())
+		defer lock.Release()
+
+		Expect(lock.TryAcquire()).To(MatchError(ContainSubstring("already acquired")))
+	})
+
+	It("refreshes the heartbeat", func() {
+		lock := NewSingletonLock(lockPath, 300*time.Millisecond)
+		Expect(lock.TryAcquire()).To(Succeed())
+		defer lock.Release()
+
+		acquired, err := lock.Holder()
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(func() time.Time {
+			holder, _ := lock.Holder()
+			return holder.Heartbeat
+		}).Should(BeTemporally(">", acquired.Heartbeat))
+	})
+
+	It("takes over locks whose heartbeat is older than the ttl", func() {
+		if runtime.GOOS == "windows" {
+			Skip("Open lock files can not be replaced on Windows")
+		}
+
+		hung := NewSingletonLock(lockPath, 0)
+		Expect(hung.TryAcquire()).To(Succeed())
+		defer hung.Release()
+
+		stale, err := json.Marshal(SingletonLockMetadata{PID: 12345, Heartbeat: time.Now().Add(-time.Hour)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(lockPath, stale, 0644)).To(Succeed())
+
+		Expect(NewSingletonLock(lockPath, 0).TryAcquire()).To(MatchError(ContainSubstring("held by pid 12345")))
+
+		lock := NewSingletonLock(lockPath, time.Minute)
+		Expect(lock.TryAcquire()).To(Succeed())
+		defer lock.Release()
+
+		holder, err := lock.Holder()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(holder.PID).To(Equal(os.Getpid()))
+	})
+
+	It("lets only one of several processes take over a stale lock", func() {
+		if runtime.GOOS == "windows" {
+			Skip("Open lock files can not be replaced on Windows")
+		}
+
+		for round := 0; round < 50; round++ {
+			Expect(os.RemoveAll(lockPath)).To(Succeed())
+
+			hung := NewSingletonLock(lockPath, 0)
+			Expect(hung.TryAcquire()).To(Succeed())
+
+			stale, err := json.Marshal(SingletonLockMetadata{PID: 12345, Heartbeat: time.Now().Add(-time.Hour)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.WriteFile(lockPath, stale, 0644)).To(Succeed())
+
+			locks := make([]*SingletonLock, 32)
+			errs := make([]error, len(locks))
+
+			var wg sync.WaitGroup
+			for i := range locks {
+				locks[i] = NewSingletonLock(lockPath, time.Minute)
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					errs[i] = locks[i].TryAcquire()
+				}(i)
+			}
+			wg.Wait()
+
+			acquired := 0
+			for i, err := range errs {
+				if err == nil {
+					acquired++
+					Consistently(locks[i].Lost(), 100*time.Millisecond).ShouldNot(BeClosed())
+					Expect(locks[i].Release()).To(Succeed())
+				} else {
+					Expect(IsSingletonLockHeldError(err)).To(BeTrue(), err.Error())
+				}
+			}
+			Expect(acquired).To(Equal(1))
+
+			Expect(hung.Release()).To(Succeed())
+		}
+	})
+
+	It("reports when the lock file was replaced", func() {
+		if runtime.GOOS == "windows" {
+			Skip("Open lock files can not be replaced on Windows")
+		}
+
+		lock := NewSingletonLock(lockPath, 150*time.Millisecond)
+		Expect(lock.TryAcquire()).To(Succeed())
+		defer lock.Release()
+
+		Expect(os.Remove(lockPath)).To(Succeed())
+
+		Eventually(lock.Lost()).Should(BeClosed())
+	})
+})
@@ -0,0 +1,21 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SymlinkError is returned by writes with NoFollow set when the file
+// to write is a symlink, or a reparse point on Windows
+type SymlinkError struct {
+	Path string
+}
+
+func (e SymlinkError) Error() string {
+	return fmt.Sprintf("Refusing to write through symlink '%s'", e.Path)
+}
+
+func IsSymlinkError(err error) bool {
+	var symlinkErr SymlinkError
+	return errors.As(err, &symlinkErr)
+}
@@ -25,6 +25,7 @@ func TestSystem(t *testing.T) {
 var CatExePath string
 var FalseExePath string
 var WindowsExePath string
+var ExecReplaceExePath string
 
 var _ = BeforeSuite(func() {
 	var err error
@@ -36,6 +37,9 @@ var _ = BeforeSuite(func() {
 
 	WindowsExePath, err = gexec.Build("exec_cmd_runner_fixtures/windows_exe/windows_exe.go")
 	Expect(err).ToNot(HaveOccurred())
+
+	ExecReplaceExePath, err = gexec.Build("exec_cmd_runner_fixtures/exec_replace/exec_replace.go")
+	Expect(err).ToNot(HaveOccurred())
 })
 
 var _ = AfterSuite(func() {
@@ -0,0 +1,30 @@
+package system
+
+type WatchOp string
+
+const (
+	WatchCreate WatchOp = "create"
+	WatchWrite  WatchOp = "write"
+	WatchRemove WatchOp = "remove"
+	WatchChmod  WatchOp = "chmod"
+)
+
+type WatchEvent struct {
+	Path string
+	Op   WatchOp
+}
+
+// Watcher reports changes of watched files and of the direct
+// entries of watched directories
+type Watcher interface {
+	Add(path string) error
+	Remove(path string) error
+
+	Events() <-chan WatchEvent
+
+	// Errors are dropped while a previous error was not received
+	Errors() <-chan error
+
+	// Close stops watching and closes Events and Errors
+	Close() error
+}
@@ -0,0 +1,253 @@
+package work
+
+import (
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+type FailurePolicy int
+
+const (
+	// FailFast stops starting new tasks after the first failure
+	FailFast FailurePolicy = iota
+	// ContinueOnError keeps running every task that does not depend on a failed one
+	ContinueOnError
+)
+
+type TaskStatus string
+
+const (
+	TaskSucceeded TaskStatus = "succeeded"
+	TaskFailed    TaskStatus = "failed"
+	TaskSkipped   TaskStatus = "skipped"
+)
+
+type TaskResult struct {
+	ID     string
+	Status TaskStatus
+
+	// Err is the task's error when it failed, or the reason it was skipped
+	Err error
+
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+func (r TaskResult) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+type graphTask struct {
+	id         string
+	deps       []string
+	fn         func() error
+	dependents []string
+}
+
+// Graph runs tasks after all of their dependencies succeeded,
+// running independent tasks in parallel on up to Workers goroutines.
+type Graph struct {
+	Workers int
+	Policy  FailurePolicy
+
+	tasks map[string]*graphTask
+	order []string
+}
+
+func NewGraph(workers int, policy FailurePolicy) *Graph {
+	return &Graph{
+		Workers: workers,
+		Policy:  policy,
+		tasks:   map[string]*graphTask{},
+	}
+}
+
+func (g *Graph) AddTask(id string, deps []string, fn func() error) error {
+	if g.tasks == nil {
+		g.tasks = map[string]*graphTask{}
+	}
+
+	if _, found := g.tasks[id]; found {
+		return bosherr.Errorf("Task '%s' was already added", id)
+	}
+
+	g.tasks[id] = &graphTask{id: id, deps: deps, fn: fn}
+	g.order = append(g.order, id)
+
+	return nil
+}
+
+// Run executes the graph and returns a result for every task. The error
+// combines the errors of failed tasks; skipped tasks are only reported in
+// the results. Unknown dependencies and cycles are reported before any
+// task is started.
+func (g *Graph) Run() (map[string]TaskResult, error) {
+	pending, err := g.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := g.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := map[string]TaskResult{}
+	done := make(chan TaskResult)
+
+	var ready []string
+	for _, id := range g.order {
+		if pending[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	running := 0
+	var firstFailure string
+
+	for len(ready) > 0 || running > 0 {
+		for len(ready) > 0 && running < workers && firstFailure == "" {
+			task := g.tasks[ready[0]]
+			ready = ready[1:]
+			running++
+
+			go func() {
+				result := TaskResult{ID: task.id, StartedAt: time.Now()}
+				result.Err = task.fn()
+				result.FinishedAt = time.Now()
+
+				result.Status = TaskSucceeded
+				if result.Err != nil {
+					result.Status = TaskFailed
+				}
+
+				done <- result
+			}()
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-done
+		running--
+		results[result.ID] = result
+
+		if result.Status == TaskFailed {
+			if g.Policy == FailFast && firstFailure == "" {
+				firstFailure = result.ID
+			}
+
+			g.skipDependents(result.ID, results)
+			continue
+		}
+
+		for _, dependent := range g.tasks[result.ID].dependents {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				if _, skipped := results[dependent]; !skipped {
+					ready = append(ready, dependent)
+				}
+			}
+		}
+	}
+
+	var errs []error
+	for _, id := range g.order {
+		result, found := results[id]
+		if !found {
+			results[id] = TaskResult{
+				ID:     id,
+				Status: TaskSkipped,
+				Err:    bosherr.Errorf("Not started after task '%s' failed", firstFailure),
+			}
+			continue
+		}
+
+		if result.Status == TaskFailed {
+			errs = append(errs, bosherr.WrapErrorf(result.Err, "Task '%s'", id))
+		}
+	}
+
+	if len(errs) > 0 {
+		return results, bosherr.NewMultiError(errs...)
+	}
+
+	return results, nil
+}
+
+// validate links dependents, checks that the graph is acyclic and returns
+// the number of unfinished dependencies per task.
+func (g *Graph) validate() (map[string]int, error) {
+	pending := map[string]int{}
+
+	for _, id := range g.order {
+		task := g.tasks[id]
+		task.dependents = nil
+	}
+
+	for _, id := range g.order {
+		task := g.tasks[id]
+		pending[id] = len(task.deps)
+
+		for _, dep := range task.deps {
+			depTask, found := g.tasks[dep]
+			if !found {
+				return nil, bosherr.Errorf("Task '%s' depends on unknown task '%s'", id, dep)
+			}
+			depTask.dependents = append(depTask.dependents, id)
+		}
+	}
+
+	remaining := map[string]int{}
+	var queue []string
+	for id, count := range pending {
+		remaining[id] = count
+		if count == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	visited := 0
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		visited++
+
+		for _, dependent := range g.tasks[id].dependents {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	if visited != len(g.order) {
+		var cyclic []string
+		for _, id := range g.order {
+			if remaining[id] > 0 {
+				cyclic = append(cyclic, id)
+			}
+		}
+		return nil, bosherr.Errorf("Tasks %v are part of or depend on a dependency cycle", cyclic)
+	}
+
+	return pending, nil
+}
+
+func (g *Graph) skipDependents(failedID string, results map[string]TaskResult) {
+	for _, dependent := range g.tasks[failedID].dependents {
+		if _, found := results[dependent]; found {
+			continue
+		}
+
+		results[dependent] = TaskResult{
+			ID:     dependent,
+			Status: TaskSkipped,
+			Err:    bosherr.Errorf("Dependency '%s' did not succeed", failedID),
+		}
+
+		g.skipDependents(dependent, results)
+	}
+}
@@ -0,0 +1,167 @@
+package work_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-utils/work"
+)
+
+var _ = Describe("Graph", func() {
+	var (
+		orderLock sync.Mutex
+		order     []string
+	)
+
+	record := func(id string) func() error {
+		return func() error {
+			orderLock.Lock()
+			defer orderLock.Unlock()
+			order = append(order, id)
+			return nil
+		}
+	}
+
+	indexOf := func(id string) int {
+		for i, recorded := range order {
+			if recorded == id {
+				return i
+			}
+		}
+		return -1
+	}
+
+	BeforeEach(func() {
+		order = nil
+	})
+
+	It("runs tasks after their dependencies", func() {
+		graph := work.NewGraph(4, work.FailFast)
+		Expect(graph.AddTask("compile", []string{"download"}, record("compile"))).To(Succeed())
+		Expect(graph.AddTask("download", nil, record("download"))).To(Succeed())
+		Expect(graph.AddTask("config", nil, record("config"))).To(Succeed())
+		Expect(graph.AddTask("start", []string{"compile", "config"}, record("start"))).To(Succeed())
+
+		results, err := graph.Run()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(order).To(HaveLen(4))
+		Expect(indexOf("download")).To(BeNumerically("<", indexOf("compile")))
+		Expect(indexOf("compile")).To(BeNumerically("<", indexOf("start")))
+		Expect(indexOf("config")).To(BeNumerically("<", indexOf("start")))
+
+		Expect(results).To(HaveLen(4))
+		for _, result := range results {
+			Expect(result.Status).To(Equal(work.TaskSucceeded))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(result.FinishedAt).ToNot(BeTemporally("<", result.StartedAt))
+		}
+	})
+
+	It("runs independent tasks in parallel up to the number of workers", func() {
+		graph := work.NewGraph(2, work.FailFast)
+
+		var lock sync.Mutex
+		running, maxRunning := 0, 0
+
+		task := func() error {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			lock.Lock()
+			running--
+			lock.Unlock()
+			return nil
+		}
+
+		for _, id := range []string{"a", "b", "c", "d"} {
+			Expect(graph.AddTask(id, nil, task)).To(Succeed())
+		}
+
+		_, err := graph.Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(maxRunning).To(Equal(2))
+	})
+
+	It("rejects duplicate tasks", func() {
+		graph := work.NewGraph(1, work.FailFast)
+		Expect(graph.AddTask("a", nil, record("a"))).To(Succeed())
+
+		err := graph.AddTask("a", nil, record("a"))
+		Expect(err).To(MatchError("Task 'a' was already added"))
+	})
+
+	It("rejects unknown dependencies before running anything", func() {
+		graph := work.NewGraph(1, work.FailFast)
+		Expect(graph.AddTask("a", nil, record("a"))).To(Succeed())
+		Expect(graph.AddTask("b", []string{"missing"}, record("b"))).To(Succeed())
+
+		_, err := graph.Run()
+		Expect(err).To(MatchError("Task 'b' depends on unknown task 'missing'"))
+		Expect(order).To(BeEmpty())
+	})
+
+	It("rejects dependency cycles before running anything", func() {
+		graph := work.NewGraph(1, work.FailFast)
+		Expect(graph.AddTask("root", nil, record("root"))).To(Succeed())
+		Expect(graph.AddTask("a", []string{"root", "b"}, record("a"))).To(Succeed())
+		Expect(graph.AddTask("b", []string{"a"}, record("b"))).To(Succeed())
+
+		_, err := graph.Run()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("[a b] are part of or depend on a dependency cycle"))
+		Expect(order).To(BeEmpty())
+	})
+
+	Context("when a task fails", func() {
+		var graph *work.Graph
+
+		build := func(policy work.FailurePolicy) {
+			graph = work.NewGraph(1, policy)
+			Expect(graph.AddTask("fails", nil, func() error { return errors.New("fake-err") })).To(Succeed())
+			Expect(graph.AddTask("dependent", []string{"fails"}, record("dependent"))).To(Succeed())
+			Expect(graph.AddTask("transitive", []string{"dependent"}, record("transitive"))).To(Succeed())
+			Expect(graph.AddTask("independent", nil, record("independent"))).To(Succeed())
+		}
+
+		It("stops starting tasks with FailFast", func() {
+			build(work.FailFast)
+
+			results, err := graph.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Task 'fails': fake-err"))
+			Expect(order).To(BeEmpty())
+
+			Expect(results["fails"].Status).To(Equal(work.TaskFailed))
+			Expect(results["dependent"].Status).To(Equal(work.TaskSkipped))
+			Expect(results["transitive"].Status).To(Equal(work.TaskSkipped))
+			Expect(results["independent"].Status).To(Equal(work.TaskSkipped))
+			Expect(results["independent"].Err).To(MatchError("Not started after task 'fails' failed"))
+		})
+
+		It("runs unaffected tasks with ContinueOnError", func() {
+			build(work.ContinueOnError)
+
+			results, err := graph.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(order).To(Equal([]string{"independent"}))
+
+			Expect(results["fails"].Status).To(Equal(work.TaskFailed))
+			Expect(results["dependent"].Status).To(Equal(work.TaskSkipped))
+			Expect(results["dependent"].Err).To(MatchError("Dependency 'fails' did not succeed"))
+			Expect(results["transitive"].Status).To(Equal(work.TaskSkipped))
+			Expect(results["transitive"].Err).To(MatchError("Dependency 'dependent' did not succeed"))
+			Expect(results["independent"].Status).To(Equal(work.TaskSucceeded))
+		})
+	})
+})
@@ -1,25 +1,143 @@
 package work
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
 
 type Pool struct {
 	Count int
+
+	// TaskTimeout limits how long the pool waits for every task
+	// unless the task sets its own Timeout
+	TaskTimeout time.Duration
+}
+
+// ShutdownablePool is a Pool that can be shut down.
+// It must not be copied after first use.
+type ShutdownablePool struct {
+	Pool
+
+	lock     sync.Mutex
+	running  sync.WaitGroup
+	cancels  map[*context.CancelFunc]struct{}
+	shutdown bool
+	aborted  bool
+	summary  ShutdownSummary
+}
+
+type ShutdownMode int
+
+const (
+	// ShutdownDrain lets running calls start and finish their queued tasks
+	ShutdownDrain ShutdownMode = iota
+	// ShutdownAbort cancels the contexts of running tasks and starts no others
+	ShutdownAbort
+)
+
+// ShutdownSummary counts the tasks the pool was given over its lifetime
+type ShutdownSummary struct {
+	Completed int
+	Failed    int
+
+	// Cancelled tasks were not started or were canceled by ShutdownAbort
+	Cancelled int
+}
+
+type Task struct {
+	// Name identifies the task in errors
+	Name string
+
+	// Timeout overrides Pool.TaskTimeout when set
+	Timeout time.Duration
+
+	// Do should return once ctx is done. The pool stops waiting for it
+	// when it does not, leaving it running in the background.
+	Do func(ctx context.Context) error
+}
+
+// TimeoutError is returned for tasks that did not finish within their
+// timeout or before the deadline of the pool's context
+type TimeoutError struct {
+	Task string
+
+	// Timeout is zero when the deadline of the pool's context passed
+	Timeout time.Duration
+}
+
+func (e TimeoutError) Error() string {
+	if e.Timeout == 0 {
+		return fmt.Sprintf("Task '%s' did not finish before the deadline", e.Task)
+	}
+	return fmt.Sprintf("Task '%s' timed out after %s", e.Task, e.Timeout)
+}
+
+func (e TimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+func IsTimeoutError(err error) bool {
+	var timeoutErr TimeoutError
+	return errors.As(err, &timeoutErr)
 }
 
 // ParallelDo Runs the given set of tasks in parallel using the configured number of worker go routines
 // Will stop adding new tasks if a task throws an error, but will wait for in-flight tasks to finish
 func (p Pool) ParallelDo(tasks ...func() error) error {
-	jobs := make(chan func() error, len(tasks))
+	return p.ParallelDoContext(context.Background(), contextTasks(tasks)...)
+}
+
+// ParallelDoContext works like ParallelDo and additionally stops starting
+// tasks once ctx is done. Tasks still running at that point fail with
+// a TimeoutError when the deadline of ctx passed.
+func (p Pool) ParallelDoContext(ctx context.Context, tasks ...Task) error {
+	return p.parallelDo(ctx, nil, tasks)
+}
+
+// ParallelDo works like Pool.ParallelDo and fails once the pool is shut down
+func (p *ShutdownablePool) ParallelDo(tasks ...func() error) error {
+	return p.ParallelDoContext(context.Background(), contextTasks(tasks)...)
+}
+
+// ParallelDoContext works like Pool.ParallelDoContext and fails once the
+// pool is shut down
+func (p *ShutdownablePool) ParallelDoContext(ctx context.Context, tasks ...Task) error {
+	ctx, cancel, err := p.begin(ctx, len(tasks))
+	if err != nil {
+		return err
+	}
+	defer p.end(cancel)
+
+	return p.Pool.parallelDo(ctx, p, tasks)
+}
+
+func contextTasks(tasks []func() error) []Task {
+	ctxTasks := make([]Task, len(tasks))
+	for i, task := range tasks {
+		task := task
+		ctxTasks[i] = Task{
+			Name: fmt.Sprintf("#%d", i+1),
+			Do:   func(context.Context) error { return task() },
+		}
+	}
+	return ctxTasks
+}
+
+// parallelDo counts the tasks in shutdownable unless it is nil
+func (p Pool) parallelDo(ctx context.Context, shutdownable *ShutdownablePool, tasks []Task) error {
+	jobs := make(chan Task, len(tasks))
 	errs := make(chan error, len(tasks))
 	wg := &sync.WaitGroup{}
 
+	var notStarted int32
+
 	wg.Add(p.Count)
 	for i := 0; i < p.Count; i++ {
-		p.spawnWorker(jobs, errs, wg)
+		p.spawnWorker(ctx, shutdownable, jobs, errs, &notStarted, wg)
 	}
 
 	for _, task := range tasks {
@@ -30,6 +148,11 @@ func (p Pool) ParallelDo(tasks ...func() error) error {
 
 	wg.Wait()
 
+	// left behind by workers that stopped after an error
+	for range jobs {
+		shutdownable.count(nil, true)
+	}
+
 	close(errs)
 
 	var combinedErrors []error
@@ -37,6 +160,10 @@ func (p Pool) ParallelDo(tasks ...func() error) error {
 		combinedErrors = append(combinedErrors, e)
 	}
 
+	if notStarted > 0 {
+		combinedErrors = append(combinedErrors, bosherr.WrapErrorf(ctx.Err(), "Not starting %d tasks", notStarted))
+	}
+
 	if len(combinedErrors) > 0 {
 		return bosherr.NewMultiError(combinedErrors...)
 	}
@@ -44,10 +171,17 @@ func (p Pool) ParallelDo(tasks ...func() error) error {
 	return nil
 }
 
-func (p Pool) spawnWorker(tasks <-chan func() error, errs chan<- error, wg *sync.WaitGroup) {
+func (p Pool) spawnWorker(ctx context.Context, shutdownable *ShutdownablePool, tasks <-chan Task, errs chan<- error, notStarted *int32, wg *sync.WaitGroup) {
 	go func() {
 		for task := range tasks {
-			err := task()
+			if ctx.Err() != nil {
+				atomic.AddInt32(notStarted, 1)
+				shutdownable.count(nil, true)
+				continue
+			}
+
+			err := p.run(ctx, task)
+			shutdownable.count(err, false)
 			if err != nil {
 				errs <- err
 				break
@@ -57,3 +191,134 @@ func (p Pool) spawnWorker(tasks <-chan func() error, errs chan<- error, wg *sync
 		wg.Done()
 	}()
 }
+
+func (p Pool) run(ctx context.Context, task Task) error {
+	timeout := task.Timeout
+	if timeout == 0 {
+		timeout = p.TaskTimeout
+	}
+
+	taskCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		taskCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- task.Do(taskCtx) }()
+
+	var err error
+
+	select {
+	case err = <-done:
+		if err == nil || taskCtx.Err() == nil {
+			return err
+		}
+	case <-taskCtx.Done():
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return bosherr.WrapErrorf(ctx.Err(), "Task '%s' was canceled", task.Name)
+	case ctx.Err() != nil:
+		return TimeoutError{Task: task.Name}
+	default:
+		return TimeoutError{Task: task.Name, Timeout: timeout}
+	}
+}
+
+// Shutdown stops the pool from running further calls, which fail right
+// away, and waits for running calls to return. ShutdownDrain lets them
+// finish their queued tasks while ShutdownAbort cancels their tasks.
+// When ctx is done first the running tasks are canceled and ctx's error
+// is returned together with the summary of what was done until then.
+func (p *ShutdownablePool) Shutdown(ctx context.Context, mode ShutdownMode) (ShutdownSummary, error) {
+	p.lock.Lock()
+	p.shutdown = true
+	p.lock.Unlock()
+
+	if mode == ShutdownAbort {
+		p.abort()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(done)
+	}()
+
+	var err error
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.abort()
+		err = bosherr.WrapError(ctx.Err(), "Shutting down pool")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.summary, err
+}
+
+// begin registers a call so that Shutdown can wait for and cancel it
+func (p *ShutdownablePool) begin(ctx context.Context, tasks int) (context.Context, *context.CancelFunc, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.shutdown {
+		p.summary.Cancelled += tasks
+		return nil, nil, bosherr.Errorf("Not starting %d tasks: pool is shut down", tasks)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	if p.cancels == nil {
+		p.cancels = map[*context.CancelFunc]struct{}{}
+	}
+	p.cancels[&cancel] = struct{}{}
+
+	p.running.Add(1)
+
+	return ctx, &cancel, nil
+}
+
+func (p *ShutdownablePool) end(cancel *context.CancelFunc) {
+	(*cancel)()
+
+	p.lock.Lock()
+	delete(p.cancels, cancel)
+	p.lock.Unlock()
+
+	p.running.Done()
+}
+
+func (p *ShutdownablePool) abort() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.aborted = true
+
+	for cancel := range p.cancels {
+		(*cancel)()
+	}
+}
+
+func (p *ShutdownablePool) count(err error, notStarted bool) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	switch {
+	case notStarted || (err != nil && p.aborted):
+		p.summary.Cancelled++
+	case err != nil:
+		p.summary.Failed++
+	default:
+		p.summary.Completed++
+	}
+}
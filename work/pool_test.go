@@ -1,7 +1,9 @@
 package work_test
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -97,4 +99,233 @@ var _ = Describe("Pool", func() {
 		Expect(err.Error()).To(ContainSubstring("fake-error"))
 		Expect(err.Error()).To(ContainSubstring("fake-cause"))
 	})
+
+	It("stops waiting for tasks that exceed the task timeout", func() {
+		pool := work.Pool{
+			Count:       2,
+			TaskTimeout: 50 * time.Millisecond,
+		}
+
+		hung := make(chan struct{})
+		defer close(hung)
+
+		err := pool.ParallelDo(
+			func() error {
+				return nil
+			},
+			func() error {
+				<-hung
+				return nil
+			},
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Task '#2' timed out after 50ms"))
+	})
+
+	It("can be used as a value", func() {
+		err := work.Pool{Count: 2}.ParallelDo(func() error { return nil })
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Describe("ParallelDoContext", func() {
+		It("passes contexts to tasks that are done after the task's timeout", func() {
+			pool := work.Pool{
+				Count:       2,
+				TaskTimeout: time.Hour,
+			}
+
+			err := pool.ParallelDoContext(context.Background(),
+				work.Task{
+					Name: "fast",
+					Do:   func(context.Context) error { return nil },
+				},
+				work.Task{
+					Name:    "slow-download",
+					Timeout: 20 * time.Millisecond,
+					Do: func(ctx context.Context) error {
+						<-ctx.Done()
+						return ctx.Err()
+					},
+				},
+			)
+			Expect(err).To(HaveOccurred())
+
+			multiErr, ok := err.(bosherr.MultiError)
+			Expect(ok).To(BeTrue())
+			Expect(multiErr.Errors).To(HaveLen(1))
+			Expect(work.IsTimeoutError(multiErr.Errors[0])).To(BeTrue())
+			Expect(multiErr.Errors[0]).To(Equal(work.TimeoutError{Task: "slow-download", Timeout: 20 * time.Millisecond}))
+			Expect(errors.Is(multiErr.Errors[0], context.DeadlineExceeded)).To(BeTrue())
+		})
+
+		It("attributes the pool deadline to running tasks and does not start others", func() {
+			pool := work.Pool{
+				Count: 1,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			hung := make(chan struct{})
+			defer close(hung)
+
+			err := pool.ParallelDoContext(ctx,
+				work.Task{
+					Name: "hung",
+					Do: func(context.Context) error {
+						<-hung
+						return nil
+					},
+				},
+				work.Task{
+					Name: "never",
+					Do: func(context.Context) error {
+						Fail("Expected second task to not run")
+						return nil
+					},
+				},
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Task 'hung' did not finish before the deadline"))
+			Expect(work.IsTimeoutError(err)).To(BeTrue())
+		})
+
+		It("reports tasks not started because the context was canceled", func() {
+			pool := work.Pool{
+				Count: 2,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := pool.ParallelDoContext(ctx,
+				work.Task{Name: "a", Do: func(context.Context) error { return nil }},
+				work.Task{Name: "b", Do: func(context.Context) error { return nil }},
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Not starting 2 tasks: context canceled"))
+		})
+
+		It("reports tasks interrupted by cancellation", func() {
+			pool := work.Pool{
+				Count: 1,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			err := pool.ParallelDoContext(ctx,
+				work.Task{
+					Name: "interrupted",
+					Do: func(ctx context.Context) error {
+						cancel()
+						<-ctx.Done()
+						return ctx.Err()
+					},
+				},
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Task 'interrupted' was canceled"))
+		})
+	})
+})
+
+var _ = Describe("ShutdownablePool.Shutdown", func() {
+	var (
+		pool    *work.ShutdownablePool
+		started chan struct{}
+		release chan struct{}
+	)
+
+	BeforeEach(func() {
+		pool = &work.ShutdownablePool{Pool: work.Pool{Count: 1}}
+		started = make(chan struct{}, 3)
+		release = make(chan struct{})
+	})
+
+	blocking := func(name string) work.Task {
+		started, release := started, release
+
+		return work.Task{
+			Name: name,
+			Do: func(ctx context.Context) error {
+				started <- struct{}{}
+				select {
+				case <-release:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		}
+	}
+
+	runInBackground := func(tasks ...work.Task) chan error {
+		errCh := make(chan error, 1)
+		go func() { errCh <- pool.ParallelDoContext(context.Background(), tasks...) }()
+		return errCh
+	}
+
+	It("drains queued tasks before returning", func() {
+		errCh := runInBackground(blocking("a"), blocking("b"))
+		Eventually(started).Should(Receive())
+
+		summaryCh := make(chan work.ShutdownSummary, 1)
+		go func() {
+			summary, err := pool.Shutdown(context.Background(), work.ShutdownDrain)
+			Expect(err).ToNot(HaveOccurred())
+			summaryCh <- summary
+		}()
+
+		Consistently(summaryCh, 50*time.Millisecond).ShouldNot(Receive())
+		close(release)
+
+		Eventually(summaryCh).Should(Receive(Equal(work.ShutdownSummary{Completed: 2})))
+		Expect(<-errCh).ToNot(HaveOccurred())
+	})
+
+	It("cancels running tasks and skips queued ones when aborting", func() {
+		defer close(release)
+
+		errCh := runInBackground(blocking("a"), blocking("b"), blocking("c"))
+		Eventually(started).Should(Receive())
+
+		summary, err := pool.Shutdown(context.Background(), work.ShutdownAbort)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(summary).To(Equal(work.ShutdownSummary{Cancelled: 3}))
+
+		err = <-errCh
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Task 'a' was canceled"))
+	})
+
+	It("aborts draining once ctx is done", func() {
+		defer close(release)
+
+		errCh := runInBackground(blocking("a"), blocking("b"))
+		Eventually(started).Should(Receive())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := pool.Shutdown(ctx, work.ShutdownDrain)
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+
+		Eventually(errCh).Should(Receive(HaveOccurred()))
+	})
+
+	It("does not run tasks given after shutdown", func() {
+		_, err := pool.Shutdown(context.Background(), work.ShutdownDrain)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = pool.ParallelDo(func() error {
+			Fail("Expected task to not run")
+			return nil
+		})
+		Expect(err).To(MatchError("Not starting 1 tasks: pool is shut down"))
+
+		summary, err := pool.Shutdown(context.Background(), work.ShutdownDrain)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(summary).To(Equal(work.ShutdownSummary{Cancelled: 1}))
+	})
 })